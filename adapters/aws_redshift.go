@@ -39,9 +39,9 @@ type AwsRedshift struct {
 
 //NewAwsRedshift return configured AwsRedshift adapter instance
 func NewAwsRedshift(ctx context.Context, dsConfig *DataSourceConfig, s3Config *S3Config,
-	queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string) (*AwsRedshift, error) {
+	queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string, tableOptions *TableOptions) (*AwsRedshift, error) {
 
-	postgres, err := NewPostgresUnderRedshift(ctx, dsConfig, queryLogger, reformatMappings(mappingTypeCasts, SchemaToRedshift))
+	postgres, err := NewPostgresUnderRedshift(ctx, dsConfig, queryLogger, reformatMappings(mappingTypeCasts, SchemaToRedshift), tableOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +126,31 @@ func (ar *AwsRedshift) CreateTable(tableSchema *Table) error {
 	return ar.dataSourceProxy.createTableInTransaction(wrappedTx, tableSchema)
 }
 
+//DropTable drop table if exists
+func (ar *AwsRedshift) DropTable(tableName string) error {
+	return ar.dataSourceProxy.DropTable(tableName)
+}
+
+//TablesList return slice of Redshift table names (views are skipped)
+func (ar *AwsRedshift) TablesList() ([]string, error) {
+	return ar.dataSourceProxy.TablesList()
+}
+
+//SelectUserRows return rows of all schema tables where any of user columns equals userId
+func (ar *AwsRedshift) SelectUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return ar.dataSourceProxy.SelectUserRows(userColumns, userId)
+}
+
+//Test check connectivity with a cheap query
+func (ar *AwsRedshift) Test() error {
+	return ar.dataSourceProxy.Test()
+}
+
+//ConfigurePool set connection pool limits
+func (ar *AwsRedshift) ConfigurePool(pool *PoolConfig) {
+	ar.dataSourceProxy.ConfigurePool(pool)
+}
+
 //Close underlying sql.DB
 func (ar *AwsRedshift) Close() error {
 	return ar.dataSourceProxy.Close()
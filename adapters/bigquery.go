@@ -1,15 +1,19 @@
 package adapters
 
 import (
+	"bytes"
 	"cloud.google.com/go/bigquery"
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/timestamp"
 	"github.com/jitsucom/eventnative/typing"
 	"google.golang.org/api/googleapi"
 	"net/http"
 	"strings"
+	"time"
 )
 
 var (
@@ -30,16 +34,19 @@ type BigQuery struct {
 	config           *GoogleConfig
 	queryLogger      *logging.QueryLogger
 	mappingTypeCasts map[string]string
+	tableOptions     *TableOptions
 }
 
 //NewBigQuery return configured BigQuery adapter instance
-func NewBigQuery(ctx context.Context, config *GoogleConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string) (*BigQuery, error) {
+func NewBigQuery(ctx context.Context, config *GoogleConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string,
+	tableOptions *TableOptions) (*BigQuery, error) {
 	client, err := bigquery.NewClient(ctx, config.Project, config.credentials)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating BigQuery client: %v", err)
 	}
 
-	return &BigQuery{ctx: ctx, client: client, config: config, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToBigQueryString)}, nil
+	return &BigQuery{ctx: ctx, client: client, config: config, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToBigQueryString),
+		tableOptions: tableOptions}, nil
 }
 
 //Transfer data from google cloud storage file to google BigQuery table as one batch
@@ -73,10 +80,76 @@ func (bq *BigQuery) Test() error {
 }
 
 //Insert provided object in BigQuery in stream mode
+//event id is used as insertId for BigQuery best effort deduplication of retried inserts
 func (bq *BigQuery) Insert(schema *Table, valuesMap map[string]interface{}) error {
-	inserter := bq.client.Dataset(bq.config.Dataset).Table(schema.Name).Inserter()
-	bq.logQuery(fmt.Sprintf("Inserting values to table %s: ", schema.Name), valuesMap, false)
-	return inserter.Put(bq.ctx, BQItem{values: valuesMap})
+	tableName := bq.partitionTableName(schema.Name, valuesMap)
+	inserter := bq.client.Dataset(bq.config.Dataset).Table(tableName).Inserter()
+	bq.logQuery(fmt.Sprintf("Inserting values to table %s: ", tableName), valuesMap, false)
+	return inserter.Put(bq.ctx, BQItem{values: valuesMap, insertID: events.ExtractEventId(valuesMap)})
+}
+
+//Load objects into BigQuery table with load job from JSON payload (without google cloud storage)
+//objects are grouped by partitions if partition decorator is enabled
+func (bq *BigQuery) Load(schema *Table, objects []map[string]interface{}) error {
+	partitions := map[string]*bytes.Buffer{}
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("Error marshalling object for BigQuery table %s: %v", schema.Name, err)
+		}
+
+		tableName := bq.partitionTableName(schema.Name, object)
+		buf, ok := partitions[tableName]
+		if !ok {
+			buf = &bytes.Buffer{}
+			partitions[tableName] = buf
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	for tableName, buf := range partitions {
+		source := bigquery.NewReaderSource(buf)
+		source.SourceFormat = bigquery.JSON
+		loader := bq.client.Dataset(bq.config.Dataset).Table(tableName).LoaderFrom(source)
+		loader.CreateDisposition = bigquery.CreateNever
+
+		bq.queryLogger.LogQuery(fmt.Sprintf("Loading objects to table %s", tableName))
+		job, err := loader.Run(bq.ctx)
+		if err != nil {
+			return fmt.Errorf("Error running loading to BigQuery table %s: %v", tableName, err)
+		}
+		jobStatus, err := job.Wait(bq.ctx)
+		if err != nil {
+			return fmt.Errorf("Error waiting loading job to BigQuery table %s: %v", tableName, err)
+		}
+		if jobStatus.Err() != nil {
+			return fmt.Errorf("Error loading to BigQuery table %s: %v", tableName, jobStatus.Err())
+		}
+	}
+
+	return nil
+}
+
+//partitionTableName return table name with partition decorator (table$YYYYMMDD) by object timestamp
+//or table name as is if partition decorator is disabled
+func (bq *BigQuery) partitionTableName(tableName string, object map[string]interface{}) string {
+	if !bq.config.PartitionDecorator {
+		return tableName
+	}
+
+	var t time.Time
+	switch value := object[timestamp.Key].(type) {
+	case time.Time:
+		t = value
+	case string:
+		t, _ = time.Parse(time.RFC3339Nano, value)
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return tableName + "$" + t.UTC().Format(timestamp.DayLayout)
 }
 
 //GetTableSchema return google BigQuery table (name,columns) representation wrapped in Table struct
@@ -122,10 +195,22 @@ func (bq *BigQuery) CreateTable(table *Table) error {
 		if ok {
 			bigQueryType = bigquery.FieldType(strings.ToUpper(castedType))
 		}
-		bqSchema = append(bqSchema, &bigquery.FieldSchema{Name: columnName, Type: bigQueryType})
+		bqSchema = append(bqSchema, &bigquery.FieldSchema{Name: columnName, Type: bigQueryType, Description: column.Comment})
+	}
+	tableMetadata := &bigquery.TableMetadata{Name: table.Name, Schema: bqSchema}
+	if bq.config.PartitionDecorator {
+		tableMetadata.TimePartitioning = &bigquery.TimePartitioning{}
+	}
+	//only DAY partitioning granularity is supported
+	partitioning, clusteringFields := bq.tableOptions.forTable(table)
+	if partitioning != nil {
+		tableMetadata.TimePartitioning = &bigquery.TimePartitioning{Field: partitioning.GetField()}
+	}
+	if len(clusteringFields) > 0 {
+		tableMetadata.Clustering = &bigquery.Clustering{Fields: clusteringFields}
 	}
 	bq.logQuery("Creating table for schema: ", bqSchema, true)
-	if err := bqTable.Create(bq.ctx, &bigquery.TableMetadata{Name: table.Name, Schema: bqSchema}); err != nil {
+	if err := bqTable.Create(bq.ctx, tableMetadata); err != nil {
 		return fmt.Errorf("Error creating [%s] BigQuery table %v", table.Name, err)
 	}
 
@@ -164,7 +249,7 @@ func (bq *BigQuery) PatchTableSchema(patchSchema *Table) error {
 		if ok {
 			bigQueryType = bigquery.FieldType(strings.ToUpper(castedType))
 		}
-		metadata.Schema = append(metadata.Schema, &bigquery.FieldSchema{Name: columnName, Type: bigQueryType})
+		metadata.Schema = append(metadata.Schema, &bigquery.FieldSchema{Name: columnName, Type: bigQueryType, Description: column.Comment})
 	}
 	updateReq := bigquery.TableMetadataToUpdate{Schema: metadata.Schema}
 	bq.logQuery("Patch update request: ", updateReq, true)
@@ -192,6 +277,16 @@ func (bq *BigQuery) logQuery(messageTemplate string, entity interface{}, ddl boo
 	}
 }
 
+//DropTable delete table if exists
+func (bq *BigQuery) DropTable(tableName string) error {
+	bq.queryLogger.LogDDL("Deleting table " + tableName)
+	if err := bq.client.Dataset(bq.config.Dataset).Table(tableName).Delete(bq.ctx); err != nil && !isNotFoundErr(err) {
+		return fmt.Errorf("Error deleting BigQuery table [%s]: %v", tableName, err)
+	}
+
+	return nil
+}
+
 func (bq *BigQuery) Close() error {
 	return bq.client.Close()
 }
@@ -202,9 +297,30 @@ func isNotFoundErr(err error) bool {
 	return ok && e.Code == http.StatusNotFound
 }
 
+//IsBigQueryQuotaError return true if google err is quota or rate limit exceeded error
+func IsBigQueryQuotaError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if e, ok := err.(*googleapi.Error); ok {
+		if e.Code == http.StatusTooManyRequests {
+			return true
+		}
+		for _, item := range e.Errors {
+			if item.Reason == "quotaExceeded" || item.Reason == "rateLimitExceeded" {
+				return true
+			}
+		}
+	}
+
+	return strings.Contains(err.Error(), "quotaExceeded") || strings.Contains(err.Error(), "rateLimitExceeded")
+}
+
 //BQItem struct for streaming inserts to BigQuery
 type BQItem struct {
-	values map[string]interface{}
+	values   map[string]interface{}
+	insertID string
 }
 
 func (bqi BQItem) Save() (row map[string]bigquery.Value, insertID string, err error) {
@@ -214,5 +330,7 @@ func (bqi BQItem) Save() (row map[string]bigquery.Value, insertID string, err er
 		row[k] = v
 	}
 
+	//empty insertID means BigQuery generates it (without deduplication)
+	insertID = bqi.insertID
 	return
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/jitsucom/eventnative/typing"
 	"github.com/mailru/go-clickhouse"
 	"io/ioutil"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -24,17 +25,38 @@ const (
 	deleteQueryChTemplate     = `ALTER TABLE %s.%s DELETE WHERE %s`
 	onClusterCHClauseTemplate = ` ON CLUSTER "%s" `
 	columnCHNullableTemplate  = ` Nullable(%s) `
+	userColumnsCHTemplate     = `SELECT table, name FROM system.columns WHERE database = ? AND name IN (%s) AND %s AND table IN (SELECT name FROM system.tables WHERE database = ? AND engine NOT IN ('View', 'MaterializedView'))`
+	selectUserRowsCHTemplate  = `SELECT * FROM "%s"."%s" WHERE %s`
+	columnCHCodecTemplate     = ` CODEC(%s)`
 
-	createTableCHTemplate            = `CREATE TABLE "%s"."%s" %s (%s) %s %s %s %s`
+	createTableCHTemplate            = `CREATE TABLE "%s"."%s" %s (%s) %s %s %s %s %s %s`
 	createDistributedTableCHTemplate = `CREATE TABLE "%s"."dist_%s" %s AS "%s"."%s" ENGINE = Distributed(%s,%s,%s,rand())`
 	dropDistributedTableCHTemplate   = `DROP TABLE "%s"."dist_%s" %s`
-
-	defaultPartition  = `PARTITION BY (toYYYYMM(_timestamp))`
-	defaultOrderBy    = `ORDER BY (eventn_ctx_event_id)`
-	defaultPrimaryKey = ``
+	dropViewCHTemplate               = `DROP VIEW IF EXISTS "%s"."%s" %s`
+	createShardsViewCHTemplate       = `CREATE VIEW "%s"."%s" %s AS %s`
+
+	tableSettingsCHQuery          = `SELECT engine_full, storage_policy FROM system.tables WHERE database = ? AND name = ?`
+	tablesListCHQuery             = `SELECT name FROM system.tables WHERE database = ? AND engine NOT IN ('View', 'MaterializedView', 'Distributed')`
+	columnsCodecsCHQuery          = `SELECT name, type, compression_codec FROM system.columns WHERE database = ? AND table = ?`
+	modifyTTLCHTemplate           = `ALTER TABLE "%s"."%s" %s MODIFY TTL %s`
+	modifyStoragePolicyCHTemplate = `ALTER TABLE "%s"."%s" %s MODIFY SETTING storage_policy = '%s'`
+	modifyColumnCodecCHTemplate   = `ALTER TABLE "%s"."%s" %s MODIFY COLUMN %s %s CODEC(%s)`
+
+	defaultPartition    = `PARTITION BY (toYYYYMM(_timestamp))`
+	defaultOrderByField = `eventn_ctx_event_id`
+	defaultOrderBy      = `ORDER BY (` + defaultOrderByField + `)`
+	defaultPrimaryKey   = ``
 )
 
 var (
+	//partitionExpressions are partition key functions by partitioning granularity
+	partitionExpressions = map[string]string{
+		HOUR:  "toStartOfHour",
+		DAY:   "toYYYYMMDD",
+		MONTH: "toYYYYMM",
+		YEAR:  "toYear",
+	}
+
 	SchemaToClickhouse = map[typing.DataType]string{
 		typing.STRING:    "String",
 		typing.INT64:     "Int64",
@@ -44,6 +66,9 @@ var (
 		typing.UNKNOWN:   "String",
 	}
 
+	//intervalCHRegexp matches 'INTERVAL 90 DAY' which is stored by ClickHouse as 'toIntervalDay(90)'
+	intervalCHRegexp = regexp.MustCompile(`(?i)INTERVAL\s+(\d+)\s+(SECOND|MINUTE|HOUR|DAY|WEEK|MONTH|QUARTER|YEAR)`)
+
 	defaultValues = map[string]interface{}{
 		"int32":                    0,
 		"int64":                    0,
@@ -78,6 +103,11 @@ type EngineConfig struct {
 	PartitionFields []FieldConfig `mapstructure:"partition_fields" json:"partition_fields,omitempty" yaml:"partition_fields,omitempty"`
 	OrderFields     []FieldConfig `mapstructure:"order_fields" json:"order_fields,omitempty" yaml:"order_fields,omitempty"`
 	PrimaryKeys     []string      `mapstructure:"primary_keys" json:"primary_keys,omitempty" yaml:"primary_keys,omitempty"`
+	//Ttl is a table TTL expression e.g. '_timestamp + INTERVAL 90 DAY'
+	Ttl           string `mapstructure:"ttl" json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	StoragePolicy string `mapstructure:"storage_policy" json:"storage_policy,omitempty" yaml:"storage_policy,omitempty"`
+	//Codecs are column compression codecs by column name e.g. {"_timestamp": "DoubleDelta, LZ4"}
+	Codecs map[string]string `mapstructure:"codecs" json:"codecs,omitempty" yaml:"codecs,omitempty"`
 }
 
 //FieldConfig dto for deserialized clickhouse engine fields
@@ -122,11 +152,19 @@ type TableStatementFactory struct {
 	partitionClause  string
 	orderByClause    string
 	primaryKeyClause string
+	ttlClause        string
+	settingsClause   string
+	//ttl, storagePolicy and codecs are used for reconciling existing tables
+	ttl           string
+	storagePolicy string
+	codecs        map[string]string
+	//tableOptions contain only partitioning and clustering which aren't overridden by engine config
+	tableOptions *TableOptions
 
 	engineStatementFormat bool
 }
 
-func NewTableStatementFactory(config *ClickHouseConfig) (*TableStatementFactory, error) {
+func NewTableStatementFactory(config *ClickHouseConfig, tableOptions *TableOptions) (*TableStatementFactory, error) {
 	if config == nil {
 		return nil, errors.New("Clickhouse config can't be nil")
 	}
@@ -138,25 +176,43 @@ func NewTableStatementFactory(config *ClickHouseConfig) (*TableStatementFactory,
 	partitionClause := defaultPartition
 	orderByClause := defaultOrderBy
 	primaryKeyClause := defaultPrimaryKey
+	var ttlClause, settingsClause, ttl, storagePolicy string
+	var codecs map[string]string
+	options := &TableOptions{}
+	if tableOptions != nil {
+		*options = *tableOptions
+	}
 	if config.Engine != nil {
-		//raw statement overrides all provided config parameters
+		codecs = config.Engine.Codecs
+		//raw statement overrides all provided config parameters except column codecs
 		if config.Engine.RawStatement != "" {
 			return &TableStatementFactory{
 				engineStatement: config.Engine.RawStatement,
 				database:        config.Database,
 				onClusterClause: onClusterClause,
+				codecs:          codecs,
 			}, nil
 		}
 
 		if len(config.Engine.PartitionFields) > 0 {
 			partitionClause = "PARTITION BY (" + extractStatement(config.Engine.PartitionFields) + ")"
+			options.Partitioning = nil
 		}
 		if len(config.Engine.OrderFields) > 0 {
 			orderByClause = "ORDER BY (" + extractStatement(config.Engine.OrderFields) + ")"
+			options.ClusteringFields = nil
 		}
 		if len(config.Engine.PrimaryKeys) > 0 {
 			primaryKeyClause = "PRIMARY KEY (" + strings.Join(config.Engine.PrimaryKeys, ", ") + ")"
 		}
+		ttl = config.Engine.Ttl
+		if ttl != "" {
+			ttlClause = "TTL " + ttl
+		}
+		storagePolicy = config.Engine.StoragePolicy
+		if storagePolicy != "" {
+			settingsClause = "SETTINGS storage_policy = '" + storagePolicy + "'"
+		}
 	}
 
 	var engineStatement string
@@ -177,18 +233,44 @@ func NewTableStatementFactory(config *ClickHouseConfig) (*TableStatementFactory,
 		partitionClause:       partitionClause,
 		orderByClause:         orderByClause,
 		primaryKeyClause:      primaryKeyClause,
+		ttlClause:             ttlClause,
+		settingsClause:        settingsClause,
+		ttl:                   ttl,
+		storagePolicy:         storagePolicy,
+		codecs:                codecs,
+		tableOptions:          options,
 		engineStatementFormat: engineStatementFormat,
 	}, nil
 }
 
 //CreateTableStatement return clickhouse DDL for creating table statement
-func (tsf TableStatementFactory) CreateTableStatement(tableName, columnsClause string) string {
+//clustering fields are used as ORDER BY prefix: eventn_ctx_event_id is kept as the last one because it is ReplacingMergeTree deduplication key
+func (tsf TableStatementFactory) CreateTableStatement(table *Table, columnsClause string) string {
 	engineStatement := tsf.engineStatement
 	if tsf.engineStatementFormat {
-		engineStatement = fmt.Sprintf(engineStatement, tableName)
+		engineStatement = fmt.Sprintf(engineStatement, table.Name)
+	}
+
+	partitionClause := tsf.partitionClause
+	orderByClause := tsf.orderByClause
+	partitioning, clusteringFields := tsf.tableOptions.forTable(table)
+	if partitioning != nil {
+		partitionClause = "PARTITION BY (" + partitionExpressions[partitioning.GetGranularity()] + "(" + partitioning.GetField() + "))"
+	}
+	if len(clusteringFields) > 0 {
+		orderByFields := clusteringFields
+		withEventId := false
+		for _, field := range clusteringFields {
+			withEventId = withEventId || field == defaultOrderByField
+		}
+		if !withEventId {
+			orderByFields = append(orderByFields, defaultOrderByField)
+		}
+		orderByClause = "ORDER BY (" + strings.Join(orderByFields, ",") + ")"
 	}
-	return fmt.Sprintf(createTableCHTemplate, tsf.database, tableName, tsf.onClusterClause, columnsClause, engineStatement,
-		tsf.partitionClause, tsf.orderByClause, tsf.primaryKeyClause)
+
+	return fmt.Sprintf(createTableCHTemplate, tsf.database, table.Name, tsf.onClusterClause, columnsClause, engineStatement,
+		partitionClause, orderByClause, tsf.primaryKeyClause, tsf.ttlClause, tsf.settingsClause)
 }
 
 //ClickHouse is adapter for creating,patching (schema or table), inserting data to clickhouse
@@ -300,19 +382,12 @@ func (ch *ClickHouse) CreateTable(tableSchema *Table) error {
 			sqlType = castedSqlType
 		}
 
-		//get nullable or plain
-		var addColumnDDL string
-		if _, ok := ch.nullableFields[columnName]; ok {
-			addColumnDDL = columnName + fmt.Sprintf(columnCHNullableTemplate, sqlType)
-		} else {
-			addColumnDDL = columnName + " " + sqlType
-		}
-		columnsDDL = append(columnsDDL, addColumnDDL)
+		columnsDDL = append(columnsDDL, columnName+" "+ch.columnTypeDDL(columnName, sqlType))
 	}
 
 	//sorting columns asc
 	sort.Strings(columnsDDL)
-	statementStr := ch.tableStatementFactory.CreateTableStatement(tableSchema.Name, strings.Join(columnsDDL, ","))
+	statementStr := ch.tableStatementFactory.CreateTableStatement(tableSchema, strings.Join(columnsDDL, ","))
 	ch.queryLogger.LogDDL(statementStr)
 
 	_, err := ch.dataSource.ExecContext(ch.ctx, statementStr)
@@ -328,6 +403,29 @@ func (ch *ClickHouse) CreateTable(tableSchema *Table) error {
 	return nil
 }
 
+//TablesList return slice of ClickHouse table names (views and distributed tables are skipped)
+func (ch *ClickHouse) TablesList() ([]string, error) {
+	var tableNames []string
+	rows, err := ch.dataSource.QueryContext(ch.ctx, tablesListCHQuery, ch.database)
+	if err != nil {
+		return tableNames, fmt.Errorf("Error querying tables names: %v", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return tableNames, fmt.Errorf("Error scanning table name: %v", err)
+		}
+		tableNames = append(tableNames, tableName)
+	}
+	if err := rows.Err(); err != nil {
+		return tableNames, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return tableNames, nil
+}
+
 //GetTableSchema return table (name,columns with name and types) representation wrapped in Table struct
 func (ch *ClickHouse) GetTableSchema(tableName string) (*Table, error) {
 	table := &Table{Name: tableName, Columns: map[string]Column{}}
@@ -368,13 +466,7 @@ func (ch *ClickHouse) PatchTableSchema(patchSchema *Table) error {
 			sqlType = castedSqlType
 		}
 
-		//get nullable or plain
-		var columnTypeDDL string
-		if _, ok := ch.nullableFields[columnName]; ok {
-			columnTypeDDL = fmt.Sprintf(columnCHNullableTemplate, sqlType)
-		} else {
-			columnTypeDDL = sqlType
-		}
+		columnTypeDDL := ch.columnTypeDDL(columnName, sqlType)
 		query := fmt.Sprintf(addColumnCHTemplate, ch.database, patchSchema.Name, ch.getOnClusterClause(), columnName, columnTypeDDL)
 		ch.queryLogger.LogDDL(query)
 		alterStmt, err := wrappedTx.tx.PrepareContext(ch.ctx, query)
@@ -396,7 +488,12 @@ func (ch *ClickHouse) PatchTableSchema(patchSchema *Table) error {
 		ch.createDistributedTableInTransaction(patchSchema.Name)
 	}
 
-	return wrappedTx.tx.Commit()
+	if err := wrappedTx.tx.Commit(); err != nil {
+		return err
+	}
+
+	ch.reconcileTableSettings(patchSchema.Name)
+	return nil
 }
 
 //Insert provided object in ClickHouse in stream mode
@@ -529,6 +626,39 @@ func (ch *ClickHouse) insertInTransaction(wrappedTx *Transaction, table *Table,
 	return nil
 }
 
+//SelectUserRows return rows of all database tables where any of user columns equals userId (data-subject access requests)
+//in cluster mode rows are selected from distributed tables (from all shards) otherwise from local tables. Views are skipped
+func (ch *ClickHouse) SelectUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	tablesCondition := `table NOT LIKE 'dist\\_%'`
+	if ch.cluster != "" {
+		tablesCondition = `table LIKE 'dist\\_%'`
+	}
+
+	return selectUserRows(ch.ctx, ch.dataSource, &userRowsQueries{
+		columnsQuery: func(userColumns []string) (string, []interface{}) {
+			query := fmt.Sprintf(userColumnsCHTemplate, placeholders(len(userColumns), 1, func(i int) string { return "?" }), tablesCondition)
+			return query, append(append([]interface{}{ch.database}, toInterfaces(userColumns)...), ch.database)
+		},
+		selectQuery: func(tableName, condition string) string {
+			return fmt.Sprintf(selectUserRowsCHTemplate, ch.database, tableName, condition)
+		},
+		whereCondition: func(column string, i int) string {
+			return fmt.Sprintf(`toString("%s") = ?`, column)
+		},
+	}, userColumns, userId)
+}
+
+//Test check connectivity with a cheap query
+func (ch *ClickHouse) Test() error {
+	_, err := ch.dataSource.ExecContext(ch.ctx, "SELECT 1")
+	return err
+}
+
+//ConfigurePool set connection pool limits
+func (ch *ClickHouse) ConfigurePool(pool *PoolConfig) {
+	pool.apply(ch.dataSource)
+}
+
 //Close underlying sql.DB
 func (ch *ClickHouse) Close() error {
 	if err := ch.dataSource.Close(); err != nil {
@@ -538,6 +668,31 @@ func (ch *ClickHouse) Close() error {
 	return nil
 }
 
+//CreateShardsView drop and create view over all shards (distributed tables of shards in cluster mode)
+//missing columns are selected as default values of their types
+func (ch *ClickHouse) CreateShardsView(view *ShardsView) error {
+	selectQuery := view.SelectQuery(func(shard string) string {
+		if ch.cluster != "" {
+			return fmt.Sprintf(`"%s"."dist_%s"`, ch.database, shard)
+		}
+		return fmt.Sprintf(`"%s"."%s"`, ch.database, shard)
+	}, func(column Column) string {
+		return fmt.Sprintf("defaultValueOfTypeName('%s')", column.SqlType)
+	})
+
+	for _, query := range []string{
+		fmt.Sprintf(dropViewCHTemplate, ch.database, view.Name, ch.getOnClusterClause()),
+		fmt.Sprintf(createShardsViewCHTemplate, ch.database, view.Name, ch.getOnClusterClause(), selectQuery),
+	} {
+		ch.queryLogger.LogDDL(query)
+		if _, err := ch.dataSource.ExecContext(ch.ctx, query); err != nil {
+			return fmt.Errorf("Error creating [%s] shards view: %v", view.Name, err)
+		}
+	}
+
+	return nil
+}
+
 //return ON CLUSTER name clause or "" if config.cluster is empty
 func (ch *ClickHouse) getOnClusterClause() string {
 	if ch.cluster == "" {
@@ -575,6 +730,80 @@ func (ch *ClickHouse) dropDistributedTableInTransaction(wrappedTx *Transaction,
 	}
 }
 
+//return nullable or plain column type with compression codec if it is configured
+func (ch *ClickHouse) columnTypeDDL(columnName, sqlType string) string {
+	columnTypeDDL := sqlType
+	if _, ok := ch.nullableFields[columnName]; ok {
+		columnTypeDDL = fmt.Sprintf(columnCHNullableTemplate, sqlType)
+	}
+	if codec, ok := ch.tableStatementFactory.codecs[columnName]; ok {
+		columnTypeDDL += fmt.Sprintf(columnCHCodecTemplate, codec)
+	}
+
+	return columnTypeDDL
+}
+
+//reconcileTableSettings apply configured TTL, storage policy and column codecs to the existing table if they differ
+//settings which aren't configured are left as is. Errors are only logged because they don't affect data inserting
+func (ch *ClickHouse) reconcileTableSettings(tableName string) {
+	tsf := ch.tableStatementFactory
+	if tsf.ttl != "" || tsf.storagePolicy != "" {
+		var engineFull, storagePolicy string
+		if err := ch.dataSource.QueryRowContext(ch.ctx, tableSettingsCHQuery, ch.database, tableName).Scan(&engineFull, &storagePolicy); err != nil {
+			logging.Errorf("Error querying table [%s] settings: %v", tableName, err)
+			return
+		}
+
+		if tsf.ttl != "" && normalizeCHExpression(extractTTL(engineFull)) != normalizeCHExpression(tsf.ttl) {
+			ch.execDDL(fmt.Sprintf(modifyTTLCHTemplate, ch.database, tableName, ch.getOnClusterClause(), tsf.ttl))
+		}
+		if tsf.storagePolicy != "" && storagePolicy != tsf.storagePolicy {
+			ch.execDDL(fmt.Sprintf(modifyStoragePolicyCHTemplate, ch.database, tableName, ch.getOnClusterClause(), tsf.storagePolicy))
+		}
+	}
+
+	if len(tsf.codecs) == 0 {
+		return
+	}
+
+	rows, err := ch.dataSource.QueryContext(ch.ctx, columnsCodecsCHQuery, ch.database, tableName)
+	if err != nil {
+		logging.Errorf("Error querying table [%s] columns codecs: %v", tableName, err)
+		return
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var columnName, columnType, codec string
+		if err := rows.Scan(&columnName, &columnType, &codec); err != nil {
+			logging.Errorf("Error scanning table [%s] columns codecs: %v", tableName, err)
+			return
+		}
+
+		configured, ok := tsf.codecs[columnName]
+		if ok && normalizeCHExpression(codec) != normalizeCHExpression(fmt.Sprintf(columnCHCodecTemplate, configured)) {
+			statements = append(statements, fmt.Sprintf(modifyColumnCodecCHTemplate, ch.database, tableName, ch.getOnClusterClause(), columnName, columnType, configured))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logging.Errorf("Error reading table [%s] columns codecs: %v", tableName, err)
+		return
+	}
+
+	for _, statement := range statements {
+		ch.execDDL(statement)
+	}
+}
+
+//execute DDL statement, ignore errors
+func (ch *ClickHouse) execDDL(statement string) {
+	ch.queryLogger.LogDDL(statement)
+	if _, err := ch.dataSource.ExecContext(ch.ctx, statement); err != nil {
+		logging.Errorf("Error executing statement [%s]: %v", statement, err)
+	}
+}
+
 func (ch *ClickHouse) getPlaceholder(columnName string) string {
 	castType, ok := ch.mappingTypeCasts[columnName]
 	if ok {
@@ -626,3 +855,25 @@ func extractStatement(fieldConfigs []FieldConfig) string {
 	}
 	return strings.Join(parameters, ",")
 }
+
+//extractTTL return TTL expression from system.tables engine_full value or empty string
+func extractTTL(engineFull string) string {
+	i := strings.Index(engineFull, " TTL ")
+	if i < 0 {
+		return ""
+	}
+
+	ttl := engineFull[i+len(" TTL "):]
+	if j := strings.Index(ttl, " SETTINGS "); j >= 0 {
+		ttl = ttl[:j]
+	}
+
+	return ttl
+}
+
+//normalizeCHExpression return expression in the form which is comparable with the one stored by ClickHouse:
+//without spaces, in lower case and with toInterval*() functions instead of INTERVAL operators
+func normalizeCHExpression(expression string) string {
+	expression = intervalCHRegexp.ReplaceAllString(expression, "toInterval${2}(${1})")
+	return strings.ToLower(strings.Join(strings.Fields(expression), ""))
+}
@@ -150,15 +150,52 @@ func TestTableStatementFactory(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			factory, err := NewTableStatementFactory(tt.inputConfig)
+			factory, err := NewTableStatementFactory(tt.inputConfig, nil)
 			if tt.expectedTableStatement == "" {
 				require.Error(t, err, "Clickhouse config can't be nil")
 				return
 			}
 			require.NotNil(t, factory)
 
-			actual := factory.CreateTableStatement("test_table", "a String,b String,c String,d String")
+			actual := factory.CreateTableStatement(&Table{Name: "test_table"}, "a String,b String,c String,d String")
 			require.Equal(t, tt.expectedTableStatement, strings.TrimSpace(actual), "Statements aren't equal")
 		})
 	}
 }
+
+func TestTableStatementFactoryWithTableOptions(t *testing.T) {
+	table := &Table{Name: "test_table", Columns: Columns{"_timestamp": Column{}, "event_type": Column{}, "eventn_ctx_event_id": Column{}}}
+	tableOptions := &TableOptions{Partitioning: &PartitioningConfig{Granularity: "day"}, ClusteringFields: []string{"event_type", "absent"}}
+
+	factory, err := NewTableStatementFactory(&ClickHouseConfig{Database: "db1"}, tableOptions)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE \"db1\".\"test_table\"  (a String) ENGINE = ReplacingMergeTree(_timestamp) PARTITION BY (toYYYYMMDD(_timestamp)) ORDER BY (event_type,eventn_ctx_event_id)",
+		strings.TrimSpace(factory.CreateTableStatement(table, "a String")))
+
+	//engine config overrides table options
+	factory, err = NewTableStatementFactory(&ClickHouseConfig{Database: "db1", Engine: &EngineConfig{OrderFields: []FieldConfig{{Field: "id"}}}}, tableOptions)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE \"db1\".\"test_table\"  (a String) ENGINE = ReplacingMergeTree(_timestamp) PARTITION BY (toYYYYMMDD(_timestamp)) ORDER BY (id)",
+		strings.TrimSpace(factory.CreateTableStatement(table, "a String")))
+}
+
+func TestTableStatementFactoryWithTtl(t *testing.T) {
+	factory, err := NewTableStatementFactory(&ClickHouseConfig{Database: "db1", Engine: &EngineConfig{Ttl: "_timestamp + INTERVAL 90 DAY",
+		StoragePolicy: "hot_cold", Codecs: map[string]string{"a": "ZSTD(3)"}}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE \"db1\".\"test_table\"  (a String) ENGINE = ReplacingMergeTree(_timestamp) PARTITION BY (toYYYYMM(_timestamp)) ORDER BY (eventn_ctx_event_id)  TTL _timestamp + INTERVAL 90 DAY SETTINGS storage_policy = 'hot_cold'",
+		strings.TrimSpace(factory.CreateTableStatement(&Table{Name: "test_table"}, "a String")))
+
+	ch := &ClickHouse{tableStatementFactory: factory, nullableFields: map[string]bool{"a": true}}
+	require.Equal(t, " Nullable(String)  CODEC(ZSTD(3))", ch.columnTypeDDL("a", "String"))
+	require.Equal(t, "String", ch.columnTypeDDL("b", "String"))
+}
+
+func TestNormalizeTtl(t *testing.T) {
+	engineFull := "ReplacingMergeTree(_timestamp) PARTITION BY toYYYYMM(_timestamp) ORDER BY eventn_ctx_event_id TTL _timestamp + toIntervalDay(90) SETTINGS index_granularity = 8192"
+	require.Equal(t, "_timestamp + toIntervalDay(90)", extractTTL(engineFull))
+	require.Equal(t, normalizeCHExpression(extractTTL(engineFull)), normalizeCHExpression("_timestamp + INTERVAL 90 DAY"))
+	require.NotEqual(t, normalizeCHExpression(extractTTL(engineFull)), normalizeCHExpression("_timestamp + interval 30 day"))
+	require.Equal(t, "", extractTTL("ReplacingMergeTree(_timestamp) ORDER BY eventn_ctx_event_id SETTINGS index_granularity = 8192"))
+	require.Equal(t, normalizeCHExpression("CODEC(ZSTD(3))"), normalizeCHExpression(" CODEC(ZSTD(3))"))
+}
@@ -0,0 +1,123 @@
+package adapters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"io"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionLZ4  = "lz4"
+)
+
+var compressionCodecs = map[string]struct {
+	extension   string
+	contentType string
+	maxLevel    int
+}{
+	CompressionNone: {"", "", 0},
+	CompressionGzip: {".gz", "application/gzip", gzip.BestCompression},
+	CompressionZstd: {".zst", "application/zstd", 22},
+	CompressionLZ4:  {".lz4", "application/x-lz4", 12},
+}
+
+//CompressionConfig is a file destinations compression codec with level
+//Level 0 means codec default level. Levels: gzip 1-9, zstd 1-22 (mapped to the closest encoder level), lz4 1-12
+type CompressionConfig struct {
+	Codec string `mapstructure:"codec" json:"codec,omitempty" yaml:"codec,omitempty"`
+	Level int    `mapstructure:"level" json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+//Validate return err if codec is unknown or level is out of codec range
+func (cc *CompressionConfig) Validate() error {
+	if cc == nil {
+		return nil
+	}
+
+	if cc.Codec == "" {
+		cc.Codec = CompressionNone
+	}
+	codec, ok := compressionCodecs[cc.Codec]
+	if !ok {
+		return fmt.Errorf("Unknown compression codec: %s. Available codecs: [%s, %s, %s, %s]", cc.Codec, CompressionNone, CompressionGzip, CompressionZstd, CompressionLZ4)
+	}
+	if cc.Level < 0 || cc.Level > codec.maxLevel {
+		return fmt.Errorf("Compression level %d is out of %s codec range [0, %d]", cc.Level, cc.Codec, codec.maxLevel)
+	}
+
+	return nil
+}
+
+//GetCodec return configured codec or none if config is nil
+func (cc *CompressionConfig) GetCodec() string {
+	if cc == nil || cc.Codec == "" {
+		return CompressionNone
+	}
+	return cc.Codec
+}
+
+//Extension return file extension of configured codec (with leading dot) or empty string
+func (cc *CompressionConfig) Extension() string {
+	return compressionCodecs[cc.GetCodec()].extension
+}
+
+//ContentType return content type of compressed payload or empty string if payload isn't compressed
+func (cc *CompressionConfig) ContentType() string {
+	return compressionCodecs[cc.GetCodec()].contentType
+}
+
+//Compress return payload compressed with configured codec and level
+func (cc *CompressionConfig) Compress(payload []byte) ([]byte, error) {
+	codec := cc.GetCodec()
+	if codec == CompressionNone {
+		return payload, nil
+	}
+
+	buf := &bytes.Buffer{}
+	var writer io.WriteCloser
+	switch codec {
+	case CompressionGzip:
+		level := gzip.DefaultCompression
+		if cc.Level != 0 {
+			level = cc.Level
+		}
+		gzipWriter, err := gzip.NewWriterLevel(buf, level)
+		if err != nil {
+			return nil, err
+		}
+		writer = gzipWriter
+	case CompressionZstd:
+		var options []zstd.EOption
+		if cc.Level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cc.Level)))
+		}
+		zstdWriter, err := zstd.NewWriter(buf, options...)
+		if err != nil {
+			return nil, err
+		}
+		writer = zstdWriter
+	case CompressionLZ4:
+		lz4Writer := lz4.NewWriter(buf)
+		lz4Writer.Header.CompressionLevel = cc.Level
+		writer = lz4Writer
+	default:
+		return nil, errors.New("Unknown compression codec: " + codec)
+	}
+
+	if _, err := writer.Write(payload); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("Error compressing payload with %s: %v", codec, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("Error finishing %s compression: %v", codec, err)
+	}
+
+	return buf.Bytes(), nil
+}
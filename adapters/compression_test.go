@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCompressionConfigValidate(t *testing.T) {
+	var nilConfig *CompressionConfig
+	require.NoError(t, nilConfig.Validate())
+	require.Equal(t, CompressionNone, nilConfig.GetCodec())
+	require.Equal(t, "", nilConfig.Extension())
+
+	require.Error(t, (&CompressionConfig{Codec: "bzip2"}).Validate())
+	require.Error(t, (&CompressionConfig{Codec: CompressionGzip, Level: 10}).Validate())
+	require.Error(t, (&CompressionConfig{Codec: CompressionZstd, Level: -1}).Validate())
+	require.NoError(t, (&CompressionConfig{Codec: CompressionZstd, Level: 19}).Validate())
+
+	config := &CompressionConfig{}
+	require.NoError(t, config.Validate())
+	require.Equal(t, CompressionNone, config.Codec)
+}
+
+func TestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"event_type":"pageview","url":"https://jitsu.com"}`+"\n"), 100)
+	tests := []struct {
+		name              string
+		config            *CompressionConfig
+		expectedExtension string
+		newReader         func(io.Reader) (io.Reader, error)
+	}{
+		{
+			"gzip",
+			&CompressionConfig{Codec: CompressionGzip, Level: 9},
+			".gz",
+			func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			"zstd",
+			&CompressionConfig{Codec: CompressionZstd, Level: 19},
+			".zst",
+			func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		},
+		{
+			"lz4",
+			&CompressionConfig{Codec: CompressionLZ4},
+			".lz4",
+			func(r io.Reader) (io.Reader, error) { return lz4.NewReader(r), nil },
+		},
+		{
+			"none",
+			&CompressionConfig{Codec: CompressionNone},
+			"",
+			func(r io.Reader) (io.Reader, error) { return r, nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.config.Validate())
+			require.Equal(t, tt.expectedExtension, tt.config.Extension())
+
+			compressed, err := tt.config.Compress(payload)
+			require.NoError(t, err)
+			if tt.config.Codec != CompressionNone {
+				require.True(t, len(compressed) < len(payload))
+			}
+
+			r, err := tt.newReader(bytes.NewReader(compressed))
+			require.NoError(t, err)
+			decompressed, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, payload, decompressed)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	druidStringType = "string"
+
+	druidSupervisorPath = "/druid/indexer/v1/supervisor"
+)
+
+var (
+	//SchemaToDruid is a mapping between types and Druid dimension types
+	SchemaToDruid = map[typing.DataType]string{
+		typing.STRING:    druidStringType,
+		typing.INT64:     "long",
+		typing.FLOAT64:   "double",
+		typing.TIMESTAMP: druidStringType,
+		typing.BOOL:      druidStringType,
+		typing.UNKNOWN:   druidStringType,
+	}
+)
+
+//DruidConfig is a Druid Kafka indexing service configuration
+type DruidConfig struct {
+	OverlordUrl string `mapstructure:"overlord_url" json:"overlord_url,omitempty" yaml:"overlord_url,omitempty"`
+	Username    string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password    string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	//SegmentGranularity of datasources. Default value is hour
+	SegmentGranularity string       `mapstructure:"segment_granularity" json:"segment_granularity,omitempty" yaml:"segment_granularity,omitempty"`
+	Kafka              *KafkaConfig `mapstructure:"kafka" json:"kafka,omitempty" yaml:"kafka,omitempty"`
+}
+
+//Validate required fields in DruidConfig
+func (dc *DruidConfig) Validate() error {
+	if dc == nil {
+		return errors.New("druid config is required")
+	}
+	if dc.OverlordUrl == "" {
+		return errors.New("overlord_url is required parameter")
+	}
+
+	return dc.Kafka.Validate()
+}
+
+type druidDimension struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+//Druid produces events into Kafka topics (one topic per datasource) and manages Druid Kafka supervisors:
+//supervisor spec dimensions are generated from the table schema
+type Druid struct {
+	config      *DruidConfig
+	producer    *KafkaProducer
+	client      *http.Client
+	debugLogger *logging.QueryLogger
+}
+
+func NewDruid(ctx context.Context, config *DruidConfig, requestDebugLogger *logging.QueryLogger) *Druid {
+	if config.SegmentGranularity == "" {
+		config.SegmentGranularity = "hour"
+	}
+
+	return &Druid{
+		config:      config,
+		producer:    NewKafkaProducer(ctx, config.Kafka),
+		client:      &http.Client{Timeout: 30 * time.Second},
+		debugLogger: requestDebugLogger,
+	}
+}
+
+//Insert produce event into the datasource Kafka topic
+func (d *Druid) Insert(table *Table, event map[string]interface{}) error {
+	return d.producer.Produce(d.config.Kafka.Topic(table.Name), event)
+}
+
+//Test check Druid overlord availability
+func (d *Druid) Test() error {
+	if _, err := d.request(http.MethodGet, "/status", nil, nil); err != nil {
+		return fmt.Errorf("Error connecting to Druid overlord: %v", err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return datasource dimensions from the supervisor spec or empty Table if supervisor doesn't exist
+func (d *Druid) GetTableSchema(tableName string) (*Table, error) {
+	table := &Table{Name: tableName, Columns: Columns{}, PKFields: map[string]bool{}}
+
+	response := struct {
+		Spec struct {
+			DataSchema struct {
+				DimensionsSpec struct {
+					Dimensions []druidDimension `json:"dimensions"`
+				} `json:"dimensionsSpec"`
+			} `json:"dataSchema"`
+		} `json:"spec"`
+	}{}
+	found, err := d.request(http.MethodGet, druidSupervisorPath+"/"+tableName, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return table, nil
+	}
+
+	for _, dimension := range response.Spec.DataSchema.DimensionsSpec.Dimensions {
+		table.Columns[dimension.Name] = Column{SqlType: dimension.Type}
+	}
+	//timestamp column is used as Druid __time
+	table.Columns[timestamp.Key] = Column{SqlType: druidStringType}
+
+	return table, nil
+}
+
+//CreateTable submit supervisor spec with table columns as dimensions
+func (d *Druid) CreateTable(tableSchema *Table) error {
+	return d.submitSupervisor(tableSchema)
+}
+
+//PatchTableSchema submit supervisor spec with current and new dimensions
+//Druid applies the new spec to the running supervisor
+func (d *Druid) PatchTableSchema(patchSchema *Table) error {
+	current, err := d.GetTableSchema(patchSchema.Name)
+	if err != nil {
+		return err
+	}
+
+	for name, column := range patchSchema.Columns {
+		current.Columns[name] = column
+	}
+
+	return d.submitSupervisor(current)
+}
+
+func (d *Druid) submitSupervisor(table *Table) error {
+	spec := d.buildSupervisorSpec(table)
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("Error marshalling Druid supervisor spec: %v", err)
+	}
+	d.debugLogger.LogDDL(string(b))
+
+	if _, err := d.request(http.MethodPost, druidSupervisorPath, b, nil); err != nil {
+		return fmt.Errorf("Error submitting Druid supervisor [%s]: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+//buildSupervisorSpec return Kafka supervisor spec without rollup: table columns (except timestamp) are dimensions
+func (d *Druid) buildSupervisorSpec(table *Table) map[string]interface{} {
+	var names []string
+	for name := range table.Columns {
+		if name != timestamp.Key {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	dimensions := []druidDimension{}
+	for _, name := range names {
+		dimensions = append(dimensions, druidDimension{Type: table.Columns[name].SqlType, Name: name})
+	}
+
+	return map[string]interface{}{
+		"type": "kafka",
+		"spec": map[string]interface{}{
+			"dataSchema": map[string]interface{}{
+				"dataSource":      table.Name,
+				"timestampSpec":   map[string]interface{}{"column": timestamp.Key, "format": "auto"},
+				"dimensionsSpec":  map[string]interface{}{"dimensions": dimensions},
+				"granularitySpec": map[string]interface{}{"type": "uniform", "segmentGranularity": d.config.SegmentGranularity, "queryGranularity": "none", "rollup": false},
+			},
+			"ioConfig": map[string]interface{}{
+				"topic":              d.config.Kafka.Topic(table.Name),
+				"inputFormat":        map[string]interface{}{"type": "json"},
+				"consumerProperties": map[string]interface{}{"bootstrap.servers": strings.Join(d.config.Kafka.Brokers, ",")},
+				"useEarliestOffset":  true,
+			},
+			"tuningConfig": map[string]interface{}{"type": "kafka"},
+		},
+	}
+}
+
+//request send request to Druid overlord and decode JSON response into result (if not nil)
+//return false if response is 404
+func (d *Druid) request(method, path string, body []byte, result interface{}) (bool, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(d.config.OverlordUrl, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.config.Username != "" {
+		req.SetBasicAuth(d.config.Username, d.config.Password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("Error reading Druid response: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Druid response code: %d body: %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return false, fmt.Errorf("Error parsing Druid response: %v", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (d *Druid) Close() error {
+	d.client.CloseIdleConnections()
+	return d.producer.Close()
+}
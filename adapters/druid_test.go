@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDruidPatchTableSchema(t *testing.T) {
+	var submitted map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/druid/indexer/v1/supervisor/events":
+			w.Write([]byte(`{"spec":{"dataSchema":{"dimensionsSpec":{"dimensions":[{"type":"string","name":"event_type"}]}}}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/druid/indexer/v1/supervisor":
+			body, _ := ioutil.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &submitted))
+			w.Write([]byte(`{"id":"events"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	druid := NewDruid(context.Background(), &DruidConfig{OverlordUrl: server.URL, Kafka: &KafkaConfig{Brokers: []string{"kafka:9092"}, TopicPrefix: "en_"}},
+		logging.NewQueryLogger("test", nil, nil))
+	defer druid.Close()
+
+	table, err := druid.GetTableSchema("events")
+	require.NoError(t, err)
+	require.Equal(t, Columns{"event_type": Column{SqlType: "string"}, "_timestamp": Column{SqlType: "string"}}, table.Columns)
+
+	notExist, err := druid.GetTableSchema("not_exist")
+	require.NoError(t, err)
+	require.Empty(t, notExist.Columns)
+
+	err = druid.PatchTableSchema(&Table{Name: "events", Columns: Columns{"amount": Column{SqlType: "double"}}})
+	require.NoError(t, err)
+
+	spec := submitted["spec"].(map[string]interface{})
+	dataSchema := spec["dataSchema"].(map[string]interface{})
+	require.Equal(t, "events", dataSchema["dataSource"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"type": "double", "name": "amount"},
+		map[string]interface{}{"type": "string", "name": "event_type"},
+	}, dataSchema["dimensionsSpec"].(map[string]interface{})["dimensions"])
+
+	ioConfig := spec["ioConfig"].(map[string]interface{})
+	require.Equal(t, "en_events", ioConfig["topic"])
+	require.Equal(t, "kafka:9092", ioConfig["consumerProperties"].(map[string]interface{})["bootstrap.servers"])
+}
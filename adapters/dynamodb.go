@@ -0,0 +1,438 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dynamoDBStringType = dynamodb.ScalarAttributeTypeS
+	dynamoDBNumberType = dynamodb.ScalarAttributeTypeN
+	dynamoDBBoolType   = "BOOL"
+
+	//dynamoDBBatchSize is a BatchWriteItem max items count
+	dynamoDBBatchSize      = 25
+	dynamoDBKeySeparator   = "#"
+	defaultDynamoDBRetries = 5
+)
+
+var (
+	//SchemaToDynamoDB is a mapping between types and DynamoDB attribute types
+	SchemaToDynamoDB = map[typing.DataType]string{
+		typing.STRING:    dynamoDBStringType,
+		typing.INT64:     dynamoDBNumberType,
+		typing.FLOAT64:   dynamoDBNumberType,
+		typing.TIMESTAMP: dynamoDBStringType,
+		typing.BOOL:      dynamoDBBoolType,
+		typing.UNKNOWN:   dynamoDBStringType,
+	}
+
+	dynamoDBThrottlingErrors = map[string]bool{
+		dynamodb.ErrCodeProvisionedThroughputExceededException: true,
+		dynamodb.ErrCodeRequestLimitExceeded:                   true,
+		"ThrottlingException":                                  true,
+	}
+)
+
+//DynamoDBKeyConfig is a DynamoDB table key attribute configuration
+//value is extracted from event fields (flattened names e.g. eventn_ctx_user_id) and joined with '#'
+type DynamoDBKeyConfig struct {
+	Attribute string   `mapstructure:"attribute" json:"attribute,omitempty" yaml:"attribute,omitempty"`
+	Fields    []string `mapstructure:"fields" json:"fields,omitempty" yaml:"fields,omitempty"`
+	//Type is S or N. Default value is S
+	Type string `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+//Validate required fields in DynamoDBKeyConfig
+func (dkc *DynamoDBKeyConfig) Validate(name string) error {
+	if dkc.Attribute == "" {
+		return fmt.Errorf("DynamoDB %s.attribute is required parameter", name)
+	}
+	if len(dkc.Fields) == 0 {
+		return fmt.Errorf("DynamoDB %s.fields is required parameter", name)
+	}
+	if dkc.Type == "" {
+		dkc.Type = dynamoDBStringType
+	}
+	if dkc.Type != dynamoDBStringType && dkc.Type != dynamoDBNumberType {
+		return fmt.Errorf("DynamoDB %s.type must be %s or %s", name, dynamoDBStringType, dynamoDBNumberType)
+	}
+	if dkc.Type == dynamoDBNumberType && len(dkc.Fields) > 1 {
+		return fmt.Errorf("DynamoDB %s with type %s must be extracted from one field", name, dynamoDBNumberType)
+	}
+
+	return nil
+}
+
+//DynamoDBTTLConfig is a DynamoDB TTL attribute configuration: attribute value is unix seconds of event time (Field) + Days
+type DynamoDBTTLConfig struct {
+	Attribute string `mapstructure:"attribute" json:"attribute,omitempty" yaml:"attribute,omitempty"`
+	//Field with event time. Default value is _timestamp. Current time is used if the field is absent
+	Field string `mapstructure:"field" json:"field,omitempty" yaml:"field,omitempty"`
+	Days  int    `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+//DynamoDBConfig is a DynamoDB destination configuration
+//if access_key_id isn't set - default AWS credentials chain is used
+type DynamoDBConfig struct {
+	AccessKeyID  string             `mapstructure:"access_key_id" json:"access_key_id,omitempty" yaml:"access_key_id,omitempty"`
+	SecretKey    string             `mapstructure:"secret_access_key" json:"secret_access_key,omitempty" yaml:"secret_access_key,omitempty"`
+	Region       string             `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
+	Endpoint     string             `mapstructure:"endpoint" json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	PartitionKey *DynamoDBKeyConfig `mapstructure:"partition_key" json:"partition_key,omitempty" yaml:"partition_key,omitempty"`
+	SortKey      *DynamoDBKeyConfig `mapstructure:"sort_key" json:"sort_key,omitempty" yaml:"sort_key,omitempty"`
+	TTL          *DynamoDBTTLConfig `mapstructure:"ttl" json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	//MaxRetries of throttled or unprocessed writes. Default value is 5
+	MaxRetries int `mapstructure:"max_retries" json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+}
+
+//Validate required fields in DynamoDBConfig and set default values
+func (dc *DynamoDBConfig) Validate() error {
+	if dc == nil {
+		return errors.New("DynamoDB config is required")
+	}
+	if dc.Region == "" {
+		return errors.New("DynamoDB region is required parameter")
+	}
+	if (dc.AccessKeyID == "") != (dc.SecretKey == "") {
+		return errors.New("DynamoDB access_key_id and secret_access_key must be set together")
+	}
+	if dc.PartitionKey == nil {
+		return errors.New("DynamoDB partition_key is required parameter")
+	}
+	if err := dc.PartitionKey.Validate("partition_key"); err != nil {
+		return err
+	}
+	if dc.SortKey != nil {
+		if err := dc.SortKey.Validate("sort_key"); err != nil {
+			return err
+		}
+		if dc.SortKey.Attribute == dc.PartitionKey.Attribute {
+			return errors.New("DynamoDB partition_key and sort_key attributes must be different")
+		}
+	}
+	if dc.TTL != nil {
+		if dc.TTL.Attribute == "" {
+			return errors.New("DynamoDB ttl.attribute is required parameter")
+		}
+		if dc.TTL.Days <= 0 {
+			return errors.New("DynamoDB ttl.days must be positive")
+		}
+		if dc.TTL.Field == "" {
+			dc.TTL.Field = timestamp.Key
+		}
+	}
+	if dc.MaxRetries < 0 {
+		return errors.New("DynamoDB max_retries can't be negative")
+	}
+	if dc.MaxRetries == 0 {
+		dc.MaxRetries = defaultDynamoDBRetries
+	}
+
+	return nil
+}
+
+//DynamoDB writes events with BatchWriteItem: key attributes and TTL are extracted from event fields
+//throttled and unprocessed writes are retried with exponential backoff
+type DynamoDB struct {
+	ctx        context.Context
+	config     *DynamoDBConfig
+	client     dynamodbiface.DynamoDBAPI
+	retryDelay time.Duration
+}
+
+func NewDynamoDB(ctx context.Context, config *DynamoDBConfig) (*DynamoDB, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	awsConfig := aws.NewConfig().WithRegion(config.Region)
+	if config.AccessKeyID != "" {
+		awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretKey, ""))
+	}
+	if config.Endpoint != "" {
+		awsConfig.WithEndpoint(config.Endpoint)
+	}
+	dynamoSession, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating DynamoDB session: %v", err)
+	}
+
+	return &DynamoDB{ctx: ctx, config: config, client: dynamodb.New(dynamoSession, awsConfig), retryDelay: 100 * time.Millisecond}, nil
+}
+
+//Test check DynamoDB availability and credentials
+func (d *DynamoDB) Test() error {
+	if _, err := d.client.ListTablesWithContext(d.ctx, &dynamodb.ListTablesInput{Limit: aws.Int64(1)}); err != nil {
+		return fmt.Errorf("Error connecting to DynamoDB: %v", err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return table key attributes or empty Table if table doesn't exist
+//DynamoDB is schemaless: other attributes aren't stored in the table description
+func (d *DynamoDB) GetTableSchema(tableName string) (*Table, error) {
+	table := &Table{Name: tableName, Columns: Columns{}, PKFields: map[string]bool{}}
+
+	output, err := d.client.DescribeTableWithContext(d.ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return table, nil
+		}
+		return nil, fmt.Errorf("Error describing DynamoDB table [%s]: %v", tableName, err)
+	}
+
+	for _, definition := range output.Table.AttributeDefinitions {
+		table.Columns[aws.StringValue(definition.AttributeName)] = Column{SqlType: aws.StringValue(definition.AttributeType)}
+	}
+
+	return table, nil
+}
+
+//CreateTable create on-demand table with configured keys and enable TTL if configured
+func (d *DynamoDB) CreateTable(tableSchema *Table) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableSchema.Name),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	}
+	//HASH key must be the first one in the key schema
+	keyTypes := []string{dynamodb.KeyTypeHash, dynamodb.KeyTypeRange}
+	for i, keyConfig := range []*DynamoDBKeyConfig{d.config.PartitionKey, d.config.SortKey} {
+		if keyConfig == nil {
+			continue
+		}
+		input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(keyConfig.Attribute),
+			AttributeType: aws.String(keyConfig.Type),
+		})
+		input.KeySchema = append(input.KeySchema, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(keyConfig.Attribute),
+			KeyType:       aws.String(keyTypes[i]),
+		})
+	}
+
+	if _, err := d.client.CreateTableWithContext(d.ctx, input); err != nil {
+		return fmt.Errorf("Error creating DynamoDB table [%s]: %v", tableSchema.Name, err)
+	}
+
+	if err := d.client.WaitUntilTableExistsWithContext(d.ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableSchema.Name)}); err != nil {
+		return fmt.Errorf("Error waiting for DynamoDB table [%s] creation: %v", tableSchema.Name, err)
+	}
+
+	if d.config.TTL != nil {
+		_, err := d.client.UpdateTimeToLiveWithContext(d.ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(tableSchema.Name),
+			TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+				AttributeName: aws.String(d.config.TTL.Attribute),
+				Enabled:       aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("Error enabling TTL on DynamoDB table [%s]: %v", tableSchema.Name, err)
+		}
+	}
+
+	return nil
+}
+
+//PatchTableSchema do nothing because DynamoDB is schemaless
+func (d *DynamoDB) PatchTableSchema(patchSchema *Table) error {
+	return nil
+}
+
+//Insert write one event
+func (d *DynamoDB) Insert(table *Table, event map[string]interface{}) error {
+	return d.BatchInsert(table, []map[string]interface{}{event})
+}
+
+//BatchInsert write objects with BatchWriteItem in chunks of 25 items
+//objects with the same key in one chunk are deduplicated (the last one is written)
+func (d *DynamoDB) BatchInsert(table *Table, objects []map[string]interface{}) error {
+	var chunk []*dynamodb.WriteRequest
+	chunkKeys := map[string]int{}
+	for _, object := range objects {
+		item, key, err := d.toItem(object)
+		if err != nil {
+			return err
+		}
+
+		request := &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}}
+		if i, ok := chunkKeys[key]; ok {
+			chunk[i] = request
+			continue
+		}
+
+		chunkKeys[key] = len(chunk)
+		chunk = append(chunk, request)
+		if len(chunk) == dynamoDBBatchSize {
+			if err := d.writeBatch(table.Name, chunk); err != nil {
+				return err
+			}
+			chunk = nil
+			chunkKeys = map[string]int{}
+		}
+	}
+
+	if len(chunk) > 0 {
+		return d.writeBatch(table.Name, chunk)
+	}
+
+	return nil
+}
+
+//writeBatch call BatchWriteItem and retry unprocessed items and throttling errors with exponential backoff
+func (d *DynamoDB) writeBatch(tableName string, requests []*dynamodb.WriteRequest) error {
+	requestItems := map[string][]*dynamodb.WriteRequest{tableName: requests}
+	for attempt := 0; ; attempt++ {
+		output, err := d.client.BatchWriteItemWithContext(d.ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems})
+		if err != nil {
+			if !isDynamoDBThrottlingErr(err) || attempt >= d.config.MaxRetries {
+				return fmt.Errorf("Error writing %d items into DynamoDB table [%s]: %v", len(requestItems[tableName]), tableName, err)
+			}
+		} else {
+			if len(output.UnprocessedItems[tableName]) == 0 {
+				return nil
+			}
+			requestItems = output.UnprocessedItems
+			if attempt >= d.config.MaxRetries {
+				return fmt.Errorf("%d items haven't been written into DynamoDB table [%s] after %d retries", len(requestItems[tableName]), tableName, attempt)
+			}
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return fmt.Errorf("Error writing into DynamoDB table [%s]: %v", tableName, d.ctx.Err())
+		case <-time.After(d.retryDelay * time.Duration(1<<uint(attempt))):
+		}
+	}
+}
+
+//toItem return DynamoDB item with key attributes and TTL attribute and the item key for deduplication
+func (d *DynamoDB) toItem(object map[string]interface{}) (map[string]*dynamodb.AttributeValue, string, error) {
+	item := map[string]*dynamodb.AttributeValue{}
+	for name, value := range object {
+		item[name] = toDynamoDBAttribute(value)
+	}
+
+	partitionKey, err := extractDynamoDBKey(d.config.PartitionKey, object)
+	if err != nil {
+		return nil, "", err
+	}
+	item[d.config.PartitionKey.Attribute] = partitionKey
+	key := aws.StringValue(partitionKey.S) + aws.StringValue(partitionKey.N)
+
+	if d.config.SortKey != nil {
+		sortKey, err := extractDynamoDBKey(d.config.SortKey, object)
+		if err != nil {
+			return nil, "", err
+		}
+		item[d.config.SortKey.Attribute] = sortKey
+		key += "|" + aws.StringValue(sortKey.S) + aws.StringValue(sortKey.N)
+	}
+
+	if d.config.TTL != nil {
+		item[d.config.TTL.Attribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(ttlValue(d.config.TTL, object), 10))}
+	}
+
+	return item, key, nil
+}
+
+//extractDynamoDBKey return key attribute value from object fields or error if any field is absent
+func extractDynamoDBKey(keyConfig *DynamoDBKeyConfig, object map[string]interface{}) (*dynamodb.AttributeValue, error) {
+	var parts []string
+	for _, field := range keyConfig.Fields {
+		value, ok := object[field]
+		if !ok || value == nil || fmt.Sprint(value) == "" {
+			return nil, fmt.Errorf("DynamoDB key attribute [%s] can't be extracted: field [%s] is absent", keyConfig.Attribute, field)
+		}
+		if t, ok := value.(time.Time); ok {
+			parts = append(parts, timestamp.ToISOFormat(t))
+		} else {
+			parts = append(parts, fmt.Sprint(value))
+		}
+	}
+
+	value := strings.Join(parts, dynamoDBKeySeparator)
+	if keyConfig.Type == dynamoDBNumberType {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("DynamoDB key attribute [%s] must be a number: %s", keyConfig.Attribute, value)
+		}
+		return &dynamodb.AttributeValue{N: aws.String(value)}, nil
+	}
+
+	return &dynamodb.AttributeValue{S: aws.String(value)}, nil
+}
+
+//ttlValue return unix seconds of event time + ttl days. Current time is used if the event time field is absent
+func ttlValue(ttlConfig *DynamoDBTTLConfig, object map[string]interface{}) int64 {
+	eventTime := time.Now().UTC()
+	switch v := object[ttlConfig.Field].(type) {
+	case time.Time:
+		eventTime = v
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			eventTime = t
+		}
+	}
+
+	return eventTime.Add(time.Duration(ttlConfig.Days) * 24 * time.Hour).Unix()
+}
+
+//toDynamoDBAttribute convert flattened event value into DynamoDB attribute value
+func toDynamoDBAttribute(value interface{}) *dynamodb.AttributeValue {
+	switch v := value.(type) {
+	case nil:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	case bool:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(v)}
+	case string:
+		return &dynamodb.AttributeValue{S: aws.String(v)}
+	case json.Number:
+		return &dynamodb.AttributeValue{N: aws.String(v.String())}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(v))}
+	case float32:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(float64(v), 'f', -1, 32))}
+	case float64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(v, 'f', -1, 64))}
+	case time.Time:
+		return &dynamodb.AttributeValue{S: aws.String(timestamp.ToISOFormat(v))}
+	case []interface{}:
+		list := make([]*dynamodb.AttributeValue, 0, len(v))
+		for _, element := range v {
+			list = append(list, toDynamoDBAttribute(element))
+		}
+		return &dynamodb.AttributeValue{L: list}
+	case map[string]interface{}:
+		m := map[string]*dynamodb.AttributeValue{}
+		for key, element := range v {
+			m[key] = toDynamoDBAttribute(element)
+		}
+		return &dynamodb.AttributeValue{M: m}
+	default:
+		return &dynamodb.AttributeValue{S: aws.String(fmt.Sprint(v))}
+	}
+}
+
+func isDynamoDBThrottlingErr(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && dynamoDBThrottlingErrors[aerr.Code()]
+}
+
+func (d *DynamoDB) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type batchWriteClient struct {
+	dynamodbiface.DynamoDBAPI
+
+	responses []func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	inputs    []*dynamodb.BatchWriteItemInput
+}
+
+func (bwc *batchWriteClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	bwc.inputs = append(bwc.inputs, input)
+	if len(bwc.responses) == 0 {
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	response := bwc.responses[0]
+	bwc.responses = bwc.responses[1:]
+	return response(input)
+}
+
+func TestDynamoDBConfigValidate(t *testing.T) {
+	require.Error(t, (&DynamoDBConfig{Region: "us-east-1"}).Validate())
+	require.Error(t, (&DynamoDBConfig{Region: "us-east-1", AccessKeyID: "key", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"user_id"}}}).Validate())
+	require.Error(t, (&DynamoDBConfig{Region: "us-east-1", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"a", "b"}, Type: "N"}}).Validate())
+	require.Error(t, (&DynamoDBConfig{Region: "us-east-1", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"a"}},
+		SortKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"b"}}}).Validate())
+	require.Error(t, (&DynamoDBConfig{Region: "us-east-1", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"a"}},
+		TTL: &DynamoDBTTLConfig{Attribute: "expires_at"}}).Validate())
+
+	config := &DynamoDBConfig{Region: "us-east-1", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"a"}},
+		TTL: &DynamoDBTTLConfig{Attribute: "expires_at", Days: 30}}
+	require.NoError(t, config.Validate())
+	require.Equal(t, "S", config.PartitionKey.Type)
+	require.Equal(t, "_timestamp", config.TTL.Field)
+	require.Equal(t, 5, config.MaxRetries)
+}
+
+func TestDynamoDBToItem(t *testing.T) {
+	config := &DynamoDBConfig{
+		Region:       "us-east-1",
+		PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"eventn_ctx_user_id"}},
+		SortKey:      &DynamoDBKeyConfig{Attribute: "sk", Fields: []string{"_timestamp", "eventn_ctx_event_id"}},
+		TTL:          &DynamoDBTTLConfig{Attribute: "expires_at", Days: 1},
+	}
+	require.NoError(t, config.Validate())
+	d := &DynamoDB{ctx: context.Background(), config: config}
+
+	eventTime := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	item, key, err := d.toItem(map[string]interface{}{
+		"eventn_ctx_user_id":  "user1",
+		"eventn_ctx_event_id": "event1",
+		"_timestamp":          eventTime,
+		"amount":              json.Number("10.5"),
+		"flag":                true,
+		"empty":               nil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "user1|2020-10-01T12:00:00.000000Z#event1", key)
+	require.Equal(t, "user1", aws.StringValue(item["pk"].S))
+	require.Equal(t, "2020-10-01T12:00:00.000000Z#event1", aws.StringValue(item["sk"].S))
+	require.Equal(t, "1601640000", aws.StringValue(item["expires_at"].N))
+	require.Equal(t, "10.5", aws.StringValue(item["amount"].N))
+	require.True(t, aws.BoolValue(item["flag"].BOOL))
+	require.True(t, aws.BoolValue(item["empty"].NULL))
+
+	_, _, err = d.toItem(map[string]interface{}{"eventn_ctx_event_id": "event1", "_timestamp": eventTime})
+	require.Error(t, err)
+}
+
+func TestDynamoDBBatchInsert(t *testing.T) {
+	config := &DynamoDBConfig{Region: "us-east-1", PartitionKey: &DynamoDBKeyConfig{Attribute: "pk", Fields: []string{"id"}}, MaxRetries: 3}
+	require.NoError(t, config.Validate())
+
+	client := &batchWriteClient{responses: []func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error){
+		func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+		},
+		func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			requests := input.RequestItems["events"]
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{"events": requests[:2]}}, nil
+		},
+	}}
+	d := &DynamoDB{ctx: context.Background(), config: config, client: client, retryDelay: time.Millisecond}
+
+	var objects []map[string]interface{}
+	for i := 0; i < 30; i++ {
+		objects = append(objects, map[string]interface{}{"id": i, "value": "first"})
+	}
+	//duplicate key in the first chunk: the last object is written
+	objects = append(objects[:24], append([]map[string]interface{}{{"id": 0, "value": "second"}}, objects[24:]...)...)
+
+	require.NoError(t, d.BatchInsert(&Table{Name: "events"}, objects))
+
+	//throttled, partially unprocessed, unprocessed retry, second chunk
+	require.Len(t, client.inputs, 4)
+	require.Len(t, client.inputs[0].RequestItems["events"], 25)
+	require.Equal(t, "second", aws.StringValue(client.inputs[0].RequestItems["events"][0].PutRequest.Item["value"].S))
+	require.Len(t, client.inputs[2].RequestItems["events"], 2)
+	require.Len(t, client.inputs[3].RequestItems["events"], 5)
+
+	//not throttling errors aren't retried
+	client.inputs = nil
+	client.responses = []func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error){
+		func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil)
+		},
+	}
+	require.Error(t, d.Insert(&Table{Name: "events"}, map[string]interface{}{"id": 1}))
+	require.Len(t, client.inputs, 1)
+}
@@ -0,0 +1,387 @@
+package adapters
+
+import (
+	"bytes"
+	"cloud.google.com/go/storage"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	GCSFormatJSON    = "json"
+	GCSFormatCSV     = "csv"
+	GCSFormatParquet = "parquet"
+
+	defaultGCSPathTemplate = "{{.table}}/{{.date}}/{{.file}}"
+	gcsDateLayout          = "2006-01-02"
+	parquetWriterThreads   = 1
+)
+
+var gcsFormats = map[string]struct {
+	extension   string
+	contentType string
+}{
+	GCSFormatJSON:    {"ndjson", "application/x-ndjson"},
+	GCSFormatCSV:     {"csv", "text/csv"},
+	GCSFormatParquet: {"parquet", "application/octet-stream"},
+}
+
+//GCSConfig is a standalone Google Cloud Storage destination config
+//PathTemplate is a Go template of the object key (without extension). Available variables:
+//table, token, date (YYYY-MM-DD), year, month, day, hour (of the upload time in UTC) and file (batch file name)
+//KMSKeyName is a customer-managed Cloud KMS key which encrypts uploaded objects
+//Compression is applied to whole JSON and CSV files and to Parquet pages (Parquet files keep .parquet extension)
+type GCSConfig struct {
+	Bucket       string      `mapstructure:"bucket" json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	KeyFile      interface{} `mapstructure:"key_file" json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	Folder       string      `mapstructure:"folder" json:"folder,omitempty" yaml:"folder,omitempty"`
+	Format       string      `mapstructure:"format" json:"format,omitempty" yaml:"format,omitempty"`
+	PathTemplate string      `mapstructure:"path_template" json:"path_template,omitempty" yaml:"path_template,omitempty"`
+	KMSKeyName   string      `mapstructure:"kms_key_name" json:"kms_key_name,omitempty" yaml:"kms_key_name,omitempty"`
+
+	Compression *CompressionConfig `mapstructure:"compression" json:"compression,omitempty" yaml:"compression,omitempty"`
+
+	//will be set on validation
+	googleConfig *GoogleConfig
+	pathTmpl     *template.Template
+}
+
+func (gc *GCSConfig) Validate() error {
+	if gc == nil {
+		return errors.New("GCS config is required")
+	}
+	if gc.Bucket == "" {
+		return errors.New("GCS bucket is required parameter")
+	}
+
+	if gc.Format == "" {
+		gc.Format = GCSFormatJSON
+	}
+	if _, ok := gcsFormats[gc.Format]; !ok {
+		return fmt.Errorf("Unknown GCS format: %s. Available formats: [%s, %s, %s]", gc.Format, GCSFormatJSON, GCSFormatCSV, GCSFormatParquet)
+	}
+
+	if err := gc.Compression.Validate(); err != nil {
+		return err
+	}
+	if gc.Format == GCSFormatParquet && gc.Compression.GetCodec() == CompressionLZ4 {
+		return fmt.Errorf("GCS %s format doesn't support %s compression", GCSFormatParquet, CompressionLZ4)
+	}
+
+	if gc.PathTemplate == "" {
+		gc.PathTemplate = defaultGCSPathTemplate
+	}
+	tmpl, err := template.New("gcs path").Option("missingkey=error").Parse(gc.PathTemplate)
+	if err != nil {
+		return fmt.Errorf("Error parsing GCS path_template: %v", err)
+	}
+	gc.pathTmpl = tmpl
+
+	googleConfig := &GoogleConfig{Bucket: gc.Bucket, KeyFile: gc.KeyFile, KMSKeyName: gc.KMSKeyName}
+	if err := googleConfig.Validate(false); err != nil {
+		return err
+	}
+	gc.googleConfig = googleConfig
+
+	return nil
+}
+
+//GCS adapter writes batch files into Google Cloud Storage bucket in configured format
+type GCS struct {
+	config  *GCSConfig
+	storage *GoogleCloudStorage
+}
+
+//NewGCS return GCS adapter with Google Cloud Storage client. config must be validated
+func NewGCS(ctx context.Context, config *GCSConfig) (*GCS, error) {
+	gcs, err := NewGoogleCloudStorage(ctx, config.googleConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCS{config: config, storage: gcs}, nil
+}
+
+//Upload marshal objects in configured format, compress them and upload as one object
+//return uploaded object key
+func (g *GCS) Upload(tableName, tokenId, fileName string, fields schema.Fields, objects []map[string]interface{}) (string, error) {
+	key, err := g.ObjectKey(tableName, tokenId, fileName, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := g.Marshal(fields, objects)
+	if err != nil {
+		return "", fmt.Errorf("Error marshalling objects into %s: %v", g.config.Format, err)
+	}
+
+	contentType := gcsFormats[g.config.Format].contentType
+	if g.compressesFile() {
+		payload, err = g.config.Compression.Compress(payload)
+		if err != nil {
+			return "", err
+		}
+		contentType = g.config.Compression.ContentType()
+	}
+
+	if err := g.storage.UploadBytesWithContentType(key, payload, contentType); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+//ObjectKey return object key: folder + rendered path template + format extension + compression extension
+func (g *GCS) ObjectKey(tableName, tokenId, fileName string, uploadTime time.Time) (string, error) {
+	fileName = strings.TrimSuffix(fileName, ".log")
+	var buf bytes.Buffer
+	if err := g.config.pathTmpl.Execute(&buf, map[string]string{
+		"table": tableName,
+		"token": tokenId,
+		"date":  uploadTime.Format(gcsDateLayout),
+		"year":  uploadTime.Format("2006"),
+		"month": uploadTime.Format("01"),
+		"day":   uploadTime.Format("02"),
+		"hour":  uploadTime.Format("15"),
+		"file":  fileName,
+	}); err != nil {
+		return "", fmt.Errorf("Error executing GCS path_template: %v", err)
+	}
+
+	key := g.folderPrefix() + strings.Trim(buf.String(), "/") + "." + gcsFormats[g.config.Format].extension
+	if g.compressesFile() {
+		key += g.config.Compression.Extension()
+	}
+
+	return key, nil
+}
+
+//Marshal return objects in configured format: new line delimited JSON, CSV with header or Parquet
+func (g *GCS) Marshal(fields schema.Fields, objects []map[string]interface{}) ([]byte, error) {
+	switch g.config.Format {
+	case GCSFormatCSV:
+		return marshalCSV(fields, objects)
+	case GCSFormatParquet:
+		return marshalParquet(fields, objects, g.config.Compression)
+	default:
+		return marshalNDJSON(objects)
+	}
+}
+
+//ListObjects return bucket objects filtered by key prefix. Keys are returned without configured folder
+func (g *GCS) ListObjects(prefix string) ([]*ObjectInfo, error) {
+	objects, err := g.storage.ListObjects(g.folderPrefix() + prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		object.Key = strings.TrimPrefix(object.Key, g.folderPrefix())
+	}
+
+	return objects, nil
+}
+
+//GetObject return object payload by key (without configured folder)
+func (g *GCS) GetObject(key string) ([]byte, error) {
+	return g.storage.GetObject(g.folderPrefix() + key)
+}
+
+//UploadBytes create object by key (without configured folder) with payload
+func (g *GCS) UploadBytes(key string, payload []byte) error {
+	return g.storage.UploadBytes(g.folderPrefix()+key, payload)
+}
+
+//DeleteObject delete object by key (without configured folder). Not existing objects are ignored
+func (g *GCS) DeleteObject(key string) error {
+	err := g.storage.client.Bucket(g.config.Bucket).Object(g.folderPrefix() + key).Delete(g.storage.ctx)
+	if err != nil && err != storage.ErrObjectNotExist {
+		return fmt.Errorf("Error deleting file %s from google cloud storage %v", key, err)
+	}
+
+	return nil
+}
+
+func (g *GCS) folderPrefix() string {
+	if g.config.Folder == "" {
+		return ""
+	}
+
+	return strings.Trim(g.config.Folder, "/") + "/"
+}
+
+//compressesFile return true if the whole marshalled file is compressed (Parquet is compressed internally)
+func (g *GCS) compressesFile() bool {
+	return g.config.Format != GCSFormatParquet && g.config.Compression.GetCodec() != CompressionNone
+}
+
+//Test check bucket availability and credentials
+func (g *GCS) Test() error {
+	return g.storage.Test()
+}
+
+func (g *GCS) Close() error {
+	return g.storage.Close()
+}
+
+func marshalNDJSON(objects []map[string]interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+//marshalCSV return RFC 4180 CSV with sorted columns header. Timestamps are formatted with timestamp.Layout
+func marshalCSV(fields schema.Fields, objects []map[string]interface{}) ([]byte, error) {
+	header := sortedHeader(fields)
+
+	buf := &bytes.Buffer{}
+	csvWriter := csv.NewWriter(buf)
+	if err := csvWriter.Write(header); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(header))
+	for _, object := range objects {
+		for i, column := range header {
+			record[i] = csvValue(object[column])
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	csvWriter.Flush()
+
+	return buf.Bytes(), csvWriter.Error()
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return timestamp.ToISOFormat(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+//marshalParquet return Parquet file with optional columns typed according to fields types
+//values which can't be converted into column type are written as nulls
+//pages are compressed with compression codec or with Snappy if compression isn't configured
+func marshalParquet(fields schema.Fields, objects []map[string]interface{}, compression *CompressionConfig) ([]byte, error) {
+	header := sortedHeader(fields)
+
+	var columns []string
+	for _, column := range header {
+		columns = append(columns, fmt.Sprintf(`{"Tag":"name=%s, %s, repetitiontype=OPTIONAL"}`, column, parquetType(fields[column].GetType())))
+	}
+	parquetSchema := `{"Tag":"name=parquet_go_root, repetitiontype=REQUIRED","Fields":[` + strings.Join(columns, ",") + `]}`
+
+	buf := &bytes.Buffer{}
+	parquetWriter, err := writer.NewJSONWriterFromWriter(parquetSchema, buf, parquetWriterThreads)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating parquet writer: %v", err)
+	}
+	if compression != nil {
+		parquetWriter.CompressionType = parquetCompression(compression.GetCodec())
+	}
+
+	for _, object := range objects {
+		row := map[string]interface{}{}
+		for _, column := range header {
+			if value, ok := parquetValue(fields[column].GetType(), object[column]); ok {
+				row[column] = value
+			}
+		}
+
+		b, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		if err := parquetWriter.Write(string(b)); err != nil {
+			return nil, fmt.Errorf("Error writing parquet row: %v", err)
+		}
+	}
+
+	if err := parquetWriter.WriteStop(); err != nil {
+		return nil, fmt.Errorf("Error finishing parquet file: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parquetCompression(codec string) parquet.CompressionCodec {
+	switch codec {
+	case CompressionGzip:
+		return parquet.CompressionCodec_GZIP
+	case CompressionZstd:
+		return parquet.CompressionCodec_ZSTD
+	default:
+		return parquet.CompressionCodec_UNCOMPRESSED
+	}
+}
+
+func parquetType(dataType typing.DataType) string {
+	switch dataType {
+	case typing.BOOL:
+		return "type=BOOLEAN"
+	case typing.INT64:
+		return "type=INT64"
+	case typing.FLOAT64:
+		return "type=DOUBLE"
+	case typing.TIMESTAMP:
+		return "type=TIMESTAMP_MICROS"
+	default:
+		return "type=UTF8"
+	}
+}
+
+//parquetValue return value converted into column type (timestamps as microseconds since epoch)
+func parquetValue(dataType typing.DataType, value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	if dataType == typing.UNKNOWN {
+		dataType = typing.STRING
+	}
+	converted, err := typing.Convert(dataType, value)
+	if err != nil {
+		return nil, false
+	}
+
+	if t, ok := converted.(time.Time); ok {
+		return t.UnixNano() / int64(time.Microsecond), true
+	}
+
+	return converted, true
+}
+
+func sortedHeader(fields schema.Fields) []string {
+	header := fields.Header()
+	sort.Strings(header)
+	return header
+}
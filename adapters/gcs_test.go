@@ -0,0 +1,130 @@
+package adapters
+
+import (
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+	"testing"
+	"time"
+)
+
+func TestGCSConfigValidate(t *testing.T) {
+	keyFile := map[string]interface{}{"type": "service_account"}
+	require.Error(t, (&GCSConfig{KeyFile: keyFile}).Validate())
+	require.Error(t, (&GCSConfig{Bucket: "bucket", KeyFile: keyFile, Format: "avro"}).Validate())
+	require.Error(t, (&GCSConfig{Bucket: "bucket", KeyFile: keyFile, PathTemplate: "{{.table"}).Validate())
+	require.Error(t, (&GCSConfig{Bucket: "bucket"}).Validate())
+	require.Error(t, (&GCSConfig{Bucket: "bucket", KeyFile: keyFile, Format: GCSFormatParquet, Compression: &CompressionConfig{Codec: CompressionLZ4}}).Validate())
+
+	config := &GCSConfig{Bucket: "bucket", KeyFile: keyFile, KMSKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}
+	require.NoError(t, config.Validate())
+	require.Equal(t, GCSFormatJSON, config.Format)
+	require.Equal(t, defaultGCSPathTemplate, config.PathTemplate)
+	require.Equal(t, config.KMSKeyName, config.googleConfig.KMSKeyName)
+}
+
+func TestGCSObjectKey(t *testing.T) {
+	uploadTime := time.Date(2020, 10, 1, 9, 0, 0, 0, time.UTC)
+	fileName := "incoming.tok=token1-2020-10-01T09-00-00.000.log"
+	tests := []struct {
+		name        string
+		config      *GCSConfig
+		expectedKey string
+		expectedErr bool
+	}{
+		{
+			"Default template",
+			&GCSConfig{Format: GCSFormatJSON},
+			"events/2020-10-01/incoming.tok=token1-2020-10-01T09-00-00.000.ndjson",
+			false,
+		},
+		{
+			"Custom template with folder",
+			&GCSConfig{Format: GCSFormatParquet, Folder: "/raw/", PathTemplate: "token={{.token}}/{{.table}}/{{.year}}/{{.month}}/{{.day}}/{{.hour}}/{{.file}}"},
+			"raw/token=token1/events/2020/10/01/09/incoming.tok=token1-2020-10-01T09-00-00.000.parquet",
+			false,
+		},
+		{
+			"Compressed JSON",
+			&GCSConfig{Format: GCSFormatJSON, Compression: &CompressionConfig{Codec: CompressionZstd}},
+			"events/2020-10-01/incoming.tok=token1-2020-10-01T09-00-00.000.ndjson.zst",
+			false,
+		},
+		{
+			"Compressed Parquet",
+			&GCSConfig{Format: GCSFormatParquet, Compression: &CompressionConfig{Codec: CompressionGzip}},
+			"events/2020-10-01/incoming.tok=token1-2020-10-01T09-00-00.000.parquet",
+			false,
+		},
+		{
+			"Unknown variable",
+			&GCSConfig{Format: GCSFormatCSV, PathTemplate: "{{.unknown}}/{{.file}}"},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Bucket = "bucket"
+			tt.config.KeyFile = map[string]interface{}{"type": "service_account"}
+			require.NoError(t, tt.config.Validate())
+
+			key, err := (&GCS{config: tt.config}).ObjectKey("events", "token1", fileName, uploadTime)
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedKey, key)
+			}
+		})
+	}
+}
+
+func TestGCSMarshal(t *testing.T) {
+	eventTime := time.Date(2020, 10, 1, 9, 0, 0, 0, time.UTC)
+	fields := schema.Fields{
+		"_timestamp": schema.NewField(typing.TIMESTAMP),
+		"amount":     schema.NewField(typing.FLOAT64),
+		"count":      schema.NewField(typing.INT64),
+		"name":       schema.NewField(typing.STRING),
+	}
+	objects := []map[string]interface{}{
+		{"_timestamp": eventTime, "amount": 10.5, "count": int64(1), "name": "a,b"},
+		{"_timestamp": eventTime, "count": int64(2)},
+	}
+
+	ndjson, err := marshalNDJSON(objects)
+	require.NoError(t, err)
+	require.Equal(t, `{"_timestamp":"2020-10-01T09:00:00Z","amount":10.5,"count":1,"name":"a,b"}
+{"_timestamp":"2020-10-01T09:00:00Z","count":2}
+`, string(ndjson))
+
+	csvPayload, err := marshalCSV(fields, objects)
+	require.NoError(t, err)
+	require.Equal(t, `_timestamp,amount,count,name
+2020-10-01T09:00:00.000000Z,10.5,1,"a,b"
+2020-10-01T09:00:00.000000Z,,2,
+`, string(csvPayload))
+
+	parquetPayload, err := marshalParquet(fields, objects, &CompressionConfig{Codec: CompressionZstd})
+	require.NoError(t, err)
+
+	parquetFile, err := buffer.NewBufferFile(parquetPayload)
+	require.NoError(t, err)
+	parquetReader, err := reader.NewParquetColumnReader(parquetFile, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), parquetReader.GetNumRows())
+
+	//columns are sorted
+	timestamps, _, _, err := parquetReader.ReadColumnByIndex(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{eventTime.UnixNano() / 1000, eventTime.UnixNano() / 1000}, timestamps)
+	amounts, _, _, err := parquetReader.ReadColumnByIndex(1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{10.5, nil}, amounts)
+	names, _, _, err := parquetReader.ReadColumnByIndex(3, 2)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a,b", nil}, names)
+}
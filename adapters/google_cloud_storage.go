@@ -23,6 +23,11 @@ type GoogleConfig struct {
 	Project string      `mapstructure:"bq_project" json:"bq_project,omitempty" yaml:"bq_project,omitempty"`
 	Dataset string      `mapstructure:"bq_dataset" json:"bq_dataset,omitempty" yaml:"bq_dataset,omitempty"`
 	KeyFile interface{} `mapstructure:"key_file" json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	//PartitionDecorator: BigQuery tables are created partitioned by day (ingestion time) and
+	//events are inserted into partitions by event timestamp (table$YYYYMMDD)
+	PartitionDecorator bool `mapstructure:"bq_partition_decorator" json:"bq_partition_decorator,omitempty" yaml:"bq_partition_decorator,omitempty"`
+	//KMSKeyName: customer-managed Cloud KMS key which encrypts uploaded objects
+	KMSKeyName string `mapstructure:"gcs_kms_key_name" json:"gcs_kms_key_name,omitempty" yaml:"gcs_kms_key_name,omitempty"`
 
 	//will be set on validation
 	credentials option.ClientOption
@@ -76,9 +81,17 @@ func NewGoogleCloudStorage(ctx context.Context, config *GoogleConfig) (*GoogleCl
 
 //Create named file on google cloud storage with payload
 func (gcs *GoogleCloudStorage) UploadBytes(fileName string, fileBytes []byte) error {
+	return gcs.UploadBytesWithContentType(fileName, fileBytes, "")
+}
+
+//UploadBytesWithContentType create named file with content type (detected by google cloud storage if empty)
+//file is encrypted with configured KMS key
+func (gcs *GoogleCloudStorage) UploadBytesWithContentType(fileName string, fileBytes []byte, contentType string) error {
 	bucket := gcs.client.Bucket(gcs.config.Bucket)
 	object := bucket.Object(fileName)
 	w := object.NewWriter(gcs.ctx)
+	w.ContentType = contentType
+	w.KMSKeyName = gcs.config.KMSKeyName
 
 	if _, err := w.Write(fileBytes); err != nil {
 		return fmt.Errorf("Error writing file to google cloud storage: %v", err)
@@ -110,6 +123,25 @@ func (gcs *GoogleCloudStorage) ListBucket(prefix string) ([]string, error) {
 	return files, nil
 }
 
+//ListObjects return google cloud storage bucket files filtered by name prefix
+func (gcs *GoogleCloudStorage) ListObjects(prefix string) ([]*ObjectInfo, error) {
+	bucket := gcs.client.Bucket(gcs.config.Bucket)
+	it := bucket.Objects(gcs.ctx, &storage.Query{Prefix: prefix})
+	var objects []*ObjectInfo
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error listing google cloud storage bucket %s: %v", gcs.config.Bucket, err)
+		}
+		objects = append(objects, &ObjectInfo{Key: attrs.Name, Size: attrs.Size, Modified: attrs.Updated})
+	}
+
+	return objects, nil
+}
+
 //Delete object from google cloud storage bucket
 func (gcs *GoogleCloudStorage) DeleteObject(key string) error {
 	bucket := gcs.client.Bucket(gcs.config.Bucket)
@@ -136,6 +168,15 @@ func (gcs *GoogleCloudStorage) GetObject(key string) ([]byte, error) {
 	return ioutil.ReadAll(r)
 }
 
+//Test check bucket availability and credentials with bucket attributes request
+func (gcs *GoogleCloudStorage) Test() error {
+	if _, err := gcs.client.Bucket(gcs.config.Bucket).Attrs(gcs.ctx); err != nil {
+		return fmt.Errorf("Error checking google cloud storage bucket %s: %v", gcs.config.Bucket, err)
+	}
+
+	return nil
+}
+
 func (gcs *GoogleCloudStorage) Close() error {
 	return gcs.client.Close()
 }
@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"sync"
+	"time"
+)
+
+//KafkaConfig is a Kafka producer configuration
+//events of every table are produced into <topic_prefix><table name> topic
+type KafkaConfig struct {
+	Brokers     []string `mapstructure:"brokers" json:"brokers,omitempty" yaml:"brokers,omitempty"`
+	TopicPrefix string   `mapstructure:"topic_prefix" json:"topic_prefix,omitempty" yaml:"topic_prefix,omitempty"`
+	Username    string   `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password    string   `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	TLS         bool     `mapstructure:"tls" json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+//Validate required fields in KafkaConfig
+func (kc *KafkaConfig) Validate() error {
+	if kc == nil {
+		return errors.New("kafka config is required")
+	}
+	if len(kc.Brokers) == 0 {
+		return errors.New("kafka brokers are required")
+	}
+
+	return nil
+}
+
+//Topic return Kafka topic of the table
+func (kc *KafkaConfig) Topic(tableName string) string {
+	return kc.TopicPrefix + tableName
+}
+
+//KafkaProducer produces JSON messages into Kafka topics
+type KafkaProducer struct {
+	sync.Mutex
+
+	ctx     context.Context
+	config  *KafkaConfig
+	dialer  *kafka.Dialer
+	writers map[string]*kafka.Writer
+}
+
+func NewKafkaProducer(ctx context.Context, config *KafkaConfig) *KafkaProducer {
+	dialer := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	if config.Username != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: config.Username, Password: config.Password}
+	}
+	if config.TLS {
+		dialer.TLS = &tls.Config{}
+	}
+
+	return &KafkaProducer{ctx: ctx, config: config, dialer: dialer, writers: map[string]*kafka.Writer{}}
+}
+
+//Produce write object as JSON message into topic synchronously
+func (kp *KafkaProducer) Produce(topic string, object map[string]interface{}) error {
+	b, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("Error marshalling object: %v", err)
+	}
+
+	return kp.getWriter(topic).WriteMessages(kp.ctx, kafka.Message{Value: b})
+}
+
+func (kp *KafkaProducer) getWriter(topic string) *kafka.Writer {
+	kp.Lock()
+	defer kp.Unlock()
+
+	writer, ok := kp.writers[topic]
+	if !ok {
+		writer = kafka.NewWriter(kafka.WriterConfig{
+			Brokers:      kp.config.Brokers,
+			Topic:        topic,
+			Dialer:       kp.dialer,
+			Balancer:     &kafka.LeastBytes{},
+			BatchTimeout: 10 * time.Millisecond,
+		})
+		kp.writers[topic] = writer
+	}
+
+	return writer
+}
+
+func (kp *KafkaProducer) Close() (multiErr error) {
+	kp.Lock()
+	defer kp.Unlock()
+
+	for topic, writer := range kp.writers {
+		if err := writer.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing Kafka writer of topic [%s]: %v", topic, err))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,10 @@
+package adapters
+
+import "time"
+
+//ObjectInfo is an object store file with key (without configured folder), size in bytes and last modification time
+type ObjectInfo struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
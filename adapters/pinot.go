@@ -0,0 +1,279 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/typing"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	pinotTimestampFormat = "1:MILLISECONDS:EPOCH"
+	pinotLongType        = "LONG"
+)
+
+var (
+	//SchemaToPinot is a mapping between types and Pinot field data types
+	//timestamps are sent as epoch milliseconds
+	SchemaToPinot = map[typing.DataType]string{
+		typing.STRING:    "STRING",
+		typing.INT64:     pinotLongType,
+		typing.FLOAT64:   "DOUBLE",
+		typing.TIMESTAMP: "TIMESTAMP",
+		typing.BOOL:      "BOOLEAN",
+		typing.UNKNOWN:   "STRING",
+	}
+)
+
+//PinotConfig is an Apache Pinot realtime tables configuration
+type PinotConfig struct {
+	ControllerUrl string `mapstructure:"controller_url" json:"controller_url,omitempty" yaml:"controller_url,omitempty"`
+	Username      string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password      string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	//Replicas is a number of replicas per partition. Default value is 1
+	Replicas int          `mapstructure:"replicas" json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Kafka    *KafkaConfig `mapstructure:"kafka" json:"kafka,omitempty" yaml:"kafka,omitempty"`
+}
+
+//Validate required fields in PinotConfig
+func (pc *PinotConfig) Validate() error {
+	if pc == nil {
+		return errors.New("pinot config is required")
+	}
+	if pc.ControllerUrl == "" {
+		return errors.New("controller_url is required parameter")
+	}
+
+	return pc.Kafka.Validate()
+}
+
+type pinotFieldSpec struct {
+	Name        string `json:"name"`
+	DataType    string `json:"dataType"`
+	Format      string `json:"format,omitempty"`
+	Granularity string `json:"granularity,omitempty"`
+}
+
+type pinotSchema struct {
+	SchemaName          string           `json:"schemaName"`
+	DimensionFieldSpecs []pinotFieldSpec `json:"dimensionFieldSpecs"`
+	MetricFieldSpecs    []pinotFieldSpec `json:"metricFieldSpecs,omitempty"`
+	DateTimeFieldSpecs  []pinotFieldSpec `json:"dateTimeFieldSpecs"`
+}
+
+//Pinot produces events into Kafka topics (one topic per table) and manages Pinot schemas and REALTIME tables
+//which consume the topics
+type Pinot struct {
+	config      *PinotConfig
+	producer    *KafkaProducer
+	client      *http.Client
+	debugLogger *logging.QueryLogger
+}
+
+func NewPinot(ctx context.Context, config *PinotConfig, requestDebugLogger *logging.QueryLogger) *Pinot {
+	if config.Replicas <= 0 {
+		config.Replicas = 1
+	}
+
+	return &Pinot{
+		config:      config,
+		producer:    NewKafkaProducer(ctx, config.Kafka),
+		client:      &http.Client{Timeout: 30 * time.Second},
+		debugLogger: requestDebugLogger,
+	}
+}
+
+//Insert produce event into the table Kafka topic. Timestamps are converted into epoch milliseconds
+func (p *Pinot) Insert(table *Table, event map[string]interface{}) error {
+	object := make(map[string]interface{}, len(event))
+	for key, value := range event {
+		if t, ok := value.(time.Time); ok {
+			value = t.UnixNano() / int64(time.Millisecond)
+		}
+		object[key] = value
+	}
+
+	return p.producer.Produce(p.config.Kafka.Topic(table.Name), object)
+}
+
+//Test check Pinot controller health
+func (p *Pinot) Test() error {
+	if _, err := p.request(http.MethodGet, "/health", nil, nil); err != nil {
+		return fmt.Errorf("Error connecting to Pinot controller: %v", err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return Pinot schema fields or empty Table if schema doesn't exist
+func (p *Pinot) GetTableSchema(tableName string) (*Table, error) {
+	table := &Table{Name: tableName, Columns: Columns{}, PKFields: map[string]bool{}}
+
+	schema := &pinotSchema{}
+	found, err := p.request(http.MethodGet, "/schemas/"+tableName, nil, schema)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return table, nil
+	}
+
+	for _, fields := range [][]pinotFieldSpec{schema.DimensionFieldSpecs, schema.MetricFieldSpecs, schema.DateTimeFieldSpecs} {
+		for _, field := range fields {
+			table.Columns[field.Name] = Column{SqlType: field.DataType}
+		}
+	}
+
+	return table, nil
+}
+
+//CreateTable create Pinot schema and REALTIME table which consumes the table Kafka topic
+func (p *Pinot) CreateTable(tableSchema *Table) error {
+	if err := p.postSchema(http.MethodPost, "/schemas", tableSchema); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(p.buildTableConfig(tableSchema.Name))
+	if err != nil {
+		return fmt.Errorf("Error marshalling Pinot table config: %v", err)
+	}
+	p.debugLogger.LogDDL(string(b))
+
+	if _, err := p.request(http.MethodPost, "/tables", b, nil); err != nil {
+		return fmt.Errorf("Error creating Pinot table [%s]: %v", tableSchema.Name, err)
+	}
+
+	return nil
+}
+
+//PatchTableSchema update Pinot schema with current and new fields and reload table segments
+func (p *Pinot) PatchTableSchema(patchSchema *Table) error {
+	current, err := p.GetTableSchema(patchSchema.Name)
+	if err != nil {
+		return err
+	}
+
+	for name, column := range patchSchema.Columns {
+		current.Columns[name] = column
+	}
+
+	return p.postSchema(http.MethodPut, "/schemas/"+patchSchema.Name+"?reload=true", current)
+}
+
+func (p *Pinot) postSchema(method, path string, table *Table) error {
+	b, err := json.Marshal(buildPinotSchema(table))
+	if err != nil {
+		return fmt.Errorf("Error marshalling Pinot schema: %v", err)
+	}
+	p.debugLogger.LogDDL(string(b))
+
+	if _, err := p.request(method, path, b, nil); err != nil {
+		return fmt.Errorf("Error saving Pinot schema [%s]: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+//buildPinotSchema return Pinot schema: timestamp column is a date time field, other columns are dimensions
+func buildPinotSchema(table *Table) *pinotSchema {
+	var names []string
+	for name := range table.Columns {
+		if name != timestamp.Key {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	schema := &pinotSchema{
+		SchemaName:          table.Name,
+		DimensionFieldSpecs: []pinotFieldSpec{},
+		DateTimeFieldSpecs: []pinotFieldSpec{{Name: timestamp.Key, DataType: pinotLongType, Format: pinotTimestampFormat,
+			Granularity: "1:MILLISECONDS"}},
+	}
+	for _, name := range names {
+		schema.DimensionFieldSpecs = append(schema.DimensionFieldSpecs, pinotFieldSpec{Name: name, DataType: table.Columns[name].SqlType})
+	}
+
+	return schema
+}
+
+func (p *Pinot) buildTableConfig(tableName string) map[string]interface{} {
+	return map[string]interface{}{
+		"tableName": tableName,
+		"tableType": "REALTIME",
+		"segmentsConfig": map[string]interface{}{
+			"schemaName":           tableName,
+			"timeColumnName":       timestamp.Key,
+			"replicasPerPartition": fmt.Sprint(p.config.Replicas),
+		},
+		"tenants":  map[string]interface{}{},
+		"metadata": map[string]interface{}{},
+		"tableIndexConfig": map[string]interface{}{
+			"loadMode": "MMAP",
+			"streamConfigs": map[string]interface{}{
+				"streamType":                                    "kafka",
+				"stream.kafka.consumer.type":                    "lowlevel",
+				"stream.kafka.topic.name":                       p.config.Kafka.Topic(tableName),
+				"stream.kafka.broker.list":                      strings.Join(p.config.Kafka.Brokers, ","),
+				"stream.kafka.decoder.class.name":               "org.apache.pinot.plugin.stream.kafka.KafkaJSONMessageDecoder",
+				"stream.kafka.consumer.factory.class.name":      "org.apache.pinot.plugin.stream.kafka20.KafkaConsumerFactory",
+				"stream.kafka.consumer.prop.auto.offset.reset":  "smallest",
+				"realtime.segment.flush.threshold.rows":         "0",
+				"realtime.segment.flush.threshold.time":         "24h",
+				"realtime.segment.flush.threshold.segment.size": "100M",
+			},
+		},
+	}
+}
+
+//request send request to Pinot controller and decode JSON response into result (if not nil)
+//return false if response is 404
+func (p *Pinot) request(method, path string, body []byte, result interface{}) (bool, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(p.config.ControllerUrl, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("Error reading Pinot response: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Pinot response code: %d body: %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return false, fmt.Errorf("Error parsing Pinot response: %v", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (p *Pinot) Close() error {
+	p.client.CloseIdleConnections()
+	return p.producer.Close()
+}
@@ -0,0 +1,18 @@
+package adapters
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBuildPinotSchema(t *testing.T) {
+	schema := buildPinotSchema(&Table{Name: "events", Columns: Columns{
+		"_timestamp": Column{SqlType: "TIMESTAMP"},
+		"user_id":    Column{SqlType: "STRING"},
+		"amount":     Column{SqlType: "DOUBLE"},
+	}})
+
+	require.Equal(t, "events", schema.SchemaName)
+	require.Equal(t, []pinotFieldSpec{{Name: "amount", DataType: "DOUBLE"}, {Name: "user_id", DataType: "STRING"}}, schema.DimensionFieldSpecs)
+	require.Equal(t, []pinotFieldSpec{{Name: "_timestamp", DataType: "LONG", Format: "1:MILLISECONDS:EPOCH", Granularity: "1:MILLISECONDS"}}, schema.DateTimeFieldSpecs)
+}
@@ -0,0 +1,123 @@
+package adapters
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxOpenConns       = 10
+	defaultMaxIdleConns       = 2
+	defaultConnMaxLifetimeSec = 1800
+)
+
+//PoolConfig is a SQL destination connection pool and statements configuration
+//without configuration connections are limited with default values (database/sql pool is unbounded)
+type PoolConfig struct {
+	MaxOpenConns        int `mapstructure:"max_open_conns" json:"max_open_conns,omitempty" yaml:"max_open_conns,omitempty"`
+	MaxIdleConns        int `mapstructure:"max_idle_conns" json:"max_idle_conns,omitempty" yaml:"max_idle_conns,omitempty"`
+	ConnMaxLifetimeSec  int `mapstructure:"conn_max_lifetime_sec" json:"conn_max_lifetime_sec,omitempty" yaml:"conn_max_lifetime_sec,omitempty"`
+	StatementTimeoutSec int `mapstructure:"statement_timeout_sec" json:"statement_timeout_sec,omitempty" yaml:"statement_timeout_sec,omitempty"`
+}
+
+//Validate return err if values are negative or max_idle_conns is greater than max_open_conns
+func (pc *PoolConfig) Validate() error {
+	if pc == nil {
+		return nil
+	}
+	if pc.MaxOpenConns < 0 || pc.MaxIdleConns < 0 || pc.ConnMaxLifetimeSec < 0 || pc.StatementTimeoutSec < 0 {
+		return errors.New("pool values can't be negative")
+	}
+	if pc.MaxIdleConns > pc.GetMaxOpenConns() {
+		return errors.New("pool max_idle_conns can't be greater than max_open_conns")
+	}
+
+	return nil
+}
+
+//GetMaxOpenConns return configured value or default one
+func (pc *PoolConfig) GetMaxOpenConns() int {
+	if pc == nil || pc.MaxOpenConns == 0 {
+		return defaultMaxOpenConns
+	}
+
+	return pc.MaxOpenConns
+}
+
+//GetMaxIdleConns return configured value or default one (but not greater than max open connections)
+func (pc *PoolConfig) GetMaxIdleConns() int {
+	if pc == nil || pc.MaxIdleConns == 0 {
+		if pc.GetMaxOpenConns() < defaultMaxIdleConns {
+			return pc.GetMaxOpenConns()
+		}
+		return defaultMaxIdleConns
+	}
+
+	return pc.MaxIdleConns
+}
+
+//GetConnMaxLifetime return configured value or default one
+func (pc *PoolConfig) GetConnMaxLifetime() time.Duration {
+	if pc == nil || pc.ConnMaxLifetimeSec == 0 {
+		return defaultConnMaxLifetimeSec * time.Second
+	}
+
+	return time.Duration(pc.ConnMaxLifetimeSec) * time.Second
+}
+
+//GetStatementTimeoutSec return configured value or 0 (driver default: without timeout)
+func (pc *PoolConfig) GetStatementTimeoutSec() int {
+	if pc == nil {
+		return 0
+	}
+
+	return pc.StatementTimeoutSec
+}
+
+//apply set connection pool limits
+func (pc *PoolConfig) apply(dataSource *sql.DB) {
+	dataSource.SetMaxOpenConns(pc.GetMaxOpenConns())
+	dataSource.SetMaxIdleConns(pc.GetMaxIdleConns())
+	dataSource.SetConnMaxLifetime(pc.GetConnMaxLifetime())
+}
+
+//EnrichPostgresParameters put statement_timeout (milliseconds) into Postgres/Redshift connection parameters if it isn't set explicitly
+func (pc *PoolConfig) EnrichPostgresParameters(parameters map[string]string) {
+	if pc.GetStatementTimeoutSec() == 0 {
+		return
+	}
+	if _, ok := parameters["statement_timeout"]; !ok {
+		parameters["statement_timeout"] = strconv.Itoa(pc.GetStatementTimeoutSec() * 1000)
+	}
+}
+
+//EnrichSnowflakeParameters put STATEMENT_TIMEOUT_IN_SECONDS session parameter if it isn't set explicitly
+func (pc *PoolConfig) EnrichSnowflakeParameters(parameters map[string]*string) {
+	if pc.GetStatementTimeoutSec() == 0 {
+		return
+	}
+	for key := range parameters {
+		if strings.EqualFold(key, "statement_timeout_in_seconds") {
+			return
+		}
+	}
+	timeout := strconv.Itoa(pc.GetStatementTimeoutSec())
+	parameters["STATEMENT_TIMEOUT_IN_SECONDS"] = &timeout
+}
+
+//EnrichClickHouseDsn return dsn with max_execution_time setting if it isn't set explicitly
+func (pc *PoolConfig) EnrichClickHouseDsn(dsn string) string {
+	if pc.GetStatementTimeoutSec() == 0 || strings.Contains(dsn, "max_execution_time=") {
+		return dsn
+	}
+
+	delimiter := "?"
+	if strings.Contains(dsn, "?") {
+		delimiter = "&"
+	}
+
+	return dsn + delimiter + "max_execution_time=" + strconv.Itoa(pc.GetStatementTimeoutSec())
+}
@@ -0,0 +1,41 @@
+package adapters
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPoolConfig(t *testing.T) {
+	var empty *PoolConfig
+	require.NoError(t, empty.Validate())
+	require.Equal(t, defaultMaxOpenConns, empty.GetMaxOpenConns())
+	require.Equal(t, defaultMaxIdleConns, empty.GetMaxIdleConns())
+	require.Equal(t, 30*time.Minute, empty.GetConnMaxLifetime())
+
+	require.Error(t, (&PoolConfig{MaxOpenConns: -1}).Validate())
+	require.Error(t, (&PoolConfig{MaxOpenConns: 2, MaxIdleConns: 3}).Validate())
+	require.Equal(t, 1, (&PoolConfig{MaxOpenConns: 1}).GetMaxIdleConns())
+
+	//statement timeout isn't set without configuration
+	parameters := map[string]string{}
+	empty.EnrichPostgresParameters(parameters)
+	require.Empty(t, parameters)
+	require.Equal(t, "tcp://host:9000", empty.EnrichClickHouseDsn("tcp://host:9000"))
+
+	pool := &PoolConfig{StatementTimeoutSec: 30}
+	pool.EnrichPostgresParameters(parameters)
+	require.Equal(t, map[string]string{"statement_timeout": "30000"}, parameters)
+
+	explicit := map[string]string{"statement_timeout": "1000"}
+	pool.EnrichPostgresParameters(explicit)
+	require.Equal(t, "1000", explicit["statement_timeout"])
+
+	require.Equal(t, "tcp://host:9000?max_execution_time=30", pool.EnrichClickHouseDsn("tcp://host:9000"))
+	require.Equal(t, "tcp://host:9000?debug=true&max_execution_time=30", pool.EnrichClickHouseDsn("tcp://host:9000?debug=true"))
+	require.Equal(t, "tcp://host:9000?max_execution_time=5", pool.EnrichClickHouseDsn("tcp://host:9000?max_execution_time=5"))
+
+	sfParameters := map[string]*string{}
+	pool.EnrichSnowflakeParameters(sfParameters)
+	require.Equal(t, "30", *sfParameters["STATEMENT_TIMEOUT_IN_SECONDS"])
+}
@@ -14,7 +14,7 @@ import (
 )
 
 const (
-	tableNamesQuery  = `SELECT table_name FROM information_schema.tables WHERE table_schema=$1`
+	tableNamesQuery  = `SELECT table_name FROM information_schema.tables WHERE table_schema=$1 AND table_type = 'BASE TABLE'`
 	tableSchemaQuery = `SELECT 
  							pg_attribute.attname AS name,
     						pg_catalog.format_type(pg_attribute.atttypid,pg_attribute.atttypmod) AS column_type
@@ -43,12 +43,26 @@ const (
 	dropPrimaryKeyTemplate            = "ALTER TABLE %s.%s DROP CONSTRAINT IF EXISTS %s"
 	alterPrimaryKeyTemplate           = `ALTER TABLE "%s"."%s" ADD CONSTRAINT %s PRIMARY KEY (%s)`
 	createTableTemplate               = `CREATE TABLE "%s"."%s" (%s)`
+	createIndexTemplate               = `CREATE INDEX ON "%s"."%s" %s(%s)`
+	sortKeyRedshiftTemplate           = ` COMPOUND SORTKEY (%s)`
+	commentColumnTemplate             = `COMMENT ON COLUMN "%s"."%s".%s IS '%s'`
 	insertTemplate                    = `INSERT INTO "%s"."%s" (%s) VALUES (%s)`
 	mergeTemplate                     = `INSERT INTO %s.%s(%s) VALUES(%s) ON CONFLICT ON CONSTRAINT %s DO UPDATE set %s;`
 	deleteQueryTemplate               = "DELETE FROM %s.%s WHERE %s"
+	createViewTemplate                = `CREATE OR REPLACE VIEW "%s"."%s" AS SELECT %s FROM "%s"."%s"`
+	createMaterializedViewTemplate    = `CREATE MATERIALIZED VIEW IF NOT EXISTS "%s"."%s" AS SELECT %s FROM "%s"."%s"`
+	refreshMaterializedViewTemplate   = `REFRESH MATERIALIZED VIEW "%s"."%s"`
+	userColumnsQueryTemplate          = `SELECT c.table_name, c.column_name FROM information_schema.columns c JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name WHERE c.table_schema = $1 AND t.table_type = 'BASE TABLE' AND c.column_name IN (%s)`
+	selectUserRowsTemplate            = `SELECT * FROM "%s"."%s" WHERE %s`
+	dropTableTemplate                 = `DROP TABLE IF EXISTS "%s"."%s"`
+	dropViewTemplate                  = `DROP VIEW IF EXISTS "%s"."%s"`
+	createShardsViewTemplate          = `CREATE VIEW "%s"."%s" AS %s`
 )
 
 var (
+	//escape JSON path element of text array literal inside SQL string literal
+	viewPathReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "'", "''")
+
 	SchemaToPostgres = map[typing.DataType]string{
 		typing.STRING:    "text",
 		typing.INT64:     "bigint",
@@ -99,10 +113,14 @@ type Postgres struct {
 	queryLogger *logging.QueryLogger
 
 	mappingTypeCasts map[string]string
+	tableOptions     *TableOptions
+	//underRedshift is true if tables are created in Redshift: sort keys are used instead of indexes
+	underRedshift bool
 }
 
 //NewPostgresUnderRedshift return configured Postgres adapter instance without mapping old types
-func NewPostgresUnderRedshift(ctx context.Context, config *DataSourceConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string) (*Postgres, error) {
+func NewPostgresUnderRedshift(ctx context.Context, config *DataSourceConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string,
+	tableOptions *TableOptions) (*Postgres, error) {
 	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
 		config.Host, config.Port, config.Db, config.Username, config.Password)
 	//concat provided connection parameters
@@ -118,11 +136,13 @@ func NewPostgresUnderRedshift(ctx context.Context, config *DataSourceConfig, que
 		return nil, err
 	}
 
-	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: mappingTypeCasts}, nil
+	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: mappingTypeCasts,
+		tableOptions: tableOptions, underRedshift: true}, nil
 }
 
 //NewPostgres return configured Postgres adapter instance
-func NewPostgres(ctx context.Context, config *DataSourceConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string) (*Postgres, error) {
+func NewPostgres(ctx context.Context, config *DataSourceConfig, queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string,
+	tableOptions *TableOptions) (*Postgres, error) {
 	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
 		config.Host, config.Port, config.Db, config.Username, config.Password)
 	//concat provided connection parameters
@@ -138,7 +158,8 @@ func NewPostgres(ctx context.Context, config *DataSourceConfig, queryLogger *log
 		return nil, err
 	}
 
-	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToPostgres)}, nil
+	return &Postgres{ctx: ctx, config: config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToPostgres),
+		tableOptions: tableOptions}, nil
 }
 
 func (Postgres) Name() string {
@@ -249,7 +270,7 @@ func (p *Postgres) getTable(tableName string) (*Table, error) {
 	return table, nil
 }
 
-//create table columns and pk key
+//create table columns, pk key and table options (sort key in Redshift, indexes in Postgres)
 //override input table sql type with configured cast type
 func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, table *Table) error {
 	var columnsDDL []string
@@ -265,6 +286,10 @@ func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, table *Table
 	//sorting columns asc
 	sort.Strings(columnsDDL)
 	query := fmt.Sprintf(createTableTemplate, p.config.Schema, table.Name, strings.Join(columnsDDL, ","))
+	partitioning, clusteringFields := p.tableOptions.forTable(table)
+	if p.underRedshift {
+		query += sortKeyClause(partitioning, clusteringFields)
+	}
 	p.queryLogger.LogDDL(query)
 	createStmt, err := wrappedTx.tx.PrepareContext(p.ctx, query)
 	if err != nil {
@@ -279,15 +304,67 @@ func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, table *Table
 		return fmt.Errorf("Error creating [%s] table: %v", table.Name, err)
 	}
 
+	err = p.commentColumnsInTransaction(wrappedTx, table)
+	if err != nil {
+		wrappedTx.Rollback()
+		return err
+	}
+
 	err = p.createPrimaryKeyInTransaction(wrappedTx, table)
 	if err != nil {
 		wrappedTx.Rollback()
 		return err
 	}
 
+	if !p.underRedshift {
+		if err := p.createIndexesInTransaction(wrappedTx, table.Name, partitioning, clusteringFields); err != nil {
+			wrappedTx.Rollback()
+			return err
+		}
+	}
+
 	return wrappedTx.tx.Commit()
 }
 
+//createIndexesInTransaction create BRIN index on partitioning field (Postgres tables aren't partitioned natively
+//because partitions must be created before inserting) and B-tree index on clustering fields
+func (p *Postgres) createIndexesInTransaction(wrappedTx *Transaction, tableName string, partitioning *PartitioningConfig, clusteringFields []string) error {
+	var queries []string
+	if partitioning != nil {
+		queries = append(queries, fmt.Sprintf(createIndexTemplate, p.config.Schema, tableName, "USING BRIN ", partitioning.GetField()))
+	}
+	if len(clusteringFields) > 0 {
+		queries = append(queries, fmt.Sprintf(createIndexTemplate, p.config.Schema, tableName, "", strings.Join(clusteringFields, ",")))
+	}
+
+	for _, query := range queries {
+		p.queryLogger.LogDDL(query)
+		if _, err := wrappedTx.tx.ExecContext(p.ctx, query); err != nil {
+			return fmt.Errorf("Error creating index on table %s: %v", tableName, err)
+		}
+	}
+
+	return nil
+}
+
+//sortKeyClause return Redshift compound sort key clause: partitioning field first and then clustering fields
+func sortKeyClause(partitioning *PartitioningConfig, clusteringFields []string) string {
+	var fields []string
+	if partitioning != nil {
+		fields = append(fields, partitioning.GetField())
+	}
+	for _, field := range clusteringFields {
+		if partitioning == nil || field != partitioning.GetField() {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(sortKeyRedshiftTemplate, strings.Join(fields, ","))
+}
+
 //alter table with columns (if not empty)
 //recreate primary key (if not empty) or delete primary key if Table.DeletePkFields is true
 func (p *Postgres) patchTableSchemaInTransaction(wrappedTx *Transaction, patchTable *Table) error {
@@ -314,6 +391,11 @@ func (p *Postgres) patchTableSchemaInTransaction(wrappedTx *Transaction, patchTa
 		}
 	}
 
+	if err := p.commentColumnsInTransaction(wrappedTx, patchTable); err != nil {
+		wrappedTx.Rollback()
+		return err
+	}
+
 	//patch primary keys - delete old
 	if len(patchTable.PKFields) > 0 || patchTable.DeletePkFields {
 		err := p.deletePrimaryKeyInTransaction(wrappedTx, patchTable)
@@ -335,6 +417,28 @@ func (p *Postgres) patchTableSchemaInTransaction(wrappedTx *Transaction, patchTa
 	return wrappedTx.DirectCommit()
 }
 
+//set comments (descriptions from mappings) of table columns which have them
+func (p *Postgres) commentColumnsInTransaction(wrappedTx *Transaction, table *Table) error {
+	for columnName, column := range table.Columns {
+		if column.Comment == "" {
+			continue
+		}
+
+		query := fmt.Sprintf(commentColumnTemplate, p.config.Schema, table.Name, columnName, escapeComment(column.Comment))
+		p.queryLogger.LogDDL(query)
+		commentStmt, err := wrappedTx.tx.PrepareContext(p.ctx, query)
+		if err != nil {
+			return fmt.Errorf("Error preparing comment on %s table column '%s' statement: %v", table.Name, columnName, err)
+		}
+
+		if _, err := commentStmt.ExecContext(p.ctx); err != nil {
+			return fmt.Errorf("Error commenting %s table column '%s': %v", table.Name, columnName, err)
+		}
+	}
+
+	return nil
+}
+
 //create primary key
 func (p *Postgres) createPrimaryKeyInTransaction(wrappedTx *Transaction, table *Table) error {
 	if len(table.PKFields) == 0 {
@@ -543,7 +647,94 @@ func updateSection(header string) string {
 	return removeLastComma(result)
 }
 
-//TablesList return slice of postgres table names
+//CreateView create (or replace) typed view or create materialized view if doesn't exist
+func (p *Postgres) CreateView(view *View) error {
+	query := p.viewQuery(view)
+	p.queryLogger.LogDDL(query)
+	if _, err := p.dataSource.ExecContext(p.ctx, query); err != nil {
+		return fmt.Errorf("Error creating [%s] view: %v", view.Name, err)
+	}
+
+	return nil
+}
+
+//CreateShardsView drop and create view over all shards in one transaction
+//(view is recreated because CREATE OR REPLACE VIEW can't change existing columns order)
+func (p *Postgres) CreateShardsView(view *ShardsView) error {
+	wrappedTx, err := p.OpenTx()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		fmt.Sprintf(dropViewTemplate, p.config.Schema, view.Name),
+		fmt.Sprintf(createShardsViewTemplate, p.config.Schema, view.Name, p.shardsViewSelectQuery(view)),
+	} {
+		p.queryLogger.LogDDL(query)
+		if _, err := wrappedTx.tx.ExecContext(p.ctx, query); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error creating [%s] shards view: %v", view.Name, err)
+		}
+	}
+
+	return wrappedTx.DirectCommit()
+}
+
+//shardsViewSelectQuery return UNION ALL of shards with NULL values of missing columns
+func (p *Postgres) shardsViewSelectQuery(view *ShardsView) string {
+	return view.SelectQuery(func(shard string) string {
+		return fmt.Sprintf(`"%s"."%s"`, p.config.Schema, shard)
+	}, func(column Column) string {
+		return "NULL::" + column.SqlType
+	})
+}
+
+//DropTable drop table if exists
+func (p *Postgres) DropTable(tableName string) error {
+	query := fmt.Sprintf(dropTableTemplate, p.config.Schema, tableName)
+	p.queryLogger.LogDDL(query)
+	if _, err := p.dataSource.ExecContext(p.ctx, query); err != nil {
+		return fmt.Errorf("Error dropping [%s] table: %v", tableName, err)
+	}
+
+	return nil
+}
+
+//RefreshView refresh materialized view data
+func (p *Postgres) RefreshView(viewName string) error {
+	query := fmt.Sprintf(refreshMaterializedViewTemplate, p.config.Schema, viewName)
+	p.queryLogger.LogQuery(query)
+	if _, err := p.dataSource.ExecContext(p.ctx, query); err != nil {
+		return fmt.Errorf("Error refreshing [%s] materialized view: %v", viewName, err)
+	}
+
+	return nil
+}
+
+//viewQuery return CREATE VIEW statement with all table columns and typed columns extracted from raw jsonb:
+//("_raw"::jsonb #>> '{"key1","key2"}')::bigint AS "key3"
+func (p *Postgres) viewQuery(view *View) string {
+	columns := []string{"*"}
+	for _, column := range view.Columns {
+		var quotedPath []string
+		for _, part := range column.Path {
+			quotedPath = append(quotedPath, `"`+viewPathReplacer.Replace(part)+`"`)
+		}
+		expression := fmt.Sprintf(`("%s"::jsonb #>> '{%s}')`, view.RawColumn, strings.Join(quotedPath, ","))
+		if column.SqlType != "" {
+			expression += "::" + column.SqlType
+		}
+		columns = append(columns, fmt.Sprintf(`%s AS "%s"`, expression, column.Name))
+	}
+
+	template := createViewTemplate
+	if view.Materialized {
+		template = createMaterializedViewTemplate
+	}
+	return fmt.Sprintf(template, p.config.Schema, view.Name, strings.Join(columns, ", "), p.config.Schema, view.Table)
+}
+
+//TablesList return slice of postgres table names (views are skipped)
 func (p *Postgres) TablesList() ([]string, error) {
 	var tableNames []string
 	rows, err := p.dataSource.QueryContext(p.ctx, tableNamesQuery, p.config.Schema)
@@ -566,6 +757,33 @@ func (p *Postgres) TablesList() ([]string, error) {
 	return tableNames, nil
 }
 
+//SelectUserRows return rows of all schema tables (views are skipped) where any of user columns equals userId (data-subject access requests)
+func (p *Postgres) SelectUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return selectUserRows(p.ctx, p.dataSource, &userRowsQueries{
+		columnsQuery: func(userColumns []string) (string, []interface{}) {
+			query := fmt.Sprintf(userColumnsQueryTemplate, placeholders(len(userColumns), 2, func(i int) string { return "$" + strconv.Itoa(i) }))
+			return query, append([]interface{}{p.config.Schema}, toInterfaces(userColumns)...)
+		},
+		selectQuery: func(tableName, condition string) string {
+			return fmt.Sprintf(selectUserRowsTemplate, p.config.Schema, tableName, condition)
+		},
+		whereCondition: func(column string, i int) string {
+			return fmt.Sprintf(`CAST("%s" AS TEXT) = $%d`, column, i)
+		},
+	}, userColumns, userId)
+}
+
+//Test check connectivity with a cheap query
+func (p *Postgres) Test() error {
+	_, err := p.dataSource.ExecContext(p.ctx, "SELECT 1")
+	return err
+}
+
+//ConfigurePool set connection pool limits (database/sql pool is unbounded by default)
+func (p *Postgres) ConfigurePool(pool *PoolConfig) {
+	pool.apply(p.dataSource)
+}
+
 //Close underlying sql.DB
 func (p *Postgres) Close() error {
 	return p.dataSource.Close()
@@ -595,6 +813,11 @@ func createDbSchemaInTransaction(ctx context.Context, wrappedTx *Transaction, st
 //handle old (deprecated) mapping types //TODO remove someday
 //put sql types as is
 //if mapping type is inner => map with sql type
+//escapeComment escape single quotes of comment inside SQL string literal
+func escapeComment(comment string) string {
+	return strings.ReplaceAll(comment, "'", "''")
+}
+
 func reformatMappings(mappingTypeCasts map[string]string, dbTypes map[typing.DataType]string) map[string]string {
 	formattedMappingTypeCasts := map[string]string{}
 	for column, sqlType := range mappingTypeCasts {
@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPostgresViewQuery(t *testing.T) {
+	p := &Postgres{config: &DataSourceConfig{Schema: "public"}}
+	view := &View{
+		Name:      "events_view",
+		Table:     "events",
+		RawColumn: "_raw",
+		Columns: []ViewColumn{
+			{Path: []string{"key1", "key2"}, Name: "key3", SqlType: "bigint"},
+			{Path: []string{"it's"}, Name: "quoted"},
+		},
+	}
+
+	require.Equal(t, `CREATE OR REPLACE VIEW "public"."events_view" AS SELECT *, ("_raw"::jsonb #>> '{"key1","key2"}')::bigint AS "key3", ("_raw"::jsonb #>> '{"it''s"}') AS "quoted" FROM "public"."events"`, p.viewQuery(view))
+
+	view.Materialized = true
+	view.Columns = view.Columns[:1]
+	require.Equal(t, `CREATE MATERIALIZED VIEW IF NOT EXISTS "public"."events_view" AS SELECT *, ("_raw"::jsonb #>> '{"key1","key2"}')::bigint AS "key3" FROM "public"."events"`, p.viewQuery(view))
+}
+
+func TestPostgresShardsViewSelectQuery(t *testing.T) {
+	p := &Postgres{config: &DataSourceConfig{Schema: "public"}}
+	view := &ShardsView{
+		Name: "events",
+		Shards: []*Table{
+			{Name: "events_0", Columns: Columns{"id": Column{SqlType: "text"}, "amount": Column{SqlType: "bigint"}}},
+			{Name: "events_1", Columns: Columns{"id": Column{SqlType: "text"}, "url": Column{SqlType: "text"}}},
+		},
+	}
+	require.Equal(t, `SELECT "amount", "id", NULL::text AS "url" FROM "public"."events_0" UNION ALL SELECT NULL::bigint AS "amount", "id", "url" FROM "public"."events_1"`, p.shardsViewSelectQuery(view))
+}
+
+func TestSortKeyClause(t *testing.T) {
+	require.Equal(t, "", sortKeyClause(nil, nil))
+	require.Equal(t, " COMPOUND SORTKEY (created_at,event_type)",
+		sortKeyClause(&PartitioningConfig{Field: "created_at"}, []string{"event_type", "created_at"}))
+	require.Equal(t, " COMPOUND SORTKEY (event_type)", sortKeyClause(nil, []string{"event_type"}))
+}
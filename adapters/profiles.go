@@ -0,0 +1,170 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/typing"
+	"time"
+)
+
+const (
+	ProfilesRedisStorage    = "redis"
+	ProfilesPostgresStorage = "postgres"
+
+	defaultProfilesUserIdField    = "eventn_ctx_user_id"
+	defaultProfilesTraitsPrefix   = "eventn_ctx_user_"
+	defaultProfilesEventTypeField = "event_type"
+	defaultProfilesTable          = "user_profiles"
+	defaultProfilesKeyPrefix      = "profile:"
+
+	createProfilesTableTemplate = `CREATE TABLE IF NOT EXISTS "%s"."%s" (user_id text PRIMARY KEY, traits jsonb NOT NULL DEFAULT '{}', created_at timestamp NOT NULL, updated_at timestamp NOT NULL)`
+	upsertProfileTemplate       = `INSERT INTO "%s"."%s" AS p (user_id, traits, created_at, updated_at) VALUES ($1, $2, $3, $3) ON CONFLICT (user_id) DO UPDATE SET traits = p.traits || EXCLUDED.traits, updated_at = EXCLUDED.updated_at`
+)
+
+//SchemaToProfiles profiles traits are stored as JSON values regardless of types
+var SchemaToProfiles = map[typing.DataType]string{
+	typing.STRING:    "json",
+	typing.INT64:     "json",
+	typing.FLOAT64:   "json",
+	typing.TIMESTAMP: "json",
+	typing.BOOL:      "json",
+	typing.UNKNOWN:   "json",
+}
+
+//ProfilesConfig is a materialized user profiles destination configuration
+//fields are flat (processed) event column names: nested traits are merged by their flattened names
+type ProfilesConfig struct {
+	Storage string `mapstructure:"storage" json:"storage,omitempty" yaml:"storage,omitempty"`
+	//UserIdField is a profile key column. Events without user id are skipped
+	UserIdField string `mapstructure:"user_id_field" json:"user_id_field,omitempty" yaml:"user_id_field,omitempty"`
+	//TraitsPrefix is a prefix of trait columns. It is cut from trait names
+	TraitsPrefix string `mapstructure:"traits_prefix" json:"traits_prefix,omitempty" yaml:"traits_prefix,omitempty"`
+	//EventTypes are event types which update profiles (e.g. identify events). If empty, all events with user id are used
+	EventTypes     []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	EventTypeField string   `mapstructure:"event_type_field" json:"event_type_field,omitempty" yaml:"event_type_field,omitempty"`
+	//Table is a Postgres profiles table name (datasource config is used for connection)
+	Table string               `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	Redis *ProfilesRedisConfig `mapstructure:"redis" json:"redis,omitempty" yaml:"redis,omitempty"`
+}
+
+//ProfilesRedisConfig is a Redis connection configuration. Profiles are stored as hashes <key_prefix><user id>
+type ProfilesRedisConfig struct {
+	Host      string `mapstructure:"host" json:"host,omitempty" yaml:"host,omitempty"`
+	Port      int    `mapstructure:"port" json:"port,omitempty" yaml:"port,omitempty"`
+	Password  string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	KeyPrefix string `mapstructure:"key_prefix" json:"key_prefix,omitempty" yaml:"key_prefix,omitempty"`
+	//TTLDays is a profile expiration since the last update. 0 means without expiration
+	TTLDays int `mapstructure:"ttl_days" json:"ttl_days,omitempty" yaml:"ttl_days,omitempty"`
+}
+
+//Validate return err if storage is unknown or its connection config is absent. Set default values
+func (pc *ProfilesConfig) Validate(dataSource *DataSourceConfig) error {
+	if pc == nil {
+		return errors.New("profiles config is required")
+	}
+
+	switch pc.Storage {
+	case ProfilesRedisStorage:
+		if pc.Redis == nil || pc.Redis.Host == "" {
+			return errors.New("profiles redis host is required parameter")
+		}
+		if pc.Redis.Port == 0 {
+			pc.Redis.Port = 6379
+		}
+		if pc.Redis.KeyPrefix == "" {
+			pc.Redis.KeyPrefix = defaultProfilesKeyPrefix
+		}
+		if pc.Redis.TTLDays < 0 {
+			return fmt.Errorf("profiles redis ttl_days can't be negative: %d", pc.Redis.TTLDays)
+		}
+	case ProfilesPostgresStorage:
+		if err := dataSource.Validate(); err != nil {
+			return err
+		}
+		if pc.Table == "" {
+			pc.Table = defaultProfilesTable
+		}
+	default:
+		return fmt.Errorf("Unknown profiles storage: %s. Available storages: [%s, %s]", pc.Storage, ProfilesRedisStorage, ProfilesPostgresStorage)
+	}
+
+	if pc.UserIdField == "" {
+		pc.UserIdField = defaultProfilesUserIdField
+	}
+	if pc.TraitsPrefix == "" {
+		pc.TraitsPrefix = defaultProfilesTraitsPrefix
+	}
+	if pc.EventTypeField == "" {
+		pc.EventTypeField = defaultProfilesEventTypeField
+	}
+
+	return nil
+}
+
+//ProfilesStore upserts user profiles: new traits are merged into existing ones (the latest value wins)
+type ProfilesStore interface {
+	Upsert(userId string, traits map[string]interface{}, updatedAt time.Time) error
+	Test() error
+	Close() error
+}
+
+//PostgresProfiles stores profiles into the table with jsonb traits column
+type PostgresProfiles struct {
+	postgres *Postgres
+	table    string
+}
+
+//NewPostgresProfiles create db schema and profiles table if they don't exist
+func NewPostgresProfiles(ctx context.Context, config *DataSourceConfig, table string, queryLogger *logging.QueryLogger) (*PostgresProfiles, error) {
+	postgres, err := NewPostgres(ctx, config, queryLogger, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := postgres.CreateDbSchema(config.Schema); err != nil {
+		postgres.Close()
+		return nil, err
+	}
+
+	query := fmt.Sprintf(createProfilesTableTemplate, config.Schema, table)
+	queryLogger.LogDDL(query)
+	if _, err := postgres.dataSource.ExecContext(ctx, query); err != nil {
+		postgres.Close()
+		return nil, fmt.Errorf("Error creating profiles table [%s]: %v", table, err)
+	}
+
+	return &PostgresProfiles{postgres: postgres, table: table}, nil
+}
+
+//Upsert insert profile or merge traits into existing one (top level jsonb concatenation)
+func (pp *PostgresProfiles) Upsert(userId string, traits map[string]interface{}, updatedAt time.Time) error {
+	b, err := json.Marshal(traits)
+	if err != nil {
+		return fmt.Errorf("Error marshaling traits: %v", err)
+	}
+
+	query := fmt.Sprintf(upsertProfileTemplate, pp.postgres.config.Schema, pp.table)
+	values := []interface{}{userId, string(b), updatedAt.UTC()}
+	pp.postgres.queryLogger.LogQueryWithValues(query, values)
+	if _, err := pp.postgres.dataSource.ExecContext(pp.postgres.ctx, query, values...); err != nil {
+		return fmt.Errorf("Error upserting profile [%s]: %v", userId, err)
+	}
+
+	return nil
+}
+
+//ConfigurePool set connection pool limits
+func (pp *PostgresProfiles) ConfigurePool(pool *PoolConfig) {
+	pp.postgres.ConfigurePool(pool)
+}
+
+func (pp *PostgresProfiles) Test() error {
+	return pp.postgres.Test()
+}
+
+func (pp *PostgresProfiles) Close() error {
+	return pp.postgres.Close()
+}
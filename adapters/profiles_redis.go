@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strconv"
+	"time"
+)
+
+//updatedAtTrait is a hash field with the last profile update time
+const updatedAtTrait = "_updated_at"
+
+//RedisProfiles stores profiles as Redis hashes: trait name -> JSON value
+type RedisProfiles struct {
+	config *ProfilesRedisConfig
+	pool   *redis.Pool
+}
+
+//NewRedisProfiles return RedisProfiles with checked connection
+func NewRedisProfiles(config *ProfilesRedisConfig) (*RedisProfiles, error) {
+	rp := &RedisProfiles{config: config, pool: &redis.Pool{
+		MaxIdle:     10,
+		MaxActive:   100,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				config.Host+":"+strconv.Itoa(config.Port),
+				redis.DialConnectTimeout(10*time.Second),
+				redis.DialReadTimeout(10*time.Second),
+				redis.DialPassword(config.Password),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}}
+
+	if err := rp.Test(); err != nil {
+		rp.pool.Close()
+		return nil, err
+	}
+
+	return rp, nil
+}
+
+//Upsert set traits hash fields (existing fields are overwritten, others are kept) and prolong ttl
+func (rp *RedisProfiles) Upsert(userId string, traits map[string]interface{}, updatedAt time.Time) error {
+	key := rp.config.KeyPrefix + userId
+	args := redis.Args{key, updatedAtTrait, timestamp.ToISOFormat(updatedAt.UTC())}
+	for name, value := range traits {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("Error marshaling trait [%s]: %v", name, err)
+		}
+		args = append(args, name, string(b))
+	}
+
+	connection := rp.pool.Get()
+	defer connection.Close()
+
+	if _, err := connection.Do("HMSET", args...); err != nil {
+		return fmt.Errorf("Error upserting profile [%s]: %v", userId, err)
+	}
+	if rp.config.TTLDays > 0 {
+		if _, err := connection.Do("EXPIRE", key, rp.config.TTLDays*24*60*60); err != nil {
+			return fmt.Errorf("Error setting profile [%s] ttl: %v", userId, err)
+		}
+	}
+
+	return nil
+}
+
+//Test check connectivity with PING
+func (rp *RedisProfiles) Test() error {
+	connection := rp.pool.Get()
+	defer connection.Close()
+
+	if _, err := redis.String(connection.Do("PING")); err != nil {
+		return fmt.Errorf("Error testing connection to Redis: %v", err)
+	}
+
+	return nil
+}
+
+func (rp *RedisProfiles) Close() error {
+	return rp.pool.Close()
+}
@@ -0,0 +1,180 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SpectrumPartitionColumn = "load_date"
+
+	defaultSpectrumSchema = "spectrum"
+
+	spectrumColumnsQuery          = `SELECT columnname, external_type FROM svv_external_columns WHERE schemaname = $1 AND tablename = $2 AND part_key = 0`
+	createExternalSchemaTemplate  = `CREATE EXTERNAL SCHEMA IF NOT EXISTS "%s" FROM DATA CATALOG DATABASE '%s' IAM_ROLE '%s' CREATE EXTERNAL DATABASE IF NOT EXISTS`
+	createExternalTableTemplate   = `CREATE EXTERNAL TABLE "%s"."%s" (%s) PARTITIONED BY (` + SpectrumPartitionColumn + ` date) ROW FORMAT SERDE 'org.openx.data.jsonserde.JsonSerDe' STORED AS TEXTFILE LOCATION '%s'`
+	addExternalPartitionTemplate  = `ALTER TABLE "%s"."%s" ADD IF NOT EXISTS PARTITION (` + SpectrumPartitionColumn + `='%s') LOCATION '%s'`
+	addExternalColumnTemplate     = `ALTER TABLE "%s"."%s" ADD COLUMN %s %s`
+	spectrumPartitionFolderLayout = "2006-01-02"
+)
+
+//RedshiftSpectrumConfig is a Redshift Spectrum external schema config
+//external tables are stored in AWS Glue Data Catalog database (default: the same as external_schema)
+//iam_role must allow Redshift to read the S3 bucket and to use the Data Catalog
+type RedshiftSpectrumConfig struct {
+	ExternalSchema string `mapstructure:"external_schema" json:"external_schema,omitempty" yaml:"external_schema,omitempty"`
+	Database       string `mapstructure:"database" json:"database,omitempty" yaml:"database,omitempty"`
+	IamRole        string `mapstructure:"iam_role" json:"iam_role,omitempty" yaml:"iam_role,omitempty"`
+}
+
+func (rsc *RedshiftSpectrumConfig) Validate() error {
+	if rsc == nil {
+		return errors.New("Spectrum config is required")
+	}
+	if rsc.IamRole == "" {
+		return errors.New("Spectrum iam_role is required parameter")
+	}
+	if rsc.ExternalSchema == "" {
+		rsc.ExternalSchema = defaultSpectrumSchema
+	}
+	if rsc.Database == "" {
+		rsc.Database = rsc.ExternalSchema
+	}
+
+	return nil
+}
+
+//RedshiftSpectrum adapter for creating and patching Redshift Spectrum external tables
+//and registering uploaded S3 files as table partitions by load date
+//external tables DDL can't be run inside a transaction block
+type RedshiftSpectrum struct {
+	redshift *AwsRedshift
+	config   *RedshiftSpectrumConfig
+}
+
+//NewRedshiftSpectrum return configured RedshiftSpectrum adapter instance which uses Redshift connection
+func NewRedshiftSpectrum(redshift *AwsRedshift, config *RedshiftSpectrumConfig) (*RedshiftSpectrum, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &RedshiftSpectrum{redshift: redshift, config: config}, nil
+}
+
+//CreateExternalSchema create external schema and Data Catalog database if don't exist
+func (rs *RedshiftSpectrum) CreateExternalSchema() error {
+	return rs.exec(fmt.Sprintf(createExternalSchemaTemplate, rs.config.ExternalSchema, rs.config.Database, rs.config.IamRole))
+}
+
+//GetTableSchema return external table columns without partition column
+func (rs *RedshiftSpectrum) GetTableSchema(tableName string) (*Table, error) {
+	proxy := rs.redshift.dataSourceProxy
+	table := &Table{Name: tableName, Columns: map[string]Column{}, PKFields: map[string]bool{}}
+	rows, err := proxy.dataSource.QueryContext(proxy.ctx, spectrumColumnsQuery, rs.config.ExternalSchema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying external table [%s] schema: %v", tableName, err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnType string
+		if err := rows.Scan(&columnName, &columnType); err != nil {
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+
+		table.Columns[columnName] = Column{SqlType: columnType}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return table, nil
+}
+
+//CreateTable create external table partitioned by load date
+//primary keys aren't supported by external tables and are ignored
+func (rs *RedshiftSpectrum) CreateTable(table *Table) error {
+	var columnsDDL []string
+	for columnName, column := range table.Columns {
+		columnsDDL = append(columnsDDL, fmt.Sprintf(`%s %s`, columnName, rs.sqlType(columnName, column)))
+	}
+
+	//sorting columns asc
+	sort.Strings(columnsDDL)
+	query := fmt.Sprintf(createExternalTableTemplate, rs.config.ExternalSchema, table.Name, strings.Join(columnsDDL, ","), rs.tableLocation(table.Name))
+	if err := rs.exec(query); err != nil {
+		return fmt.Errorf("Error creating [%s] external table: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+//PatchTableSchema add new columns one by one. Primary keys are ignored
+func (rs *RedshiftSpectrum) PatchTableSchema(patchTable *Table) error {
+	for columnName, column := range patchTable.Columns {
+		query := fmt.Sprintf(addExternalColumnTemplate, rs.config.ExternalSchema, patchTable.Name, columnName, rs.sqlType(columnName, column))
+		if err := rs.exec(query); err != nil {
+			return fmt.Errorf("Error patching %s external table with '%s' - %s column schema: %v", patchTable.Name, columnName, column.SqlType, err)
+		}
+	}
+
+	return nil
+}
+
+//AddPartition register S3 folder of the load date as external table partition
+func (rs *RedshiftSpectrum) AddPartition(tableName string, loadDate time.Time) error {
+	partition := loadDate.Format(spectrumPartitionFolderLayout)
+	query := fmt.Sprintf(addExternalPartitionTemplate, rs.config.ExternalSchema, tableName, partition, rs.partitionLocation(tableName, loadDate))
+	if err := rs.exec(query); err != nil {
+		return fmt.Errorf("Error adding partition [%s] to [%s] external table: %v", partition, tableName, err)
+	}
+
+	return nil
+}
+
+//FileKey return S3 key (without configured folder) of the file in the load date partition folder
+func (rs *RedshiftSpectrum) FileKey(tableName string, loadDate time.Time, fileName string) string {
+	return rs.partitionKey(tableName, loadDate) + fileName
+}
+
+func (rs *RedshiftSpectrum) partitionKey(tableName string, loadDate time.Time) string {
+	return tableName + "/" + SpectrumPartitionColumn + "=" + loadDate.Format(spectrumPartitionFolderLayout) + "/"
+}
+
+func (rs *RedshiftSpectrum) tableLocation(tableName string) string {
+	return rs.s3Url(tableName + "/")
+}
+
+func (rs *RedshiftSpectrum) partitionLocation(tableName string, loadDate time.Time) string {
+	return rs.s3Url(rs.partitionKey(tableName, loadDate))
+}
+
+//s3Url return s3://bucket/folder/key
+func (rs *RedshiftSpectrum) s3Url(key string) string {
+	s3Config := rs.redshift.s3Config
+	if s3Config.Folder != "" {
+		key = s3Config.Folder + "/" + key
+	}
+
+	return "s3://" + s3Config.Bucket + "/" + key
+}
+
+//sqlType return configured cast type or column type
+func (rs *RedshiftSpectrum) sqlType(columnName string, column Column) string {
+	if castedSqlType, ok := rs.redshift.dataSourceProxy.mappingTypeCasts[columnName]; ok {
+		return castedSqlType
+	}
+
+	return column.SqlType
+}
+
+func (rs *RedshiftSpectrum) exec(query string) error {
+	proxy := rs.redshift.dataSourceProxy
+	proxy.queryLogger.LogDDL(query)
+	_, err := proxy.dataSource.ExecContext(proxy.ctx, query)
+	return err
+}
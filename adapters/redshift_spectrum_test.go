@@ -0,0 +1,24 @@
+package adapters
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedshiftSpectrumLocations(t *testing.T) {
+	config := &RedshiftSpectrumConfig{IamRole: "arn:aws:iam::123:role/spectrum"}
+	redshift := &AwsRedshift{s3Config: &S3Config{Bucket: "bucket", Folder: "folder"}}
+	spectrum, err := NewRedshiftSpectrum(redshift, config)
+	require.NoError(t, err)
+	require.Equal(t, "spectrum", config.ExternalSchema)
+	require.Equal(t, "spectrum", config.Database)
+
+	loadDate := time.Date(2021, 2, 3, 10, 0, 0, 0, time.UTC)
+	require.Equal(t, "events/load_date=2021-02-03/file.log", spectrum.FileKey("events", loadDate, "file.log"))
+	require.Equal(t, "s3://bucket/folder/events/", spectrum.tableLocation("events"))
+	require.Equal(t, "s3://bucket/folder/events/load_date=2021-02-03/", spectrum.partitionLocation("events", loadDate))
+
+	_, err = NewRedshiftSpectrum(redshift, &RedshiftSpectrumConfig{})
+	require.Error(t, err)
+}
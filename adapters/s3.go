@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"io"
 	"net/http"
+	"strings"
 )
 
 type S3 struct {
@@ -24,6 +25,8 @@ type S3Config struct {
 	Region      string `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
 	Endpoint    string `mapstructure:"endpoint" json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 	Folder      string `mapstructure:"folder" json:"folder,omitempty" yaml:"folder,omitempty"`
+
+	Compression *CompressionConfig `mapstructure:"compression" json:"compression,omitempty" yaml:"compression,omitempty"`
 }
 
 func (s3c *S3Config) Validate() error {
@@ -42,6 +45,9 @@ func (s3c *S3Config) Validate() error {
 	if s3c.Region == "" {
 		return errors.New("S3 region is required parameter")
 	}
+	if err := s3c.Compression.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -64,10 +70,29 @@ func NewS3(s3Config *S3Config) (*S3, error) {
 
 //Create named file on s3 with payload
 func (a *S3) UploadBytes(fileName string, fileBytes []byte) error {
+	return a.uploadBytes(fileName, fileBytes, http.DetectContentType(fileBytes))
+}
+
+//UploadCompressedBytes compress payload with configured codec and create named file with codec extension on s3
+//return uploaded file name
+func (a *S3) UploadCompressedBytes(fileName string, fileBytes []byte) (string, error) {
+	if a.config.Compression.GetCodec() == CompressionNone {
+		return fileName, a.UploadBytes(fileName, fileBytes)
+	}
+
+	compressed, err := a.config.Compression.Compress(fileBytes)
+	if err != nil {
+		return "", err
+	}
+
+	fileName += a.config.Compression.Extension()
+	return fileName, a.uploadBytes(fileName, compressed, a.config.Compression.ContentType())
+}
+
+func (a *S3) uploadBytes(fileName string, fileBytes []byte, fileType string) error {
 	if a.config.Folder != "" {
 		fileName = a.config.Folder + "/" + fileName
 	}
-	fileType := http.DetectContentType(fileBytes)
 	params := &s3.PutObjectInput{
 		Bucket:      aws.String(a.config.Bucket),
 		Key:         aws.String(fileName),
@@ -108,6 +133,36 @@ func (a *S3) ListBucket(fileNamePrefix string) ([]string, error) {
 	return files, nil
 }
 
+//ListObjects return s3 bucket files filtered by key prefix. Keys are returned without configured folder
+func (a *S3) ListObjects(prefix string) ([]*ObjectInfo, error) {
+	folderPrefix := ""
+	if a.config.Folder != "" {
+		folderPrefix = a.config.Folder + "/"
+	}
+	fullPrefix := folderPrefix + prefix
+	input := &s3.ListObjectsV2Input{Bucket: &a.config.Bucket, Prefix: &fullPrefix}
+	var objects []*ObjectInfo
+	for {
+		output, err := a.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing s3 bucket %s: %v", a.config.Bucket, err)
+		}
+		for _, f := range output.Contents {
+			objects = append(objects, &ObjectInfo{
+				Key:      strings.TrimPrefix(aws.StringValue(f.Key), folderPrefix),
+				Size:     aws.Int64Value(f.Size),
+				Modified: aws.TimeValue(f.LastModified),
+			})
+		}
+		if !aws.BoolValue(output.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
 //Return s3 file by key
 //Deprecated
 func (a *S3) GetObject(key string) ([]byte, error) {
@@ -147,6 +202,15 @@ func (a *S3) DeleteObject(key string) error {
 	return nil
 }
 
+//Test check bucket availability and credentials with HEAD bucket request
+func (a *S3) Test() error {
+	if _, err := a.client.HeadBucket(&s3.HeadBucketInput{Bucket: &a.config.Bucket}); err != nil {
+		return fmt.Errorf("Error checking s3 bucket %s: %v", a.config.Bucket, err)
+	}
+
+	return nil
+}
+
 func (a *S3) Close() error {
 	return nil
 }
@@ -25,7 +25,12 @@ const (
 	createSFDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS %s`
 	addSFColumnTemplate                 = `ALTER TABLE %s.%s ADD COLUMN %s %s`
 	createSFTableTemplate               = `CREATE TABLE %s.%s (%s)`
+	clusterBySFTemplate                 = ` CLUSTER BY (%s)`
 	insertSFTemplate                    = `INSERT INTO %s.%s (%s) VALUES (%s)`
+	userColumnsSFQueryTemplate          = `SELECT C.TABLE_NAME, C.COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS C JOIN INFORMATION_SCHEMA.TABLES T ON T.TABLE_SCHEMA = C.TABLE_SCHEMA AND T.TABLE_NAME = C.TABLE_NAME WHERE C.TABLE_SCHEMA = ? AND T.TABLE_TYPE = 'BASE TABLE' AND C.COLUMN_NAME IN (%s)`
+	selectUserRowsSFTemplate            = `SELECT * FROM %s."%s" WHERE %s`
+	dropSFTableTemplate                 = `DROP TABLE IF EXISTS %s.%s`
+	tablesListSFQuery                   = `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'`
 )
 
 var (
@@ -86,11 +91,12 @@ type Snowflake struct {
 	dataSource       *sql.DB
 	queryLogger      *logging.QueryLogger
 	mappingTypeCasts map[string]string
+	tableOptions     *TableOptions
 }
 
 //NewSnowflake return configured Snowflake adapter instance
 func NewSnowflake(ctx context.Context, config *SnowflakeConfig, s3Config *S3Config,
-	queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string) (*Snowflake, error) {
+	queryLogger *logging.QueryLogger, mappingTypeCasts map[string]string, tableOptions *TableOptions) (*Snowflake, error) {
 	cfg := &sf.Config{
 		Account:   config.Account,
 		User:      config.Username,
@@ -115,7 +121,8 @@ func NewSnowflake(ctx context.Context, config *SnowflakeConfig, s3Config *S3Conf
 		return nil, err
 	}
 
-	return &Snowflake{ctx: ctx, config: config, s3Config: s3Config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToSnowflake)}, nil
+	return &Snowflake{ctx: ctx, config: config, s3Config: s3Config, dataSource: dataSource, queryLogger: queryLogger, mappingTypeCasts: reformatMappings(mappingTypeCasts, SchemaToSnowflake),
+		tableOptions: tableOptions}, nil
 }
 
 func (Snowflake) Name() string {
@@ -157,12 +164,13 @@ func (s *Snowflake) CreateTable(tableSchema *Table) error {
 		if ok {
 			sqlType = castedSqlType
 		}
-		columnsDDL = append(columnsDDL, fmt.Sprintf(`%s %s`, reformatValue(columnName), sqlType))
+		columnsDDL = append(columnsDDL, fmt.Sprintf(`%s %s%s`, reformatValue(columnName), sqlType, columnComment(column)))
 	}
 
 	//sorting columns asc
 	sort.Strings(columnsDDL)
 	query := fmt.Sprintf(createSFTableTemplate, s.config.Schema, reformatValue(tableSchema.Name), strings.Join(columnsDDL, ","))
+	query += clusterByClause(s.tableOptions.forTable(tableSchema))
 	s.queryLogger.LogDDL(query)
 	createStmt, err := wrappedTx.tx.PrepareContext(s.ctx, query)
 	if err != nil {
@@ -179,6 +187,40 @@ func (s *Snowflake) CreateTable(tableSchema *Table) error {
 	return wrappedTx.tx.Commit()
 }
 
+//DropTable drop table if exists
+func (s *Snowflake) DropTable(tableName string) error {
+	query := fmt.Sprintf(dropSFTableTemplate, s.config.Schema, reformatValue(tableName))
+	s.queryLogger.LogDDL(query)
+	if _, err := s.dataSource.ExecContext(s.ctx, query); err != nil {
+		return fmt.Errorf("Error dropping [%s] table: %v", tableName, err)
+	}
+
+	return nil
+}
+
+//TablesList return slice of lower case Snowflake table names (views are skipped)
+func (s *Snowflake) TablesList() ([]string, error) {
+	var tableNames []string
+	rows, err := s.dataSource.QueryContext(s.ctx, tablesListSFQuery, reformatToParam(s.config.Schema))
+	if err != nil {
+		return tableNames, fmt.Errorf("Error querying tables names: %v", err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return tableNames, fmt.Errorf("Error scanning table name: %v", err)
+		}
+		tableNames = append(tableNames, strings.ToLower(tableName))
+	}
+	if err := rows.Err(); err != nil {
+		return tableNames, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return tableNames, nil
+}
+
 //PatchTableSchema add new columns(from provided Table) to existing table
 func (s *Snowflake) PatchTableSchema(patchSchema *Table) error {
 	wrappedTx, err := s.OpenTx()
@@ -193,7 +235,7 @@ func (s *Snowflake) PatchTableSchema(patchSchema *Table) error {
 			sqlType = castedSqlType
 		}
 		query := fmt.Sprintf(addSFColumnTemplate, s.config.Schema,
-			reformatValue(patchSchema.Name), reformatValue(columnName), sqlType)
+			reformatValue(patchSchema.Name), reformatValue(columnName), sqlType+columnComment(column))
 		s.queryLogger.LogDDL(query)
 		alterStmt, err := wrappedTx.tx.PrepareContext(s.ctx, query)
 		if err != nil {
@@ -311,6 +353,36 @@ func (s *Snowflake) Insert(table *Table, valuesMap map[string]interface{}) error
 	return wrappedTx.DirectCommit()
 }
 
+//SelectUserRows return rows of all schema tables (views are skipped) where any of user columns equals userId (data-subject access requests)
+func (s *Snowflake) SelectUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return selectUserRows(s.ctx, s.dataSource, &userRowsQueries{
+		columnsQuery: func(userColumns []string) (string, []interface{}) {
+			args := []interface{}{reformatToParam(s.config.Schema)}
+			for _, column := range userColumns {
+				args = append(args, reformatToParam(reformatValue(column)))
+			}
+			return fmt.Sprintf(userColumnsSFQueryTemplate, placeholders(len(userColumns), 1, func(i int) string { return "?" })), args
+		},
+		selectQuery: func(tableName, condition string) string {
+			return fmt.Sprintf(selectUserRowsSFTemplate, s.config.Schema, tableName, condition)
+		},
+		whereCondition: func(column string, i int) string {
+			return fmt.Sprintf(`TO_VARCHAR("%s") = ?`, column)
+		},
+	}, userColumns, userId)
+}
+
+//Test check connectivity with a cheap query
+func (s *Snowflake) Test() error {
+	_, err := s.dataSource.ExecContext(s.ctx, "SELECT 1")
+	return err
+}
+
+//ConfigurePool set connection pool limits
+func (s *Snowflake) ConfigurePool(pool *PoolConfig) {
+	pool.apply(s.dataSource)
+}
+
 //Close underlying sql.DB
 func (s *Snowflake) Close() (multiErr error) {
 	return s.dataSource.Close()
@@ -363,3 +435,30 @@ func isNotLetterOrUnderscore(symbol int32) bool {
 func isNotNumberOrDollar(symbol int32) bool {
 	return symbol != 36 && (symbol < 48 || symbol > 57)
 }
+
+//columnComment return inline column comment DDL (e.g. " COMMENT 'description'") or empty string
+//backslashes are escape characters in Snowflake string literals
+//clusterByClause return Snowflake clustering key clause (Snowflake tables aren't partitioned explicitly):
+//partitioning field truncated to granularity first and then clustering fields
+func clusterByClause(partitioning *PartitioningConfig, clusteringFields []string) string {
+	var expressions []string
+	if partitioning != nil {
+		expressions = append(expressions, fmt.Sprintf("DATE_TRUNC('%s', %s)", partitioning.GetGranularity(), reformatValue(partitioning.GetField())))
+	}
+	for _, field := range clusteringFields {
+		expressions = append(expressions, reformatValue(field))
+	}
+	if len(expressions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(clusterBySFTemplate, strings.Join(expressions, ","))
+}
+
+func columnComment(column Column) string {
+	if column.Comment == "" {
+		return ""
+	}
+
+	return " COMMENT '" + escapeComment(strings.ReplaceAll(column.Comment, `\`, `\\`)) + "'"
+}
@@ -87,3 +87,16 @@ func TestReformatToParam(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnComment(t *testing.T) {
+	require.Equal(t, "", columnComment(Column{SqlType: "text"}))
+	require.Equal(t, " COMMENT 'User''s email'", columnComment(Column{SqlType: "text", Comment: "User's email"}))
+	require.Equal(t, ` COMMENT 'C:\\path'`, columnComment(Column{SqlType: "text", Comment: `C:\path`}))
+}
+
+func TestClusterByClause(t *testing.T) {
+	require.Equal(t, "", clusterByClause(nil, nil))
+	require.Equal(t, " CLUSTER BY (DATE_TRUNC('MONTH', _timestamp),event_type)",
+		clusterByClause(&PartitioningConfig{Granularity: "month"}, []string{"event_type"}))
+	require.Equal(t, ` CLUSTER BY ("1st")`, clusterByClause(nil, []string{"1st"}))
+}
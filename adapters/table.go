@@ -12,6 +12,8 @@ type TableField struct {
 
 type Column struct {
 	SqlType string
+	//Comment is set as the column comment (description) on column creation if the adapter supports it
+	Comment string
 }
 
 type Table struct {
@@ -5,3 +5,13 @@ type TableManager interface {
 	CreateTable(schemaToCreate *Table) error
 	PatchTableSchema(schemaToAdd *Table) error
 }
+
+//TableDropper is implemented by adapters which can drop tables (e.g. before full re-sync of a source collection)
+type TableDropper interface {
+	DropTable(tableName string) error
+}
+
+//TablesLister is implemented by adapters which can list warehouse tables (without views)
+type TablesLister interface {
+	TablesList() ([]string, error)
+}
@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strings"
+)
+
+//partitioning granularities
+const (
+	HOUR  = "HOUR"
+	DAY   = "DAY"
+	MONTH = "MONTH"
+	YEAR  = "YEAR"
+)
+
+//PartitioningConfig is a table partitioning by event time column
+type PartitioningConfig struct {
+	Field       string `mapstructure:"field" json:"field,omitempty" yaml:"field,omitempty"`
+	Granularity string `mapstructure:"granularity" json:"granularity,omitempty" yaml:"granularity,omitempty"`
+}
+
+//GetField return configured field or _timestamp
+func (pc *PartitioningConfig) GetField() string {
+	if pc.Field == "" {
+		return timestamp.Key
+	}
+
+	return pc.Field
+}
+
+//GetGranularity return configured upper cased granularity or DAY
+func (pc *PartitioningConfig) GetGranularity() string {
+	if pc.Granularity == "" {
+		return DAY
+	}
+
+	return strings.ToUpper(pc.Granularity)
+}
+
+//TableOptions are DDL options which are applied only when tables are created:
+//partitioning by event time and clustering (sort) keys
+type TableOptions struct {
+	Partitioning     *PartitioningConfig
+	ClusteringFields []string
+}
+
+//Validate return err if granularity is unknown or clustering fields are empty
+func (to *TableOptions) Validate() error {
+	if to == nil {
+		return nil
+	}
+
+	if to.Partitioning != nil {
+		switch to.Partitioning.GetGranularity() {
+		case HOUR, DAY, MONTH, YEAR:
+		default:
+			return fmt.Errorf("Unknown partitioning granularity: %s. Available granularities: [%s, %s, %s, %s]", to.Partitioning.Granularity, HOUR, DAY, MONTH, YEAR)
+		}
+	}
+
+	for _, field := range to.ClusteringFields {
+		if field == "" {
+			return errors.New("clustering_fields can't contain empty field")
+		}
+	}
+
+	return nil
+}
+
+//forTable return partitioning field and clustering fields which are present in the table columns
+//fields which are absent in the first table batch are skipped with warning because DDL with unknown columns fails
+func (to *TableOptions) forTable(table *Table) (*PartitioningConfig, []string) {
+	if to == nil {
+		return nil, nil
+	}
+
+	var partitioning *PartitioningConfig
+	if to.Partitioning != nil {
+		if _, ok := table.Columns[to.Partitioning.GetField()]; ok {
+			partitioning = to.Partitioning
+		} else {
+			logging.Warnf("Table [%s] is created without partitioning: partitioning field [%s] is absent in the table columns", table.Name, to.Partitioning.GetField())
+		}
+	}
+
+	var clusteringFields []string
+	for _, field := range to.ClusteringFields {
+		if _, ok := table.Columns[field]; ok {
+			clusteringFields = append(clusteringFields, field)
+		} else {
+			logging.Warnf("Table [%s] clustering field [%s] is skipped: it is absent in the table columns", table.Name, field)
+		}
+	}
+
+	return partitioning, clusteringFields
+}
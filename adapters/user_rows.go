@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//userRowsQueries is a SQL dialect of user rows selection:
+//columnsQuery return (table name, column name) pairs of tables which have any of user columns
+//selectQuery return select of table rows with the condition
+//whereCondition return condition of one user column with the i-th user id placeholder
+type userRowsQueries struct {
+	columnsQuery   func(userColumns []string) (string, []interface{})
+	selectQuery    func(tableName, condition string) string
+	whereCondition func(column string, i int) string
+}
+
+//selectUserRows find tables with user columns and select rows where any of user columns equals userId
+//return rows per table
+func selectUserRows(ctx context.Context, dataSource *sql.DB, queries *userRowsQueries, userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	columnsQuery, columnsArgs := queries.columnsQuery(userColumns)
+	rows, err := dataSource.QueryContext(ctx, columnsQuery, columnsArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying tables with user columns: %v", err)
+	}
+
+	tableColumns := map[string][]string{}
+	for rows.Next() {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+		tableColumns[tableName] = append(tableColumns[tableName], columnName)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	result := map[string][]map[string]interface{}{}
+	for tableName, columns := range tableColumns {
+		sort.Strings(columns)
+
+		var conditions []string
+		var args []interface{}
+		for i, column := range columns {
+			conditions = append(conditions, queries.whereCondition(column, i+1))
+			args = append(args, userId)
+		}
+
+		query := queries.selectQuery(tableName, strings.Join(conditions, " OR "))
+		tableRows, err := selectRows(ctx, dataSource, query, args)
+		if err != nil {
+			return nil, fmt.Errorf("Error selecting user rows from table [%s]: %v", tableName, err)
+		}
+		if len(tableRows) > 0 {
+			result[tableName] = tableRows
+		}
+	}
+
+	return result, nil
+}
+
+//selectRows return query result rows as maps. []byte values are converted into strings
+func selectRows(ctx context.Context, dataSource *sql.DB, query string, args []interface{}) ([]map[string]interface{}, error) {
+	rows, err := dataSource.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return result, nil
+}
+
+//placeholders return comma separated placeholders of count values starting from index from
+func placeholders(count, from int, placeholder func(i int) string) string {
+	var result []string
+	for i := 0; i < count; i++ {
+		result = append(result, placeholder(from+i))
+	}
+	return strings.Join(result, ", ")
+}
+
+func toInterfaces(values []string) []interface{} {
+	var result []interface{}
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package adapters
+
+import (
+	"sort"
+	"strings"
+)
+
+//ViewColumn is a typed view column extracted from raw JSON column by JSON path
+//SqlType might be empty (column type is text)
+type ViewColumn struct {
+	Path    []string
+	Name    string
+	SqlType string
+}
+
+//View is a typed view (or materialized view) over table with raw JSON column (schema-on-read mode)
+type View struct {
+	Name         string
+	Table        string
+	RawColumn    string
+	Columns      []ViewColumn
+	Materialized bool
+}
+
+//ShardsView is a view over all existing shards of a sharded table (UNION ALL of every shard)
+type ShardsView struct {
+	Name   string
+	Shards []*Table
+}
+
+//Columns return union of shards columns. If types differ - the first shard column type is used
+func (sv *ShardsView) Columns() Columns {
+	columns := Columns{}
+	for _, shard := range sv.Shards {
+		for name, column := range shard.Columns {
+			if _, ok := columns[name]; !ok {
+				columns[name] = column
+			}
+		}
+	}
+	return columns
+}
+
+//SelectQuery return UNION ALL of shards SELECT statements with sorted columns of all shards
+//fromExpression return FROM clause table expression of the shard
+//missingColumnExpression return expression of the column which doesn't exist in the shard
+func (sv *ShardsView) SelectQuery(fromExpression func(shard string) string, missingColumnExpression func(column Column) string) string {
+	columns := sv.Columns()
+	var names []string
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var selects []string
+	for _, shard := range sv.Shards {
+		var expressions []string
+		for _, name := range names {
+			if _, ok := shard.Columns[name]; ok {
+				expressions = append(expressions, `"`+name+`"`)
+			} else {
+				expressions = append(expressions, missingColumnExpression(columns[name])+` AS "`+name+`"`)
+			}
+		}
+		selects = append(selects, "SELECT "+strings.Join(expressions, ", ")+" FROM "+fromExpression(shard.Name))
+	}
+
+	return strings.Join(selects, " UNION ALL ")
+}
@@ -1,7 +1,9 @@
 package appconfig
 
 import (
+	"fmt"
 	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/geo"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/useragent"
@@ -17,6 +19,7 @@ type AppConfig struct {
 	GeoResolver          geo.Resolver
 	UaResolver           useragent.Resolver
 	AuthorizationService *authorization.Service
+	Priority             *events.Priority
 	DDLLogsWriter        io.Writer
 	QueryLogsWriter      io.Writer
 
@@ -31,7 +34,8 @@ var (
 	Beta         bool
 )
 
-func setDefaultParams() {
+//SetDefaultParams set default config values (is also used by preflight checks before Init)
+func SetDefaultParams() {
 	viper.SetDefault("server.name", "unnamed-server")
 	viper.SetDefault("server.port", "8001")
 	viper.SetDefault("server.static_files_dir", "./web")
@@ -39,7 +43,11 @@ func setDefaultParams() {
 	viper.SetDefault("server.destinations_reload_sec", 40)
 	viper.SetDefault("server.sync_tasks.pool.size", 500)
 	viper.SetDefault("server.disable_version_reminder", false)
+	viper.SetDefault("server.drain_timeout_sec", 600)
+	viper.SetDefault("server.private_host", "127.0.0.1")
 	viper.SetDefault("server.cache.events.size", 100)
+	viper.SetDefault("server.statistics.uniques.enabled", true)
+	viper.SetDefault("server.statistics.uniques.anonymous_id_node", "/eventn_ctx/user/anonymous_id")
 	viper.SetDefault("geo.maxmind_path", "/home/eventnative/app/res/")
 	viper.SetDefault("log.path", "/home/eventnative/logs/events")
 	viper.SetDefault("log.show_in_server", false)
@@ -50,10 +58,15 @@ func setDefaultParams() {
 	viper.SetDefault("users_recognition.enabled", false)
 	viper.SetDefault("users_recognition.anonymous_id_node", "/eventn_ctx/user/anonymous_id")
 	viper.SetDefault("users_recognition.user_id_node", "/eventn_ctx/user/internal_id")
+	viper.SetDefault("identity_stitching.enabled", false)
+	viper.SetDefault("identity_stitching.anonymous_id_node", "/eventn_ctx/user/anonymous_id")
+	viper.SetDefault("identity_stitching.user_id_node", "/eventn_ctx/user/internal_id")
+	viper.SetDefault("identity_stitching.event_type_node", "/event_type")
+	viper.SetDefault("users_export.columns", []string{"eventn_ctx_user_internal_id", "eventn_ctx_user_anonymous_id", "eventn_ctx_user_email"})
 }
 
 func Init() error {
-	setDefaultParams()
+	SetDefaultParams()
 
 	serverName := viper.GetString("server.name")
 	globalLoggerConfig := logging.Config{
@@ -121,6 +134,18 @@ func Init() error {
 	}
 
 	appConfig.AuthorizationService = authService
+
+	if viper.IsSet("priority") {
+		priorityConfig := &events.PriorityConfig{}
+		if err := viper.UnmarshalKey("priority", priorityConfig); err != nil {
+			return fmt.Errorf("Error parsing priority config: %v", err)
+		}
+		appConfig.Priority = events.NewPriority(priorityConfig, authService.GetAllIdsByToken(priorityConfig.Tokens))
+		if appConfig.Priority != nil {
+			logging.Infof("Configured priority lane for tokens: %v and event types: %v with [%d] stream workers per destination",
+				priorityConfig.Tokens, priorityConfig.EventTypes, appConfig.Priority.StreamWorkers())
+		}
+	}
 	appConfig.GeoResolver = geoResolver
 	appConfig.UaResolver = useragent.NewResolver()
 
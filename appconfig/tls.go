@@ -0,0 +1,188 @@
+package appconfig
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"golang.org/x/crypto/acme/autocert"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAutocertCacheDir = "/home/eventnative/data/autocert"
+	certReloadInterval      = time.Minute
+)
+
+//TLSConfig is a server.tls configuration: either certificate files (reloaded after renewal)
+//or automatic Let's Encrypt certificates for domains. HTTP/2 is enabled by default
+type TLSConfig struct {
+	CertFile     string          `mapstructure:"cert_file" json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile      string          `mapstructure:"key_file" json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	Autocert     *AutocertConfig `mapstructure:"autocert" json:"autocert,omitempty" yaml:"autocert,omitempty"`
+	RedirectPort string          `mapstructure:"redirect_port" json:"redirect_port,omitempty" yaml:"redirect_port,omitempty"`
+	DisableHTTP2 bool            `mapstructure:"disable_http2" json:"disable_http2,omitempty" yaml:"disable_http2,omitempty"`
+}
+
+//AutocertConfig is a Let's Encrypt configuration. Certificates are cached in CacheDir
+type AutocertConfig struct {
+	Domains  []string `mapstructure:"domains" json:"domains,omitempty" yaml:"domains,omitempty"`
+	Email    string   `mapstructure:"email" json:"email,omitempty" yaml:"email,omitempty"`
+	CacheDir string   `mapstructure:"cache_dir" json:"cache_dir,omitempty" yaml:"cache_dir,omitempty"`
+}
+
+func (tc *TLSConfig) Validate() error {
+	if tc.Autocert != nil {
+		if tc.CertFile != "" || tc.KeyFile != "" {
+			return errors.New("only one of cert_file/key_file or autocert must be configured")
+		}
+		if len(tc.Autocert.Domains) == 0 {
+			return errors.New("autocert.domains are required")
+		}
+		return nil
+	}
+
+	if tc.CertFile == "" || tc.KeyFile == "" {
+		return errors.New("cert_file and key_file (or autocert) are required")
+	}
+
+	return nil
+}
+
+//ConfigureTLS set up server TLS configuration (and HTTP/2) and return HTTP server for redirecting to HTTPS
+//(and serving Let's Encrypt HTTP challenges) if redirect_port is configured or nil
+//server must be started with ListenAndServeTLS("", "")
+func ConfigureTLS(server *http.Server, config *TLSConfig) (*http.Server, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error validating server.tls config: %v", err)
+	}
+
+	var redirectHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectToHTTPS(w, r, server.Addr)
+	})
+
+	if config.Autocert != nil {
+		cacheDir := config.Autocert.CacheDir
+		if cacheDir == "" {
+			cacheDir = defaultAutocertCacheDir
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.Autocert.Domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      config.Autocert.Email,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		redirectHandler = manager.HTTPHandler(redirectHandler)
+		logging.Infof("TLS certificates will be issued by Let's Encrypt for domains: %v (cache dir: %s)", config.Autocert.Domains, cacheDir)
+	} else {
+		reloader, err := newCertReloader(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
+		logging.Infof("TLS is configured with certificate: %s", config.CertFile)
+	}
+	server.TLSConfig.MinVersion = tls.VersionTLS12
+
+	if config.DisableHTTP2 {
+		//non-nil empty map disables HTTP/2 in net/http server
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		var nextProtos []string
+		for _, proto := range server.TLSConfig.NextProtos {
+			if proto != "h2" {
+				nextProtos = append(nextProtos, proto)
+			}
+		}
+		server.TLSConfig.NextProtos = nextProtos
+	}
+
+	if config.RedirectPort == "" {
+		return nil, nil
+	}
+
+	return &http.Server{
+		Addr:              "0.0.0.0:" + config.RedirectPort,
+		Handler:           redirectHandler,
+		ReadHeaderTimeout: time.Second * 60,
+		IdleTimeout:       time.Second * 65,
+	}, nil
+}
+
+//redirectToHTTPS write 301 redirect to the same host and HTTPS server port (port is omitted if 443)
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request, httpsAddr string) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(httpsAddr); err == nil && port != "443" && port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+//certReloader keeps certificate and reloads it if files were modified (e.g. renewed by certbot)
+//files are checked not more often than once a minute
+type certReloader struct {
+	sync.Mutex
+
+	certFile string
+	keyFile  string
+
+	certificate *tls.Certificate
+	modTime     time.Time
+	checkedAt   time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (cr *certReloader) load() error {
+	info, err := os.Stat(cr.certFile)
+	if err != nil {
+		return fmt.Errorf("Error reading TLS certificate file: %v", err)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("Error loading TLS certificate: %v", err)
+	}
+
+	cr.certificate = &certificate
+	cr.modTime = info.ModTime()
+	return nil
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.Lock()
+	defer cr.Unlock()
+
+	now := time.Now()
+	if now.Sub(cr.checkedAt) >= certReloadInterval {
+		cr.checkedAt = now
+		if info, err := os.Stat(cr.certFile); err == nil && info.ModTime().After(cr.modTime) {
+			//keep serving the old certificate if the new one can't be loaded (e.g. key file hasn't been written yet)
+			if err := cr.load(); err != nil {
+				logging.SystemErrorf("Error reloading TLS certificate: %v", err)
+			} else {
+				logging.Infof("TLS certificate [%s] has been reloaded", cr.certFile)
+			}
+		}
+	}
+
+	return cr.certificate, nil
+}
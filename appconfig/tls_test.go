@@ -0,0 +1,95 @@
+package appconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestConfigureTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSignedCert(t, dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{
+		Addr: listener.Addr().String(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}),
+	}
+	redirectServer, err := ConfigureTLS(server, &TLSConfig{CertFile: certFile, KeyFile: keyFile, RedirectPort: "8080"})
+	require.NoError(t, err)
+	require.NotNil(t, redirectServer)
+
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + listener.Addr().String() + "/api/v1/event")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0", string(body))
+
+	//redirect handler
+	recorder := httptest.NewRecorder()
+	redirectServer.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com:8080/api/v1/event?token=1", nil))
+	require.Equal(t, http.StatusMovedPermanently, recorder.Code)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	require.Equal(t, "https://example.com:"+port+"/api/v1/event?token=1", recorder.Header().Get("Location"))
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	require.Error(t, (&TLSConfig{}).Validate())
+	require.Error(t, (&TLSConfig{CertFile: "cert.pem"}).Validate())
+	require.Error(t, (&TLSConfig{Autocert: &AutocertConfig{}}).Validate())
+	require.Error(t, (&TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", Autocert: &AutocertConfig{Domains: []string{"a.com"}}}).Validate())
+	require.NoError(t, (&TLSConfig{Autocert: &AutocertConfig{Domains: []string{"a.com"}}}).Validate())
+
+	_, err := ConfigureTLS(&http.Server{}, &TLSConfig{CertFile: "unknown.pem", KeyFile: "unknown.pem"})
+	require.Error(t, err)
+}
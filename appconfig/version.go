@@ -4,7 +4,8 @@ import (
 	"context"
 	"github.com/google/go-github/v32/github"
 	"github.com/jitsucom/eventnative/logging"
-	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/scheduler"
 	"regexp"
 	"strings"
 	"time"
@@ -22,65 +23,61 @@ var VersionRegex = regexp.MustCompile(`v(\d\.\d\d?)[-|\.](beta)?(\d?\d?)`)
 type VersionReminder struct {
 	ctx    context.Context
 	client *github.Client
-
-	closed bool
+	job    *scheduler.Job
 }
 
 func NewVersionReminder(ctx context.Context) *VersionReminder {
 	return &VersionReminder{
 		ctx:    ctx,
 		client: github.NewClient(nil),
-		closed: false,
 	}
 }
 
+//Start schedule checking new releases every 24 hours
 func (vn *VersionReminder) Start() {
-	ticker := time.NewTicker(24 * time.Hour)
-	safego.RunWithRestart(func() {
-		for {
-			if vn.closed {
-				break
-			}
-
-			<-ticker.C
-			releasesList, _, err := vn.client.Repositories.ListReleases(context.Background(), "jitsucom", "eventnative", &github.ListOptions{Page: 0, PerPage: 100})
-			if err != nil {
-				continue
-			}
+	vn.job = scheduler.Add("version_reminder", scheduler.Every(24*time.Hour), func() error {
+		releasesList, _, err := vn.client.Repositories.ListReleases(context.Background(), "jitsucom", "eventnative", &github.ListOptions{Page: 0, PerPage: 100})
+		if err != nil {
+			return err
+		}
 
-			for _, rl := range releasesList {
-				if rl != nil && rl.TagName != nil {
-					//compare beta and stable releases separately
-					if (Beta && !strings.Contains(*rl.TagName, "beta")) ||
-						(!Beta && strings.Contains(*rl.TagName, "beta")) {
-						continue
-					}
+		for _, rl := range releasesList {
+			if rl != nil && rl.TagName != nil {
+				//compare beta and stable releases separately
+				if (Beta && !strings.Contains(*rl.TagName, "beta")) ||
+					(!Beta && strings.Contains(*rl.TagName, "beta")) {
+					continue
+				}
 
-					parsedNewTag := VersionRegex.FindStringSubmatch(*rl.TagName)
-					//malformed
-					if len(parsedNewTag) != 4 {
-						break
-					}
+				parsedNewTag := VersionRegex.FindStringSubmatch(*rl.TagName)
+				//malformed
+				if len(parsedNewTag) != 4 {
+					break
+				}
 
-					if parsedNewTag[1] > MajorVersion || (parsedNewTag[1] > MajorVersion && parsedNewTag[3] > MinorVersion) {
-						//banner format expects version 13 letters for correct formatting (e.g. v1.XX-betaYY)
-						newTagName := parsedNewTag[0]
-						for i := len(newTagName); i < 13; i++ {
-							newTagName += " "
-						}
-						logging.Warnf(logTemplate, newTagName)
+				if parsedNewTag[1] > MajorVersion || (parsedNewTag[1] > MajorVersion && parsedNewTag[3] > MinorVersion) {
+					//banner format expects version 13 letters for correct formatting (e.g. v1.XX-betaYY)
+					newTagName := parsedNewTag[0]
+					for i := len(newTagName); i < 13; i++ {
+						newTagName += " "
 					}
-
-					//only first element in the array (last release version) is compared
-					break
+					logging.Warnf(logTemplate, newTagName)
+					notifications.NewVersion(parsedNewTag[0])
 				}
+
+				//only first element in the array (last release version) is compared
+				break
 			}
 		}
+
+		return nil
 	})
 }
 
 func (vn *VersionReminder) Close() error {
-	vn.closed = true
+	if vn.job != nil {
+		vn.job.Stop()
+	}
 
 	return nil
 }
@@ -4,6 +4,8 @@ var Instance = &AppStatus{Idle: false}
 
 //Singleton struct for storing application status. Some services check this flag
 //and don't perform any actions if Idle = true
+//Draining = true means that the server doesn't accept new events (see drain.Service). It is never reset: draining is one-way
 type AppStatus struct {
-	Idle bool
+	Idle     bool
+	Draining bool
 }
@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/scheduler"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ResultSuccess      = "success"
+	ResultError        = "error"
+	ResultUnauthorized = "unauthorized"
+
+	Redacted = "*****"
+
+	flushEvery = 10 * time.Second
+	//maxBuffered is a max number of entries which are waiting for storing into the destination
+	//(the oldest ones are dropped if the destination is unavailable for a long time)
+	maxBuffered = 10000
+)
+
+//sensitiveKeyParts are parts of parameters and body keys which values are redacted
+var sensitiveKeyParts = []string{"token", "secret", "password", "key", "credentials"}
+
+var instance *Logger
+
+//Config is an audit log configuration (audit section)
+type Config struct {
+	Path        string `mapstructure:"path" json:"path,omitempty" yaml:"path,omitempty"`
+	RotationMin int64  `mapstructure:"rotation_min" json:"rotation_min,omitempty" yaml:"rotation_min,omitempty"`
+	MaxBackups  int    `mapstructure:"max_backups" json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+	//DestinationId is an optional destination where entries are stored as well
+	DestinationId string `mapstructure:"destination_id" json:"destination_id,omitempty" yaml:"destination_id,omitempty"`
+}
+
+//Entry is an admin API call record
+type Entry struct {
+	Timestamp  string                 `json:"timestamp"`
+	Action     string                 `json:"action"`
+	Method     string                 `json:"method"`
+	Path       string                 `json:"path"`
+	CallerIp   string                 `json:"caller_ip"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Body       interface{}            `json:"body,omitempty"`
+	Status     int                    `json:"status"`
+	Result     string                 `json:"result"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+//Logger writes audit entries into the dedicated rotating log file
+//and stores them into the configured destination periodically
+type Logger struct {
+	sync.Mutex
+
+	fileLogger    *logging.AsyncLogger
+	destinationId string
+	storageFunc   func(id string) (events.StorageProxy, bool)
+	buffer        []*Entry
+	job           *scheduler.Job
+}
+
+//Init create audit Logger. Entries are written into <path>/<serverName>-audit.log
+//storageFunc is used for getting the configured destination
+func Init(serverName string, config *Config, storageFunc func(id string) (events.StorageProxy, bool)) *Logger {
+	instance = &Logger{
+		fileLogger: logging.NewAsyncLogger(logging.NewRollingWriter(logging.Config{
+			FileName:    serverName + "-audit",
+			FileDir:     config.Path,
+			RotationMin: config.RotationMin,
+			MaxBackups:  config.MaxBackups}), false),
+		destinationId: config.DestinationId,
+		storageFunc:   storageFunc,
+	}
+	if instance.destinationId != "" {
+		instance.job = scheduler.Add("audit_flush", scheduler.Every(flushEvery), instance.flush)
+	}
+
+	return instance
+}
+
+//Enabled return true if audit log is configured
+func Enabled() bool {
+	return instance != nil
+}
+
+//Record write entry into the audit log if it is configured
+func Record(entry *Entry) {
+	if instance == nil {
+		return
+	}
+
+	instance.record(entry)
+}
+
+func (l *Logger) record(entry *Entry) {
+	l.fileLogger.ConsumeAny(entry)
+
+	if l.destinationId == "" {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.buffer = append(l.buffer, entry)
+	if len(l.buffer) > maxBuffered {
+		l.buffer = l.buffer[len(l.buffer)-maxBuffered:]
+	}
+}
+
+//flush store buffered entries into the destination. Entries are kept in buffer if the destination isn't available
+func (l *Logger) flush() error {
+	l.Lock()
+	entries := l.buffer
+	l.buffer = nil
+	l.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	err := l.store(entries)
+	if err != nil {
+		l.Lock()
+		l.buffer = append(entries, l.buffer...)
+		if len(l.buffer) > maxBuffered {
+			l.buffer = l.buffer[len(l.buffer)-maxBuffered:]
+		}
+		l.Unlock()
+	}
+
+	return err
+}
+
+func (l *Logger) store(entries []*Entry) error {
+	storageProxy, ok := l.storageFunc(l.destinationId)
+	if !ok {
+		return fmt.Errorf("Audit destination [%s] wasn't found", l.destinationId)
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return fmt.Errorf("Audit destination [%s] hasn't been initialized yet", l.destinationId)
+	}
+
+	payload := bytes.Buffer{}
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			logging.Errorf("Error marshaling audit entry: %v", err)
+			continue
+		}
+		payload.Write(b)
+		payload.WriteByte('\n')
+	}
+
+	fileName := fmt.Sprintf("audit-%s.log", time.Now().UTC().Format("2006-01-02T15-04-05.000"))
+	resultPerTable, _, err := storage.Store(fileName, payload.Bytes(), map[string]bool{})
+	if err != nil {
+		return fmt.Errorf("Error storing audit entries into [%s]: %v", l.destinationId, err)
+	}
+	for tableName, result := range resultPerTable {
+		if result.Err != nil {
+			//entries aren't retried: other tables might have been already stored
+			logging.Errorf("Error storing audit entries into [%s] table [%s]: %v", l.destinationId, tableName, result.Err)
+		}
+	}
+
+	return nil
+}
+
+//Close stop flushing, store the rest entries and close the log file
+func (l *Logger) Close() error {
+	if l.job != nil {
+		l.job.Stop()
+		if err := l.flush(); err != nil {
+			logging.Error(err)
+		}
+	}
+
+	return l.fileLogger.Close()
+}
+
+//IsSensitive return true if parameter or body key might contain credentials (identifiers e.g. token_id aren't sensitive)
+func IsSensitive(key string) bool {
+	lowerKey := strings.ToLower(key)
+	if strings.HasSuffix(lowerKey, "_id") {
+		return false
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lowerKey, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Redact return copy of value with sensitive scalar map values replaced with Redacted recursively
+func Redact(value interface{}) interface{} {
+	return redact(value, false)
+}
+
+//redact replace scalar values with Redacted if sensitive (e.g. items of previous_server_secrets array)
+func redact(value interface{}, sensitive bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			switch item.(type) {
+			case map[string]interface{}:
+				//nested objects (e.g. tokens section) are redacted by their keys
+				result[key] = redact(item, false)
+			default:
+				result[key] = redact(item, IsSensitive(key))
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redact(item, sensitive)
+		}
+		return result
+	default:
+		if sensitive {
+			return Redacted
+		}
+		return value
+	}
+}
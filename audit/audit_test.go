@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+//auditStorage collects stored payloads or fails with err
+type auditStorage struct {
+	events.Storage
+	payloads []string
+	err      error
+}
+
+func (as *auditStorage) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	if as.err != nil {
+		return nil, 0, as.err
+	}
+	as.payloads = append(as.payloads, string(payload))
+	return map[string]*events.StoreResult{"audit": {RowsCount: strings.Count(string(payload), "\n")}}, 0, nil
+}
+
+type storageProxyMock struct {
+	storage events.Storage
+}
+
+func (spm *storageProxyMock) Get() (events.Storage, bool) {
+	return spm.storage, spm.storage != nil
+}
+
+func (spm *storageProxyMock) Close() error {
+	return nil
+}
+
+func TestRedact(t *testing.T) {
+	body := map[string]interface{}{
+		"token_id": "token1",
+		"destinations": map[string]interface{}{
+			"pg": map[string]interface{}{
+				"datasource": map[string]interface{}{"host": "localhost", "password": "pwd"},
+				"s3":         map[string]interface{}{"access_key_id": "id", "secret_access_key": "secret"},
+			},
+		},
+		"tokens": []interface{}{map[string]interface{}{"id": "t1", "server_secret": "s1", "previous_server_secrets": []interface{}{"s0"}}},
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"token_id": "token1",
+		"destinations": map[string]interface{}{
+			"pg": map[string]interface{}{
+				"datasource": map[string]interface{}{"host": "localhost", "password": Redacted},
+				"s3":         map[string]interface{}{"access_key_id": "id", "secret_access_key": Redacted},
+			},
+		},
+		"tokens": []interface{}{map[string]interface{}{"id": "t1", "server_secret": Redacted, "previous_server_secrets": []interface{}{Redacted}}},
+	}, Redact(body))
+	require.Equal(t, "pwd", body["destinations"].(map[string]interface{})["pg"].(map[string]interface{})["datasource"].(map[string]interface{})["password"])
+}
+
+func TestFlush(t *testing.T) {
+	storage := &auditStorage{err: errors.New("connection refused")}
+	l := &Logger{
+		fileLogger:    logging.NewAsyncLogger(logging.InitInMemoryWriter(), false),
+		destinationId: "audit_pg",
+		storageFunc: func(id string) (events.StorageProxy, bool) {
+			return &storageProxyMock{storage: storage}, id == "audit_pg"
+		},
+	}
+
+	l.record(&Entry{Action: "POST /api/v1/sources/:id/sync", Status: 200, Result: ResultSuccess})
+	require.EqualError(t, l.flush(), "Error storing audit entries into [audit_pg]: connection refused")
+	require.Len(t, l.buffer, 1)
+
+	storage.err = nil
+	l.record(&Entry{Action: "POST /api/v1/fallback/replay", Status: 401, Result: ResultUnauthorized})
+	require.NoError(t, l.flush())
+	require.Empty(t, l.buffer)
+	require.Len(t, storage.payloads, 1)
+	lines := strings.Split(strings.TrimSpace(storage.payloads[0]), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"action":"POST /api/v1/sources/:id/sync"`)
+	require.Contains(t, lines[1], `"result":"unauthorized"`)
+}
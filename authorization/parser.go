@@ -2,16 +2,230 @@ package authorization
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/resources"
 	"strings"
 )
 
 type Token struct {
-	Id           string   `mapstructure:"id" json:"id,omitempty"`
-	ClientSecret string   `mapstructure:"client_secret" json:"client_secret,omitempty"`
-	ServerSecret string   `mapstructure:"server_secret" json:"server_secret,omitempty"`
-	Origins      []string `mapstructure:"origins" json:"origins,omitempty"`
+	Id           string            `mapstructure:"id" json:"id,omitempty"`
+	ClientSecret string            `mapstructure:"client_secret" json:"client_secret,omitempty"`
+	ServerSecret string            `mapstructure:"server_secret" json:"server_secret,omitempty"`
+	Origins      []string          `mapstructure:"origins" json:"origins,omitempty"`
+	Timestamps   *TimestampsConfig `mapstructure:"timestamps" json:"timestamps,omitempty"`
+	Sampling     *SamplingConfig   `mapstructure:"sampling" json:"sampling,omitempty"`
+	Quota        *QuotaConfig      `mapstructure:"quota" json:"quota,omitempty"`
+	EventSize    *EventSizeConfig  `mapstructure:"event_size" json:"event_size,omitempty"`
+	//Scopes of the token: ingest (events API), read (read-only admin API), admin (all admin API). Default: ingest only
+	Scopes []string `mapstructure:"scopes" json:"scopes,omitempty"`
+	//PreviousServerSecrets are accepted as well as server_secret while rotation: remove them after all clients are updated
+	PreviousServerSecrets []string `mapstructure:"previous_server_secrets" json:"previous_server_secrets,omitempty"`
+	//if signing_secret is set - requests with this token must have valid HMAC signature header
+	SigningSecret      string `mapstructure:"signing_secret" json:"signing_secret,omitempty"`
+	SignatureMaxAgeSec int    `mapstructure:"signature_max_age_sec" json:"signature_max_age_sec,omitempty"`
+}
+
+//HasScope return true if token has the scope. Tokens without scopes have only ingest scope, admin scope includes read scope
+func (t *Token) HasScope(scope string) bool {
+	if len(t.Scopes) == 0 {
+		return scope == IngestScope
+	}
+
+	for _, tokenScope := range t.Scopes {
+		if tokenScope == scope || (tokenScope == AdminScope && scope == ReadScope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//serverSecrets return trimmed server_secret and previous server secrets
+func (t *Token) serverSecrets() []string {
+	var secrets []string
+	for _, secret := range append([]string{t.ServerSecret}, t.PreviousServerSecrets...) {
+		if trimmed := strings.TrimSpace(secret); trimmed != "" {
+			secrets = append(secrets, trimmed)
+		}
+	}
+
+	return secrets
+}
+
+//validateScopes return err if scopes contain unknown values
+func validateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		switch scope {
+		case IngestScope, ReadScope, AdminScope:
+		default:
+			return fmt.Errorf("Unknown scope: %s. Available scopes: [%s, %s, %s]", scope, IngestScope, ReadScope, AdminScope)
+		}
+	}
+
+	return nil
+}
+
+//TimestampsConfig is used for validation of client event timestamps (clock-skewed clients)
+//events with timestamp later than now + max_future_sec or earlier than now - max_past_days
+//are clamped or only flagged according to action
+type TimestampsConfig struct {
+	Field        string `mapstructure:"field" json:"field,omitempty"`
+	MaxFutureSec int    `mapstructure:"max_future_sec" json:"max_future_sec,omitempty"`
+	MaxPastDays  int    `mapstructure:"max_past_days" json:"max_past_days,omitempty"`
+	Action       string `mapstructure:"action" json:"action,omitempty"`
+	FlagField    string `mapstructure:"flag_field" json:"flag_field,omitempty"`
+}
+
+//Validate return err if action is unknown
+func (tc *TimestampsConfig) Validate() error {
+	switch tc.Action {
+	case "", TimestampsClampAction, TimestampsFlagAction:
+	default:
+		return fmt.Errorf("Unknown timestamps action: %s. Available actions: [%s, %s]", tc.Action, TimestampsClampAction, TimestampsFlagAction)
+	}
+
+	return nil
+}
+
+//SamplingConfig is used for keeping only a part of high-frequency events (e.g. 10% of heartbeat events)
+//events are sampled deterministically by anonymous id hash: all events of the same user are kept or dropped together
+type SamplingConfig struct {
+	EventTypeField   string          `mapstructure:"event_type_field" json:"event_type_field,omitempty"`
+	AnonymousIdField string          `mapstructure:"anonymous_id_field" json:"anonymous_id_field,omitempty"`
+	RateField        string          `mapstructure:"rate_field" json:"rate_field,omitempty"`
+	Rules            []*SamplingRule `mapstructure:"rules" json:"rules,omitempty"`
+}
+
+//SamplingRule keeps rate [0, 1] part of events with event_type ('*' means all event types without own rule)
+type SamplingRule struct {
+	EventType string  `mapstructure:"event_type" json:"event_type,omitempty"`
+	Rate      float64 `mapstructure:"rate" json:"rate"`
+}
+
+//Validate return err if rules have wrong rates or empty event types
+func (sc *SamplingConfig) Validate() error {
+	for _, rule := range sc.Rules {
+		if rule == nil {
+			continue
+		}
+		if rule.EventType == "" {
+			return errors.New("sampling rule event_type can't be empty")
+		}
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return fmt.Errorf("sampling rule [%s] rate must be in [0, 1]: %v", rule.EventType, rule.Rate)
+		}
+	}
+
+	return nil
+}
+
+//QuotaConfig is a token events quota (0 - unlimited). Events over quota of the current UTC day or month are rejected
+type QuotaConfig struct {
+	Daily   int `mapstructure:"daily" json:"daily,omitempty"`
+	Monthly int `mapstructure:"monthly" json:"monthly,omitempty"`
+}
+
+//Validate return err if quotas are negative
+func (qc *QuotaConfig) Validate() error {
+	if qc.Daily < 0 {
+		return fmt.Errorf("quota daily can't be negative: %d", qc.Daily)
+	}
+	if qc.Monthly < 0 {
+		return fmt.Errorf("quota monthly can't be negative: %d", qc.Monthly)
+	}
+
+	return nil
+}
+
+const (
+	IngestScope = "ingest"
+	ReadScope   = "read"
+	AdminScope  = "admin"
+)
+
+const (
+	TimestampsClampAction = "clamp"
+	TimestampsFlagAction  = "flag"
+)
+
+const (
+	EventSizeTruncatePolicy = "truncate"
+	EventSizeFallbackPolicy = "fallback"
+)
+
+//EventSizeConfig is used for limiting serialized event size. Oversized events are truncated
+//(the largest fields are dropped) or routed to destinations fallback according to policy
+type EventSizeConfig struct {
+	MaxBytes  int    `mapstructure:"max_bytes" json:"max_bytes,omitempty"`
+	Policy    string `mapstructure:"policy" json:"policy,omitempty"`
+	FlagField string `mapstructure:"flag_field" json:"flag_field,omitempty"`
+}
+
+//Validate return err if max_bytes is negative or policy is unknown
+func (esc *EventSizeConfig) Validate() error {
+	if esc.MaxBytes < 0 {
+		return fmt.Errorf("event_size max_bytes can't be negative: %d", esc.MaxBytes)
+	}
+
+	switch esc.Policy {
+	case "", EventSizeTruncatePolicy, EventSizeFallbackPolicy:
+	default:
+		return fmt.Errorf("Unknown event_size policy: %s. Available policies: [%s, %s]", esc.Policy, EventSizeTruncatePolicy, EventSizeFallbackPolicy)
+	}
+
+	return nil
+}
+
+//ValidateTokens return err if a token doesn't have secrets, ids or secrets are duplicated
+//or scopes, timestamps, sampling, quota and event size configurations are invalid (reformat skips them instead)
+func ValidateTokens(tokens []Token) error {
+	//identity -> token index
+	identities := map[string]int{}
+	for i, token := range tokens {
+		clientSecret := strings.TrimSpace(token.ClientSecret)
+		serverSecret := strings.TrimSpace(token.ServerSecret)
+		if clientSecret == "" && serverSecret == "" {
+			return fmt.Errorf("token [%d] %s: client_secret or server_secret is required", i, token.Id)
+		}
+
+		for _, identity := range append([]string{token.Id, clientSecret}, token.serverSecrets()...) {
+			if identity == "" {
+				continue
+			}
+			if index, ok := identities[identity]; ok && index != i {
+				return fmt.Errorf("token [%d] %s: id or secret [%s] is used by another token", i, token.Id, identity)
+			}
+			identities[identity] = i
+		}
+
+		if err := validateScopes(token.Scopes); err != nil {
+			return fmt.Errorf("token [%d] %s: %v", i, token.Id, err)
+		}
+		if token.Timestamps != nil {
+			if err := token.Timestamps.Validate(); err != nil {
+				return fmt.Errorf("token [%d] %s: %v", i, token.Id, err)
+			}
+		}
+		if token.Sampling != nil {
+			if err := token.Sampling.Validate(); err != nil {
+				return fmt.Errorf("token [%d] %s: %v", i, token.Id, err)
+			}
+		}
+		if token.Quota != nil {
+			if err := token.Quota.Validate(); err != nil {
+				return fmt.Errorf("token [%d] %s: %v", i, token.Id, err)
+			}
+		}
+		if token.EventSize != nil {
+			if err := token.EventSize.Validate(); err != nil {
+				return fmt.Errorf("token [%d] %s: %v", i, token.Id, err)
+			}
+		}
+	}
+
+	return nil
 }
 
 type TokensPayload struct {
@@ -68,19 +282,59 @@ func reformat(tokens []Token) *TokensHolder {
 			tokenObj.Id = resources.GetHash([]byte(tokenObj.ClientSecret + tokenObj.ServerSecret))
 		}
 
+		if err := validateScopes(tokenObj.Scopes); err != nil {
+			//token without valid scopes can't be used neither for ingestion nor for admin API
+			logging.Errorf("Token [%s] will be skipped: %v", tokenObj.Id, err)
+			continue
+		}
+
+		if tokenObj.Timestamps != nil {
+			if err := tokenObj.Timestamps.Validate(); err != nil {
+				logging.Errorf("Token [%s] timestamps will be skipped: %v", tokenObj.Id, err)
+				tokenObj.Timestamps = nil
+			}
+		}
+
+		if tokenObj.Sampling != nil {
+			if err := tokenObj.Sampling.Validate(); err != nil {
+				logging.Errorf("Token [%s] sampling will be skipped: %v", tokenObj.Id, err)
+				tokenObj.Sampling = nil
+			}
+		}
+
+		if tokenObj.Quota != nil {
+			if err := tokenObj.Quota.Validate(); err != nil {
+				logging.Errorf("Token [%s] quota will be skipped: %v", tokenObj.Id, err)
+				tokenObj.Quota = nil
+			}
+		}
+
+		if tokenObj.EventSize != nil {
+			if err := tokenObj.EventSize.Validate(); err != nil {
+				logging.Errorf("Token [%s] event_size will be skipped: %v", tokenObj.Id, err)
+				tokenObj.EventSize = nil
+			}
+		}
+
 		all[tokenObj.Id] = tokenObj
 		ids = append(ids, tokenObj.Id)
 
+		//tokens without ingest scope (e.g. read-only admin tokens) aren't accepted by events API
+		ingest := tokenObj.HasScope(IngestScope)
+
 		trimmedClientToken := strings.TrimSpace(tokenObj.ClientSecret)
 		if trimmedClientToken != "" {
-			clientTokensOrigins[trimmedClientToken] = tokenObj.Origins
+			if ingest {
+				clientTokensOrigins[trimmedClientToken] = tokenObj.Origins
+			}
 			all[trimmedClientToken] = tokenObj
 		}
 
-		trimmedServerToken := strings.TrimSpace(tokenObj.ServerSecret)
-		if trimmedServerToken != "" {
-			serverTokensOrigins[trimmedServerToken] = tokenObj.Origins
-			all[trimmedServerToken] = tokenObj
+		for _, serverSecret := range tokenObj.serverSecrets() {
+			if ingest {
+				serverTokensOrigins[serverSecret] = tokenObj.Origins
+			}
+			all[serverSecret] = tokenObj
 		}
 	}
 
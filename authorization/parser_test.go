@@ -174,3 +174,55 @@ func TestFromStrings(t *testing.T) {
 		})
 	}
 }
+
+func TestScopes(t *testing.T) {
+	tokensHolder := reformat([]Token{
+		{Id: "ingest", ClientSecret: "cl_secret", ServerSecret: "sr_secret"},
+		{Id: "reader", ServerSecret: "read_secret", Scopes: []string{ReadScope}},
+		{Id: "admin", ServerSecret: "new_admin_secret", PreviousServerSecrets: []string{"old_admin_secret"}, Scopes: []string{AdminScope, IngestScope}},
+		{Id: "unknown", ServerSecret: "unknown_secret", Scopes: []string{"superuser"}},
+	})
+	service := &Service{tokensHolder: tokensHolder}
+
+	//ingest only
+	_, ok := service.GetServerOrigins("sr_secret")
+	require.True(t, ok)
+	require.False(t, service.HasServerScope("sr_secret", ReadScope))
+
+	//read-only tokens aren't accepted by events API
+	_, ok = service.GetServerOrigins("read_secret")
+	require.False(t, ok)
+	require.True(t, service.HasServerScope("read_secret", ReadScope))
+	require.False(t, service.HasServerScope("read_secret", AdminScope))
+	require.False(t, service.HasServerScope("reader", ReadScope), "Token id isn't a secret")
+
+	//rotation: both secrets are valid
+	for _, secret := range []string{"new_admin_secret", "old_admin_secret"} {
+		require.True(t, service.HasServerScope(secret, AdminScope))
+		require.True(t, service.HasServerScope(secret, ReadScope))
+		_, ok = service.GetServerOrigins(secret)
+		require.True(t, ok)
+	}
+
+	//tokens with unknown scopes are skipped
+	require.Equal(t, "", service.GetTokenId("unknown_secret"))
+
+	require.EqualError(t, ValidateTokens([]Token{{Id: "t", ServerSecret: "s", Scopes: []string{"superuser"}}}),
+		"token [0] t: Unknown scope: superuser. Available scopes: [ingest, read, admin]")
+	require.EqualError(t, ValidateTokens([]Token{{Id: "t1", ServerSecret: "s1"}, {Id: "t2", ServerSecret: "s2", PreviousServerSecrets: []string{"s1"}}}),
+		"token [1] t2: id or secret [s1] is used by another token")
+}
+
+func TestTimestampsAction(t *testing.T) {
+	tokensHolder := reformat([]Token{
+		{Id: "clamp", ServerSecret: "s1", Timestamps: &TimestampsConfig{MaxFutureSec: 60, Action: TimestampsClampAction}},
+		{Id: "unknown", ServerSecret: "s2", Timestamps: &TimestampsConfig{MaxFutureSec: 60, Action: "drop"}},
+	})
+	require.NotNil(t, tokensHolder.all["clamp"].Timestamps)
+	//unknown action is skipped
+	require.Nil(t, tokensHolder.all["unknown"].Timestamps)
+
+	require.NoError(t, ValidateTokens([]Token{{Id: "t", ServerSecret: "s", Timestamps: &TimestampsConfig{Action: TimestampsFlagAction}}}))
+	require.EqualError(t, ValidateTokens([]Token{{Id: "t", ServerSecret: "s", Timestamps: &TimestampsConfig{Action: "drop"}}}),
+		"token [0] t: Unknown timestamps action: drop. Available actions: [clamp, flag]")
+}
@@ -2,10 +2,12 @@ package authorization
 
 import (
 	"errors"
+	"fmt"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/resources"
 	"github.com/jitsucom/eventnative/uuid"
 	"github.com/spf13/viper"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,14 +17,28 @@ const (
 	serviceName            = "authorization"
 	viperAuthKey           = "server.auth"
 	deprecatedViperAuthKey = "server.s2s_auth"
+	viperTimestampsKey     = "server.timestamps"
+	viperSamplingKey       = "server.sampling"
+	viperQuotaKey          = "server.quota"
+	viperEventSizeKey      = "server.event_size"
 
 	defaultTokenId = "defaultid"
+
+	defaultSignatureMaxAge = 5 * time.Minute
 )
 
 type Service struct {
 	sync.RWMutex
 
 	tokensHolder *TokensHolder
+	//default configuration for tokens without own timestamps configuration
+	defaultTimestamps *TimestampsConfig
+	//default configuration for tokens without own sampling configuration
+	defaultSampling *SamplingConfig
+	//default quota for tokens without own quota
+	defaultQuota *QuotaConfig
+	//default events size limit for tokens without own limit
+	defaultEventSize *EventSizeConfig
 	//will call after every reloading
 	DestinationsForceReload func()
 }
@@ -35,6 +51,47 @@ func NewService() (*Service, error) {
 		return nil, errors.New("server.auth_reload_sec can't be empty")
 	}
 
+	if viper.IsSet(viperTimestampsKey) {
+		timestamps := &TimestampsConfig{}
+		if err := viper.UnmarshalKey(viperTimestampsKey, timestamps); err != nil {
+			return nil, fmt.Errorf("Error parsing %s config: %v", viperTimestampsKey, err)
+		}
+		service.defaultTimestamps = timestamps
+	}
+
+	if viper.IsSet(viperSamplingKey) {
+		sampling := &SamplingConfig{}
+		if err := viper.UnmarshalKey(viperSamplingKey, sampling); err != nil {
+			return nil, fmt.Errorf("Error parsing %s config: %v", viperSamplingKey, err)
+		}
+		if err := sampling.Validate(); err != nil {
+			return nil, fmt.Errorf("Error validating %s config: %v", viperSamplingKey, err)
+		}
+		service.defaultSampling = sampling
+	}
+
+	if viper.IsSet(viperQuotaKey) {
+		quota := &QuotaConfig{}
+		if err := viper.UnmarshalKey(viperQuotaKey, quota); err != nil {
+			return nil, fmt.Errorf("Error parsing %s config: %v", viperQuotaKey, err)
+		}
+		if err := quota.Validate(); err != nil {
+			return nil, fmt.Errorf("Error validating %s config: %v", viperQuotaKey, err)
+		}
+		service.defaultQuota = quota
+	}
+
+	if viper.IsSet(viperEventSizeKey) {
+		eventSize := &EventSizeConfig{}
+		if err := viper.UnmarshalKey(viperEventSizeKey, eventSize); err != nil {
+			return nil, fmt.Errorf("Error parsing %s config: %v", viperEventSizeKey, err)
+		}
+		if err := eventSize.Validate(); err != nil {
+			return nil, fmt.Errorf("Error validating %s config: %v", viperEventSizeKey, err)
+		}
+		service.defaultEventSize = eventSize
+	}
+
 	//deprecated viper key
 	deprecatedS2SAuth := viper.GetStringSlice(deprecatedViperAuthKey)
 
@@ -106,6 +163,26 @@ func (s *Service) GetServerOrigins(serverSecret string) ([]string, bool) {
 	return origins, ok
 }
 
+//HasServerScope return true if serverSecret (or a previous server secret) belongs to a token with the scope
+//client secrets are public (used in browsers) and never grant admin API access
+func (s *Service) HasServerScope(serverSecret, scope string) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[serverSecret]
+	if !ok {
+		return false
+	}
+
+	for _, secret := range token.serverSecrets() {
+		if secret == serverSecret {
+			return token.HasScope(scope)
+		}
+	}
+
+	return false
+}
+
 //GetAllTokenIds return all token ids
 func (s *Service) GetAllTokenIds() []string {
 	s.RLock()
@@ -114,6 +191,14 @@ func (s *Service) GetAllTokenIds() []string {
 	return s.tokensHolder.ids
 }
 
+//IsGenerated return true if tokens weren't configured (or loaded) and the default one has been generated
+func (s *Service) IsGenerated() bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return len(s.tokensHolder.ids) == 1 && s.tokensHolder.ids[0] == defaultTokenId
+}
+
 //GetAllIdsByToken return token ids by token identity(client_secret/server_secret/token id)
 func (s *Service) GetAllIdsByToken(tokenIdentity []string) (ids []string) {
 	s.RLock()
@@ -146,6 +231,103 @@ func (s *Service) GetTokenId(tokenFilter string) string {
 	return ""
 }
 
+//GetTimestampsConfig return token timestamps configuration or default one
+//return nil if timestamps validation isn't configured
+func (s *Service) GetTimestampsConfig(tokenId string) *TimestampsConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	if ok && token.Timestamps != nil {
+		return token.Timestamps
+	}
+
+	return s.defaultTimestamps
+}
+
+//GetSamplingConfig return token sampling configuration or default one
+//return nil if sampling isn't configured
+func (s *Service) GetSamplingConfig(tokenId string) *SamplingConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	if ok && token.Sampling != nil {
+		return token.Sampling
+	}
+
+	return s.defaultSampling
+}
+
+//GetQuotaConfig return token quota configuration or default one
+//return nil if quotas aren't configured
+func (s *Service) GetQuotaConfig(tokenId string) *QuotaConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	if ok && token.Quota != nil {
+		return token.Quota
+	}
+
+	return s.defaultQuota
+}
+
+//GetEventSizeConfig return token event size configuration or default one
+//return nil if event size isn't limited
+func (s *Service) GetEventSizeConfig(tokenId string) *EventSizeConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenId]
+	if ok && token.EventSize != nil {
+		return token.EventSize
+	}
+
+	return s.defaultEventSize
+}
+
+//GetSigningSecret return token signing secret and max signature age (replay window)
+//return empty secret if requests with the token shouldn't be signed
+func (s *Service) GetSigningSecret(tokenFilter string) (string, time.Duration) {
+	s.RLock()
+	defer s.RUnlock()
+
+	token, ok := s.tokensHolder.all[tokenFilter]
+	if !ok || token.SigningSecret == "" {
+		return "", 0
+	}
+
+	if token.SignatureMaxAgeSec > 0 {
+		return token.SigningSecret, time.Duration(token.SignatureMaxAgeSec) * time.Second
+	}
+
+	return token.SigningSecret, defaultSignatureMaxAge
+}
+
+//GetTokens return all tokens ordered by id
+func (s *Service) GetTokens() []Token {
+	s.RLock()
+	defer s.RUnlock()
+
+	tokens := make([]Token, 0, len(s.tokensHolder.ids))
+	for _, id := range s.tokensHolder.ids {
+		tokens = append(tokens, s.tokensHolder.all[id])
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Id < tokens[j].Id })
+
+	return tokens
+}
+
+//SetTokens replace all tokens (e.g. with declarative state tokens). Destinations aren't reloaded
+func (s *Service) SetTokens(tokens []Token) {
+	tokensHolder := reformat(tokens)
+
+	s.Lock()
+	s.tokensHolder = tokensHolder
+	s.Unlock()
+}
+
 //parse and set tokensHolder with lock
 func (s *Service) updateTokens(payload []byte) {
 	tokenHolder, err := parseFromBytes(payload)
@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotFilePrefix = "meta_snapshot_"
+	snapshotFileSuffix = ".json"
+	snapshotTimeLayout = "20060102T150405"
+
+	defaultIntervalMin = 60
+	defaultKeep        = 24
+)
+
+//DefaultPatterns are critical meta storage keys which can't be recalculated after data loss:
+//sources sync state (signatures, statuses), deduplication windows, destinations modes, feature flags and identities
+var DefaultPatterns = []string{"source#*", "dedup:*", "destination_modes", "feature_flags", "identities:*"}
+
+//Config is a meta.backup configuration: snapshots are uploaded either into S3 (into folder if configured) or into Google Cloud Storage
+type Config struct {
+	IntervalMin int                    `mapstructure:"interval_min" json:"interval_min,omitempty" yaml:"interval_min,omitempty"`
+	Keep        int                    `mapstructure:"keep" json:"keep,omitempty" yaml:"keep,omitempty"`
+	Patterns    []string               `mapstructure:"patterns" json:"patterns,omitempty" yaml:"patterns,omitempty"`
+	S3          *adapters.S3Config     `mapstructure:"s3" json:"s3,omitempty" yaml:"s3,omitempty"`
+	Google      *adapters.GoogleConfig `mapstructure:"google" json:"google,omitempty" yaml:"google,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.S3 == nil && c.Google == nil {
+		return errors.New("s3 or google configuration is required")
+	}
+	if c.S3 != nil && c.Google != nil {
+		return errors.New("only one of s3 or google configurations must be set")
+	}
+	if c.IntervalMin < 0 {
+		return errors.New("interval_min can't be negative")
+	}
+	if c.Keep < 0 {
+		return errors.New("keep can't be negative")
+	}
+
+	if c.S3 != nil {
+		return c.S3.Validate()
+	}
+	return c.Google.Validate(false)
+}
+
+//Service periodically uploads meta storage snapshot into object storage and removes old snapshots
+//Snapshots are restored with: eventnative meta import -file meta_snapshot_yyyymmddThhmmss.json
+type Service struct {
+	metaStorage meta.Storage
+	stage       adapters.Stage
+	patterns    []string
+	interval    time.Duration
+	keep        int
+
+	job *scheduler.Job
+}
+
+//NewService create object storage client and schedule snapshots uploading
+func NewService(ctx context.Context, metaStorage meta.Storage, config *Config) (*Service, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error validating meta.backup config: %v", err)
+	}
+	if metaStorage.Type() == meta.DummyType {
+		return nil, errors.New("meta.backup requires meta storage configuration")
+	}
+
+	var stage adapters.Stage
+	var err error
+	if config.S3 != nil {
+		stage, err = adapters.NewS3(config.S3)
+	} else {
+		stage, err = adapters.NewGoogleCloudStorage(ctx, config.Google)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	service := newService(metaStorage, stage, config)
+	service.job = scheduler.Add("meta_backup", scheduler.Every(service.interval), service.run)
+	logging.Infof("Meta storage snapshots will be uploaded every [%s] (keep last %d)", service.interval, service.keep)
+	return service, nil
+}
+
+func newService(metaStorage meta.Storage, stage adapters.Stage, config *Config) *Service {
+	intervalMin := config.IntervalMin
+	if intervalMin == 0 {
+		intervalMin = defaultIntervalMin
+	}
+	keep := config.Keep
+	if keep == 0 {
+		keep = defaultKeep
+	}
+	patterns := config.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultPatterns
+	}
+
+	return &Service{
+		metaStorage: metaStorage,
+		stage:       stage,
+		patterns:    patterns,
+		interval:    time.Duration(intervalMin) * time.Minute,
+		keep:        keep,
+	}
+}
+
+//run upload snapshot if there isn't a fresh one (uploaded by another cluster node) and remove old snapshots
+func (s *Service) run() error {
+	now := time.Now().UTC()
+	snapshots, err := s.listSnapshots()
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		if last, ok := parseSnapshotTime(snapshots[len(snapshots)-1]); ok && now.Sub(last) < s.interval/2 {
+			return nil
+		}
+	}
+
+	snapshot, err := s.metaStorage.Export(s.patterns)
+	if err != nil {
+		return fmt.Errorf("Error exporting meta storage: %v", err)
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("Error marshalling meta snapshot: %v", err)
+	}
+
+	fileName := snapshotFilePrefix + now.Format(snapshotTimeLayout) + snapshotFileSuffix
+	if err := s.stage.UploadBytes(fileName, b); err != nil {
+		return fmt.Errorf("Error uploading meta snapshot [%s]: %v", fileName, err)
+	}
+	logging.Infof("Meta storage snapshot [%s] has been uploaded: %d entries", fileName, len(snapshot.Entries))
+
+	snapshots = append(snapshots, fileName)
+	for i := 0; i < len(snapshots)-s.keep; i++ {
+		if err := s.stage.DeleteObject(snapshots[i]); err != nil {
+			logging.Errorf("Error deleting old meta snapshot [%s]: %v", snapshots[i], err)
+		}
+	}
+
+	return nil
+}
+
+//listSnapshots return snapshots file names (without S3 folder) sorted from the oldest to the newest
+func (s *Service) listSnapshots() ([]string, error) {
+	keys, err := s.stage.ListBucket(snapshotFilePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing meta snapshots: %v", err)
+	}
+
+	var snapshots []string
+	for _, key := range keys {
+		//S3 returns keys with the configured folder which is added by adapter on deletion
+		name := key[strings.LastIndex(key, "/")+1:]
+		if _, ok := parseSnapshotTime(name); ok {
+			snapshots = append(snapshots, name)
+		}
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+func (s *Service) Close() error {
+	if s.job != nil {
+		s.job.Stop()
+	}
+
+	return s.stage.Close()
+}
+
+func parseSnapshotTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, snapshotFilePrefix) || !strings.HasSuffix(name, snapshotFileSuffix) {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(snapshotTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, snapshotFilePrefix), snapshotFileSuffix))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
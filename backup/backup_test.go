@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type exportStorage struct {
+	meta.Dummy
+	patterns []string
+}
+
+func (es *exportStorage) Export(patterns []string) (*meta.Snapshot, error) {
+	es.patterns = patterns
+	return &meta.Snapshot{Version: meta.SnapshotVersion, Source: meta.RedisType, Entries: []*meta.SnapshotEntry{
+		{Key: "feature_flags", Type: meta.HashEntry, Hash: map[string]string{"new_dedup": `{"enabled":true}`}},
+	}}, nil
+}
+
+func (es *exportStorage) Type() string {
+	return meta.RedisType
+}
+
+//memoryStage emulates S3 folder: keys are listed with folder
+type memoryStage struct {
+	folder  string
+	objects map[string][]byte
+}
+
+func (ms *memoryStage) UploadBytes(fileName string, fileBytes []byte) error {
+	ms.objects[ms.folder+fileName] = fileBytes
+	return nil
+}
+
+func (ms *memoryStage) ListBucket(prefix string) ([]string, error) {
+	var keys []string
+	for key := range ms.objects {
+		if strings.HasPrefix(key, ms.folder+prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (ms *memoryStage) GetObject(name string) ([]byte, error) {
+	return ms.objects[ms.folder+name], nil
+}
+
+func (ms *memoryStage) DeleteObject(key string) error {
+	delete(ms.objects, ms.folder+key)
+	return nil
+}
+
+func (ms *memoryStage) Close() error {
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	require.Error(t, (&Config{}).Validate())
+	require.Error(t, (&Config{S3: &adapters.S3Config{}, Google: &adapters.GoogleConfig{}}).Validate())
+	require.Error(t, (&Config{S3: &adapters.S3Config{AccessKeyID: "id", SecretKey: "key", Bucket: "b", Region: "r"}, IntervalMin: -1}).Validate())
+	require.NoError(t, (&Config{S3: &adapters.S3Config{AccessKeyID: "id", SecretKey: "key", Bucket: "b", Region: "r"}}).Validate())
+}
+
+func TestRun(t *testing.T) {
+	storage := &exportStorage{}
+	stage := &memoryStage{folder: "backups/", objects: map[string][]byte{
+		"backups/meta_snapshot_20200101T000000.json": []byte("{}"),
+		"backups/meta_snapshot_20200102T000000.json": []byte("{}"),
+		"backups/other.json":                         []byte("{}"),
+	}}
+	service := newService(storage, stage, &Config{Keep: 2})
+	require.Equal(t, DefaultPatterns, service.patterns)
+	require.Equal(t, time.Hour, service.interval)
+
+	require.NoError(t, service.run())
+	require.Equal(t, DefaultPatterns, storage.patterns)
+	require.Len(t, stage.objects, 3)
+	require.Contains(t, stage.objects, "backups/meta_snapshot_20200102T000000.json")
+	require.Contains(t, stage.objects, "backups/other.json")
+
+	snapshots, err := service.listSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	snapshot := &meta.Snapshot{}
+	require.NoError(t, json.Unmarshal(stage.objects["backups/"+snapshots[1]], snapshot))
+	require.Len(t, snapshot.Entries, 1)
+
+	//fresh snapshot exists (e.g. uploaded by another node)
+	storage.patterns = nil
+	require.NoError(t, service.run())
+	require.Nil(t, storage.patterns)
+}
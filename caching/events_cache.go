@@ -7,6 +7,7 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/safego"
+	"sync"
 	"time"
 )
 
@@ -17,6 +18,13 @@ type EventsCache struct {
 	failedCh               chan *failedEvent
 	capacityPerDestination int
 
+	//current config hash per destination id
+	configHashes sync.Map
+	//live events subscriptions
+	tail *tail
+	//nil if events cache isn't token scoped
+	tokensRetention *tokensRetention
+
 	closed bool
 }
 
@@ -28,6 +36,7 @@ func NewEventsCache(storage meta.Storage, capacityPerDestination int) *EventsCac
 		succeedCh:              make(chan *succeedEvent, 1000000),
 		failedCh:               make(chan *failedEvent, 1000000),
 		capacityPerDestination: capacityPerDestination,
+		tail:                   newTail(),
 	}
 	c.start()
 	return c
@@ -42,7 +51,7 @@ func (ec *EventsCache) start() {
 			}
 
 			cf := <-ec.originalCh
-			ec.put(cf.destinationId, cf.eventId, cf.event)
+			ec.put(cf.tokenId, cf.destinationId, cf.eventId, cf.event)
 		}
 	})
 
@@ -64,21 +73,33 @@ func (ec *EventsCache) start() {
 			}
 
 			cf := <-ec.failedCh
-			ec.error(cf.destinationId, cf.eventId, cf.error)
+			if cf.rejected != nil {
+				ec.reject(cf.destinationId, cf.eventId, cf.error, cf.errorType, cf.rejected, cf.table)
+			} else {
+				ec.error(cf.destinationId, cf.eventId, cf.error)
+			}
 		}
 	})
 }
 
 //Put put value into channel which will be read and written to storage
-func (ec *EventsCache) Put(destinationId, eventId string, value events.Event) {
+func (ec *EventsCache) Put(tokenId, destinationId, eventId string, value events.Event) {
+	if ec.tail.active() {
+		ec.tail.publish(&TailEvent{Status: StatusPending, TokenId: tokenId, DestinationId: destinationId, EventId: eventId, Timestamp: time.Now().UTC(), Event: value})
+	}
+
 	select {
-	case ec.originalCh <- &originalEvent{destinationId: destinationId, eventId: eventId, event: value}:
+	case ec.originalCh <- &originalEvent{tokenId: tokenId, destinationId: destinationId, eventId: eventId, event: value}:
 	default:
 	}
 }
 
 //Succeed put value into channel which will be read and updated in storage
 func (ec *EventsCache) Succeed(destinationId, eventId string, processed events.Event, table *adapters.Table) {
+	if ec.tail.active() {
+		ec.tail.publish(&TailEvent{Status: StatusSuccess, DestinationId: destinationId, EventId: eventId, Timestamp: time.Now().UTC(), Event: processed, Table: table.Name})
+	}
+
 	select {
 	case ec.succeedCh <- &succeedEvent{destinationId: destinationId, eventId: eventId, processed: processed, table: table}:
 	default:
@@ -87,14 +108,31 @@ func (ec *EventsCache) Succeed(destinationId, eventId string, processed events.E
 
 //Error put value into channel which will be read and updated in storage
 func (ec *EventsCache) Error(destinationId, eventId string, errMsg string) {
+	if ec.tail.active() {
+		ec.tail.publish(&TailEvent{Status: StatusError, DestinationId: destinationId, EventId: eventId, Timestamp: time.Now().UTC(), Error: errMsg})
+	}
+
 	select {
 	case ec.failedCh <- &failedEvent{destinationId: destinationId, eventId: eventId, error: errMsg}:
 	default:
 	}
 }
 
+//Reject put value into channel which will be read and updated in storage
+//rejected is the processed event exactly as it was sent to the destination table
+func (ec *EventsCache) Reject(destinationId, eventId, errMsg, errorType string, rejected events.Event, table *adapters.Table) {
+	if ec.tail.active() {
+		ec.tail.publish(&TailEvent{Status: StatusError, DestinationId: destinationId, EventId: eventId, Timestamp: time.Now().UTC(), Event: rejected, Table: table.Name, Error: errMsg})
+	}
+
+	select {
+	case ec.failedCh <- &failedEvent{destinationId: destinationId, eventId: eventId, error: errMsg, errorType: errorType, rejected: rejected, table: table}:
+	default:
+	}
+}
+
 //put create new event in storage
-func (ec *EventsCache) put(destinationId, eventId string, value events.Event) {
+func (ec *EventsCache) put(tokenId, destinationId, eventId string, value events.Event) {
 	if eventId == "" {
 		logging.SystemErrorf("[EventsCache] Put(): Event id can't be empty. Destination [%s] Event: %s", destinationId, value.Serialize())
 		return
@@ -106,12 +144,18 @@ func (ec *EventsCache) put(destinationId, eventId string, value events.Event) {
 		return
 	}
 
-	eventsInCache, err := ec.storage.AddEvent(destinationId, eventId, string(b), time.Now().UTC())
+	eventsInCache, err := ec.storage.AddEvent(destinationId, eventId, tokenId, string(b), time.Now().UTC())
 	if err != nil {
 		logging.SystemErrorf("[%s] Error saving event %v in cache: %v", destinationId, value.Serialize(), err)
 		return
 	}
 
+	//token scoped capacity: noisy tokens don't evict events of other tokens
+	if ec.tokensRetention != nil && tokenId != "" {
+		ec.putTokenScoped(tokenId, destinationId)
+		return
+	}
+
 	//delete old if overflow
 	if eventsInCache > ec.capacityPerDestination {
 		toDelete := eventsInCache - ec.capacityPerDestination
@@ -177,13 +221,79 @@ func (ec *EventsCache) error(destinationId, eventId string, errMsg string) {
 		return
 	}
 
-	err := ec.storage.UpdateErrorEvent(destinationId, eventId, errMsg)
+	err := ec.storage.UpdateErrorEvent(destinationId, eventId, errMsg, ec.GetCurrentConfigHash(destinationId))
 	if err != nil {
 		logging.SystemErrorf("[%s] Error updating error event [%s] in cache: %v", destinationId, eventId, err)
 		return
 	}
 }
 
+//reject write error and serialized rejected payload into event fields in storage
+//rejected payload fields which aren't in the table schema are written without type
+func (ec *EventsCache) reject(destinationId, eventId, errMsg, errorType string, rejected events.Event, table *adapters.Table) {
+	if eventId == "" {
+		logging.SystemErrorf("[EventsCache] Reject(): Event id can't be empty. Destination [%s]", destinationId)
+		return
+	}
+
+	fields := []*adapters.TableField{}
+	for name, value := range rejected {
+		fields = append(fields, &adapters.TableField{
+			Field: name,
+			Type:  table.Columns[name].SqlType,
+			Value: value,
+		})
+	}
+
+	re := RejectedEvent{
+		DestinationId: destinationId,
+		Table:         table.Name,
+		ErrorType:     errorType,
+		Record:        fields,
+	}
+
+	b, err := json.Marshal(re)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error marshalling rejected event [%v] before update: %v", destinationId, re, err)
+		return
+	}
+
+	err = ec.storage.UpdateRejectedEvent(destinationId, eventId, errMsg, string(b), ec.GetCurrentConfigHash(destinationId))
+	if err != nil {
+		logging.SystemErrorf("[%s] Error updating rejected event [%s] in cache: %v", destinationId, eventId, err)
+		return
+	}
+}
+
+//SetConfigSnapshot save destination configuration snapshot in storage and use hash as current config hash
+//of the destination: the hash is written into all further error events
+func (ec *EventsCache) SetConfigSnapshot(destinationId, hash string, snapshot []byte) {
+	if err := ec.storage.SaveConfigSnapshot(destinationId, hash, string(snapshot)); err != nil {
+		logging.SystemErrorf("[%s] Error saving destination config snapshot: %v", destinationId, err)
+	}
+
+	ec.configHashes.Store(destinationId, hash)
+}
+
+//GetCurrentConfigHash return current destination config hash or empty string
+func (ec *EventsCache) GetCurrentConfigHash(destinationId string) string {
+	if value, ok := ec.configHashes.Load(destinationId); ok {
+		return value.(string)
+	}
+
+	return ""
+}
+
+//GetConfigSnapshot return destination configuration snapshot json by hash or empty string if it doesn't exist
+func (ec *EventsCache) GetConfigSnapshot(destinationId, hash string) (string, error) {
+	return ec.storage.GetConfigSnapshot(destinationId, hash)
+}
+
+//GetEvent return cached event or nil if it doesn't exist
+func (ec *EventsCache) GetEvent(destinationId, eventId string) (*meta.Event, error) {
+	return ec.storage.GetEvent(destinationId, eventId)
+}
+
 //GetN return at most n facts by key
 func (ec *EventsCache) GetN(destinationId string, start, end time.Time, n int) []meta.Event {
 	facts, err := ec.storage.GetEvents(destinationId, start, end, n)
@@ -208,5 +318,8 @@ func (ec *EventsCache) GetTotal(destinationId string) int {
 
 func (ec *EventsCache) Close() error {
 	ec.closed = true
+	if ec.tokensRetention != nil {
+		ec.tokensRetention.close()
+	}
 	return nil
 }
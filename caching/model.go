@@ -12,8 +12,17 @@ type SucceedEvent struct {
 	Record        []*adapters.TableField `json:"record,omitempty"`
 }
 
+//entity
+type RejectedEvent struct {
+	DestinationId string                 `json:"destination_id,omitempty"`
+	Table         string                 `json:"table,omitempty"`
+	ErrorType     string                 `json:"error_type,omitempty"`
+	Record        []*adapters.TableField `json:"record,omitempty"`
+}
+
 //channel dto
 type originalEvent struct {
+	tokenId       string
 	destinationId string
 	eventId       string
 	event         events.Event
@@ -29,9 +38,13 @@ type succeedEvent struct {
 }
 
 //channel dto
+//rejected and table are set if the destination rejected the processed event
 type failedEvent struct {
 	destinationId string
 	eventId       string
 
-	error string
+	error     string
+	errorType string
+	rejected  events.Event
+	table     *adapters.Table
 }
@@ -0,0 +1,199 @@
+package caching
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/meta"
+	"sort"
+	"time"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusPending = "pending"
+
+	//how many index items are read from storage at once while filtering
+	indexBatchSize = 100
+)
+
+//EventsFilter is a query of cached events
+//Cursor is an opaque value from previous EventsPage.NextCursor
+type EventsFilter struct {
+	DestinationIds []string
+	TokenId        string
+	Status         string
+	Start          time.Time
+	End            time.Time
+	Cursor         string
+	Limit          int
+}
+
+//QueriedEvent is a cached event with its identifiers
+type QueriedEvent struct {
+	meta.Event
+	DestinationId string
+	EventId       string
+	Timestamp     time.Time
+}
+
+//EventsPage is a result of events query
+//NextCursor is empty if there are no more events
+type EventsPage struct {
+	Events     []*QueriedEvent
+	NextCursor string
+}
+
+//cursor is a position (last returned event) per destination
+type cursor map[string]meta.EventIndex
+
+//Query return page of cached events which match filter ordered by caching time
+//events are merged from all filter destinations
+func (ec *EventsCache) Query(filter *EventsFilter) (*EventsPage, error) {
+	if filter.Status != "" && filter.Status != StatusSuccess && filter.Status != StatusError && filter.Status != StatusPending {
+		return nil, fmt.Errorf("Unknown status [%s]. Available values: [%s, %s, %s]", filter.Status, StatusSuccess, StatusError, StatusPending)
+	}
+	if filter.Limit <= 0 {
+		return nil, fmt.Errorf("Limit must be positive")
+	}
+
+	position, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*QueriedEvent
+	hasMore := map[string]bool{}
+	for _, destinationId := range filter.DestinationIds {
+		destinationEvents, more, err := ec.queryDestination(destinationId, filter, position[destinationId])
+		if err != nil {
+			return nil, fmt.Errorf("Error querying [%s] destination cached events: %v", destinationId, err)
+		}
+		candidates = append(candidates, destinationEvents...)
+		hasMore[destinationId] = more
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Timestamp.Before(candidates[j].Timestamp)
+	})
+
+	more := false
+	if len(candidates) > filter.Limit {
+		candidates = candidates[:filter.Limit]
+		more = true
+	}
+
+	next := cursor{}
+	for destinationId, index := range position {
+		next[destinationId] = index
+	}
+	for _, event := range candidates {
+		next[event.DestinationId] = meta.EventIndex{EventId: event.EventId, Timestamp: event.Timestamp.Unix()}
+	}
+	for _, destinationMore := range hasMore {
+		more = more || destinationMore
+	}
+
+	page := &EventsPage{Events: candidates}
+	if more {
+		page.NextCursor, err = encodeCursor(next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+//queryDestination return at most filter.Limit events which match filter after position
+//and flag if there might be more events
+func (ec *EventsCache) queryDestination(destinationId string, filter *EventsFilter, position meta.EventIndex) ([]*QueriedEvent, bool, error) {
+	start := filter.Start
+	if position.EventId != "" && position.Timestamp > start.Unix() {
+		start = time.Unix(position.Timestamp, 0).UTC()
+	}
+
+	var result []*QueriedEvent
+	offset := 0
+	for {
+		index, err := ec.storage.GetEventsIndex(destinationId, start, filter.End, offset, indexBatchSize)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, item := range index {
+			//skip already returned events with the same timestamp
+			if position.EventId != "" && (item.Timestamp < position.Timestamp || (item.Timestamp == position.Timestamp && item.EventId <= position.EventId)) {
+				continue
+			}
+
+			event, err := ec.storage.GetEvent(destinationId, item.EventId)
+			if err != nil {
+				return nil, false, err
+			}
+			//was removed from cache
+			if event == nil || !matches(event, filter) {
+				continue
+			}
+
+			if len(result) == filter.Limit {
+				return result, true, nil
+			}
+			result = append(result, &QueriedEvent{
+				Event:         *event,
+				DestinationId: destinationId,
+				EventId:       item.EventId,
+				Timestamp:     time.Unix(item.Timestamp, 0).UTC(),
+			})
+		}
+
+		if len(index) < indexBatchSize {
+			return result, false, nil
+		}
+		offset += len(index)
+	}
+}
+
+func matches(event *meta.Event, filter *EventsFilter) bool {
+	if filter.TokenId != "" && event.TokenId != filter.TokenId {
+		return false
+	}
+
+	switch filter.Status {
+	case StatusSuccess:
+		return event.Success != ""
+	case StatusError:
+		return event.Error != ""
+	case StatusPending:
+		return event.Success == "" && event.Error == ""
+	}
+
+	return true
+}
+
+func decodeCursor(value string) (cursor, error) {
+	c := cursor{}
+	if value == "" {
+		return c, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed cursor: %v", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("Malformed cursor: %v", err)
+	}
+
+	return c, nil
+}
+
+func encodeCursor(c cursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("Error serializing cursor: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
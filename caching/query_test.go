@@ -0,0 +1,98 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+//storage with in-memory events cache
+type testStorage struct {
+	meta.Dummy
+	index  map[string][]meta.EventIndex
+	events map[string]*meta.Event
+}
+
+func (ts *testStorage) add(destinationId, eventId string, timestamp int64, event *meta.Event) {
+	ts.index[destinationId] = append(ts.index[destinationId], meta.EventIndex{EventId: eventId, Timestamp: timestamp})
+	sort.Slice(ts.index[destinationId], func(i, j int) bool {
+		a, b := ts.index[destinationId][i], ts.index[destinationId][j]
+		return a.Timestamp < b.Timestamp || (a.Timestamp == b.Timestamp && a.EventId < b.EventId)
+	})
+	ts.events[destinationId+eventId] = event
+}
+
+func (ts *testStorage) GetEventsIndex(destinationId string, start, end time.Time, offset, n int) ([]meta.EventIndex, error) {
+	var result []meta.EventIndex
+	for _, item := range ts.index[destinationId] {
+		if item.Timestamp >= start.Unix() && item.Timestamp <= end.Unix() {
+			result = append(result, item)
+		}
+	}
+	if offset >= len(result) {
+		return nil, nil
+	}
+	result = result[offset:]
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
+
+func (ts *testStorage) GetEvent(destinationId, eventId string) (*meta.Event, error) {
+	return ts.events[destinationId+eventId], nil
+}
+
+func TestQuery(t *testing.T) {
+	storage := &testStorage{index: map[string][]meta.EventIndex{}, events: map[string]*meta.Event{}}
+	storage.add("d1", "e1", 100, &meta.Event{TokenId: "t1", Original: "1", Success: "ok"})
+	storage.add("d1", "e3", 101, &meta.Event{TokenId: "t2", Original: "3", Error: "err"})
+	storage.add("d1", "e2", 101, &meta.Event{TokenId: "t1", Original: "2"})
+	storage.add("d2", "e4", 100, &meta.Event{TokenId: "t1", Original: "4", Error: "err"})
+	storage.add("d2", "e5", 200, &meta.Event{TokenId: "t1", Original: "5", Success: "ok"})
+
+	ec := &EventsCache{storage: storage}
+	end := time.Unix(1000, 0)
+
+	//pagination across destinations
+	var originals []string
+	filter := &EventsFilter{DestinationIds: []string{"d1", "d2"}, End: end, Limit: 2}
+	for i := 0; i < 10; i++ {
+		page, err := ec.Query(filter)
+		require.NoError(t, err)
+		require.True(t, len(page.Events) <= 2)
+		for _, event := range page.Events {
+			originals = append(originals, event.Original)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	sort.Strings(originals[:2])
+	require.Equal(t, []string{"1", "4", "2", "3", "5"}, originals)
+
+	//filters
+	page, err := ec.Query(&EventsFilter{DestinationIds: []string{"d1", "d2"}, TokenId: "t1", Status: StatusError, End: end, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(page.Events))
+	require.Equal(t, "e4", page.Events[0].EventId)
+	require.Equal(t, "", page.NextCursor)
+
+	page, err = ec.Query(&EventsFilter{DestinationIds: []string{"d1"}, Status: StatusPending, End: end, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(page.Events))
+	require.Equal(t, "e2", page.Events[0].EventId)
+
+	page, err = ec.Query(&EventsFilter{DestinationIds: []string{"d1", "d2"}, Start: time.Unix(150, 0), End: end, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(page.Events))
+	require.Equal(t, "e5", page.Events[0].EventId)
+
+	_, err = ec.Query(&EventsFilter{DestinationIds: []string{"d1"}, Status: "unknown", End: end, Limit: 10})
+	require.Error(t, err)
+	_, err = ec.Query(&EventsFilter{DestinationIds: []string{"d1"}, Cursor: "%%%", End: end, Limit: 10})
+	require.Error(t, err)
+}
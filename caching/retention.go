@@ -0,0 +1,198 @@
+package caching
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/scheduler"
+	"sync"
+	"time"
+)
+
+const retentionEvery = time.Minute
+
+//TokenRetention is a token scoped events cache configuration: max cached events of the token per destination
+//and max age of cached events (0 - without limits)
+type TokenRetention struct {
+	Size         int `mapstructure:"size" json:"size,omitempty" yaml:"size,omitempty"`
+	RetentionSec int `mapstructure:"retention_sec" json:"retention_sec,omitempty" yaml:"retention_sec,omitempty"`
+}
+
+func (tr *TokenRetention) Validate() error {
+	if tr == nil {
+		return nil
+	}
+	if tr.Size < 0 {
+		return errors.New("size can't be negative")
+	}
+	if tr.RetentionSec < 0 {
+		return errors.New("retention_sec can't be negative")
+	}
+
+	return nil
+}
+
+//TokensRetentionConfig is a server.cache.events token scoped configuration
+//PerToken is a default for all tokens, Tokens are overrides by token id
+type TokensRetentionConfig struct {
+	PerToken *TokenRetention            `mapstructure:"per_token" json:"per_token,omitempty" yaml:"per_token,omitempty"`
+	Tokens   map[string]*TokenRetention `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+}
+
+//IsEmpty return true if token scoped caching isn't configured
+func (trc *TokensRetentionConfig) IsEmpty() bool {
+	return trc == nil || (trc.PerToken == nil && len(trc.Tokens) == 0)
+}
+
+//tokensRetention holds token scoped caching configuration and (destination, token) pairs which have cached events
+type tokensRetention struct {
+	sync.RWMutex
+
+	defaultRetention *TokenRetention
+	tokens           map[string]*TokenRetention
+	//destination id -> token ids
+	cached map[string]map[string]bool
+
+	job *scheduler.Job
+}
+
+//ConfigureTokens enable token scoped caching: every token has own capacity in each destination cache
+//so noisy tokens don't evict events of low-volume tokens. Capacity of tokens without configuration is
+//PerToken size (or destination capacity). Events older than retention_sec are removed every minute
+func (ec *EventsCache) ConfigureTokens(config *TokensRetentionConfig) error {
+	if config.IsEmpty() {
+		return nil
+	}
+
+	if err := config.PerToken.Validate(); err != nil {
+		return fmt.Errorf("Error validating per_token events cache config: %v", err)
+	}
+	for tokenId, tokenRetention := range config.Tokens {
+		if err := tokenRetention.Validate(); err != nil {
+			return fmt.Errorf("Error validating token [%s] events cache config: %v", tokenId, err)
+		}
+	}
+
+	defaultRetention := &TokenRetention{Size: ec.capacityPerDestination}
+	if config.PerToken != nil {
+		defaultRetention = config.PerToken
+		if defaultRetention.Size == 0 {
+			defaultRetention.Size = ec.capacityPerDestination
+		}
+	}
+	tokens := map[string]*TokenRetention{}
+	for tokenId, tokenRetention := range config.Tokens {
+		if tokenRetention == nil {
+			continue
+		}
+		if tokenRetention.Size == 0 {
+			tokenRetention.Size = defaultRetention.Size
+		}
+		tokens[tokenId] = tokenRetention
+	}
+
+	tr := &tokensRetention{defaultRetention: defaultRetention, tokens: tokens, cached: map[string]map[string]bool{}}
+	tr.job = scheduler.Add("events_cache_retention", scheduler.Every(retentionEvery), func() error {
+		ec.removeExpired(time.Now().UTC())
+		return nil
+	})
+	ec.tokensRetention = tr
+	logging.Infof("Events cache is token scoped: default token capacity [%d], configured tokens: %d", defaultRetention.Size, len(tokens))
+	return nil
+}
+
+//get return token configuration or default one
+func (tr *tokensRetention) get(tokenId string) *TokenRetention {
+	if tokenRetention, ok := tr.tokens[tokenId]; ok {
+		return tokenRetention
+	}
+
+	return tr.defaultRetention
+}
+
+func (tr *tokensRetention) track(destinationId, tokenId string) {
+	tr.Lock()
+	defer tr.Unlock()
+
+	tokenIds, ok := tr.cached[destinationId]
+	if !ok {
+		tokenIds = map[string]bool{}
+		tr.cached[destinationId] = tokenIds
+	}
+	tokenIds[tokenId] = true
+}
+
+//pairs return copy of cached (destination id -> token ids)
+func (tr *tokensRetention) pairs() map[string][]string {
+	tr.RLock()
+	defer tr.RUnlock()
+
+	result := map[string][]string{}
+	for destinationId, tokenIds := range tr.cached {
+		for tokenId := range tokenIds {
+			result[destinationId] = append(result[destinationId], tokenId)
+		}
+	}
+
+	return result
+}
+
+func (tr *tokensRetention) close() {
+	if tr.job != nil {
+		tr.job.Stop()
+	}
+}
+
+//putTokenScoped evict the oldest token events if the token capacity is exceeded
+func (ec *EventsCache) putTokenScoped(tokenId, destinationId string) {
+	ec.tokensRetention.track(destinationId, tokenId)
+	capacity := ec.tokensRetention.get(tokenId).Size
+
+	tokenEvents, err := ec.storage.GetTotalTokenEvents(destinationId, tokenId)
+	if err != nil {
+		logging.SystemErrorf("[%s] Error getting token [%s] cached events count: %v", destinationId, tokenId, err)
+		return
+	}
+
+	if tokenEvents > capacity {
+		toDelete := tokenEvents - capacity
+		if toDelete > 2 {
+			logging.Infof("[%s] Token [%s] events cache size: [%d] capacity: [%d] elements to delete: [%d]", destinationId, tokenId, tokenEvents, capacity, toDelete)
+		}
+		for i := 0; i < toDelete; i++ {
+			if err := ec.storage.RemoveLastTokenEvent(destinationId, tokenId); err != nil {
+				logging.SystemErrorf("[%s] Error removing token [%s] event from cache: %v", destinationId, tokenId, err)
+				return
+			}
+		}
+		tokenEvents = capacity
+	}
+
+	metrics.EventsCacheSize(destinationId, tokenId, tokenEvents)
+}
+
+//removeExpired remove events older than token retention from all destinations caches
+func (ec *EventsCache) removeExpired(now time.Time) {
+	for destinationId, tokenIds := range ec.tokensRetention.pairs() {
+		for _, tokenId := range tokenIds {
+			retentionSec := ec.tokensRetention.get(tokenId).RetentionSec
+			if retentionSec == 0 {
+				continue
+			}
+
+			removed, err := ec.storage.RemoveTokenEventsBefore(destinationId, tokenId, now.Add(-time.Duration(retentionSec)*time.Second))
+			if err != nil {
+				logging.SystemErrorf("[%s] Error removing expired token [%s] events from cache: %v", destinationId, tokenId, err)
+				continue
+			}
+			if removed == 0 {
+				continue
+			}
+
+			if tokenEvents, err := ec.storage.GetTotalTokenEvents(destinationId, tokenId); err == nil {
+				metrics.EventsCacheSize(destinationId, tokenId, tokenEvents)
+			}
+		}
+	}
+}
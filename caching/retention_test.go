@@ -0,0 +1,70 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//storage with in-memory token scoped events cache
+type tokenStorage struct {
+	meta.Dummy
+	//destination id + token id -> event ids with timestamps (the oldest first)
+	index map[string][]meta.EventIndex
+}
+
+func (ts *tokenStorage) AddEvent(destinationId, eventId, tokenId, payload string, now time.Time) (int, error) {
+	key := destinationId + ":" + tokenId
+	ts.index[key] = append(ts.index[key], meta.EventIndex{EventId: eventId, Timestamp: now.Unix()})
+	return 0, nil
+}
+
+func (ts *tokenStorage) GetTotalTokenEvents(destinationId, tokenId string) (int, error) {
+	return len(ts.index[destinationId+":"+tokenId]), nil
+}
+
+func (ts *tokenStorage) RemoveLastTokenEvent(destinationId, tokenId string) error {
+	key := destinationId + ":" + tokenId
+	ts.index[key] = ts.index[key][1:]
+	return nil
+}
+
+func (ts *tokenStorage) RemoveTokenEventsBefore(destinationId, tokenId string, before time.Time) (int, error) {
+	key := destinationId + ":" + tokenId
+	var kept []meta.EventIndex
+	for _, item := range ts.index[key] {
+		if item.Timestamp >= before.Unix() {
+			kept = append(kept, item)
+		}
+	}
+	removed := len(ts.index[key]) - len(kept)
+	ts.index[key] = kept
+	return removed, nil
+}
+
+func TestTokenScopedCache(t *testing.T) {
+	storage := &tokenStorage{index: map[string][]meta.EventIndex{}}
+	ec := &EventsCache{storage: storage, capacityPerDestination: 3}
+	require.NoError(t, ec.ConfigureTokens(nil))
+	require.Nil(t, ec.tokensRetention)
+
+	require.Error(t, ec.ConfigureTokens(&TokensRetentionConfig{PerToken: &TokenRetention{Size: -1}}))
+	require.NoError(t, ec.ConfigureTokens(&TokensRetentionConfig{Tokens: map[string]*TokenRetention{"quiet": {RetentionSec: 60}}}))
+	defer ec.Close()
+
+	//noisy token doesn't evict quiet token events
+	ec.put("quiet", "pg", "q1", events.Event{})
+	for _, eventId := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		ec.put("noisy", "pg", eventId, events.Event{})
+	}
+	require.Equal(t, []meta.EventIndex{{EventId: "q1", Timestamp: storage.index["pg:quiet"][0].Timestamp}}, storage.index["pg:quiet"])
+	require.Len(t, storage.index["pg:noisy"], 3, "destination capacity is the default token capacity")
+	require.Equal(t, "n3", storage.index["pg:noisy"][0].EventId)
+
+	//retention is applied only to the configured token
+	ec.removeExpired(time.Now().Add(time.Hour))
+	require.Len(t, storage.index["pg:quiet"], 0)
+	require.Len(t, storage.index["pg:noisy"], 3)
+}
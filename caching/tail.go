@@ -0,0 +1,126 @@
+package caching
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const tailBufferSize = 1000
+
+//TailEvent is a live event of events cache pipeline: original event (pending), processed event (success) or error
+type TailEvent struct {
+	Status        string       `json:"status"`
+	TokenId       string       `json:"token_id,omitempty"`
+	DestinationId string       `json:"destination_id,omitempty"`
+	EventId       string       `json:"event_id,omitempty"`
+	Timestamp     time.Time    `json:"timestamp"`
+	Event         events.Event `json:"event,omitempty"`
+	Table         string       `json:"table,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+//TailFilter selects live events. Empty fields match all events
+type TailFilter struct {
+	DestinationIds []string
+	Status         string
+}
+
+//Match return true if event status and destination match the filter
+func (tf *TailFilter) Match(event *TailEvent) bool {
+	if tf.Status != "" && tf.Status != event.Status {
+		return false
+	}
+	if len(tf.DestinationIds) == 0 {
+		return true
+	}
+	for _, destinationId := range tf.DestinationIds {
+		if destinationId == event.DestinationId {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Subscription receives live events which match the filter
+//Events are dropped (and counted) if subscriber is slower than events flow
+type Subscription struct {
+	//first field for 64-bit aligned atomic operations
+	dropped int64
+
+	Events chan *TailEvent
+	filter *TailFilter
+}
+
+//Dropped return count of events which weren't delivered because of full buffer
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+//tail broadcasts events cache pipeline events to subscribers
+type tail struct {
+	sync.RWMutex
+	subscriptions map[*Subscription]bool
+}
+
+func newTail() *tail {
+	return &tail{subscriptions: map[*Subscription]bool{}}
+}
+
+func (t *tail) subscribe(filter *TailFilter) *Subscription {
+	subscription := &Subscription{Events: make(chan *TailEvent, tailBufferSize), filter: filter}
+
+	t.Lock()
+	t.subscriptions[subscription] = true
+	t.Unlock()
+
+	return subscription
+}
+
+func (t *tail) unsubscribe(subscription *Subscription) {
+	t.Lock()
+	delete(t.subscriptions, subscription)
+	t.Unlock()
+}
+
+//active return true if there is at least one subscriber (for skipping events building)
+func (t *tail) active() bool {
+	if t == nil {
+		return false
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+
+	return len(t.subscriptions) > 0
+}
+
+//publish send event to all matched subscribers without blocking
+func (t *tail) publish(event *TailEvent) {
+	t.RLock()
+	defer t.RUnlock()
+
+	for subscription := range t.subscriptions {
+		if !subscription.filter.Match(event) {
+			continue
+		}
+
+		select {
+		case subscription.Events <- event:
+		default:
+			atomic.AddInt64(&subscription.dropped, 1)
+		}
+	}
+}
+
+//Subscribe return subscription on live events cache pipeline events. Unsubscribe must be called after using
+func (ec *EventsCache) Subscribe(filter *TailFilter) *Subscription {
+	return ec.tail.subscribe(filter)
+}
+
+//Unsubscribe stop sending events to the subscription
+func (ec *EventsCache) Unsubscribe(subscription *Subscription) {
+	ec.tail.unsubscribe(subscription)
+}
@@ -0,0 +1,339 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultCapacity   = 100
+	maxCapacity       = 1000
+	defaultTtlMinutes = 60
+	maxTtlMinutes     = 24 * 60
+	//maxBodyBytes is a max captured body size. Bigger bodies are truncated (requests are processed as is:
+	//only the first maxBodyBytes are read before handlers, the rest is streamed)
+	maxBodyBytes = 1 << 20
+
+	maskedValue    = "*****"
+	tokenParameter = "token"
+	//snowplowAppIdParameter is a Snowplow tracker app id which is used as a token
+	snowplowAppIdParameter = "aid"
+	snowplowPostPath       = "/com.snowplowanalytics.snowplow/tp2"
+)
+
+//maskedHeaders are headers with credentials which values aren't captured
+var maskedHeaders = map[string]bool{"Authorization": true, "Cookie": true, "X-Auth-Token": true}
+
+//snowplowAppIdRegex matches app ids in Snowplow POST payload (data array items) including the app id cut by truncation
+var snowplowAppIdRegex = regexp.MustCompile(`"` + snowplowAppIdParameter + `"\s*:\s*"(\\.|[^"\\])*("|\\?$)`)
+
+var instance = &Service{sessions: map[string]*session{}}
+
+//Config is a capture session of the token: sample_rate (0, 1] of requests are captured into ring buffer
+//with capacity last requests during ttl_minutes
+type Config struct {
+	TokenId    string  `json:"token_id"`
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	Capacity   int     `json:"capacity,omitempty"`
+	TtlMinutes int     `json:"ttl_minutes,omitempty"`
+}
+
+//Validate return err if the config is invalid and set default values
+func (c *Config) Validate() error {
+	if c.TokenId == "" {
+		return errors.New("token_id is required")
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be in (0, 1]: %v", c.SampleRate)
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 1
+	}
+	if c.Capacity < 0 || c.Capacity > maxCapacity {
+		return fmt.Errorf("capacity must be in [1, %d]: %d", maxCapacity, c.Capacity)
+	}
+	if c.Capacity == 0 {
+		c.Capacity = defaultCapacity
+	}
+	if c.TtlMinutes < 0 || c.TtlMinutes > maxTtlMinutes {
+		return fmt.Errorf("ttl_minutes must be in [1, %d]: %d", maxTtlMinutes, c.TtlMinutes)
+	}
+	if c.TtlMinutes == 0 {
+		c.TtlMinutes = defaultTtlMinutes
+	}
+
+	return nil
+}
+
+//Request is a captured raw request. Body is base64 encoded if it isn't a valid UTF-8 string (e.g. gzipped)
+type Request struct {
+	Time         time.Time           `json:"time"`
+	Method       string              `json:"method"`
+	Url          string              `json:"url"`
+	RemoteAddr   string              `json:"remote_addr"`
+	Headers      map[string][]string `json:"headers"`
+	Body         string              `json:"body"`
+	BodyEncoding string              `json:"body_encoding,omitempty"`
+	Truncated    bool                `json:"truncated,omitempty"`
+}
+
+//SessionStatus is a capture session config with its state
+type SessionStatus struct {
+	Config
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+	Captured  int       `json:"captured"`
+}
+
+//session keeps the last captured requests in the ring buffer
+type session struct {
+	sync.Mutex
+
+	config    Config
+	startedAt time.Time
+	expiresAt time.Time
+
+	requests []*Request
+	next     int
+	total    int
+}
+
+func (s *session) put(request *Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.requests) < s.config.Capacity {
+		s.requests = append(s.requests, request)
+	} else {
+		s.requests[s.next] = request
+	}
+	s.next = (s.next + 1) % s.config.Capacity
+	s.total++
+}
+
+//get return captured requests from the oldest to the newest
+func (s *session) get() []*Request {
+	s.Lock()
+	defer s.Unlock()
+
+	result := make([]*Request, 0, len(s.requests))
+	if len(s.requests) < s.config.Capacity {
+		return append(result, s.requests...)
+	}
+
+	result = append(result, s.requests[s.next:]...)
+	return append(result, s.requests[:s.next]...)
+}
+
+func (s *session) status(now time.Time) *SessionStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	return &SessionStatus{Config: s.config, StartedAt: s.startedAt, ExpiresAt: s.expiresAt, Expired: !now.Before(s.expiresAt), Captured: s.total}
+}
+
+//Service keeps capture sessions per token in memory of the current instance
+type Service struct {
+	sync.RWMutex
+
+	sessions map[string]*session
+	//active is a count of sessions: requests aren't inspected if there are no sessions
+	active int32
+	//nextExpiration is the earliest sessions expiration (unix nanoseconds): expired sessions are removed after it
+	nextExpiration int64
+}
+
+//updateActive set the count of sessions and the earliest expiration (must be called with lock)
+func (s *Service) updateActive() {
+	nextExpiration := int64(math.MaxInt64)
+	for _, session := range s.sessions {
+		if expiration := session.expiresAt.UnixNano(); expiration < nextExpiration {
+			nextExpiration = expiration
+		}
+	}
+	atomic.StoreInt64(&s.nextExpiration, nextExpiration)
+	atomic.StoreInt32(&s.active, int32(len(s.sessions)))
+}
+
+//removeExpired remove expired sessions with captured requests
+func (s *Service) removeExpired(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for tokenId, session := range s.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(s.sessions, tokenId)
+		}
+	}
+	s.updateActive()
+}
+
+//Start create (or replace) capture session of the token
+func Start(config *Config) (*SessionStatus, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	s := &session{config: *config, startedAt: now, expiresAt: now.Add(time.Duration(config.TtlMinutes) * time.Minute)}
+
+	instance.Lock()
+	instance.sessions[config.TokenId] = s
+	instance.updateActive()
+	instance.Unlock()
+
+	return s.status(now), nil
+}
+
+//Stop remove capture session of the token with captured requests. Return false if the session doesn't exist
+func Stop(tokenId string) bool {
+	instance.Lock()
+	defer instance.Unlock()
+
+	if _, ok := instance.sessions[tokenId]; !ok {
+		return false
+	}
+	delete(instance.sessions, tokenId)
+	instance.updateActive()
+
+	return true
+}
+
+//Sessions return all capture sessions statuses sorted by token id
+func Sessions() []*SessionStatus {
+	now := time.Now().UTC()
+	instance.RLock()
+	defer instance.RUnlock()
+
+	result := []*SessionStatus{}
+	for _, s := range instance.sessions {
+		result = append(result, s.status(now))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TokenId < result[j].TokenId
+	})
+
+	return result
+}
+
+//Get return capture session status and captured requests of the token
+func Get(tokenId string) (*SessionStatus, []*Request, bool) {
+	instance.RLock()
+	s, ok := instance.sessions[tokenId]
+	instance.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+
+	return s.status(time.Now().UTC()), s.get(), true
+}
+
+//IsActive return true if there is at least one not expired capture session
+//expired sessions are removed with captured requests
+func IsActive() bool {
+	if atomic.LoadInt32(&instance.active) == 0 {
+		return false
+	}
+
+	now := time.Now().UTC()
+	if now.UnixNano() >= atomic.LoadInt64(&instance.nextExpiration) {
+		instance.removeExpired(now)
+	}
+
+	return atomic.LoadInt32(&instance.active) > 0
+}
+
+//Capture put the request into the token capture session (if it isn't expired and the request is sampled)
+//request body is read and replaced so it can be read again by handlers
+func Capture(tokenId string, r *http.Request) {
+	instance.RLock()
+	s, ok := instance.sessions[tokenId]
+	instance.RUnlock()
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(s.expiresAt) || (s.config.SampleRate < 1 && rand.Float64() >= s.config.SampleRate) {
+		return
+	}
+
+	s.put(newRequest(r, now))
+}
+
+func newRequest(r *http.Request, now time.Time) *Request {
+	request := &Request{Time: now, Method: r.Method, Url: r.URL.String(), RemoteAddr: r.RemoteAddr, Headers: map[string][]string{}}
+	for name, values := range r.Header {
+		if maskedHeaders[http.CanonicalHeaderKey(name)] {
+			values = []string{maskedValue}
+		}
+		request.Headers[name] = values
+	}
+	//query token values (token, p_* and Snowplow app id) are credentials as well
+	query := r.URL.Query()
+	masked := false
+	for name, values := range query {
+		if isTokenParameter(name) && len(values) > 0 {
+			query.Set(name, maskedValue)
+			masked = true
+		}
+	}
+	if masked {
+		u := *r.URL
+		u.RawQuery = query.Encode()
+		request.Url = u.String()
+	}
+
+	if r.Body == nil {
+		return request
+	}
+	//read only captured bytes (+1 for detecting truncation), handlers read them and the rest of the body
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	r.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	if err != nil {
+		request.Body = "Error reading body: " + err.Error()
+		return request
+	}
+
+	if len(body) > maxBodyBytes {
+		body = body[:maxBodyBytes]
+		request.Truncated = true
+	}
+	if r.URL.Path == snowplowPostPath {
+		body = snowplowAppIdRegex.ReplaceAll(body, []byte(`"`+snowplowAppIdParameter+`":"`+maskedValue+`"`))
+	}
+	if utf8.Valid(body) {
+		request.Body = string(body)
+	} else {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.BodyEncoding = "base64"
+	}
+
+	return request
+}
+
+//readCloser is a request body which reads captured bytes and then the rest of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+//isTokenParameter return true if query parameter value is a token (see middleware.extractToken and Snowplow handlers)
+func isTokenParameter(name string) bool {
+	return name == tokenParameter || name == snowplowAppIdParameter || strings.HasPrefix(name, "p_")
+}
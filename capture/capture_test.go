@@ -0,0 +1,141 @@
+package capture
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapture(t *testing.T) {
+	defer Stop("t1")
+
+	require.False(t, IsActive())
+	_, err := Start(&Config{TokenId: "t1", SampleRate: 2})
+	require.EqualError(t, err, "sample_rate must be in (0, 1]: 2")
+
+	status, err := Start(&Config{TokenId: "t1", Capacity: 2})
+	require.NoError(t, err)
+	require.True(t, IsActive())
+	require.Equal(t, 1.0, status.SampleRate)
+	require.Equal(t, 60, status.TtlMinutes)
+
+	for _, body := range []string{`{"n":1}`, `{"n":2}`, `{"n":3`} {
+		r := httptest.NewRequest("POST", "/api/v1/event?token=secret&v=1", strings.NewReader(body))
+		r.Header.Set("X-Auth-Token", "secret")
+		r.Header.Set("Content-Type", "application/json")
+		Capture("t1", r)
+
+		//body can be read by handlers
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, body, string(b))
+	}
+	//another token isn't captured
+	Capture("t2", httptest.NewRequest("POST", "/api/v1/event", strings.NewReader("{}")))
+
+	status, requests, ok := Get("t1")
+	require.True(t, ok)
+	require.Equal(t, 3, status.Captured)
+	require.False(t, status.Expired)
+	//ring buffer keeps the last requests from the oldest to the newest
+	require.Len(t, requests, 2)
+	require.Equal(t, `{"n":2}`, requests[0].Body)
+	require.Equal(t, `{"n":3`, requests[1].Body)
+	require.Equal(t, []string{maskedValue}, requests[1].Headers["X-Auth-Token"])
+	require.Equal(t, []string{"application/json"}, requests[1].Headers["Content-Type"])
+	require.Equal(t, "/api/v1/event?token=%2A%2A%2A%2A%2A&v=1", requests[1].Url)
+
+	_, _, ok = Get("t2")
+	require.False(t, ok)
+	require.Len(t, Sessions(), 1)
+
+	require.True(t, Stop("t1"))
+	require.False(t, Stop("t1"))
+	require.False(t, IsActive())
+}
+
+func TestCaptureBinaryBody(t *testing.T) {
+	defer Stop("t1")
+
+	_, err := Start(&Config{TokenId: "t1"})
+	require.NoError(t, err)
+
+	Capture("t1", httptest.NewRequest("POST", "/api/v1/event", strings.NewReader("\x1f\x8b\x08")))
+	_, requests, _ := Get("t1")
+	require.Len(t, requests, 1)
+	require.Equal(t, "base64", requests[0].BodyEncoding)
+	require.Equal(t, "H4sI", requests[0].Body)
+}
+
+func TestCaptureMaskTokens(t *testing.T) {
+	defer Stop("t1")
+
+	_, err := Start(&Config{TokenId: "t1"})
+	require.NoError(t, err)
+
+	Capture("t1", httptest.NewRequest("GET", "/api/v1/event.gif?p_api_key=secret&data=1", nil))
+	Capture("t1", httptest.NewRequest("GET", "/i?aid=secret&e=pv", nil))
+	Capture("t1", httptest.NewRequest("POST", "/com.snowplowanalytics.snowplow/tp2?token=secret",
+		strings.NewReader(`{"schema":"payload_data","data":[{"aid": "secret","e":"pv"},{"aid":"se\"cret","e":"pv"}]}`)))
+
+	_, requests, _ := Get("t1")
+	require.Len(t, requests, 3)
+	require.Equal(t, "/api/v1/event.gif?data=1&p_api_key=%2A%2A%2A%2A%2A", requests[0].Url)
+	require.Equal(t, "/i?aid=%2A%2A%2A%2A%2A&e=pv", requests[1].Url)
+	require.Equal(t, "/com.snowplowanalytics.snowplow/tp2?token=%2A%2A%2A%2A%2A", requests[2].Url)
+	require.Equal(t, `{"schema":"payload_data","data":[{"aid":"*****","e":"pv"},{"aid":"*****","e":"pv"}]}`, requests[2].Body)
+	for _, request := range requests {
+		require.NotContains(t, request.Url+request.Body, "secret")
+	}
+}
+
+func TestCaptureTruncatedBody(t *testing.T) {
+	defer Stop("t1")
+
+	_, err := Start(&Config{TokenId: "t1"})
+	require.NoError(t, err)
+
+	body := strings.Repeat("a", maxBodyBytes+10)
+	r := httptest.NewRequest("POST", "/api/v1/event", strings.NewReader(body))
+	Capture("t1", r)
+	b, err := ioutil.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, string(b))
+
+	//app id cut by truncation is masked as well
+	snowplowBody := `{"data":[{"aid":"` + strings.Repeat("a", maxBodyBytes)
+	Capture("t1", httptest.NewRequest("POST", snowplowPostPath, strings.NewReader(snowplowBody)))
+
+	_, requests, _ := Get("t1")
+	require.Len(t, requests, 2)
+	require.True(t, requests[0].Truncated)
+	require.Equal(t, body[:maxBodyBytes], requests[0].Body)
+	require.Equal(t, `{"data":[{"aid":"*****"`, requests[1].Body)
+}
+
+func TestCaptureExpiredSessions(t *testing.T) {
+	defer Stop("t1")
+	defer Stop("t2")
+
+	_, err := Start(&Config{TokenId: "t1"})
+	require.NoError(t, err)
+	_, err = Start(&Config{TokenId: "t2"})
+	require.NoError(t, err)
+
+	instance.Lock()
+	instance.sessions["t1"].expiresAt = time.Now().Add(-time.Second)
+	instance.updateActive()
+	instance.Unlock()
+
+	require.True(t, IsActive())
+	_, _, ok := Get("t1")
+	require.False(t, ok)
+	_, _, ok = Get("t2")
+	require.True(t, ok)
+
+	require.True(t, Stop("t2"))
+	require.False(t, IsActive())
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultServer = "http://localhost:8001"
+
+	serverEnv     = "EVENTNATIVE_SERVER"
+	adminTokenEnv = "EVENTNATIVE_ADMIN_TOKEN"
+)
+
+//command is an operational subcommand: eventnative <name> [flags]
+type command struct {
+	name        string
+	description string
+	run         func(args []string, out io.Writer) error
+}
+
+var commands = []*command{
+	{name: "replay", description: "List fallback files or replay a fallback file into the destination (admin API)", run: replay},
+	{name: "import", description: "Send events from a local JSON/JSON lines file via server to server events API", run: importEvents},
+	{name: "validate", description: "Validate a local config file: destinations and sources configurations", run: validate},
+	{name: "meta", description: "Meta storage snapshots (admin API): export into a local file, import from a local file", run: metaSnapshot},
+	{name: "tokens", description: "Tokens operations on a local tokens JSON file: rotate", run: tokens},
+	{name: "service", description: "Windows service management: install, uninstall, start, stop", run: service},
+}
+
+//IsCommand return true if name is a CLI subcommand name (the first not flag argument)
+func IsCommand(name string) bool {
+	return getCommand(name) != nil || name == "help"
+}
+
+//Run execute subcommand and return process exit code
+//args[0] is a subcommand name
+func Run(args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		usage(out)
+		return 0
+	}
+
+	cmd := getCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(errOut, "Unknown command: %s\n", args[0])
+		usage(errOut)
+		return 2
+	}
+
+	if err := cmd.run(args[1:], out); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func getCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: eventnative [-cfg config.yaml] - run server")
+	fmt.Fprintln(out, "       eventnative <command> [flags] - run operational command")
+	fmt.Fprintln(out, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(out, "Run 'eventnative <command> -h' for command flags")
+}
+
+//newFlagSet return flag set which doesn't exit the process on errors
+func newFlagSet(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("eventnative "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
+
+//adminFlags register server and admin token flags with defaults from os env
+func adminFlags(fs *flag.FlagSet) *adminClient {
+	ac := &adminClient{}
+	fs.StringVar(&ac.server, "server", envOrDefault(serverEnv, defaultServer), "EventNative server url. Env: "+serverEnv)
+	fs.StringVar(&ac.adminToken, "admin-token", os.Getenv(adminTokenEnv), "server.admin_token value. Env: "+adminTokenEnv)
+	return ac
+}
+
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+//required return error if one of flags values is empty
+func required(values map[string]string) error {
+	var missing []string
+	for name, value := range values {
+		if value == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("required flags aren't set: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
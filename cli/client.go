@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/middleware"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const adminTokenHeader = "X-Admin-Token"
+
+//adminClient sends requests to EventNative server API
+type adminClient struct {
+	server     string
+	adminToken string
+
+	client *http.Client
+}
+
+//do send request with JSON body (if not nil) and headers and unmarshal JSON response into result (if not nil)
+//return error response message if status isn't 200
+func (ac *adminClient) do(method, path string, query url.Values, headers map[string]string, body, result interface{}) error {
+	if ac.client == nil {
+		ac.client = &http.Client{Timeout: time.Minute}
+	}
+
+	requestUrl := strings.TrimRight(ac.server, "/") + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Error marshalling request body: %v", err)
+		}
+		bodyReader = bytes.NewBuffer(b)
+	}
+
+	req, err := http.NewRequest(method, requestUrl, bodyReader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ac.adminToken != "" {
+		req.Header.Set(adminTokenHeader, ac.adminToken)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResponse := &middleware.ErrorResponse{}
+		if err := json.Unmarshal(respBody, errResponse); err == nil && errResponse.Message != "" {
+			if errResponse.Error != "" {
+				return fmt.Errorf("%s: %s", errResponse.Message, errResponse.Error)
+			}
+			return errors.New(errResponse.Message)
+		}
+		return fmt.Errorf("HTTP code = %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("Error unmarshalling response body: %v", err)
+		}
+	}
+
+	return nil
+}
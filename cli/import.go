@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+const tokenHeader = "X-Auth-Token"
+
+//importEvents send events from a local file (JSON array or JSON lines; '-' is stdin) via server to server events API
+func importEvents(args []string, out io.Writer) error {
+	fs := newFlagSet("import", out)
+	ac := adminFlags(fs)
+	token := fs.String("token", "", "Server secret (s2s token)")
+	filePath := fs.String("file", "", "Events file path: JSON array or JSON lines. '-' - read from stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(map[string]string{"token": *token, "file": *filePath}); err != nil {
+		return err
+	}
+
+	var payload []byte
+	var err error
+	if *filePath == "-" {
+		payload, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		payload, err = ioutil.ReadFile(*filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading events: %v", err)
+	}
+
+	eventsArray, err := parseEvents(payload)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for i, event := range eventsArray {
+		if err := ac.do(http.MethodPost, "/api/v1/s2s/event", nil, map[string]string{tokenHeader: *token}, event, nil); err != nil {
+			fmt.Fprintf(out, "Error sending event #%d: %v\n", i+1, err)
+			failed++
+		}
+	}
+
+	fmt.Fprintf(out, "Imported events: %d, failed: %d\n", len(eventsArray)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d events haven't been imported", failed)
+	}
+
+	return nil
+}
+
+//parseEvents return events from JSON array or JSON lines payload
+func parseEvents(payload []byte) ([]events.Event, error) {
+	payload = bytes.TrimSpace(payload)
+	if bytes.HasPrefix(payload, []byte("[")) {
+		var eventsArray []events.Event
+		if err := json.Unmarshal(payload, &eventsArray); err != nil {
+			return nil, fmt.Errorf("Error parsing events JSON array: %v", err)
+		}
+		return eventsArray, nil
+	}
+
+	var eventsArray []events.Event
+	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		lineBytes := bytes.TrimSpace(scanner.Bytes())
+		if len(lineBytes) == 0 {
+			continue
+		}
+		event := events.Event{}
+		if err := json.Unmarshal(lineBytes, &event); err != nil {
+			return nil, fmt.Errorf("Error parsing event JSON on line %d: %v", line, err)
+		}
+		eventsArray = append(eventsArray, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading events: %v", err)
+	}
+
+	return eventsArray, nil
+}
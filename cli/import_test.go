@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestImportEvents(t *testing.T) {
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/s2s/event", r.URL.Path)
+		if r.Header.Get(tokenHeader) != "s2stoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"The token is not found"}`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		event := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(body, &event))
+		received = append(received, event)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	file, err := ioutil.TempFile("", "events*.json")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("{\"event_type\":\"a\"}\n\n{\"event_type\":\"b\"}\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	out := &bytes.Buffer{}
+	require.NoError(t, importEvents([]string{"-server", server.URL, "-token", "s2stoken", "-file", file.Name()}, out))
+	require.Equal(t, []map[string]interface{}{{"event_type": "a"}, {"event_type": "b"}}, received)
+	require.Contains(t, out.String(), "Imported events: 2, failed: 0")
+
+	out.Reset()
+	require.EqualError(t, importEvents([]string{"-server", server.URL, "-token", "wrong", "-file", file.Name()}, out), "2 events haven't been imported")
+	require.Contains(t, out.String(), "The token is not found")
+
+	require.EqualError(t, importEvents([]string{"-server", server.URL}, out), "required flags aren't set: -file, -token")
+
+	parsed, err := parseEvents([]byte(`[{"a":1},{"b":2}]`))
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/meta"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+//metaSnapshot run meta subcommands: export and import (e.g. migration to another Redis or restore after data loss)
+func metaSnapshot(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return exportMeta(args[1:], out)
+		case "import":
+			return importMeta(args[1:], out)
+		}
+	}
+
+	return errors.New("usage: eventnative meta export -file snapshot.json [-patterns 'source#*,hourly_events:*'] or eventnative meta import -file snapshot.json")
+}
+
+//exportMeta write meta storage snapshot from the server into the local file
+func exportMeta(args []string, out io.Writer) error {
+	fs := newFlagSet("meta export", out)
+	ac := adminFlags(fs)
+	filePath := fs.String("file", "", "Snapshot file path")
+	patterns := fs.String("patterns", "", "Comma separated keys patterns (e.g. 'source#*'). Default: all keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(map[string]string{"file": *filePath}); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *patterns != "" {
+		query.Set("patterns", *patterns)
+	}
+	snapshot := &meta.Snapshot{}
+	if err := ac.do(http.MethodGet, "/api/v1/meta/export", query, nil, nil, snapshot); err != nil {
+		return fmt.Errorf("Error exporting meta storage: %v", err)
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("Error marshalling snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(*filePath, b, 0644); err != nil {
+		return fmt.Errorf("Error writing snapshot file: %v", err)
+	}
+
+	fmt.Fprintf(out, "Meta storage [%s] snapshot has been written into [%s]: %d entries\n", snapshot.Source, *filePath, len(snapshot.Entries))
+	return nil
+}
+
+//importMeta send the local snapshot file into the server meta storage
+func importMeta(args []string, out io.Writer) error {
+	fs := newFlagSet("meta import", out)
+	ac := adminFlags(fs)
+	filePath := fs.String("file", "", "Snapshot file path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(map[string]string{"file": *filePath}); err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(*filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading snapshot file: %v", err)
+	}
+	snapshot := &meta.Snapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return fmt.Errorf("Error parsing snapshot file: %v", err)
+	}
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+
+	response := &handlers.ImportResponse{}
+	if err := ac.do(http.MethodPost, "/api/v1/meta/import", nil, nil, snapshot, response); err != nil {
+		return fmt.Errorf("Error importing snapshot: %v", err)
+	}
+
+	fmt.Fprintf(out, "Snapshot [%s] has been imported: %d entries\n", *filePath, response.Entries)
+	return nil
+}
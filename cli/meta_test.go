@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetaExportImport(t *testing.T) {
+	exported := &meta.Snapshot{
+		Version:   meta.SnapshotVersion,
+		CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Source:    meta.RedisType,
+		Entries: []*meta.SnapshotEntry{
+			{Key: "source#s1:collection#c1:status", Type: meta.HashEntry, Hash: map[string]string{"2021": "OK"}},
+			{Key: "daily_uniques:id#token_t1:day#20210101", Type: meta.StringEntry, Value: []byte{0x48, 0x59, 0x4c, 0x4c, 0xff}, TTLMs: 1000},
+		},
+	}
+	var imported *meta.Snapshot
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "admin", r.Header.Get(adminTokenHeader))
+		switch r.URL.Path {
+		case "/api/v1/meta/export":
+			require.Equal(t, "source#*", r.URL.Query().Get("patterns"))
+			b, _ := json.Marshal(exported)
+			w.Write(b)
+		case "/api/v1/meta/import":
+			body, _ := ioutil.ReadAll(r.Body)
+			imported = &meta.Snapshot{}
+			require.NoError(t, json.Unmarshal(body, imported))
+			w.Write([]byte(`{"status":"ok","entries":2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "meta")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "snapshot.json")
+
+	out := &bytes.Buffer{}
+	require.Equal(t, 0, Run([]string{"meta", "export", "-server", server.URL, "-admin-token", "admin", "-file", filePath, "-patterns", "source#*"}, out, out))
+	require.NoError(t, metaSnapshot([]string{"import", "-server", server.URL, "-admin-token", "admin", "-file", filePath}, out))
+	require.Equal(t, exported, imported)
+	require.Contains(t, out.String(), "has been imported: 2 entries")
+
+	require.Error(t, metaSnapshot([]string{"restore"}, out))
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/engine"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/spf13/viper"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const containerConfigDir = "/home/eventnative/app/res/"
+
+//Preflight check that the server is able to start: config file, meta storage connection, destinations connections,
+//authorization tokens and directories write permissions. It is executed by the container entrypoint before the server.
+//Write report into out (text or json format) and return process exit code
+func Preflight(out io.Writer, format string, containerized bool) int {
+	appconfig.SetDefaultParams()
+	report := &ValidationReport{Results: []*ValidationResult{}}
+
+	if checkConfigFile(report, containerized) {
+		checkMetaStorage(report)
+		checkDestinations(viper.GetViper(), report, true)
+		checkAuthorization(report)
+		checkDirectories(report)
+	}
+
+	if err := report.Write(out, format); err != nil {
+		fmt.Fprintf(out, "Error writing preflight report: %v\n", err)
+		return 1
+	}
+	if problems := report.Problems(); problems > 0 {
+		if format != "json" {
+			fmt.Fprintf(out, "Preflight checks failed: %d problem(s). The server won't be started\n", problems)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+//checkConfigFile return false if config file can't be parsed
+//a missing config file is allowed in containerized run (the server starts without destinations)
+func checkConfigFile(report *ValidationReport, containerized bool) bool {
+	configPath := viper.ConfigFileUsed()
+	if configPath == "" {
+		if containerized {
+			report.addStatus("config", "-cfg", validationWarn, "config file isn't provided: the server will start without destinations")
+			return true
+		}
+		report.addStatus("config", "-cfg", validationFail, "config file isn't provided: please add -cfg eventnative.yaml parameter")
+		return false
+	}
+
+	config := viper.New()
+	config.SetConfigFile(configPath)
+	if err := config.ReadInConfig(); err != nil {
+		if _, statErr := os.Stat(configPath); os.IsNotExist(statErr) && containerized {
+			report.addStatus("config", configPath, validationWarn, "config file doesn't exist: the server will start without destinations. "+
+				"Please put eventnative.yaml into <config_dir> and add mapping -v <config_dir>/:"+containerConfigDir)
+			return true
+		}
+		report.add("config", configPath, fmt.Errorf("Error parsing config file: %v", err))
+		return false
+	}
+
+	report.add("config", configPath, nil)
+	return true
+}
+
+//checkMetaStorage connect to meta storage (if configured)
+func checkMetaStorage(report *ValidationReport) {
+	metaStorageViper := engine.MetaStorageViper()
+	if metaStorageViper == nil {
+		report.addStatus("meta storage", "meta.storage", validationSkip, "meta storage isn't configured")
+		return
+	}
+
+	storage, err := meta.NewStorage(metaStorageViper)
+	if err == nil {
+		err = storage.Ping()
+		storage.Close()
+	}
+	if err != nil {
+		err = fmt.Errorf("meta storage isn't reachable: %v. Please check meta.storage.redis host, port and password or meta.storage.bolt.path", err)
+	}
+	report.add("meta storage", "meta.storage", err)
+}
+
+//checkDirectories check that events logs directory (it must exist) and application logs directory
+//(it is created by the logger like by the server) are writable
+func checkDirectories(report *ValidationReport) {
+	for _, key := range []string{"log.path", "server.log.path"} {
+		dir := viper.GetString(key)
+		if dir == "" {
+			continue
+		}
+
+		if key == "server.log.path" {
+			os.MkdirAll(dir, 0755)
+		}
+		if !logging.IsDirWritable(dir) {
+			absDir, _ := filepath.Abs(dir)
+			report.add("directory", key, fmt.Errorf("%s must exist and be writable. If the owner of the mounted dir isn't the container user, please use 'chmod 777 your_mount_dir'", absDir))
+			continue
+		}
+		report.add("directory", key, nil)
+	}
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bytes"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer viper.Reset()
+
+	configPath := filepath.Join(dir, "eventnative.yaml")
+	viper.Reset()
+	viper.SetConfigFile(configPath)
+
+	//missing config is allowed only in containerized run
+	out := &bytes.Buffer{}
+	require.Equal(t, 1, Preflight(out, "text", false))
+	require.Contains(t, out.String(), "[FAIL] config")
+
+	logPath := filepath.Join(dir, "events")
+	require.NoError(t, os.Mkdir(logPath, 0755))
+	require.NoError(t, ioutil.WriteFile(configPath, []byte("log:\n  path: "+logPath+"\nserver:\n  auth: token1\n"), 0644))
+	viper.Reset()
+	viper.SetConfigFile(configPath)
+	require.NoError(t, viper.ReadInConfig())
+
+	out = &bytes.Buffer{}
+	require.Equal(t, 0, Preflight(out, "text", false), out.String())
+	require.Contains(t, out.String(), "[OK] directory [log.path]")
+	require.Contains(t, out.String(), "[SKIP] meta storage")
+
+	viper.Set("log.path", filepath.Join(dir, "not_existing"))
+	out = &bytes.Buffer{}
+	require.Equal(t, 1, Preflight(out, "text", true))
+	require.Contains(t, out.String(), "must exist and be writable")
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/handlers"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+//replay list fallback files (without -file) or replay the fallback file into the destination
+func replay(args []string, out io.Writer) error {
+	fs := newFlagSet("replay", out)
+	ac := adminFlags(fs)
+	fileName := fs.String("file", "", "Fallback file name. If empty - fallback files are listed")
+	destinationId := fs.String("destination", "", "Destination id. Required with -file. Used as a filter for listing")
+	rawJson := fs.Bool("raw", false, "File contains raw JSON events (not fallback format)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *fileName == "" {
+		query := url.Values{}
+		if *destinationId != "" {
+			query.Set("destination_ids", *destinationId)
+		}
+		response := &handlers.FallbackFilesResponse{}
+		if err := ac.do(http.MethodGet, "/api/v1/fallback", query, nil, nil, response); err != nil {
+			return fmt.Errorf("Error getting fallback files: %v", err)
+		}
+
+		sort.Slice(response.Files, func(i, j int) bool {
+			return response.Files[i].FileName < response.Files[j].FileName
+		})
+		for _, file := range response.Files {
+			fmt.Fprintf(out, "%s\t%s\n", file.DestinationId, file.FileName)
+		}
+		fmt.Fprintf(out, "Total fallback files: %d\n", len(response.Files))
+		return nil
+	}
+
+	if err := required(map[string]string{"destination": *destinationId}); err != nil {
+		return err
+	}
+
+	request := &handlers.ReplayRequest{FileName: *fileName, DestinationId: *destinationId}
+	if *rawJson {
+		request.FileFormat = "raw_json"
+	}
+	if err := ac.do(http.MethodPost, "/api/v1/fallback/replay", nil, nil, request, nil); err != nil {
+		return fmt.Errorf("Error replaying file [%s]: %v", *fileName, err)
+	}
+
+	fmt.Fprintf(out, "File [%s] has been replayed into [%s]\n", *fileName, *destinationId)
+	return nil
+}
@@ -0,0 +1,13 @@
+// +build !windows
+
+package cli
+
+import (
+	"errors"
+	"io"
+)
+
+//service is supported only on Windows
+func service(args []string, out io.Writer) error {
+	return errors.New("service command is supported only on Windows")
+}
@@ -0,0 +1,96 @@
+// +build windows
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const defaultServiceName = "EventNative"
+
+//service run Windows service management subcommand: install, uninstall, start or stop
+func service(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: eventnative service install|uninstall|start|stop [-name EventNative] [-cfg config.yaml]")
+	}
+	action := args[0]
+
+	fs := newFlagSet("service "+action, out)
+	name := fs.String("name", defaultServiceName, "Windows service name")
+	configPath := fs.String("cfg", "", "Config file path (required for install)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("Error connecting to Windows Service Control Manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	if action == "install" {
+		return installService(m, *name, *configPath, out)
+	}
+
+	s, err := m.OpenService(*name)
+	if err != nil {
+		return fmt.Errorf("Error opening service [%s]: %v", *name, err)
+	}
+	defer s.Close()
+
+	switch action {
+	case "uninstall":
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("Error deleting service [%s]: %v", *name, err)
+		}
+	case "start":
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("Error starting service [%s]: %v", *name, err)
+		}
+	case "stop":
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("Error stopping service [%s]: %v", *name, err)
+		}
+	default:
+		return fmt.Errorf("unknown service action: %s. Available: [install, uninstall, start, stop]", action)
+	}
+
+	fmt.Fprintf(out, "Service [%s] %s: ok\n", *name, action)
+	return nil
+}
+
+//installService register the current executable as an automatically started service with absolute config path
+//services are started in the system directory so all paths in the config must be absolute
+func installService(m *mgr.Mgr, name, configPath string, out io.Writer) error {
+	if err := required(map[string]string{"cfg": configPath}); err != nil {
+		return err
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Error getting executable path: %v", err)
+	}
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return fmt.Errorf("Error getting config absolute path: %v", err)
+	}
+
+	s, err := m.CreateService(name, exePath, mgr.Config{
+		DisplayName: name,
+		Description: "EventNative event collection service",
+		StartType:   mgr.StartAutomatic,
+	}, "-cfg", configPath)
+	if err != nil {
+		return fmt.Errorf("Error creating service [%s]: %v", name, err)
+	}
+	defer s.Close()
+
+	fmt.Fprintf(out, "Service [%s] has been installed: %s -cfg %s\n", name, exePath, configPath)
+	return nil
+}
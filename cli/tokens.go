@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/uuid"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	clientSecretType  = "client"
+	serverSecretType  = "server"
+	signingSecretType = "signing"
+	allSecretsType    = "all"
+)
+
+//tokens run tokens subcommand: rotate
+func tokens(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "rotate" {
+		return errors.New("usage: eventnative tokens rotate -file tokens.json -id token_id [-secret client|server|signing|all]")
+	}
+
+	return rotateToken(args[1:], out)
+}
+
+//rotateToken generate new secrets of the token in the local tokens JSON file ({"tokens": [...]}).
+//Servers which load server.auth from the file (file://) reload tokens automatically
+func rotateToken(args []string, out io.Writer) error {
+	fs := newFlagSet("tokens rotate", out)
+	filePath := fs.String("file", "", "Tokens JSON file path")
+	tokenId := fs.String("id", "", "Token id")
+	secretType := fs.String("secret", allSecretsType, "Rotated secret: client, server, signing or all (configured client and server secrets)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(map[string]string{"file": *filePath, "id": *tokenId}); err != nil {
+		return err
+	}
+
+	switch *secretType {
+	case clientSecretType, serverSecretType, signingSecretType, allSecretsType:
+	default:
+		return fmt.Errorf("unknown secret type: %s. Available: [%s, %s, %s, %s]", *secretType, clientSecretType, serverSecretType, signingSecretType, allSecretsType)
+	}
+
+	fileInfo, err := os.Stat(*filePath)
+	if err != nil {
+		return err
+	}
+	payload, err := ioutil.ReadFile(*filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading tokens file: %v", err)
+	}
+
+	tokensPayload := &authorization.TokensPayload{}
+	if err := json.Unmarshal(payload, tokensPayload); err != nil {
+		return fmt.Errorf("Error parsing tokens file. Payload must be json with 'tokens' key: %v", err)
+	}
+
+	var token *authorization.Token
+	for i := range tokensPayload.Tokens {
+		if tokensPayload.Tokens[i].Id == *tokenId {
+			token = &tokensPayload.Tokens[i]
+			break
+		}
+	}
+	if token == nil {
+		return fmt.Errorf("token [%s] wasn't found", *tokenId)
+	}
+
+	rotated := 0
+	if *secretType == clientSecretType || (*secretType == allSecretsType && token.ClientSecret != "") {
+		token.ClientSecret = uuid.New()
+		fmt.Fprintf(out, "client_secret: %s\n", token.ClientSecret)
+		rotated++
+	}
+	if *secretType == serverSecretType || (*secretType == allSecretsType && token.ServerSecret != "") {
+		token.ServerSecret = uuid.New()
+		fmt.Fprintf(out, "server_secret: %s\n", token.ServerSecret)
+		rotated++
+	}
+	if *secretType == signingSecretType {
+		token.SigningSecret = uuid.New()
+		fmt.Fprintf(out, "signing_secret: %s\n", token.SigningSecret)
+		rotated++
+	}
+	if rotated == 0 {
+		return fmt.Errorf("token [%s] doesn't have client or server secrets", *tokenId)
+	}
+
+	result, err := json.MarshalIndent(tokensPayload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error marshalling tokens: %v", err)
+	}
+	if err := ioutil.WriteFile(*filePath, result, fileInfo.Mode()); err != nil {
+		return fmt.Errorf("Error writing tokens file: %v", err)
+	}
+
+	fmt.Fprintf(out, "Token [%s] has been rotated in [%s]\n", *tokenId, *filePath)
+	return nil
+}
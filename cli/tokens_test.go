@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRotateToken(t *testing.T) {
+	file, err := ioutil.TempFile("", "tokens*.json")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`{"tokens":[{"id":"id1","client_secret":"c1","server_secret":"s1","origins":["*.site.com"]},{"id":"id2","server_secret":"s2"}]}`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	out := &bytes.Buffer{}
+	require.NoError(t, tokens([]string{"rotate", "-file", file.Name(), "-id", "id1"}, out))
+	require.NoError(t, tokens([]string{"rotate", "-file", file.Name(), "-id", "id2", "-secret", "client"}, out))
+	require.EqualError(t, tokens([]string{"rotate", "-file", file.Name(), "-id", "id3"}, out), "token [id3] wasn't found")
+	require.Error(t, tokens([]string{"rotate", "-file", file.Name(), "-id", "id1", "-secret", "unknown"}, out))
+
+	payload, err := ioutil.ReadFile(file.Name())
+	require.NoError(t, err)
+	tokensPayload := &authorization.TokensPayload{}
+	require.NoError(t, json.Unmarshal(payload, tokensPayload))
+	require.Len(t, tokensPayload.Tokens, 2)
+
+	first := tokensPayload.Tokens[0]
+	require.NotEqual(t, "c1", first.ClientSecret)
+	require.NotEqual(t, "s1", first.ServerSecret)
+	require.Equal(t, []string{"*.site.com"}, first.Origins)
+	require.Contains(t, out.String(), "client_secret: "+first.ClientSecret)
+
+	second := tokensPayload.Tokens[1]
+	require.NotEmpty(t, second.ClientSecret)
+	require.Equal(t, "s2", second.ServerSecret)
+}
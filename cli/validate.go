@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/spf13/viper"
+	"io"
+	"sort"
+)
+
+const (
+	validationOk   = "OK"
+	validationFail = "FAIL"
+	validationWarn = "WARN"
+	validationSkip = "SKIP"
+
+	defaultAuthReloadSec = 30
+)
+
+//ValidationResult is a validation result of one config entity (destination, source or authorization)
+type ValidationResult struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+//ValidationReport is a structured config validation report
+type ValidationReport struct {
+	Valid   bool                `json:"valid"`
+	Results []*ValidationResult `json:"results"`
+}
+
+func (vr *ValidationReport) add(kind, name string, err error) {
+	if err != nil {
+		vr.Results = append(vr.Results, &ValidationResult{Kind: kind, Name: name, Status: validationFail, Message: err.Error()})
+	} else {
+		vr.Results = append(vr.Results, &ValidationResult{Kind: kind, Name: name, Status: validationOk})
+	}
+}
+
+func (vr *ValidationReport) addStatus(kind, name, status, message string) {
+	vr.Results = append(vr.Results, &ValidationResult{Kind: kind, Name: name, Status: status, Message: message})
+}
+
+//Problems return failed results count
+func (vr *ValidationReport) Problems() int {
+	problems := 0
+	for _, result := range vr.Results {
+		if result.Status == validationFail {
+			problems++
+		}
+	}
+	return problems
+}
+
+//Write report in text (one line per result) or json format
+func (vr *ValidationReport) Write(out io.Writer, format string) error {
+	vr.Valid = vr.Problems() == 0
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(vr)
+	}
+
+	for _, result := range vr.Results {
+		if result.Message != "" {
+			fmt.Fprintf(out, "[%s] %s [%s]: %s\n", result.Status, result.Kind, result.Name, result.Message)
+		} else {
+			fmt.Fprintf(out, "[%s] %s [%s]\n", result.Status, result.Kind, result.Name)
+		}
+	}
+	return nil
+}
+
+//validate check destinations and sources configurations of a local config file without connecting to them
+func validate(args []string, out io.Writer) error {
+	fs := newFlagSet("validate", out)
+	configPath := fs.String("cfg", "", "Config file path")
+	format := fs.String("format", "text", "Report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(map[string]string{"cfg": *configPath}); err != nil {
+		return err
+	}
+
+	config := viper.New()
+	config.SetConfigFile(*configPath)
+	if err := config.ReadInConfig(); err != nil {
+		return fmt.Errorf("Error reading config file: %v", err)
+	}
+
+	report := checkConfig(config, false)
+	if err := report.Write(out, *format); err != nil {
+		return err
+	}
+	if problems := report.Problems(); problems > 0 {
+		return fmt.Errorf("config has %d problem(s)", problems)
+	}
+
+	if *format != "json" {
+		fmt.Fprintln(out, "Config is valid")
+	}
+	return nil
+}
+
+//Validate check the application config (global viper): authorization tokens, destinations (with connection tests) and
+//sources (with drivers creation), write report into out (text or json format) and return process exit code
+func Validate(out io.Writer, format string) int {
+	report := checkConfig(viper.GetViper(), true)
+	checkAuthorization(report)
+
+	if err := report.Write(out, format); err != nil {
+		fmt.Fprintf(out, "Error writing validation report: %v\n", err)
+		return 1
+	}
+	if report.Problems() > 0 {
+		return 1
+	}
+	return 0
+}
+
+//validateConfig write every destination and source validation result into out and return invalid count
+func validateConfig(config *viper.Viper, out io.Writer) int {
+	report := checkConfig(config, false)
+	report.Write(out, "text")
+	return report.Problems()
+}
+
+//checkConfig validate destinations and sources configurations. If testConnections is true: connections to valid
+//destinations are tested and drivers of valid sources are created
+func checkConfig(config *viper.Viper, testConnections bool) *ValidationReport {
+	report := &ValidationReport{Results: []*ValidationResult{}}
+	destinationIds := checkDestinations(config, report, testConnections)
+
+	if sourcesViper := config.Sub("sources"); sourcesViper != nil {
+		sourcesConfig := map[string]drivers.SourceConfig{}
+		if err := sourcesViper.Unmarshal(&sourcesConfig); err != nil {
+			report.add("sources", "*", err)
+		}
+
+		names := make([]string, 0, len(sourcesConfig))
+		for name := range sourcesConfig {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sourceConfig := sourcesConfig[name]
+			err := drivers.Validate(name, &sourceConfig)
+			if err == nil && destinationIds != nil {
+				for _, destinationId := range sourceConfig.Destinations {
+					if !destinationIds[destinationId] {
+						err = errors.New("unknown destination: " + destinationId)
+						break
+					}
+				}
+			}
+			if err == nil && testConnections {
+				err = testSource(name, &sourceConfig)
+			}
+			report.add("source", name, err)
+		}
+	}
+
+	return report
+}
+
+//checkDestinations validate inline destinations configurations (and test connections if testConnections is true)
+//return destinations ids or nil if destinations aren't configured inline
+func checkDestinations(config *viper.Viper, report *ValidationReport, testConnections bool) map[string]bool {
+	//destinations might be loaded from URL or file: only inline ones are validated
+	var destinationIds map[string]bool
+	if destinationsViper := config.Sub("destinations"); destinationsViper != nil {
+		destinationIds = map[string]bool{}
+		destinationsConfig := map[string]storages.DestinationConfig{}
+		if err := destinationsViper.Unmarshal(&destinationsConfig); err != nil {
+			report.add("destinations", "*", err)
+		}
+
+		names := make([]string, 0, len(destinationsConfig))
+		for name := range destinationsConfig {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			destinationIds[name] = true
+			destinationConfig := destinationsConfig[name]
+			err := storages.Validate(name, destinationConfig)
+			if err == nil && testConnections {
+				if destinationConfig.Mode == "" {
+					destinationConfig.Mode = storages.BatchMode
+				}
+				err = storages.TestConnection(&destinationConfig)
+			}
+			report.add("destination", name, err)
+		}
+	} else if source := config.GetString("destinations"); source != "" {
+		report.addStatus("destinations", "*", validationSkip, "destinations are loaded from "+source)
+	}
+
+	return destinationIds
+}
+
+//testSource create source drivers (they connect to the source) and close them
+func testSource(name string, sourceConfig *drivers.SourceConfig) error {
+	driversPerCollection, err := drivers.Create(context.Background(), name, sourceConfig)
+	for _, driver := range driversPerCollection {
+		driver.Close()
+	}
+	return err
+}
+
+//checkAuthorization load authorization tokens (from config, file or URL)
+func checkAuthorization(report *ValidationReport) {
+	if !viper.IsSet("server.auth_reload_sec") {
+		viper.Set("server.auth_reload_sec", defaultAuthReloadSec)
+	}
+
+	service, err := authorization.NewService()
+	if err != nil {
+		report.add("authorization", "server.auth", err)
+		return
+	}
+
+	if !viper.IsSet("server.auth") {
+		report.addStatus("authorization", "server.auth", validationWarn, "tokens aren't configured: token will be generated on every start")
+		return
+	}
+	if service.IsGenerated() {
+		report.add("authorization", "server.auth", errors.New("tokens can't be loaded"))
+		return
+	}
+
+	report.addStatus("authorization", "server.auth", validationOk, fmt.Sprintf("%d token(s)", len(service.GetAllTokenIds())))
+}
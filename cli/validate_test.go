@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const validateTestConfig = `
+destinations:
+  pg:
+    type: postgres
+    mode: stream
+  unknown_dst:
+    type: mysql
+  wrong_mode:
+    type: clickhouse
+    mode: realtime
+sources:
+  my_sql:
+    type: firebase
+    destinations: [ pg ]
+    collections: [ users ]
+  wrong_destination:
+    type: firebase
+    destinations: [ bigquery ]
+    collections: [ users ]
+  empty_collections:
+    type: firebase
+    destinations: [ pg ]
+`
+
+func TestValidateConfig(t *testing.T) {
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(strings.NewReader(validateTestConfig)))
+
+	out := &bytes.Buffer{}
+	require.Equal(t, 4, validateConfig(config, out))
+
+	output := out.String()
+	require.Contains(t, output, "[OK] destination [pg]")
+	require.Contains(t, output, "[FAIL] destination [unknown_dst]: Unknown destination type: mysql")
+	require.Contains(t, output, "[FAIL] destination [wrong_mode]: Unknown destination mode: realtime")
+	require.Contains(t, output, "[OK] source [my_sql]")
+	require.Contains(t, output, "[FAIL] source [wrong_destination]: unknown destination: bigquery")
+	require.Contains(t, output, "[FAIL] source [empty_collections]: collections are empty")
+}
+
+func TestValidationReportJson(t *testing.T) {
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(strings.NewReader(validateTestConfig)))
+
+	report := checkConfig(config, false)
+	out := &bytes.Buffer{}
+	require.NoError(t, report.Write(out, "json"))
+
+	parsed := &ValidationReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), parsed))
+	require.False(t, parsed.Valid)
+	require.Len(t, parsed.Results, 6)
+	require.Equal(t, &ValidationResult{Kind: "destination", Name: "pg", Status: validationOk}, parsed.Results[0])
+}
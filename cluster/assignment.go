@@ -0,0 +1,35 @@
+package cluster
+
+import (
+	"hash/fnv"
+)
+
+//GetOwner return instance which is responsible for the key (rendezvous hashing)
+//so that tasks are distributed evenly and only a small part of keys are reassigned when instances are changed
+//return empty string if instances are empty
+func GetOwner(instances []string, key string) string {
+	var owner string
+	var maxWeight uint64
+	for _, instance := range instances {
+		weight := hash(instance + "_" + key)
+		if owner == "" || weight > maxWeight || (weight == maxWeight && instance < owner) {
+			owner = instance
+			maxWeight = weight
+		}
+	}
+
+	return owner
+}
+
+//fnv hash with murmur3 finalizer for spreading close keys (e.g. collection1, collection2) across all bits
+func hash(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
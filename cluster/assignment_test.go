@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetOwner(t *testing.T) {
+	require.Equal(t, "", GetOwner(nil, "source_collection"))
+	require.Equal(t, "node1", GetOwner([]string{"node1"}, "source_collection"))
+
+	//order independent
+	require.Equal(t, GetOwner([]string{"node1", "node2", "node3"}, "source_collection"), GetOwner([]string{"node3", "node1", "node2"}, "source_collection"))
+
+	//distributed across all instances
+	instances := []string{"node1", "node2", "node3"}
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		counts[GetOwner(instances, fmt.Sprintf("source_collection%d", i))]++
+	}
+	require.Equal(t, 3, len(counts))
+
+	//only keys of removed instance are reassigned
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("source_collection%d", i)
+		owner := GetOwner(instances, key)
+		if owner != "node3" {
+			require.Equal(t, owner, GetOwner([]string{"node1", "node2"}, key))
+		}
+	}
+}
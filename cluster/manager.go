@@ -2,4 +2,10 @@ package cluster
 
 type Manager interface {
 	GetInstances() ([]string, error)
+
+	//PushSyncTask send sync task of source collection to the instance
+	//error is returned if the instance hasn't taken the task (the caller might run it itself)
+	PushSyncTask(instance, sourceId, collection string) error
+	//WatchSyncTasks call handler on every sync task which was pushed to the current instance
+	WatchSyncTasks(handler func(sourceId, collection string))
 }
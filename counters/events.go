@@ -6,6 +6,9 @@ import (
 	"time"
 )
 
+//EventsStatuses are events counters statuses
+var EventsStatuses = []string{meta.SuccessStatus, meta.SkipStatus, meta.ErrorStatus}
+
 var eventsInstance *Events
 
 type Events struct {
@@ -16,26 +19,68 @@ func InitEvents(storage meta.Storage) {
 	eventsInstance = &Events{storage: storage}
 }
 
-func SuccessEvents(destinationId string, value int) {
+//SuccessEvents count events which have been stored in the destination
+//events are counted per destination and per token (if tokenId isn't empty)
+func SuccessEvents(destinationId, tokenId string, value int) {
+	DestinationStage(destinationId, StageLoaded, value)
+	incrementEvents(destinationId, tokenId, meta.SuccessStatus, value)
+}
+
+//SkipEvents count events which have been skipped by the destination (e.g. empty table name, late events or without consent)
+func SkipEvents(destinationId, tokenId string, value int) {
+	incrementEvents(destinationId, tokenId, meta.SkipStatus, value)
+}
+
+//ErrorEvents count events which haven't been stored in the destination because of errors
+func ErrorEvents(destinationId, tokenId string, value int) {
+	DestinationStage(destinationId, StageErrored, value)
+	incrementEvents(destinationId, tokenId, meta.ErrorStatus, value)
+}
+
+func incrementEvents(destinationId, tokenId, status string, value int) {
+	if value <= 0 {
+		return
+	}
 	if eventsInstance == nil {
 		logging.Warnf("Counters instance isn't configured!")
 		return
 	}
 
-	err := eventsInstance.storage.SuccessEvents(destinationId, time.Now().UTC(), value)
-	if err != nil {
-		logging.SystemErrorf("Error updating success events counter destination [%s] value [%d]: %v", destinationId, value, err)
+	now := time.Now().UTC()
+	if err := eventsInstance.storage.IncrementEventsCounter(meta.DestinationNamespace, destinationId, status, now, value); err != nil {
+		logging.SystemErrorf("Error updating %s events counter destination [%s] value [%d]: %v", status, destinationId, value, err)
+	}
+	if tokenId == "" {
+		return
+	}
+	if err := eventsInstance.storage.IncrementEventsCounter(meta.TokenNamespace, tokenId, status, now, value); err != nil {
+		logging.SystemErrorf("Error updating %s events counter token [%s] value [%d]: %v", status, tokenId, value, err)
 	}
 }
 
-func ErrorEvents(destinationId string, value int) {
+//GetEventsCounters return events counters by bucket start time (hour or day granularity) and status
+//for namespace (meta.DestinationNamespace or meta.TokenNamespace) id
+func GetEventsCounters(namespace, id, granularity string, start, end time.Time) (map[time.Time]map[string]int, error) {
+	result := map[time.Time]map[string]int{}
 	if eventsInstance == nil {
-		logging.Warnf("Counters instance isn't configured!")
-		return
+		return result, nil
 	}
 
-	err := eventsInstance.storage.ErrorEvents(destinationId, time.Now().UTC(), value)
-	if err != nil {
-		logging.SystemErrorf("Error updating error events counter destination [%s] value [%d]: %v", destinationId, value, err)
+	for _, status := range EventsStatuses {
+		counters, err := eventsInstance.storage.GetEventsCounters(namespace, id, status, granularity, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for bucket, value := range counters {
+			statuses, ok := result[bucket]
+			if !ok {
+				statuses = map[string]int{}
+				result[bucket] = statuses
+			}
+			statuses[status] += value
+		}
 	}
+
+	return result, nil
 }
@@ -0,0 +1,229 @@
+package counters
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sync"
+	"time"
+)
+
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+
+	quotaWarningPercent = 80
+	quotasFlushEvery    = 10 * time.Second
+)
+
+var quotasInstance *Quotas
+
+//QuotaExceededError is returned when token events quota of the current period has been exhausted
+type QuotaExceededError struct {
+	TokenId string
+	Quota   string
+	Limit   int
+	//ResetAt is a start of the next quota period (UTC)
+	ResetAt time.Time
+}
+
+func (qee *QuotaExceededError) Error() string {
+	return fmt.Sprintf("Token [%s] %s quota of %d events has been exceeded. Quota will be reset at %s", qee.TokenId, qee.Quota, qee.Limit, qee.ResetAt.Format(timestamp.Layout))
+}
+
+//quotaUsage is a token events counter of one quota period
+type quotaUsage struct {
+	tokenId string
+	quota   string
+	//period is yyyymmdd for daily and yyyymm for monthly quotas
+	period string
+	limit  int
+	//total is the last flushed (cluster-wide) counter + unflushed events of this node
+	total     int
+	unflushed int
+}
+
+//Quotas counts tokens events per UTC day and month and rejects events over the configured quotas
+//counters are flushed into meta storage periodically and shared between cluster nodes,
+//so quotas are enforced cluster-wide with the flush interval delay. Without meta storage counters are kept only in memory
+type Quotas struct {
+	sync.Mutex
+
+	storage meta.Storage
+	shared  bool
+	//usages by token id and period
+	usages map[string]*quotaUsage
+	job    *scheduler.Job
+}
+
+//InitQuotas create Quotas instance and schedule flushing
+func InitQuotas(storage meta.Storage) *Quotas {
+	quotasInstance = &Quotas{
+		storage: storage,
+		shared:  storage != nil && storage.Type() != meta.DummyType,
+		usages:  map[string]*quotaUsage{},
+	}
+	quotasInstance.job = scheduler.Add("quotas_flush", scheduler.Every(quotasFlushEvery), quotasInstance.flush)
+	return quotasInstance
+}
+
+//ConsumeQuota count event into token quotas of the current day and month
+//return *QuotaExceededError if one of them has been exhausted (event must be rejected and isn't counted)
+func ConsumeQuota(tokenId string, config *authorization.QuotaConfig) error {
+	if quotasInstance == nil || config == nil || (config.Daily <= 0 && config.Monthly <= 0) {
+		return nil
+	}
+
+	return quotasInstance.consume(tokenId, config, time.Now().UTC())
+}
+
+func (q *Quotas) consume(tokenId string, config *authorization.QuotaConfig, now time.Time) error {
+	q.Lock()
+	defer q.Unlock()
+
+	var usages []*quotaUsage
+	if config.Daily > 0 {
+		usages = append(usages, q.getUsage(tokenId, QuotaDaily, now, config.Daily))
+	}
+	if config.Monthly > 0 {
+		usages = append(usages, q.getUsage(tokenId, QuotaMonthly, now, config.Monthly))
+	}
+
+	for _, usage := range usages {
+		if usage.total >= usage.limit {
+			return &QuotaExceededError{TokenId: tokenId, Quota: usage.quota, Limit: usage.limit, ResetAt: quotaResetAt(usage.quota, now)}
+		}
+	}
+
+	for _, usage := range usages {
+		usage.total++
+		usage.unflushed++
+		//shared counters thresholds are checked on flush: only one node crosses a threshold
+		if !q.shared {
+			notifyThresholds(usage, usage.total-1, usage.total)
+		}
+	}
+
+	return nil
+}
+
+//getUsage return usage of the current period (must be called with lock)
+func (q *Quotas) getUsage(tokenId, quota string, now time.Time, limit int) *quotaUsage {
+	period := quotaPeriod(quota, now)
+	key := tokenId + ":" + period
+	usage, ok := q.usages[key]
+	if !ok {
+		usage = &quotaUsage{tokenId: tokenId, quota: quota, period: period}
+		q.usages[key] = usage
+	}
+	//quota might be changed on tokens reloading
+	usage.limit = limit
+
+	return usage
+}
+
+//flush write unflushed counters into meta storage and update totals with values of other cluster nodes
+//previous periods usages are removed after flushing
+func (q *Quotas) flush() error {
+	now := time.Now().UTC()
+	type delta struct {
+		usage *quotaUsage
+		value int
+	}
+
+	q.Lock()
+	var deltas []delta
+	for key, usage := range q.usages {
+		current := usage.period == quotaPeriod(usage.quota, now)
+		if q.shared && (current || usage.unflushed > 0) {
+			deltas = append(deltas, delta{usage: usage, value: usage.unflushed})
+			usage.unflushed = 0
+		}
+		if !current {
+			delete(q.usages, key)
+		}
+	}
+	q.Unlock()
+
+	var multiErr error
+	for _, d := range deltas {
+		total, err := q.storage.IncrementQuotaUsage(d.usage.tokenId, d.usage.period, d.value, quotaTTL(d.usage.quota))
+		q.Lock()
+		if err != nil {
+			d.usage.unflushed += d.value
+			q.Unlock()
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error flushing token [%s] quota usage [%s]: %v", d.usage.tokenId, d.usage.period, err))
+			continue
+		}
+		d.usage.total = total + d.usage.unflushed
+		q.Unlock()
+
+		notifyThresholds(d.usage, total-d.value, total)
+	}
+
+	return multiErr
+}
+
+//Close flush counters and stop flushing
+func (q *Quotas) Close() error {
+	q.job.Stop()
+	return q.flush()
+}
+
+//notifyThresholds send notifications if warning threshold or quota limit is in (before, after]
+func notifyThresholds(usage *quotaUsage, before, after int) {
+	warning := (usage.limit*quotaWarningPercent + 99) / 100
+	for _, threshold := range []int{warning, usage.limit} {
+		if before >= threshold || after < threshold {
+			continue
+		}
+
+		exhausted := threshold == usage.limit
+		percent := quotaWarningPercent
+		if exhausted {
+			percent = 100
+		}
+		text := fmt.Sprintf("Token [%s] has used %d%% of %s quota: %d of %d events (period: %s)", usage.tokenId, percent, usage.quota, after, usage.limit, usage.period)
+		logging.Warn(text)
+		notifications.Quota(exhausted, text)
+		notifications.Lifecycle(notifications.QuotaThreshold, map[string]interface{}{
+			"token_id": usage.tokenId,
+			"quota":    usage.quota,
+			"period":   usage.period,
+			"limit":    usage.limit,
+			"used":     after,
+			"percent":  percent,
+		})
+	}
+}
+
+func quotaPeriod(quota string, now time.Time) string {
+	if quota == QuotaMonthly {
+		return now.Format(timestamp.MonthLayout)
+	}
+
+	return now.Format(timestamp.DayLayout)
+}
+
+//quotaResetAt return start of the next UTC day or month
+func quotaResetAt(quota string, now time.Time) time.Time {
+	if quota == QuotaMonthly {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+//quotaTTL return meta storage counter ttl: the period with a margin
+func quotaTTL(quota string) time.Duration {
+	if quota == QuotaMonthly {
+		return 62 * 24 * time.Hour
+	}
+
+	return 48 * time.Hour
+}
@@ -0,0 +1,86 @@
+package counters
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//quotasStorage is a shared meta storage: other cluster nodes usage is emulated with counters changes
+type quotasStorage struct {
+	meta.Dummy
+	counters map[string]int
+}
+
+func (qs *quotasStorage) IncrementQuotaUsage(tokenId, period string, value int, ttl time.Duration) (int, error) {
+	qs.counters[tokenId+":"+period] += value
+	return qs.counters[tokenId+":"+period], nil
+}
+
+func (qs *quotasStorage) Type() string {
+	return meta.RedisType
+}
+
+func TestQuotasLocal(t *testing.T) {
+	quotas := InitQuotas(&meta.Dummy{})
+	defer func() {
+		quotas.Close()
+		quotasInstance = nil
+	}()
+
+	now := time.Date(2020, 10, 17, 23, 59, 0, 0, time.UTC)
+	config := &authorization.QuotaConfig{Daily: 3, Monthly: 5}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, quotas.consume("token1", config, now))
+	}
+
+	err := quotas.consume("token1", config, now)
+	require.IsType(t, &QuotaExceededError{}, err)
+	quotaErr := err.(*QuotaExceededError)
+	require.Equal(t, QuotaDaily, quotaErr.Quota)
+	require.Equal(t, time.Date(2020, 10, 18, 0, 0, 0, 0, time.UTC), quotaErr.ResetAt)
+
+	//the next day: daily quota is reset, monthly quota isn't
+	nextDay := now.Add(time.Minute)
+	require.NoError(t, quotas.consume("token1", config, nextDay))
+	require.NoError(t, quotas.consume("token1", config, nextDay))
+	err = quotas.consume("token1", config, nextDay)
+	require.IsType(t, &QuotaExceededError{}, err)
+	require.Equal(t, QuotaMonthly, err.(*QuotaExceededError).Quota)
+	require.Equal(t, time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC), err.(*QuotaExceededError).ResetAt)
+
+	//other tokens aren't affected
+	require.NoError(t, quotas.consume("token2", config, nextDay))
+	require.NoError(t, ConsumeQuota("token1", nil))
+}
+
+func TestQuotasShared(t *testing.T) {
+	storage := &quotasStorage{counters: map[string]int{}}
+	quotas := InitQuotas(storage)
+	defer func() {
+		quotas.Close()
+		quotasInstance = nil
+	}()
+
+	now := time.Now().UTC()
+	config := &authorization.QuotaConfig{Daily: 10}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, quotas.consume("token1", config, now))
+	}
+	require.NoError(t, quotas.flush())
+	period := now.Format(timestamp.DayLayout)
+	require.Equal(t, 4, storage.counters["token1:"+period])
+
+	//other nodes have consumed 5 events
+	storage.counters["token1:"+period] += 5
+	require.NoError(t, quotas.flush())
+
+	require.NoError(t, quotas.consume("token1", config, now))
+	require.IsType(t, &QuotaExceededError{}, quotas.consume("token1", config, now))
+
+	require.NoError(t, quotas.flush())
+	require.Equal(t, 10, storage.counters["token1:"+period])
+}
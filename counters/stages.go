@@ -0,0 +1,163 @@
+package counters
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
+	"math"
+	"sync"
+	"time"
+)
+
+//Pipeline stages in processing order
+const (
+	//token stages
+	StageReceived = "received"
+	StageEnriched = "enriched"
+	//token and destination stage
+	StageLogged = "logged"
+	//destination stages
+	StageUploaded = "uploaded"
+	StageLoaded   = "loaded"
+	StageErrored  = "errored"
+	StageFallback = "fallback"
+
+	//EstimatedSuffix is a suffix of stages counters of sampled tokens events extrapolated by the sampling rate
+	EstimatedSuffix = "_estimated"
+
+	stagesFlushEvery = 10 * time.Second
+)
+
+var (
+	//TokenStages is an ordered list of token pipeline stages
+	TokenStages = []string{StageReceived, StageEnriched, StageLogged}
+	//DestinationStages is an ordered list of destination pipeline stages
+	DestinationStages = []string{StageLogged, StageUploaded, StageLoaded, StageErrored, StageFallback}
+)
+
+var stagesInstance *PipelineStages
+
+type stageKey struct {
+	id    string
+	stage string
+	hour  time.Time
+}
+
+//PipelineStages accumulates stages counters in memory and flushes them into meta storage periodically
+type PipelineStages struct {
+	sync.Mutex
+
+	storage meta.Storage
+	buffer  map[stageKey]int
+	//estimated counters are fractional: the remainder is kept till the next flush
+	estimated map[stageKey]float64
+	job       *scheduler.Job
+}
+
+//InitStages create PipelineStages instance and schedule flushing
+func InitStages(storage meta.Storage) *PipelineStages {
+	stagesInstance = &PipelineStages{storage: storage, buffer: map[stageKey]int{}, estimated: map[stageKey]float64{}}
+	stagesInstance.job = scheduler.Add("pipeline_stages_flush", scheduler.Every(stagesFlushEvery), stagesInstance.flush)
+	return stagesInstance
+}
+
+//TokenStage count events which have passed the stage for the token
+func TokenStage(tokenId, stage string, value int) {
+	pipelineStage(TokenPrefix+tokenId, stage, value)
+}
+
+//DestinationStage count events which have passed the stage for the destination
+func DestinationStage(destinationId, stage string, value int) {
+	pipelineStage(DestinationPrefix+destinationId, stage, value)
+}
+
+//TokenStageEstimated count estimated original events (sampled events multiplied by the sampling factor)
+//which have passed the stage for the token. Counted as <stage>_estimated
+func TokenStageEstimated(tokenId, stage string, estimated float64) {
+	pipelineStageEstimated(TokenPrefix+tokenId, stage, estimated)
+}
+
+//DestinationStageEstimated count estimated original events which have passed the stage for the destination
+func DestinationStageEstimated(destinationId, stage string, estimated float64) {
+	pipelineStageEstimated(DestinationPrefix+destinationId, stage, estimated)
+}
+
+func pipelineStageEstimated(id, stage string, estimated float64) {
+	if stagesInstance == nil || estimated <= 0 {
+		return
+	}
+
+	key := stageKey{id: id, stage: stage + EstimatedSuffix, hour: time.Now().UTC().Truncate(time.Hour)}
+	stagesInstance.Lock()
+	stagesInstance.estimated[key] += estimated
+	stagesInstance.Unlock()
+}
+
+func pipelineStage(id, stage string, value int) {
+	if stagesInstance == nil || value <= 0 {
+		return
+	}
+
+	key := stageKey{id: id, stage: stage, hour: time.Now().UTC().Truncate(time.Hour)}
+	stagesInstance.Lock()
+	stagesInstance.buffer[key] += value
+	stagesInstance.Unlock()
+}
+
+//GetPipelineStages return events counters by hour and stage for id (token_tokenId or destination_destinationId)
+//Counters of the last stagesFlushEvery period might be absent
+func GetPipelineStages(id string, start, end time.Time) (map[time.Time]map[string]int, error) {
+	if stagesInstance == nil {
+		return map[time.Time]map[string]int{}, nil
+	}
+
+	return stagesInstance.storage.GetPipelineStages(id, start, end)
+}
+
+//flush write buffered counters into meta storage. Failed counters are kept in the buffer
+//integer parts of estimated counters are flushed as well
+func (ps *PipelineStages) flush() error {
+	ps.Lock()
+	buffer := ps.buffer
+	ps.buffer = map[stageKey]int{}
+	for key, estimated := range ps.estimated {
+		whole := math.Floor(estimated)
+		if whole >= 1 {
+			buffer[key] += int(whole)
+		}
+		if remainder := estimated - whole; remainder > 0 {
+			ps.estimated[key] = remainder
+		} else {
+			delete(ps.estimated, key)
+		}
+	}
+	ps.Unlock()
+
+	var lastErr error
+	for key, value := range buffer {
+		if err := ps.storage.IncrementPipelineStage(key.id, key.stage, key.hour, value); err != nil {
+			lastErr = err
+			ps.Lock()
+			ps.buffer[key] += value
+			ps.Unlock()
+		}
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("Error updating pipeline stages counters: %v", lastErr)
+	}
+	return nil
+}
+
+//Close stop flushing and flush the rest counters (estimated remainders are rounded)
+func (ps *PipelineStages) Close() error {
+	ps.job.Stop()
+
+	ps.Lock()
+	for key, estimated := range ps.estimated {
+		ps.estimated[key] = math.Round(estimated)
+	}
+	ps.Unlock()
+
+	return ps.flush()
+}
@@ -0,0 +1,50 @@
+package counters
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type stagesStorage struct {
+	meta.Dummy
+	counters map[string]int
+}
+
+func (ss *stagesStorage) IncrementPipelineStage(id, stage string, hour time.Time, value int) error {
+	ss.counters[id+":"+stage] += value
+	return nil
+}
+
+func TestPipelineStages(t *testing.T) {
+	storage := &stagesStorage{counters: map[string]int{}}
+	stages := InitStages(storage)
+	defer func() { stagesInstance = nil }()
+
+	TokenStage("token1", StageReceived, 1)
+	TokenStage("token1", StageReceived, 1)
+	DestinationStage("dest1", StageLoaded, 5)
+	DestinationStage("dest1", StageErrored, 0)
+
+	require.NoError(t, stages.Close())
+	require.Equal(t, map[string]int{"token_token1:received": 2, "destination_dest1:loaded": 5}, storage.counters)
+}
+
+func TestPipelineStagesEstimated(t *testing.T) {
+	storage := &stagesStorage{counters: map[string]int{}}
+	stages := InitStages(storage)
+	defer func() { stagesInstance = nil }()
+
+	TokenStageEstimated("token1", StageEnriched, 2.5)
+	TokenStageEstimated("token1", StageEnriched, 2)
+	DestinationStageEstimated("dest1", StageLoaded, 0)
+
+	require.NoError(t, stages.flush())
+	require.Equal(t, map[string]int{"token_token1:enriched_estimated": 4}, storage.counters)
+
+	//remainder is kept till the next flush
+	TokenStageEstimated("token1", StageEnriched, 0.5)
+	require.NoError(t, stages.Close())
+	require.Equal(t, map[string]int{"token_token1:enriched_estimated": 5}, storage.counters)
+}
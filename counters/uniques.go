@@ -0,0 +1,107 @@
+package counters
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/safego"
+	"sync"
+	"time"
+)
+
+const (
+	TokenPrefix       = "token_"
+	DestinationPrefix = "destination_"
+)
+
+var uniquesInstance *Uniques
+
+//Uniques writes anonymous ids into meta storage HyperLogLogs asynchronously
+type Uniques struct {
+	storage             meta.Storage
+	anonymousIdJsonPath *jsonutils.JsonPath
+	usersCh             chan *uniqueUser
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//channel dto
+type uniqueUser struct {
+	ids         []string
+	anonymousId string
+	now         time.Time
+}
+
+//InitUniques create Uniques instance and start goroutine for async writes
+func InitUniques(storage meta.Storage, anonymousIdNode string) *Uniques {
+	uniquesInstance = &Uniques{
+		storage:             storage,
+		anonymousIdJsonPath: jsonutils.NewJsonPath(anonymousIdNode),
+		usersCh:             make(chan *uniqueUser, 1000000),
+		done:                make(chan struct{}),
+	}
+	uniquesInstance.start()
+	return uniquesInstance
+}
+
+func (u *Uniques) start() {
+	safego.RunWithRestart(func() {
+		for {
+			select {
+			case <-u.done:
+				return
+			case uu := <-u.usersCh:
+				for _, id := range uu.ids {
+					if err := u.storage.AddUniqueUser(id, uu.anonymousId, uu.now); err != nil {
+						logging.SystemErrorf("Error updating unique users counter [%s]: %v", id, err)
+					}
+				}
+			}
+		}
+	})
+}
+
+//Close stop writing goroutine. Not written unique users are lost
+func (u *Uniques) Close() error {
+	u.closeOnce.Do(func() {
+		close(u.done)
+	})
+	return nil
+}
+
+//UniqueUser extract anonymous id from event and put it into channel for counting per token and per every destination
+func UniqueUser(tokenId string, destinationIds []string, event map[string]interface{}) {
+	if uniquesInstance == nil {
+		return
+	}
+
+	anonymousIdIface, ok := uniquesInstance.anonymousIdJsonPath.Get(event)
+	if !ok || anonymousIdIface == nil {
+		return
+	}
+	anonymousId := fmt.Sprint(anonymousIdIface)
+	if anonymousId == "" {
+		return
+	}
+
+	ids := []string{TokenPrefix + tokenId}
+	for _, destinationId := range destinationIds {
+		ids = append(ids, DestinationPrefix+destinationId)
+	}
+
+	select {
+	case uniquesInstance.usersCh <- &uniqueUser{ids: ids, anonymousId: anonymousId, now: time.Now().UTC()}:
+	default:
+	}
+}
+
+//CountUniqueUsers return approximated unique users count in [start, end] days range
+func CountUniqueUsers(id string, start, end time.Time) (int, error) {
+	if uniquesInstance == nil {
+		return 0, nil
+	}
+
+	return uniquesInstance.storage.CountUniqueUsers(id, start, end)
+}
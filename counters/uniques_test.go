@@ -0,0 +1,68 @@
+package counters
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testUniquesStorage struct {
+	meta.Dummy
+
+	sync.Mutex
+	users map[string]map[string]bool
+}
+
+func (tus *testUniquesStorage) AddUniqueUser(id, anonymousId string, now time.Time) error {
+	tus.Lock()
+	defer tus.Unlock()
+	if _, ok := tus.users[id]; !ok {
+		tus.users[id] = map[string]bool{}
+	}
+	tus.users[id][anonymousId] = true
+	return nil
+}
+
+func (tus *testUniquesStorage) CountUniqueUsers(id string, start, end time.Time) (int, error) {
+	tus.Lock()
+	defer tus.Unlock()
+	return len(tus.users[id]), nil
+}
+
+func TestUniques(t *testing.T) {
+	defer func() { uniquesInstance = nil }()
+
+	storage := &testUniquesStorage{users: map[string]map[string]bool{}}
+	uniques := InitUniques(storage, "/eventn_ctx/user/anonymous_id")
+
+	UniqueUser("token1", []string{"dest1", "dest2"}, map[string]interface{}{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "user1"}}})
+	UniqueUser("token1", []string{"dest1"}, map[string]interface{}{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "user2"}}})
+	//without anonymous id
+	UniqueUser("token1", []string{"dest1"}, map[string]interface{}{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{}}})
+
+	require.Eventually(t, func() bool {
+		count, _ := CountUniqueUsers("destination_dest1", time.Now(), time.Now())
+		return count == 2
+	}, time.Second, 10*time.Millisecond)
+
+	tokenCount, err := CountUniqueUsers("token_token1", time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 2, tokenCount)
+	destinationCount, err := CountUniqueUsers("destination_dest2", time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, destinationCount)
+
+	//writing goroutine is stopped after Close
+	require.NoError(t, uniques.Close())
+	require.NoError(t, uniques.Close())
+	time.Sleep(50 * time.Millisecond)
+
+	UniqueUser("token1", nil, map[string]interface{}{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "user3"}}})
+	time.Sleep(50 * time.Millisecond)
+	tokenCount, err = CountUniqueUsers("token_token1", time.Now(), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 2, tokenCount)
+	require.Len(t, uniques.usersCh, 1)
+}
@@ -0,0 +1,110 @@
+package dedup
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/metrics"
+	"time"
+)
+
+const (
+	GlobalScope       = "global"
+	DestinationPrefix = "destination_"
+
+	defaultWindow = time.Hour
+)
+
+//storage is nil until Init: all events are considered unique
+var (
+	storage      meta.Storage
+	globalWindow *Window
+)
+
+//Config is a deduplication configuration (server.dedup globally or dedup per destination)
+type Config struct {
+	Enabled   bool `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	WindowSec int  `mapstructure:"window_sec" json:"window_sec,omitempty" yaml:"window_sec,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.WindowSec < 0 {
+		return errors.New("dedup window_sec can't be negative")
+	}
+
+	return nil
+}
+
+//Window drops events with eventn_ctx_event_id which has been already seen in the scope within ttl
+type Window struct {
+	scope string
+	ttl   time.Duration
+}
+
+//NewWindow return Window or nil if config is nil or deduplication is disabled
+func NewWindow(scope string, config *Config) (*Window, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	ttl := defaultWindow
+	if config.WindowSec > 0 {
+		ttl = time.Duration(config.WindowSec) * time.Second
+	}
+
+	return &Window{scope: scope, ttl: ttl}, nil
+}
+
+//Init set meta storage for all windows and create the global one
+func Init(metaStorage meta.Storage, globalConfig *Config) error {
+	window, err := NewWindow(GlobalScope, globalConfig)
+	if err != nil {
+		return err
+	}
+
+	if window != nil && metaStorage.Type() == meta.DummyType {
+		logging.Warnf("Events deduplication requires meta storage (Redis) configuration. All events will be considered unique")
+	}
+
+	storage = metaStorage
+	globalWindow = window
+	return nil
+}
+
+//IsGlobalDuplicate return true if event has been already received within the global window
+func IsGlobalDuplicate(eventId string) bool {
+	return globalWindow.IsDuplicate(eventId, "")
+}
+
+//TTL return deduplication window duration
+func (w *Window) TTL() time.Duration {
+	return w.ttl
+}
+
+//IsDuplicate return true if event with eventId has been already seen in the window scope
+//from another origin (origin is e.g. batch file name so repeated uploads of the same file aren't duplicates)
+//return false if window is nil, event id is empty or meta storage is unavailable
+func (w *Window) IsDuplicate(eventId, origin string) bool {
+	if w == nil || storage == nil || eventId == "" {
+		return false
+	}
+
+	firstSeen, err := storage.MarkEventSeen(w.scope, eventId, origin, w.ttl)
+	if err != nil {
+		logging.SystemErrorf("Error checking event [%s] duplication in scope [%s]: %v", eventId, w.scope, err)
+		return false
+	}
+
+	if !firstSeen {
+		metrics.DuplicateEvent(w.scope)
+	}
+	return !firstSeen
+}
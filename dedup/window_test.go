@@ -0,0 +1,75 @@
+package dedup
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type seenStorage struct {
+	meta.Dummy
+	seen map[string]string
+}
+
+func (ss *seenStorage) MarkEventSeen(scope, eventId, origin string, ttl time.Duration) (bool, error) {
+	key := scope + ":" + eventId
+	seenOrigin, ok := ss.seen[key]
+	if !ok {
+		ss.seen[key] = origin
+		return true, nil
+	}
+
+	return origin != "" && seenOrigin == origin, nil
+}
+
+func (ss *seenStorage) Type() string {
+	return meta.RedisType
+}
+
+func TestNewWindow(t *testing.T) {
+	window, err := NewWindow(GlobalScope, nil)
+	require.NoError(t, err)
+	require.Nil(t, window)
+
+	window, err = NewWindow(GlobalScope, &Config{WindowSec: 60})
+	require.NoError(t, err)
+	require.Nil(t, window, "disabled config")
+
+	window, err = NewWindow(GlobalScope, &Config{Enabled: true})
+	require.NoError(t, err)
+	require.Equal(t, defaultWindow, window.TTL())
+
+	window, err = NewWindow(GlobalScope, &Config{Enabled: true, WindowSec: 60})
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, window.TTL())
+
+	_, err = NewWindow(GlobalScope, &Config{Enabled: true, WindowSec: -1})
+	require.Error(t, err)
+}
+
+func TestIsDuplicate(t *testing.T) {
+	require.NoError(t, Init(&seenStorage{seen: map[string]string{}}, &Config{Enabled: true}))
+	defer func() {
+		storage = nil
+		globalWindow = nil
+	}()
+
+	require.False(t, IsGlobalDuplicate("event1"))
+	require.True(t, IsGlobalDuplicate("event1"))
+	require.False(t, IsGlobalDuplicate("event2"))
+	require.False(t, IsGlobalDuplicate(""))
+	require.False(t, IsGlobalDuplicate(""))
+
+	window, err := NewWindow(DestinationPrefix+"pg", &Config{Enabled: true})
+	require.NoError(t, err)
+	//destination scope is independent from the global one
+	require.False(t, window.IsDuplicate("event1", "incoming.tok=token1-2021-01-01T00-00-00.000.log"))
+	//retry of the same file
+	require.False(t, window.IsDuplicate("event1", "incoming.tok=token1-2021-01-01T00-00-00.000.log"))
+	//the same event in another file
+	require.True(t, window.IsDuplicate("event1", "incoming.tok=token1-2021-01-01T00-01-00.000.log"))
+
+	var disabled *Window
+	require.False(t, disabled.IsDuplicate("event1", ""))
+}
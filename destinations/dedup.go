@@ -0,0 +1,36 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/dedup"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+)
+
+//DedupConsumer passes only events which haven't been seen by the destination within deduplication window
+//to the underlying consumer
+type DedupConsumer struct {
+	consumer events.Consumer
+	window   *dedup.Window
+}
+
+func NewDedupConsumer(consumer events.Consumer, window *dedup.Window) events.Consumer {
+	if window == nil {
+		return consumer
+	}
+
+	return &DedupConsumer{consumer: consumer, window: window}
+}
+
+func (dc *DedupConsumer) Consume(event map[string]interface{}, tokenId string) {
+	eventId := events.ExtractEventId(event)
+	if dc.window.IsDuplicate(eventId, "") {
+		logging.Debugf("Event [%s] has been already consumed within deduplication window. Skipped", eventId)
+		return
+	}
+
+	dc.consumer.Consume(event, tokenId)
+}
+
+func (dc *DedupConsumer) Close() error {
+	return dc.consumer.Close()
+}
@@ -0,0 +1,191 @@
+package destinations
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	HealthOk          = "ok"
+	HealthFailed      = "failed"
+	HealthPending     = "pending"
+	HealthUnsupported = "unsupported"
+
+	defaultHealthIntervalSec = 60
+	defaultHealthTimeoutSec  = 10
+)
+
+//HealthConfig is a destinations connectivity checks configuration (server.destinations_health)
+type HealthConfig struct {
+	Disabled    bool `mapstructure:"disabled" json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	IntervalSec int  `mapstructure:"interval_sec" json:"interval_sec,omitempty" yaml:"interval_sec,omitempty"`
+	TimeoutSec  int  `mapstructure:"timeout_sec" json:"timeout_sec,omitempty" yaml:"timeout_sec,omitempty"`
+}
+
+//HealthStatus is the last destination connectivity check result
+type HealthStatus struct {
+	DestinationId string `json:"destination_id"`
+	Type          string `json:"type,omitempty"`
+	//Status is ok, failed, pending (destination is being initialized or hasn't been checked yet) or unsupported
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	CheckedAt string `json:"checked_at,omitempty"`
+	LastOkAt  string `json:"last_ok_at,omitempty"`
+	//Failures is a number of consecutive failed checks
+	Failures int `json:"failures,omitempty"`
+}
+
+//HealthChecker periodically checks destinations connectivity with cheap requests (ping query, bucket HEAD)
+//so broken credentials are discovered before flushing
+type HealthChecker struct {
+	sync.RWMutex
+
+	timeout  time.Duration
+	statuses map[string]*HealthStatus
+	//running is true while destination check is in progress (hung checks aren't started again)
+	running map[string]bool
+}
+
+func newHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout, statuses: map[string]*HealthStatus{}, running: map[string]bool{}}
+}
+
+//checkAll run checks of all destinations concurrently and wait for them (at most timeout)
+//statuses of removed destinations are dropped. Return number of failed checks
+func (hc *HealthChecker) checkAll(storageProxies map[string]events.StorageProxy) int {
+	hc.Lock()
+	for id := range hc.statuses {
+		if _, ok := storageProxies[id]; !ok {
+			delete(hc.statuses, id)
+		}
+	}
+	hc.Unlock()
+
+	wg := sync.WaitGroup{}
+	for id, storageProxy := range storageProxies {
+		wg.Add(1)
+		go func(id string, storageProxy events.StorageProxy) {
+			defer wg.Done()
+			hc.check(id, storageProxy)
+		}(id, storageProxy)
+	}
+	wg.Wait()
+
+	hc.RLock()
+	defer hc.RUnlock()
+	failed := 0
+	for _, status := range hc.statuses {
+		if status.Status == HealthFailed {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+//check run destination health check and update its status
+func (hc *HealthChecker) check(id string, storageProxy events.StorageProxy) {
+	storage, ok := storageProxy.Get()
+	if !ok {
+		hc.update(id, "", HealthPending, nil, 0)
+		return
+	}
+	checker, ok := storage.(storages.HealthChecker)
+	if !ok {
+		hc.update(id, storage.Type(), HealthUnsupported, nil, 0)
+		return
+	}
+
+	hc.Lock()
+	if hc.running[id] {
+		hc.Unlock()
+		return
+	}
+	hc.running[id] = true
+	hc.Unlock()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		err := checker.HealthCheck()
+		hc.Lock()
+		delete(hc.running, id)
+		hc.Unlock()
+		result <- err
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-time.After(hc.timeout):
+		err = fmt.Errorf("health check hasn't been finished in %s", hc.timeout)
+	}
+
+	status := HealthOk
+	if err != nil {
+		status = HealthFailed
+	}
+	hc.update(id, storage.Type(), status, err, time.Since(start))
+}
+
+func (hc *HealthChecker) update(id, destinationType, status string, err error, latency time.Duration) {
+	hc.Lock()
+	defer hc.Unlock()
+
+	healthStatus, ok := hc.statuses[id]
+	if !ok {
+		healthStatus = &HealthStatus{DestinationId: id}
+		hc.statuses[id] = healthStatus
+	}
+	previous := healthStatus.Status
+
+	healthStatus.Type = destinationType
+	healthStatus.Status = status
+	healthStatus.Error = ""
+	if status == HealthPending || status == HealthUnsupported {
+		return
+	}
+
+	now := time.Now().UTC().Format(timestamp.Layout)
+	healthStatus.LatencyMs = latency.Milliseconds()
+	healthStatus.CheckedAt = now
+	if err != nil {
+		healthStatus.Error = err.Error()
+		healthStatus.Failures++
+		if previous != HealthFailed {
+			logging.Errorf("[%s] Destination health check failed: %v", id, err)
+		}
+	} else {
+		healthStatus.LastOkAt = now
+		healthStatus.Failures = 0
+		if previous == HealthFailed {
+			logging.Infof("[%s] Destination health check succeeded after failures", id)
+		}
+	}
+	metrics.DestinationHealth(id, err == nil, latency)
+}
+
+//Statuses return copies of the last check results ordered by destination id
+func (hc *HealthChecker) Statuses() []*HealthStatus {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	result := make([]*HealthStatus, 0, len(hc.statuses))
+	for _, status := range hc.statuses {
+		statusCopy := *status
+		result = append(result, &statusCopy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DestinationId < result[j].DestinationId
+	})
+
+	return result
+}
@@ -0,0 +1,91 @@
+package destinations
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//healthStorage is a storage with configurable connectivity check
+type healthStorage struct {
+	events.Storage
+	check func() error
+}
+
+func (hs *healthStorage) Type() string {
+	return "test"
+}
+
+func (hs *healthStorage) HealthCheck() error {
+	return hs.check()
+}
+
+//unsupportedStorage is a storage without connectivity check
+type unsupportedStorage struct {
+	events.Storage
+}
+
+func (us *unsupportedStorage) Type() string {
+	return "unsupported"
+}
+
+type storageProxyMock struct {
+	storage events.Storage
+}
+
+func (spm *storageProxyMock) Get() (events.Storage, bool) {
+	return spm.storage, spm.storage != nil
+}
+
+func (spm *storageProxyMock) Close() error {
+	return nil
+}
+
+func TestHealthChecker(t *testing.T) {
+	var checkErr error
+	hang := make(chan struct{})
+	defer close(hang)
+
+	proxies := map[string]events.StorageProxy{
+		"ok":          &storageProxyMock{storage: &healthStorage{check: func() error { return nil }}},
+		"failed":      &storageProxyMock{storage: &healthStorage{check: func() error { return checkErr }}},
+		"hung":        &storageProxyMock{storage: &healthStorage{check: func() error { <-hang; return nil }}},
+		"unsupported": &storageProxyMock{storage: &unsupportedStorage{}},
+		"pending":     &storageProxyMock{},
+	}
+
+	checker := newHealthChecker(100 * time.Millisecond)
+	checkErr = errors.New("connection refused")
+	require.Equal(t, 2, checker.checkAll(proxies))
+
+	statuses := map[string]*HealthStatus{}
+	for _, status := range checker.Statuses() {
+		statuses[status.DestinationId] = status
+	}
+	require.Len(t, statuses, 5)
+	require.Equal(t, HealthOk, statuses["ok"].Status)
+	require.Equal(t, statuses["ok"].CheckedAt, statuses["ok"].LastOkAt)
+	require.Equal(t, HealthFailed, statuses["failed"].Status)
+	require.Equal(t, "connection refused", statuses["failed"].Error)
+	require.Equal(t, 1, statuses["failed"].Failures)
+	require.Equal(t, HealthFailed, statuses["hung"].Status)
+	require.Equal(t, "health check hasn't been finished in 100ms", statuses["hung"].Error)
+	require.Equal(t, HealthUnsupported, statuses["unsupported"].Status)
+	require.Equal(t, HealthPending, statuses["pending"].Status)
+
+	//hung check isn't started again, failed destination recovers, removed destination is dropped
+	checkErr = nil
+	delete(proxies, "ok")
+	require.Equal(t, 1, checker.checkAll(proxies))
+
+	statuses = map[string]*HealthStatus{}
+	for _, status := range checker.Statuses() {
+		statuses[status.DestinationId] = status
+	}
+	require.Len(t, statuses, 4)
+	require.Equal(t, HealthOk, statuses["failed"].Status)
+	require.Equal(t, 0, statuses["failed"].Failures)
+	require.Empty(t, statuses["failed"].Error)
+}
@@ -3,6 +3,7 @@ package destinations
 import (
 	"encoding/json"
 	"github.com/google/martian/log"
+	"github.com/jitsucom/eventnative/audit"
 	"github.com/jitsucom/eventnative/resources"
 	"github.com/jitsucom/eventnative/storages"
 )
@@ -30,3 +31,53 @@ func getHash(name string, destination storages.DestinationConfig) string {
 
 	return resources.GetHash(b)
 }
+
+//getSnapshot return destination configuration json without connection configurations and credentials:
+//only pipeline fields are copied (whitelist) and sensitive nested values are redacted as well
+//it is used for debugging events processing with different configurations
+func getSnapshot(name string, destination storages.DestinationConfig) []byte {
+	snapshot := storages.DestinationConfig{
+		OnlyTokens:         destination.OnlyTokens,
+		Type:               destination.Type,
+		Mode:               destination.Mode,
+		DataLayout:         destination.DataLayout,
+		UsersRecognition:   destination.UsersRecognition,
+		Enrichment:         destination.Enrichment,
+		BreakOnError:       destination.BreakOnError,
+		Timestamps:         destination.Timestamps,
+		LateEvents:         destination.LateEvents,
+		Consent:            destination.Consent,
+		IpAnonymization:    destination.IpAnonymization,
+		Sharding:           destination.Sharding,
+		ColumnsLimit:       destination.ColumnsLimit,
+		CircuitBreaker:     destination.CircuitBreaker,
+		StreamWorkers:      destination.StreamWorkers,
+		Batch:              destination.Batch,
+		Routing:            destination.Routing,
+		Dedup:              destination.Dedup,
+		Residency:          destination.Residency,
+		Compaction:         destination.Compaction,
+		Pool:               destination.Pool,
+		MaxInflightUploads: destination.MaxInflightUploads,
+	}
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Errorf("Error getting snapshot(marshalling) from [%s] destination: %v", name, err)
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		log.Errorf("Error getting snapshot(unmarshalling) from [%s] destination: %v", name, err)
+		return nil
+	}
+
+	b, err = json.Marshal(audit.Redact(fields))
+	if err != nil {
+		log.Errorf("Error getting snapshot(marshalling) from [%s] destination: %v", name, err)
+		return nil
+	}
+
+	return b
+}
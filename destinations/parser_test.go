@@ -0,0 +1,32 @@
+package destinations
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetSnapshot(t *testing.T) {
+	destination := storages.DestinationConfig{
+		Type:          "dynamodb",
+		Mode:          "stream",
+		StreamWorkers: 2,
+		DataLayout:    &storages.DataLayout{TableNameTemplate: "events"},
+		DataSource:    &adapters.DataSourceConfig{Host: "localhost", Password: "pwd"},
+		DynamoDB:      &adapters.DynamoDBConfig{AccessKeyID: "id", SecretKey: "secret"},
+		Druid:         &adapters.DruidConfig{Username: "user", Password: "pwd"},
+		GCS:           &adapters.GCSConfig{KeyFile: "key.json"},
+		Profiles:      &adapters.ProfilesConfig{},
+	}
+
+	snapshot := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(getSnapshot("dest", destination), &snapshot))
+	require.Equal(t, map[string]interface{}{
+		"type":           "dynamodb",
+		"mode":           "stream",
+		"stream_workers": float64(2),
+		"data_layout":    map[string]interface{}{"table_name_template": "events"},
+	}, snapshot)
+}
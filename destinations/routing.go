@@ -0,0 +1,30 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/routing"
+)
+
+//RoutedConsumer passes only events which match destination routing rules to the underlying consumer
+type RoutedConsumer struct {
+	consumer events.Consumer
+	rules    *routing.Rules
+}
+
+func NewRoutedConsumer(consumer events.Consumer, rules *routing.Rules) events.Consumer {
+	if rules == nil {
+		return consumer
+	}
+
+	return &RoutedConsumer{consumer: consumer, rules: rules}
+}
+
+func (rc *RoutedConsumer) Consume(event map[string]interface{}, tokenId string) {
+	if rc.rules.Match(event) {
+		rc.consumer.Consume(event, tokenId)
+	}
+}
+
+func (rc *RoutedConsumer) Close() error {
+	return rc.consumer.Close()
+}
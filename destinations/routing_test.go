@@ -0,0 +1,42 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/routing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testConsumer struct {
+	consumed []map[string]interface{}
+}
+
+func (tc *testConsumer) Consume(event map[string]interface{}, tokenId string) {
+	tc.consumed = append(tc.consumed, event)
+}
+
+func (tc *testConsumer) Close() error {
+	return nil
+}
+
+func TestRouting(t *testing.T) {
+	rules, err := routing.ParseRules([]string{"event_type == 'purchase'"})
+	require.NoError(t, err)
+
+	consumer := &testConsumer{}
+	routed := NewRoutedConsumer(consumer, rules)
+	routed.Consume(map[string]interface{}{"event_type": "pageview"}, "token1")
+	routed.Consume(map[string]interface{}{"event_type": "purchase"}, "token1")
+	require.Equal(t, []map[string]interface{}{{"event_type": "purchase"}}, consumer.consumed)
+	require.Equal(t, consumer, NewRoutedConsumer(consumer, nil))
+
+	service := &Service{
+		unitsByName: map[string]*Unit{
+			"billing_postgres": {routingRules: rules},
+			"all_events":       {},
+		},
+		destinationsIdByTokenId: TokenizedIds{"token1": {"billing_postgres": true, "all_events": true}},
+	}
+	require.Equal(t, map[string]bool{"all_events": true}, service.GetDestinationIdsByEvent("token1", events.Event{"event_type": "pageview"}))
+	require.Equal(t, map[string]bool{"all_events": true, "billing_postgres": true}, service.GetDestinationIdsByEvent("token1", events.Event{"event_type": "purchase"}))
+}
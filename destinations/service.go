@@ -7,11 +7,19 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/dedup"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/notifications"
 	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/routing"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/secrets"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/spf13/viper"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -40,9 +48,13 @@ type Service struct {
 	monitorKeeper storages.MonitorKeeper
 	eventsCache   *caching.EventsCache
 	loggerFactory *logging.Factory
+	//destinations modes changes
+	transitions *Transitions
 
 	//map for holding all destinations for closing
 	unitsByName map[string]*Unit
+	//configs is the last applied destinations configuration
+	configs map[string]storages.DestinationConfig
 	//map for holding all loggers for closing
 	loggersUsageByTokenId map[string]*LoggerUsage
 
@@ -50,6 +62,11 @@ type Service struct {
 	consumersByTokenId      TokenizedConsumers
 	storagesByTokenId       TokenizedStorages
 	destinationsIdByTokenId TokenizedIds
+
+	monitoringJob *scheduler.Job
+	//health is nil if connectivity checks are disabled
+	health    *HealthChecker
+	healthJob *scheduler.Job
 }
 
 //only for tests
@@ -63,7 +80,7 @@ func NewTestService(consumersByTokenId TokenizedConsumers, storagesByTokenId Tok
 
 //NewService return loaded Service instance and call resources.Watcher() if destinations source is http url or file path
 func NewService(ctx context.Context, destinations *viper.Viper, destinationsSource, logEventPath string, monitorKeeper storages.MonitorKeeper,
-	eventsCache *caching.EventsCache, loggerFactory *logging.Factory,
+	eventsCache *caching.EventsCache, metaStorage meta.Storage, loggerFactory *logging.Factory,
 	storageFactoryMethod func(ctx context.Context, name, logEventPath string, destination storages.DestinationConfig,
 		monitorKeeper storages.MonitorKeeper, eventsCache *caching.EventsCache, loggerFactory *logging.Factory) (events.StorageProxy, *events.PersistentQueue, error)) (*Service, error) {
 	service := &Service{
@@ -73,6 +90,7 @@ func NewService(ctx context.Context, destinations *viper.Viper, destinationsSour
 		monitorKeeper:        monitorKeeper,
 		eventsCache:          eventsCache,
 		loggerFactory:        loggerFactory,
+		transitions:          NewTransitions(metaStorage),
 
 		unitsByName:           map[string]*Unit{},
 		loggersUsageByTokenId: map[string]*LoggerUsage{},
@@ -87,34 +105,52 @@ func NewService(ctx context.Context, destinations *viper.Viper, destinationsSour
 		return nil, errors.New("server.destinations_reload_sec can't be empty")
 	}
 
+	healthConfig := &HealthConfig{}
+	if err := viper.UnmarshalKey("server.destinations_health", healthConfig); err != nil {
+		return nil, fmt.Errorf("Error parsing server.destinations_health config: %v", err)
+	}
+
+	if err := service.load(destinations, destinationsSource, reloadSec); err != nil {
+		return nil, err
+	}
+
+	//background jobs are started only after successful initialization
+	service.startMonitoring()
+	service.startHealthChecks(healthConfig)
+
+	return service, nil
+}
+
+//load initialize destinations from viper config or start watching destinations source
+func (s *Service) load(destinations *viper.Viper, destinationsSource string, reloadSec int) error {
 	if destinations != nil {
 		dc := map[string]storages.DestinationConfig{}
 		if err := destinations.Unmarshal(&dc); err != nil {
 			logging.Error(marshallingErrorMsg, err)
-			return service, nil
+			return nil
 		}
 
-		service.init(dc)
+		s.init(dc)
 
-		if len(service.unitsByName) == 0 {
+		if len(s.unitsByName) == 0 {
 			logging.Errorf("Destinations are empty")
 		}
 
 	} else if destinationsSource != "" {
 		if strings.HasPrefix(destinationsSource, "http://") || strings.HasPrefix(destinationsSource, "https://") {
-			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, destinationsSource, resources.LoadFromHttp, service.updateDestinations, time.Duration(reloadSec)*time.Second)
+			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, destinationsSource, resources.LoadFromHttp, s.updateDestinations, time.Duration(reloadSec)*time.Second)
 		} else if strings.Contains(destinationsSource, "file://") {
-			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, strings.Replace(destinationsSource, "file://", "", 1), resources.LoadFromFile, service.updateDestinations, time.Duration(reloadSec)*time.Second)
+			appconfig.Instance.AuthorizationService.DestinationsForceReload = resources.Watch(serviceName, strings.Replace(destinationsSource, "file://", "", 1), resources.LoadFromFile, s.updateDestinations, time.Duration(reloadSec)*time.Second)
 		} else if strings.HasPrefix(destinationsSource, "{") && strings.HasSuffix(destinationsSource, "}") {
-			service.updateDestinations([]byte(destinationsSource))
+			s.updateDestinations([]byte(destinationsSource))
 		} else {
-			return nil, errors.New("Unknown destination source: " + destinationsSource)
+			return errors.New("Unknown destination source: " + destinationsSource)
 		}
 	} else {
 		logging.Errorf("Destinations aren't configured")
 	}
 
-	return service, nil
+	return nil
 }
 
 func (ds *Service) GetConsumers(tokenId string) (consumers []events.Consumer) {
@@ -138,6 +174,18 @@ func (ds *Service) GetStorageById(id string) (events.StorageProxy, bool) {
 	return unit.storage, true
 }
 
+//GetAllStorages return all destinations storage proxies by destination id
+func (ds *Service) GetAllStorages() map[string]events.StorageProxy {
+	ds.RLock()
+	defer ds.RUnlock()
+
+	result := map[string]events.StorageProxy{}
+	for id, unit := range ds.unitsByName {
+		result[id] = unit.storage
+	}
+	return result
+}
+
 func (ds *Service) GetStorages(tokenId string) (storages []events.StorageProxy) {
 	ds.RLock()
 	defer ds.RUnlock()
@@ -147,6 +195,16 @@ func (ds *Service) GetStorages(tokenId string) (storages []events.StorageProxy)
 	return
 }
 
+//GetUploadWindow return events window for uploading from log files into the destination and false
+//if events mustn't be uploaded (see Transitions)
+func (ds *Service) GetUploadWindow(destinationId string) (*UploadWindow, bool) {
+	if ds.transitions == nil {
+		return &UploadWindow{}, true
+	}
+
+	return ds.transitions.GetUploadWindow(destinationId, time.Now().UTC())
+}
+
 func (ds *Service) GetDestinationIds(tokenId string) map[string]bool {
 	ids := map[string]bool{}
 	ds.RLock()
@@ -157,7 +215,27 @@ func (ds *Service) GetDestinationIds(tokenId string) map[string]bool {
 	return ids
 }
 
+//GetDestinationIdsByEvent return ids of the token destinations which routing rules match the event
+func (ds *Service) GetDestinationIdsByEvent(tokenId string, event events.Event) map[string]bool {
+	ids := map[string]bool{}
+	ds.RLock()
+	defer ds.RUnlock()
+	for id := range ds.destinationsIdByTokenId[tokenId] {
+		if unit, ok := ds.unitsByName[id]; ok && !unit.routingRules.Match(event) {
+			continue
+		}
+		ids[id] = true
+	}
+	return ids
+}
+
 func (s *Service) updateDestinations(payload []byte) {
+	payload, err := secrets.ResolveJson(payload)
+	if err != nil {
+		logging.Errorf("Error resolving destinations secrets: %v", err)
+		return
+	}
+
 	dc, err := parseFromBytes(payload)
 	if err != nil {
 		logging.Error(marshallingErrorMsg, err)
@@ -171,11 +249,33 @@ func (s *Service) updateDestinations(payload []byte) {
 	}
 }
 
+//Update apply destinations configuration (e.g. from declarative state): changed destinations are recreated
+func (s *Service) Update(dc map[string]storages.DestinationConfig) {
+	s.init(dc)
+}
+
+//GetConfigs return the last applied destinations configuration
+func (s *Service) GetConfigs() map[string]storages.DestinationConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	configs := map[string]storages.DestinationConfig{}
+	for name, config := range s.configs {
+		configs[name] = config
+	}
+
+	return configs
+}
+
 //1. close and remove all destinations which don't exist in new config
 //2. recreate/create changed/new destinations
 func (s *Service) init(dc map[string]storages.DestinationConfig) {
 	StatusInstance.Reloading = true
 
+	s.Lock()
+	s.configs = dc
+	s.Unlock()
+
 	//close and remove non-existent (in new config)
 	toDelete := map[string]*Unit{}
 	for name, unit := range s.unitsByName {
@@ -193,6 +293,8 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 	}
 
 	// create or recreate
+	//recreated: destination name -> true if it existed before reloading (changed) and false if it is new
+	recreated := map[string]bool{}
 	newConsumers := TokenizedConsumers{}
 	newStorages := TokenizedStorages{}
 	newIds := TokenizedIds{}
@@ -227,6 +329,26 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 			//and call force reload on this service
 			continue
 		}
+		recreated[name] = ok
+
+		routingRules, err := routing.ParseRules(destination.Routing)
+		if err != nil {
+			logging.Errorf("[%s] Error initializing destination of type %s: %v", name, destination.Type, err)
+			continue
+		}
+
+		residency, err := routing.NewResidency(name, destination.OnlyTokens, destination.Residency, appconfig.Instance.GeoResolver)
+		if err != nil {
+			logging.Errorf("[%s] Error initializing destination of type %s: %v", name, destination.Type, err)
+			continue
+		}
+		routingRules = routingRules.WithResidency(residency)
+
+		dedupWindow, err := dedup.NewWindow(dedup.DestinationPrefix+name, destination.Dedup)
+		if err != nil {
+			logging.Errorf("[%s] Error initializing destination of type %s: %v", name, destination.Type, err)
+			continue
+		}
 
 		//create new
 		newStorageProxy, eventQueue, err := s.storageFactoryMethod(s.ctx, name, s.logEventPath, destination, s.monitorKeeper, s.eventsCache, s.loggerFactory)
@@ -235,13 +357,23 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 			continue
 		}
 
+		routing.RegisterStrict(residency)
 		s.unitsByName[name] = &Unit{
-			eventQueue: eventQueue,
-			storage:    newStorageProxy,
-			tokenIds:   destination.OnlyTokens,
-			hash:       hash,
+			eventQueue:   eventQueue,
+			storage:      newStorageProxy,
+			routingRules: routingRules,
+			tokenIds:     destination.OnlyTokens,
+			hash:         hash,
+		}
+
+		if s.eventsCache != nil {
+			s.eventsCache.SetConfigSnapshot(name, hash, getSnapshot(name, destination))
 		}
 
+		s.transitions.Update(name, destination.Mode, time.Now().UTC())
+		//events from log files which were written before batch -> stream transition are still uploaded
+		_, backfill := s.transitions.GetUploadWindow(name, time.Now().UTC())
+
 		//create:
 		//  1 logger per token id
 		//  1 queue per destination id
@@ -251,7 +383,10 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 		for _, tokenId := range destination.OnlyTokens {
 			newIds.Add(tokenId, name)
 			if destination.Mode == storages.StreamMode {
-				newConsumers.Add(tokenId, name, eventQueue)
+				newConsumers.Add(tokenId, name, NewDedupConsumer(NewRoutedConsumer(eventQueue, routingRules), dedupWindow))
+				if backfill {
+					newStorages.Add(tokenId, name, newStorageProxy)
+				}
 			} else {
 				//get or create new logger
 				loggerUsage, ok := s.loggersUsageByTokenId[tokenId]
@@ -268,6 +403,7 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 				}
 
 				//add storage only if batch mode
+				//routing rules are applied by the storage processor because the logger is shared by all token destinations
 				newStorages.Add(tokenId, name, newStorageProxy)
 			}
 		}
@@ -280,6 +416,41 @@ func (s *Service) init(dc map[string]storages.DestinationConfig) {
 	s.Unlock()
 
 	StatusInstance.Reloading = false
+
+	s.notifyReloaded(recreated, toDelete)
+}
+
+//notifyReloaded send config_reloaded lifecycle event with added, changed, removed and failed (not created) destinations
+//does nothing if destinations weren't changed
+func (s *Service) notifyReloaded(recreated map[string]bool, removed map[string]*Unit) {
+	if len(recreated) == 0 && len(removed) == 0 {
+		return
+	}
+
+	added, changed, failed, removedNames := []string{}, []string{}, []string{}, []string{}
+	for name, existed := range recreated {
+		if _, ok := s.unitsByName[name]; !ok {
+			failed = append(failed, name)
+		} else if existed {
+			changed = append(changed, name)
+		} else {
+			added = append(added, name)
+		}
+	}
+	for name := range removed {
+		removedNames = append(removedNames, name)
+	}
+	for _, names := range [][]string{added, changed, failed, removedNames} {
+		sort.Strings(names)
+	}
+
+	notifications.Lifecycle(notifications.ConfigReloaded, map[string]interface{}{
+		"component": serviceName,
+		"added":     added,
+		"changed":   changed,
+		"removed":   removedNames,
+		"failed":    failed,
+	})
 }
 
 //remove destination from all collections and close it
@@ -328,11 +499,138 @@ func (s *Service) remove(name string, unit *Unit) {
 		logging.Errorf("[%s] Error closing destination unit: %v", name, err)
 	}
 
+	routing.UnregisterStrict(name)
 	delete(s.unitsByName, name)
 	logging.Infof("[%s] has been removed!", name)
 }
 
+//startMonitoring schedule setting stream destinations queue size metrics every 20 seconds
+func (s *Service) startMonitoring() {
+	s.monitoringJob = scheduler.Add("destinations_monitoring", scheduler.Every(20*time.Second), func() error {
+		s.RLock()
+		for name, unit := range s.unitsByName {
+			if unit.eventQueue != nil {
+				metrics.DestinationQueueSize(name, unit.eventQueue.Size())
+				if unit.eventQueue.Priority() != nil {
+					metrics.DestinationPriorityQueueSize(name, unit.eventQueue.PrioritySize())
+				}
+			}
+		}
+		s.RUnlock()
+
+		return nil
+	})
+}
+
+//startHealthChecks schedule destinations connectivity checks (the first one is run immediately)
+func (s *Service) startHealthChecks(config *HealthConfig) {
+	if config.Disabled {
+		return
+	}
+
+	intervalSec := config.IntervalSec
+	if intervalSec <= 0 {
+		intervalSec = defaultHealthIntervalSec
+	}
+	timeoutSec := config.TimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = defaultHealthTimeoutSec
+	}
+
+	s.health = newHealthChecker(time.Duration(timeoutSec) * time.Second)
+	s.healthJob = scheduler.Add("destinations_health", scheduler.Every(time.Duration(intervalSec)*time.Second), func() error {
+		if failed := s.health.checkAll(s.GetAllStorages()); failed > 0 {
+			return fmt.Errorf("%d destinations health checks failed", failed)
+		}
+
+		return nil
+	})
+	s.healthJob.RunNow()
+}
+
+//GetHealthStatuses return the last connectivity checks results of all destinations ordered by destination id
+//(pending if destination hasn't been checked yet). Return nil if health checks are disabled
+func (s *Service) GetHealthStatuses() []*HealthStatus {
+	if s.health == nil {
+		return nil
+	}
+
+	checked := map[string]*HealthStatus{}
+	for _, status := range s.health.Statuses() {
+		checked[status.DestinationId] = status
+	}
+
+	statuses := []*HealthStatus{}
+	for id := range s.GetAllStorages() {
+		status, ok := checked[id]
+		if !ok {
+			status = &HealthStatus{DestinationId: id, Status: HealthPending}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].DestinationId < statuses[j].DestinationId
+	})
+
+	return statuses
+}
+
+//QueuesSize return the total number of events in stream destinations queues
+func (s *Service) QueuesSize() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	size := 0
+	for _, unit := range s.unitsByName {
+		if unit.eventQueue != nil {
+			size += unit.eventQueue.Size()
+		}
+	}
+
+	return size
+}
+
+//InFlightEvents return number of events which have been dequeued from streaming queues but haven't been processed yet
+func (s *Service) InFlightEvents() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	inFlight := 0
+	for _, unit := range s.unitsByName {
+		if unit.eventQueue != nil {
+			inFlight += unit.eventQueue.InFlight()
+		}
+	}
+
+	return inFlight
+}
+
+//RotateLoggers rotate incoming events log files of all tokens so they can be uploaded by the uploader right away
+func (s *Service) RotateLoggers() (multiErr error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for token, loggerUsage := range s.loggersUsageByTokenId {
+		rotator, ok := loggerUsage.logger.(interface{ Rotate() error })
+		if !ok {
+			continue
+		}
+		if err := rotator.Rotate(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error rotating logger for token [%s]: %v", token, err))
+		}
+	}
+
+	return
+}
+
 func (s *Service) Close() (multiErr error) {
+	if s.monitoringJob != nil {
+		s.monitoringJob.Stop()
+	}
+	if s.healthJob != nil {
+		s.healthJob.Stop()
+	}
+
 	for token, loggerUsage := range s.loggersUsageByTokenId {
 		if err := loggerUsage.logger.Close(); err != nil {
 			multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing logger for token [%s]: %v", token, err))
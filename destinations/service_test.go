@@ -7,6 +7,7 @@ import (
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
@@ -113,7 +114,7 @@ func TestServiceInit(t *testing.T) {
 
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
 	service, err := NewService(context.Background(), nil, mockDestinationsServer.URL, "/tmp",
-		nil, eventsCache, logging.NewFactory("/tmp", 5, false, nil, nil), createTestStorage)
+		nil, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), createTestStorage)
 	require.NoError(t, err)
 	require.NotNil(t, service)
 
@@ -302,7 +303,39 @@ func createTestStorage(ctx context.Context, name, logEventPath string, destinati
 	eventsCache *caching.EventsCache, loggerFactory *logging.Factory) (events.StorageProxy, *events.PersistentQueue, error) {
 	var eventQueue *events.PersistentQueue
 	if destination.Mode == storages.StreamMode {
-		eventQueue, _ = events.NewPersistentQueue(name, "/tmp")
+		eventQueue, _ = events.NewPersistentQueue(name, "/tmp", nil)
 	}
 	return &testProxyMock{}, eventQueue, nil
 }
+
+func TestNewServiceBackgroundJobs(t *testing.T) {
+	viper.Set("server.destinations_reload_sec", 1)
+
+	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
+	service, err := NewService(context.Background(), nil, "{}", "/tmp",
+		nil, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), createTestStorage)
+	require.NoError(t, err)
+	require.True(t, hasJob("destinations_monitoring"))
+	require.True(t, hasJob("destinations_health"))
+
+	require.NoError(t, service.Close())
+	require.False(t, hasJob("destinations_monitoring"))
+	require.False(t, hasJob("destinations_health"))
+
+	//jobs aren't started if the service isn't initialized
+	service, err = NewService(context.Background(), nil, "unknown", "/tmp",
+		nil, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), createTestStorage)
+	require.Error(t, err)
+	require.Nil(t, service)
+	require.False(t, hasJob("destinations_monitoring"))
+	require.False(t, hasJob("destinations_health"))
+}
+
+func hasJob(name string) bool {
+	for _, job := range scheduler.Jobs() {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
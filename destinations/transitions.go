@@ -0,0 +1,134 @@
+package destinations
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/storages"
+	"sync"
+	"time"
+)
+
+//BackfillPeriod is a period after batch -> stream transition when events from already rotated log files
+//(which were written before the transition) are still uploaded into the destination
+const BackfillPeriod = 7 * 24 * time.Hour
+
+//ModeTransition is a destination mode change (batch <-> stream)
+type ModeTransition struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+//destinationMode is a current destination mode with the last transition (nil if mode has never been changed)
+type destinationMode struct {
+	Mode       string          `json:"mode"`
+	Transition *ModeTransition `json:"transition,omitempty"`
+}
+
+//UploadWindow is a range of events _timestamp which must be uploaded into the destination from log files:
+//events received in stream mode mustn't be uploaded again (and vice versa). Zero From or To means unbounded
+type UploadWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+//Contains return true if t is in [From, To)
+func (uw *UploadWindow) Contains(t time.Time) bool {
+	return (uw.From.IsZero() || !t.Before(uw.From)) && (uw.To.IsZero() || t.Before(uw.To))
+}
+
+//IsUnbounded return true if all events must be uploaded
+func (uw *UploadWindow) IsUnbounded() bool {
+	return uw.From.IsZero() && uw.To.IsZero()
+}
+
+//Transitions keeps destinations modes in meta storage and detects mode changes for preventing events double loading
+type Transitions struct {
+	sync.RWMutex
+
+	storage meta.Storage
+	modes   map[string]*destinationMode
+}
+
+//NewTransitions load destinations modes from meta storage (storage might be nil)
+func NewTransitions(storage meta.Storage) *Transitions {
+	t := &Transitions{storage: storage, modes: map[string]*destinationMode{}}
+	if storage == nil {
+		return t
+	}
+
+	payloads, err := storage.GetDestinationModes()
+	if err != nil {
+		logging.Errorf("Error loading destinations modes from meta storage: %v", err)
+		return t
+	}
+
+	for destinationId, payload := range payloads {
+		mode := &destinationMode{}
+		if err := json.Unmarshal([]byte(payload), mode); err != nil {
+			logging.Errorf("Error parsing destination [%s] mode from meta storage: %v", destinationId, err)
+			continue
+		}
+		t.modes[destinationId] = mode
+	}
+
+	return t
+}
+
+//Update save destination mode and record transition if mode has been changed
+func (t *Transitions) Update(destinationId, mode string, now time.Time) {
+	if mode == "" {
+		mode = storages.BatchMode
+	}
+
+	t.Lock()
+	current, ok := t.modes[destinationId]
+	if ok && current.Mode == mode {
+		t.Unlock()
+		return
+	}
+
+	updated := &destinationMode{Mode: mode}
+	if ok {
+		updated.Transition = &ModeTransition{From: current.Mode, To: mode, ChangedAt: now}
+		logging.Infof("[%s] Destination mode has been changed: %s -> %s. Already logged events will be uploaded only once", destinationId, current.Mode, mode)
+	}
+	t.modes[destinationId] = updated
+	t.Unlock()
+
+	if t.storage == nil {
+		return
+	}
+	b, _ := json.Marshal(updated)
+	if err := t.storage.SaveDestinationMode(destinationId, string(b)); err != nil {
+		logging.SystemErrorf("Error saving destination [%s] mode into meta storage: %v", destinationId, err)
+	}
+}
+
+//GetUploadWindow return events window for uploading from log files and false if events mustn't be uploaded:
+//batch destination after stream -> batch transition: only events received after the transition
+//stream destination during BackfillPeriod after batch -> stream transition: only events received before the transition
+func (t *Transitions) GetUploadWindow(destinationId string, now time.Time) (*UploadWindow, bool) {
+	t.RLock()
+	defer t.RUnlock()
+
+	current, ok := t.modes[destinationId]
+	if !ok {
+		return &UploadWindow{}, true
+	}
+
+	transition := current.Transition
+	if current.Mode == storages.StreamMode {
+		if transition != nil && transition.From == storages.BatchMode && now.Sub(transition.ChangedAt) < BackfillPeriod {
+			return &UploadWindow{To: transition.ChangedAt}, true
+		}
+		return nil, false
+	}
+
+	if transition != nil && transition.From == storages.StreamMode {
+		return &UploadWindow{From: transition.ChangedAt}, true
+	}
+
+	return &UploadWindow{}, true
+}
@@ -0,0 +1,60 @@
+package destinations
+
+import (
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type modesStorage struct {
+	meta.Dummy
+	modes map[string]string
+}
+
+func (ms *modesStorage) SaveDestinationMode(destinationId, payload string) error {
+	ms.modes[destinationId] = payload
+	return nil
+}
+
+func (ms *modesStorage) GetDestinationModes() (map[string]string, error) {
+	return ms.modes, nil
+}
+
+func TestTransitions(t *testing.T) {
+	storage := &modesStorage{modes: map[string]string{}}
+	changedAt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	transitions := NewTransitions(storage)
+	transitions.Update("dest1", "", changedAt.Add(-time.Hour))
+	transitions.Update("dest2", storages.BatchMode, changedAt.Add(-time.Hour))
+
+	window, ok := transitions.GetUploadWindow("dest1", changedAt)
+	require.True(t, ok)
+	require.True(t, window.IsUnbounded())
+
+	//modes are reloaded from meta storage after restart
+	transitions = NewTransitions(storage)
+	transitions.Update("dest1", storages.BatchMode, changedAt)
+	transitions.Update("dest2", storages.StreamMode, changedAt)
+
+	window, ok = transitions.GetUploadWindow("dest1", changedAt)
+	require.True(t, ok)
+	require.True(t, window.IsUnbounded(), "mode wasn't changed")
+
+	//batch -> stream: only events before the transition during backfill period
+	window, ok = transitions.GetUploadWindow("dest2", changedAt.Add(time.Hour))
+	require.True(t, ok)
+	require.True(t, window.Contains(changedAt.Add(-time.Second)))
+	require.False(t, window.Contains(changedAt))
+	_, ok = transitions.GetUploadWindow("dest2", changedAt.Add(BackfillPeriod))
+	require.False(t, ok)
+
+	//stream -> batch: only events after the transition
+	transitions.Update("dest2", storages.BatchMode, changedAt.Add(2*time.Hour))
+	window, ok = transitions.GetUploadWindow("dest2", changedAt.Add(3*time.Hour))
+	require.True(t, ok)
+	require.False(t, window.Contains(changedAt.Add(time.Hour)))
+	require.True(t, window.Contains(changedAt.Add(2*time.Hour)))
+}
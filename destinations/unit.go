@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/routing"
 )
 
 //Unit holds storage bundle for closing at once
 type Unit struct {
 	eventQueue *events.PersistentQueue
 	storage    events.StorageProxy
+	//routingRules is nil if all token events are stored
+	routingRules *routing.Rules
 
 	tokenIds []string
 	hash     string
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Container entrypoint: runs preflight checks (config, meta storage, destinations connections, directories permissions)
+# and starts the server only if they pass. Set EVENTNATIVE_SKIP_PREFLIGHT=true to skip checks
+set -e
+
+CONFIG_ARG="-cfg=./res/eventnative.yaml"
+
+if [ "$EVENTNATIVE_SKIP_PREFLIGHT" != "true" ]; then
+  ./eventnative "$CONFIG_ARG" -cr=true -preflight "$@"
+fi
+
+exec ./eventnative "$CONFIG_ARG" -cr=true "$@"
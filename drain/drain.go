@@ -0,0 +1,181 @@
+package drain
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/jitsucom/eventnative/logging"
+	"sync"
+	"time"
+)
+
+const (
+	NotStarted = "not_started"
+	InProgress = "in_progress"
+	Drained    = "drained"
+	TimedOut   = "timed_out"
+
+	pollInterval = 500 * time.Millisecond
+	//ingestGrace is a time for finishing already accepted ingest requests
+	ingestGrace = time.Second
+)
+
+//Destinations rotate incoming events log files and return streaming queues size and number of events which are being inserted
+type Destinations interface {
+	RotateLoggers() error
+	QueuesSize() int
+	InFlightEvents() int
+}
+
+//Uploader upload all rotated log files right away
+type Uploader interface {
+	Flush() error
+}
+
+//Sources stop accepting new sync tasks and return running sync tasks count
+type Sources interface {
+	Drain()
+	RunningTasks() int
+}
+
+//Status is a drain progress
+type Status struct {
+	Status         string     `json:"status"`
+	Stage          string     `json:"stage,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	QueuesSize     int        `json:"queues_size"`
+	InFlightEvents int        `json:"in_flight_events"`
+	RunningTasks   int        `json:"running_tasks"`
+	Errors         []string   `json:"errors,omitempty"`
+}
+
+//Service drains the server before termination: stops accepting new events (see appstatus.AppStatus) and sync tasks,
+//finishes running sync tasks, waits for empty streaming queues and uploads all batch log files to destinations.
+//Waiting for sync tasks and streaming queues is limited with timeout (e.g. events are retried forever if a destination is unavailable)
+type Service struct {
+	sync.RWMutex
+
+	destinations Destinations
+	uploader     Uploader
+	sources      Sources
+	timeout      time.Duration
+
+	status Status
+	done   chan struct{}
+}
+
+func NewService(destinations Destinations, uploader Uploader, sources Sources, timeout time.Duration) *Service {
+	return &Service{destinations: destinations, uploader: uploader, sources: sources, timeout: timeout, status: Status{Status: NotStarted}}
+}
+
+//Start run draining in background if it hasn't been started yet (or has been timed out) and return channel which is closed on completion
+func (s *Service) Start() <-chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.done != nil && s.status.Status != TimedOut {
+		return s.done
+	}
+
+	logging.Info("* Server is draining.. *")
+	now := time.Now().UTC()
+	s.status = Status{Status: InProgress, StartedAt: &now}
+	s.done = make(chan struct{})
+	appstatus.Instance.Draining = true
+
+	go s.drain()
+
+	return s.done
+}
+
+//Status return current drain progress
+func (s *Service) Status() Status {
+	s.RLock()
+	defer s.RUnlock()
+
+	status := s.status
+	status.Errors = append([]string{}, s.status.Errors...)
+	if status.Status == InProgress {
+		status.QueuesSize = s.destinations.QueuesSize()
+		status.InFlightEvents = s.destinations.InFlightEvents()
+		status.RunningTasks = s.sources.RunningTasks()
+	}
+
+	return status
+}
+
+//IsDrained return true if draining has been completed
+func (s *Service) IsDrained() bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.status.Status == Drained
+}
+
+func (s *Service) drain() {
+	deadline := time.Now().Add(s.timeout)
+	s.sources.Drain()
+	time.Sleep(ingestGrace)
+
+	//sync tasks store objects directly into destinations
+	s.stage("sync_tasks")
+	for s.sources.RunningTasks() > 0 && time.Now().Before(deadline) {
+		time.Sleep(pollInterval)
+	}
+
+	//dequeued events are counted until they are inserted or put back to the queue
+	s.stage("streaming_queues")
+	for s.destinations.QueuesSize()+s.destinations.InFlightEvents() > 0 && time.Now().Before(deadline) {
+		time.Sleep(pollInterval)
+	}
+
+	s.stage("batch_files")
+	if err := s.destinations.RotateLoggers(); err != nil {
+		s.fail(fmt.Errorf("Error rotating log files: %v", err))
+	}
+	if err := s.uploader.Flush(); err != nil {
+		s.fail(fmt.Errorf("Error uploading log files: %v", err))
+	}
+
+	queuesSize, inFlightEvents, runningTasks := s.destinations.QueuesSize(), s.destinations.InFlightEvents(), s.sources.RunningTasks()
+	timedOut := queuesSize+inFlightEvents+runningTasks > 0
+	if timedOut {
+		s.fail(fmt.Errorf("Timeout %s: %d events in streaming queues, %d in-flight events and %d sync tasks remain",
+			s.timeout.String(), queuesSize, inFlightEvents, runningTasks))
+	}
+
+	s.Lock()
+	now := time.Now().UTC()
+	s.status.Status = Drained
+	if timedOut {
+		s.status.Status = TimedOut
+	}
+	s.status.Stage = ""
+	s.status.FinishedAt = &now
+	s.status.QueuesSize = queuesSize
+	s.status.InFlightEvents = inFlightEvents
+	s.status.RunningTasks = runningTasks
+	duration := now.Sub(*s.status.StartedAt)
+	close(s.done)
+	s.Unlock()
+
+	if timedOut {
+		logging.Warnf("Server draining has been timed out in %s", duration.String())
+	} else {
+		logging.Infof("Server has been drained in %s", duration.String())
+	}
+}
+
+func (s *Service) stage(name string) {
+	logging.Infof("Draining: %s", name)
+	s.Lock()
+	s.status.Stage = name
+	s.Unlock()
+}
+
+func (s *Service) fail(err error) {
+	logging.Errorf("Draining: %v", err)
+	s.Lock()
+	s.status.Errors = append(s.status.Errors, err.Error())
+	s.Unlock()
+}
@@ -0,0 +1,124 @@
+package drain
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testComponents struct {
+	sync.Mutex
+
+	queue    int
+	inFlight int
+	tasks    int
+	rotated  bool
+	flushed  bool
+	draining bool
+}
+
+func (tc *testComponents) RotateLoggers() error {
+	tc.Lock()
+	defer tc.Unlock()
+	tc.rotated = true
+	return nil
+}
+
+func (tc *testComponents) QueuesSize() int {
+	tc.Lock()
+	defer tc.Unlock()
+	if tc.queue > 0 {
+		tc.queue--
+	}
+	return tc.queue
+}
+
+func (tc *testComponents) InFlightEvents() int {
+	tc.Lock()
+	defer tc.Unlock()
+	return tc.inFlight
+}
+
+func (tc *testComponents) Flush() error {
+	tc.Lock()
+	defer tc.Unlock()
+	if !tc.rotated {
+		return errors.New("log files haven't been rotated")
+	}
+	tc.flushed = true
+	return errors.New("1 log files haven't been uploaded")
+}
+
+func (tc *testComponents) Drain() {
+	tc.Lock()
+	defer tc.Unlock()
+	tc.draining = true
+}
+
+func (tc *testComponents) RunningTasks() int {
+	tc.Lock()
+	defer tc.Unlock()
+	if tc.tasks > 0 {
+		tc.tasks--
+	}
+	return tc.tasks
+}
+
+func TestDrain(t *testing.T) {
+	defer func() { appstatus.Instance.Draining = false }()
+
+	components := &testComponents{queue: 3, tasks: 2}
+	service := NewService(components, components, components, time.Minute)
+	require.Equal(t, NotStarted, service.Status().Status)
+
+	done := service.Start()
+	require.True(t, appstatus.Instance.Draining)
+	require.Equal(t, InProgress, service.Status().Status)
+	//repeated calls return the same drain
+	require.True(t, done == service.Start())
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("drain hasn't been completed")
+	}
+
+	status := service.Status()
+	require.True(t, service.IsDrained())
+	require.Equal(t, Drained, status.Status)
+	require.NotNil(t, status.FinishedAt)
+	require.Equal(t, 0, status.QueuesSize)
+	require.Equal(t, 0, status.RunningTasks)
+	require.Equal(t, []string{"Error uploading log files: 1 log files haven't been uploaded"}, status.Errors)
+	require.True(t, components.draining)
+	require.True(t, components.flushed)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	defer func() { appstatus.Instance.Draining = false }()
+
+	//in-flight events are never processed (e.g. destination is unavailable)
+	components := &testComponents{inFlight: 2, tasks: 2}
+	service := NewService(components, components, components, 2*time.Second)
+
+	done := service.Start()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("drain hasn't been timed out")
+	}
+
+	status := service.Status()
+	require.False(t, service.IsDrained())
+	require.Equal(t, TimedOut, status.Status)
+	require.Equal(t, 2, status.InFlightEvents)
+	require.Equal(t, 0, status.RunningTasks)
+	require.Contains(t, status.Errors, "Timeout 2s: 0 events in streaming queues, 2 in-flight events and 0 sync tasks remain")
+
+	//timed out drain can be started again
+	require.False(t, done == service.Start())
+	require.Equal(t, InProgress, service.Status().Status)
+}
@@ -0,0 +1,443 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/scheduler"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	NewField         = "new_field"
+	MissingField     = "missing_field"
+	NewEventType     = "new_event_type"
+	MissingEventType = "missing_event_type"
+
+	defaultWindowMin          = 60
+	defaultMinEvents          = 100
+	defaultNewFieldRatio      = 0.1
+	defaultExpectedFieldRatio = 0.9
+	defaultMaxDepth           = 3
+	defaultEventTypeNode      = "/event_type"
+
+	//baseline is an exponential moving average of windows
+	baselineWeight = 0.5
+	//fields with lower baseline ratio are removed from baseline
+	minBaselineRatio = 0.01
+
+	maxFieldsPerEventType = 500
+	maxEventTypesPerToken = 200
+	maxAnomalies          = 100
+	unknownEventType      = "unknown"
+)
+
+var instance *Detector
+
+//Config is a server.statistics.drift configuration. Every window event types and fields presence (JSON paths up to max_depth)
+//are compared with baseline (previous windows): fields which appear in new_field_ratio of events and fields which were in
+//expected_field_ratio of events but disappear are reported. Only event types with at least min_events in window are checked
+type Config struct {
+	Enabled            bool    `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	WindowMin          int     `mapstructure:"window_min" json:"window_min,omitempty" yaml:"window_min,omitempty"`
+	MinEvents          int     `mapstructure:"min_events" json:"min_events,omitempty" yaml:"min_events,omitempty"`
+	NewFieldRatio      float64 `mapstructure:"new_field_ratio" json:"new_field_ratio,omitempty" yaml:"new_field_ratio,omitempty"`
+	ExpectedFieldRatio float64 `mapstructure:"expected_field_ratio" json:"expected_field_ratio,omitempty" yaml:"expected_field_ratio,omitempty"`
+	MaxDepth           int     `mapstructure:"max_depth" json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	EventTypeNode      string  `mapstructure:"event_type_node" json:"event_type_node,omitempty" yaml:"event_type_node,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.WindowMin < 0 {
+		return errors.New("window_min can't be negative")
+	}
+	if c.MinEvents < 0 {
+		return errors.New("min_events can't be negative")
+	}
+	if c.NewFieldRatio < 0 || c.NewFieldRatio > 1 {
+		return errors.New("new_field_ratio must be in [0, 1]")
+	}
+	if c.ExpectedFieldRatio < 0 || c.ExpectedFieldRatio > 1 {
+		return errors.New("expected_field_ratio must be in [0, 1]")
+	}
+	if c.MaxDepth < 0 {
+		return errors.New("max_depth can't be negative")
+	}
+
+	return nil
+}
+
+//Anomaly is a detected schema drift of token events
+type Anomaly struct {
+	Type          string    `json:"type"`
+	TokenId       string    `json:"token_id"`
+	EventType     string    `json:"event_type"`
+	Field         string    `json:"field,omitempty"`
+	Ratio         float64   `json:"ratio"`
+	BaselineRatio float64   `json:"baseline_ratio"`
+	Events        int       `json:"events"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+func (a *Anomaly) String() string {
+	switch a.Type {
+	case NewField:
+		return fmt.Sprintf("Token [%s] event type [%s]: new field [%s] appeared in %.0f%% of %d events", a.TokenId, a.EventType, a.Field, a.Ratio*100, a.Events)
+	case MissingField:
+		return fmt.Sprintf("Token [%s] event type [%s]: field [%s] is present in %.0f%% of %d events (expected %.0f%%). Instrumentation might be broken",
+			a.TokenId, a.EventType, a.Field, a.Ratio*100, a.Events, a.BaselineRatio*100)
+	case NewEventType:
+		return fmt.Sprintf("Token [%s]: new event type [%s] appeared: %d events", a.TokenId, a.EventType, a.Events)
+	default:
+		return fmt.Sprintf("Token [%s]: event type [%s] disappeared (expected ~%.0f events per window). Instrumentation might be broken",
+			a.TokenId, a.EventType, a.BaselineRatio)
+	}
+}
+
+//EventTypeStats is a baseline distribution of the event type: average events per window and fields presence ratios
+type EventTypeStats struct {
+	Events float64            `json:"events"`
+	Fields map[string]float64 `json:"fields"`
+}
+
+//window is a current window counters
+type window struct {
+	events int
+	fields map[string]int
+}
+
+type eventTypeState struct {
+	current        *window
+	baseline       map[string]float64
+	baselineEvents float64
+	windows        int
+}
+
+type tokenState struct {
+	sync.Mutex
+	eventTypes map[string]*eventTypeState
+	windows    int
+}
+
+//Detector tracks event types and fields distributions per token and detects schema drift every window
+type Detector struct {
+	sync.RWMutex
+
+	minEvents          int
+	newFieldRatio      float64
+	expectedFieldRatio float64
+	maxDepth           int
+	eventTypePath      *jsonutils.JsonPath
+
+	tokens    map[string]*tokenState
+	anomalies []*Anomaly
+
+	job *scheduler.Job
+}
+
+//Init create global Detector and schedule windows evaluation. Return nil if drift detection is disabled
+func Init(config *Config) (*Detector, error) {
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Error validating server.statistics.drift config: %v", err)
+	}
+
+	windowMin := config.WindowMin
+	if windowMin == 0 {
+		windowMin = defaultWindowMin
+	}
+
+	detector := newDetector(config)
+	detector.job = scheduler.Add("schema_drift", scheduler.Every(time.Duration(windowMin)*time.Minute), func() error {
+		detector.evaluate(time.Now().UTC())
+		return nil
+	})
+	instance = detector
+	logging.Infof("Schema drift detection is enabled with [%d] minutes window", windowMin)
+	return detector, nil
+}
+
+func newDetector(config *Config) *Detector {
+	detector := &Detector{
+		minEvents:          config.MinEvents,
+		newFieldRatio:      config.NewFieldRatio,
+		expectedFieldRatio: config.ExpectedFieldRatio,
+		maxDepth:           config.MaxDepth,
+		eventTypePath:      jsonutils.NewJsonPath(config.EventTypeNode),
+		tokens:             map[string]*tokenState{},
+	}
+	if detector.minEvents == 0 {
+		detector.minEvents = defaultMinEvents
+	}
+	if detector.newFieldRatio == 0 {
+		detector.newFieldRatio = defaultNewFieldRatio
+	}
+	if detector.expectedFieldRatio == 0 {
+		detector.expectedFieldRatio = defaultExpectedFieldRatio
+	}
+	if detector.maxDepth == 0 {
+		detector.maxDepth = defaultMaxDepth
+	}
+	if config.EventTypeNode == "" {
+		detector.eventTypePath = jsonutils.NewJsonPath(defaultEventTypeNode)
+	}
+
+	return detector
+}
+
+//IsEnabled return true if drift detection is initialized
+func IsEnabled() bool {
+	return instance != nil
+}
+
+//Track count event type and fields presence of the token event. Does nothing if drift detection is disabled
+func Track(tokenId string, event map[string]interface{}) {
+	if instance != nil {
+		instance.track(tokenId, event)
+	}
+}
+
+//GetTokenStats return baseline event types distributions of the token or nil if drift detection is disabled
+func GetTokenStats(tokenId string) map[string]*EventTypeStats {
+	if instance == nil {
+		return nil
+	}
+
+	return instance.tokenStats(tokenId)
+}
+
+//GetAnomalies return last detected anomalies of tokens (all tokens if empty) from the newest to the oldest
+func GetAnomalies(tokenIds map[string]bool) []*Anomaly {
+	if instance == nil {
+		return nil
+	}
+
+	return instance.getAnomalies(tokenIds)
+}
+
+func (d *Detector) track(tokenId string, event map[string]interface{}) {
+	eventType := unknownEventType
+	if value, ok := d.eventTypePath.Get(event); ok && value != nil {
+		eventType = fmt.Sprint(value)
+	}
+
+	d.RLock()
+	ts, ok := d.tokens[tokenId]
+	d.RUnlock()
+	if !ok {
+		d.Lock()
+		ts, ok = d.tokens[tokenId]
+		if !ok {
+			ts = &tokenState{eventTypes: map[string]*eventTypeState{}}
+			d.tokens[tokenId] = ts
+		}
+		d.Unlock()
+	}
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	state, ok := ts.eventTypes[eventType]
+	if !ok {
+		if len(ts.eventTypes) >= maxEventTypesPerToken {
+			return
+		}
+		state = &eventTypeState{baseline: map[string]float64{}}
+		ts.eventTypes[eventType] = state
+	}
+	if state.current == nil {
+		state.current = &window{fields: map[string]int{}}
+	}
+
+	state.current.events++
+	d.collectFields("", 1, event, state.current.fields)
+}
+
+//collectFields increment presence counters of object fields JSON paths up to max depth
+func (d *Detector) collectFields(prefix string, depth int, object map[string]interface{}, fields map[string]int) {
+	for key, value := range object {
+		path := prefix + "/" + key
+		if nested, ok := value.(map[string]interface{}); ok && depth < d.maxDepth {
+			d.collectFields(path, depth+1, nested, fields)
+			continue
+		}
+
+		if _, ok := fields[path]; !ok && len(fields) >= maxFieldsPerEventType {
+			continue
+		}
+		fields[path]++
+	}
+}
+
+//evaluate compare current windows with baselines, report anomalies and update baselines
+func (d *Detector) evaluate(now time.Time) {
+	d.RLock()
+	tokens := make(map[string]*tokenState, len(d.tokens))
+	for tokenId, ts := range d.tokens {
+		tokens[tokenId] = ts
+	}
+	d.RUnlock()
+
+	var anomalies []*Anomaly
+	for tokenId, ts := range tokens {
+		ts.Lock()
+		for eventType, state := range ts.eventTypes {
+			anomalies = append(anomalies, d.evaluateEventType(tokenId, eventType, ts.windows > 0, state, now)...)
+			if state.baselineEvents == 0 && len(state.baseline) == 0 && state.current == nil {
+				delete(ts.eventTypes, eventType)
+			}
+		}
+		ts.windows++
+		ts.Unlock()
+	}
+
+	if len(anomalies) == 0 {
+		return
+	}
+
+	d.Lock()
+	d.anomalies = append(d.anomalies, anomalies...)
+	if len(d.anomalies) > maxAnomalies {
+		d.anomalies = d.anomalies[len(d.anomalies)-maxAnomalies:]
+	}
+	d.Unlock()
+
+	for _, anomaly := range anomalies {
+		logging.Warnf("Schema drift: %s", anomaly.String())
+		notifications.SchemaDrift(anomaly.String())
+	}
+}
+
+//evaluateEventType must be called under token lock
+func (d *Detector) evaluateEventType(tokenId, eventType string, knownToken bool, state *eventTypeState, now time.Time) []*Anomaly {
+	current := state.current
+	state.current = nil
+	events := 0
+	if current != nil {
+		events = current.events
+	}
+
+	var anomalies []*Anomaly
+	if state.windows == 0 {
+		//the first window of the event type: it is a baseline
+		if events > 0 && knownToken && events >= d.minEvents {
+			anomalies = append(anomalies, &Anomaly{Type: NewEventType, TokenId: tokenId, EventType: eventType, Ratio: 1, Events: events, DetectedAt: now})
+		}
+	} else if events == 0 {
+		if state.baselineEvents >= float64(d.minEvents) {
+			anomalies = append(anomalies, &Anomaly{Type: MissingEventType, TokenId: tokenId, EventType: eventType, BaselineRatio: state.baselineEvents, DetectedAt: now})
+			//report once
+			state.baselineEvents = 0
+			state.baseline = map[string]float64{}
+		}
+	} else if events >= d.minEvents {
+		for field, count := range current.fields {
+			ratio := float64(count) / float64(events)
+			if _, ok := state.baseline[field]; !ok && ratio >= d.newFieldRatio {
+				anomalies = append(anomalies, &Anomaly{Type: NewField, TokenId: tokenId, EventType: eventType, Field: field, Ratio: ratio, Events: events, DetectedAt: now})
+			}
+		}
+		for field, baselineRatio := range state.baseline {
+			ratio := float64(current.fields[field]) / float64(events)
+			if baselineRatio >= d.expectedFieldRatio && ratio < d.expectedFieldRatio/2 {
+				anomalies = append(anomalies, &Anomaly{Type: MissingField, TokenId: tokenId, EventType: eventType, Field: field, Ratio: ratio,
+					BaselineRatio: baselineRatio, Events: events, DetectedAt: now})
+				//report once
+				delete(state.baseline, field)
+			}
+		}
+	}
+
+	d.updateBaseline(state, current, events)
+	return anomalies
+}
+
+func (d *Detector) updateBaseline(state *eventTypeState, current *window, events int) {
+	if state.windows == 0 {
+		if events == 0 {
+			return
+		}
+		state.baselineEvents = float64(events)
+		for field, count := range current.fields {
+			state.baseline[field] = float64(count) / float64(events)
+		}
+		state.windows++
+		return
+	}
+
+	state.windows++
+	state.baselineEvents = baselineWeight*state.baselineEvents + (1-baselineWeight)*float64(events)
+	if events == 0 {
+		return
+	}
+
+	for field, baselineRatio := range state.baseline {
+		ratio := float64(current.fields[field]) / float64(events)
+		state.baseline[field] = baselineWeight*baselineRatio + (1-baselineWeight)*ratio
+		if state.baseline[field] < minBaselineRatio {
+			delete(state.baseline, field)
+		}
+	}
+	for field, count := range current.fields {
+		if _, ok := state.baseline[field]; !ok && len(state.baseline) < maxFieldsPerEventType {
+			state.baseline[field] = float64(count) / float64(events)
+		}
+	}
+}
+
+func (d *Detector) tokenStats(tokenId string) map[string]*EventTypeStats {
+	result := map[string]*EventTypeStats{}
+
+	d.RLock()
+	ts, ok := d.tokens[tokenId]
+	d.RUnlock()
+	if !ok {
+		return result
+	}
+
+	ts.Lock()
+	defer ts.Unlock()
+	for eventType, state := range ts.eventTypes {
+		if state.windows == 0 {
+			continue
+		}
+		fields := make(map[string]float64, len(state.baseline))
+		for field, ratio := range state.baseline {
+			fields[field] = ratio
+		}
+		result[eventType] = &EventTypeStats{Events: state.baselineEvents, Fields: fields}
+	}
+
+	return result
+}
+
+func (d *Detector) getAnomalies(tokenIds map[string]bool) []*Anomaly {
+	d.RLock()
+	defer d.RUnlock()
+
+	result := []*Anomaly{}
+	for _, anomaly := range d.anomalies {
+		if len(tokenIds) == 0 || tokenIds[anomaly.TokenId] {
+			result = append(result, anomaly)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DetectedAt.After(result[j].DetectedAt)
+	})
+
+	return result
+}
+
+func (d *Detector) Close() error {
+	if d.job != nil {
+		d.job.Stop()
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package drift
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func trackN(d *Detector, tokenId string, n int, event map[string]interface{}) {
+	for i := 0; i < n; i++ {
+		d.track(tokenId, event)
+	}
+}
+
+func anomaliesByType(d *Detector) map[string][]*Anomaly {
+	result := map[string][]*Anomaly{}
+	for _, anomaly := range d.getAnomalies(nil) {
+		result[anomaly.Type] = append(result[anomaly.Type], anomaly)
+	}
+	return result
+}
+
+func TestDetector(t *testing.T) {
+	d := newDetector(&Config{Enabled: true, MinEvents: 10})
+	now := time.Now().UTC()
+
+	pageview := map[string]interface{}{"event_type": "pageview", "user": map[string]interface{}{"id": "1"}, "url": "a"}
+	purchase := map[string]interface{}{"event_type": "purchase", "amount": 10}
+
+	//baseline window
+	trackN(d, "token1", 100, pageview)
+	trackN(d, "token1", 20, purchase)
+	d.evaluate(now)
+	require.Empty(t, d.getAnomalies(nil))
+
+	stats := d.tokenStats("token1")
+	require.Equal(t, 2, len(stats))
+	require.Equal(t, 1.0, stats["pageview"].Fields["/user/id"])
+	require.Equal(t, 100.0, stats["pageview"].Events)
+
+	//user id disappears, new field appears, purchases disappear, new event type appears
+	trackN(d, "token1", 100, map[string]interface{}{"event_type": "pageview", "url": "a", "referrer": "b"})
+	trackN(d, "token1", 15, map[string]interface{}{"event_type": "signup"})
+	d.evaluate(now.Add(time.Hour))
+
+	anomalies := anomaliesByType(d)
+	require.Equal(t, 1, len(anomalies[MissingField]))
+	require.Equal(t, "/user/id", anomalies[MissingField][0].Field)
+	require.Equal(t, 1, len(anomalies[NewField]))
+	require.Equal(t, "/referrer", anomalies[NewField][0].Field)
+	require.Equal(t, 1, len(anomalies[MissingEventType]))
+	require.Equal(t, "purchase", anomalies[MissingEventType][0].EventType)
+	require.Equal(t, 1, len(anomalies[NewEventType]))
+	require.Equal(t, "signup", anomalies[NewEventType][0].EventType)
+
+	//anomalies are reported once
+	trackN(d, "token1", 100, map[string]interface{}{"event_type": "pageview", "url": "a", "referrer": "b"})
+	trackN(d, "token1", 15, map[string]interface{}{"event_type": "signup"})
+	d.evaluate(now.Add(2 * time.Hour))
+	require.Equal(t, 4, len(d.getAnomalies(nil)))
+	require.Empty(t, d.getAnomalies(map[string]bool{"token2": true}))
+}
+
+func TestDetectorMinEvents(t *testing.T) {
+	d := newDetector(&Config{Enabled: true, MinEvents: 10})
+	trackN(d, "token1", 5, map[string]interface{}{"event_type": "pageview", "url": "a"})
+	d.evaluate(time.Now())
+	trackN(d, "token1", 5, map[string]interface{}{"event_type": "pageview", "referrer": "b"})
+	d.evaluate(time.Now())
+
+	require.Empty(t, d.getAnomalies(nil), "event types with less than min_events aren't checked")
+}
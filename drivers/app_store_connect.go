@@ -0,0 +1,264 @@
+package drivers
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/typing"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	appStoreConnectType = "app_store_connect"
+
+	appStoreSalesCollection = "sales"
+
+	appStoreSalesReportsUrl = "https://api.appstoreconnect.apple.com/v1/salesReports"
+	appStoreAudience        = "appstoreconnect-v1"
+	appStoreTokenTTL        = 20 * time.Minute
+
+	defaultAppStoreDays = 30
+	//daily reports are available for 365 days
+	maxAppStoreDays = 365
+
+	//reportDateKey is a field with report day (YYYY-MM-DD) in every object
+	reportDateKey = "report_date"
+)
+
+var appStoreSalesTypeCasts = map[string]func(interface{}) (interface{}, error){
+	"units":              typing.StringToInt,
+	"developer_proceeds": typing.StringWithCommasToFloat,
+	"customer_price":     typing.StringWithCommasToFloat,
+}
+
+//AppStoreConnectConfig is an App Store Connect API key config
+//private_key is a content of .p8 key file
+type AppStoreConnectConfig struct {
+	KeyId        string `mapstructure:"key_id" json:"key_id,omitempty" yaml:"key_id,omitempty"`
+	IssuerId     string `mapstructure:"issuer_id" json:"issuer_id,omitempty" yaml:"issuer_id,omitempty"`
+	PrivateKey   string `mapstructure:"private_key" json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	VendorNumber string `mapstructure:"vendor_number" json:"vendor_number,omitempty" yaml:"vendor_number,omitempty"`
+	Days         int    `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (ac *AppStoreConnectConfig) Validate() error {
+	if ac == nil {
+		return errors.New("AppStoreConnect config is required")
+	}
+	if ac.KeyId == "" {
+		return errors.New("AppStoreConnect key_id is required parameter")
+	}
+	if ac.IssuerId == "" {
+		return errors.New("AppStoreConnect issuer_id is required parameter")
+	}
+	if ac.PrivateKey == "" {
+		return errors.New("AppStoreConnect private_key is required parameter")
+	}
+	if ac.VendorNumber == "" {
+		return errors.New("AppStoreConnect vendor_number is required parameter")
+	}
+	if ac.Days <= 0 {
+		ac.Days = defaultAppStoreDays
+	}
+	if ac.Days > maxAppStoreDays {
+		ac.Days = maxAppStoreDays
+	}
+
+	return nil
+}
+
+//AppStoreConnectCollectionConfig is a sales report parameters
+type AppStoreConnectCollectionConfig struct {
+	ReportType    string `mapstructure:"report_type" json:"report_type,omitempty" yaml:"report_type,omitempty"`
+	ReportSubType string `mapstructure:"report_sub_type" json:"report_sub_type,omitempty" yaml:"report_sub_type,omitempty"`
+	Version       string `mapstructure:"version" json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+//AppStoreConnect is a driver for App Store Connect daily sales reports
+type AppStoreConnect struct {
+	config           *AppStoreConnectConfig
+	collectionConfig *AppStoreConnectCollectionConfig
+	privateKey       *ecdsa.PrivateKey
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(appStoreConnectType, NewAppStoreConnect); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", appStoreConnectType, err)
+	}
+}
+
+func NewAppStoreConnect(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &AppStoreConnectConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != appStoreSalesCollection {
+		return nil, fmt.Errorf("AppStoreConnect unknown collection type [%s]. Available types: [%s]", collection.Type, appStoreSalesCollection)
+	}
+
+	collectionConfig := &AppStoreConnectCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if collectionConfig.ReportType == "" {
+		collectionConfig.ReportType = "SALES"
+	}
+	if collectionConfig.ReportSubType == "" {
+		collectionConfig.ReportSubType = "SUMMARY"
+	}
+	if collectionConfig.Version == "" {
+		collectionConfig.Version = "1_0"
+	}
+
+	privateKey, err := parseAppStorePrivateKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AppStoreConnect{config: config, collectionConfig: collectionConfig, privateKey: privateKey, ctx: ctx, collection: collection}, nil
+}
+
+func (asc *AppStoreConnect) GetCollectionTable() string {
+	return asc.collection.GetTableName()
+}
+
+//GetAllAvailableIntervals return day intervals for configured days (reports are published with 1 day lag)
+func (asc *AppStoreConnect) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return lastDaysIntervals(asc.config.Days), nil
+}
+
+//GetObjectsFor download daily report and return rows with report_date field
+func (asc *AppStoreConnect) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	reportDate := interval.LowerEndpoint().Format(dayLayout)
+
+	query := url.Values{}
+	query.Set("filter[frequency]", "DAILY")
+	query.Set("filter[reportDate]", reportDate)
+	query.Set("filter[reportType]", asc.collectionConfig.ReportType)
+	query.Set("filter[reportSubType]", asc.collectionConfig.ReportSubType)
+	query.Set("filter[vendorNumber]", asc.config.VendorNumber)
+	query.Set("filter[version]", asc.collectionConfig.Version)
+
+	token, err := asc.token(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, appStoreSalesReportsUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("AppStoreConnect error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/a-gzip")
+
+	resp, err := doRateLimitedRequest(req.WithContext(asc.ctx))
+	if err != nil {
+		return nil, fmt.Errorf("AppStoreConnect error requesting %s report: %v", reportDate, err)
+	}
+	defer resp.Body.Close()
+
+	//there is no report if there were no sales
+	if resp.StatusCode == http.StatusNotFound {
+		return []map[string]interface{}{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("AppStoreConnect error response of %s report code: %d body: %s", reportDate, resp.StatusCode, string(body))
+	}
+
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("AppStoreConnect error reading %s report: %v", reportDate, err)
+	}
+	defer gzipReader.Close()
+
+	objects, err := parsers.ParseTsv(gzipReader, appStoreSalesTypeCasts)
+	if err != nil {
+		return nil, fmt.Errorf("AppStoreConnect error parsing %s report: %v", reportDate, err)
+	}
+	for _, object := range objects {
+		object[reportDateKey] = reportDate
+	}
+
+	return objects, nil
+}
+
+func (asc *AppStoreConnect) Type() string {
+	return appStoreConnectType
+}
+
+func (asc *AppStoreConnect) Close() error {
+	return nil
+}
+
+//token return App Store Connect API JWT signed with ES256
+func (asc *AppStoreConnect) token(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": asc.config.KeyId, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": asc.config.IssuerId,
+		"iat": now.Unix(),
+		"exp": now.Add(appStoreTokenTTL).Unix(),
+		"aud": appStoreAudience,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, asc.privateKey, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("AppStoreConnect error signing token: %v", err)
+	}
+
+	//JWS ES256 signature is 64 bytes: R and S padded to 32 bytes
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+//parseAppStorePrivateKey return ECDSA private key from PEM encoded PKCS8 .p8 file content
+func parseAppStorePrivateKey(value string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("AppStoreConnect private_key must be PEM encoded .p8 key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("AppStoreConnect error parsing private_key: %v", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("AppStoreConnect private_key must be ECDSA key")
+	}
+
+	return ecdsaKey, nil
+}
@@ -0,0 +1,68 @@
+package drivers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppStoreConnectToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	p8 := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	privateKey, err := parseAppStorePrivateKey(p8)
+	require.NoError(t, err)
+
+	_, err = parseAppStorePrivateKey("not a key")
+	require.Error(t, err)
+
+	asc := &AppStoreConnect{config: &AppStoreConnectConfig{KeyId: "KEY123", IssuerId: "issuer"}, privateKey: privateKey}
+	now := time.Unix(1600000000, 0)
+	token, err := asc.token(now)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	header := map[string]string{}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &header))
+	require.Equal(t, map[string]string{"alg": "ES256", "kid": "KEY123", "typ": "JWT"}, header)
+
+	claims := map[string]interface{}{}
+	b, err = base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &claims))
+	require.Equal(t, "issuer", claims["iss"])
+	require.Equal(t, appStoreAudience, claims["aud"])
+	require.Equal(t, float64(now.Add(appStoreTokenTTL).Unix()), claims["exp"])
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.True(t, ecdsa.Verify(&key.PublicKey, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])))
+}
+
+func TestParseInstallsReportMonth(t *testing.T) {
+	month, ok := parseInstallsReportMonth("stats/installs/installs_com.my_app.android_202012_overview.csv")
+	require.True(t, ok)
+	require.Equal(t, "202012", month)
+
+	_, ok = parseInstallsReportMonth("stats/installs/installs_com.app_202012_country.csv")
+	require.False(t, ok)
+}
@@ -0,0 +1,179 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	asanaType = "asana"
+
+	asanaTasksCollection = "tasks"
+
+	asanaTasksUrl  = "https://app.asana.com/api/1.0/tasks"
+	asanaPageSize  = 100
+	asanaOptFields = "name,resource_subtype,completed,completed_at,created_at,modified_at,due_on,due_at,start_on,notes,assignee.name,assignee.email,projects.name,memberships.section.name,tags.name,parent.name,custom_fields.name,custom_fields.display_value,num_subtasks"
+)
+
+//AsanaConfig is an Asana personal access token config
+//start_date (YYYY-MM-DD) is used as "modified since" in the first sync. Default - all the tasks
+type AsanaConfig struct {
+	AccessToken string `mapstructure:"access_token" json:"access_token,omitempty" yaml:"access_token,omitempty"`
+	StartDate   string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (ac *AsanaConfig) Validate() error {
+	if ac == nil {
+		return errors.New("Asana config is required")
+	}
+	if ac.AccessToken == "" {
+		return errors.New("Asana access_token is required parameter")
+	}
+
+	return nil
+}
+
+//AsanaCollectionConfig is a collection parameters: tasks of the project or of the assignee in the workspace
+type AsanaCollectionConfig struct {
+	Project   string   `mapstructure:"project" json:"project,omitempty" yaml:"project,omitempty"`
+	Workspace string   `mapstructure:"workspace" json:"workspace,omitempty" yaml:"workspace,omitempty"`
+	Assignee  string   `mapstructure:"assignee" json:"assignee,omitempty" yaml:"assignee,omitempty"`
+	OptFields []string `mapstructure:"opt_fields" json:"opt_fields,omitempty" yaml:"opt_fields,omitempty"`
+}
+
+func (acc *AsanaCollectionConfig) Validate() error {
+	if acc.Project == "" && (acc.Workspace == "" || acc.Assignee == "") {
+		return errors.New("Asana project or workspace and assignee collection parameters are required")
+	}
+
+	return nil
+}
+
+type asanaTasksResponse struct {
+	Data     []map[string]interface{} `json:"data"`
+	NextPage *struct {
+		Offset string `json:"offset"`
+	} `json:"next_page"`
+}
+
+//Asana is a driver for incremental syncing Asana tasks modified since the last sync
+type Asana struct {
+	incrementalCursor
+
+	config           *AsanaConfig
+	collectionConfig *AsanaCollectionConfig
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(asanaType, NewAsana); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", asanaType, err)
+	}
+}
+
+func NewAsana(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &AsanaConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != asanaTasksCollection {
+		return nil, fmt.Errorf("Asana unknown collection type [%s]. Available types: [%s]", collection.Type, asanaTasksCollection)
+	}
+
+	collectionConfig := &AsanaCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if err := collectionConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Asana{
+		incrementalCursor: incrementalCursor{stateKey: asanaType + "_" + collection.Name, initial: initial},
+		config:            config,
+		collectionConfig:  collectionConfig,
+		ctx:               ctx,
+		collection:        collection,
+	}, nil
+}
+
+func (a *Asana) GetCollectionTable() string {
+	return a.collection.GetTableName()
+}
+
+func (a *Asana) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return tasks modified since the last sync
+//the sync start time is used as the next cursor because tasks aren't ordered by modification time
+func (a *Asana) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := a.get()
+	if err != nil {
+		return nil, fmt.Errorf("Asana %v", err)
+	}
+	syncStart := time.Now().UTC()
+
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(asanaPageSize))
+	if a.collectionConfig.Project != "" {
+		query.Set("project", a.collectionConfig.Project)
+	} else {
+		query.Set("workspace", a.collectionConfig.Workspace)
+		query.Set("assignee", a.collectionConfig.Assignee)
+	}
+	if len(a.collectionConfig.OptFields) > 0 {
+		query.Set("opt_fields", strings.Join(a.collectionConfig.OptFields, ","))
+	} else {
+		query.Set("opt_fields", asanaOptFields)
+	}
+	if !since.IsZero() {
+		query.Set("modified_since", since.UTC().Format(time.RFC3339))
+	}
+
+	var objects []map[string]interface{}
+	for {
+		response := &asanaTasksResponse{}
+		if err := doJsonRequest(http.MethodGet, asanaTasksUrl+"?"+query.Encode(), nil, a.authorize, response); err != nil {
+			return nil, fmt.Errorf("Asana error getting tasks: %v", err)
+		}
+		objects = append(objects, response.Data...)
+
+		if response.NextPage == nil || response.NextPage.Offset == "" {
+			break
+		}
+		query.Set("offset", response.NextPage.Offset)
+	}
+
+	a.setPending(syncStart)
+	return objects, nil
+}
+
+func (a *Asana) Type() string {
+	return asanaType
+}
+
+func (a *Asana) Close() error {
+	return nil
+}
+
+func (a *Asana) authorize(r *http.Request) {
+	r.Header.Set("Authorization", "Bearer "+a.config.AccessToken)
+}
@@ -0,0 +1,151 @@
+package drivers
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"math/big"
+)
+
+const bigQueryType = "bigquery"
+
+//BigQueryConfig is a Google project and credentials config of query source
+type BigQueryConfig struct {
+	ProjectId  string            `mapstructure:"project_id" json:"project_id,omitempty" yaml:"project_id,omitempty"`
+	AccountKey *GoogleAuthConfig `mapstructure:"auth" json:"auth,omitempty" yaml:"auth,omitempty"`
+}
+
+func (bqc *BigQueryConfig) Validate() error {
+	if bqc == nil {
+		return errors.New("BigQuery config is required")
+	}
+	if bqc.ProjectId == "" {
+		return errors.New("BigQuery project_id is required")
+	}
+	if bqc.AccountKey == nil {
+		return errors.New("BigQuery auth is required")
+	}
+
+	return bqc.AccountKey.Validate()
+}
+
+//BigQuery is a driver for loading SQL query results from Google BigQuery (e.g. for reverse-ETL)
+type BigQuery struct {
+	config           *BigQueryConfig
+	collectionConfig *QueryCollectionConfig
+	client           *bigquery.Client
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(bigQueryType, NewBigQuery); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", bigQueryType, err)
+	}
+}
+
+func NewBigQuery(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &BigQueryConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig, err := parseQueryCollectionConfig(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsJSON, err := config.AccountKey.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	client, err := bigquery.NewClient(ctx, config.ProjectId, option.WithCredentialsJSON(credentialsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("BigQuery error creating client: %v", err)
+	}
+
+	return &BigQuery{config: config, collectionConfig: collectionConfig, client: client, ctx: ctx, collection: collection}, nil
+}
+
+func (bq *BigQuery) GetCollectionTable() string {
+	return bq.collection.GetTableName()
+}
+
+func (bq *BigQuery) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor run collection query and return all result rows
+func (bq *BigQuery) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	it, err := bq.client.Query(bq.collectionConfig.Query).Read(bq.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("BigQuery error running [%s] collection query: %v", bq.collection.Name, err)
+	}
+
+	var objects []map[string]interface{}
+	for {
+		row := map[string]bigquery.Value{}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("BigQuery error reading [%s] collection query result: %v", bq.collection.Name, err)
+		}
+
+		object := map[string]interface{}{}
+		for name, value := range row {
+			object[name] = toObjectValue(value)
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func (bq *BigQuery) Type() string {
+	return bigQueryType
+}
+
+func (bq *BigQuery) Close() error {
+	return bq.client.Close()
+}
+
+//toObjectValue convert BigQuery records and repeated values into objects and slices
+//civil date/time values are converted into strings and NUMERIC values into floats
+func toObjectValue(value bigquery.Value) interface{} {
+	switch v := value.(type) {
+	case map[string]bigquery.Value:
+		object := map[string]interface{}{}
+		for name, nested := range v {
+			object[name] = toObjectValue(nested)
+		}
+		return object
+	case []bigquery.Value:
+		values := make([]interface{}, 0, len(v))
+		for _, nested := range v {
+			values = append(values, toObjectValue(nested))
+		}
+		return values
+	case civil.Date:
+		return v.String()
+	case civil.Time:
+		return v.String()
+	case civil.DateTime:
+		return v.String()
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
@@ -0,0 +1,156 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	chargebeeType = "chargebee"
+
+	chargebeeUrlTemplate = "https://%s.chargebee.com/api/v2/"
+	chargebeePageLimit   = 100
+)
+
+//ChargebeeConfig is a Chargebee site and full-access API key config
+//start_date (YYYY-MM-DD) is used as "updated since" in the first sync. Default - all the objects
+type ChargebeeConfig struct {
+	Site      string `mapstructure:"site" json:"site,omitempty" yaml:"site,omitempty"`
+	ApiKey    string `mapstructure:"api_key" json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (cc *ChargebeeConfig) Validate() error {
+	if cc == nil {
+		return errors.New("Chargebee config is required")
+	}
+	if cc.Site == "" {
+		return errors.New("Chargebee site is required parameter")
+	}
+	if cc.ApiKey == "" {
+		return errors.New("Chargebee api_key is required parameter")
+	}
+
+	return nil
+}
+
+//chargebeeListResponse is a list page: every element is wrapped into object with entity name key
+//e.g. {"subscription": {...}, "customer": {...}}
+type chargebeeListResponse struct {
+	List       []map[string]interface{} `json:"list"`
+	NextOffset string                   `json:"next_offset"`
+}
+
+//Chargebee is a driver for incremental syncing Chargebee plans, subscriptions, invoices and transactions
+//updated since the last sync
+type Chargebee struct {
+	incrementalCursor
+
+	config *ChargebeeConfig
+	url    string
+	entity string
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(chargebeeType, NewChargebee); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", chargebeeType, err)
+	}
+}
+
+func NewChargebee(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &ChargebeeConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !isBillingCollection(collection.Type) {
+		return nil, fmt.Errorf("Chargebee unknown collection type [%s]. Available types: %v", collection.Type, billingCollections)
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chargebee{
+		incrementalCursor: incrementalCursor{stateKey: chargebeeType + "_" + collection.Name, initial: initial},
+		config:            config,
+		url:               fmt.Sprintf(chargebeeUrlTemplate, config.Site),
+		entity:            strings.TrimSuffix(collection.Type, "s"),
+		collection:        collection,
+	}, nil
+}
+
+func (c *Chargebee) GetCollectionTable() string {
+	return c.collection.GetTableName()
+}
+
+func (c *Chargebee) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return objects updated since the last sync. The max updated_at (unix timestamp) is used as the next cursor
+func (c *Chargebee) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := c.get()
+	if err != nil {
+		return nil, fmt.Errorf("Chargebee %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(chargebeePageLimit))
+	if !since.IsZero() {
+		query.Set("updated_at[after]", fmt.Sprint(since.Unix()))
+	}
+
+	var objects []map[string]interface{}
+	maxUpdated := since
+	for {
+		response := &chargebeeListResponse{}
+		if err := doJsonRequest(http.MethodGet, c.url+c.collection.Type+"?"+query.Encode(), nil, c.authorize, response); err != nil {
+			return nil, fmt.Errorf("Chargebee error getting %s: %v", c.collection.Type, err)
+		}
+		for _, element := range response.List {
+			object, ok := element[c.entity].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Chargebee malformed %s list element: %v", c.collection.Type, element)
+			}
+			objects = append(objects, object)
+
+			if updated, ok := parseCursorTime(object["updated_at"]); ok && updated.After(maxUpdated) {
+				maxUpdated = updated
+			}
+		}
+
+		if response.NextOffset == "" {
+			break
+		}
+		query.Set("offset", response.NextOffset)
+	}
+
+	if maxUpdated.After(since) {
+		c.setPending(maxUpdated)
+	}
+	return objects, nil
+}
+
+func (c *Chargebee) Type() string {
+	return chargebeeType
+}
+
+func (c *Chargebee) Close() error {
+	return nil
+}
+
+func (c *Chargebee) authorize(req *http.Request) {
+	req.SetBasicAuth(c.config.ApiKey, "")
+}
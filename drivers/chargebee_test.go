@@ -0,0 +1,48 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChargebeeIncrementalSync(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		user, _, _ := r.BasicAuth()
+		require.Equal(t, "key", user)
+		require.Equal(t, "/subscriptions", r.URL.Path)
+
+		if r.URL.Query().Get("offset") == "" {
+			w.Write([]byte(`{"list":[{"subscription":{"id":"1","updated_at":1609502400},"customer":{"id":"c1"}}],"next_offset":"[\"2\"]"}`))
+		} else {
+			w.Write([]byte(`{"list":[{"subscription":{"id":"2","updated_at":1609545600},"customer":{"id":"c2"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	driver, err := NewChargebee(context.Background(), &SourceConfig{Config: map[string]interface{}{"site": "test", "api_key": "key", "start_date": "2021-01-01"}},
+		&Collection{Name: "subscriptions", Type: subscriptionsCollection})
+	require.NoError(t, err)
+	chargebee := driver.(*Chargebee)
+	chargebee.url = server.URL + "/"
+	chargebee.SetStateStorage("source", testStateStorage{})
+
+	objects, err := chargebee.GetObjectsFor(allIntervals()[0])
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, "1", objects[0]["id"])
+	require.Equal(t, "2", objects[1]["id"])
+	require.Equal(t, "limit=100&updated_at%5Bafter%5D=1609459200", requests[0])
+	require.Equal(t, "limit=100&offset=%5B%222%22%5D&updated_at%5Bafter%5D=1609459200", requests[1])
+
+	//the max updated_at is the next cursor
+	require.NoError(t, chargebee.Commit())
+	cursor, err := chargebee.get()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC), cursor.UTC())
+}
@@ -18,3 +18,26 @@ type Driver interface {
 	//GetCollectionTable returns table name and primary keys per collection
 	GetCollectionTable() string
 }
+
+//TransactionalDriver is a Driver which claims objects in GetObjectsFor until Commit (after successful delivery to
+//all destinations) or Rollback is called. Objects of such drivers are only appended to destinations: previously
+//stored objects of the same time interval aren't deleted
+type TransactionalDriver interface {
+	Driver
+	Commit() error
+	Rollback() error
+}
+
+//StateStorage is used by stateful drivers for keeping state between syncs (e.g. processed files)
+type StateStorage interface {
+	GetSignature(sourceId, collection, interval string) (string, error)
+	SaveSignature(sourceId, collection, interval, signature string) error
+}
+
+//StatefulDriver is a Driver which keeps state in StateStorage. SetStateStorage is called right after driver creation
+type StatefulDriver interface {
+	Driver
+	SetStateStorage(sourceId string, storage StateStorage)
+	//StateKey return collection key of the driver state in StateStorage (is used for state reset before full re-sync)
+	StateKey() string
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/notifications"
 	"github.com/spf13/cast"
 )
 
@@ -18,12 +19,23 @@ const (
 	collectionNameField       = "name"
 	collectionTableNameField  = "table_name"
 	collectionParametersField = "parameters"
+	collectionTransformField  = "transform"
+	collectionMaxRowsField    = "max_rows_per_run"
+	collectionMaxBytesField   = "max_bytes_per_run"
 )
 
 type SourceConfig struct {
 	Type         string        `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
 	Destinations []string      `mapstructure:"destinations" json:"destinations,omitempty" yaml:"destinations,omitempty"`
 	Collections  []interface{} `mapstructure:"collections" json:"collections,omitempty" yaml:"collections,omitempty"`
+	//Parallelism is a number of concurrently synced intervals of a collection (default 1).
+	//Transactional drivers intervals are always synced sequentially
+	Parallelism int `mapstructure:"parallelism" json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	//MaxRowsPerRun and MaxBytesPerRun are default collections limits (see Collection)
+	MaxRowsPerRun  int   `mapstructure:"max_rows_per_run" json:"max_rows_per_run,omitempty" yaml:"max_rows_per_run,omitempty"`
+	MaxBytesPerRun int64 `mapstructure:"max_bytes_per_run" json:"max_bytes_per_run,omitempty" yaml:"max_bytes_per_run,omitempty"`
+	//Notifications enables sync runs summaries and failures alerts via notifications channels
+	Notifications *notifications.SourceSyncConfig `mapstructure:"notifications" json:"notifications,omitempty" yaml:"notifications,omitempty"`
 
 	Config map[string]interface{} `mapstructure:"config" json:"config,omitempty" yaml:"config,omitempty"`
 }
@@ -33,6 +45,12 @@ type Collection struct {
 	Type       string                 `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
 	TableName  string                 `mapstructure:"table_name" json:"table_name,omitempty" yaml:"table_name,omitempty"`
 	Parameters map[string]interface{} `mapstructure:"parameters" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	//Transform is a JavaScript function body applied to every collection object (see transform.Transformer)
+	Transform string `mapstructure:"transform" json:"transform,omitempty" yaml:"transform,omitempty"`
+	//MaxRowsPerRun and MaxBytesPerRun limit data synced per sync run (0 means without limit).
+	//Limits are checked between intervals. Not synced intervals will be synced in the next runs
+	MaxRowsPerRun  int   `mapstructure:"max_rows_per_run" json:"max_rows_per_run,omitempty" yaml:"max_rows_per_run,omitempty"`
+	MaxBytesPerRun int64 `mapstructure:"max_bytes_per_run" json:"max_bytes_per_run,omitempty" yaml:"max_bytes_per_run,omitempty"`
 }
 
 func (c Collection) GetTableName() string {
@@ -56,28 +74,9 @@ func Create(ctx context.Context, name string, sourceConfig *SourceConfig) (map[s
 		sourceConfig.Type = name
 	}
 
-	var collections []*Collection
-	for _, collection := range sourceConfig.Collections {
-		switch collection.(type) {
-		case string:
-			collections = append(collections, &Collection{Name: collection.(string), Type: collection.(string)})
-		case map[interface{}]interface{}:
-			collectionConfigMap := cast.ToStringMap(collection)
-			collectionName := getStringParameter(collectionConfigMap, collectionNameField)
-			if collectionName == "" {
-				return nil, errors.New("[name] field of collection is not configured")
-			}
-			collectionType := getStringParameter(collectionConfigMap, "type")
-			if collectionType == "" {
-				collectionType = collectionName
-			}
-			collection := Collection{Name: collectionName, Type: collectionType,
-				TableName:  getStringParameter(collectionConfigMap, collectionTableNameField),
-				Parameters: cast.ToStringMap(collectionConfigMap[collectionParametersField])}
-			collections = append(collections, &collection)
-		default:
-			return nil, errors.New("failed to parse source collections as array of string or collections structure")
-		}
+	collections, err := ParseCollections(sourceConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	logging.Infof("[%s] Initializing source of type: %s", name, sourceConfig.Type)
@@ -97,13 +96,77 @@ func Create(ctx context.Context, name string, sourceConfig *SourceConfig) (map[s
 	for _, collection := range collections {
 		driver, err := createDriverFunc(ctx, sourceConfig, collection)
 		if err != nil {
-			return nil, fmt.Errorf("error creating [%s] driver for [%s] collection: %v", sourceConfig.Type, collection, err)
+			return nil, fmt.Errorf("error creating [%s] driver for [%s] collection: %v", sourceConfig.Type, collection.Name, err)
 		}
 		driverPerCollection[collection.Name] = driver
 	}
 	return driverPerCollection, nil
 }
 
+//Validate check source configuration without connecting to the source:
+//source type, collections and destinations
+func Validate(name string, sourceConfig *SourceConfig) error {
+	sourceType := sourceConfig.Type
+	if sourceType == "" {
+		sourceType = name
+	}
+	if _, ok := driverConstructors[sourceType]; !ok {
+		return fmt.Errorf("%v: %s", unknownSource, sourceType)
+	}
+
+	collections, err := ParseCollections(sourceConfig)
+	if err != nil {
+		return err
+	}
+	if len(collections) == 0 {
+		return errors.New("collections are empty. Please specify at least one collection")
+	}
+	if len(sourceConfig.Destinations) == 0 {
+		return errors.New("destinations are empty. Please specify at least one destination")
+	}
+
+	return nil
+}
+
+//ParseCollections return collections from source config: array of strings or collections structures
+func ParseCollections(sourceConfig *SourceConfig) ([]*Collection, error) {
+	var collections []*Collection
+	for _, collection := range sourceConfig.Collections {
+		switch collection.(type) {
+		case string:
+			collections = append(collections, &Collection{Name: collection.(string), Type: collection.(string),
+				MaxRowsPerRun: sourceConfig.MaxRowsPerRun, MaxBytesPerRun: sourceConfig.MaxBytesPerRun})
+		case map[interface{}]interface{}:
+			collectionConfigMap := cast.ToStringMap(collection)
+			collectionName := getStringParameter(collectionConfigMap, collectionNameField)
+			if collectionName == "" {
+				return nil, errors.New("[name] field of collection is not configured")
+			}
+			collectionType := getStringParameter(collectionConfigMap, "type")
+			if collectionType == "" {
+				collectionType = collectionName
+			}
+			collection := Collection{Name: collectionName, Type: collectionType,
+				TableName:      getStringParameter(collectionConfigMap, collectionTableNameField),
+				Parameters:     cast.ToStringMap(collectionConfigMap[collectionParametersField]),
+				Transform:      getStringParameter(collectionConfigMap, collectionTransformField),
+				MaxRowsPerRun:  sourceConfig.MaxRowsPerRun,
+				MaxBytesPerRun: sourceConfig.MaxBytesPerRun}
+			if maxRows, ok := collectionConfigMap[collectionMaxRowsField]; ok {
+				collection.MaxRowsPerRun = cast.ToInt(maxRows)
+			}
+			if maxBytes, ok := collectionConfigMap[collectionMaxBytesField]; ok {
+				collection.MaxBytesPerRun = cast.ToInt64(maxBytes)
+			}
+			collections = append(collections, &collection)
+		default:
+			return nil, errors.New("failed to parse source collections as array of string or collections structure")
+		}
+	}
+
+	return collections, nil
+}
+
 func getStringParameter(dict map[string]interface{}, parameterName string) string {
 	value, ok := dict[parameterName]
 	if !ok {
@@ -0,0 +1,467 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	filesType = "files"
+
+	CsvFormat   = "csv"
+	JsonlFormat = "jsonl"
+
+	//sourceFileKey is a field with file name in every object
+	sourceFileKey = "source_file"
+
+	defaultFilesMaxFiles = 100
+	defaultSftpPort      = 22
+)
+
+var typeHintConverts = map[typing.DataType]func(interface{}) (interface{}, error){
+	typing.STRING:  func(v interface{}) (interface{}, error) { return v, nil },
+	typing.INT64:   typing.StringToInt,
+	typing.FLOAT64: typing.StringToFloat,
+	typing.BOOL: func(v interface{}) (interface{}, error) {
+		return strconv.ParseBool(v.(string))
+	},
+	typing.TIMESTAMP: func(v interface{}) (interface{}, error) {
+		return typing.Convert(typing.TIMESTAMP, v)
+	},
+}
+
+//FilesConfig is a files location config: local directory or directory on SFTP server (if sftp is configured)
+type FilesConfig struct {
+	Path string      `mapstructure:"path" json:"path,omitempty" yaml:"path,omitempty"`
+	Sftp *SftpConfig `mapstructure:"sftp" json:"sftp,omitempty" yaml:"sftp,omitempty"`
+}
+
+func (fc *FilesConfig) Validate() error {
+	if fc == nil {
+		return errors.New("Files config is required")
+	}
+	if fc.Path == "" {
+		return errors.New("Files path is required parameter")
+	}
+
+	return fc.Sftp.Validate()
+}
+
+//SftpConfig is a SFTP connection config
+//private_key is a key content or file path
+//host_key is a server public key in authorized_keys format. Server key isn't verified if host_key is empty
+type SftpConfig struct {
+	Host       string `mapstructure:"host" json:"host,omitempty" yaml:"host,omitempty"`
+	Port       int    `mapstructure:"port" json:"port,omitempty" yaml:"port,omitempty"`
+	Username   string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password   string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey string `mapstructure:"private_key" json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	HostKey    string `mapstructure:"host_key" json:"host_key,omitempty" yaml:"host_key,omitempty"`
+}
+
+func (sc *SftpConfig) Validate() error {
+	if sc == nil {
+		return nil
+	}
+	if sc.Host == "" {
+		return errors.New("SFTP host is required parameter")
+	}
+	if sc.Username == "" {
+		return errors.New("SFTP username is required parameter")
+	}
+	if sc.Password == "" && sc.PrivateKey == "" {
+		return errors.New("SFTP password or private_key is required")
+	}
+	if sc.Port == 0 {
+		sc.Port = defaultSftpPort
+	}
+
+	return nil
+}
+
+//FilesCollectionConfig is a collection parameters: files glob and parsing settings
+//type_hints is a map: field name -> type (string, integer, double, boolean, timestamp). Other CSV fields are strings
+type FilesCollectionConfig struct {
+	Glob      string            `mapstructure:"glob" json:"glob,omitempty" yaml:"glob,omitempty"`
+	Format    string            `mapstructure:"format" json:"format,omitempty" yaml:"format,omitempty"`
+	Delimiter string            `mapstructure:"delimiter" json:"delimiter,omitempty" yaml:"delimiter,omitempty"`
+	TypeHints map[string]string `mapstructure:"type_hints" json:"type_hints,omitempty" yaml:"type_hints,omitempty"`
+	MaxFiles  int               `mapstructure:"max_files" json:"max_files,omitempty" yaml:"max_files,omitempty"`
+}
+
+//Validate check format and delimiter and set default values
+func (fcc *FilesCollectionConfig) Validate() error {
+	if fcc.Glob == "" {
+		return errors.New("glob collection parameter is required")
+	}
+	if fcc.Format != "" && fcc.Format != CsvFormat && fcc.Format != JsonlFormat {
+		return fmt.Errorf("Unknown format [%s]. Available formats: [%s, %s]", fcc.Format, CsvFormat, JsonlFormat)
+	}
+	if fcc.Delimiter == "" {
+		fcc.Delimiter = ","
+	}
+	if utf8.RuneCountInString(fcc.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character: [%s]", fcc.Delimiter)
+	}
+	if fcc.MaxFiles <= 0 {
+		fcc.MaxFiles = defaultFilesMaxFiles
+	}
+
+	return nil
+}
+
+//fileSystem is a local or SFTP file system
+type fileSystem interface {
+	io.Closer
+	Glob(pattern string) ([]string, error)
+	Stat(name string) (os.FileInfo, error)
+	Open(name string) (io.ReadCloser, error)
+}
+
+//pendingFile is a parsed file which will be marked as processed after successful delivery
+type pendingFile struct {
+	name      string
+	signature string
+}
+
+//Files is a driver for CSV/JSONL files in local or SFTP directory
+//every sync parses new or modified files (at most max_files) matching the glob. Files are marked as processed
+//in meta storage after successful delivery (see Commit)
+type Files struct {
+	sync.Mutex
+
+	config           *FilesConfig
+	collectionConfig *FilesCollectionConfig
+	typeConverts     map[string]func(interface{}) (interface{}, error)
+	ctx              context.Context
+
+	collection   *Collection
+	sourceId     string
+	stateStorage StateStorage
+
+	pending []*pendingFile
+}
+
+func init() {
+	if err := RegisterDriverConstructor(filesType, NewFiles); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", filesType, err)
+	}
+}
+
+func NewFiles(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &FilesConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &FilesCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if err := collectionConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	typeConverts := map[string]func(interface{}) (interface{}, error){}
+	for field, typeName := range collectionConfig.TypeHints {
+		dataType, err := typing.TypeFromString(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing [%s] field type hint: %v", field, err)
+		}
+		typeConverts[field] = typeHintConverts[dataType]
+	}
+
+	f := &Files{config: config, collectionConfig: collectionConfig, typeConverts: typeConverts, ctx: ctx, collection: collection}
+
+	//check connection
+	fs, err := f.openFileSystem()
+	if err != nil {
+		return nil, err
+	}
+	fs.Close()
+
+	return f, nil
+}
+
+//SetStateStorage is used for marking processed files
+func (f *Files) SetStateStorage(sourceId string, storage StateStorage) {
+	f.sourceId = sourceId
+	f.stateStorage = storage
+}
+
+func (f *Files) GetCollectionTable() string {
+	return f.collection.GetTableName()
+}
+
+func (f *Files) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor parse new or modified files
+func (f *Files) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.stateStorage == nil {
+		return nil, errors.New("Files state storage isn't configured")
+	}
+
+	fs, err := f.openFileSystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	names, err := fs.Glob(joinPath(f.config.Sftp != nil, f.config.Path, f.collectionConfig.Glob))
+	if err != nil {
+		return nil, fmt.Errorf("Files error listing [%s] files: %v", f.collectionConfig.Glob, err)
+	}
+	sort.Strings(names)
+
+	f.pending = nil
+	var objects []map[string]interface{}
+	for _, name := range names {
+		if len(f.pending) == f.collectionConfig.MaxFiles {
+			logging.Infof("[%s] Files max_files [%d] is reached. Other files will be processed in the next sync", f.collection.Name, f.collectionConfig.MaxFiles)
+			break
+		}
+
+		info, err := fs.Stat(name)
+		if err != nil {
+			return nil, fmt.Errorf("Files error getting file [%s] info: %v", name, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		signature := fileSignature(info)
+		storedSignature, err := f.stateStorage.GetSignature(f.sourceId, f.StateKey(), name)
+		if err != nil {
+			return nil, fmt.Errorf("Files error getting file [%s] state: %v", name, err)
+		}
+		if storedSignature == signature {
+			continue
+		}
+
+		fileObjects, err := f.parseFile(fs, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range fileObjects {
+			object[sourceFileKey] = path.Base(filepath.ToSlash(name))
+		}
+		objects = append(objects, fileObjects...)
+		f.pending = append(f.pending, &pendingFile{name: name, signature: signature})
+	}
+
+	return objects, nil
+}
+
+//Commit mark parsed files as processed
+func (f *Files) Commit() error {
+	f.Lock()
+	defer f.Unlock()
+
+	pending := f.pending
+	f.pending = nil
+	for _, file := range pending {
+		if err := f.stateStorage.SaveSignature(f.sourceId, f.StateKey(), file.name, file.signature); err != nil {
+			return fmt.Errorf("Files error saving file [%s] state: %v", file.name, err)
+		}
+	}
+
+	return nil
+}
+
+//Rollback forget parsed files: they will be parsed again in the next sync
+func (f *Files) Rollback() error {
+	f.Lock()
+	f.pending = nil
+	f.Unlock()
+
+	return nil
+}
+
+func (f *Files) Type() string {
+	return filesType
+}
+
+func (f *Files) Close() error {
+	return nil
+}
+
+//StateKey return collection key of processed files signatures in StateStorage
+func (f *Files) StateKey() string {
+	return filesType + "_" + f.collection.Name
+}
+
+//parseFile parse CSV or JSONL file (format is detected by file extension if it isn't configured)
+func (f *Files) parseFile(fs fileSystem, name string) ([]map[string]interface{}, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("Files error opening file [%s]: %v", name, err)
+	}
+	defer file.Close()
+
+	format := f.collectionConfig.Format
+	if format == "" {
+		if strings.HasSuffix(strings.ToLower(name), "."+JsonlFormat) {
+			format = JsonlFormat
+		} else {
+			format = CsvFormat
+		}
+	}
+
+	var objects []map[string]interface{}
+	if format == JsonlFormat {
+		objects, err = parsers.ParseJsonLines(file)
+	} else {
+		delimiter, _ := utf8.DecodeRuneInString(f.collectionConfig.Delimiter)
+		objects, err = parsers.ParseCsvWithDelimiter(file, delimiter, f.typeConverts)
+		if err == io.EOF {
+			//empty file
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Files error parsing [%s] file [%s]: %v", format, name, err)
+	}
+
+	return objects, nil
+}
+
+func (f *Files) openFileSystem() (fileSystem, error) {
+	if f.config.Sftp == nil {
+		if _, err := os.Stat(f.config.Path); err != nil {
+			return nil, fmt.Errorf("Files error accessing path [%s]: %v", f.config.Path, err)
+		}
+		return localFileSystem{}, nil
+	}
+
+	return newSftpFileSystem(f.config.Sftp)
+}
+
+//fileSignature return file modification time and size: modified files are processed again
+func fileSignature(info os.FileInfo) string {
+	return info.ModTime().UTC().Format(time.RFC3339Nano) + "_" + strconv.FormatInt(info.Size(), 10)
+}
+
+//joinPath join paths with "/" on SFTP servers and with OS separator locally
+func joinPath(remote bool, dir, name string) string {
+	if remote {
+		return path.Join(dir, name)
+	}
+
+	return filepath.Join(dir, name)
+}
+
+type localFileSystem struct{}
+
+func (localFileSystem) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (localFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (localFileSystem) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (localFileSystem) Close() error {
+	return nil
+}
+
+type sftpFileSystem struct {
+	sshClient *ssh.Client
+	client    *sftp.Client
+}
+
+func newSftpFileSystem(config *SftpConfig) (*sftpFileSystem, error) {
+	var auth []ssh.AuthMethod
+	if config.PrivateKey != "" {
+		key := []byte(config.PrivateKey)
+		if !strings.HasPrefix(config.PrivateKey, "-----") {
+			var err error
+			key, err = ioutil.ReadFile(config.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("SFTP error reading private key file: %v", err)
+			}
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("SFTP error parsing private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if config.HostKey != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("SFTP error parsing host_key: %v", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	}
+
+	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	sshClient, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            config.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SFTP error connecting to [%s]: %v", address, err)
+	}
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("SFTP error creating client: %v", err)
+	}
+
+	return &sftpFileSystem{sshClient: sshClient, client: client}, nil
+}
+
+func (sfs *sftpFileSystem) Glob(pattern string) ([]string, error) {
+	return sfs.client.Glob(pattern)
+}
+
+func (sfs *sftpFileSystem) Stat(name string) (os.FileInfo, error) {
+	return sfs.client.Stat(name)
+}
+
+func (sfs *sftpFileSystem) Open(name string) (io.ReadCloser, error) {
+	return sfs.client.Open(name)
+}
+
+func (sfs *sftpFileSystem) Close() error {
+	sfs.client.Close()
+	return sfs.sshClient.Close()
+}
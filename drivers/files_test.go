@@ -0,0 +1,69 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testStateStorage map[string]string
+
+func (tss testStateStorage) GetSignature(sourceId, collection, interval string) (string, error) {
+	return tss[sourceId+collection+interval], nil
+}
+
+func (tss testStateStorage) SaveSignature(sourceId, collection, interval, signature string) error {
+	tss[sourceId+collection+interval] = signature
+	return nil
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files_driver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.csv"), []byte("id;Full Name;active\n1;John;true\n2;Ann;false\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.jsonl"), []byte("{\"id\":3}\n\n{\"id\":4}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.txt"), []byte("skipped"), 0644))
+
+	driver, err := NewFiles(context.Background(), &SourceConfig{Config: map[string]interface{}{"path": dir}},
+		&Collection{Name: "drops", Parameters: map[string]interface{}{
+			"glob":       "*.*sv*",
+			"delimiter":  ";",
+			"type_hints": map[string]interface{}{"id": "integer", "active": "boolean"},
+		}})
+	require.NoError(t, err)
+	files := driver.(*Files)
+	files.SetStateStorage("source", testStateStorage{})
+
+	objects, err := files.GetObjectsFor(nil)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"id": int64(1), "full_name": "John", "active": true, "source_file": "a.csv"},
+		{"id": int64(2), "full_name": "Ann", "active": false, "source_file": "a.csv"},
+	}, objects)
+
+	//not committed files are parsed again
+	require.NoError(t, files.Rollback())
+	objects, err = files.GetObjectsFor(nil)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	require.NoError(t, files.Commit())
+	objects, err = files.GetObjectsFor(nil)
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	jsonlDriver, err := NewFiles(context.Background(), &SourceConfig{Config: map[string]interface{}{"path": dir}},
+		&Collection{Name: "jsonl_drops", Parameters: map[string]interface{}{"glob": "*.jsonl"}})
+	require.NoError(t, err)
+	jsonlDriver.(*Files).SetStateStorage("source", testStateStorage{})
+
+	objects, err = jsonlDriver.GetObjectsFor(nil)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, "b.jsonl", objects[1]["source_file"])
+}
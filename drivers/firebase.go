@@ -100,7 +100,7 @@ func (f *Firebase) GetObjectsFor(interval *TimeInterval) ([]map[string]interface
 	} else if f.collection.Type == usersCollection {
 		return f.loadUsers()
 	}
-	return nil, fmt.Errorf("unknown collection: %s", f.collection)
+	return nil, fmt.Errorf("unknown collection: %s", f.collection.Name)
 }
 
 func (f *Firebase) loadCollection(firestoreCollectionName string) ([]map[string]interface{}, error) {
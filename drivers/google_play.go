@@ -10,6 +10,8 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/jitsucom/eventnative/typing"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"io/ioutil"
@@ -23,6 +25,11 @@ const (
 
 	salesCollection    = "sales"
 	earningsCollection = "earnings"
+	installsCollection = "installs"
+
+	//installs overview reports: stats/installs/installs_<package name>_<yyyyMM>_overview.csv
+	installsPrefix = "stats/installs/installs_"
+	installsSuffix = "_overview.csv"
 
 	//"yyyyMM"
 	intervalLayout = "200601"
@@ -43,6 +50,19 @@ var (
 		"currency_conversion_rate":   typing.StringWithCommasToFloat,
 		"amount__merchant_currency_": typing.StringWithCommasToFloat,
 	}
+
+	installsTypeCasts = map[string]func(interface{}) (interface{}, error){
+		"daily_device_installs":   typing.StringToInt,
+		"daily_device_uninstalls": typing.StringToInt,
+		"daily_device_upgrades":   typing.StringToInt,
+		"total_user_installs":     typing.StringToInt,
+		"daily_user_installs":     typing.StringToInt,
+		"daily_user_uninstalls":   typing.StringToInt,
+		"active_device_installs":  typing.StringToInt,
+		"install_events":          typing.StringToInt,
+		"update_events":           typing.StringToInt,
+		"uninstall_events":        typing.StringToInt,
+	}
 )
 
 type GooglePlayConfig struct {
@@ -104,6 +124,10 @@ func (gp *GooglePlay) GetAllAvailableIntervals() ([]*TimeInterval, error) {
 	bucketName := bucketPrefix + gp.config.AccountId
 	bucket := gp.client.Bucket(bucketName)
 
+	if gp.collection.Type == installsCollection {
+		return gp.getInstallsIntervals(bucket, bucketName)
+	}
+
 	it := bucket.Objects(gp.ctx, &storage.Query{Prefix: gp.collection.Name})
 	var intervals []*TimeInterval
 	for {
@@ -129,7 +153,7 @@ func (gp *GooglePlay) GetAllAvailableIntervals() ([]*TimeInterval, error) {
 			}
 			intervalStr = nameParts[1]
 		} else {
-			return nil, fmt.Errorf("GooglePlay unknown collection: %s", gp.collection)
+			return nil, fmt.Errorf("GooglePlay unknown collection: %s", gp.collection.Name)
 		}
 
 		t, err := time.Parse(intervalLayout, intervalStr)
@@ -155,8 +179,10 @@ func (gp *GooglePlay) GetObjectsFor(interval *TimeInterval) ([]map[string]interf
 	} else if gp.collection.Type == earningsCollection {
 		prefix := "earnings/earnings_" + interval.LowerEndpoint().Format(intervalLayout)
 		objects, err = gp.getFilesObjects(bucket, prefix)
+	} else if gp.collection.Type == installsCollection {
+		objects, err = gp.getInstallsObjects(bucket, interval.LowerEndpoint().Format(intervalLayout))
 	} else {
-		return nil, fmt.Errorf("GooglePlay unknown collection: %s", gp.collection)
+		return nil, fmt.Errorf("GooglePlay unknown collection: %s", gp.collection.Name)
 	}
 
 	if err != nil {
@@ -233,6 +259,89 @@ func (gp *GooglePlay) getFileObjects(bucket *storage.BucketHandle, key string) (
 	return objects, nil
 }
 
+//getInstallsIntervals return month intervals of installs overview reports of all the packages
+func (gp *GooglePlay) getInstallsIntervals(bucket *storage.BucketHandle, bucketName string) ([]*TimeInterval, error) {
+	months := map[string]bool{}
+	var intervals []*TimeInterval
+
+	it := bucket.Objects(gp.ctx, &storage.Query{Prefix: installsPrefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GooglePlay Error reading object from gcp bucket [%s]: %v", bucketName, err)
+		}
+
+		month, ok := parseInstallsReportMonth(attrs.Name)
+		if !ok || months[month] {
+			continue
+		}
+		months[month] = true
+
+		t, err := time.Parse(intervalLayout, month)
+		if err != nil {
+			return nil, fmt.Errorf("GooglePlay file on gcp has wrong interval layout: %s", attrs.Name)
+		}
+
+		intervals = append(intervals, NewTimeInterval(MONTH, t))
+	}
+
+	return intervals, nil
+}
+
+//getInstallsObjects return daily rows of installs overview reports of all the packages for the month
+//reports are UTF-16 encoded CSV files (not zipped)
+func (gp *GooglePlay) getInstallsObjects(bucket *storage.BucketHandle, month string) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+
+	it := bucket.Objects(gp.ctx, &storage.Query{Prefix: installsPrefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if reportMonth, ok := parseInstallsReportMonth(attrs.Name); !ok || reportMonth != month {
+			continue
+		}
+
+		r, err := bucket.Object(attrs.Name).NewReader(gp.ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := parsers.ParseCsv(transform.NewReader(r, unicode.BOMOverride(unicode.UTF8.NewDecoder())), installsTypeCasts)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing [%s]: %v", attrs.Name, err)
+		}
+
+		objects = append(objects, parsed...)
+	}
+
+	return objects, nil
+}
+
+//parseInstallsReportMonth return yyyyMM from installs overview report file name
+//package name can contain underscores so the month is extracted from the end
+func parseInstallsReportMonth(name string) (string, bool) {
+	if !strings.HasPrefix(name, installsPrefix) || !strings.HasSuffix(name, installsSuffix) {
+		return "", false
+	}
+
+	nameParts := strings.Split(strings.TrimSuffix(name, installsSuffix), "_")
+	if len(nameParts) < 3 {
+		return "", false
+	}
+
+	return nameParts[len(nameParts)-1], true
+}
+
 func (gp *GooglePlay) Type() string {
 	return googlePlayType
 }
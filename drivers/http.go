@@ -0,0 +1,92 @@
+package drivers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxRateLimitedAttempts = 3
+
+var defaultHttpClient = &http.Client{Timeout: 1 * time.Minute}
+
+//doJsonRequest send request with JSON body (if not nil) and decode JSON response into result
+//authorize func sets authorization headers
+//requests are throttled per API host (see rateLimiter). Rate limited requests (HTTP 429) are retried after Retry-After delay
+func doJsonRequest(method, url string, body interface{}, authorize func(*http.Request), result interface{}) error {
+	_, err := doJsonRequestWithHeaders(method, url, body, authorize, result)
+	return err
+}
+
+//doJsonRequestWithHeaders is a doJsonRequest which also return response headers (e.g. for Link header pagination)
+func doJsonRequestWithHeaders(method, url string, body interface{}, authorize func(*http.Request), result interface{}) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("Error marshalling request body: %v", err)
+		}
+		payload = b
+	}
+
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating request [%s]: %v", url, err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		authorize(req)
+
+		resp, err := doRateLimitedRequest(req)
+		if err != nil {
+			return nil, fmt.Errorf("Error requesting [%s]: %v", url, err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading response [%s]: %v", url, err)
+		}
+		//rate limiter pauses the next attempt
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitedAttempts {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Error response [%s] code: %d body: %s", url, resp.StatusCode, string(respBody))
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(respBody))
+		decoder.UseNumber()
+		if err := decoder.Decode(result); err != nil {
+			return nil, fmt.Errorf("Error parsing response [%s]: %v", url, err)
+		}
+
+		return resp.Header, nil
+	}
+}
+
+//retryAfter return Retry-After header delay in seconds or 1 second by default (max 1 minute)
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return time.Second
+	}
+	if seconds > 60 {
+		seconds = 60
+	}
+
+	return time.Duration(seconds) * time.Second
+}
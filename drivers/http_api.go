@@ -0,0 +1,469 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	httpApiType = "http_api"
+
+	httpApiBearerAuth = "bearer"
+	httpApiBasicAuth  = "basic"
+	httpApiHeaderAuth = "header"
+	httpApiQueryAuth  = "query"
+
+	httpApiNoPagination         = "none"
+	httpApiCursorPagination     = "cursor"
+	httpApiOffsetPagination     = "offset"
+	httpApiLinkHeaderPagination = "link_header"
+
+	httpApiDefaultPageSize    = 100
+	httpApiDefaultCursorParam = "cursor"
+	httpApiDefaultOffsetParam = "offset"
+	httpApiDefaultLimitParam  = "limit"
+)
+
+//HttpApiAuthConfig is an authorization of generic REST API:
+//bearer - Authorization: Bearer <token>
+//basic - basic auth with username and password
+//header - <name>: <token> header
+//query - <name>=<token> query parameter
+type HttpApiAuthConfig struct {
+	Type     string `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+	Token    string `mapstructure:"token" json:"token,omitempty" yaml:"token,omitempty"`
+	Username string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	Name     string `mapstructure:"name" json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+func (hac *HttpApiAuthConfig) Validate() error {
+	if hac == nil {
+		return nil
+	}
+
+	switch hac.Type {
+	case httpApiBearerAuth:
+		if hac.Token == "" {
+			return errors.New("HTTP API auth token is required for bearer auth")
+		}
+	case httpApiBasicAuth:
+		if hac.Username == "" {
+			return errors.New("HTTP API auth username is required for basic auth")
+		}
+	case httpApiHeaderAuth, httpApiQueryAuth:
+		if hac.Name == "" || hac.Token == "" {
+			return fmt.Errorf("HTTP API auth name and token are required for %s auth", hac.Type)
+		}
+	default:
+		return fmt.Errorf("HTTP API unknown auth type [%s]. Available types: [%s, %s, %s, %s]", hac.Type, httpApiBearerAuth, httpApiBasicAuth, httpApiHeaderAuth, httpApiQueryAuth)
+	}
+
+	return nil
+}
+
+//HttpApiConfig is a generic REST API source config
+//start_date (YYYY-MM-DD) is used as "updated since" in the first sync of incremental collections. Default - all the records
+type HttpApiConfig struct {
+	BaseUrl   string             `mapstructure:"base_url" json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	Headers   map[string]string  `mapstructure:"headers" json:"headers,omitempty" yaml:"headers,omitempty"`
+	Auth      *HttpApiAuthConfig `mapstructure:"auth" json:"auth,omitempty" yaml:"auth,omitempty"`
+	StartDate string             `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (hc *HttpApiConfig) Validate() error {
+	if hc == nil {
+		return errors.New("HTTP API config is required")
+	}
+	if hc.BaseUrl == "" {
+		return errors.New("HTTP API base_url is required parameter")
+	}
+	if _, err := url.Parse(hc.BaseUrl); err != nil {
+		return fmt.Errorf("HTTP API malformed base_url [%s]: %v", hc.BaseUrl, err)
+	}
+	hc.BaseUrl = strings.TrimSuffix(hc.BaseUrl, "/")
+
+	return hc.Auth.Validate()
+}
+
+//HttpApiPaginationConfig is a pagination style of the collection endpoint:
+//none - the single request
+//cursor - the next page cursor is taken from cursor_path of the response and sent as cursor_param (default: cursor).
+//If the cursor is an absolute URL it is requested as is
+//offset - offset_param (default: offset) is increased by the number of received records, page_size is sent as limit_param (default: limit)
+//link_header - the next page URL is taken from Link: <url>; rel="next" response header
+type HttpApiPaginationConfig struct {
+	Type        string `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+	CursorPath  string `mapstructure:"cursor_path" json:"cursor_path,omitempty" yaml:"cursor_path,omitempty"`
+	CursorParam string `mapstructure:"cursor_param" json:"cursor_param,omitempty" yaml:"cursor_param,omitempty"`
+	OffsetParam string `mapstructure:"offset_param" json:"offset_param,omitempty" yaml:"offset_param,omitempty"`
+	LimitParam  string `mapstructure:"limit_param" json:"limit_param,omitempty" yaml:"limit_param,omitempty"`
+	PageSize    int    `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+	MaxPages    int    `mapstructure:"max_pages" json:"max_pages,omitempty" yaml:"max_pages,omitempty"`
+}
+
+func (hpc *HttpApiPaginationConfig) Validate() error {
+	if hpc.Type == "" {
+		hpc.Type = httpApiNoPagination
+	}
+	if hpc.PageSize <= 0 {
+		hpc.PageSize = httpApiDefaultPageSize
+	}
+	if hpc.MaxPages < 0 {
+		return errors.New("HTTP API pagination max_pages can't be negative")
+	}
+
+	switch hpc.Type {
+	case httpApiNoPagination, httpApiLinkHeaderPagination:
+	case httpApiCursorPagination:
+		if hpc.CursorPath == "" {
+			return errors.New("HTTP API pagination cursor_path is required for cursor pagination")
+		}
+		if hpc.CursorParam == "" {
+			hpc.CursorParam = httpApiDefaultCursorParam
+		}
+	case httpApiOffsetPagination:
+		if hpc.OffsetParam == "" {
+			hpc.OffsetParam = httpApiDefaultOffsetParam
+		}
+		if hpc.LimitParam == "" {
+			hpc.LimitParam = httpApiDefaultLimitParam
+		}
+	default:
+		return fmt.Errorf("HTTP API unknown pagination type [%s]. Available types: [%s, %s, %s, %s]", hpc.Type, httpApiNoPagination, httpApiCursorPagination, httpApiOffsetPagination, httpApiLinkHeaderPagination)
+	}
+
+	return nil
+}
+
+//HttpApiCollectionConfig is a collection parameters: endpoint path, JSON path to records array in the response
+//(empty - the response is an array) and optional incremental_field - JSON path of record modification time
+//(RFC3339 string or unix timestamp). If incremental_param is set the cursor is sent as the query parameter (RFC3339)
+type HttpApiCollectionConfig struct {
+	Path             string                   `mapstructure:"path" json:"path,omitempty" yaml:"path,omitempty"`
+	Params           map[string]string        `mapstructure:"params" json:"params,omitempty" yaml:"params,omitempty"`
+	RecordsPath      string                   `mapstructure:"records_path" json:"records_path,omitempty" yaml:"records_path,omitempty"`
+	Pagination       *HttpApiPaginationConfig `mapstructure:"pagination" json:"pagination,omitempty" yaml:"pagination,omitempty"`
+	IncrementalField string                   `mapstructure:"incremental_field" json:"incremental_field,omitempty" yaml:"incremental_field,omitempty"`
+	IncrementalParam string                   `mapstructure:"incremental_param" json:"incremental_param,omitempty" yaml:"incremental_param,omitempty"`
+}
+
+func (hcc *HttpApiCollectionConfig) Validate() error {
+	if hcc.Pagination == nil {
+		hcc.Pagination = &HttpApiPaginationConfig{}
+	}
+	if hcc.IncrementalParam != "" && hcc.IncrementalField == "" {
+		return errors.New("HTTP API incremental_field is required when incremental_param is set")
+	}
+
+	return hcc.Pagination.Validate()
+}
+
+//HttpApi is a driver for full syncing generic REST API collections
+type HttpApi struct {
+	config           *HttpApiConfig
+	collectionConfig *HttpApiCollectionConfig
+	ctx              context.Context
+
+	recordsPath      *jsonutils.JsonPath
+	cursorPath       *jsonutils.JsonPath
+	incrementalField *jsonutils.JsonPath
+
+	collection *Collection
+}
+
+//IncrementalHttpApi is a driver for incremental syncing generic REST API collections:
+//records modified after the last sync cursor are loaded
+type IncrementalHttpApi struct {
+	incrementalCursor
+	*HttpApi
+}
+
+func init() {
+	if err := RegisterDriverConstructor(httpApiType, NewHttpApi); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", httpApiType, err)
+	}
+}
+
+//NewHttpApi return IncrementalHttpApi if incremental_field is configured otherwise HttpApi
+func NewHttpApi(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &HttpApiConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &HttpApiCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if err := collectionConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	api := &HttpApi{
+		config:           config,
+		collectionConfig: collectionConfig,
+		ctx:              ctx,
+		recordsPath:      jsonutils.NewJsonPath(collectionConfig.RecordsPath),
+		cursorPath:       jsonutils.NewJsonPath(collectionConfig.Pagination.CursorPath),
+		incrementalField: jsonutils.NewJsonPath(collectionConfig.IncrementalField),
+		collection:       collection,
+	}
+
+	if collectionConfig.IncrementalField == "" {
+		return api, nil
+	}
+
+	return &IncrementalHttpApi{
+		incrementalCursor: incrementalCursor{stateKey: httpApiType + "_" + collection.Name, initial: initial},
+		HttpApi:           api,
+	}, nil
+}
+
+func (ha *HttpApi) GetCollectionTable() string {
+	return ha.collection.GetTableName()
+}
+
+func (ha *HttpApi) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return all the records of the collection
+func (ha *HttpApi) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	objects, _, err := ha.load(time.Time{})
+	return objects, err
+}
+
+func (ha *HttpApi) Type() string {
+	return httpApiType
+}
+
+func (ha *HttpApi) Close() error {
+	return nil
+}
+
+//GetObjectsFor return records modified after the cursor. The max incremental_field value is used as the next cursor
+func (iha *IncrementalHttpApi) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := iha.get()
+	if err != nil {
+		return nil, fmt.Errorf("HTTP API %v", err)
+	}
+
+	objects, maxUpdated, err := iha.load(since)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxUpdated.After(since) {
+		iha.setPending(maxUpdated)
+	}
+
+	return objects, nil
+}
+
+//load request all the pages and return records modified after since (if incremental_field is configured)
+//and the max incremental_field value
+func (ha *HttpApi) load(since time.Time) ([]map[string]interface{}, time.Time, error) {
+	pagination := ha.collectionConfig.Pagination
+
+	query := url.Values{}
+	for name, value := range ha.collectionConfig.Params {
+		query.Set(name, value)
+	}
+	if ha.collectionConfig.IncrementalParam != "" && !since.IsZero() {
+		query.Set(ha.collectionConfig.IncrementalParam, since.UTC().Format(time.RFC3339))
+	}
+	if pagination.Type == httpApiOffsetPagination {
+		query.Set(pagination.LimitParam, fmt.Sprint(pagination.PageSize))
+	}
+
+	endpoint := ha.config.BaseUrl + "/" + strings.TrimPrefix(ha.collectionConfig.Path, "/")
+	nextUrl := buildUrl(endpoint, query)
+
+	var objects []map[string]interface{}
+	maxUpdated := since
+	offset := 0
+	for page := 1; ; page++ {
+		var response interface{}
+		headers, err := doJsonRequestWithHeaders(http.MethodGet, nextUrl, nil, ha.authorize, &response)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("HTTP API error getting records: %v", err)
+		}
+
+		records, err := ha.extractRecords(response)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		for _, record := range records {
+			if !ha.incrementalField.IsEmpty() {
+				if updated, ok := ha.recordTime(record); ok {
+					if !since.IsZero() && !updated.After(since) {
+						continue
+					}
+					if updated.After(maxUpdated) {
+						maxUpdated = updated
+					}
+				}
+			}
+			objects = append(objects, record)
+		}
+
+		if len(records) == 0 || (pagination.MaxPages > 0 && page >= pagination.MaxPages) {
+			break
+		}
+
+		switch pagination.Type {
+		case httpApiCursorPagination:
+			cursor := ha.extractCursor(response)
+			if cursor == "" {
+				return objects, maxUpdated, nil
+			}
+			if strings.HasPrefix(cursor, "http://") || strings.HasPrefix(cursor, "https://") {
+				nextUrl = cursor
+			} else {
+				query.Set(pagination.CursorParam, cursor)
+				nextUrl = buildUrl(endpoint, query)
+			}
+		case httpApiOffsetPagination:
+			if len(records) < pagination.PageSize {
+				return objects, maxUpdated, nil
+			}
+			offset += len(records)
+			query.Set(pagination.OffsetParam, fmt.Sprint(offset))
+			nextUrl = buildUrl(endpoint, query)
+		case httpApiLinkHeaderPagination:
+			nextUrl = nextLink(headers.Get("Link"))
+			if nextUrl == "" {
+				return objects, maxUpdated, nil
+			}
+		default:
+			return objects, maxUpdated, nil
+		}
+	}
+
+	return objects, maxUpdated, nil
+}
+
+//extractRecords return objects array from records_path of the response (or the response itself if records_path is empty)
+func (ha *HttpApi) extractRecords(response interface{}) ([]map[string]interface{}, error) {
+	value := response
+	if !ha.recordsPath.IsEmpty() {
+		obj, ok := response.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("HTTP API response isn't a JSON object: records_path [%s] can't be applied", ha.collectionConfig.RecordsPath)
+		}
+		value, ok = ha.recordsPath.Get(obj)
+		if !ok || value == nil {
+			return nil, nil
+		}
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("HTTP API records [%s] aren't a JSON array", ha.collectionConfig.RecordsPath)
+	}
+
+	records := make([]map[string]interface{}, 0, len(array))
+	for _, item := range array {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("HTTP API record isn't a JSON object: %v", item)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+//extractCursor return the next page cursor from cursor_path of the response or empty string
+func (ha *HttpApi) extractCursor(response interface{}) string {
+	obj, ok := response.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, ok := ha.cursorPath.Get(obj)
+	if !ok || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+//recordTime return incremental_field value of the record: RFC3339 string or unix timestamp in seconds or milliseconds
+func (ha *HttpApi) recordTime(record map[string]interface{}) (time.Time, bool) {
+	value, ok := ha.incrementalField.Get(record)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return parseCursorTime(value)
+}
+
+func (ha *HttpApi) authorize(r *http.Request) {
+	for name, value := range ha.config.Headers {
+		r.Header.Set(name, value)
+	}
+
+	auth := ha.config.Auth
+	if auth == nil {
+		return
+	}
+	switch auth.Type {
+	case httpApiBearerAuth:
+		r.Header.Set("Authorization", "Bearer "+auth.Token)
+	case httpApiBasicAuth:
+		r.SetBasicAuth(auth.Username, auth.Password)
+	case httpApiHeaderAuth:
+		r.Header.Set(auth.Name, auth.Token)
+	case httpApiQueryAuth:
+		query := r.URL.Query()
+		query.Set(auth.Name, auth.Token)
+		r.URL.RawQuery = query.Encode()
+	}
+}
+
+func buildUrl(endpoint string, query url.Values) string {
+	if len(query) == 0 {
+		return endpoint
+	}
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	return endpoint + separator + query.Encode()
+}
+
+//nextLink return rel="next" URL from Link header value: <https://api/items?page=2>; rel="next", <...>; rel="last"
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == `rel="next"` || param == "rel=next" {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+
+	return ""
+}
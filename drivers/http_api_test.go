@@ -0,0 +1,127 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpApiPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/cursor":
+			if query.Get("after") == "" {
+				w.Write([]byte(`{"data":{"items":[{"id":1}]},"meta":{"next":"abc"}}`))
+			} else {
+				require.Equal(t, "abc", query.Get("after"))
+				w.Write([]byte(`{"data":{"items":[{"id":2}]},"meta":{"next":null}}`))
+			}
+		case "/offset":
+			require.Equal(t, "2", query.Get("limit"))
+			switch query.Get("offset") {
+			case "":
+				w.Write([]byte(`[{"id":1},{"id":2}]`))
+			case "2":
+				w.Write([]byte(`[{"id":3}]`))
+			default:
+				t.Fatalf("unexpected offset: %s", query.Get("offset"))
+			}
+		case "/link":
+			if query.Get("page") == "" {
+				w.Header().Set("Link", `<http://`+r.Host+`/link?page=2>; rel="next", <http://`+r.Host+`/link?page=2>; rel="last"`)
+				w.Write([]byte(`[{"id":1}]`))
+			} else {
+				w.Write([]byte(`[{"id":2}]`))
+			}
+		}
+	}))
+	defer server.Close()
+
+	sourceConfig := &SourceConfig{Config: map[string]interface{}{"base_url": server.URL, "auth": map[string]interface{}{"type": "bearer", "token": "token"}}}
+	tests := []struct {
+		name       string
+		parameters map[string]interface{}
+		expected   int
+	}{
+		{
+			"cursor",
+			map[string]interface{}{"path": "/cursor", "records_path": "/data/items", "pagination": map[string]interface{}{"type": "cursor", "cursor_path": "/meta/next", "cursor_param": "after"}},
+			2,
+		},
+		{
+			"offset",
+			map[string]interface{}{"path": "offset", "pagination": map[string]interface{}{"type": "offset", "page_size": 2}},
+			3,
+		},
+		{
+			"link header",
+			map[string]interface{}{"path": "/link", "pagination": map[string]interface{}{"type": "link_header"}},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, err := NewHttpApi(context.Background(), sourceConfig, &Collection{Name: "items", Parameters: tt.parameters})
+			require.NoError(t, err)
+			_, ok := driver.(TransactionalDriver)
+			require.False(t, ok, "full sync driver shouldn't be transactional")
+
+			objects, err := driver.GetObjectsFor(allIntervals()[0])
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, len(objects))
+		})
+	}
+}
+
+func TestHttpApiIncrementalSync(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		require.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		w.Write([]byte(`{"records":[{"id":1,"updated_at":"2020-12-31T00:00:00Z"},{"id":2,"updated_at":"2021-01-02T10:00:00Z"},{"id":3,"updated_at":1609761600}]}`))
+	}))
+	defer server.Close()
+
+	driver, err := NewHttpApi(context.Background(),
+		&SourceConfig{Config: map[string]interface{}{"base_url": server.URL + "/", "start_date": "2021-01-01",
+			"auth": map[string]interface{}{"type": "header", "name": "X-Api-Key", "token": "secret"}}},
+		&Collection{Name: "records", Parameters: map[string]interface{}{"path": "records", "records_path": "records",
+			"incremental_field": "updated_at", "incremental_param": "updated_since", "params": map[string]interface{}{"status": "all"}}})
+	require.NoError(t, err)
+	api, ok := driver.(*IncrementalHttpApi)
+	require.True(t, ok, "driver should be incremental")
+	api.SetStateStorage("source", testStateStorage{})
+
+	objects, err := api.GetObjectsFor(allIntervals()[0])
+	require.NoError(t, err)
+	require.Equal(t, 2, len(objects))
+	require.Equal(t, "2", objects[0]["id"].(interface{ String() string }).String())
+	require.Equal(t, "status=all&updated_since=2021-01-01T00%3A00%3A00Z", requests[0])
+
+	require.NoError(t, api.Commit())
+	cursor, err := api.get()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC), cursor.UTC())
+
+	objects, err = api.GetObjectsFor(allIntervals()[0])
+	require.NoError(t, err)
+	require.Empty(t, objects)
+	require.Equal(t, "status=all&updated_since=2021-01-04T12%3A00%3A00Z", requests[1])
+}
+
+func TestHttpApiValidation(t *testing.T) {
+	_, err := NewHttpApi(context.Background(), &SourceConfig{Config: map[string]interface{}{}}, &Collection{Name: "c"})
+	require.EqualError(t, err, "HTTP API base_url is required parameter")
+
+	_, err = NewHttpApi(context.Background(), &SourceConfig{Config: map[string]interface{}{"base_url": "http://api"}},
+		&Collection{Name: "c", Parameters: map[string]interface{}{"pagination": map[string]interface{}{"type": "page"}}})
+	require.EqualError(t, err, "HTTP API unknown pagination type [page]. Available types: [none, cursor, offset, link_header]")
+
+	require.Equal(t, "https://api/items?page=3", nextLink(`<https://api/items?page=1>; rel="prev", <https://api/items?page=3>; rel="next"`))
+	require.Equal(t, "", nextLink(`<https://api/items?page=1>; rel="prev"`))
+}
@@ -0,0 +1,36 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoJsonRequestRetriesRateLimited(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"value": 1}`))
+	}))
+	defer server.Close()
+
+	result := map[string]interface{}{}
+	err := doJsonRequest(http.MethodGet, server.URL, nil, func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer token")
+	}, &result)
+	require.NoError(t, err)
+	require.Equal(t, 2, requests)
+	require.Equal(t, "1", result["value"].(interface{ String() string }).String())
+
+	require.Equal(t, time.Second, retryAfter(""))
+	require.Equal(t, 5*time.Second, retryAfter("5"))
+	require.Equal(t, time.Minute, retryAfter("3600"))
+}
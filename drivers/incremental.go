@@ -0,0 +1,115 @@
+package drivers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const incrementalCursorKey = "updated_since"
+
+//incrementalCursor keeps "updated since" time of incremental drivers in StateStorage
+//new cursor value is saved only after successful delivery (see TransactionalDriver)
+type incrementalCursor struct {
+	sync.Mutex
+
+	sourceId     string
+	stateKey     string
+	stateStorage StateStorage
+	initial      time.Time
+
+	pending *time.Time
+}
+
+//SetStateStorage is used for keeping cursor
+func (ic *incrementalCursor) SetStateStorage(sourceId string, storage StateStorage) {
+	ic.sourceId = sourceId
+	ic.stateStorage = storage
+}
+
+//StateKey return collection key of the cursor in StateStorage
+func (ic *incrementalCursor) StateKey() string {
+	return ic.stateKey
+}
+
+//get return stored cursor or initial value
+func (ic *incrementalCursor) get() (time.Time, error) {
+	if ic.stateStorage == nil {
+		return time.Time{}, errors.New("state storage isn't configured")
+	}
+
+	value, err := ic.stateStorage.GetSignature(ic.sourceId, ic.stateKey, incrementalCursorKey)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error getting cursor: %v", err)
+	}
+	if value == "" {
+		return ic.initial, nil
+	}
+
+	cursor, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error parsing cursor [%s]: %v", value, err)
+	}
+
+	return cursor, nil
+}
+
+//setPending remember new cursor value which will be saved in Commit
+func (ic *incrementalCursor) setPending(cursor time.Time) {
+	ic.Lock()
+	ic.pending = &cursor
+	ic.Unlock()
+}
+
+//Commit save pending cursor
+func (ic *incrementalCursor) Commit() error {
+	ic.Lock()
+	defer ic.Unlock()
+
+	if ic.pending == nil {
+		return nil
+	}
+	cursor := *ic.pending
+	ic.pending = nil
+
+	if err := ic.stateStorage.SaveSignature(ic.sourceId, ic.stateKey, incrementalCursorKey, cursor.UTC().Format(time.RFC3339Nano)); err != nil {
+		return fmt.Errorf("Error saving cursor: %v", err)
+	}
+
+	return nil
+}
+
+//Rollback forget pending cursor: the same objects will be loaded in the next sync
+func (ic *incrementalCursor) Rollback() error {
+	ic.Lock()
+	ic.pending = nil
+	ic.Unlock()
+
+	return nil
+}
+
+//parseCursorTime return time of the object cursor field value: RFC3339 string or unix timestamp in seconds or milliseconds
+func parseCursorTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		//milliseconds
+		if n > 1e12 {
+			return time.Unix(0, n*int64(time.Millisecond)).UTC(), true
+		}
+		return time.Unix(n, 0).UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
@@ -0,0 +1,276 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	jiraType = "jira"
+
+	jiraIssuesCollection   = "issues"
+	jiraWorklogsCollection = "worklogs"
+
+	jiraPageSize = 100
+	//Jira issue timestamps format e.g. 2020-12-24T12:00:00.000+0000
+	jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+	jiraSearchPath         = "/rest/api/2/search"
+	jiraUpdatedWorklogPath = "/rest/api/2/worklog/updated?since=%d"
+	jiraWorklogListPath    = "/rest/api/2/worklog/list"
+)
+
+//JiraConfig is a Jira Cloud/Server connection config (basic auth with email and API token)
+//start_date (YYYY-MM-DD) is used as "updated since" in the first sync. Default - all the objects
+type JiraConfig struct {
+	Url       string `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Email     string `mapstructure:"email" json:"email,omitempty" yaml:"email,omitempty"`
+	ApiToken  string `mapstructure:"api_token" json:"api_token,omitempty" yaml:"api_token,omitempty"`
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (jc *JiraConfig) Validate() error {
+	if jc == nil {
+		return errors.New("Jira config is required")
+	}
+	if jc.Url == "" {
+		return errors.New("Jira url is required parameter")
+	}
+	if jc.Email == "" {
+		return errors.New("Jira email is required parameter")
+	}
+	if jc.ApiToken == "" {
+		return errors.New("Jira api_token is required parameter")
+	}
+	jc.Url = strings.TrimSuffix(jc.Url, "/")
+
+	return nil
+}
+
+//JiraCollectionConfig is a collection parameters
+//jql is an additional issues filter (e.g. project = ABC), fields is a list of issue fields
+type JiraCollectionConfig struct {
+	Jql    string   `mapstructure:"jql" json:"jql,omitempty" yaml:"jql,omitempty"`
+	Fields []string `mapstructure:"fields" json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+type jiraSearchResponse struct {
+	StartAt int                      `json:"startAt"`
+	Total   int                      `json:"total"`
+	Issues  []map[string]interface{} `json:"issues"`
+}
+
+type jiraUpdatedWorklogsResponse struct {
+	Values []struct {
+		WorklogId json.Number `json:"worklogId"`
+	} `json:"values"`
+	Until    int64  `json:"until"`
+	LastPage bool   `json:"lastPage"`
+	NextPage string `json:"nextPage"`
+}
+
+//Jira is a driver for incremental syncing Jira issues and worklogs updated since the last sync
+type Jira struct {
+	incrementalCursor
+
+	config           *JiraConfig
+	collectionConfig *JiraCollectionConfig
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(jiraType, NewJira); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", jiraType, err)
+	}
+}
+
+func NewJira(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &JiraConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != jiraIssuesCollection && collection.Type != jiraWorklogsCollection {
+		return nil, fmt.Errorf("Jira unknown collection type [%s]. Available types: [%s, %s]", collection.Type, jiraIssuesCollection, jiraWorklogsCollection)
+	}
+
+	collectionConfig := &JiraCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if len(collectionConfig.Fields) == 0 {
+		collectionConfig.Fields = []string{"*navigable"}
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Jira{
+		incrementalCursor: incrementalCursor{stateKey: jiraType + "_" + collection.Name, initial: initial},
+		config:            config,
+		collectionConfig:  collectionConfig,
+		ctx:               ctx,
+		collection:        collection,
+	}, nil
+}
+
+func (j *Jira) GetCollectionTable() string {
+	return j.collection.GetTableName()
+}
+
+func (j *Jira) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return objects updated since the last sync
+func (j *Jira) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := j.get()
+	if err != nil {
+		return nil, fmt.Errorf("Jira %v", err)
+	}
+
+	if j.collection.Type == jiraWorklogsCollection {
+		return j.getWorklogs(since)
+	}
+
+	return j.getIssues(since)
+}
+
+func (j *Jira) Type() string {
+	return jiraType
+}
+
+func (j *Jira) Close() error {
+	return nil
+}
+
+//getIssues search issues with updated >= since ordered by updated
+//JQL relative minutes are used because JQL dates are interpreted in the Jira user timezone
+func (j *Jira) getIssues(since time.Time) ([]map[string]interface{}, error) {
+	var conditions []string
+	if j.collectionConfig.Jql != "" {
+		conditions = append(conditions, "("+j.collectionConfig.Jql+")")
+	}
+	if !since.IsZero() {
+		minutes := int64(math.Ceil(time.Since(since).Minutes())) + 1
+		conditions = append(conditions, fmt.Sprintf(`updated >= "-%dm"`, minutes))
+	}
+	jql := strings.TrimSpace(strings.Join(conditions, " AND ") + " ORDER BY updated ASC")
+
+	var objects []map[string]interface{}
+	maxUpdated := since
+	for startAt := 0; ; {
+		request := map[string]interface{}{
+			"jql":        jql,
+			"startAt":    startAt,
+			"maxResults": jiraPageSize,
+			"fields":     j.collectionConfig.Fields,
+		}
+		response := &jiraSearchResponse{}
+		if err := doJsonRequest(http.MethodPost, j.config.Url+jiraSearchPath, request, j.authorize, response); err != nil {
+			return nil, fmt.Errorf("Jira error searching issues: %v", err)
+		}
+
+		for _, issue := range response.Issues {
+			object := map[string]interface{}{}
+			if fields, ok := issue["fields"].(map[string]interface{}); ok {
+				for name, value := range fields {
+					object[name] = value
+				}
+			}
+			object["id"] = issue["id"]
+			object["key"] = issue["key"]
+			objects = append(objects, object)
+
+			if updated, ok := object["updated"].(string); ok {
+				if t, err := time.Parse(jiraTimeLayout, updated); err == nil && t.After(maxUpdated) {
+					maxUpdated = t
+				}
+			}
+		}
+
+		startAt += len(response.Issues)
+		if len(response.Issues) == 0 || startAt >= response.Total {
+			break
+		}
+	}
+
+	if maxUpdated.After(since) {
+		j.setPending(maxUpdated)
+	}
+
+	return objects, nil
+}
+
+//getWorklogs return worklogs updated since: ids of updated worklogs are requested page by page
+//and then worklogs are requested by ids
+func (j *Jira) getWorklogs(since time.Time) ([]map[string]interface{}, error) {
+	var sinceMs int64
+	if !since.IsZero() {
+		sinceMs = since.UnixNano() / int64(time.Millisecond)
+	}
+
+	var objects []map[string]interface{}
+	url := j.config.Url + fmt.Sprintf(jiraUpdatedWorklogPath, sinceMs)
+	for {
+		updated := &jiraUpdatedWorklogsResponse{}
+		if err := doJsonRequest(http.MethodGet, url, nil, j.authorize, updated); err != nil {
+			return nil, fmt.Errorf("Jira error getting updated worklogs: %v", err)
+		}
+
+		if len(updated.Values) > 0 {
+			var ids []json.Number
+			for _, value := range updated.Values {
+				ids = append(ids, value.WorklogId)
+			}
+
+			var worklogs []map[string]interface{}
+			if err := doJsonRequest(http.MethodPost, j.config.Url+jiraWorklogListPath, map[string]interface{}{"ids": ids}, j.authorize, &worklogs); err != nil {
+				return nil, fmt.Errorf("Jira error getting worklogs: %v", err)
+			}
+			objects = append(objects, worklogs...)
+		}
+
+		if updated.LastPage || updated.NextPage == "" {
+			if updated.Until > 0 {
+				j.setPending(time.Unix(0, updated.Until*int64(time.Millisecond)))
+			}
+			break
+		}
+		url = updated.NextPage
+	}
+
+	return objects, nil
+}
+
+func (j *Jira) authorize(r *http.Request) {
+	r.SetBasicAuth(j.config.Email, j.config.ApiToken)
+}
+
+//parseStartDate return zero time if value is empty or parsed YYYY-MM-DD date
+func parseStartDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(dayLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Malformed start_date [%s]. Expected format: YYYY-MM-DD", value)
+	}
+
+	return t, nil
+}
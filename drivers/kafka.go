@@ -0,0 +1,289 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"sync"
+	"time"
+)
+
+const (
+	kafkaType = "kafka"
+
+	defaultKafkaGroupIdPrefix = "eventnative_"
+	defaultKafkaBatchSize     = 1000
+	defaultKafkaBatchWaitMs   = 5000
+
+	KafkaEarliestOffset = "earliest"
+	KafkaLatestOffset   = "latest"
+
+	//kafkaPartitionKey and kafkaOffsetKey are fields with message coordinates in objects
+	//(messages with the same payload are different events)
+	kafkaPartitionKey = "kafka_partition"
+	kafkaOffsetKey    = "kafka_offset"
+)
+
+//KafkaSourceConfig is a Kafka consumer configuration. Consumer group offsets are the source state:
+//they are committed only after successful delivery to all destinations
+type KafkaSourceConfig struct {
+	Brokers     []string `mapstructure:"brokers" json:"brokers,omitempty" yaml:"brokers,omitempty"`
+	GroupId     string   `mapstructure:"group_id" json:"group_id,omitempty" yaml:"group_id,omitempty"`
+	StartOffset string   `mapstructure:"start_offset" json:"start_offset,omitempty" yaml:"start_offset,omitempty"`
+	Username    string   `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password    string   `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	TLS         bool     `mapstructure:"tls" json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+func (kc *KafkaSourceConfig) Validate() error {
+	if kc == nil {
+		return errors.New("Kafka config is required")
+	}
+	if len(kc.Brokers) == 0 {
+		return errors.New("Kafka brokers are required")
+	}
+
+	switch kc.StartOffset {
+	case "":
+		kc.StartOffset = KafkaEarliestOffset
+	case KafkaEarliestOffset, KafkaLatestOffset:
+	default:
+		return fmt.Errorf("Unknown start_offset value [%s]. Available values: [%s, %s]", kc.StartOffset, KafkaEarliestOffset, KafkaLatestOffset)
+	}
+
+	return nil
+}
+
+//KafkaCollectionConfig is a collection parameters: topic and messages mapping
+type KafkaCollectionConfig struct {
+	Topic string `mapstructure:"topic" json:"topic,omitempty" yaml:"topic,omitempty"`
+	//EventPath is a JSON path to an event object in a message. The whole message is an event if empty
+	EventPath   string `mapstructure:"event_path" json:"event_path,omitempty" yaml:"event_path,omitempty"`
+	BatchSize   int    `mapstructure:"batch_size" json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
+	BatchWaitMs int    `mapstructure:"batch_wait_ms" json:"batch_wait_ms,omitempty" yaml:"batch_wait_ms,omitempty"`
+}
+
+//Validate set default values
+func (kcc *KafkaCollectionConfig) Validate(collectionName string) error {
+	if kcc.Topic == "" {
+		kcc.Topic = collectionName
+	}
+	if kcc.BatchSize <= 0 {
+		kcc.BatchSize = defaultKafkaBatchSize
+	}
+	if kcc.BatchWaitMs <= 0 {
+		kcc.BatchWaitMs = defaultKafkaBatchWaitMs
+	}
+
+	return nil
+}
+
+//Kafka is a driver which consumes a topic as a continuous collection
+//every sync fetches a batch of messages (up to batch_size or until batch_wait_ms is expired)
+//offsets are committed after successful delivery (see Commit). Rollback makes the consumer re-read the batch
+type Kafka struct {
+	sync.Mutex
+
+	config           *KafkaSourceConfig
+	collectionConfig *KafkaCollectionConfig
+	eventPath        *jsonutils.JsonPath
+	ctx              context.Context
+
+	collection *Collection
+
+	reader  *kafka.Reader
+	fetched []kafka.Message
+}
+
+func init() {
+	if err := RegisterDriverConstructor(kafkaType, NewKafka); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", kafkaType, err)
+	}
+}
+
+func NewKafka(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &KafkaSourceConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &KafkaCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if err := collectionConfig.Validate(collection.Name); err != nil {
+		return nil, err
+	}
+
+	k := &Kafka{
+		config:           config,
+		collectionConfig: collectionConfig,
+		eventPath:        jsonutils.NewJsonPath(collectionConfig.EventPath),
+		ctx:              ctx,
+		collection:       collection,
+	}
+	k.reader = k.newReader()
+
+	return k, nil
+}
+
+func (k *Kafka) newReader() *kafka.Reader {
+	dialer := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	if k.config.Username != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: k.config.Username, Password: k.config.Password}
+	}
+	if k.config.TLS {
+		dialer.TLS = &tls.Config{}
+	}
+
+	groupId := k.config.GroupId
+	if groupId == "" {
+		groupId = defaultKafkaGroupIdPrefix + k.collectionConfig.Topic
+	}
+
+	startOffset := kafka.FirstOffset
+	if k.config.StartOffset == KafkaLatestOffset {
+		startOffset = kafka.LastOffset
+	}
+
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     k.config.Brokers,
+		GroupID:     groupId,
+		Topic:       k.collectionConfig.Topic,
+		Dialer:      dialer,
+		StartOffset: startOffset,
+		MaxWait:     time.Second,
+	})
+}
+
+func (k *Kafka) GetCollectionTable() string {
+	return k.collection.GetTableName()
+}
+
+//GetAllAvailableIntervals return ALL interval: every sync fetches next batch of messages
+func (k *Kafka) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor fetch batch of messages. Offsets remain uncommitted until Commit is called
+//malformed messages are skipped (and committed with the batch)
+func (k *Kafka) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	if len(k.fetched) > 0 {
+		return nil, errors.New("Kafka previous batch hasn't been committed or rolled back")
+	}
+
+	ctx, cancel := context.WithTimeout(k.ctx, time.Duration(k.collectionConfig.BatchWaitMs)*time.Millisecond)
+	defer cancel()
+
+	var objects []map[string]interface{}
+	for len(k.fetched) < k.collectionConfig.BatchSize {
+		message, err := k.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil && k.ctx.Err() == nil {
+				//batch wait is expired
+				break
+			}
+			k.fetched = nil
+			return nil, fmt.Errorf("Kafka error fetching message from topic [%s]: %v", k.collectionConfig.Topic, err)
+		}
+		k.fetched = append(k.fetched, message)
+
+		object, err := k.toObject(message)
+		if err != nil {
+			logging.Errorf("[%s] Kafka message will be skipped: %v", k.collection.Name, err)
+			continue
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+//Commit commit offsets of the fetched batch
+func (k *Kafka) Commit() error {
+	k.Lock()
+	defer k.Unlock()
+
+	if len(k.fetched) == 0 {
+		return nil
+	}
+	fetched := k.fetched
+	k.fetched = nil
+
+	if err := k.reader.CommitMessages(k.ctx, fetched...); err != nil {
+		return fmt.Errorf("Kafka error committing %d messages offsets: %v", len(fetched), err)
+	}
+
+	return nil
+}
+
+//Rollback recreate the consumer: it continues from the last committed offsets and the batch will be fetched again
+func (k *Kafka) Rollback() error {
+	k.Lock()
+	defer k.Unlock()
+
+	if len(k.fetched) == 0 {
+		return nil
+	}
+	k.fetched = nil
+
+	err := k.reader.Close()
+	k.reader = k.newReader()
+	if err != nil {
+		return fmt.Errorf("Kafka error closing consumer: %v", err)
+	}
+
+	return nil
+}
+
+func (k *Kafka) Type() string {
+	return kafkaType
+}
+
+func (k *Kafka) Close() error {
+	k.Lock()
+	defer k.Unlock()
+
+	//not committed offsets will be fetched again by the next consumer of the group
+	k.fetched = nil
+	return k.reader.Close()
+}
+
+//toObject return message JSON object (or its part by event path) with message partition and offset
+func (k *Kafka) toObject(message kafka.Message) (map[string]interface{}, error) {
+	payload := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(message.Value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("message [partition: %d offset: %d] must be JSON object: %v", message.Partition, message.Offset, err)
+	}
+
+	object := payload
+	if !k.eventPath.IsEmpty() {
+		value, ok := k.eventPath.Get(payload)
+		if !ok {
+			return nil, fmt.Errorf("message [partition: %d offset: %d] doesn't have event path [%s]", message.Partition, message.Offset, k.eventPath.String())
+		}
+		object, ok = value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("message [partition: %d offset: %d] event path [%s] value must be JSON object", message.Partition, message.Offset, k.eventPath.String())
+		}
+	}
+	object[kafkaPartitionKey] = message.Partition
+	object[kafkaOffsetKey] = message.Offset
+
+	return object, nil
+}
@@ -0,0 +1,43 @@
+package drivers
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKafkaToObject(t *testing.T) {
+	k := &Kafka{eventPath: jsonutils.NewJsonPath("")}
+	object, err := k.toObject(kafka.Message{Partition: 1, Offset: 42, Value: []byte(`{"event_type":"click","value":10}`)})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"event_type": "click", "value": json.Number("10"), kafkaPartitionKey: 1, kafkaOffsetKey: int64(42)}, object)
+
+	k = &Kafka{eventPath: jsonutils.NewJsonPath("/payload/event")}
+	object, err = k.toObject(kafka.Message{Offset: 1, Value: []byte(`{"headers":{},"payload":{"event":{"event_type":"view"}}}`)})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"event_type": "view", kafkaPartitionKey: 0, kafkaOffsetKey: int64(1)}, object)
+
+	_, err = k.toObject(kafka.Message{Value: []byte(`{"payload":{"event":"view"}}`)})
+	require.Error(t, err)
+	_, err = k.toObject(kafka.Message{Value: []byte(`{"headers":{}}`)})
+	require.Error(t, err)
+	_, err = k.toObject(kafka.Message{Value: []byte(`not json`)})
+	require.Error(t, err)
+}
+
+func TestKafkaConfigValidate(t *testing.T) {
+	require.Error(t, (&KafkaSourceConfig{}).Validate())
+	require.Error(t, (&KafkaSourceConfig{Brokers: []string{"localhost:9092"}, StartOffset: "middle"}).Validate())
+
+	config := &KafkaSourceConfig{Brokers: []string{"localhost:9092"}}
+	require.NoError(t, config.Validate())
+	require.Equal(t, KafkaEarliestOffset, config.StartOffset)
+
+	collectionConfig := &KafkaCollectionConfig{}
+	require.NoError(t, collectionConfig.Validate("events"))
+	require.Equal(t, "events", collectionConfig.Topic)
+	require.Equal(t, defaultKafkaBatchSize, collectionConfig.BatchSize)
+	require.Equal(t, defaultKafkaBatchWaitMs, collectionConfig.BatchWaitMs)
+}
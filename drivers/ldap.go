@@ -0,0 +1,197 @@
+package drivers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jitsucom/eventnative/logging"
+	"strings"
+)
+
+const (
+	ldapType = "ldap"
+
+	ldapUsersCollection  = "users"
+	ldapGroupsCollection = "groups"
+
+	ldapDnKey           = "dn"
+	defaultLdapPageSize = 500
+)
+
+var defaultLdapFilters = map[string]string{
+	ldapUsersCollection:  "(|(objectClass=person)(objectClass=inetOrgPerson))",
+	ldapGroupsCollection: "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))",
+}
+
+//LdapConfig is a LDAP/Active Directory connection config
+type LdapConfig struct {
+	Url                string `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	BindDn             string `mapstructure:"bind_dn" json:"bind_dn,omitempty" yaml:"bind_dn,omitempty"`
+	BindPassword       string `mapstructure:"bind_password" json:"bind_password,omitempty" yaml:"bind_password,omitempty"`
+	BaseDn             string `mapstructure:"base_dn" json:"base_dn,omitempty" yaml:"base_dn,omitempty"`
+	StartTls           bool   `mapstructure:"start_tls" json:"start_tls,omitempty" yaml:"start_tls,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+func (lc *LdapConfig) Validate() error {
+	if lc == nil {
+		return errors.New("LDAP config is required")
+	}
+	if lc.Url == "" {
+		return errors.New("LDAP url is required parameter")
+	}
+	if lc.BaseDn == "" {
+		return errors.New("LDAP base_dn is required parameter")
+	}
+
+	return nil
+}
+
+//LdapCollectionConfig is a collection parameters: search base, filter and attributes
+type LdapCollectionConfig struct {
+	BaseDn     string   `mapstructure:"base_dn" json:"base_dn,omitempty" yaml:"base_dn,omitempty"`
+	Filter     string   `mapstructure:"filter" json:"filter,omitempty" yaml:"filter,omitempty"`
+	Attributes []string `mapstructure:"attributes" json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	PageSize   int      `mapstructure:"page_size" json:"page_size,omitempty" yaml:"page_size,omitempty"`
+}
+
+//Ldap is a driver for syncing users and groups from LDAP/Active Directory
+//collection types: users, groups. Every sync reloads all the entries
+type Ldap struct {
+	config           *LdapConfig
+	collectionConfig *LdapCollectionConfig
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(ldapType, NewLdap); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", ldapType, err)
+	}
+}
+
+func NewLdap(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &LdapConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &LdapCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if collectionConfig.Filter == "" {
+		defaultFilter, ok := defaultLdapFilters[collection.Type]
+		if !ok {
+			return nil, fmt.Errorf("LDAP unknown collection type [%s]. Available types: [%s, %s] or filter parameter must be configured", collection.Type, ldapUsersCollection, ldapGroupsCollection)
+		}
+		collectionConfig.Filter = defaultFilter
+	}
+	if collectionConfig.BaseDn == "" {
+		collectionConfig.BaseDn = config.BaseDn
+	}
+	if collectionConfig.PageSize <= 0 {
+		collectionConfig.PageSize = defaultLdapPageSize
+	}
+	if _, err := ldap.CompileFilter(collectionConfig.Filter); err != nil {
+		return nil, fmt.Errorf("LDAP malformed filter [%s]: %v", collectionConfig.Filter, err)
+	}
+
+	l := &Ldap{config: config, collectionConfig: collectionConfig, ctx: ctx, collection: collection}
+
+	//check connection and credentials
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
+	return l, nil
+}
+
+func (l *Ldap) GetCollectionTable() string {
+	return l.collection.GetTableName()
+}
+
+func (l *Ldap) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor search all the collection entries with paging
+//single-valued attributes are strings and multi-valued ones are arrays
+func (l *Ldap) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	cc := l.collectionConfig
+	request := ldap.NewSearchRequest(cc.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		cc.Filter, cc.Attributes, nil)
+	result, err := conn.SearchWithPaging(request, uint32(cc.PageSize))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP error searching [%s] collection entries: %v", l.collection.Name, err)
+	}
+
+	var objects []map[string]interface{}
+	for _, entry := range result.Entries {
+		object := map[string]interface{}{ldapDnKey: entry.DN}
+		for _, attribute := range entry.Attributes {
+			if len(attribute.Values) == 1 {
+				object[attribute.Name] = attribute.Values[0]
+			} else if len(attribute.Values) > 1 {
+				values := make([]interface{}, 0, len(attribute.Values))
+				for _, value := range attribute.Values {
+					values = append(values, value)
+				}
+				object[attribute.Name] = values
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func (l *Ldap) Type() string {
+	return ldapType
+}
+
+func (l *Ldap) Close() error {
+	return nil
+}
+
+//connect dial LDAP server, upgrade connection with StartTLS if configured and bind
+func (l *Ldap) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(l.config.Url, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP error connecting to [%s]: %v", l.config.Url, err)
+	}
+
+	if l.config.StartTls && !strings.HasPrefix(l.config.Url, "ldaps://") {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP error starting TLS: %v", err)
+		}
+	}
+
+	if l.config.BindDn != "" {
+		err = conn.Bind(l.config.BindDn, l.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("LDAP error binding: %v", err)
+	}
+
+	return conn, nil
+}
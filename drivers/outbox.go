@@ -0,0 +1,327 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/lib/pq"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outboxType = "outbox"
+
+	DeleteAfterDelivery = "delete"
+	FlagAfterDelivery   = "flag"
+
+	defaultOutboxIdColumn   = "id"
+	defaultOutboxFlagColumn = "delivered_at"
+	defaultOutboxBatchSize  = 1000
+
+	//outboxIdKey is a field with outbox row id in objects which are created from payload column
+	outboxIdKey = "outbox_id"
+
+	claimOutboxQueryTemplate = `SELECT * FROM "%s"."%s"%s ORDER BY "%s" LIMIT %d FOR UPDATE SKIP LOCKED`
+	deleteOutboxTemplate     = `DELETE FROM "%s"."%s" WHERE "%s" = ANY($1)`
+	flagOutboxTemplate       = `UPDATE "%s"."%s" SET "%s" = $1 WHERE "%s" = ANY($2)`
+)
+
+//OutboxConfig is a Postgres connection config of outbox source
+type OutboxConfig struct {
+	Host       string            `mapstructure:"host" json:"host,omitempty" yaml:"host,omitempty"`
+	Port       int               `mapstructure:"port" json:"port,omitempty" yaml:"port,omitempty"`
+	Db         string            `mapstructure:"db" json:"db,omitempty" yaml:"db,omitempty"`
+	Schema     string            `mapstructure:"schema" json:"schema,omitempty" yaml:"schema,omitempty"`
+	Username   string            `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password   string            `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	Parameters map[string]string `mapstructure:"parameters" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+func (oc *OutboxConfig) Validate() error {
+	if oc == nil {
+		return errors.New("Outbox config is required")
+	}
+	if oc.Host == "" {
+		return errors.New("Outbox host is required parameter")
+	}
+	if oc.Db == "" {
+		return errors.New("Outbox db is required parameter")
+	}
+	if oc.Username == "" {
+		return errors.New("Outbox username is required parameter")
+	}
+	if oc.Port == 0 {
+		oc.Port = 5432
+	}
+	if oc.Schema == "" {
+		oc.Schema = "public"
+	}
+
+	return nil
+}
+
+//OutboxCollectionConfig is a collection parameters: outbox table and delivery settings
+type OutboxCollectionConfig struct {
+	Table         string `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	IdColumn      string `mapstructure:"id_column" json:"id_column,omitempty" yaml:"id_column,omitempty"`
+	OrderColumn   string `mapstructure:"order_column" json:"order_column,omitempty" yaml:"order_column,omitempty"`
+	PayloadColumn string `mapstructure:"payload_column" json:"payload_column,omitempty" yaml:"payload_column,omitempty"`
+	BatchSize     int    `mapstructure:"batch_size" json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
+	AfterDelivery string `mapstructure:"after_delivery" json:"after_delivery,omitempty" yaml:"after_delivery,omitempty"`
+	FlagColumn    string `mapstructure:"flag_column" json:"flag_column,omitempty" yaml:"flag_column,omitempty"`
+}
+
+//Validate check after_delivery value and set default values
+func (occ *OutboxCollectionConfig) Validate(collectionName string) error {
+	if occ.Table == "" {
+		occ.Table = collectionName
+	}
+	if occ.IdColumn == "" {
+		occ.IdColumn = defaultOutboxIdColumn
+	}
+	if occ.OrderColumn == "" {
+		occ.OrderColumn = occ.IdColumn
+	}
+	if occ.BatchSize <= 0 {
+		occ.BatchSize = defaultOutboxBatchSize
+	}
+
+	switch occ.AfterDelivery {
+	case "":
+		occ.AfterDelivery = DeleteAfterDelivery
+	case DeleteAfterDelivery:
+	case FlagAfterDelivery:
+		if occ.FlagColumn == "" {
+			occ.FlagColumn = defaultOutboxFlagColumn
+		}
+	default:
+		return fmt.Errorf("Unknown after_delivery value [%s]. Available values: [%s, %s]", occ.AfterDelivery, DeleteAfterDelivery, FlagAfterDelivery)
+	}
+
+	return nil
+}
+
+//Outbox is a driver for transactional outbox tables in Postgres
+//it claims a batch of undelivered rows with SELECT .. FOR UPDATE SKIP LOCKED (so several instances/tasks don't
+//read the same rows) and deletes or flags them in the same transaction after successful delivery (see Commit)
+type Outbox struct {
+	sync.Mutex
+
+	config           *OutboxConfig
+	collectionConfig *OutboxCollectionConfig
+	dataSource       *sql.DB
+	ctx              context.Context
+
+	collection *Collection
+
+	tx         *sql.Tx
+	claimedIds []interface{}
+}
+
+func init() {
+	if err := RegisterDriverConstructor(outboxType, NewOutbox); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", outboxType, err)
+	}
+}
+
+func NewOutbox(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &OutboxConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &OutboxCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if err := collectionConfig.Validate(collection.Name); err != nil {
+		return nil, err
+	}
+
+	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s ",
+		config.Host, config.Port, config.Db, config.Username, config.Password)
+	//concat provided connection parameters
+	for k, v := range config.Parameters {
+		connectionString += k + "=" + v + " "
+	}
+	dataSource, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("Outbox error opening connection: %v", err)
+	}
+	if err := dataSource.PingContext(ctx); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Outbox error connecting to database: %v", err)
+	}
+
+	return &Outbox{config: config, collectionConfig: collectionConfig, dataSource: dataSource, ctx: ctx, collection: collection}, nil
+}
+
+func (o *Outbox) GetCollectionTable() string {
+	return o.collection.GetTableName()
+}
+
+//GetAllAvailableIntervals return ALL interval: every sync claims next batch of undelivered rows
+func (o *Outbox) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor begin transaction and claim batch of undelivered rows
+//the transaction remains opened until Commit or Rollback is called
+func (o *Outbox) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.tx != nil {
+		return nil, errors.New("Outbox previous batch hasn't been committed or rolled back")
+	}
+
+	tx, err := o.dataSource.BeginTx(o.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Outbox error beginning transaction: %v", err)
+	}
+
+	objects, ids, err := o.claim(tx)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			logging.Errorf("[%s] Outbox error rolling back transaction: %v", o.collection.Name, rollbackErr)
+		}
+		return nil, err
+	}
+
+	o.tx = tx
+	o.claimedIds = ids
+	return objects, nil
+}
+
+//Commit delete or flag claimed rows and commit the transaction
+func (o *Outbox) Commit() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.tx == nil {
+		return nil
+	}
+	tx, ids := o.tx, o.claimedIds
+	o.tx, o.claimedIds = nil, nil
+
+	if len(ids) > 0 {
+		var err error
+		cc := o.collectionConfig
+		if cc.AfterDelivery == FlagAfterDelivery {
+			_, err = tx.ExecContext(o.ctx, fmt.Sprintf(flagOutboxTemplate, o.config.Schema, cc.Table, cc.FlagColumn, cc.IdColumn), time.Now().UTC(), pq.Array(ids))
+		} else {
+			_, err = tx.ExecContext(o.ctx, fmt.Sprintf(deleteOutboxTemplate, o.config.Schema, cc.Table, cc.IdColumn), pq.Array(ids))
+		}
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logging.Errorf("[%s] Outbox error rolling back transaction: %v", o.collection.Name, rollbackErr)
+			}
+			return fmt.Errorf("Outbox error marking %d rows as delivered: %v", len(ids), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Outbox error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+//Rollback release claimed rows: they will be claimed again by the next sync
+func (o *Outbox) Rollback() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.tx == nil {
+		return nil
+	}
+	tx := o.tx
+	o.tx, o.claimedIds = nil, nil
+
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("Outbox error rolling back transaction: %v", err)
+	}
+
+	return nil
+}
+
+func (o *Outbox) Type() string {
+	return outboxType
+}
+
+func (o *Outbox) Close() error {
+	if err := o.Rollback(); err != nil {
+		logging.Errorf("[%s] %v", o.collection.Name, err)
+	}
+
+	return o.dataSource.Close()
+}
+
+//claim select batch of undelivered rows with locks and return objects and claimed rows ids
+func (o *Outbox) claim(tx *sql.Tx) ([]map[string]interface{}, []interface{}, error) {
+	cc := o.collectionConfig
+	var condition string
+	if cc.AfterDelivery == FlagAfterDelivery {
+		condition = fmt.Sprintf(` WHERE "%s" IS NULL`, cc.FlagColumn)
+	}
+
+	rows, err := tx.QueryContext(o.ctx, fmt.Sprintf(claimOutboxQueryTemplate, o.config.Schema, cc.Table, condition, cc.OrderColumn, cc.BatchSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Outbox error claiming rows: %v", err)
+	}
+	defer rows.Close()
+
+	claimed, err := scanRows(rows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Outbox error reading claimed rows: %v", err)
+	}
+
+	var objects []map[string]interface{}
+	var ids []interface{}
+	for _, row := range claimed {
+		id, ok := row[cc.IdColumn]
+		if !ok {
+			return nil, nil, fmt.Errorf("Outbox table [%s] doesn't have id column [%s]", cc.Table, cc.IdColumn)
+		}
+		ids = append(ids, id)
+
+		object, err := o.toObject(row, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, ids, nil
+}
+
+//toObject return the whole row or parsed JSON payload column with outbox row id
+func (o *Outbox) toObject(row map[string]interface{}, id interface{}) (map[string]interface{}, error) {
+	payloadColumn := o.collectionConfig.PayloadColumn
+	if payloadColumn == "" {
+		return row, nil
+	}
+
+	payload, ok := row[payloadColumn].(string)
+	if !ok {
+		return nil, fmt.Errorf("Outbox row [%v] payload column [%s] must be JSON", id, payloadColumn)
+	}
+
+	object := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("Outbox row [%v] payload column [%s] must be JSON object: %v", id, payloadColumn, err)
+	}
+	object[outboxIdKey] = id
+
+	return object, nil
+}
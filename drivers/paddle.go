@@ -0,0 +1,182 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	paddleType = "paddle"
+
+	paddleUrl        = "https://api.paddle.com"
+	paddleSandboxUrl = "https://sandbox-api.paddle.com"
+	paddlePageLimit  = 200
+)
+
+//paddlePaths is a Paddle Billing API resources per collection type
+//Paddle Billing has no plans: prices (recurring or one-time) are used instead. Invoices are transactions
+var paddlePaths = map[string]string{
+	plansCollection:         "/prices",
+	subscriptionsCollection: "/subscriptions",
+	transactionsCollection:  "/transactions",
+}
+
+//PaddleConfig is a Paddle Billing API key config
+//start_date (YYYY-MM-DD) is used as "updated since" in the first sync. Default - all the objects
+type PaddleConfig struct {
+	ApiKey    string `mapstructure:"api_key" json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	Sandbox   bool   `mapstructure:"sandbox" json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (pc *PaddleConfig) Validate() error {
+	if pc == nil {
+		return errors.New("Paddle config is required")
+	}
+	if pc.ApiKey == "" {
+		return errors.New("Paddle api_key is required parameter")
+	}
+
+	return nil
+}
+
+type paddleListResponse struct {
+	Data []map[string]interface{} `json:"data"`
+	Meta struct {
+		Pagination struct {
+			Next    string `json:"next"`
+			HasMore bool   `json:"has_more"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+//Paddle is a driver for incremental syncing Paddle Billing prices (plans), subscriptions and transactions
+//updated since the last sync
+type Paddle struct {
+	incrementalCursor
+
+	config *PaddleConfig
+	url    string
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(paddleType, NewPaddle); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", paddleType, err)
+	}
+}
+
+func NewPaddle(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &PaddleConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	path, ok := paddlePaths[collection.Type]
+	if !ok {
+		return nil, fmt.Errorf("Paddle unknown collection type [%s]. Available types: [%s, %s, %s]", collection.Type,
+			plansCollection, subscriptionsCollection, transactionsCollection)
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	baseUrl := paddleUrl
+	if config.Sandbox {
+		baseUrl = paddleSandboxUrl
+	}
+
+	return &Paddle{
+		incrementalCursor: incrementalCursor{stateKey: paddleType + "_" + collection.Name, initial: initial},
+		config:            config,
+		url:               baseUrl + path,
+		collection:        collection,
+	}, nil
+}
+
+func (p *Paddle) GetCollectionTable() string {
+	return p.collection.GetTableName()
+}
+
+func (p *Paddle) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return objects updated since the last sync
+//only transactions can be filtered by API, other objects are filtered by updated_at field
+func (p *Paddle) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := p.get()
+	if err != nil {
+		return nil, fmt.Errorf("Paddle %v", err)
+	}
+	syncStart := time.Now().UTC()
+
+	query := url.Values{}
+	query.Set("per_page", fmt.Sprint(paddlePageLimit))
+	if !since.IsZero() && p.collection.Type == transactionsCollection {
+		query.Set("updated_at[GT]", since.UTC().Format(time.RFC3339))
+	}
+
+	var objects []map[string]interface{}
+	next := p.url + "?" + query.Encode()
+	for next != "" {
+		response := &paddleListResponse{}
+		if err := doJsonRequest(http.MethodGet, next, nil, p.authorize, response); err != nil {
+			return nil, fmt.Errorf("Paddle error getting %s: %v", p.collection.Type, err)
+		}
+		for _, object := range response.Data {
+			if paddleUpdatedSince(object, since) {
+				objects = append(objects, object)
+			}
+		}
+
+		if !response.Meta.Pagination.HasMore {
+			break
+		}
+		next = response.Meta.Pagination.Next
+	}
+
+	p.setPending(syncStart)
+	return objects, nil
+}
+
+func (p *Paddle) Type() string {
+	return paddleType
+}
+
+func (p *Paddle) Close() error {
+	return nil
+}
+
+func (p *Paddle) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+p.config.ApiKey)
+}
+
+//paddleUpdatedSince return true if object updated_at (RFC3339) is after since or can't be parsed
+func paddleUpdatedSince(object map[string]interface{}, since time.Time) bool {
+	if since.IsZero() {
+		return true
+	}
+
+	updatedAt, ok := object["updated_at"].(string)
+	if !ok {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, updatedAt)
+	if err != nil {
+		return true
+	}
+
+	return t.After(since)
+}
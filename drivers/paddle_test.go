@@ -0,0 +1,15 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPaddleUpdatedSince(t *testing.T) {
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.True(t, paddleUpdatedSince(map[string]interface{}{"updated_at": "2021-01-01T00:00:01.123Z"}, since))
+	require.False(t, paddleUpdatedSince(map[string]interface{}{"updated_at": "2020-12-31T23:59:59Z"}, since))
+	require.True(t, paddleUpdatedSince(map[string]interface{}{}, since))
+	require.True(t, paddleUpdatedSince(map[string]interface{}{"updated_at": "2020-12-31T23:59:59Z"}, time.Time{}))
+}
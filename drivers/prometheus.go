@@ -0,0 +1,243 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/uuid"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	prometheusType = "prometheus"
+
+	prometheusQueryCollection = "query"
+
+	defaultPrometheusDays = 7
+	defaultPrometheusStep = time.Hour
+	//Prometheus rejects range queries with more than 11000 points per series
+	maxPrometheusPointsPerSeries = 11000
+)
+
+//PrometheusConfig is a Prometheus (or compatible e.g. VictoriaMetrics) HTTP API config
+//username/password (basic auth) or bearer_token are optional
+type PrometheusConfig struct {
+	Url         string `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Username    string `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password    string `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	BearerToken string `mapstructure:"bearer_token" json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	Days        int    `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (pc *PrometheusConfig) Validate() error {
+	if pc == nil {
+		return errors.New("Prometheus config is required")
+	}
+	if pc.Url == "" {
+		return errors.New("Prometheus url is required parameter")
+	}
+	if pc.Days <= 0 {
+		pc.Days = defaultPrometheusDays
+	}
+
+	return nil
+}
+
+//PrometheusQueryConfig is a PromQL query with evaluation step (e.g. 1m, 1h)
+type PrometheusQueryConfig struct {
+	Query string `mapstructure:"query" json:"query,omitempty" yaml:"query,omitempty"`
+	Step  string `mapstructure:"step" json:"step,omitempty" yaml:"step,omitempty"`
+}
+
+//Prometheus is a driver for Prometheus range queries results
+//every sample is an object with metric name, labels, timestamp and value
+type Prometheus struct {
+	config     *PrometheusConfig
+	query      string
+	step       time.Duration
+	collection *Collection
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func init() {
+	if err := RegisterDriverConstructor(prometheusType, NewPrometheus); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", prometheusType, err)
+	}
+}
+
+func NewPrometheus(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &PrometheusConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != prometheusQueryCollection {
+		return nil, fmt.Errorf("Prometheus unknown collection type [%s]. Available types: [%s]", collection.Type, prometheusQueryCollection)
+	}
+
+	queryConfig := &PrometheusQueryConfig{}
+	if err := unmarshalConfig(collection.Parameters, queryConfig); err != nil {
+		return nil, err
+	}
+	if queryConfig.Query == "" {
+		return nil, errors.New("Prometheus query is required collection parameter")
+	}
+
+	step := defaultPrometheusStep
+	if queryConfig.Step != "" {
+		parsed, err := time.ParseDuration(queryConfig.Step)
+		if err != nil {
+			return nil, fmt.Errorf("Prometheus error parsing step [%s]: %v", queryConfig.Step, err)
+		}
+		if parsed <= 0 || 24*time.Hour/parsed > maxPrometheusPointsPerSeries {
+			return nil, fmt.Errorf("Prometheus step [%s] must be positive and produce not more than %d points per day", queryConfig.Step, maxPrometheusPointsPerSeries)
+		}
+		step = parsed
+	}
+
+	return &Prometheus{config: config, query: queryConfig.Query, step: step, collection: collection}, nil
+}
+
+func (p *Prometheus) GetCollectionTable() string {
+	return p.collection.GetTableName()
+}
+
+//GetAllAvailableIntervals return today and the last days intervals
+//today interval is re-synced on every run so metrics are loaded periodically
+func (p *Prometheus) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	intervals := []*TimeInterval{NewTimeInterval(DAY, time.Now().UTC())}
+	return append(intervals, lastDaysIntervals(p.config.Days)...), nil
+}
+
+//GetObjectsFor run range query for the interval day and return samples
+func (p *Prometheus) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	start := interval.LowerEndpoint()
+	end := interval.UpperEndpoint()
+	if now := time.Now().UTC(); end.After(now) {
+		end = now
+	}
+
+	query := url.Values{}
+	query.Set("query", p.query)
+	query.Set("start", strconv.FormatInt(start.Unix(), 10))
+	query.Set("end", strconv.FormatInt(end.Unix(), 10))
+	query.Set("step", strconv.FormatFloat(p.step.Seconds(), 'f', -1, 64))
+
+	response := &prometheusResponse{}
+	requestUrl := strings.TrimRight(p.config.Url, "/") + "/api/v1/query_range?" + query.Encode()
+	if err := doJsonRequest(http.MethodGet, requestUrl, nil, p.authorize, response); err != nil {
+		return nil, fmt.Errorf("Prometheus error querying %s: %v", interval.String(), err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query error [%s]: %s", response.ErrorType, response.Error)
+	}
+	if response.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("Prometheus unexpected result type: %s", response.Data.ResultType)
+	}
+
+	var result []map[string]interface{}
+	for _, series := range response.Data.Result {
+		labels := map[string]interface{}{}
+		for name, value := range series.Metric {
+			if name != "__name__" {
+				labels[name] = value
+			}
+		}
+
+		for _, sample := range series.Values {
+			object, err := prometheusSample(series.Metric["__name__"], labels, sample)
+			if err != nil {
+				return nil, err
+			}
+			object[eventCtx] = map[string]interface{}{eventId: uuid.GetHash(object)}
+			result = append(result, object)
+		}
+	}
+
+	return result, nil
+}
+
+func (p *Prometheus) Type() string {
+	return prometheusType
+}
+
+func (p *Prometheus) Close() error {
+	return nil
+}
+
+func (p *Prometheus) authorize(req *http.Request) {
+	if p.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.BearerToken)
+	} else if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+}
+
+//prometheusSample return object from [unix_time, "value"] pair
+//NaN and Inf values are returned as nil
+func prometheusSample(name string, labels map[string]interface{}, sample []interface{}) (map[string]interface{}, error) {
+	if len(sample) != 2 {
+		return nil, fmt.Errorf("Prometheus malformed sample: %v", sample)
+	}
+
+	ts, ok := sample[0].(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("Prometheus malformed sample timestamp: %v", sample[0])
+	}
+	seconds, err := ts.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("Prometheus malformed sample timestamp: %v", sample[0])
+	}
+
+	rawValue, ok := sample[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("Prometheus malformed sample value: %v", sample[1])
+	}
+	var value interface{}
+	parsed, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Prometheus malformed sample value: %v", sample[1])
+	}
+	if !math.IsNaN(parsed) && !math.IsInf(parsed, 0) {
+		value = parsed
+	}
+
+	//every object has own labels map
+	copyLabels := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		copyLabels[k] = v
+	}
+
+	object := map[string]interface{}{
+		"timestamp": time.Unix(0, int64(seconds*float64(time.Second))).UTC(),
+		"value":     value,
+		"labels":    copyLabels,
+	}
+	if name != "" {
+		object["name"] = name
+	}
+
+	return object, nil
+}
@@ -0,0 +1,53 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrometheusGetObjectsFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/query_range", r.URL.Path)
+		require.Equal(t, "up", r.URL.Query().Get("query"))
+		require.Equal(t, "1609459200", r.URL.Query().Get("start"))
+		require.Equal(t, "1609545599", r.URL.Query().Get("end"))
+		require.Equal(t, "3600", r.URL.Query().Get("step"))
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "pass", password)
+
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+{"metric":{"__name__":"up","job":"eventnative"},"values":[[1609459200,"1"],[1609462800.5,"NaN"]]}]}}`))
+	}))
+	defer server.Close()
+
+	driver, err := NewPrometheus(context.Background(), &SourceConfig{Config: map[string]interface{}{"url": server.URL + "/", "username": "user", "password": "pass"}},
+		&Collection{Name: "up", Type: prometheusQueryCollection, Parameters: map[string]interface{}{"query": "up"}})
+	require.NoError(t, err)
+
+	objects, err := driver.GetObjectsFor(NewTimeInterval(DAY, time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	require.Equal(t, "up", objects[0]["name"])
+	require.Equal(t, 1.0, objects[0]["value"])
+	require.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), objects[0]["timestamp"])
+	require.Equal(t, map[string]interface{}{"job": "eventnative"}, objects[0]["labels"])
+
+	require.Nil(t, objects[1]["value"])
+	require.Equal(t, time.Date(2021, 1, 1, 1, 0, 0, 500000000, time.UTC), objects[1]["timestamp"])
+	require.NotEqual(t, objects[0][eventCtx], objects[1][eventCtx])
+}
+
+func TestPrometheusStepValidation(t *testing.T) {
+	sourceConfig := &SourceConfig{Config: map[string]interface{}{"url": "http://localhost:9090"}}
+	_, err := NewPrometheus(context.Background(), sourceConfig, &Collection{Type: prometheusQueryCollection, Parameters: map[string]interface{}{"query": "up", "step": "1s"}})
+	require.Error(t, err)
+	_, err = NewPrometheus(context.Background(), sourceConfig, &Collection{Type: prometheusQueryCollection, Parameters: map[string]interface{}{"step": "1m"}})
+	require.Error(t, err)
+}
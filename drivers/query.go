@@ -0,0 +1,37 @@
+package drivers
+
+import (
+	"errors"
+	"time"
+)
+
+//QueryCollectionConfig is a collection parameters of warehouse query drivers (e.g. Snowflake or BigQuery):
+//every collection is an arbitrary SQL query. The whole query result is reloaded on every sync
+type QueryCollectionConfig struct {
+	Query string `mapstructure:"query" json:"query,omitempty" yaml:"query,omitempty"`
+}
+
+func (qcc *QueryCollectionConfig) Validate() error {
+	if qcc.Query == "" {
+		return errors.New("query collection parameter is required")
+	}
+
+	return nil
+}
+
+func parseQueryCollectionConfig(collection *Collection) (*QueryCollectionConfig, error) {
+	config := &QueryCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+//allIntervals return one ALL interval: query result can't be split by date
+func allIntervals() []*TimeInterval {
+	return []*TimeInterval{NewTimeInterval(ALL, time.Time{})}
+}
@@ -0,0 +1,158 @@
+package drivers
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//minLearnedInterval is the first requests interval after HTTP 429 without rate limit headers
+	minLearnedInterval = 100 * time.Millisecond
+	maxLearnedInterval = time.Minute
+	//learned intervals below the threshold are dropped after successful requests
+	minIntervalThreshold = 10 * time.Millisecond
+	//unix timestamps are used as reset header values by some APIs (e.g. GitHub) instead of seconds
+	unixResetThreshold = 1000000000
+)
+
+var (
+	rateLimitersMutex = sync.Mutex{}
+	//rateLimiters are shared per API host: all collections (and concurrently synced intervals) of the API share limits
+	rateLimiters = map[string]*rateLimiter{}
+
+	remainingHeaders = []string{"X-RateLimit-Remaining", "X-Rate-Limit-Remaining", "RateLimit-Remaining"}
+	resetHeaders     = []string{"X-RateLimit-Reset", "X-Rate-Limit-Reset", "RateLimit-Reset"}
+)
+
+//rateLimiter throttles API requests according to rate limit response headers or learns requests interval from HTTP 429.
+//Requests reserve time slots in order of arrival so concurrent collections get fair share of API limits
+type rateLimiter struct {
+	sync.Mutex
+
+	//interval is a minimal interval between requests
+	interval time.Duration
+	//next is the earliest time of the next request
+	next time.Time
+	//pausedUntil is set when API limits have been exhausted
+	pausedUntil time.Time
+}
+
+//getRateLimiter return rateLimiter of the host
+func getRateLimiter(host string) *rateLimiter {
+	rateLimitersMutex.Lock()
+	defer rateLimitersMutex.Unlock()
+
+	limiter, ok := rateLimiters[host]
+	if !ok {
+		limiter = &rateLimiter{}
+		rateLimiters[host] = limiter
+	}
+
+	return limiter
+}
+
+//doRateLimitedRequest wait for the host rate limiter slot, send request and update limiter with the response
+func doRateLimitedRequest(req *http.Request) (*http.Response, error) {
+	limiter := getRateLimiter(req.URL.Host)
+	if delay := limiter.reserve(time.Now()); delay > 0 {
+		time.Sleep(delay)
+	}
+
+	resp, err := defaultHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter.update(resp.StatusCode, resp.Header, time.Now())
+	return resp, nil
+}
+
+//reserve return delay before the request
+func (rl *rateLimiter) reserve(now time.Time) time.Duration {
+	rl.Lock()
+	defer rl.Unlock()
+
+	slot := now
+	if rl.next.After(slot) {
+		slot = rl.next
+	}
+	if rl.pausedUntil.After(slot) {
+		slot = rl.pausedUntil
+	}
+	rl.next = slot.Add(rl.interval)
+
+	return slot.Sub(now)
+}
+
+//update adjust requests interval:
+//1. rate limit headers: remaining requests are spread evenly until the limit reset
+//2. HTTP 429 without headers: pause for Retry-After and double the interval
+//3. successful requests without headers: decrease the learned interval
+func (rl *rateLimiter) update(statusCode int, header http.Header, now time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	remaining, hasRemaining := intHeader(header, remainingHeaders)
+	reset, hasReset := intHeader(header, resetHeaders)
+	var resetIn time.Duration
+	if hasReset {
+		if reset > unixResetThreshold {
+			resetIn = time.Unix(int64(reset), 0).Sub(now)
+		} else {
+			resetIn = time.Duration(reset) * time.Second
+		}
+		if resetIn < 0 {
+			resetIn = 0
+		}
+	}
+
+	if statusCode == http.StatusTooManyRequests {
+		pause := retryAfter(header.Get("Retry-After"))
+		if header.Get("Retry-After") == "" && resetIn > 0 {
+			pause = resetIn
+		}
+		rl.pausedUntil = now.Add(pause)
+
+		if !hasRemaining {
+			rl.interval *= 2
+			if rl.interval < minLearnedInterval {
+				rl.interval = minLearnedInterval
+			}
+			if rl.interval > maxLearnedInterval {
+				rl.interval = maxLearnedInterval
+			}
+		}
+		return
+	}
+
+	if hasRemaining && hasReset {
+		if remaining <= 0 {
+			rl.pausedUntil = now.Add(resetIn)
+			return
+		}
+		rl.interval = resetIn / time.Duration(remaining)
+		return
+	}
+
+	//slowly speed up after learning from 429
+	rl.interval = rl.interval * 3 / 4
+	if rl.interval < minIntervalThreshold {
+		rl.interval = 0
+	}
+}
+
+//intHeader return the first found header int value
+func intHeader(header http.Header, names []string) (int, bool) {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err == nil {
+				return parsed, true
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,43 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterHeaders(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &rateLimiter{}
+	require.Equal(t, time.Duration(0), limiter.reserve(now))
+
+	//10 remaining requests in 5 seconds
+	limiter.update(http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"10"}, "X-Ratelimit-Reset": {"5"}}, now)
+	require.Equal(t, time.Duration(0), limiter.reserve(now))
+	require.Equal(t, 500*time.Millisecond, limiter.reserve(now))
+	require.Equal(t, time.Second, limiter.reserve(now))
+
+	//exhausted limits with unix timestamp reset
+	limiter = &rateLimiter{}
+	limiter.update(http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1609459230"}}, now)
+	require.Equal(t, 30*time.Second, limiter.reserve(now))
+}
+
+func TestRateLimiterLearns(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &rateLimiter{}
+
+	limiter.update(http.StatusTooManyRequests, http.Header{"Retry-After": {"2"}}, now)
+	require.Equal(t, 2*time.Second, limiter.reserve(now))
+	require.Equal(t, 2*time.Second+minLearnedInterval, limiter.reserve(now))
+
+	limiter.update(http.StatusTooManyRequests, http.Header{}, now)
+	require.Equal(t, 2*minLearnedInterval, limiter.interval)
+
+	//speeds up after successful requests
+	for i := 0; i < 20; i++ {
+		limiter.update(http.StatusOK, http.Header{}, now)
+	}
+	require.Equal(t, time.Duration(0), limiter.interval)
+}
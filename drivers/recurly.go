@@ -0,0 +1,183 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	recurlyType = "recurly"
+
+	plansCollection         = "plans"
+	subscriptionsCollection = "subscriptions"
+	invoicesCollection      = "invoices"
+	transactionsCollection  = "transactions"
+
+	recurlyUrl       = "https://v3.recurly.com"
+	recurlyEUUrl     = "https://v3.eu.recurly.com"
+	recurlyAccept    = "application/vnd.recurly.v2021-02-25+json"
+	recurlyPageLimit = 200
+)
+
+var billingCollections = []string{plansCollection, subscriptionsCollection, invoicesCollection, transactionsCollection}
+
+//RecurlyConfig is a Recurly private API key config
+//region is "us" (default) or "eu"
+//start_date (YYYY-MM-DD) is used as "updated since" in the first sync. Default - all the objects
+type RecurlyConfig struct {
+	ApiKey    string `mapstructure:"api_key" json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	Region    string `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
+	StartDate string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+func (rc *RecurlyConfig) Validate() error {
+	if rc == nil {
+		return errors.New("Recurly config is required")
+	}
+	if rc.ApiKey == "" {
+		return errors.New("Recurly api_key is required parameter")
+	}
+	if rc.Region != "" && rc.Region != "us" && rc.Region != "eu" {
+		return fmt.Errorf("Recurly unknown region [%s]. Available: us, eu", rc.Region)
+	}
+
+	return nil
+}
+
+type recurlyListResponse struct {
+	HasMore bool                     `json:"has_more"`
+	Next    string                   `json:"next"`
+	Data    []map[string]interface{} `json:"data"`
+}
+
+//Recurly is a driver for incremental syncing Recurly plans, subscriptions, invoices and transactions
+//updated since the last sync
+type Recurly struct {
+	incrementalCursor
+
+	config *RecurlyConfig
+	url    string
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(recurlyType, NewRecurly); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", recurlyType, err)
+	}
+}
+
+func NewRecurly(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &RecurlyConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !isBillingCollection(collection.Type) {
+		return nil, fmt.Errorf("Recurly unknown collection type [%s]. Available types: %v", collection.Type, billingCollections)
+	}
+
+	initial, err := parseStartDate(config.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	baseUrl := recurlyUrl
+	if config.Region == "eu" {
+		baseUrl = recurlyEUUrl
+	}
+
+	return &Recurly{
+		incrementalCursor: incrementalCursor{stateKey: recurlyType + "_" + collection.Name, initial: initial},
+		config:            config,
+		url:               baseUrl,
+		collection:        collection,
+	}, nil
+}
+
+func (r *Recurly) GetCollectionTable() string {
+	return r.collection.GetTableName()
+}
+
+func (r *Recurly) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor return objects updated since the last sync. The max updated_at is used as the next cursor
+//begin_time filter is inclusive: objects updated at the cursor time have been already loaded in the previous sync
+func (r *Recurly) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	since, err := r.get()
+	if err != nil {
+		return nil, fmt.Errorf("Recurly %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(recurlyPageLimit))
+	query.Set("sort", "updated_at")
+	query.Set("order", "asc")
+	if !since.IsZero() {
+		query.Set("begin_time", since.UTC().Format(time.RFC3339))
+	}
+
+	var objects []map[string]interface{}
+	maxUpdated := since
+	next := "/" + r.collection.Type + "?" + query.Encode()
+	for next != "" {
+		response := &recurlyListResponse{}
+		if err := doJsonRequest(http.MethodGet, r.url+next, nil, r.authorize, response); err != nil {
+			return nil, fmt.Errorf("Recurly error getting %s: %v", r.collection.Type, err)
+		}
+		for _, object := range response.Data {
+			if updated, ok := parseCursorTime(object["updated_at"]); ok {
+				if !since.IsZero() && !updated.After(since) {
+					continue
+				}
+				if updated.After(maxUpdated) {
+					maxUpdated = updated
+				}
+			}
+			objects = append(objects, object)
+		}
+
+		if !response.HasMore {
+			break
+		}
+		next = response.Next
+	}
+
+	if maxUpdated.After(since) {
+		r.setPending(maxUpdated)
+	}
+	return objects, nil
+}
+
+func (r *Recurly) Type() string {
+	return recurlyType
+}
+
+func (r *Recurly) Close() error {
+	return nil
+}
+
+func (r *Recurly) authorize(req *http.Request) {
+	req.SetBasicAuth(r.config.ApiKey, "")
+	req.Header.Set("Accept", recurlyAccept)
+}
+
+func isBillingCollection(collectionType string) bool {
+	for _, c := range billingCollections {
+		if c == collectionType {
+			return true
+		}
+	}
+
+	return false
+}
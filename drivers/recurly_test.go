@@ -0,0 +1,52 @@
+package drivers
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecurlyIncrementalSync(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		user, _, _ := r.BasicAuth()
+		require.Equal(t, "key", user)
+		require.Equal(t, recurlyAccept, r.Header.Get("Accept"))
+		require.Equal(t, "/invoices", r.URL.Path)
+
+		if r.URL.Query().Get("cursor") == "" {
+			w.Write([]byte(`{"has_more":true,"next":"/invoices?cursor=abc","data":[{"id":"0","updated_at":"2021-01-01T00:00:00Z"},{"id":"1","updated_at":"2021-01-02T10:00:00Z"}]}`))
+		} else {
+			w.Write([]byte(`{"has_more":false,"data":[{"id":"2","updated_at":"2021-01-03T12:30:00Z"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	driver, err := NewRecurly(context.Background(), &SourceConfig{Config: map[string]interface{}{"api_key": "key", "start_date": "2021-01-01"}},
+		&Collection{Name: "invoices", Type: invoicesCollection})
+	require.NoError(t, err)
+	recurly := driver.(*Recurly)
+	recurly.url = server.URL
+	recurly.SetStateStorage("source", testStateStorage{})
+
+	objects, err := recurly.GetObjectsFor(allIntervals()[0])
+	require.NoError(t, err)
+	//begin_time is inclusive: objects updated exactly at the cursor time are skipped
+	require.Len(t, objects, 2)
+	require.Equal(t, "1", objects[0]["id"])
+	require.Equal(t, "2", objects[1]["id"])
+	require.Equal(t, "begin_time=2021-01-01T00%3A00%3A00Z&limit=200&order=asc&sort=updated_at", requests[0])
+	require.Equal(t, "cursor=abc", requests[1])
+
+	require.NoError(t, recurly.Commit())
+	cursor, err := recurly.get()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 1, 3, 12, 30, 0, 0, time.UTC), cursor.UTC())
+
+	_, err = NewRecurly(context.Background(), &SourceConfig{Config: map[string]interface{}{"api_key": "key"}}, &Collection{Name: "c", Type: "accounts"})
+	require.EqualError(t, err, "Recurly unknown collection type [accounts]. Available types: [plans subscriptions invoices transactions]")
+}
@@ -0,0 +1,158 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/uuid"
+	"google.golang.org/api/option"
+	sc "google.golang.org/api/webmasters/v3"
+)
+
+const (
+	searchConsoleType = "search_console"
+
+	searchConsolePerformanceCollection = "performance"
+
+	//max rows per Search Analytics request
+	searchConsolePageSize = 25000
+)
+
+var defaultSearchConsoleDimensions = []string{"query", "page"}
+
+//SearchConsoleConfig is a Google Search Console property config
+//site_url is a property e.g. https://www.example.com/ or sc-domain:example.com
+type SearchConsoleConfig struct {
+	AuthConfig *GoogleAuthConfig `mapstructure:"auth" json:"auth,omitempty" yaml:"auth,omitempty"`
+	SiteUrl    string            `mapstructure:"site_url" json:"site_url,omitempty" yaml:"site_url,omitempty"`
+	Days       int               `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (scc *SearchConsoleConfig) Validate() error {
+	if scc == nil {
+		return errors.New("SearchConsole config is required")
+	}
+	if scc.SiteUrl == "" {
+		return errors.New("SearchConsole site_url is required parameter")
+	}
+	if scc.Days <= 0 {
+		scc.Days = defaultGoogleReportDays
+	}
+
+	return scc.AuthConfig.Validate()
+}
+
+//SearchConsoleCollectionConfig is a performance report parameters
+//search_type: web, image, video or news. Default value is web
+type SearchConsoleCollectionConfig struct {
+	Dimensions []string `mapstructure:"dimensions" json:"dimensions,omitempty" yaml:"dimensions,omitempty"`
+	SearchType string   `mapstructure:"search_type" json:"search_type,omitempty" yaml:"search_type,omitempty"`
+}
+
+//SearchConsole is a driver for Google Search Console performance reports with DAY granularity
+type SearchConsole struct {
+	ctx              context.Context
+	config           *SearchConsoleConfig
+	collectionConfig *SearchConsoleCollectionConfig
+	service          *sc.Service
+	collection       *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(searchConsoleType, NewSearchConsole); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", searchConsoleType, err)
+	}
+}
+
+func NewSearchConsole(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &SearchConsoleConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != searchConsolePerformanceCollection {
+		return nil, fmt.Errorf("SearchConsole unknown collection type [%s]. Available types: [%s]", collection.Type, searchConsolePerformanceCollection)
+	}
+
+	collectionConfig := &SearchConsoleCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if len(collectionConfig.Dimensions) == 0 {
+		collectionConfig.Dimensions = defaultSearchConsoleDimensions
+	}
+
+	credentialsJSON, err := config.AuthConfig.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	service, err := sc.NewService(ctx, option.WithCredentialsJSON(credentialsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("SearchConsole error creating service: %v", err)
+	}
+
+	return &SearchConsole{ctx: ctx, config: config, collectionConfig: collectionConfig, service: service, collection: collection}, nil
+}
+
+func (s *SearchConsole) GetCollectionTable() string {
+	return s.collection.GetTableName()
+}
+
+func (s *SearchConsole) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return lastDaysIntervals(s.config.Days), nil
+}
+
+//GetObjectsFor return performance rows of the interval day: dimensions values, clicks, impressions, ctr, position
+//and report_date field
+func (s *SearchConsole) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	reportDate := interval.LowerEndpoint().Format(dayLayout)
+
+	var result []map[string]interface{}
+	for startRow := int64(0); ; {
+		request := &sc.SearchAnalyticsQueryRequest{
+			StartDate:  reportDate,
+			EndDate:    reportDate,
+			Dimensions: s.collectionConfig.Dimensions,
+			SearchType: s.collectionConfig.SearchType,
+			RowLimit:   searchConsolePageSize,
+			StartRow:   startRow,
+		}
+		response, err := s.service.Searchanalytics.Query(s.config.SiteUrl, request).Context(s.ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("SearchConsole error querying %s report: %v", reportDate, err)
+		}
+
+		for _, row := range response.Rows {
+			object := map[string]interface{}{}
+			for i := 0; i < len(s.collectionConfig.Dimensions) && i < len(row.Keys); i++ {
+				object[s.collectionConfig.Dimensions[i]] = row.Keys[i]
+			}
+			object["clicks"] = int64(row.Clicks)
+			object["impressions"] = int64(row.Impressions)
+			object["ctr"] = row.Ctr
+			object["position"] = row.Position
+			object[reportDateKey] = reportDate
+			object[eventCtx] = map[string]interface{}{eventId: uuid.GetHash(object)}
+			result = append(result, object)
+		}
+
+		if len(response.Rows) < searchConsolePageSize {
+			break
+		}
+		startRow += int64(len(response.Rows))
+	}
+
+	return result, nil
+}
+
+func (s *SearchConsole) Type() string {
+	return searchConsoleType
+}
+
+func (s *SearchConsole) Close() error {
+	return nil
+}
@@ -0,0 +1,240 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"net/http"
+	"net/url"
+)
+
+const (
+	slackType = "slack"
+
+	slackAuditLogsCollection = "audit_logs"
+	slackMessagesCollection  = "messages"
+
+	slackApiUrl      = "https://slack.com/api/"
+	slackAuditLogUrl = "https://api.slack.com/audit/v1/logs"
+	slackPageSize    = 200
+)
+
+//SlackConfig is a Slack access token config
+//audit_logs collection requires Enterprise Grid org owner user token with auditlogs:read scope
+//messages collection requires token with channels:read and channels:history scopes
+type SlackConfig struct {
+	AccessToken string `mapstructure:"access_token" json:"access_token,omitempty" yaml:"access_token,omitempty"`
+	Days        int    `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (sc *SlackConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Slack config is required")
+	}
+	if sc.AccessToken == "" {
+		return errors.New("Slack access_token is required parameter")
+	}
+	if sc.Days <= 0 {
+		sc.Days = defaultGoogleReportDays
+	}
+
+	return nil
+}
+
+//SlackCollectionConfig is a messages collection parameters: channels ids. Default - all public channels
+type SlackCollectionConfig struct {
+	Channels []string `mapstructure:"channels" json:"channels,omitempty" yaml:"channels,omitempty"`
+}
+
+type slackResponseMetadata struct {
+	NextCursor string `json:"next_cursor"`
+}
+
+type slackAuditLogsResponse struct {
+	Entries          []map[string]interface{} `json:"entries"`
+	ResponseMetadata slackResponseMetadata    `json:"response_metadata"`
+}
+
+type slackHistoryResponse struct {
+	Ok               bool                     `json:"ok"`
+	Error            string                   `json:"error"`
+	Messages         []map[string]interface{} `json:"messages"`
+	ResponseMetadata slackResponseMetadata    `json:"response_metadata"`
+}
+
+type slackChannelsResponse struct {
+	Ok       bool   `json:"ok"`
+	Error    string `json:"error"`
+	Channels []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channels"`
+	ResponseMetadata slackResponseMetadata `json:"response_metadata"`
+}
+
+//Slack is a driver for Slack audit logs and channels messages export with DAY granularity
+type Slack struct {
+	config           *SlackConfig
+	collectionConfig *SlackCollectionConfig
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(slackType, NewSlack); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", slackType, err)
+	}
+}
+
+func NewSlack(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &SlackConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != slackAuditLogsCollection && collection.Type != slackMessagesCollection {
+		return nil, fmt.Errorf("Slack unknown collection type [%s]. Available types: [%s, %s]", collection.Type, slackAuditLogsCollection, slackMessagesCollection)
+	}
+
+	collectionConfig := &SlackCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+
+	return &Slack{config: config, collectionConfig: collectionConfig, ctx: ctx, collection: collection}, nil
+}
+
+func (s *Slack) GetCollectionTable() string {
+	return s.collection.GetTableName()
+}
+
+func (s *Slack) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return lastDaysIntervals(s.config.Days), nil
+}
+
+//GetObjectsFor return audit log entries or channels messages of the interval day
+func (s *Slack) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	oldest := interval.LowerEndpoint().Unix()
+	latest := interval.UpperEndpoint().Unix()
+
+	if s.collection.Type == slackAuditLogsCollection {
+		return s.getAuditLogs(oldest, latest)
+	}
+
+	return s.getMessages(oldest, latest)
+}
+
+func (s *Slack) Type() string {
+	return slackType
+}
+
+func (s *Slack) Close() error {
+	return nil
+}
+
+func (s *Slack) getAuditLogs(oldest, latest int64) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	query := url.Values{}
+	query.Set("oldest", fmt.Sprint(oldest))
+	query.Set("latest", fmt.Sprint(latest))
+	query.Set("limit", fmt.Sprint(slackPageSize))
+	for {
+		response := &slackAuditLogsResponse{}
+		if err := doJsonRequest(http.MethodGet, slackAuditLogUrl+"?"+query.Encode(), nil, s.authorize, response); err != nil {
+			return nil, fmt.Errorf("Slack error getting audit logs: %v", err)
+		}
+		objects = append(objects, response.Entries...)
+
+		if response.ResponseMetadata.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", response.ResponseMetadata.NextCursor)
+	}
+
+	return objects, nil
+}
+
+//getMessages return messages of configured channels (or all public channels) with channel_id and channel_name fields
+func (s *Slack) getMessages(oldest, latest int64) ([]map[string]interface{}, error) {
+	channels, err := s.getChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []map[string]interface{}
+	for channelId, channelName := range channels {
+		query := url.Values{}
+		query.Set("channel", channelId)
+		query.Set("oldest", fmt.Sprint(oldest))
+		query.Set("latest", fmt.Sprint(latest))
+		query.Set("inclusive", "true")
+		query.Set("limit", fmt.Sprint(slackPageSize))
+		for {
+			response := &slackHistoryResponse{}
+			if err := doJsonRequest(http.MethodGet, slackApiUrl+"conversations.history?"+query.Encode(), nil, s.authorize, response); err != nil {
+				return nil, fmt.Errorf("Slack error getting channel [%s] history: %v", channelId, err)
+			}
+			if !response.Ok {
+				return nil, fmt.Errorf("Slack error getting channel [%s] history: %s", channelId, response.Error)
+			}
+
+			for _, message := range response.Messages {
+				message["channel_id"] = channelId
+				message["channel_name"] = channelName
+				objects = append(objects, message)
+			}
+
+			if response.ResponseMetadata.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", response.ResponseMetadata.NextCursor)
+		}
+	}
+
+	return objects, nil
+}
+
+//getChannels return configured channels ids or all public channels ids with names
+func (s *Slack) getChannels() (map[string]string, error) {
+	channels := map[string]string{}
+	if len(s.collectionConfig.Channels) > 0 {
+		for _, channel := range s.collectionConfig.Channels {
+			channels[channel] = ""
+		}
+		return channels, nil
+	}
+
+	query := url.Values{}
+	query.Set("types", "public_channel")
+	query.Set("exclude_archived", "true")
+	query.Set("limit", fmt.Sprint(slackPageSize))
+	for {
+		response := &slackChannelsResponse{}
+		if err := doJsonRequest(http.MethodGet, slackApiUrl+"conversations.list?"+query.Encode(), nil, s.authorize, response); err != nil {
+			return nil, fmt.Errorf("Slack error getting channels: %v", err)
+		}
+		if !response.Ok {
+			return nil, fmt.Errorf("Slack error getting channels: %s", response.Error)
+		}
+
+		for _, channel := range response.Channels {
+			channels[channel.Id] = channel.Name
+		}
+
+		if response.ResponseMetadata.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", response.ResponseMetadata.NextCursor)
+	}
+
+	return channels, nil
+}
+
+func (s *Slack) authorize(r *http.Request) {
+	r.Header.Set("Authorization", "Bearer "+s.config.AccessToken)
+}
@@ -0,0 +1,132 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	sf "github.com/snowflakedb/gosnowflake"
+)
+
+const snowflakeType = "snowflake"
+
+//SnowflakeConfig is a Snowflake connection config of query source
+type SnowflakeConfig struct {
+	Account    string             `mapstructure:"account" json:"account,omitempty" yaml:"account,omitempty"`
+	Port       int                `mapstructure:"port" json:"port,omitempty" yaml:"port,omitempty"`
+	Db         string             `mapstructure:"db" json:"db,omitempty" yaml:"db,omitempty"`
+	Schema     string             `mapstructure:"schema" json:"schema,omitempty" yaml:"schema,omitempty"`
+	Username   string             `mapstructure:"username" json:"username,omitempty" yaml:"username,omitempty"`
+	Password   string             `mapstructure:"password" json:"password,omitempty" yaml:"password,omitempty"`
+	Warehouse  string             `mapstructure:"warehouse" json:"warehouse,omitempty" yaml:"warehouse,omitempty"`
+	Parameters map[string]*string `mapstructure:"parameters" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+func (sc *SnowflakeConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Snowflake config is required")
+	}
+	if sc.Account == "" {
+		return errors.New("Snowflake account is required parameter")
+	}
+	if sc.Db == "" {
+		return errors.New("Snowflake db is required parameter")
+	}
+	if sc.Username == "" {
+		return errors.New("Snowflake username is required parameter")
+	}
+	if sc.Warehouse == "" {
+		return errors.New("Snowflake warehouse is required parameter")
+	}
+
+	return nil
+}
+
+//Snowflake is a driver for loading SQL query results from Snowflake (e.g. for reverse-ETL)
+type Snowflake struct {
+	config           *SnowflakeConfig
+	collectionConfig *QueryCollectionConfig
+	dataSource       *sql.DB
+	ctx              context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(snowflakeType, NewSnowflake); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", snowflakeType, err)
+	}
+}
+
+func NewSnowflake(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &SnowflakeConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig, err := parseQueryCollectionConfig(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	connectionString, err := sf.DSN(&sf.Config{
+		Account:   config.Account,
+		User:      config.Username,
+		Password:  config.Password,
+		Port:      config.Port,
+		Schema:    config.Schema,
+		Database:  config.Db,
+		Warehouse: config.Warehouse,
+		Params:    config.Parameters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Snowflake error building connection string: %v", err)
+	}
+
+	dataSource, err := sql.Open("snowflake", connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("Snowflake error opening connection: %v", err)
+	}
+	if err := dataSource.PingContext(ctx); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Snowflake error connecting to database: %v", err)
+	}
+
+	return &Snowflake{config: config, collectionConfig: collectionConfig, dataSource: dataSource, ctx: ctx, collection: collection}, nil
+}
+
+func (s *Snowflake) GetCollectionTable() string {
+	return s.collection.GetTableName()
+}
+
+func (s *Snowflake) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return allIntervals(), nil
+}
+
+//GetObjectsFor run collection query and return all result rows
+func (s *Snowflake) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	rows, err := s.dataSource.QueryContext(s.ctx, s.collectionConfig.Query)
+	if err != nil {
+		return nil, fmt.Errorf("Snowflake error running [%s] collection query: %v", s.collection.Name, err)
+	}
+	defer rows.Close()
+
+	objects, err := scanRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Snowflake error reading [%s] collection query result: %v", s.collection.Name, err)
+	}
+
+	return objects, nil
+}
+
+func (s *Snowflake) Type() string {
+	return snowflakeType
+}
+
+func (s *Snowflake) Close() error {
+	return s.dataSource.Close()
+}
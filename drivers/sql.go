@@ -0,0 +1,212 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "github.com/denisenkom/go-mssqldb"
+	"github.com/jitsucom/eventnative/logging"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	sqlType = "sql"
+
+	sqlQueryTimeLayout = "2006-01-02 15:04:05"
+)
+
+//SqlConfig is a database/sql connection config: driver is one of compiled in database/sql drivers
+//(e.g. postgres, sqlserver, clickhouse, snowflake) and dsn is a driver specific connection string
+type SqlConfig struct {
+	Driver string `mapstructure:"driver" json:"driver,omitempty" yaml:"driver,omitempty"`
+	Dsn    string `mapstructure:"dsn" json:"dsn,omitempty" yaml:"dsn,omitempty"`
+}
+
+func (sc *SqlConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Sql config is required")
+	}
+	if sc.Driver == "" {
+		return errors.New("Sql driver is required parameter")
+	}
+	if sc.Dsn == "" {
+		return errors.New("Sql dsn is required parameter")
+	}
+
+	available := sql.Drivers()
+	sort.Strings(available)
+	for _, driver := range available {
+		if driver == sc.Driver {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Sql unknown driver [%s]. Available drivers: [%s]", sc.Driver, strings.Join(available, ", "))
+}
+
+//SqlCollectionConfig is a query template with interval placeholders. If granularity (DAY, MONTH or YEAR) is set -
+//query is run per every interval from start_date (YYYY-MM-DD) till now, otherwise the whole query result is reloaded on every sync
+type SqlCollectionConfig struct {
+	Query       string `mapstructure:"query" json:"query,omitempty" yaml:"query,omitempty"`
+	Granularity string `mapstructure:"granularity" json:"granularity,omitempty" yaml:"granularity,omitempty"`
+	StartDate   string `mapstructure:"start_date" json:"start_date,omitempty" yaml:"start_date,omitempty"`
+}
+
+//SqlQueryInterval is a data of query template:
+//From and To are interval endpoints formatted as 'YYYY-MM-DD HH:MM:SS' (To is exclusive)
+//Start and End are the same endpoints as time.Time for custom formatting e.g. {{.Start.Format "2006-01-02"}}
+type SqlQueryInterval struct {
+	From  string
+	To    string
+	Start time.Time
+	End   time.Time
+}
+
+//Sql is a driver for loading SQL query results from any database with compiled in database/sql driver
+type Sql struct {
+	config      *SqlConfig
+	query       *template.Template
+	granularity Granularity
+	startDate   time.Time
+	dataSource  *sql.DB
+	ctx         context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(sqlType, NewSql); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", sqlType, err)
+	}
+}
+
+func NewSql(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &SqlConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	collectionConfig := &SqlCollectionConfig{}
+	if err := unmarshalConfig(collection.Parameters, collectionConfig); err != nil {
+		return nil, err
+	}
+	if collectionConfig.Query == "" {
+		return nil, errors.New("Sql query collection parameter is required")
+	}
+	query, err := template.New(collection.Name).Option("missingkey=error").Parse(collectionConfig.Query)
+	if err != nil {
+		return nil, fmt.Errorf("Sql error parsing [%s] collection query template: %v", collection.Name, err)
+	}
+
+	granularity, err := parseSqlGranularity(collectionConfig.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	var startDate time.Time
+	if granularity != ALL {
+		if collectionConfig.StartDate == "" {
+			return nil, errors.New("Sql start_date collection parameter is required if granularity is set")
+		}
+		startDate, err = parseStartDate(collectionConfig.StartDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dataSource, err := sql.Open(config.Driver, config.Dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Sql error opening [%s] connection: %v", config.Driver, err)
+	}
+	if err := dataSource.PingContext(ctx); err != nil {
+		dataSource.Close()
+		return nil, fmt.Errorf("Sql error connecting to [%s] database: %v", config.Driver, err)
+	}
+
+	return &Sql{config: config, query: query, granularity: granularity, startDate: startDate, dataSource: dataSource, ctx: ctx, collection: collection}, nil
+}
+
+func (s *Sql) GetCollectionTable() string {
+	return s.collection.GetTableName()
+}
+
+//GetAllAvailableIntervals return intervals from start date till now or one ALL interval if granularity isn't set
+func (s *Sql) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	if s.granularity == ALL {
+		return allIntervals(), nil
+	}
+
+	var intervals []*TimeInterval
+	now := time.Now().UTC()
+	for t := s.granularity.Lower(s.startDate); !t.After(now); t = s.granularity.Upper(t).Add(time.Nanosecond) {
+		intervals = append(intervals, NewTimeInterval(s.granularity, t))
+	}
+
+	return intervals, nil
+}
+
+//GetObjectsFor run collection query with the interval endpoints and return all result rows
+func (s *Sql) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	query, err := s.buildQuery(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.dataSource.QueryContext(s.ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("Sql error running [%s] collection query: %v", s.collection.Name, err)
+	}
+	defer rows.Close()
+
+	objects, err := scanRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Sql error reading [%s] collection query result: %v", s.collection.Name, err)
+	}
+
+	return objects, nil
+}
+
+func (s *Sql) Type() string {
+	return sqlType
+}
+
+func (s *Sql) Close() error {
+	return s.dataSource.Close()
+}
+
+//buildQuery return query template executed with interval endpoints (empty for ALL interval)
+func (s *Sql) buildQuery(interval *TimeInterval) (string, error) {
+	data := SqlQueryInterval{}
+	if !interval.IsAll() {
+		data.Start = interval.LowerEndpoint()
+		data.End = interval.UpperEndpoint().Add(time.Nanosecond)
+		data.From = data.Start.Format(sqlQueryTimeLayout)
+		data.To = data.End.Format(sqlQueryTimeLayout)
+	}
+
+	var buf bytes.Buffer
+	if err := s.query.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Sql error building [%s] collection query: %v", s.collection.Name, err)
+	}
+
+	return buf.String(), nil
+}
+
+//parseSqlGranularity return ALL if value is empty
+func parseSqlGranularity(value string) (Granularity, error) {
+	switch granularity := Granularity(strings.ToUpper(value)); granularity {
+	case "", ALL:
+		return ALL, nil
+	case DAY, MONTH, YEAR:
+		return granularity, nil
+	default:
+		return "", fmt.Errorf("Sql unknown granularity [%s]. Available: [%s, %s, %s]", value, DAY, MONTH, YEAR)
+	}
+}
@@ -0,0 +1,41 @@
+package drivers
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+//scanRows return sql rows as objects: column name -> value ([]byte values are converted into strings)
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading columns: %v", err)
+	}
+
+	var objects []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("Error scanning row: %v", err)
+		}
+
+		object := map[string]interface{}{}
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				object[column] = string(b)
+			} else {
+				object[column] = values[i]
+			}
+		}
+		objects = append(objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading rows: %v", err)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,37 @@
+package drivers
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestSqlBuildQuery(t *testing.T) {
+	query := template.Must(template.New("orders").Option("missingkey=error").
+		Parse(`SELECT * FROM orders WHERE created_at >= '{{.From}}' AND created_at < '{{.To}}' -- {{.Start.Format "20060102"}}`))
+	s := &Sql{query: query, granularity: MONTH, startDate: time.Date(2020, 11, 15, 0, 0, 0, 0, time.UTC), collection: &Collection{Name: "orders"}}
+
+	actual, err := s.buildQuery(NewTimeInterval(MONTH, time.Date(2020, 12, 10, 0, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM orders WHERE created_at >= '2020-12-01 00:00:00' AND created_at < '2021-01-01 00:00:00' -- 20201201", actual)
+
+	intervals, err := s.GetAllAvailableIntervals()
+	require.NoError(t, err)
+	require.True(t, len(intervals) > 2)
+	require.Equal(t, "UTC_MONTH_2020-11", intervals[0].String())
+	require.Equal(t, "UTC_MONTH_2020-12", intervals[1].String())
+	require.False(t, intervals[len(intervals)-1].LowerEndpoint().After(time.Now().UTC()))
+}
+
+func TestSqlConfigValidate(t *testing.T) {
+	require.NoError(t, (&SqlConfig{Driver: "postgres", Dsn: "host=localhost"}).Validate())
+	require.NoError(t, (&SqlConfig{Driver: "sqlserver", Dsn: "sqlserver://localhost"}).Validate())
+	require.Error(t, (&SqlConfig{Driver: "unknown", Dsn: "dsn"}).Validate())
+
+	_, err := parseSqlGranularity("hour")
+	require.Error(t, err)
+	granularity, err := parseSqlGranularity("day")
+	require.NoError(t, err)
+	require.Equal(t, DAY, granularity)
+}
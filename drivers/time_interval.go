@@ -45,3 +45,14 @@ func (ti *TimeInterval) String() string {
 func (ti *TimeInterval) IsAll() bool {
 	return ti.granularity == ALL
 }
+
+//lastDaysIntervals return day intervals for the last days (yesterday, the day before yesterday, ...)
+func lastDaysIntervals(days int) []*TimeInterval {
+	var intervals []*TimeInterval
+	now := time.Now().UTC()
+	for i := 1; i <= days; i++ {
+		intervals = append(intervals, NewTimeInterval(DAY, now.AddDate(0, 0, -i)))
+	}
+
+	return intervals
+}
@@ -0,0 +1,150 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/typing"
+	"net/http"
+	"net/url"
+)
+
+const (
+	twilioType = "twilio"
+
+	twilioMessagesCollection = "messages"
+	twilioCallsCollection    = "calls"
+
+	twilioApiUrl   = "https://api.twilio.com"
+	twilioPageSize = 1000
+)
+
+//twilioCollections is a collection type - API resource, date filter and response list field
+var twilioCollections = map[string]struct {
+	resource   string
+	dateFilter string
+	listField  string
+}{
+	twilioMessagesCollection: {resource: "Messages.json", dateFilter: "DateSent", listField: "messages"},
+	twilioCallsCollection:    {resource: "Calls.json", dateFilter: "StartTime", listField: "calls"},
+}
+
+//TwilioConfig is a Twilio account config
+//api_key_sid and api_key_secret can be used instead of auth_token
+type TwilioConfig struct {
+	AccountSid   string `mapstructure:"account_sid" json:"account_sid,omitempty" yaml:"account_sid,omitempty"`
+	AuthToken    string `mapstructure:"auth_token" json:"auth_token,omitempty" yaml:"auth_token,omitempty"`
+	ApiKeySid    string `mapstructure:"api_key_sid" json:"api_key_sid,omitempty" yaml:"api_key_sid,omitempty"`
+	ApiKeySecret string `mapstructure:"api_key_secret" json:"api_key_secret,omitempty" yaml:"api_key_secret,omitempty"`
+	Days         int    `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (tc *TwilioConfig) Validate() error {
+	if tc == nil {
+		return errors.New("Twilio config is required")
+	}
+	if tc.AccountSid == "" {
+		return errors.New("Twilio account_sid is required parameter")
+	}
+	if tc.AuthToken == "" && (tc.ApiKeySid == "" || tc.ApiKeySecret == "") {
+		return errors.New("Twilio auth_token or api_key_sid and api_key_secret are required parameters")
+	}
+	if tc.Days <= 0 {
+		tc.Days = defaultGoogleReportDays
+	}
+
+	return nil
+}
+
+//Twilio is a driver for Twilio messages and calls logs with DAY granularity
+type Twilio struct {
+	config *TwilioConfig
+	ctx    context.Context
+
+	collection *Collection
+}
+
+func init() {
+	if err := RegisterDriverConstructor(twilioType, NewTwilio); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", twilioType, err)
+	}
+}
+
+func NewTwilio(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &TwilioConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := twilioCollections[collection.Type]; !ok {
+		return nil, fmt.Errorf("Twilio unknown collection type [%s]. Available types: [%s, %s]", collection.Type, twilioMessagesCollection, twilioCallsCollection)
+	}
+
+	return &Twilio{config: config, ctx: ctx, collection: collection}, nil
+}
+
+func (t *Twilio) GetCollectionTable() string {
+	return t.collection.GetTableName()
+}
+
+func (t *Twilio) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return lastDaysIntervals(t.config.Days), nil
+}
+
+//GetObjectsFor return messages sent or calls started in the interval day
+func (t *Twilio) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	collection := twilioCollections[t.collection.Type]
+	query := url.Values{}
+	query.Set(collection.dateFilter, interval.LowerEndpoint().Format(dayLayout))
+	query.Set("PageSize", fmt.Sprint(twilioPageSize))
+	requestUrl := fmt.Sprintf("%s/2010-04-01/Accounts/%s/%s?%s", twilioApiUrl, t.config.AccountSid, collection.resource, query.Encode())
+
+	var objects []map[string]interface{}
+	for requestUrl != "" {
+		response := map[string]interface{}{}
+		if err := doJsonRequest(http.MethodGet, requestUrl, nil, t.authorize, &response); err != nil {
+			return nil, fmt.Errorf("Twilio error getting %s: %v", t.collection.Type, err)
+		}
+
+		items, _ := response[collection.listField].([]interface{})
+		for _, item := range items {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if price, ok := object["price"].(string); ok && price != "" {
+				if value, err := typing.StringToFloat(price); err == nil {
+					object["price"] = value
+				}
+			}
+			objects = append(objects, object)
+		}
+
+		requestUrl = ""
+		if nextPageUri, ok := response["next_page_uri"].(string); ok && nextPageUri != "" {
+			requestUrl = twilioApiUrl + nextPageUri
+		}
+	}
+
+	return objects, nil
+}
+
+func (t *Twilio) Type() string {
+	return twilioType
+}
+
+func (t *Twilio) Close() error {
+	return nil
+}
+
+func (t *Twilio) authorize(r *http.Request) {
+	if t.config.ApiKeySid != "" {
+		r.SetBasicAuth(t.config.ApiKeySid, t.config.ApiKeySecret)
+	} else {
+		r.SetBasicAuth(t.config.AccountSid, t.config.AuthToken)
+	}
+}
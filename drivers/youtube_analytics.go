@@ -0,0 +1,159 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/uuid"
+	"google.golang.org/api/option"
+	ya "google.golang.org/api/youtubeanalytics/v2"
+	"strings"
+)
+
+const (
+	youTubeAnalyticsType = "youtube_analytics"
+
+	youTubePageSize = 200
+
+	defaultGoogleReportDays = 30
+)
+
+//YouTubeAnalyticsConfig is a YouTube channel analytics config
+//YouTube Analytics API requires OAuth credentials (client_id, client_secret, refresh_token) of the channel owner
+type YouTubeAnalyticsConfig struct {
+	AuthConfig *GoogleAuthConfig `mapstructure:"auth" json:"auth,omitempty" yaml:"auth,omitempty"`
+	ChannelId  string            `mapstructure:"channel_id" json:"channel_id,omitempty" yaml:"channel_id,omitempty"`
+	Days       int               `mapstructure:"days" json:"days,omitempty" yaml:"days,omitempty"`
+}
+
+func (yac *YouTubeAnalyticsConfig) Validate() error {
+	if yac == nil {
+		return errors.New("YouTubeAnalytics config is required")
+	}
+	if yac.Days <= 0 {
+		yac.Days = defaultGoogleReportDays
+	}
+
+	return yac.AuthConfig.Validate()
+}
+
+//YouTubeAnalytics is a driver for YouTube channel analytics reports with DAY granularity
+type YouTubeAnalytics struct {
+	ctx                context.Context
+	config             *YouTubeAnalyticsConfig
+	service            *ya.Service
+	collection         *Collection
+	reportFieldsConfig *ReportFieldsConfig
+}
+
+func init() {
+	if err := RegisterDriverConstructor(youTubeAnalyticsType, NewYouTubeAnalytics); err != nil {
+		logging.Errorf("Failed to register driver %s: %v", youTubeAnalyticsType, err)
+	}
+}
+
+func NewYouTubeAnalytics(ctx context.Context, sourceConfig *SourceConfig, collection *Collection) (Driver, error) {
+	config := &YouTubeAnalyticsConfig{}
+	if err := unmarshalConfig(sourceConfig.Config, config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if collection.Type != reportsCollection {
+		return nil, fmt.Errorf("YouTubeAnalytics unknown collection type [%s]. Available types: [%s]", collection.Type, reportsCollection)
+	}
+
+	reportFieldsConfig := &ReportFieldsConfig{}
+	if err := unmarshalConfig(collection.Parameters, reportFieldsConfig); err != nil {
+		return nil, err
+	}
+	if len(reportFieldsConfig.Metrics) == 0 {
+		return nil, errors.New("YouTubeAnalytics metrics must not be empty")
+	}
+
+	credentialsJSON, err := config.AuthConfig.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	service, err := ya.NewService(ctx, option.WithCredentialsJSON(credentialsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("YouTubeAnalytics error creating service: %v", err)
+	}
+
+	return &YouTubeAnalytics{ctx: ctx, config: config, service: service, collection: collection, reportFieldsConfig: reportFieldsConfig}, nil
+}
+
+func (y *YouTubeAnalytics) GetCollectionTable() string {
+	return y.collection.GetTableName()
+}
+
+func (y *YouTubeAnalytics) GetAllAvailableIntervals() ([]*TimeInterval, error) {
+	return lastDaysIntervals(y.config.Days), nil
+}
+
+//GetObjectsFor return report rows of the interval day with report_date field
+func (y *YouTubeAnalytics) GetObjectsFor(interval *TimeInterval) ([]map[string]interface{}, error) {
+	reportDate := interval.LowerEndpoint().Format(dayLayout)
+	ids := "channel==MINE"
+	if y.config.ChannelId != "" {
+		ids = "channel==" + y.config.ChannelId
+	}
+
+	var result []map[string]interface{}
+	for startIndex := int64(1); ; {
+		call := y.service.Reports.Query().
+			Ids(ids).
+			StartDate(reportDate).
+			EndDate(reportDate).
+			Metrics(strings.Join(y.reportFieldsConfig.Metrics, ",")).
+			StartIndex(startIndex).
+			Context(y.ctx)
+		//YouTube allows paging only for reports with dimensions
+		if len(y.reportFieldsConfig.Dimensions) > 0 {
+			call = call.Dimensions(strings.Join(y.reportFieldsConfig.Dimensions, ",")).MaxResults(youTubePageSize)
+		}
+
+		response, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("YouTubeAnalytics error querying %s report: %v", reportDate, err)
+		}
+
+		for _, row := range response.Rows {
+			object := map[string]interface{}{}
+			for i := 0; i < len(response.ColumnHeaders) && i < len(row); i++ {
+				header := response.ColumnHeaders[i]
+				object[header.Name] = youTubeValue(header.DataType, row[i])
+			}
+			object[reportDateKey] = reportDate
+			object[eventCtx] = map[string]interface{}{eventId: uuid.GetHash(object)}
+			result = append(result, object)
+		}
+
+		if len(y.reportFieldsConfig.Dimensions) == 0 || len(response.Rows) < youTubePageSize {
+			break
+		}
+		startIndex += int64(len(response.Rows))
+	}
+
+	return result, nil
+}
+
+func (y *YouTubeAnalytics) Type() string {
+	return youTubeAnalyticsType
+}
+
+func (y *YouTubeAnalytics) Close() error {
+	return nil
+}
+
+//youTubeValue return int64 for INTEGER columns (JSON numbers are decoded as float64)
+func youTubeValue(dataType string, value interface{}) interface{} {
+	if f, ok := value.(float64); ok && dataType == "INTEGER" {
+		return int64(f)
+	}
+
+	return value
+}
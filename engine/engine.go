@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/backup"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/dedup"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drift"
+	"github.com/jitsucom/eventnative/enrichment"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/features"
+	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/secrets"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/synchronization"
+	"github.com/jitsucom/eventnative/users"
+	"github.com/spf13/viper"
+	"io"
+	"sync"
+)
+
+const destinationsKey = "destinations"
+
+//Engine is an embeddable EventNative pipeline without HTTP server:
+//events enrichment, identity stitching, caching and multiplexing into destinations.
+//Engine is configured with the same keys as the server (see config/config.template.yaml).
+//Application config and counters are global so only one Engine per process is supported
+type Engine struct {
+	sync.Mutex
+
+	syncService         synchronization.Service
+	metaStorage         meta.Storage
+	eventsCache         *caching.EventsCache
+	inMemoryEventsCache *events.Cache
+	destinations        *destinations.Service
+	recognition         *users.RecognitionService
+	identity            *users.IdentityService
+	eventHandler        *handlers.EventHandler
+
+	//closeMe are closed in reverse order
+	closeMe []io.Closer
+	closed  bool
+}
+
+//New merge config (might be nil if global viper is already configured) into global viper,
+//initialize application config (if it hasn't been initialized yet) and create all Engine components
+func New(ctx context.Context, config *viper.Viper) (*Engine, error) {
+	if config != nil {
+		if err := viper.MergeConfigMap(config.AllSettings()); err != nil {
+			return nil, fmt.Errorf("Error merging engine config: %v", err)
+		}
+	}
+
+	if appconfig.Instance == nil {
+		if err := appconfig.Init(); err != nil {
+			return nil, err
+		}
+		enrichment.InitDefault()
+	}
+
+	e := &Engine{}
+	if err := e.init(ctx); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *Engine) init(ctx context.Context) error {
+	logEventPath := viper.GetString("log.path")
+	if !logging.IsDirWritable(logEventPath) {
+		return fmt.Errorf("log.path: %s must be writable", logEventPath)
+	}
+
+	loggerFactory := logging.NewFactory(logEventPath, viper.GetInt64("log.rotation_min"), viper.GetBool("log.show_in_server"),
+		appconfig.Instance.DDLLogsWriter, appconfig.Instance.QueryLogsWriter)
+
+	//synchronization service
+	syncService, err := synchronization.NewService(
+		ctx,
+		appconfig.Instance.ServerName,
+		viper.GetString("synchronization_service.type"),
+		viper.GetString("synchronization_service.endpoint"),
+		viper.GetUint("synchronization_service.connection_timeout_seconds"))
+	if err != nil {
+		return fmt.Errorf("Failed to initiate synchronization service: %v", err)
+	}
+	e.syncService = syncService
+
+	//meta storage
+	metaStorage, err := meta.NewStorage(MetaStorageViper())
+	if err != nil {
+		return fmt.Errorf("Error initializing meta storage: %v", err)
+	}
+	e.metaStorage = metaStorage
+	e.closeMe = append(e.closeMe, metaStorage)
+
+	//meta storage snapshots for disaster recovery
+	if viper.IsSet("meta.backup") {
+		backupConfig := &backup.Config{}
+		if err := viper.UnmarshalKey("meta.backup", backupConfig); err != nil {
+			return fmt.Errorf("Error parsing 'meta.backup' config: %v", err)
+		}
+		backupService, err := backup.NewService(ctx, metaStorage, backupConfig)
+		if err != nil {
+			return err
+		}
+		e.closeMe = append(e.closeMe, backupService)
+	}
+
+	//feature flags
+	featuresConfig := map[string]*features.Flag{}
+	if err := viper.UnmarshalKey("features", &featuresConfig); err != nil {
+		return fmt.Errorf("Error parsing 'features' config: %v", err)
+	}
+	featuresService, err := features.Init(metaStorage, featuresConfig)
+	if err != nil {
+		return fmt.Errorf("Error initializing feature flags: %v", err)
+	}
+	e.closeMe = append(e.closeMe, featuresService)
+
+	//events deduplication window
+	dedupConfig := &dedup.Config{}
+	if err := viper.UnmarshalKey("server.dedup", dedupConfig); err != nil {
+		return fmt.Errorf("Error parsing 'server.dedup' config: %v", err)
+	}
+	if err := dedup.Init(metaStorage, dedupConfig); err != nil {
+		return fmt.Errorf("Error initializing events deduplication: %v", err)
+	}
+
+	//events counters
+	counters.InitEvents(metaStorage)
+	e.closeMe = append(e.closeMe, counters.InitStages(metaStorage))
+	e.closeMe = append(e.closeMe, counters.InitQuotas(metaStorage))
+
+	//unique users counters
+	if viper.GetBool("server.statistics.uniques.enabled") {
+		uniques := counters.InitUniques(metaStorage, viper.GetString("server.statistics.uniques.anonymous_id_node"))
+		e.closeMe = append(e.closeMe, uniques)
+	}
+
+	//event schema drift detection
+	driftConfig := &drift.Config{}
+	if err := viper.UnmarshalKey("server.statistics.drift", driftConfig); err != nil {
+		return fmt.Errorf("Error parsing 'server.statistics.drift' config: %v", err)
+	}
+	driftDetector, err := drift.Init(driftConfig)
+	if err != nil {
+		return err
+	}
+	if driftDetector != nil {
+		e.closeMe = append(e.closeMe, driftDetector)
+	}
+
+	//events cache
+	eventsCacheSize := viper.GetInt("server.cache.events.size")
+	e.eventsCache = caching.NewEventsCache(metaStorage, eventsCacheSize)
+	e.closeMe = append(e.closeMe, e.eventsCache)
+	tokensRetention := &caching.TokensRetentionConfig{}
+	if err := viper.UnmarshalKey("server.cache.events", tokensRetention); err != nil {
+		return fmt.Errorf("Error parsing 'server.cache.events' config: %v", err)
+	}
+	if err := e.eventsCache.ConfigureTokens(tokensRetention); err != nil {
+		return err
+	}
+
+	//Deprecated
+	e.inMemoryEventsCache = events.NewCache(eventsCacheSize)
+	e.closeMe = append(e.closeMe, e.inMemoryEventsCache)
+
+	//Create event destinations
+	destinationsViper, destinationsStr := destinationsConfig()
+	e.destinations, err = destinations.NewService(ctx, destinationsViper, destinationsStr, logEventPath, syncService, e.eventsCache, metaStorage, loggerFactory, storages.Create)
+	if err != nil {
+		return err
+	}
+	e.closeMe = append(e.closeMe, e.destinations)
+	//reload destinations with new credentials when leased secrets (e.g. Vault dynamic credentials) are expired
+	if appconfig.Instance.AuthorizationService.DestinationsForceReload != nil {
+		secrets.OnChange(appconfig.Instance.AuthorizationService.DestinationsForceReload)
+	}
+
+	// ** Retrospective users recognition
+	var recognitionConfiguration *storages.UsersRecognition
+	if viper.IsSet("users_recognition") {
+		recognitionConfiguration = &storages.UsersRecognition{
+			Enabled:         viper.GetBool("users_recognition.enabled"),
+			AnonymousIdNode: viper.GetString("users_recognition.anonymous_id_node"),
+			UserIdNode:      viper.GetString("users_recognition.user_id_node"),
+		}
+	} else {
+		logging.Warnf("Global users recognition isn't configured")
+	}
+
+	e.recognition, err = users.NewRecognitionService(metaStorage, e.destinations, recognitionConfiguration, logEventPath)
+	if err != nil {
+		return err
+	}
+	e.closeMe = append(e.closeMe, e.recognition)
+
+	// ** Identity stitching
+	identityConfiguration := &users.IdentityStitchingConfig{
+		Enabled:         viper.GetBool("identity_stitching.enabled"),
+		AnonymousIdNode: viper.GetString("identity_stitching.anonymous_id_node"),
+		UserIdNode:      viper.GetString("identity_stitching.user_id_node"),
+		EventTypeNode:   viper.GetString("identity_stitching.event_type_node"),
+		IdentifyEvents:  viper.GetStringSlice("identity_stitching.identify_events"),
+		TTLDays:         viper.GetInt("identity_stitching.ttl_days"),
+	}
+	e.identity, err = users.NewIdentityService(metaStorage, identityConfiguration)
+	if err != nil {
+		return err
+	}
+	e.closeMe = append(e.closeMe, e.identity)
+
+	//embedded events are processed as server to server ones
+	e.eventHandler = handlers.NewEventHandler(e.destinations, events.NewApiPreprocessor(), e.eventsCache, e.inMemoryEventsCache, e.recognition, e.identity)
+
+	return nil
+}
+
+//MetaStorageViper return meta storage config (might be overridden with META_STORAGE_JSON os env)
+func MetaStorageViper() *viper.Viper {
+	metaStorageViper := viper.Sub("meta.storage")
+
+	metaStorageJsonConfig := viper.GetString("meta_storage_json")
+	if metaStorageJsonConfig != "" && metaStorageJsonConfig != "{}" {
+		envJsonViper, err := readJson(metaStorageJsonConfig)
+		if err != nil {
+			logging.Error("Error reading/parsing json config from META_STORAGE_JSON", err)
+		} else {
+			metaStorageViper = envJsonViper.Sub("meta_storage")
+		}
+	}
+
+	return metaStorageViper
+}
+
+//destinationsConfig return destinations config or source string (might be overridden with DESTINATIONS_JSON os env)
+func destinationsConfig() (*viper.Viper, string) {
+	destinationsViper := viper.Sub(destinationsKey)
+	destinationsStr := viper.GetString(destinationsKey)
+
+	destinationsJsonConfig := viper.GetString("destinations_json")
+	if destinationsJsonConfig != "" && destinationsJsonConfig != "{}" {
+		envJsonViper, err := readJson(destinationsJsonConfig)
+		if err != nil {
+			logging.Error("Error reading/parsing json config from DESTINATIONS_JSON", err)
+		} else {
+			destinationsViper = envJsonViper.Sub(destinationsKey)
+			destinationsStr = envJsonViper.GetString(destinationsKey)
+		}
+	}
+
+	return destinationsViper, destinationsStr
+}
+
+func readJson(config string) (*viper.Viper, error) {
+	envJsonViper := viper.New()
+	envJsonViper.SetConfigType("json")
+	if err := envJsonViper.ReadConfig(bytes.NewBufferString(config)); err != nil {
+		return nil, err
+	}
+
+	return envJsonViper, nil
+}
+
+//Ingest enrich, cache and multiplex event to all token destinations (the same as HTTP events API does)
+//token is a client_secret, server_secret or token id
+//return *counters.QuotaExceededError if token events quota has been exhausted
+func (e *Engine) Ingest(event events.Event, token string) error {
+	if event == nil {
+		return errors.New("Event can't be nil")
+	}
+
+	if appconfig.Instance.AuthorizationService.GetTokenId(token) == "" {
+		return fmt.Errorf("Unknown token: %s", token)
+	}
+
+	e.Lock()
+	closed := e.closed
+	e.Unlock()
+	if closed {
+		return errors.New("Engine has been closed")
+	}
+
+	return e.eventHandler.ProcessEvent(event, token, nil)
+}
+
+//SyncService return synchronization service (used as a monitor keeper)
+func (e *Engine) SyncService() synchronization.Service {
+	return e.syncService
+}
+
+func (e *Engine) MetaStorage() meta.Storage {
+	return e.metaStorage
+}
+
+func (e *Engine) EventsCache() *caching.EventsCache {
+	return e.eventsCache
+}
+
+func (e *Engine) InMemoryEventsCache() *events.Cache {
+	return e.inMemoryEventsCache
+}
+
+func (e *Engine) Destinations() *destinations.Service {
+	return e.destinations
+}
+
+func (e *Engine) RecognitionService() *users.RecognitionService {
+	return e.recognition
+}
+
+func (e *Engine) IdentityService() *users.IdentityService {
+	return e.identity
+}
+
+//EventHandler return events processor (e.g. for MQTT listener)
+func (e *Engine) EventHandler() *handlers.EventHandler {
+	return e.eventHandler
+}
+
+//Close all components in reverse creation order: meta storage is closed last
+func (e *Engine) Close() (multiErr error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	for i := len(e.closeMe) - 1; i >= 0; i-- {
+		if err := e.closeMe[i].Close(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
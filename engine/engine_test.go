@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"context"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIngest(t *testing.T) {
+	logPath, err := ioutil.TempDir("", "engine_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(logPath)
+
+	config := viper.New()
+	config.Set("log.path", logPath)
+	config.Set("server.auth", `{"tokens":[{"id":"id1","server_secret":"s2stoken"}]}`)
+	config.Set("server.statistics.uniques.enabled", false)
+
+	engine, err := New(context.Background(), config)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Ingest(events.Event{"event_type": "test"}, "s2stoken"))
+	require.EqualError(t, engine.Ingest(events.Event{"event_type": "test"}, "unknown"), "Unknown token: unknown")
+	require.Error(t, engine.Ingest(nil, "s2stoken"))
+
+	require.NoError(t, engine.Close())
+	require.EqualError(t, engine.Ingest(events.Event{"event_type": "test"}, "s2stoken"), "Engine has been closed")
+}
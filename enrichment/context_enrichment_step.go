@@ -13,22 +13,29 @@ const (
 	ipKey       = "source_ip"
 )
 
-//Enrich payload with ip, user-agent, token, event id and _timestamp
+//Enrich payload with ip, user-agent, DNT/GPC signals, token, event id and _timestamp
 func ContextEnrichmentStep(payload map[string]interface{}, token string, r *http.Request, preprocessor events.Preprocessor) {
-	//1. source IP
-	ip := extractIp(r)
-	if ip != "" {
-		payload[ipKey] = ip
+	//1. source IP (request is nil for events which weren't received via HTTP)
+	if r != nil {
+		ip := extractIp(r)
+		if ip != "" {
+			payload[ipKey] = ip
+		}
 	}
 
 	//2. preprocess
 	preprocessor.Preprocess(payload, r)
 
-	//3. identifier
+	//3. privacy signals (applied per destination by schema.ConsentPolicy)
+	if r != nil {
+		events.EnrichWithConsentSignals(payload, r.Header.Get("DNT") == "1", r.Header.Get("Sec-GPC") == "1")
+	}
+
+	//4. identifier
 	//put and get eventn_ctx_event_id if not set (e.g. It is used for ClickHouse)
 	events.EnrichWithEventId(payload, uuid.New())
 
-	//4. timestamp & api key
+	//5. timestamp & api key
 	payload[apiTokenKey] = token
 	payload[timestamp.Key] = timestamp.NowUTC()
 }
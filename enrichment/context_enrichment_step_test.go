@@ -48,6 +48,17 @@ func TestWithJsPreprocess(t *testing.T) {
 			},
 			map[string]string{"X-Real-IP": "10.10.10.10", "User-Agent": "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/77.0.3865.90 Safari/537.36"},
 		},
+		{
+			"DNT and GPC signals",
+			map[string]interface{}{"consent": map[string]interface{}{"analytics": true}},
+			map[string]interface{}{
+				"_timestamp": "2020-06-16T23:00:00.000000Z",
+				"api_key":    "token",
+				"consent":    map[string]interface{}{"analytics": true, "dnt": true, "gpc": true},
+				"eventn_ctx": map[string]interface{}{"event_id": "mockeduuid"},
+			},
+			map[string]string{"DNT": "1", "Sec-GPC": "1"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
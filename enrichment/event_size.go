@@ -0,0 +1,149 @@
+package enrichment
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/maputils"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/timestamp"
+	"sort"
+	"strings"
+)
+
+const (
+	//TooLargeReason is a fallback error prefix of oversized events
+	TooLargeReason = "too_large"
+
+	defaultTruncatedFlagField = "/eventn_ctx/truncated_fields"
+)
+
+//protectedPaths are system fields which are never dropped while truncation
+var protectedPaths = map[string]bool{
+	"/" + apiTokenKey:         true,
+	"/" + timestamp.Key:       true,
+	"/src":                    true,
+	"/event_type":             true,
+	"/eventn_ctx/event_id":    true,
+	defaultTruncatedFlagField: true,
+}
+
+//EventTooLargeError is returned when event must be routed to fallback: fallback policy is configured
+//or event is still oversized after dropping all not system fields
+type EventTooLargeError struct {
+	Size     int
+	MaxBytes int
+}
+
+func (etle *EventTooLargeError) Error() string {
+	return fmt.Sprintf("%s: event size %d bytes exceeds max_bytes %d", TooLargeReason, etle.Size, etle.MaxBytes)
+}
+
+//leafField is a not object field with the estimated serialized size
+type leafField struct {
+	path string
+	size int
+}
+
+//EventSizeStep limits serialized event size according to token configuration
+//return *EventTooLargeError if event must be routed to fallback
+func EventSizeStep(payload map[string]interface{}, tokenId string) error {
+	config := appconfig.Instance.AuthorizationService.GetEventSizeConfig(tokenId)
+	return limitEventSize(payload, tokenId, config)
+}
+
+//limitEventSize drop the largest fields (truncate policy) until event fits into max_bytes
+//dropped fields paths are written into flag field. Do nothing if config is nil or max_bytes isn't set
+func limitEventSize(payload map[string]interface{}, tokenId string, config *authorization.EventSizeConfig) error {
+	if config == nil || config.MaxBytes <= 0 {
+		return nil
+	}
+
+	size := serializedSize(payload)
+	if size <= config.MaxBytes {
+		return nil
+	}
+
+	policy := config.Policy
+	if policy == "" {
+		policy = authorization.EventSizeTruncatePolicy
+	}
+	metrics.OversizedEvent(tokenId, policy)
+
+	if policy == authorization.EventSizeFallbackPolicy {
+		return &EventTooLargeError{Size: size, MaxBytes: config.MaxBytes}
+	}
+
+	flagField := config.FlagField
+	if flagField == "" {
+		flagField = defaultTruncatedFlagField
+	}
+	flagPath := jsonutils.NewJsonPath(flagField)
+
+	//truncation is applied to the copy: the original event is routed to fallback if it can't be truncated
+	truncated := maputils.CopyMap(payload)
+	var leaves []*leafField
+	collectLeaves(truncated, "", &leaves)
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].size == leaves[j].size {
+			return leaves[i].path < leaves[j].path
+		}
+		return leaves[i].size > leaves[j].size
+	})
+
+	var dropped []string
+	estimated := size
+	for _, leaf := range leaves {
+		if protectedPaths[leaf.path] || leaf.path == flagField {
+			continue
+		}
+
+		jsonutils.NewJsonPath(leaf.path).GetAndRemove(truncated)
+		dropped = append(dropped, leaf.path)
+		estimated -= leaf.size
+		if estimated > config.MaxBytes {
+			continue
+		}
+
+		//estimation doesn't take into account commas, emptied objects and the flag
+		flagPath.Set(truncated, strings.Join(dropped, ","))
+		estimated = serializedSize(truncated)
+		if estimated <= config.MaxBytes {
+			for key := range payload {
+				delete(payload, key)
+			}
+			for key, value := range truncated {
+				payload[key] = value
+			}
+			logging.Debugf("Oversized event [%d bytes] of token [%s] has been truncated. Dropped fields: %v", size, tokenId, dropped)
+			return nil
+		}
+	}
+
+	return &EventTooLargeError{Size: size, MaxBytes: config.MaxBytes}
+}
+
+//collectLeaves put all not object fields with "/" delimited paths and estimated "key":value sizes into leaves
+func collectLeaves(object map[string]interface{}, prefix string, leaves *[]*leafField) {
+	for key, value := range object {
+		path := prefix + "/" + key
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			collectLeaves(nested, path, leaves)
+			continue
+		}
+
+		*leaves = append(*leaves, &leafField{path: path, size: len(key) + 3 + serializedSize(value)})
+	}
+}
+
+func serializedSize(value interface{}) int {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+
+	return len(b)
+}
@@ -0,0 +1,60 @@
+package enrichment
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestLimitEventSize(t *testing.T) {
+	newEvent := func() map[string]interface{} {
+		return map[string]interface{}{
+			"api_key":    "token1",
+			"event_type": "pageview",
+			"eventn_ctx": map[string]interface{}{"event_id": "e1", "url": "https://site.com/" + strings.Repeat("p", 200)},
+			"html":       strings.Repeat("h", 1000),
+			"title":      "Home",
+		}
+	}
+
+	//not configured and fitting events aren't changed
+	event := newEvent()
+	require.NoError(t, limitEventSize(event, "token1", nil))
+	require.NoError(t, limitEventSize(event, "token1", &authorization.EventSizeConfig{MaxBytes: 2000}))
+	require.Equal(t, newEvent(), event)
+
+	//the largest field is dropped
+	event = newEvent()
+	require.NoError(t, limitEventSize(event, "token1", &authorization.EventSizeConfig{MaxBytes: 500}))
+	require.Equal(t, map[string]interface{}{
+		"api_key":    "token1",
+		"event_type": "pageview",
+		"eventn_ctx": map[string]interface{}{"event_id": "e1", "url": "https://site.com/" + strings.Repeat("p", 200), "truncated_fields": "/html"},
+		"title":      "Home",
+	}, event)
+
+	//nested fields are dropped as well
+	event = newEvent()
+	require.NoError(t, limitEventSize(event, "token1", &authorization.EventSizeConfig{MaxBytes: 150, FlagField: "/truncated"}))
+	require.Equal(t, map[string]interface{}{
+		"api_key":    "token1",
+		"event_type": "pageview",
+		"eventn_ctx": map[string]interface{}{"event_id": "e1"},
+		"title":      "Home",
+		"truncated":  "/html,/eventn_ctx/url",
+	}, event)
+
+	//system fields aren't dropped: the original event is routed to fallback
+	event = newEvent()
+	err := limitEventSize(event, "token1", &authorization.EventSizeConfig{MaxBytes: 50})
+	require.IsType(t, &EventTooLargeError{}, err)
+	require.True(t, strings.HasPrefix(err.Error(), "too_large: event size "))
+	require.Equal(t, newEvent(), event)
+
+	//fallback policy
+	event = newEvent()
+	err = limitEventSize(event, "token1", &authorization.EventSizeConfig{MaxBytes: 500, Policy: authorization.EventSizeFallbackPolicy})
+	require.IsType(t, &EventTooLargeError{}, err)
+	require.Equal(t, newEvent(), event)
+}
@@ -0,0 +1,142 @@
+package enrichment
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/metrics"
+	"hash/fnv"
+	"strconv"
+)
+
+const (
+	allEventTypes = "*"
+
+	defaultSamplingEventTypeField   = "/event_type"
+	defaultSamplingAnonymousIdField = "/eventn_ctx/user/anonymous_id"
+	defaultSamplingRateField        = "/eventn_ctx/sample_rate"
+
+	samplingBuckets = 10000
+)
+
+//SamplingStep samples event according to token configuration
+//return false if event should be dropped
+func SamplingStep(payload map[string]interface{}, tokenId string) bool {
+	config := appconfig.Instance.AuthorizationService.GetSamplingConfig(tokenId)
+	return sample(payload, tokenId, config)
+}
+
+//sample find rule by event type (or '*' rule) and keep rate part of events by anonymous id hash
+//(or by event id if anonymous id is absent). Kept events are stamped with the rate for later extrapolation
+//return true if config is nil or there is no rule for the event type
+func sample(payload map[string]interface{}, tokenId string, config *authorization.SamplingConfig) bool {
+	if config == nil || len(config.Rules) == 0 {
+		return true
+	}
+
+	eventTypeField := config.EventTypeField
+	if eventTypeField == "" {
+		eventTypeField = defaultSamplingEventTypeField
+	}
+	var eventType string
+	if value, ok := jsonutils.NewJsonPath(eventTypeField).Get(payload); ok {
+		eventType = fmt.Sprint(value)
+	}
+
+	rule := findSamplingRule(config.Rules, eventType)
+	if rule == nil {
+		return true
+	}
+
+	anonymousIdField := config.AnonymousIdField
+	if anonymousIdField == "" {
+		anonymousIdField = defaultSamplingAnonymousIdField
+	}
+	key := events.ExtractEventId(payload)
+	if value, ok := jsonutils.NewJsonPath(anonymousIdField).Get(payload); ok && fmt.Sprint(value) != "" {
+		key = fmt.Sprint(value)
+	}
+
+	if key != "" && !inSample(key, rule.Rate) {
+		metrics.SampledOutEvent(tokenId, eventType)
+		return false
+	}
+
+	rateField := config.RateField
+	if rateField == "" {
+		rateField = defaultSamplingRateField
+	}
+	jsonutils.NewJsonPath(rateField).Set(payload, rule.Rate)
+
+	return true
+}
+
+//IsSampled return true if token has sampling rules
+func IsSampled(tokenId string) bool {
+	config := getSamplingConfig(tokenId)
+	return config != nil && len(config.Rules) > 0
+}
+
+//SampleWeight return estimated count of original events which the kept event represents: 1 / sample rate
+//return 1 if token events aren't sampled or the event doesn't have the sample rate
+func SampleWeight(payload map[string]interface{}, tokenId string) float64 {
+	return sampleWeight(payload, getSamplingConfig(tokenId))
+}
+
+func sampleWeight(payload map[string]interface{}, config *authorization.SamplingConfig) float64 {
+	if config == nil || len(config.Rules) == 0 {
+		return 1
+	}
+
+	rateField := config.RateField
+	if rateField == "" {
+		rateField = defaultSamplingRateField
+	}
+	value, ok := jsonutils.NewJsonPath(rateField).Get(payload)
+	if !ok {
+		return 1
+	}
+
+	rate, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+	if err != nil || rate <= 0 || rate > 1 {
+		return 1
+	}
+
+	return 1 / rate
+}
+
+//getSamplingConfig return nil if authorization isn't initialized (e.g. events are processed outside of the server)
+func getSamplingConfig(tokenId string) *authorization.SamplingConfig {
+	if appconfig.Instance == nil || appconfig.Instance.AuthorizationService == nil {
+		return nil
+	}
+
+	return appconfig.Instance.AuthorizationService.GetSamplingConfig(tokenId)
+}
+
+//findSamplingRule return rule with the same event type or '*' rule
+func findSamplingRule(rules []*authorization.SamplingRule, eventType string) *authorization.SamplingRule {
+	var defaultRule *authorization.SamplingRule
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		if rule.EventType == eventType {
+			return rule
+		}
+		if rule.EventType == allEventTypes {
+			defaultRule = rule
+		}
+	}
+
+	return defaultRule
+}
+
+//inSample return true if key hash bucket is less than rate part of all buckets
+func inSample(key string, rate float64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32()%samplingBuckets) < rate*samplingBuckets
+}
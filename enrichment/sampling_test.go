@@ -0,0 +1,67 @@
+package enrichment
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	config := &authorization.SamplingConfig{Rules: []*authorization.SamplingRule{
+		{EventType: "heartbeat", Rate: 0.1},
+		{EventType: "debug", Rate: 0},
+	}}
+
+	//no rule
+	pageview := map[string]interface{}{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	require.True(t, sample(pageview, "token1", config))
+	require.Equal(t, map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}, pageview["eventn_ctx"])
+
+	//rate 0
+	debug := map[string]interface{}{"event_type": "debug", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	require.False(t, sample(debug, "token1", config))
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		anonymousId := fmt.Sprintf("anon%d", i)
+		heartbeat := func() map[string]interface{} {
+			return map[string]interface{}{"event_type": "heartbeat", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": anonymousId}}}
+		}
+
+		first := heartbeat()
+		result := sample(first, "token1", config)
+		require.Equal(t, result, sample(heartbeat(), "token1", config), "sampling must be deterministic by anonymous id")
+		if result {
+			kept++
+			require.Equal(t, 0.1, first["eventn_ctx"].(map[string]interface{})["sample_rate"])
+		}
+	}
+	require.InDelta(t, 1000, kept, 150)
+}
+
+func TestSampleDefaultRule(t *testing.T) {
+	config := &authorization.SamplingConfig{RateField: "/rate", Rules: []*authorization.SamplingRule{{EventType: "*", Rate: 1}}}
+
+	event := map[string]interface{}{"event_type": "click", "eventn_ctx_event_id": "1"}
+	require.True(t, sample(event, "token1", config))
+	require.Equal(t, 1.0, event["rate"])
+
+	require.True(t, sample(map[string]interface{}{"event_type": "click"}, "token1", nil))
+}
+
+func TestSampleWeight(t *testing.T) {
+	config := &authorization.SamplingConfig{Rules: []*authorization.SamplingRule{{EventType: "heartbeat", Rate: 0.25}}}
+
+	require.Equal(t, float64(1), sampleWeight(map[string]interface{}{"eventn_ctx": map[string]interface{}{"sample_rate": 0.25}}, nil))
+	require.Equal(t, float64(1), sampleWeight(map[string]interface{}{"event_type": "pageview"}, config))
+	require.Equal(t, float64(4), sampleWeight(map[string]interface{}{"eventn_ctx": map[string]interface{}{"sample_rate": 0.25}}, config))
+	require.Equal(t, float64(4), sampleWeight(map[string]interface{}{"eventn_ctx": map[string]interface{}{"sample_rate": "0.25"}}, config))
+	require.Equal(t, float64(1), sampleWeight(map[string]interface{}{"eventn_ctx": map[string]interface{}{"sample_rate": 0}}, config))
+}
+
+func TestSamplingConfigValidate(t *testing.T) {
+	require.NoError(t, (&authorization.SamplingConfig{Rules: []*authorization.SamplingRule{{EventType: "*", Rate: 0.5}}}).Validate())
+	require.Error(t, (&authorization.SamplingConfig{Rules: []*authorization.SamplingRule{{EventType: "heartbeat", Rate: 2}}}).Validate())
+	require.Error(t, (&authorization.SamplingConfig{Rules: []*authorization.SamplingRule{{Rate: 0.5}}}).Validate())
+}
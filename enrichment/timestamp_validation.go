@@ -0,0 +1,83 @@
+package enrichment
+
+import (
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/timestamp"
+	"time"
+)
+
+const (
+	ClampAction = authorization.TimestampsClampAction
+	FlagAction  = authorization.TimestampsFlagAction
+
+	futureReason = "future"
+	pastReason   = "past"
+
+	defaultTimestampField     = "/eventn_ctx/utc_time"
+	defaultTimestampFlagField = "/eventn_ctx/timestamp_flag"
+)
+
+//TimestampValidationStep validates client event timestamp according to token configuration
+func TimestampValidationStep(payload map[string]interface{}, tokenId string) {
+	config := appconfig.Instance.AuthorizationService.GetTimestampsConfig(tokenId)
+	validateTimestamp(payload, tokenId, config, time.Now().UTC())
+}
+
+//validateTimestamp flag event with future or too old timestamp and clamp timestamp value
+//into [now - max_past_days, now] if clamp action is configured
+//do nothing if config is nil or timestamp isn't a valid RFC3339 string
+func validateTimestamp(payload map[string]interface{}, tokenId string, config *authorization.TimestampsConfig, now time.Time) {
+	if config == nil || (config.MaxFutureSec <= 0 && config.MaxPastDays <= 0) {
+		return
+	}
+
+	field := config.Field
+	if field == "" {
+		field = defaultTimestampField
+	}
+	fieldPath := jsonutils.NewJsonPath(field)
+
+	value, ok := fieldPath.Get(payload)
+	if !ok {
+		return
+	}
+	strValue, ok := value.(string)
+	if !ok {
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, strValue)
+	if err != nil {
+		return
+	}
+
+	var reason string
+	var clamped time.Time
+	if config.MaxFutureSec > 0 && t.After(now.Add(time.Duration(config.MaxFutureSec)*time.Second)) {
+		reason = futureReason
+		clamped = now
+	} else if config.MaxPastDays > 0 && t.Before(now.AddDate(0, 0, -config.MaxPastDays)) {
+		reason = pastReason
+		clamped = now.AddDate(0, 0, -config.MaxPastDays)
+	} else {
+		return
+	}
+
+	action := config.Action
+	if action == "" {
+		action = FlagAction
+	}
+	metrics.InvalidTimestamp(tokenId, reason, action)
+
+	flagField := config.FlagField
+	if flagField == "" {
+		flagField = defaultTimestampFlagField
+	}
+	jsonutils.NewJsonPath(flagField).Set(payload, reason)
+
+	if action == ClampAction {
+		fieldPath.Set(payload, timestamp.ToISOFormat(clamped))
+	}
+}
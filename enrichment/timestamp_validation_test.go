@@ -0,0 +1,61 @@
+package enrichment
+
+import (
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/test"
+	"testing"
+	"time"
+)
+
+func TestValidateTimestamp(t *testing.T) {
+	now := time.Date(2020, 12, 24, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		config   *authorization.TimestampsConfig
+		input    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			"Nil config",
+			nil,
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2021-12-24T12:00:00Z"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2021-12-24T12:00:00Z"}},
+		},
+		{
+			"Valid timestamp",
+			&authorization.TimestampsConfig{MaxFutureSec: 60, MaxPastDays: 30, Action: ClampAction},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T11:59:00Z"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T11:59:00Z"}},
+		},
+		{
+			"Future timestamp flag",
+			&authorization.TimestampsConfig{MaxFutureSec: 60},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T12:05:00.123Z"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T12:05:00.123Z", "timestamp_flag": "future"}},
+		},
+		{
+			"Future timestamp clamp",
+			&authorization.TimestampsConfig{MaxFutureSec: 60, Action: ClampAction},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T12:05:00Z"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "2020-12-24T12:00:00.000000Z", "timestamp_flag": "future"}},
+		},
+		{
+			"Old timestamp clamp with custom fields",
+			&authorization.TimestampsConfig{Field: "/ts", MaxPastDays: 1, Action: ClampAction, FlagField: "/skew"},
+			map[string]interface{}{"ts": "2020-01-01T00:00:00Z"},
+			map[string]interface{}{"ts": "2020-12-23T12:00:00.000000Z", "skew": "past"},
+		},
+		{
+			"Malformed timestamp",
+			&authorization.TimestampsConfig{MaxFutureSec: 60, Action: ClampAction},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "abc"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"utc_time": "abc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validateTimestamp(tt.input, "token1", tt.config, now)
+			test.ObjectsEqual(t, tt.expected, tt.input, "Events aren't equal")
+		})
+	}
+}
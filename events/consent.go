@@ -0,0 +1,34 @@
+package events
+
+const (
+	//ConsentKey is an event consent object: category -> granted (e.g. {"analytics": true, "marketing": false})
+	ConsentKey = "consent"
+	//DntKey and GpcKey are set into the consent object if request has DNT or Sec-GPC header
+	DntKey = "dnt"
+	GpcKey = "gpc"
+)
+
+//EnrichWithConsentSignals put DNT/GPC signals into the event consent object (creates it if it doesn't exist)
+//signals aren't put if consent isn't an object
+func EnrichWithConsentSignals(object map[string]interface{}, dnt, gpc bool) {
+	if !dnt && !gpc {
+		return
+	}
+
+	consentObject, ok := object[ConsentKey]
+	if !ok {
+		consentObject = map[string]interface{}{}
+		object[ConsentKey] = consentObject
+	}
+
+	consent, ok := consentObject.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if dnt {
+		consent[DntKey] = true
+	}
+	if gpc {
+		consent[GpcKey] = true
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/joncrlsn/dque"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,17 +28,37 @@ func QueuedFactBuilder() interface{} {
 	return &QueuedEvent{}
 }
 
+//PersistentQueue is a destination events queue with optional priority lane (separate queue)
+//events matched by Priority are put into the priority lane (retries as well)
+//dequeued events are counted as in-flight until Processed is called
 type PersistentQueue struct {
-	queue *dque.DQue
+	queue         *dque.DQue
+	priorityQueue *dque.DQue
+	priority      *Priority
+
+	inFlight int64
 }
 
-func NewPersistentQueue(queueName, fallbackDir string) (*PersistentQueue, error) {
+//NewPersistentQueue return queue with priority lane if priority isn't nil
+func NewPersistentQueue(queueName, fallbackDir string, priority *Priority) (*PersistentQueue, error) {
 	queue, err := dque.NewOrOpen(queueName, fallbackDir, eventsPerPersistedFile, QueuedFactBuilder)
 	if err != nil {
 		return nil, fmt.Errorf("Error opening/creating event queue [%s] in dir [%s]: %v", queueName, fallbackDir, err)
 	}
 
-	return &PersistentQueue{queue: queue}, nil
+	pq := &PersistentQueue{queue: queue}
+	if priority != nil {
+		priorityQueueName := queueName + priorityQueueSuffix
+		priorityQueue, err := dque.NewOrOpen(priorityQueueName, fallbackDir, eventsPerPersistedFile, QueuedFactBuilder)
+		if err != nil {
+			queue.Close()
+			return nil, fmt.Errorf("Error opening/creating event queue [%s] in dir [%s]: %v", priorityQueueName, fallbackDir, err)
+		}
+		pq.priorityQueue = priorityQueue
+		pq.priority = priority
+	}
+
+	return pq, nil
 }
 
 func (pq *PersistentQueue) Consume(f map[string]interface{}, tokenId string) {
@@ -50,14 +72,49 @@ func (pq *PersistentQueue) ConsumeTimed(f map[string]interface{}, t time.Time, t
 		return
 	}
 
-	if err := pq.queue.Enqueue(&QueuedEvent{FactBytes: factBytes, DequeuedTime: t, TokenId: tokenId}); err != nil {
+	queue := pq.queue
+	if pq.priorityQueue != nil && pq.priority.Match(f, tokenId) {
+		queue = pq.priorityQueue
+	}
+
+	if err := queue.Enqueue(&QueuedEvent{FactBytes: factBytes, DequeuedTime: t, TokenId: tokenId}); err != nil {
 		logSkippedEvent(f, fmt.Errorf("Error putting event event bytes to the persistent queue: %v", err))
 		return
 	}
 }
 
+//DequeueBlock return event from the regular lane. Block until an event is available
 func (pq *PersistentQueue) DequeueBlock() (Event, time.Time, string, error) {
-	iface, err := pq.queue.DequeueBlock()
+	return pq.dequeueBlock(pq.queue)
+}
+
+//DequeuePriorityBlock return event from the priority lane. Block until an event is available
+func (pq *PersistentQueue) DequeuePriorityBlock() (Event, time.Time, string, error) {
+	if pq.priorityQueue == nil {
+		return nil, time.Time{}, "", errors.New("Priority lane isn't configured")
+	}
+
+	return pq.dequeueBlock(pq.priorityQueue)
+}
+
+//Priority return priority lane configuration or nil if the lane isn't configured
+func (pq *PersistentQueue) Priority() *Priority {
+	return pq.priority
+}
+
+//Processed mark dequeued event as processed (inserted, put back to the queue or skipped)
+func (pq *PersistentQueue) Processed() {
+	atomic.AddInt64(&pq.inFlight, -1)
+}
+
+//InFlight return number of dequeued events which haven't been processed yet
+func (pq *PersistentQueue) InFlight() int {
+	return int(atomic.LoadInt64(&pq.inFlight))
+}
+
+//dequeueBlock return event from the queue and count it as in-flight
+func (pq *PersistentQueue) dequeueBlock(queue *dque.DQue) (Event, time.Time, string, error) {
+	iface, err := queue.DequeueBlock()
 	if err != nil {
 		if err == dque.ErrQueueClosed {
 			err = ErrQueueClosed
@@ -75,11 +132,36 @@ func (pq *PersistentQueue) DequeueBlock() (Event, time.Time, string, error) {
 		return nil, time.Time{}, "", fmt.Errorf("Error unmarshalling events.Event from bytes: %v", err)
 	}
 
+	atomic.AddInt64(&pq.inFlight, 1)
 	return fact, wrappedFact.DequeuedTime, wrappedFact.TokenId, nil
 }
 
-func (pq *PersistentQueue) Close() error {
-	return pq.queue.Close()
+//Size return approximate number of events in the queue (both lanes)
+func (pq *PersistentQueue) Size() int {
+	return pq.queue.SizeUnsafe() + pq.PrioritySize()
+}
+
+//PrioritySize return approximate number of events in the priority lane
+func (pq *PersistentQueue) PrioritySize() int {
+	if pq.priorityQueue == nil {
+		return 0
+	}
+
+	return pq.priorityQueue.SizeUnsafe()
+}
+
+func (pq *PersistentQueue) Close() (multiErr error) {
+	if pq.priorityQueue != nil {
+		if err := pq.priorityQueue.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing priority lane: %v", err))
+		}
+	}
+
+	if err := pq.queue.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	return
 }
 
 func logSkippedEvent(event Event, err error) {
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/jsonutils"
+)
+
+const (
+	defaultPriorityEventTypeField = "/event_type"
+	defaultPriorityStreamWorkers  = 1
+	priorityQueueSuffix           = ".priority"
+)
+
+//PriorityConfig is a configuration of the priority lane: events of Tokens (ids or client/server secrets) or with EventTypes
+//are processed ahead of other events: in stream mode by StreamWorkers dedicated workers of every destination
+//from a separate queue, in batch mode log files with such events are uploaded first
+type PriorityConfig struct {
+	Tokens         []string `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	EventTypes     []string `mapstructure:"event_types" json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	EventTypeField string   `mapstructure:"event_type_field" json:"event_type_field,omitempty" yaml:"event_type_field,omitempty"`
+	StreamWorkers  int      `mapstructure:"stream_workers" json:"stream_workers,omitempty" yaml:"stream_workers,omitempty"`
+}
+
+//Priority decides if an event goes through the priority lane
+type Priority struct {
+	tokens        map[string]bool
+	eventTypes    map[string]bool
+	eventTypePath *jsonutils.JsonPath
+	streamWorkers int
+}
+
+//NewPriority return nil if config is nil or doesn't have tokens and event types (priority lane is disabled)
+//tokenIds are token ids of configured tokens secrets
+func NewPriority(config *PriorityConfig, tokenIds []string) *Priority {
+	if config == nil || (len(config.Tokens) == 0 && len(config.EventTypes) == 0) {
+		return nil
+	}
+
+	tokens := map[string]bool{}
+	for _, token := range append(config.Tokens, tokenIds...) {
+		tokens[token] = true
+	}
+	eventTypes := map[string]bool{}
+	for _, eventType := range config.EventTypes {
+		eventTypes[eventType] = true
+	}
+	eventTypeField := config.EventTypeField
+	if eventTypeField == "" {
+		eventTypeField = defaultPriorityEventTypeField
+	}
+	streamWorkers := config.StreamWorkers
+	if streamWorkers <= 0 {
+		streamWorkers = defaultPriorityStreamWorkers
+	}
+
+	return &Priority{
+		tokens:        tokens,
+		eventTypes:    eventTypes,
+		eventTypePath: jsonutils.NewJsonPath(eventTypeField),
+		streamWorkers: streamWorkers,
+	}
+}
+
+//Match return true if the event is from priority token or has priority event type
+func (p *Priority) Match(event Event, tokenId string) bool {
+	if p == nil {
+		return false
+	}
+
+	if p.tokens[tokenId] {
+		return true
+	}
+
+	eventType, ok := p.eventTypePath.Get(event)
+	if !ok {
+		return false
+	}
+	eventTypeStr, ok := eventType.(string)
+	return ok && p.eventTypes[eventTypeStr]
+}
+
+//MatchPayload return true if the token is priority or any of payload lines (JSON events divided with \n) matches
+func (p *Priority) MatchPayload(payload []byte, tokenId string) bool {
+	if p == nil {
+		return false
+	}
+
+	if p.tokens[tokenId] {
+		return true
+	}
+	if len(p.eventTypes) == 0 {
+		return false
+	}
+
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		event := map[string]interface{}{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+		if p.Match(event, tokenId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//StreamWorkers return count of dedicated priority lane workers per stream destination
+func (p *Priority) StreamWorkers() int {
+	return p.streamWorkers
+}
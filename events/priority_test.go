@@ -0,0 +1,71 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPriorityMatch(t *testing.T) {
+	require.Nil(t, NewPriority(nil, nil))
+	require.Nil(t, NewPriority(&PriorityConfig{StreamWorkers: 2}, nil))
+
+	priority := NewPriority(&PriorityConfig{Tokens: []string{"client_secret"}, EventTypes: []string{"purchase", "signup"}}, []string{"token1"})
+	require.Equal(t, 1, priority.StreamWorkers())
+
+	require.True(t, priority.Match(Event{"event_type": "pageview"}, "token1"))
+	require.True(t, priority.Match(Event{"event_type": "pageview"}, "client_secret"))
+	require.True(t, priority.Match(Event{"event_type": "purchase"}, "token2"))
+	require.False(t, priority.Match(Event{"event_type": "pageview"}, "token2"))
+	require.False(t, priority.Match(Event{"event_type": 1}, "token2"))
+
+	require.True(t, priority.MatchPayload([]byte(`{"event_type":"pageview"}`), "token1"))
+	require.True(t, priority.MatchPayload([]byte("{\"event_type\":\"pageview\"}\nmalformed\n{\"event_type\":\"signup\"}\n"), "token2"))
+	require.False(t, priority.MatchPayload([]byte("{\"event_type\":\"pageview\"}\n"), "token2"))
+
+	custom := NewPriority(&PriorityConfig{EventTypes: []string{"purchase"}, EventTypeField: "/eventn_ctx/type", StreamWorkers: 3}, nil)
+	require.True(t, custom.Match(Event{"eventn_ctx": map[string]interface{}{"type": "purchase"}}, "token1"))
+	require.False(t, custom.Match(Event{"event_type": "purchase"}, "token1"))
+	require.Equal(t, 3, custom.StreamWorkers())
+}
+
+func TestPersistentQueuePriorityLane(t *testing.T) {
+	dir, err := ioutil.TempDir("", "priority_queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	queue, err := NewPersistentQueue("queue.dst=test", dir, NewPriority(&PriorityConfig{EventTypes: []string{"purchase"}}, nil))
+	require.NoError(t, err)
+	defer queue.Close()
+
+	queue.Consume(Event{"event_type": "pageview", "eventn_ctx_event_id": "1"}, "token1")
+	queue.Consume(Event{"event_type": "purchase", "eventn_ctx_event_id": "2"}, "token1")
+	queue.ConsumeTimed(Event{"event_type": "purchase", "eventn_ctx_event_id": "3"}, time.Now(), "token1")
+	require.Equal(t, 3, queue.Size())
+	require.Equal(t, 2, queue.PrioritySize())
+
+	event, _, tokenId, err := queue.DequeuePriorityBlock()
+	require.NoError(t, err)
+	require.Equal(t, "purchase", event["event_type"])
+	require.Equal(t, "token1", tokenId)
+
+	event, _, _, err = queue.DequeueBlock()
+	require.NoError(t, err)
+	require.Equal(t, "pageview", event["event_type"])
+	//dequeued events are in-flight until they are processed
+	require.Equal(t, 1, queue.Size())
+	require.Equal(t, 2, queue.InFlight())
+	queue.Processed()
+	require.Equal(t, 1, queue.InFlight())
+
+	withoutLane, err := NewPersistentQueue("queue.dst=regular", dir, nil)
+	require.NoError(t, err)
+	defer withoutLane.Close()
+	withoutLane.Consume(Event{"event_type": "purchase"}, "token1")
+	require.Equal(t, 1, withoutLane.Size())
+	require.Equal(t, 0, withoutLane.PrioritySize())
+	_, _, _, err = withoutLane.DequeuePriorityBlock()
+	require.Error(t, err)
+}
@@ -0,0 +1,30 @@
+package events
+
+import (
+	"github.com/jitsucom/eventnative/jsonutils"
+	"net/http"
+)
+
+//SnowplowPreprocessor preprocess events which are converted from Snowplow tracker payloads
+type SnowplowPreprocessor struct {
+	userAgentJsonPath *jsonutils.JsonPath
+}
+
+func NewSnowplowPreprocessor() Preprocessor {
+	return &SnowplowPreprocessor{userAgentJsonPath: jsonutils.NewJsonPath(EventnKey + "/user_agent")}
+}
+
+//Preprocess put src = snowplow and set user-agent from request header if tracker hasn't sent it (ua parameter)
+func (sp *SnowplowPreprocessor) Preprocess(event Event, r *http.Request) {
+	event["src"] = "snowplow"
+
+	if r == nil {
+		return
+	}
+	if _, ok := sp.userAgentJsonPath.Get(event); ok {
+		return
+	}
+	if clientUserAgent := r.Header.Get("user-agent"); clientUserAgent != "" {
+		sp.userAgentJsonPath.Set(event, clientUserAgent)
+	}
+}
@@ -0,0 +1,214 @@
+package fallback
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//fallback files are rotated by lumberjack: failed.dst=<destination id>-2006-01-02T15-04-05.000.log (UTC)
+const fileTimeLayout = "2006-01-02T15-04-05.000"
+
+var fileTimeExtractRegexp = regexp.MustCompile(`-(\d\d\d\d-\d\d-\d\dT\d\d-\d\d-\d\d\.\d\d\d)\.log$`)
+
+//ReplayFilter selects fallback files (by name, source destinations and rotation time in [Start, End])
+//and events (by error reason substring). Empty fields match all
+type ReplayFilter struct {
+	FileName       string
+	DestinationIds map[string]bool
+	Start          time.Time
+	End            time.Time
+	ErrorContains  string
+}
+
+//ReplayResult is a result (or dry run estimation) of one fallback file replay
+type ReplayResult struct {
+	FileName            string `json:"file_name"`
+	DestinationId       string `json:"destination_id"`
+	TargetDestinationId string `json:"target_destination_id"`
+	Events              int    `json:"events"`
+	Skipped             int    `json:"skipped"`
+	Error               string `json:"error,omitempty"`
+}
+
+//ReplayFiltered replay events which match the filter from all matched fallback files into their destinations
+//(or into targetDestinationId if it isn't empty). Files are archived if all events were replayed, otherwise
+//not matched events are kept in the file. If dryRun is true - only matched events counts are returned
+//replay_finished lifecycle event is sent if at least one file has been replayed
+func (s *Service) ReplayFiltered(filter *ReplayFilter, targetDestinationId string, rawFile, dryRun bool) ([]*ReplayResult, error) {
+	if rawFile && filter.ErrorContains != "" {
+		return nil, errors.New("error filter isn't supported for raw_json files")
+	}
+
+	filePaths, err := s.selectFiles(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*ReplayResult{}
+	for _, filePath := range filePaths {
+		fileName := filepath.Base(filePath)
+		destinationId, err := extractDestinationId(fileName)
+		if err != nil && targetDestinationId == "" {
+			return nil, err
+		}
+
+		result := &ReplayResult{FileName: fileName, DestinationId: destinationId, TargetDestinationId: destinationId}
+		if targetDestinationId != "" {
+			result.TargetDestinationId = targetDestinationId
+		}
+		if err := s.replayFile(filePath, filter.ErrorContains, rawFile, dryRun, result); err != nil {
+			result.Error = err.Error()
+			logging.Errorf("Error replaying file: [%s] from fallback: %v", fileName, err)
+		}
+		results = append(results, result)
+	}
+
+	if !dryRun && len(results) > 0 {
+		notifyReplayed(results)
+	}
+
+	return results, nil
+}
+
+//selectFiles return fallback files paths which match the filter sorted by name
+func (s *Service) selectFiles(filter *ReplayFilter) ([]string, error) {
+	var filePaths []string
+	if filter.FileName != "" {
+		filePath, _ := s.resolvePath(filter.FileName)
+		filePaths = []string{filePath}
+	} else {
+		var err error
+		filePaths, err = filepath.Glob(s.fileMask)
+		if err != nil {
+			return nil, fmt.Errorf("Error finding fallback files by mask [%s]: %v", s.fileMask, err)
+		}
+	}
+
+	var result []string
+	for _, filePath := range filePaths {
+		fileName := filepath.Base(filePath)
+		if len(filter.DestinationIds) > 0 {
+			destinationId, err := extractDestinationId(fileName)
+			if err != nil || !filter.DestinationIds[destinationId] {
+				continue
+			}
+		}
+
+		if !filter.Start.IsZero() || !filter.End.IsZero() {
+			fileTime, ok := extractFileTime(fileName)
+			if !ok || (!filter.Start.IsZero() && fileTime.Before(filter.Start)) || (!filter.End.IsZero() && fileTime.After(filter.End)) {
+				continue
+			}
+		}
+
+		result = append(result, filePath)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+//replayFile store matched lines into the target destination and archive the file or rewrite it with not matched lines
+func (s *Service) replayFile(filePath, errorContains string, rawFile, dryRun bool, result *ReplayResult) error {
+	fileName := filepath.Base(filePath)
+	_, loaded := s.locks.LoadOrStore(fileName, true)
+	if loaded {
+		return fmt.Errorf("File [%s] is being processed", fileName)
+	}
+	defer s.locks.Delete(fileName)
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Error reading fallback file [%s]: %v", fileName, err)
+	}
+
+	matched, remaining := splitLines(b, errorContains)
+	result.Events = countLines(matched)
+	result.Skipped = countLines(remaining)
+	if dryRun || result.Events == 0 {
+		return nil
+	}
+
+	if err := s.store(fileName, filePath, result.TargetDestinationId, matched, rawFile); err != nil {
+		return err
+	}
+
+	if result.Skipped == 0 {
+		s.archive(fileName, filePath)
+		return nil
+	}
+
+	//keep not matched events for further replays. Statuses are related to replayed events
+	tmpPath := filePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, remaining, 0644); err != nil {
+		return fmt.Errorf("Error writing not replayed events of [%s]: %v", fileName, err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("Error replacing [%s] with not replayed events: %v", fileName, err)
+	}
+	s.statusManager.CleanUp(fileName)
+
+	return nil
+}
+
+//splitLines split payload into lines which error contains substring (all lines if substring is empty) and other lines
+func splitLines(payload []byte, errorContains string) ([]byte, []byte) {
+	if errorContains == "" {
+		return payload, nil
+	}
+
+	var matched, remaining bytes.Buffer
+	for _, line := range bytes.Split(payload, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		failedEvent := &struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.Unmarshal(line, failedEvent); err == nil && strings.Contains(failedEvent.Error, errorContains) {
+			matched.Write(line)
+			matched.WriteByte('\n')
+		} else {
+			remaining.Write(line)
+			remaining.WriteByte('\n')
+		}
+	}
+
+	return matched.Bytes(), remaining.Bytes()
+}
+
+func countLines(payload []byte) int {
+	count := 0
+	for _, line := range bytes.Split(payload, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+func extractFileTime(fileName string) (time.Time, bool) {
+	regexResult := fileTimeExtractRegexp.FindStringSubmatch(fileName)
+	if len(regexResult) != 2 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(fileTimeLayout, regexResult[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,86 @@
+package fallback
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitLines(t *testing.T) {
+	payload := []byte(`{"event":{"a":1},"error":"pq: column b has type integer"}
+{"event":{"a":2},"error":"connection refused"}
+
+{"event":{"a":3},"error":"pq: column c has type integer"}
+malformed
+`)
+
+	matched, remaining := splitLines(payload, "has type")
+	require.Equal(t, 2, countLines(matched))
+	require.Equal(t, 2, countLines(remaining))
+	require.Contains(t, string(matched), `"a":3`)
+	require.Contains(t, string(remaining), "malformed")
+
+	matched, remaining = splitLines(payload, "")
+	require.Equal(t, 4, countLines(matched))
+	require.Nil(t, remaining)
+}
+
+func TestExtractFileTime(t *testing.T) {
+	fileTime, ok := extractFileTime("failed.dst=my-postgres-2021-01-02T15-04-05.123.log")
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, 1, 2, 15, 4, 5, 123000000, time.UTC), fileTime)
+
+	_, ok = extractFileTime("failed.dst=my-postgres.log")
+	require.False(t, ok)
+}
+
+func TestReplayFilteredDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fallback_replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"failed.dst=pg-2021-01-01T10-00-00.000.log": `{"event":{},"error":"timeout"}` + "\n" + `{"event":{},"error":"type mismatch"}` + "\n",
+		"failed.dst=pg-2021-01-03T10-00-00.000.log": `{"event":{},"error":"timeout"}` + "\n",
+		"failed.dst=ch-2021-01-01T10-00-00.000.log": `{"event":{},"error":"timeout"}` + "\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	s := &Service{fallbackDir: dir, fileMask: filepath.Join(dir, fallbackFileMaskPostfix)}
+	filter := &ReplayFilter{
+		DestinationIds: map[string]bool{"pg": true},
+		End:            time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+		ErrorContains:  "timeout",
+	}
+	results, err := s.ReplayFiltered(filter, "", false, true)
+	require.NoError(t, err)
+	require.Equal(t, []*ReplayResult{{
+		FileName:            "failed.dst=pg-2021-01-01T10-00-00.000.log",
+		DestinationId:       "pg",
+		TargetDestinationId: "pg",
+		Events:              1,
+		Skipped:             1,
+	}}, results)
+
+	results, err = s.ReplayFiltered(&ReplayFilter{}, "backup", false, true)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for _, result := range results {
+		require.Equal(t, "backup", result.TargetDestinationId)
+	}
+
+	//files aren't changed in dry run mode
+	for name, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(b))
+	}
+
+	_, err = s.ReplayFiltered(&ReplayFilter{ErrorContains: "timeout"}, "", true, true)
+	require.Error(t, err)
+}
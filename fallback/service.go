@@ -8,10 +8,10 @@ import (
 	"github.com/jitsucom/eventnative/logfiles"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/notifications"
 	"github.com/jitsucom/eventnative/parsers"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -41,8 +41,8 @@ func NewTestService() *Service {
 }
 
 func NewService(logEventsPath string, destinationService *destinations.Service) (*Service, error) {
-	fallbackPath := path.Join(logEventsPath, "failed")
-	logArchiveEventPath := path.Join(logEventsPath, "archive")
+	fallbackPath := filepath.Join(logEventsPath, "failed")
+	logArchiveEventPath := filepath.Join(logEventsPath, "archive")
 	statusManager, err := logfiles.NewStatusManager(fallbackPath)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating fallback files status manager: %v", err)
@@ -50,25 +50,20 @@ func NewService(logEventsPath string, destinationService *destinations.Service)
 	return &Service{
 		fallbackDir:        fallbackPath,
 		statusManager:      statusManager,
-		fileMask:           path.Join(fallbackPath, fallbackFileMaskPostfix),
+		fileMask:           filepath.Join(fallbackPath, fallbackFileMaskPostfix),
 		destinationService: destinationService,
 		archiver:           logfiles.NewArchiver(fallbackPath, logArchiveEventPath),
 	}, nil
 }
 
-func (s *Service) Replay(fileName, destinationId string, rawFile bool) error {
+//Replay store all fallback file events into the destination (from the file name if destinationId is empty) and archive the file
+//replay_finished lifecycle event is sent if the file isn't being processed by another replay
+func (s *Service) Replay(fileName, destinationId string, rawFile bool) (err error) {
 	if fileName == "" {
 		return errors.New("File name can't be empty")
 	}
 
-	//handle absolute and local path
-	var filePath string
-	if strings.HasPrefix(fileName, "/") {
-		filePath = fileName
-		fileName = filepath.Base(fileName)
-	} else {
-		filePath = path.Join(s.fallbackDir, fileName)
-	}
+	filePath, fileName := s.resolvePath(fileName)
 
 	_, loaded := s.locks.LoadOrStore(fileName, true)
 	if loaded {
@@ -76,21 +71,64 @@ func (s *Service) Replay(fileName, destinationId string, rawFile bool) error {
 	}
 	defer s.locks.Delete(fileName)
 
+	result := &ReplayResult{FileName: fileName, TargetDestinationId: destinationId}
+	defer func() {
+		if err != nil {
+			result.Error = err.Error()
+		}
+		notifyReplayed([]*ReplayResult{result})
+	}()
+
 	b, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("Error reading fallback file [%s]: %v", fileName, err)
 	}
+	result.Events = countLines(b)
 
+	result.DestinationId, _ = extractDestinationId(fileName)
 	if destinationId == "" {
 		//get destinationId from filename
-		regexResult := destinationIdExtractRegexp.FindStringSubmatch(fileName)
-		if len(regexResult) != 2 {
-			return fmt.Errorf("Error processing fallback file %s: Malformed name", fileName)
+		destinationId, err = extractDestinationId(fileName)
+		if err != nil {
+			return err
 		}
+		result.TargetDestinationId = destinationId
+	}
+
+	if err := s.store(fileName, filePath, destinationId, b, rawFile); err != nil {
+		return err
+	}
+
+	s.archive(fileName, filePath)
+	return nil
+}
+
+//notifyReplayed send replay_finished lifecycle event with files replay results
+func notifyReplayed(results []*ReplayResult) {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
 
-		destinationId = regexResult[1]
+	notifications.Lifecycle(notifications.ReplayFinished, map[string]interface{}{
+		"files":  results,
+		"failed": failed,
+	})
+}
+
+//resolvePath handle absolute and local path. Return file path and file name
+func (s *Service) resolvePath(fileName string) (string, string) {
+	if strings.HasPrefix(fileName, "/") {
+		return fileName, filepath.Base(fileName)
 	}
 
+	return filepath.Join(s.fallbackDir, fileName), fileName
+}
+
+//store payload into destination and update tables statuses of the file
+func (s *Service) store(fileName, filePath, destinationId string, payload []byte, rawFile bool) error {
 	storageProxy, ok := s.destinationService.GetStorageById(destinationId)
 	if !ok {
 		return fmt.Errorf("Destination [%s] wasn't found", destinationId)
@@ -114,7 +152,7 @@ func (s *Service) Replay(fileName, destinationId string, rawFile bool) error {
 		parserFunc = parsers.ParseJson
 	}
 
-	resultPerTable, errRowsCount, err := storage.StoreWithParseFunc(fileName, b, alreadyUploadedTables, parserFunc)
+	resultPerTable, errRowsCount, err := storage.StoreWithParseFunc(fileName, payload, alreadyUploadedTables, parserFunc)
 	if errRowsCount > 0 {
 		metrics.ErrorTokenEvents(fallbackIdentifier, storage.Name(), errRowsCount)
 	}
@@ -136,17 +174,15 @@ func (s *Service) Replay(fileName, destinationId string, rawFile bool) error {
 		s.statusManager.UpdateStatus(fileName, storage.Name(), tableName, result.Err)
 	}
 
-	if multiErr == nil {
-		archiveErr := s.archiver.ArchiveByPath(filePath)
-		if archiveErr != nil {
-			logging.SystemErrorf("Error archiving [%s] fallback file: %v", filePath, err)
-		} else {
-			s.statusManager.CleanUp(fileName)
-		}
+	return multiErr
+}
 
-		return nil
+//archive move fully replayed file into archive and clean up its statuses
+func (s *Service) archive(fileName, filePath string) {
+	if err := s.archiver.ArchiveByPath(filePath); err != nil {
+		logging.SystemErrorf("Error archiving [%s] fallback file: %v", filePath, err)
 	} else {
-		return multiErr
+		s.statusManager.CleanUp(fileName)
 	}
 }
 
@@ -174,13 +210,11 @@ func (s *Service) GetFileStatuses(destinationsFilter map[string]bool) []*FileSta
 		}
 
 		//get destinationId from filename
-		regexResult := destinationIdExtractRegexp.FindStringSubmatch(fileName)
-		if len(regexResult) != 2 {
+		destinationId, err := extractDestinationId(fileName)
+		if err != nil {
 			logging.Errorf("Error processing fallback file %s. Malformed name", filePath)
 			continue
 		}
-
-		destinationId := regexResult[1]
 		_, ok := destinationsFilter[destinationId]
 		if len(destinationsFilter) > 0 && !ok {
 			continue
@@ -198,3 +232,12 @@ func (s *Service) GetFileStatuses(destinationsFilter map[string]bool) []*FileSta
 
 	return fileStatuses
 }
+
+func extractDestinationId(fileName string) (string, error) {
+	regexResult := destinationIdExtractRegexp.FindStringSubmatch(fileName)
+	if len(regexResult) != 2 {
+		return "", fmt.Errorf("Error processing fallback file %s: Malformed name", fileName)
+	}
+
+	return regexResult[1], nil
+}
@@ -0,0 +1,252 @@
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const reloadEvery = time.Minute
+
+var instance = &Service{configured: map[string]*Flag{}, stored: map[string]*Flag{}}
+
+//Flag enables a pipeline behavior for all tokens, for listed tokens or for a percentage of tokens.
+//Evaluation order: disabled_tokens, tokens, enabled, percentage
+type Flag struct {
+	Name           string   `mapstructure:"name" json:"name" yaml:"name,omitempty"`
+	Enabled        bool     `mapstructure:"enabled" json:"enabled" yaml:"enabled,omitempty"`
+	Tokens         []string `mapstructure:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	DisabledTokens []string `mapstructure:"disabled_tokens" json:"disabled_tokens,omitempty" yaml:"disabled_tokens,omitempty"`
+	//Percentage [0, 100] of tokens (chosen deterministically by token id hash) with enabled flag
+	Percentage float64 `mapstructure:"percentage" json:"percentage,omitempty" yaml:"percentage,omitempty"`
+}
+
+//Validate return err if flag name is empty or percentage is out of [0, 100]
+func (f *Flag) Validate() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return errors.New("feature flag name can't be empty")
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("feature flag [%s] percentage must be in [0, 100]: %v", f.Name, f.Percentage)
+	}
+
+	return nil
+}
+
+//IsEnabled return true if flag is enabled for the token
+func (f *Flag) IsEnabled(tokenId string) bool {
+	for _, t := range f.DisabledTokens {
+		if t == tokenId {
+			return false
+		}
+	}
+	for _, t := range f.Tokens {
+		if t == tokenId {
+			return true
+		}
+	}
+	if f.Enabled {
+		return true
+	}
+	if f.Percentage > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(f.Name + ":" + tokenId))
+		return float64(h.Sum32()%10000) < f.Percentage*100
+	}
+
+	return false
+}
+
+//Service keeps feature flags from configuration and from meta storage.
+//Flags from meta storage override configured ones with the same name and are reloaded periodically
+type Service struct {
+	sync.RWMutex
+
+	metaStorage meta.Storage
+	configured  map[string]*Flag
+	stored      map[string]*Flag
+	job         *scheduler.Job
+}
+
+//Init validate configured flags (key is a flag name), load flags from meta storage and schedule their reloading
+//metaStorage might be nil
+func Init(metaStorage meta.Storage, config map[string]*Flag) (*Service, error) {
+	configured := map[string]*Flag{}
+	for name, flag := range config {
+		if flag == nil {
+			continue
+		}
+		flag.Name = name
+		if err := flag.Validate(); err != nil {
+			return nil, err
+		}
+		configured[name] = flag
+	}
+
+	service := &Service{metaStorage: metaStorage, configured: configured, stored: map[string]*Flag{}}
+	if metaStorage != nil && metaStorage.Type() != meta.DummyType {
+		if err := service.reload(); err != nil {
+			logging.Errorf("Error loading feature flags from meta storage: %v", err)
+		}
+		service.job = scheduler.Add("features_reload", scheduler.Every(reloadEvery), service.reload)
+	}
+
+	instance = service
+	return service, nil
+}
+
+//IsEnabled return true if feature flag is enabled for the token in the global service
+func IsEnabled(name, tokenId string) bool {
+	return instance.IsEnabled(name, tokenId)
+}
+
+//Flags return all feature flags of the global service
+func Flags() []*Flag {
+	return instance.Flags()
+}
+
+//Save validate flag and persist it in meta storage of the global service
+func Save(flag *Flag) error {
+	return instance.Save(flag)
+}
+
+//Delete remove flag from meta storage of the global service
+func Delete(name string) error {
+	return instance.Delete(name)
+}
+
+//IsEnabled return false if flag doesn't exist
+func (s *Service) IsEnabled(name, tokenId string) bool {
+	flag := s.get(name)
+	if flag == nil {
+		return false
+	}
+
+	return flag.IsEnabled(tokenId)
+}
+
+func (s *Service) get(name string) *Flag {
+	s.RLock()
+	defer s.RUnlock()
+
+	if flag, ok := s.stored[name]; ok {
+		return flag
+	}
+
+	return s.configured[name]
+}
+
+//Flags return effective flags sorted by name
+func (s *Service) Flags() []*Flag {
+	s.RLock()
+	all := map[string]*Flag{}
+	for name, flag := range s.configured {
+		all[name] = flag
+	}
+	for name, flag := range s.stored {
+		all[name] = flag
+	}
+	s.RUnlock()
+
+	flags := make([]*Flag, 0, len(all))
+	for _, flag := range all {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+
+	return flags
+}
+
+//Save persist flag in meta storage and apply it immediately on this node (other nodes apply it after reloading)
+func (s *Service) Save(flag *Flag) error {
+	if err := s.checkMetaStorage(); err != nil {
+		return err
+	}
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(flag)
+	if err != nil {
+		return fmt.Errorf("Error marshalling feature flag: %v", err)
+	}
+	if err := s.metaStorage.SaveFeatureFlag(flag.Name, string(b)); err != nil {
+		return fmt.Errorf("Error saving feature flag [%s] in meta storage: %v", flag.Name, err)
+	}
+
+	s.Lock()
+	s.stored[flag.Name] = flag
+	s.Unlock()
+	return nil
+}
+
+//Delete remove flag from meta storage. Configured flag with the same name (if any) becomes effective
+func (s *Service) Delete(name string) error {
+	if err := s.checkMetaStorage(); err != nil {
+		return err
+	}
+
+	if err := s.metaStorage.DeleteFeatureFlag(name); err != nil {
+		return fmt.Errorf("Error deleting feature flag [%s] from meta storage: %v", name, err)
+	}
+
+	s.Lock()
+	delete(s.stored, name)
+	s.Unlock()
+	return nil
+}
+
+func (s *Service) checkMetaStorage() error {
+	if s.metaStorage == nil || s.metaStorage.Type() == meta.DummyType {
+		return errors.New("Feature flags can't be saved without configured meta storage")
+	}
+
+	return nil
+}
+
+//reload replace stored flags with meta storage ones. Malformed flags are skipped
+func (s *Service) reload() error {
+	payloads, err := s.metaStorage.GetFeatureFlags()
+	if err != nil {
+		return err
+	}
+
+	stored := map[string]*Flag{}
+	for name, payload := range payloads {
+		flag := &Flag{}
+		if err := json.Unmarshal([]byte(payload), flag); err != nil {
+			logging.Errorf("Error parsing feature flag [%s] from meta storage: %v", name, err)
+			continue
+		}
+		flag.Name = name
+		if err := flag.Validate(); err != nil {
+			logging.Errorf("Feature flag [%s] from meta storage will be skipped: %v", name, err)
+			continue
+		}
+		stored[name] = flag
+	}
+
+	s.Lock()
+	s.stored = stored
+	s.Unlock()
+	return nil
+}
+
+//Close stop reloading job
+func (s *Service) Close() error {
+	if s.job != nil {
+		s.job.Stop()
+	}
+
+	return nil
+}
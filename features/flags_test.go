@@ -0,0 +1,80 @@
+package features
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type flagsStorage struct {
+	meta.Dummy
+	flags map[string]string
+}
+
+func (fs *flagsStorage) SaveFeatureFlag(name, payload string) error {
+	fs.flags[name] = payload
+	return nil
+}
+
+func (fs *flagsStorage) GetFeatureFlags() (map[string]string, error) {
+	return fs.flags, nil
+}
+
+func (fs *flagsStorage) DeleteFeatureFlag(name string) error {
+	delete(fs.flags, name)
+	return nil
+}
+
+func (fs *flagsStorage) Type() string {
+	return meta.RedisType
+}
+
+func TestFlagIsEnabled(t *testing.T) {
+	flag := &Flag{Name: "new_dedup", Enabled: true, DisabledTokens: []string{"token1"}}
+	require.False(t, flag.IsEnabled("token1"))
+	require.True(t, flag.IsEnabled("token2"))
+
+	flag = &Flag{Name: "new_dedup", Tokens: []string{"token1"}}
+	require.True(t, flag.IsEnabled("token1"))
+	require.False(t, flag.IsEnabled("token2"))
+
+	flag = &Flag{Name: "new_dedup", Percentage: 30}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		tokenId := fmt.Sprintf("token%d", i)
+		if flag.IsEnabled(tokenId) {
+			enabled++
+		}
+		require.Equal(t, flag.IsEnabled(tokenId), flag.IsEnabled(tokenId), "rollout must be deterministic")
+	}
+	require.InDelta(t, 300, enabled, 60)
+
+	require.Error(t, (&Flag{Name: "new_dedup", Percentage: 101}).Validate())
+}
+
+func TestServiceOverrides(t *testing.T) {
+	storage := &flagsStorage{flags: map[string]string{"new_mapping": `{"tokens":["token2"]}`}}
+	service, err := Init(storage, map[string]*Flag{
+		"new_dedup":   {Enabled: true},
+		"new_mapping": {Enabled: true},
+	})
+	require.NoError(t, err)
+	defer service.Close()
+
+	require.True(t, IsEnabled("new_dedup", "token1"))
+	require.False(t, IsEnabled("new_mapping", "token1"), "meta storage flag must override configured one")
+	require.True(t, IsEnabled("new_mapping", "token2"))
+	require.False(t, IsEnabled("unknown", "token1"))
+
+	require.NoError(t, Save(&Flag{Name: "new_dedup", DisabledTokens: []string{"token1"}, Enabled: true}))
+	require.False(t, IsEnabled("new_dedup", "token1"))
+	require.Contains(t, storage.flags, "new_dedup")
+
+	require.NoError(t, Delete("new_mapping"))
+	require.True(t, IsEnabled("new_mapping", "token1"), "configured flag must be effective after deletion")
+	require.Len(t, Flags(), 2)
+
+	_, err = Init(nil, map[string]*Flag{"wrong": {Percentage: -1}})
+	require.Error(t, err)
+}
@@ -8,7 +8,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
-	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -120,7 +120,7 @@ func findMmdbFile(dir string) string {
 
 	for _, f := range files {
 		if strings.HasSuffix(f.Name(), mmdbSuffix) {
-			return path.Join(dir, f.Name())
+			return filepath.Join(dir, f.Name())
 		}
 	}
 
@@ -4,14 +4,21 @@ go 1.14
 
 require (
 	bou.ke/monkey v1.0.2
+	cloud.google.com/go v0.53.0
 	cloud.google.com/go/bigquery v1.4.0
 	cloud.google.com/go/firestore v1.1.1
-	cloud.google.com/go/storage v1.5.0
+	cloud.google.com/go/storage v1.6.0
 	firebase.google.com/go/v4 v4.1.0
 	github.com/aws/aws-sdk-go v1.34.0
 	github.com/coreos/etcd v3.3.13+incompatible
+	github.com/denisenkom/go-mssqldb v0.9.0
+	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/docker/go-connections v0.4.0
-	github.com/gin-gonic/gin v1.6.3
+	github.com/dop251/goja v0.0.0-20201221183957-6b6d5e2b5d80
+	github.com/eclipse/paho.mqtt.golang v1.2.0
+	github.com/gin-gonic/gin v1.7.7
+	github.com/go-ldap/ldap/v3 v3.2.4
+	github.com/go-sourcemap/sourcemap v2.1.4+incompatible // indirect
 	github.com/golang/protobuf v1.4.2 // indirect
 	github.com/gomodule/redigo v1.8.2
 	github.com/google/go-cmp v0.5.1 // indirect
@@ -19,30 +26,40 @@ require (
 	github.com/google/martian v2.1.0+incompatible
 	github.com/google/uuid v1.1.2
 	github.com/gookit/color v1.3.1
+	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/joncrlsn/dque v0.0.0-20200702023911-3e80e3146ce5
+	github.com/klauspost/compress v1.10.5
 	github.com/lib/pq v1.8.0
 	github.com/mailru/easyjson v0.7.6
 	github.com/mailru/go-clickhouse v1.3.0
 	github.com/oschwald/geoip2-golang v1.4.0
 	github.com/panjf2000/ants/v2 v2.4.3
+	github.com/pierrec/lz4 v2.0.5+incompatible
+	github.com/pkg/sftp v1.12.0
 	github.com/prometheus/client_golang v0.9.3
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/segmentio/kafka-go v0.3.5
 	github.com/snowflakedb/gosnowflake v1.3.8
 	github.com/spf13/cast v1.3.0
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1
 	github.com/testcontainers/testcontainers-go v0.9.0
 	github.com/ua-parser/uap-go v0.0.0-20200325213135-e1c09f13e2fe
+	github.com/xitongsys/parquet-go v1.5.4
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	go.etcd.io/bbolt v1.3.5
 	go.opencensus.io v0.22.4 // indirect
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	golang.org/x/exp v0.0.0-20200224162631-6cc2880d07d6 // indirect
 	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
 	golang.org/x/net v0.0.0-20200707034311-ab3426394381 // indirect
-	golang.org/x/sys v0.0.0-20200803210538-64077c9b5642 // indirect
-	golang.org/x/text v0.3.3 // indirect
+	golang.org/x/sys v0.0.0-20200803210538-64077c9b5642
+	golang.org/x/text v0.3.3
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
 	golang.org/x/tools v0.0.0-20200806022845-90696ccdc692 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	google.golang.org/api v0.17.0
+	google.golang.org/api v0.18.0
 	google.golang.org/appengine v1.6.6 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 	honnef.co/go/tools v0.0.1-2020.1.4 // indirect
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/enrichment"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	BulkCsvFormat    = "csv"
+	BulkNdjsonFormat = "ndjson"
+
+	//maxBulkRowErrors is a max number of rows errors in the response
+	maxBulkRowErrors = 10
+	//bulkChunkRows is a max number of rows per destination which are kept in memory before storing
+	bulkChunkRows = 10000
+)
+
+//BulkImportResponse is a bulk import result: parsed rows, rows which have been skipped because of mapping errors
+//and store results per destination
+type BulkImportResponse struct {
+	Status       string                   `json:"status"`
+	Error        string                   `json:"error,omitempty"`
+	Rows         int                      `json:"rows"`
+	Skipped      int                      `json:"skipped"`
+	RowErrors    []string                 `json:"row_errors,omitempty"`
+	Destinations []*BulkDestinationResult `json:"destinations"`
+}
+
+//BulkDestinationResult is a destination store result: stored and failed (with processing or table errors) events
+type BulkDestinationResult struct {
+	DestinationId string `json:"destination_id"`
+	Events        int    `json:"events"`
+	Stored        int    `json:"stored"`
+	Failed        int    `json:"failed"`
+	Error         string `json:"error,omitempty"`
+}
+
+//BulkHandler imports historical events from CSV or NDJSON files (backfills) into token destinations as a batch
+type BulkHandler struct {
+	destinationService *destinations.Service
+	preprocessor       events.Preprocessor
+}
+
+//NewBulkHandler return BulkHandler which preprocesses rows as server 2 server events
+func NewBulkHandler(destinationService *destinations.Service) *BulkHandler {
+	return &BulkHandler{destinationService: destinationService, preprocessor: events.NewApiPreprocessor()}
+}
+
+//Handler accept multipart/form-data with fields:
+//file - CSV (with header) or NDJSON file, format - csv or ndjson (optional, detected by file extension),
+//delimiter - CSV delimiter (optional, default ','), mapping - JSON of data_layout.mappings format which is applied to every row (optional),
+//target_token - server token which events are imported with (required with admin token, request server token is used otherwise)
+//Rows are enriched like API events but _timestamp and eventn_ctx_event_id are kept if they are present.
+//File is read row by row and every destination of the token stores its events synchronously in chunks of bulkChunkRows rows:
+//at most one chunk per destination is kept in memory. Already stored chunks aren't rolled back if the rest of the file
+//can't be parsed (the response has status 'error' and results of stored chunks). Quotas aren't applied
+func (bh *BulkHandler) Handler(c *gin.Context) {
+	tokenId, token, ok := bh.extractToken(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "file is required multipart form field", Error: err.Error()})
+		return
+	}
+
+	mapper := schema.Mapper(&schema.DummyMapper{})
+	if mappingStr := c.PostForm("mapping"); mappingStr != "" {
+		mapping := &schema.Mapping{}
+		if err := json.Unmarshal([]byte(mappingStr), mapping); err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse mapping", Error: err.Error()})
+			return
+		}
+		mapper, _, err = schema.NewFieldMapper(schema.Default, nil, mapping)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Invalid mapping", Error: err.Error()})
+			return
+		}
+	}
+
+	response := &BulkImportResponse{Status: "ok", Destinations: []*BulkDestinationResult{}}
+	fileName := fmt.Sprintf("bulk-%s-%s", tokenId, time.Now().UTC().Format("2006-01-02T15-04-05.000"))
+	chunks := 0
+	results := map[string]*BulkDestinationResult{}
+	payloads := map[string]*bytes.Buffer{}
+	rowsCount := map[string]int{}
+	flush := func(destinationId string) {
+		chunks++
+		result := bh.store(tokenId, destinationId, fmt.Sprintf("%s-%d.log", fileName, chunks), payloads[destinationId].Bytes())
+		if total, ok := results[destinationId]; ok {
+			total.merge(result)
+		} else {
+			results[destinationId] = result
+		}
+		payloads[destinationId].Reset()
+		rowsCount[destinationId] = 0
+	}
+
+	err = readBulkFile(fileHeader, c.PostForm("format"), c.PostForm("delimiter"), func(object map[string]interface{}) error {
+		response.Rows++
+		event, err := bh.prepare(object, mapper, token, c.Request)
+		if err != nil {
+			response.Skipped++
+			if len(response.RowErrors) < maxBulkRowErrors {
+				response.RowErrors = append(response.RowErrors, fmt.Sprintf("row [%d]: %v", response.Rows, err))
+			}
+			return nil
+		}
+
+		for destinationId := range bh.destinationService.GetDestinationIdsByEvent(tokenId, event) {
+			payload, ok := payloads[destinationId]
+			if !ok {
+				payload = &bytes.Buffer{}
+				payloads[destinationId] = payload
+			}
+			payload.WriteString(event.Serialize())
+			payload.WriteByte('\n')
+			rowsCount[destinationId]++
+			if rowsCount[destinationId] >= bulkChunkRows {
+				flush(destinationId)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if chunks == 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse file", Error: err.Error()})
+			return
+		}
+		response.Status = "error"
+		response.Error = "Failed to parse file: " + err.Error()
+	} else {
+		for destinationId, rows := range rowsCount {
+			if rows > 0 {
+				flush(destinationId)
+			}
+		}
+	}
+
+	for _, result := range results {
+		response.Destinations = append(response.Destinations, result)
+	}
+	sort.Slice(response.Destinations, func(i, j int) bool {
+		return response.Destinations[i].DestinationId < response.Destinations[j].DestinationId
+	})
+
+	if response.Status != "ok" {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+//extractToken return token id and token which is written into events: server token from context
+//or target_token parameter in admin requests. Write error response if token can't be resolved
+//Tokens with signing secret can't be used directly: multipart files are streamed and aren't verified with signatures
+func (bh *BulkHandler) extractToken(c *gin.Context) (string, string, bool) {
+	if iface, ok := c.Get(middleware.TokenName); ok {
+		token := iface.(string)
+		if secret, _ := appconfig.Instance.AuthorizationService.GetSigningSecret(token); secret != "" {
+			c.JSON(http.StatusUnauthorized, middleware.ErrorResponse{Message: "The token requires signed requests. Bulk import with this token is allowed only with admin token and target_token parameter"})
+			return "", "", false
+		}
+		return appconfig.Instance.AuthorizationService.GetTokenId(token), token, true
+	}
+
+	targetToken := c.Query("target_token")
+	if targetToken == "" {
+		targetToken = c.PostForm("target_token")
+	}
+	if targetToken == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "target_token (server token which events are imported with) is required parameter in requests with admin token"})
+		return "", "", false
+	}
+
+	if _, ok := appconfig.Instance.AuthorizationService.GetServerOrigins(targetToken); !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "target_token must be a server token"})
+		return "", "", false
+	}
+
+	return appconfig.Instance.AuthorizationService.GetTokenId(targetToken), targetToken, true
+}
+
+//prepare return mapped and enriched event with the original event timestamp and identifier (if they are present)
+func (bh *BulkHandler) prepare(object map[string]interface{}, mapper schema.Mapper, token string, r *http.Request) (events.Event, error) {
+	mapped, err := mapper.Map(object)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTimestamp, err := extractBulkTimestamp(mapped)
+	if err != nil {
+		return nil, err
+	}
+
+	//flat event id (e.g. CSV column) is kept as eventn_ctx.event_id for preventing generated id collision
+	flatEventIdKey := events.EventnKey + "_" + events.EventIdKey
+	if eventId, ok := mapped[flatEventIdKey]; ok {
+		if _, exists := mapped[events.EventnKey]; !exists {
+			mapped[events.EventnKey] = map[string]interface{}{}
+		}
+		if eventn, ok := mapped[events.EventnKey].(map[string]interface{}); ok {
+			delete(mapped, flatEventIdKey)
+			eventn[events.EventIdKey] = eventId
+		}
+	}
+
+	event := events.Event(mapped)
+	enrichment.ContextEnrichmentStep(event, token, r, bh.preprocessor)
+	if eventTimestamp != "" {
+		event[timestamp.Key] = eventTimestamp
+	}
+
+	return event, nil
+}
+
+//store payload into the destination synchronously and return the result
+func (bh *BulkHandler) store(tokenId, destinationId, fileName string, payload []byte) *BulkDestinationResult {
+	result := &BulkDestinationResult{DestinationId: destinationId, Events: bytes.Count(payload, []byte{'\n'})}
+
+	storageProxy, ok := bh.destinationService.GetStorageById(destinationId)
+	if !ok {
+		result.Failed = result.Events
+		result.Error = "Destination wasn't found"
+		return result
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		result.Failed = result.Events
+		result.Error = "Destination hasn't been initialized yet"
+		return result
+	}
+
+	resultPerTable, errRowsCount, err := storage.Store(fileName, payload, map[string]bool{})
+	if err != nil {
+		logging.Errorf("[%s] Error storing bulk import file %s: %v", destinationId, fileName, err)
+		metrics.ErrorTokenEvents(tokenId, storage.Name(), result.Events)
+		result.Failed = result.Events
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Failed = errRowsCount
+	var tableErrors []string
+	for tableName, tableResult := range resultPerTable {
+		if tableResult.Err != nil {
+			logging.Errorf("[%s] Error storing bulk import table %s from file %s: %v", destinationId, tableName, fileName, tableResult.Err)
+			tableErrors = append(tableErrors, fmt.Sprintf("table [%s]: %v", tableName, tableResult.Err))
+			result.Failed += tableResult.RowsCount
+		} else {
+			result.Stored += tableResult.RowsCount
+		}
+	}
+	sort.Strings(tableErrors)
+	result.Error = strings.Join(tableErrors, "; ")
+
+	metrics.SuccessTokenEvents(tokenId, storage.Name(), result.Stored)
+	metrics.ErrorTokenEvents(tokenId, storage.Name(), result.Failed)
+
+	return result
+}
+
+//merge add chunk result into the destination result
+func (bdr *BulkDestinationResult) merge(chunk *BulkDestinationResult) {
+	bdr.Events += chunk.Events
+	bdr.Stored += chunk.Stored
+	bdr.Failed += chunk.Failed
+	if chunk.Error != "" && !strings.Contains(bdr.Error, chunk.Error) {
+		if bdr.Error != "" {
+			bdr.Error += "; "
+		}
+		bdr.Error += chunk.Error
+	}
+}
+
+//readBulkFile pass objects from CSV or NDJSON file to handle func row by row.
+//Format is detected by file extension (.ndjson, .jsonl, .json) if it is empty
+func readBulkFile(fileHeader *multipart.FileHeader, format, delimiter string, handle func(map[string]interface{}) error) error {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return readBulk(file, detectBulkFormat(fileHeader.Filename, format), delimiter, handle)
+}
+
+func readBulk(r io.Reader, format, delimiter string, handle func(map[string]interface{}) error) error {
+	switch format {
+	case BulkNdjsonFormat:
+		return parsers.ReadJsonLines(r, handle)
+	case BulkCsvFormat:
+		delimiterRune := ','
+		if delimiter != "" {
+			delimiterRune, _ = utf8.DecodeRuneInString(delimiter)
+		}
+		err := parsers.ReadCsvWithDelimiter(r, delimiterRune, nil, handle)
+		if err == io.EOF {
+			//empty file
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("Unknown format [%s]. Available formats: [%s, %s]", format, BulkCsvFormat, BulkNdjsonFormat)
+	}
+}
+
+func detectBulkFormat(fileName, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".ndjson", ".jsonl", ".json":
+		return BulkNdjsonFormat
+	default:
+		return BulkCsvFormat
+	}
+}
+
+//extractBulkTimestamp return _timestamp value in timestamp.Layout or empty string if it isn't present
+//RFC3339 strings and unix seconds are accepted
+func extractBulkTimestamp(object map[string]interface{}) (string, error) {
+	value, ok := object[timestamp.Key]
+	if !ok || value == nil || value == "" {
+		return "", nil
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case string:
+		//CSV cells are always strings
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			t = time.Unix(0, int64(seconds*float64(time.Second)))
+			break
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return "", fmt.Errorf("%s must be RFC3339 string or unix seconds: %v", timestamp.Key, err)
+		}
+		t = parsed
+	case json.Number:
+		seconds, err := v.Float64()
+		if err != nil {
+			return "", fmt.Errorf("%s must be RFC3339 string or unix seconds: %v", timestamp.Key, err)
+		}
+		t = time.Unix(0, int64(seconds*float64(time.Second)))
+	case float64:
+		t = time.Unix(0, int64(v*float64(time.Second)))
+	default:
+		return "", fmt.Errorf("%s must be RFC3339 string or unix seconds: %v", timestamp.Key, value)
+	}
+
+	return timestamp.ToISOFormat(t.UTC()), nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//parseBulk return all objects which are read by readBulk
+func parseBulk(r io.Reader, format, delimiter string) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	err := readBulk(r, format, delimiter, func(object map[string]interface{}) error {
+		objects = append(objects, object)
+		return nil
+	})
+	return objects, err
+}
+
+func TestParseBulk(t *testing.T) {
+	objects, err := parseBulk(strings.NewReader("User Id;Event\nu1;signup\nu2;purchase\n"), detectBulkFormat("events.csv", ""), ";")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"user_id": "u1", "event": "signup"},
+		{"user_id": "u2", "event": "purchase"},
+	}, objects)
+
+	objects, err = parseBulk(strings.NewReader("{\"a\":1}\n\n{\"a\":2}"), detectBulkFormat("events.NDJSON", ""), "")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"a": json.Number("1")}, {"a": json.Number("2")}}, objects)
+
+	objects, err = parseBulk(strings.NewReader(""), BulkCsvFormat, "")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	//rows before malformed line are read one by one
+	objects, err = parseBulk(strings.NewReader("{\"a\":1}\n{"), BulkNdjsonFormat, "")
+	require.EqualError(t, err, "malformed JSON line [2]: unexpected EOF")
+	require.Len(t, objects, 1)
+
+	_, err = parseBulk(strings.NewReader(""), detectBulkFormat("events.csv", "xml"), "")
+	require.EqualError(t, err, "Unknown format [xml]. Available formats: [csv, ndjson]")
+}
+
+func TestBulkPrepare(t *testing.T) {
+	mapper, _, err := schema.NewFieldMapper(schema.Default, nil, &schema.Mapping{Fields: []schema.MappingField{
+		{Src: "/user_id", Dst: "/eventn_ctx/user/id", Action: schema.MOVE},
+		{Src: "/ts", Dst: "/_timestamp", Action: schema.MOVE},
+	}})
+	require.NoError(t, err)
+
+	bh := NewBulkHandler(nil)
+	r := httptest.NewRequest("POST", "/api/v1/events/bulk", nil)
+
+	event, err := bh.prepare(map[string]interface{}{"user_id": "u1", "ts": "2020-05-01T10:00:00+02:00", "eventn_ctx_event_id": "e1"}, mapper, "token1", r)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-01T08:00:00.000000Z", event["_timestamp"])
+	require.Equal(t, map[string]interface{}{"event_id": "e1", "user": map[string]interface{}{"id": "u1"}}, event["eventn_ctx"])
+	require.Equal(t, "api", event["src"])
+
+	event, err = bh.prepare(map[string]interface{}{"_timestamp": json.Number("1588320000")}, mapper, "token1", r)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-01T08:00:00.000000Z", event["_timestamp"])
+
+	//CSV cells are strings
+	objects, err := parseBulk(strings.NewReader("_timestamp,user_id\n1588320000,u1\n1588320000.5,u2\n"), BulkCsvFormat, "")
+	require.NoError(t, err)
+	event, err = bh.prepare(objects[0], mapper, "token1", r)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-01T08:00:00.000000Z", event["_timestamp"])
+	event, err = bh.prepare(objects[1], mapper, "token1", r)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-01T08:00:00.500000Z", event["_timestamp"])
+
+	_, err = bh.prepare(map[string]interface{}{"ts": "yesterday"}, mapper, "token1", r)
+	require.Error(t, err)
+}
+
+func TestBulkDestinationResultMerge(t *testing.T) {
+	result := &BulkDestinationResult{DestinationId: "dest1", Events: 10, Stored: 10}
+	result.merge(&BulkDestinationResult{DestinationId: "dest1", Events: 5, Stored: 3, Failed: 2, Error: "table [events]: error"})
+	result.merge(&BulkDestinationResult{DestinationId: "dest1", Events: 5, Failed: 5, Error: "table [events]: error"})
+	require.Equal(t, &BulkDestinationResult{DestinationId: "dest1", Events: 20, Stored: 13, Failed: 7, Error: "table [events]: error"}, result)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/capture"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+)
+
+type CaptureSessionsResponse struct {
+	Sessions []*capture.SessionStatus `json:"sessions"`
+}
+
+type CapturedRequestsResponse struct {
+	Session  *capture.SessionStatus `json:"session"`
+	Requests []*capture.Request     `json:"requests"`
+}
+
+//CaptureStartHandler start (or restart) raw requests capture session of the token on the current instance
+func CaptureStartHandler(c *gin.Context) {
+	config := &capture.Config{}
+	if err := c.BindJSON(config); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	status, err := capture.Start(config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Invalid capture session config", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+//CaptureSessionsHandler return all capture sessions of the current instance
+func CaptureSessionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, CaptureSessionsResponse{Sessions: capture.Sessions()})
+}
+
+//CaptureGetHandler return captured raw requests of the token from the oldest to the newest
+func CaptureGetHandler(c *gin.Context) {
+	status, requests, ok := capture.Get(c.Param("token_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Capture session of the token doesn't exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CapturedRequestsResponse{Session: status, Requests: requests})
+}
+
+//CaptureStopHandler stop capture session of the token and remove captured requests
+func CaptureStopHandler(c *gin.Context) {
+	if !capture.Stop(c.Param("token_id")) {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Capture session of the token doesn't exist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
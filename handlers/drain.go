@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/drain"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultDrainTimeoutSec = 300
+
+type DrainHandler struct {
+	drainService *drain.Service
+}
+
+func NewDrainHandler(drainService *drain.Service) *DrainHandler {
+	return &DrainHandler{drainService: drainService}
+}
+
+//PostHandler start draining (if it hasn't been started yet) and wait for completion up to timeout (seconds) query parameter
+//return 200 with drain status if the server has been drained, 202 if draining is still in progress
+//or 500 if draining has been timed out (status contains remaining counts and the request might be repeated)
+//draining is one-way: the server keeps rejecting events after completion (or timeout) until it is restarted
+func (dh *DrainHandler) PostHandler(c *gin.Context) {
+	timeoutSec := defaultDrainTimeoutSec
+	if value := c.Query("timeout"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "timeout must be a non-negative number of seconds"})
+			return
+		}
+		timeoutSec = parsed
+	}
+
+	done := dh.drainService.Start()
+	timer := time.NewTimer(time.Duration(timeoutSec) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		status := dh.drainService.Status()
+		if status.Status == drain.TimedOut {
+			c.JSON(http.StatusInternalServerError, status)
+		} else {
+			c.JSON(http.StatusOK, status)
+		}
+	case <-timer.C:
+		c.JSON(http.StatusAccepted, dh.drainService.Status())
+	case <-c.Request.Context().Done():
+	}
+}
+
+//GetHandler return drain status
+func (dh *DrainHandler) GetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, dh.drainService.Status())
+}
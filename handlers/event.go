@@ -5,7 +5,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/dedup"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drift"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
@@ -13,6 +16,7 @@ import (
 	"github.com/jitsucom/eventnative/telemetry"
 	"github.com/jitsucom/eventnative/timestamp"
 	"github.com/jitsucom/eventnative/users"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -24,9 +28,14 @@ const (
 )
 
 type CachedEvent struct {
-	Original json.RawMessage `json:"original,omitempty"`
-	Success  json.RawMessage `json:"success,omitempty"`
-	Error    string          `json:"error,omitempty"`
+	DestinationId string          `json:"destination_id,omitempty"`
+	EventId       string          `json:"event_id,omitempty"`
+	TokenId       string          `json:"token_id,omitempty"`
+	Timestamp     string          `json:"timestamp,omitempty"`
+	Original      json.RawMessage `json:"original,omitempty"`
+	Success       json.RawMessage `json:"success,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Rejected      json.RawMessage `json:"rejected,omitempty"`
 }
 
 type OldCachedEventsResponse struct {
@@ -37,6 +46,7 @@ type CachedEventsResponse struct {
 	TotalEvents    int           `json:"total_events"`
 	ResponseEvents int           `json:"response_events"`
 	Events         []CachedEvent `json:"events"`
+	NextCursor     string        `json:"next_cursor,omitempty"`
 }
 
 //Accept all events
@@ -46,17 +56,20 @@ type EventHandler struct {
 	eventsCache            *caching.EventsCache
 	inMemoryEventsCache    *events.Cache
 	userRecognitionService *users.RecognitionService
+	identityService        *users.IdentityService
 }
 
 //Accept all events according to token
 func NewEventHandler(destinationService *destinations.Service, preprocessor events.Preprocessor, eventsCache *caching.EventsCache,
-	inMemoryEventsCache *events.Cache, userRecognitionService *users.RecognitionService) (eventHandler *EventHandler) {
+	inMemoryEventsCache *events.Cache, userRecognitionService *users.RecognitionService,
+	identityService *users.IdentityService) (eventHandler *EventHandler) {
 	return &EventHandler{
 		destinationService:     destinationService,
 		preprocessor:           preprocessor,
 		eventsCache:            eventsCache,
 		inMemoryEventsCache:    inMemoryEventsCache,
 		userRecognitionService: userRecognitionService,
+		identityService:        identityService,
 	}
 }
 
@@ -75,8 +88,65 @@ func (eh *EventHandler) PostHandler(c *gin.Context) {
 	}
 	token := iface.(string)
 
+	if err := eh.ProcessEvent(payload, token, c.Request); err != nil {
+		if quotaErr, ok := err.(*counters.QuotaExceededError); ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(quotaErr.ResetAt).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, middleware.ErrorResponse{Message: "Events quota exceeded", Error: err.Error(), Code: middleware.QuotaExceededCode})
+			return
+		}
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to process event", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
+//ProcessEvent enrich, cache and multiplex event to all token destinations
+//request might be nil if event wasn't received via HTTP (e.g. MQTT)
+//return *counters.QuotaExceededError if token events quota has been exhausted
+func (eh *EventHandler) ProcessEvent(payload events.Event, token string, r *http.Request) error {
 	//** Context enrichment **
-	enrichment.ContextEnrichmentStep(payload, token, c.Request, eh.preprocessor)
+	enrichment.ContextEnrichmentStep(payload, token, r, eh.preprocessor)
+
+	tokenId := appconfig.Instance.AuthorizationService.GetTokenId(token)
+	counters.TokenStage(tokenId, counters.StageReceived, 1)
+
+	//** Deduplication **
+	//duplicates (e.g. SDK retries) don't consume quotas
+	eventId := events.ExtractEventId(payload)
+	if dedup.IsGlobalDuplicate(eventId) {
+		logging.Debugf("Event [%s] has been already received within deduplication window. Skipped", eventId)
+		return nil
+	}
+
+	//** Quotas **
+	if err := counters.ConsumeQuota(tokenId, appconfig.Instance.AuthorizationService.GetQuotaConfig(tokenId)); err != nil {
+		return err
+	}
+
+	//** Client timestamp validation **
+	enrichment.TimestampValidationStep(payload, tokenId)
+
+	//** Sampling **
+	if !enrichment.SamplingStep(payload, tokenId) {
+		return nil
+	}
+	//kept event represents 1 / sample rate original events
+	sampleWeight := enrichment.SampleWeight(payload, tokenId)
+	counters.TokenStage(tokenId, counters.StageEnriched, 1)
+	counters.TokenStageEstimated(tokenId, counters.StageEnriched, sampleWeight)
+
+	//** Event size limit **
+	if err := enrichment.EventSizeStep(payload, tokenId); err != nil {
+		eh.fallback(tokenId, payload, err)
+		return nil
+	}
+
+	//** Schema drift statistics **
+	drift.Track(tokenId, payload)
+
+	//** Identity stitching **
+	eh.identityService.Stitch(payload)
 
 	//** Caching **
 	//clone payload for preventing concurrent changes while serialization
@@ -86,15 +156,13 @@ func (eh *EventHandler) PostHandler(c *gin.Context) {
 	eh.inMemoryEventsCache.PutAsync(token, cachingEvent)
 
 	//Persisted cache
-	eventId := events.ExtractEventId(payload)
 	if eventId == "" {
 		logging.SystemErrorf("Empty extracted eventn_ctx_event_id in: %s", payload.Serialize())
 	}
-	tokenId := appconfig.Instance.AuthorizationService.GetTokenId(token)
 	var destinationIds []string
-	for destinationId := range eh.destinationService.GetDestinationIds(tokenId) {
+	for destinationId := range eh.destinationService.GetDestinationIdsByEvent(tokenId, payload) {
 		destinationIds = append(destinationIds, destinationId)
-		eh.eventsCache.Put(destinationId, eventId, cachingEvent)
+		eh.eventsCache.Put(tokenId, destinationId, eventId, cachingEvent)
 	}
 
 	//** Multiplexing **
@@ -107,12 +175,40 @@ func (eh *EventHandler) PostHandler(c *gin.Context) {
 		for _, consumer := range consumers {
 			consumer.Consume(payload, tokenId)
 		}
+		counters.TokenStage(tokenId, counters.StageLogged, 1)
+		counters.TokenStageEstimated(tokenId, counters.StageLogged, sampleWeight)
+		for _, destinationId := range destinationIds {
+			counters.DestinationStage(destinationId, counters.StageLogged, 1)
+			counters.DestinationStageEstimated(destinationId, counters.StageLogged, sampleWeight)
+		}
 
 		//Retrospective users recognition
 		eh.userRecognitionService.Event(payload, destinationIds)
+
+		//Unique users counting
+		counters.UniqueUser(tokenId, destinationIds, payload)
 	}
 
-	c.JSON(http.StatusOK, middleware.OkResponse())
+	return nil
+}
+
+//fallback write event with the error into fallback of all token destinations
+func (eh *EventHandler) fallback(tokenId string, payload events.Event, err error) {
+	logging.Warnf("Event [%s] of token [%s] is routed to fallback: %v", events.ExtractEventId(payload), tokenId, err)
+
+	failedEvent := &events.FailedEvent{Event: []byte(payload.Serialize()), Error: err.Error(), EventId: events.ExtractEventId(payload)}
+	for destinationId := range eh.destinationService.GetDestinationIdsByEvent(tokenId, payload) {
+		storageProxy, ok := eh.destinationService.GetStorageById(destinationId)
+		if !ok {
+			continue
+		}
+		storage, ok := storageProxy.Get()
+		if !ok {
+			logging.Errorf("[%s] Event [%s] can't be written into fallback: destination hasn't been initialized yet", destinationId, failedEvent.EventId)
+			continue
+		}
+		storage.Fallback(failedEvent)
+	}
 }
 
 func (eh *EventHandler) OldGetHandler(c *gin.Context) {
@@ -142,19 +238,37 @@ func (eh *EventHandler) OldGetHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+//GetHandler return cached events filtered by destination ids or token id (destinations of the token),
+//status (success/error/pending) and time range with cursor pagination
 func (eh *EventHandler) GetHandler(c *gin.Context) {
 	var err error
+	tokenId := c.Query("token_id")
 	destinationIds := c.Query("destination_ids")
-	if destinationIds == "" {
-		logging.Errorf("Empty destination ids in events cache handler")
-		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_ids is required parameter."})
+	if destinationIds == "" && tokenId == "" {
+		logging.Errorf("Empty destination ids and token id in events cache handler")
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_ids or token_id is required parameter."})
 		return
 	}
 
-	start := time.Time{}
+	filter := &caching.EventsFilter{
+		TokenId: tokenId,
+		Status:  c.Query("status"),
+		Cursor:  c.Query("cursor"),
+		End:     time.Now().UTC(),
+		Limit:   defaultLimit,
+	}
+
+	if destinationIds != "" {
+		filter.DestinationIds = strings.Split(destinationIds, ",")
+	} else {
+		for destinationId := range eh.destinationService.GetDestinationIds(tokenId) {
+			filter.DestinationIds = append(filter.DestinationIds, destinationId)
+		}
+	}
+
 	startStr := c.Query("start")
 	if startStr != "" {
-		start, err = time.Parse(timestamp.Layout, startStr)
+		filter.Start, err = time.Parse(timestamp.Layout, startStr)
 		if err != nil {
 			logging.Errorf("Error parsing start query param [%s] in events cache handler: %v", startStr, err)
 			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing start query parameter. Accepted datetime format: " + timestamp.Layout, Error: err.Error()})
@@ -162,10 +276,9 @@ func (eh *EventHandler) GetHandler(c *gin.Context) {
 		}
 	}
 
-	end := time.Now().UTC()
 	endStr := c.Query("end")
 	if endStr != "" {
-		end, err = time.Parse(timestamp.Layout, endStr)
+		filter.End, err = time.Parse(timestamp.Layout, endStr)
 		if err != nil {
 			logging.Errorf("Error parsing end query param [%s] in events cache handler: %v", endStr, err)
 			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing end query parameter. Accepted datetime format: " + timestamp.Layout, Error: err.Error()})
@@ -174,29 +287,37 @@ func (eh *EventHandler) GetHandler(c *gin.Context) {
 	}
 
 	limitStr := c.Query("limit")
-	var limit int
-	if limitStr == "" {
-		limit = defaultLimit
-	} else {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			logging.Errorf("Error parsing limit [%s] to int in events cache handler: %v", limitStr, err)
-			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be int"})
+	if limitStr != "" {
+		filter.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || filter.Limit <= 0 {
+			logging.Errorf("Error parsing limit [%s] to positive int in events cache handler: %v", limitStr, err)
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be positive int"})
 			return
 		}
 	}
 
-	response := CachedEventsResponse{Events: []CachedEvent{}}
-	for _, destinationId := range strings.Split(destinationIds, ",") {
-		eventsArray := eh.eventsCache.GetN(destinationId, start, end, limit)
-		for _, event := range eventsArray {
-			response.Events = append(response.Events, CachedEvent{
-				Original: []byte(event.Original),
-				Success:  []byte(event.Success),
-				Error:    event.Error,
-			})
-		}
-		response.ResponseEvents += len(eventsArray)
+	page, err := eh.eventsCache.Query(filter)
+	if err != nil {
+		logging.Errorf("Error querying events cache: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error querying events cache", Error: err.Error()})
+		return
+	}
+
+	response := CachedEventsResponse{Events: []CachedEvent{}, NextCursor: page.NextCursor}
+	for _, event := range page.Events {
+		response.Events = append(response.Events, CachedEvent{
+			DestinationId: event.DestinationId,
+			EventId:       event.EventId,
+			TokenId:       event.TokenId,
+			Timestamp:     event.Timestamp.Format(timestamp.Layout),
+			Original:      []byte(event.Original),
+			Success:       []byte(event.Success),
+			Error:         event.Error,
+			Rejected:      []byte(event.Rejected),
+		})
+	}
+	response.ResponseEvents = len(page.Events)
+	for _, destinationId := range filter.DestinationIds {
 		response.TotalEvents += eh.eventsCache.GetTotal(destinationId)
 	}
 
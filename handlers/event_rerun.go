@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/maputils"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"net/http"
+)
+
+//EventOutcome is a result of event processing with destination configuration
+type EventOutcome struct {
+	ConfigHash string                 `json:"config_hash,omitempty"`
+	Table      string                 `json:"table,omitempty"`
+	Record     map[string]interface{} `json:"record,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+//EventRerunResponse is a comparison of cached event outcome with current configuration outcome
+type EventRerunResponse struct {
+	DestinationId  string                 `json:"destination_id"`
+	EventId        string                 `json:"event_id"`
+	Original       json.RawMessage        `json:"original,omitempty"`
+	Previous       *EventOutcome          `json:"previous"`
+	Current        *EventOutcome          `json:"current"`
+	OutcomeChanged bool                   `json:"outcome_changed"`
+	RecordDiff     []*maputils.Difference `json:"record_diff,omitempty"`
+	ConfigDiff     []*maputils.Difference `json:"config_diff,omitempty"`
+	ConfigWarning  string                 `json:"config_warning,omitempty"`
+}
+
+//RerunHandler process cached event with current destination configuration (dry run: without storing)
+//and return diff between previous and current outcomes and destination configurations
+func (eh *EventHandler) RerunHandler(c *gin.Context) {
+	destinationId := c.Query("destination_id")
+	eventId := c.Query("event_id")
+	if destinationId == "" || eventId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "destination_id and event_id are required parameters."})
+		return
+	}
+
+	cached, err := eh.eventsCache.GetEvent(destinationId, eventId)
+	if err != nil {
+		logging.Errorf("[%s] Error getting cached event [%s]: %v", destinationId, eventId, err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error getting cached event", Error: err.Error()})
+		return
+	}
+	if cached == nil {
+		c.JSON(http.StatusNotFound, middleware.ErrorResponse{Message: "Event [" + eventId + "] wasn't found in destination [" + destinationId + "] cache"})
+		return
+	}
+
+	storageProxy, ok := eh.destinationService.GetStorageById(destinationId)
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination [" + destinationId + "] wasn't found"})
+		return
+	}
+	storage, ok := storageProxy.Get()
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination [" + destinationId + "] hasn't been initialized yet"})
+		return
+	}
+
+	original := events.Event{}
+	decoder := json.NewDecoder(bytes.NewBufferString(cached.Original))
+	decoder.UseNumber()
+	if err := decoder.Decode(&original); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing cached event", Error: err.Error()})
+		return
+	}
+
+	previous := &EventOutcome{ConfigHash: cached.ConfigHash, Error: cached.Error}
+	if cached.Success != "" {
+		succeed := &caching.SucceedEvent{}
+		if err := json.Unmarshal([]byte(cached.Success), succeed); err != nil {
+			logging.Errorf("[%s] Error parsing cached succeed event [%s]: %v", destinationId, eventId, err)
+		} else {
+			previous.Table = succeed.Table
+			previous.Record = map[string]interface{}{}
+			for _, field := range succeed.Record {
+				previous.Record[field.Field] = field.Value
+			}
+		}
+	}
+
+	current := &EventOutcome{ConfigHash: eh.eventsCache.GetCurrentConfigHash(destinationId)}
+	table, processed, err := storages.DryRun(storage, original)
+	if err != nil {
+		current.Error = err.Error()
+	} else {
+		current.Table = table
+		current.Record = normalize(processed)
+	}
+
+	response := EventRerunResponse{
+		DestinationId: destinationId,
+		EventId:       eventId,
+		Original:      []byte(cached.Original),
+		Previous:      previous,
+		Current:       current,
+		RecordDiff:    maputils.Diff(normalize(previous.Record), current.Record),
+	}
+	response.OutcomeChanged = (previous.Error == "") != (current.Error == "") || previous.Table != current.Table || len(response.RecordDiff) > 0
+
+	if previous.ConfigHash == "" {
+		response.ConfigWarning = "Event doesn't have configuration hash: only error events have it"
+	} else if previous.ConfigHash != current.ConfigHash {
+		response.ConfigDiff, err = eh.configDiff(destinationId, previous.ConfigHash, current.ConfigHash)
+		if err != nil {
+			response.ConfigWarning = err.Error()
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//configDiff return differences between destination configuration snapshots
+func (eh *EventHandler) configDiff(destinationId, previousHash, currentHash string) ([]*maputils.Difference, error) {
+	previous, err := eh.getConfigSnapshot(destinationId, previousHash)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := eh.getConfigSnapshot(destinationId, currentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return maputils.Diff(previous, current), nil
+}
+
+func (eh *EventHandler) getConfigSnapshot(destinationId, hash string) (map[string]interface{}, error) {
+	snapshot, err := eh.eventsCache.GetConfigSnapshot(destinationId, hash)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting configuration snapshot [%s]: %v", hash, err)
+	}
+	if snapshot == "" {
+		return nil, fmt.Errorf("Configuration snapshot [%s] wasn't found", hash)
+	}
+
+	parsed := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(snapshot), &parsed); err != nil {
+		return nil, fmt.Errorf("Error parsing configuration snapshot [%s]: %v", hash, err)
+	}
+
+	return parsed, nil
+}
+
+//normalize return object with JSON types for comparison (e.g. json.Number or time.Time)
+func normalize(object map[string]interface{}) map[string]interface{} {
+	if object == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(object)
+	if err != nil {
+		return object
+	}
+
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return object
+	}
+
+	return normalized
+}
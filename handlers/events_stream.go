@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	streamWriteTimeout = 10 * time.Second
+	streamPingInterval = 30 * time.Second
+)
+
+//admin token is checked by middleware: any origin (e.g. local debugging page) is allowed
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+//StreamHandler upgrade connection to WebSocket and write live events of events cache pipeline as JSON messages
+//filtered by destination_ids or token_id (destinations of the token) and status (pending/success/error) query parameters
+func (eh *EventHandler) StreamHandler(c *gin.Context) {
+	filter := &caching.TailFilter{Status: c.Query("status")}
+	switch filter.Status {
+	case "", caching.StatusPending, caching.StatusSuccess, caching.StatusError:
+	default:
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Unknown status. Available values: [" + caching.StatusPending + ", " + caching.StatusSuccess + ", " + caching.StatusError + "]"})
+		return
+	}
+
+	if destinationIds := c.Query("destination_ids"); destinationIds != "" {
+		filter.DestinationIds = strings.Split(destinationIds, ",")
+	} else if tokenId := c.Query("token_id"); tokenId != "" {
+		for destinationId := range eh.destinationService.GetDestinationIds(tokenId) {
+			filter.DestinationIds = append(filter.DestinationIds, destinationId)
+		}
+		if len(filter.DestinationIds) == 0 {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Token [" + tokenId + "] doesn't have destinations"})
+			return
+		}
+	}
+
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		//upgrader has already written HTTP error response
+		logging.Errorf("Error upgrading events stream connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	subscription := eh.eventsCache.Subscribe(filter)
+	defer eh.eventsCache.Unsubscribe(subscription)
+
+	//read loop is required for handling close and control messages
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-subscription.Events:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				logging.Debugf("Events stream connection has been closed: %v", err)
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamHandler(t *testing.T) {
+	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 10)
+	handler := &EventHandler{eventsCache: eventsCache}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/events/stream", handler.StreamHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/events/stream"
+	_, resp, err := websocket.DefaultDialer.Dial(wsUrl+"?status=unknown", nil)
+	require.Error(t, err)
+	require.Equal(t, 400, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl+"?destination_ids=pg&status=success", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	//wait for subscription
+	require.Eventually(t, func() bool {
+		eventsCache.Succeed("pg", "warmup", events.Event{}, &adapters.Table{Name: "events"})
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		event := &caching.TailEvent{}
+		return conn.ReadJSON(event) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	eventsCache.Put("token1", "pg", "event1", events.Event{"event_type": "pageview"})
+	eventsCache.Error("pg", "event1", "connection refused")
+	eventsCache.Succeed("clickhouse", "event1", events.Event{"event_type": "pageview"}, &adapters.Table{Name: "events"})
+	eventsCache.Succeed("pg", "event2", events.Event{"event_type": "click"}, &adapters.Table{Name: "events"})
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		event := &caching.TailEvent{}
+		require.NoError(t, conn.ReadJSON(event))
+		if event.EventId == "warmup" {
+			continue
+		}
+		require.Equal(t, caching.StatusSuccess, event.Status)
+		require.Equal(t, "pg", event.DestinationId)
+		require.Equal(t, "event2", event.EventId)
+		require.Equal(t, "events", event.Table)
+		require.Equal(t, "click", event.Event["event_type"])
+		break
+	}
+}
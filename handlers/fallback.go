@@ -15,10 +15,29 @@ type FallbackFilesResponse struct {
 	Files []*fallback.FileStatus `json:"files"`
 }
 
+//ReplayRequest is a fallback replay request. File name can be omitted if filters are used:
+//destination_ids (fallback files of these destinations), start/end (files rotation time) and error_contains (events error reason).
+//If dry_run is true - events aren't replayed, only counts of events which would be replayed are returned
 type ReplayRequest struct {
-	FileName      string `json:"file_name"`
-	DestinationId string `json:"destination_id"`
-	FileFormat    string `json:"file_format"`
+	FileName       string   `json:"file_name"`
+	DestinationId  string   `json:"destination_id"`
+	FileFormat     string   `json:"file_format"`
+	DestinationIds []string `json:"destination_ids"`
+	Start          string   `json:"start"`
+	End            string   `json:"end"`
+	ErrorContains  string   `json:"error_contains"`
+	DryRun         bool     `json:"dry_run"`
+}
+
+type ReplayResponse struct {
+	Status string                   `json:"status"`
+	DryRun bool                     `json:"dry_run"`
+	Files  []*fallback.ReplayResult `json:"files"`
+	Total  int                      `json:"total"`
+}
+
+func (rr *ReplayRequest) isFiltered() bool {
+	return rr.FileName == "" || len(rr.DestinationIds) > 0 || rr.Start != "" || rr.End != "" || rr.ErrorContains != "" || rr.DryRun
 }
 
 type FallbackHandler struct {
@@ -51,6 +70,11 @@ func (fh *FallbackHandler) ReplayHandler(c *gin.Context) {
 		return
 	}
 
+	if req.isFiltered() {
+		fh.replayFiltered(c, req)
+		return
+	}
+
 	err := fh.fallbackService.Replay(req.FileName, req.DestinationId, req.FileFormat == rawJsonFormat)
 	if err != nil {
 		logging.Errorf("Error replaying file: [%s] from fallback: %v", req.FileName, err)
@@ -60,3 +84,43 @@ func (fh *FallbackHandler) ReplayHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, middleware.OkResponse())
 }
+
+func (fh *FallbackHandler) replayFiltered(c *gin.Context, req *ReplayRequest) {
+	filter := &fallback.ReplayFilter{FileName: req.FileName, DestinationIds: map[string]bool{}, ErrorContains: req.ErrorContains}
+	for _, destinationId := range req.DestinationIds {
+		filter.DestinationIds[destinationId] = true
+	}
+
+	var err error
+	if req.Start != "" {
+		filter.Start, err = parseStatisticsTime(req.Start)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing start", Error: err.Error()})
+			return
+		}
+	}
+	if req.End != "" {
+		filter.End, err = parseStatisticsTime(req.End)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing end", Error: err.Error()})
+			return
+		}
+	}
+
+	results, err := fh.fallbackService.ReplayFiltered(filter, req.DestinationId, req.FileFormat == rawJsonFormat, req.DryRun)
+	if err != nil {
+		logging.Errorf("Error replaying fallback files: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to replay fallback files", Error: err.Error()})
+		return
+	}
+
+	response := ReplayResponse{Status: "ok", DryRun: req.DryRun, Files: results}
+	for _, result := range results {
+		response.Total += result.Events
+		if result.Error != "" {
+			response.Status = "partial"
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/features"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+)
+
+type FeaturesResponse struct {
+	Flags []*features.Flag `json:"flags"`
+	//Enabled is a flags evaluation result for token_id query parameter
+	Enabled map[string]bool `json:"enabled,omitempty"`
+}
+
+//FeaturesGetHandler return all feature flags (and their values for token if token_id query parameter is set)
+func FeaturesGetHandler(c *gin.Context) {
+	response := FeaturesResponse{Flags: features.Flags()}
+	if tokenId := c.Query("token_id"); tokenId != "" {
+		response.Enabled = map[string]bool{}
+		for _, flag := range response.Flags {
+			response.Enabled[flag.Name] = flag.IsEnabled(tokenId)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//FeaturesPostHandler create or update feature flag in meta storage
+func FeaturesPostHandler(c *gin.Context) {
+	flag := &features.Flag{}
+	if err := c.BindJSON(flag); err != nil {
+		logging.Errorf("Error parsing feature flag body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if err := features.Save(flag); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to save feature flag", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
+//FeaturesDeleteHandler remove feature flag from meta storage
+func FeaturesDeleteHandler(c *gin.Context) {
+	if err := features.Delete(c.Param("name")); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to delete feature flag", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/jitsucom/eventnative/cluster"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/storages"
+	"net/http"
+	"sort"
+)
+
+const (
+	HealthOk       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+
+	uploaderJobName = "uploader"
+)
+
+type HealthResponse struct {
+	Status     string             `json:"status"`
+	Components []*ComponentHealth `json:"components,omitempty"`
+}
+
+//ComponentHealth is a component status. Critical components which are down make the server not ready
+type ComponentHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+//HealthHandler serves Kubernetes liveness and readiness probes
+type HealthHandler struct {
+	metaStorage  meta.Storage
+	destinations *destinations.Service
+	manager      cluster.Manager
+}
+
+func NewHealthHandler(metaStorage meta.Storage, destinations *destinations.Service, manager cluster.Manager) *HealthHandler {
+	return &HealthHandler{
+		metaStorage:  metaStorage,
+		destinations: destinations,
+		manager:      manager,
+	}
+}
+
+//LiveHandler return 200 while the process is able to serve HTTP requests
+func (hh *HealthHandler) LiveHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: HealthOk})
+}
+
+//ReadyHandler return components statuses: meta storage and synchronization service are critical (503 if any is down),
+//destinations and the uploader only degrade the status
+func (hh *HealthHandler) ReadyHandler(c *gin.Context) {
+	response := hh.check()
+
+	code := http.StatusOK
+	if response.Status == HealthDown {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, response)
+}
+
+func (hh *HealthHandler) check() *HealthResponse {
+	var components []*ComponentHealth
+
+	if appstatus.Instance.Idle {
+		components = append(components, &ComponentHealth{Name: "server", Status: HealthDown, Critical: true, Error: "Server is shutting down"})
+	} else if appstatus.Instance.Draining {
+		components = append(components, &ComponentHealth{Name: "server", Status: HealthDown, Critical: true, Error: "Server is draining"})
+	}
+
+	if hh.metaStorage != nil {
+		components = append(components, newComponentHealth("meta_storage", true, hh.metaStorage.Ping()))
+	}
+
+	if hh.manager != nil {
+		_, err := hh.manager.GetInstances()
+		components = append(components, newComponentHealth("synchronization_service", true, err))
+	}
+
+	if hh.destinations != nil {
+		var destinationComponents []*ComponentHealth
+		for id, storageProxy := range hh.destinations.GetAllStorages() {
+			health := &ComponentHealth{Name: "destination_" + id, Status: HealthOk}
+			if _, ok := storageProxy.Get(); !ok {
+				health.Status = HealthDown
+				health.Error = "Destination isn't initialized"
+			} else if state := storages.GetCircuitBreaker(storageProxy).State(); state != storages.CircuitClosed {
+				health.Status = HealthDegraded
+				health.Error = "Circuit breaker is " + state
+			}
+			destinationComponents = append(destinationComponents, health)
+		}
+		sort.Slice(destinationComponents, func(i, j int) bool {
+			return destinationComponents[i].Name < destinationComponents[j].Name
+		})
+		components = append(components, destinationComponents...)
+	}
+
+	for _, job := range scheduler.Jobs() {
+		if job.Name == uploaderJobName {
+			health := &ComponentHealth{Name: uploaderJobName, Status: HealthOk}
+			if job.LastError != "" {
+				health.Status = HealthDegraded
+				health.Error = job.LastError
+			}
+			components = append(components, health)
+		}
+	}
+
+	status := HealthOk
+	for _, component := range components {
+		if component.Status == HealthOk {
+			continue
+		}
+		if component.Critical {
+			status = HealthDown
+			break
+		}
+		status = HealthDegraded
+	}
+
+	return &HealthResponse{Status: status, Components: components}
+}
+
+func newComponentHealth(name string, critical bool, err error) *ComponentHealth {
+	if err != nil {
+		return &ComponentHealth{Name: name, Status: HealthDown, Critical: critical, Error: err.Error()}
+	}
+
+	return &ComponentHealth{Name: name, Status: HealthOk, Critical: critical}
+}
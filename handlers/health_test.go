@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/synchronization"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type unavailableStorage struct {
+	meta.Dummy
+}
+
+func (us *unavailableStorage) Ping() error {
+	return errors.New("connection refused")
+}
+
+func TestHealthCheck(t *testing.T) {
+	manager := synchronization.NewInMemoryService([]string{})
+
+	response := NewHealthHandler(&meta.Dummy{}, nil, manager).check()
+	require.Equal(t, HealthOk, response.Status)
+	require.Len(t, response.Components, 2)
+
+	response = NewHealthHandler(&unavailableStorage{}, nil, manager).check()
+	require.Equal(t, HealthDown, response.Status)
+	require.Equal(t, "meta_storage", response.Components[0].Name)
+	require.Equal(t, "connection refused", response.Components[0].Error)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"strings"
+)
+
+type ImportResponse struct {
+	Status  string `json:"status"`
+	Entries int    `json:"entries"`
+}
+
+//MetaHandler exports meta storage state into portable snapshot and imports it (migrations, disaster recovery restores)
+type MetaHandler struct {
+	metaStorage meta.Storage
+}
+
+func NewMetaHandler(metaStorage meta.Storage) *MetaHandler {
+	return &MetaHandler{metaStorage: metaStorage}
+}
+
+//ExportHandler return meta storage snapshot with keys which match patterns (comma separated, e.g. source#*,hourly_events:*)
+//or with all keys if patterns query parameter is empty
+func (mh *MetaHandler) ExportHandler(c *gin.Context) {
+	var patterns []string
+	if patternsStr := c.Query("patterns"); patternsStr != "" {
+		patterns = strings.Split(patternsStr, ",")
+	}
+
+	snapshot, err := mh.metaStorage.Export(patterns)
+	if err != nil {
+		logging.Errorf("Error exporting meta storage: %v", err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Failed to export meta storage", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+//ImportHandler write snapshot entries into meta storage. Existing keys are overwritten
+func (mh *MetaHandler) ImportHandler(c *gin.Context) {
+	snapshot := &meta.Snapshot{}
+	if err := c.BindJSON(snapshot); err != nil {
+		logging.Errorf("Error parsing meta snapshot body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	if err := snapshot.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Invalid snapshot", Error: err.Error()})
+		return
+	}
+
+	if err := mh.metaStorage.Import(snapshot); err != nil {
+		logging.Errorf("Error importing meta snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Failed to import meta storage snapshot", Error: err.Error()})
+		return
+	}
+
+	logging.Infof("Meta storage snapshot from [%s] created at [%s] has been imported: %d entries", snapshot.Source, snapshot.CreatedAt, len(snapshot.Entries))
+	c.JSON(http.StatusOK, ImportResponse{Status: "ok", Entries: len(snapshot.Entries)})
+}
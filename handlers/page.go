@@ -18,11 +18,12 @@ var blankPage = []byte(`<html><head></head><body></body></html>`)
 type PageHandler struct {
 	serverPublicUrl string
 	welcome         *template.Template
+	statusPage      *StatusPage
 }
 
-//Serve html files
-func NewPageHandler(sourceDir, serverPublicUrl string, disableWelcomePage bool) (ph *PageHandler) {
-	ph = &PageHandler{serverPublicUrl: serverPublicUrl}
+//Serve html files and status page (if statusPage isn't nil)
+func NewPageHandler(sourceDir, serverPublicUrl string, disableWelcomePage bool, statusPage *StatusPage) (ph *PageHandler) {
+	ph = &PageHandler{serverPublicUrl: serverPublicUrl, statusPage: statusPage}
 
 	if disableWelcomePage {
 		return
@@ -77,6 +78,13 @@ func (ph *PageHandler) Handler(c *gin.Context) {
 		if err != nil {
 			logging.Error("Error executing welcome.html template", err)
 		}
+	case statusPageName:
+		if ph.statusPage == nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ph.statusPage.Handler(c)
 	default:
 		c.AbortWithStatus(http.StatusNotFound)
 	}
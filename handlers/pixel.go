@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/parsers"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const pixelDataParameter = "data"
+
+//transparentPixel is a 1x1 transparent GIF image
+var transparentPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+//PixelHandler accepts events from GET requests (e.g. <img> tag in emails) and always responds with 1x1 transparent GIF
+//event is read from 'data' query parameter (base64 or url-encoded JSON) or from all query parameters
+func (eh *EventHandler) PixelHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+	defer c.Data(http.StatusOK, "image/gif", transparentPixel)
+
+	payload, err := parsePixelPayload(c.Request.URL.Query())
+	if err != nil {
+		logging.Errorf("Error parsing pixel event: %v", err)
+		return
+	}
+
+	iface, ok := c.Get(middleware.TokenName)
+	if !ok {
+		logging.SystemError("Token wasn't found in context")
+		return
+	}
+
+	//pixel response is always the same
+	if err := eh.ProcessEvent(payload, iface.(string), c.Request); err != nil {
+		logging.Debugf("Pixel event was rejected: %v", err)
+	}
+}
+
+//parsePixelPayload return event from 'data' query parameter or from query parameters except tokens
+func parsePixelPayload(values url.Values) (events.Event, error) {
+	data := values.Get(pixelDataParameter)
+	if data == "" {
+		payload := events.Event{}
+		for key, value := range values {
+			if key == middleware.TokenName || strings.HasPrefix(key, "p_") || len(value) == 0 {
+				continue
+			}
+			payload[key] = value[0]
+		}
+
+		if len(payload) == 0 {
+			return nil, errors.New("event is empty")
+		}
+
+		return payload, nil
+	}
+
+	var body []byte
+	if strings.HasPrefix(strings.TrimSpace(data), "{") {
+		body = []byte(data)
+	} else {
+		decoded, err := decodeBase64(data)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' parameter must be base64 or url-encoded JSON: %v", pixelDataParameter, err)
+		}
+		body = decoded
+	}
+
+	payload, err := parsers.ParseJson(body)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing '%s' parameter JSON: %v", pixelDataParameter, err)
+	}
+
+	return payload, nil
+}
+
+//decodeBase64 decodes standard or URL-safe base64 with or without padding
+func decodeBase64(data string) ([]byte, error) {
+	data = strings.TrimRight(data, "=")
+	if strings.ContainsAny(data, "-_") {
+		return base64.RawURLEncoding.DecodeString(data)
+	}
+
+	//'+' might be decoded as space in query parameters
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(data, " ", "+"))
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestParsePixelPayload(t *testing.T) {
+	body := `{"event_type":"email_open","eventn_ctx":{"user":{"email":"a+b@example.com"}},"amount":10}`
+	expected := events.Event{"event_type": "email_open", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"email": "a+b@example.com"}}, "amount": json.Number("10")}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected events.Event
+	}{
+		{"url-encoded JSON", "token=abc&data=" + url.QueryEscape(body), expected},
+		{"base64", "token=abc&data=" + url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(body))), expected},
+		{"url-safe base64", "token=abc&data=" + base64.RawURLEncoding.EncodeToString([]byte(body)), expected},
+		{"query parameters", "token=abc&p_key=abc&event_type=email_open&campaign=spring", events.Event{"event_type": "email_open", "campaign": "spring"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			actual, err := parsePixelPayload(values)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	_, err := parsePixelPayload(url.Values{"token": {"abc"}})
+	require.Error(t, err)
+	_, err = parsePixelPayload(url.Values{"data": {"not json"}})
+	require.Error(t, err)
+}
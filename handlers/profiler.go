@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCaptureSeconds = 30
+	maxCaptureSeconds     = 300
+)
+
+//ProfilerHandler captures CPU, heap and goroutines profiles bundle on demand
+type ProfilerHandler struct {
+	serverName string
+}
+
+func NewProfilerHandler(serverName string) *ProfilerHandler {
+	return &ProfilerHandler{serverName: serverName}
+}
+
+//CaptureHandler run CPU profiling for 'seconds' (30 by default) and return zip with cpu.pprof, heap.pprof and goroutine.pprof
+//Only one CPU profile might be captured at the same time
+func (ph *ProfilerHandler) CaptureHandler(c *gin.Context) {
+	seconds := defaultCaptureSeconds
+	if secondsStr := c.Query("seconds"); secondsStr != "" {
+		var err error
+		seconds, err = strconv.Atoi(secondsStr)
+		if err != nil || seconds <= 0 || seconds > maxCaptureSeconds {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: fmt.Sprintf("seconds must be an integer in [1, %d]", maxCaptureSeconds)})
+			return
+		}
+	}
+
+	cpuProfile := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(cpuProfile); err != nil {
+		c.JSON(http.StatusConflict, middleware.ErrorResponse{Message: "Error starting CPU profiling", Error: err.Error()})
+		return
+	}
+	logging.Infof("CPU profile capturing has been started for %d seconds", seconds)
+
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+	case <-c.Request.Context().Done():
+	}
+	pprof.StopCPUProfile()
+
+	//up-to-date heap statistics
+	runtime.GC()
+
+	bundle := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(bundle)
+	profiles := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+	}{
+		{"cpu.pprof", func(b *bytes.Buffer) error { _, err := b.Write(cpuProfile.Bytes()); return err }},
+		{"heap.pprof", func(b *bytes.Buffer) error { return pprof.Lookup("heap").WriteTo(b, 0) }},
+		{"goroutine.pprof", func(b *bytes.Buffer) error { return pprof.Lookup("goroutine").WriteTo(b, 0) }},
+	}
+	for _, profile := range profiles {
+		b := &bytes.Buffer{}
+		if err := profile.write(b); err != nil {
+			c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error writing " + profile.name, Error: err.Error()})
+			return
+		}
+		w, err := zipWriter.Create(profile.name)
+		if err == nil {
+			_, err = w.Write(b.Bytes())
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error writing profiles bundle", Error: err.Error()})
+			return
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error writing profiles bundle", Error: err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("profile-%s-%s.zip", ph.serverName, time.Now().UTC().Format("20060102T150405"))
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Data(http.StatusOK, "application/zip", bundle.Bytes())
+}
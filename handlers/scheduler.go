@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/scheduler"
+	"net/http"
+)
+
+type SchedulerJobsResponse struct {
+	Jobs []*scheduler.JobStatus `json:"jobs"`
+}
+
+// SchedulerJobsHandler return all scheduled background jobs with their last runs
+func SchedulerJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, SchedulerJobsResponse{Jobs: scheduler.Jobs()})
+}
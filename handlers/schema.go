@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"net/http"
+)
+
+type SchemaRefreshResponse struct {
+	Status string   `json:"status"`
+	Tables []string `json:"tables"`
+}
+
+type SchemaHandler struct {
+	destinationService *destinations.Service
+}
+
+func NewSchemaHandler(destinationService *destinations.Service) *SchemaHandler {
+	return &SchemaHandler{destinationService: destinationService}
+}
+
+//RefreshHandler drop destination tables schema cache and re-introspect the warehouse
+//It is needed after manual DDL changes outside EventNative
+func (sh *SchemaHandler) RefreshHandler(c *gin.Context) {
+	destinationId := c.Param("id")
+	if destinationId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	storageProxy, ok := sh.destinationService.GetStorageById(destinationId)
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination [" + destinationId + "] wasn't found"})
+		return
+	}
+
+	storage, ok := storageProxy.Get()
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination [" + destinationId + "] hasn't been initialized yet"})
+		return
+	}
+
+	refresher, ok := storage.(storages.SchemaRefresher)
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination type [" + storage.Type() + "] doesn't keep tables schema"})
+		return
+	}
+
+	tables, err := refresher.RefreshSchema()
+	if err != nil {
+		logging.Errorf("[%s] Error refreshing tables schema: %v", destinationId, err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error refreshing tables schema", Error: err.Error()})
+		return
+	}
+
+	logging.Infof("[%s] Tables schema has been refreshed: %v", destinationId, tables)
+	if tables == nil {
+		tables = []string{}
+	}
+	c.JSON(http.StatusOK, SchemaRefreshResponse{Status: "ok", Tables: tables})
+}
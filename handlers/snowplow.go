@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errSnowplowUnauthorized = errors.New("The token is not found. Snowplow tracker app id (aid) or 'token' query parameter must be a client token")
+	errSnowplowSigned       = errors.New("The token requires signed requests. Snowplow trackers can't sign requests: please use a token without signing_secret")
+	errSnowplowOrigin       = errors.New("Request origin isn't allowed for the token")
+)
+
+//snowplowEventTypes are EventNative event types by Snowplow tracker protocol 'e' parameter
+var snowplowEventTypes = map[string]string{
+	"pv": "pageview",
+	"pp": "page_ping",
+	"se": "structured",
+	"ue": "self_describing",
+	"tr": "transaction",
+	"ti": "transaction_item",
+}
+
+//snowplowGroups are tracker protocol parameters which are converted into nested objects (numbers are parsed)
+var snowplowGroups = map[string]map[string]string{
+	"se": {"se_ca": "category", "se_ac": "action", "se_la": "label", "se_pr": "property", "se_va": "value"},
+	"conversion": {"tr_id": "transaction_id", "tr_af": "affiliation", "tr_tt": "revenue", "tr_tx": "tax", "tr_sh": "shipping_cost",
+		"tr_ci": "city", "tr_st": "state", "tr_co": "country", "tr_cu": "currency"},
+	"item":      {"ti_id": "transaction_id", "ti_sk": "sku", "ti_nm": "name", "ti_ca": "category", "ti_pr": "price", "ti_qu": "quantity", "ti_cu": "currency"},
+	"page_ping": {"pp_mix": "min_x", "pp_max": "max_x", "pp_miy": "min_y", "pp_may": "max_y"},
+}
+
+//snowplowContextFields are tracker protocol parameters which are written into eventn_ctx
+var snowplowContextFields = map[string]string{
+	"eid":  "event_id",
+	"ua":   "user_agent",
+	"url":  "url",
+	"refr": "referer",
+	"page": "page_title",
+	"res":  "screen_resolution",
+	"vp":   "vp_size",
+	"lang": "user_language",
+	"cs":   "doc_encoding",
+	"tz":   "local_tz",
+	"ds":   "doc_size",
+}
+
+//snowplowUserFields are tracker protocol parameters which are written into eventn_ctx.user
+var snowplowUserFields = map[string]string{
+	"duid":  "anonymous_id",
+	"uid":   "id",
+	"nuid":  "network_user_id",
+	"tnuid": "network_user_id",
+}
+
+//snowplowSkippedFields are parameters which are decoded into other fields or aren't needed
+var snowplowSkippedFields = map[string]bool{
+	"e": true, "dtm": true, "ttm": true, "stm": true, "sid": true, "vid": true,
+	"ue_pr": true, "ue_px": true, "co": true, "cx": true, middleware.TokenName: true,
+}
+
+var snowplowUtm = map[string]string{"utm_source": "source", "utm_medium": "medium", "utm_campaign": "campaign", "utm_term": "term", "utm_content": "content"}
+
+var snowplowClickIds = map[string]bool{"gclid": true, "fbclid": true, "dclid": true}
+
+//snowplowPayload is a tracker POST request body (payload_data schema)
+type snowplowPayload struct {
+	Schema string                   `json:"schema"`
+	Data   []map[string]interface{} `json:"data"`
+}
+
+//selfDescribingJson is a Snowplow self-describing JSON {"schema": "iglu:vendor/name/format/version", "data": ...}
+type selfDescribingJson struct {
+	Schema string          `json:"schema"`
+	Data   json.RawMessage `json:"data"`
+}
+
+//SnowplowGetHandler accepts a Snowplow tracker event from GET /i query parameters and always responds with 1x1 transparent GIF
+//(Snowplow collector compatibility)
+func (eh *EventHandler) SnowplowGetHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+	defer c.Data(http.StatusOK, "image/gif", transparentPixel)
+
+	params := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+
+	if err := eh.processSnowplow(c, params); err != nil {
+		logging.Debugf("Snowplow event was rejected: %v", err)
+	}
+}
+
+//SnowplowPostHandler accepts a batch of Snowplow tracker events from POST /com.snowplowanalytics.snowplow/tp2 body
+//(Snowplow collector compatibility). Every event is authorized separately
+func (eh *EventHandler) SnowplowPostHandler(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to read body", Error: err.Error()})
+		return
+	}
+
+	payload := &snowplowPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		logging.Errorf("Error parsing Snowplow payload: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	var unauthorized int
+	var authErr error
+	for _, data := range payload.Data {
+		//tracker protocol values are strings, but some trackers send numbers
+		params := make(map[string]string, len(data))
+		for key, value := range data {
+			if strValue, ok := value.(string); ok {
+				params[key] = strValue
+			} else if value != nil {
+				params[key] = fmt.Sprint(value)
+			}
+		}
+
+		if err := eh.processSnowplow(c, params); err != nil {
+			if err == errSnowplowUnauthorized || err == errSnowplowSigned || err == errSnowplowOrigin {
+				unauthorized++
+				authErr = err
+			}
+			logging.Debugf("Snowplow event was rejected: %v", err)
+		}
+	}
+
+	if len(payload.Data) > 0 && unauthorized == len(payload.Data) {
+		c.JSON(http.StatusUnauthorized, middleware.ErrorResponse{Message: authErr.Error()})
+		return
+	}
+
+	c.String(http.StatusOK, "ok")
+}
+
+//processSnowplow authorize, convert and process one tracker event
+func (eh *EventHandler) processSnowplow(c *gin.Context, params map[string]string) error {
+	token, err := authorizeSnowplow(c.Request, params, appconfig.Instance.AuthorizationService.GetClientOrigins,
+		appconfig.Instance.AuthorizationService.GetSigningSecret)
+	if err != nil {
+		return err
+	}
+
+	event, err := convertSnowplow(params)
+	if err != nil {
+		return err
+	}
+
+	return eh.ProcessEvent(event, token, c.Request)
+}
+
+//authorizeSnowplow return client token of the tracker event or error if:
+//1. token isn't a client token
+//2. token requires signed requests (trackers can't sign requests, so signature check can't be bypassed with these endpoints)
+//3. token has origins and request Origin (or Referer for pixel GET requests which are sent without Origin) doesn't match them
+func authorizeSnowplow(r *http.Request, params map[string]string, clientOriginsFunc func(string) ([]string, bool),
+	signingSecretFunc func(string) (string, time.Duration)) (string, error) {
+	token := snowplowToken(r, params)
+	origins, ok := clientOriginsFunc(token)
+	if !ok {
+		return "", errSnowplowUnauthorized
+	}
+
+	if secret, _ := signingSecretFunc(token); secret != "" {
+		return "", errSnowplowSigned
+	}
+
+	if len(origins) > 0 {
+		reqOrigin := r.Header.Get("Origin")
+		if reqOrigin == "" {
+			if referer, err := url.Parse(r.Header.Get("Referer")); err == nil {
+				reqOrigin = referer.Host
+			}
+		}
+		if !middleware.IsOriginAllowed(origins, reqOrigin) {
+			return "", errSnowplowOrigin
+		}
+	}
+
+	return token, nil
+}
+
+//snowplowToken return token from 'token' query parameter or x-auth-token header or tracker app id (aid)
+func snowplowToken(r *http.Request, params map[string]string) string {
+	if token := r.URL.Query().Get(middleware.TokenName); token != "" {
+		return token
+	}
+	if token := r.Header.Get("x-auth-token"); token != "" {
+		return token
+	}
+
+	return params["aid"]
+}
+
+//convertSnowplow return EventNative event from Snowplow tracker protocol parameters
+//https://docs.snowplowanalytics.com/docs/collecting-data/collecting-from-own-applications/snowplow-tracker-protocol
+func convertSnowplow(params map[string]string) (events.Event, error) {
+	e := params["e"]
+	if e == "" {
+		return nil, errors.New("'e' (event type) parameter is required")
+	}
+
+	event := events.Event{}
+	eventCtx := map[string]interface{}{}
+	user := map[string]interface{}{}
+	srcPayload := map[string]interface{}{}
+
+	eventType, ok := snowplowEventTypes[e]
+	if !ok {
+		eventType = e
+	}
+
+	for key, value := range params {
+		if value == "" || snowplowSkippedFields[key] {
+			continue
+		}
+		if field, ok := snowplowContextFields[key]; ok {
+			eventCtx[field] = value
+			continue
+		}
+		if field, ok := snowplowUserFields[key]; ok {
+			user[field] = value
+			continue
+		}
+
+		grouped := false
+		for group, fields := range snowplowGroups {
+			if field, ok := fields[key]; ok {
+				object, _ := event[group].(map[string]interface{})
+				if object == nil {
+					object = map[string]interface{}{}
+					event[group] = object
+				}
+				object[field] = snowplowValue(value)
+				grouped = true
+				break
+			}
+		}
+		if grouped {
+			continue
+		}
+
+		switch key {
+		case "aid":
+			event["app_id"] = value
+		case "p":
+			event["platform"] = value
+		case "tv":
+			event["tracker_version"] = value
+		case "tna":
+			event["tracker_namespace"] = value
+		default:
+			srcPayload[key] = value
+		}
+	}
+
+	//structured event action is used as event type
+	if e == "se" {
+		if action, ok := params["se_ac"]; ok && action != "" {
+			eventType = action
+		}
+	}
+
+	//self-describing event
+	if e == "ue" {
+		unstructured, err := decodeSnowplowJson(params["ue_pr"], params["ue_px"])
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding self-describing event (ue_pr/ue_px): %v", err)
+		}
+		if unstructured != nil {
+			inner := &selfDescribingJson{}
+			if err := json.Unmarshal(unstructured.Data, inner); err != nil {
+				return nil, fmt.Errorf("Error parsing self-describing event data: %v", err)
+			}
+			properties := map[string]interface{}{}
+			if err := json.Unmarshal(inner.Data, &properties); err != nil {
+				return nil, fmt.Errorf("Error parsing self-describing event properties: %v", err)
+			}
+			event["event_schema"] = inner.Schema
+			event["properties"] = properties
+			if name := snowplowSchemaName(inner.Schema); name != "" {
+				eventType = name
+			}
+		}
+	}
+
+	//contexts are put into 'contexts' object by schema name
+	contexts, err := decodeSnowplowJson(params["co"], params["cx"])
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding contexts (co/cx): %v", err)
+	}
+	if contexts != nil {
+		var items []*selfDescribingJson
+		if err := json.Unmarshal(contexts.Data, &items); err != nil {
+			return nil, fmt.Errorf("Error parsing contexts data: %v", err)
+		}
+		contextsObj := map[string]interface{}{}
+		for _, item := range items {
+			//null items
+			if item == nil {
+				continue
+			}
+			data := map[string]interface{}{}
+			if err := json.Unmarshal(item.Data, &data); err != nil {
+				return nil, fmt.Errorf("Error parsing context [%s] data: %v", item.Schema, err)
+			}
+			contextsObj[snowplowSchemaName(item.Schema)] = data
+		}
+		if len(contextsObj) > 0 {
+			event["contexts"] = contextsObj
+		}
+	}
+
+	//client time: true timestamp or device created timestamp (unix millis)
+	for _, key := range []string{"ttm", "dtm"} {
+		if millis, err := strconv.ParseInt(params[key], 10, 64); err == nil {
+			eventCtx["utc_time"] = time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+			break
+		}
+	}
+
+	if sessionId := params["sid"]; sessionId != "" {
+		session := map[string]interface{}{"id": sessionId}
+		if index, err := strconv.Atoi(params["vid"]); err == nil {
+			session["index"] = index
+		}
+		eventCtx["session"] = session
+	}
+
+	if pageUrl, ok := eventCtx["url"].(string); ok {
+		enrichFromSnowplowUrl(eventCtx, pageUrl)
+	}
+
+	if len(user) > 0 {
+		eventCtx["user"] = user
+	}
+	event["event_type"] = eventType
+	event[events.EventnKey] = eventCtx
+	if len(srcPayload) > 0 {
+		event["src_payload"] = srcPayload
+	}
+
+	return event, nil
+}
+
+//decodeSnowplowJson return self-describing JSON from plain or base64 encoded parameter value or nil if both are empty
+func decodeSnowplowJson(plain, encoded string) (*selfDescribingJson, error) {
+	body := []byte(plain)
+	if plain == "" {
+		if encoded == "" {
+			return nil, nil
+		}
+
+		decoded, err := decodeBase64(encoded)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	result := &selfDescribingJson{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+//snowplowSchemaName return name from iglu schema URI: iglu:com.acme/link_click/jsonschema/1-0-0 -> link_click
+func snowplowSchemaName(schema string) string {
+	parts := strings.Split(strings.TrimPrefix(schema, "iglu:"), "/")
+	if len(parts) < 2 {
+		return schema
+	}
+
+	return parts[1]
+}
+
+//snowplowValue return number if value is numeric
+func snowplowValue(value string) interface{} {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number
+	}
+
+	return value
+}
+
+//enrichFromSnowplowUrl put document host, path, search, utm tags and click ids from page url (like JS tracker does)
+func enrichFromSnowplowUrl(eventCtx map[string]interface{}, pageUrl string) {
+	u, err := url.Parse(pageUrl)
+	if err != nil {
+		return
+	}
+
+	eventCtx["doc_host"] = u.Host
+	eventCtx["doc_path"] = u.Path
+	if u.RawQuery != "" {
+		eventCtx["doc_search"] = "?" + u.RawQuery
+	}
+
+	utm := map[string]interface{}{}
+	clickIds := map[string]interface{}{}
+	for key, values := range u.Query() {
+		if len(values) == 0 {
+			continue
+		}
+		if name, ok := snowplowUtm[key]; ok {
+			utm[name] = values[0]
+		} else if snowplowClickIds[key] {
+			clickIds[key] = values[0]
+		}
+	}
+	if len(utm) > 0 {
+		eventCtx["utm"] = utm
+	}
+	if len(clickIds) > 0 {
+		eventCtx["click_id"] = clickIds
+	}
+}
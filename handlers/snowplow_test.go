@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConvertSnowplow(t *testing.T) {
+	event, err := convertSnowplow(map[string]string{
+		"e":    "pv",
+		"eid":  "e1",
+		"aid":  "client_token",
+		"p":    "web",
+		"tv":   "js-2.17.2",
+		"dtm":  "1608811200123",
+		"duid": "anon1",
+		"uid":  "user@example.com",
+		"sid":  "s1",
+		"vid":  "3",
+		"url":  "https://site.com/docs?utm_source=google&gclid=abc",
+		"page": "Docs",
+		"res":  "1680x1050",
+		"co":   `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[{"schema":"iglu:com.snowplowanalytics.snowplow/web_page/jsonschema/1-0-0","data":{"id":"p1"}}]}`,
+		"cd":   "24",
+	})
+	require.NoError(t, err)
+	require.Equal(t, events.Event{
+		"event_type":      "pageview",
+		"app_id":          "client_token",
+		"platform":        "web",
+		"tracker_version": "js-2.17.2",
+		"contexts":        map[string]interface{}{"web_page": map[string]interface{}{"id": "p1"}},
+		"src_payload":     map[string]interface{}{"cd": "24"},
+		"eventn_ctx": map[string]interface{}{
+			"event_id":          "e1",
+			"utc_time":          "2020-12-24T12:00:00.123Z",
+			"user":              map[string]interface{}{"anonymous_id": "anon1", "id": "user@example.com"},
+			"session":           map[string]interface{}{"id": "s1", "index": 3},
+			"url":               "https://site.com/docs?utm_source=google&gclid=abc",
+			"page_title":        "Docs",
+			"screen_resolution": "1680x1050",
+			"doc_host":          "site.com",
+			"doc_path":          "/docs",
+			"doc_search":        "?utm_source=google&gclid=abc",
+			"utm":               map[string]interface{}{"source": "google"},
+			"click_id":          map[string]interface{}{"gclid": "abc"},
+		},
+	}, event)
+
+	ue := `{"schema":"iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0","data":{"schema":"iglu:com.acme/link_click/jsonschema/1-0-1","data":{"target":"https://acme.com"}}}`
+	event, err = convertSnowplow(map[string]string{"e": "ue", "ue_px": base64.RawURLEncoding.EncodeToString([]byte(ue))})
+	require.NoError(t, err)
+	require.Equal(t, "link_click", event["event_type"])
+	require.Equal(t, "iglu:com.acme/link_click/jsonschema/1-0-1", event["event_schema"])
+	require.Equal(t, map[string]interface{}{"target": "https://acme.com"}, event["properties"])
+
+	event, err = convertSnowplow(map[string]string{"e": "se", "se_ca": "video", "se_ac": "play", "se_va": "12.5"})
+	require.NoError(t, err)
+	require.Equal(t, "play", event["event_type"])
+	require.Equal(t, map[string]interface{}{"category": "video", "action": "play", "value": 12.5}, event["se"])
+
+	_, err = convertSnowplow(map[string]string{"aid": "client_token"})
+	require.EqualError(t, err, "'e' (event type) parameter is required")
+
+	_, err = convertSnowplow(map[string]string{"e": "ue", "ue_pr": "{"})
+	require.Error(t, err)
+
+	//null contexts items are skipped
+	event, err = convertSnowplow(map[string]string{"e": "pv", "co": `{"schema":"iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-0","data":[null]}`})
+	require.NoError(t, err)
+	require.NotContains(t, event, "contexts")
+}
+
+func TestAuthorizeSnowplow(t *testing.T) {
+	clientOrigins := func(token string) ([]string, bool) {
+		switch token {
+		case "any_origin", "signed":
+			return nil, true
+		case "site":
+			return []string{"*site.com"}, true
+		}
+		return nil, false
+	}
+	signingSecret := func(token string) (string, time.Duration) {
+		if token == "signed" {
+			return "secret", time.Minute
+		}
+		return "", 0
+	}
+
+	r := httptest.NewRequest("GET", "/i", nil)
+	token, err := authorizeSnowplow(r, map[string]string{"aid": "any_origin"}, clientOrigins, signingSecret)
+	require.NoError(t, err)
+	require.Equal(t, "any_origin", token)
+
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "unknown"}, clientOrigins, signingSecret)
+	require.Equal(t, errSnowplowUnauthorized, err)
+
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "signed"}, clientOrigins, signingSecret)
+	require.Equal(t, errSnowplowSigned, err)
+
+	//origin is required if token has origins
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "site"}, clientOrigins, signingSecret)
+	require.Equal(t, errSnowplowOrigin, err)
+
+	r.Header.Set("Origin", "https://evil.com")
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "site"}, clientOrigins, signingSecret)
+	require.Equal(t, errSnowplowOrigin, err)
+
+	r.Header.Set("Origin", "https://www.site.com")
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "site"}, clientOrigins, signingSecret)
+	require.NoError(t, err)
+
+	//pixel requests are sent without Origin header
+	r = httptest.NewRequest("GET", "/i", nil)
+	r.Header.Set("Referer", "https://site.com/docs")
+	_, err = authorizeSnowplow(r, map[string]string{"aid": "site"}, clientOrigins, signingSecret)
+	require.NoError(t, err)
+}
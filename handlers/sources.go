@@ -3,9 +3,16 @@ package handlers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/middleware"
 	"github.com/jitsucom/eventnative/sources"
 	"net/http"
+	"strconv"
+)
+
+const (
+	defaultSyncTasksLimit = 20
+	maxSyncTasksLimit     = 100
 )
 
 type SourceSyncStatusResponse struct {
@@ -13,9 +20,22 @@ type SourceSyncStatusResponse struct {
 }
 
 type SourceSyncStatus struct {
-	Collection string `json:"collection"`
-	Status     string `json:"status"`
-	Logs       string `json:"logs"`
+	Collection string         `json:"collection"`
+	Status     string         `json:"status"`
+	Logs       string         `json:"logs"`
+	LastTask   *meta.SyncTask `json:"last_task,omitempty"`
+}
+
+type SourceSyncTasksResponse struct {
+	Collections []CollectionSyncTasks `json:"collections"`
+}
+
+type CollectionSyncTasks struct {
+	Collection string          `json:"collection"`
+	Total      int             `json:"total"`
+	Offset     int             `json:"offset"`
+	Limit      int             `json:"limit"`
+	Tasks      []meta.SyncTask `json:"tasks"`
 }
 
 type SourcesHandler struct {
@@ -26,6 +46,9 @@ func NewSourcesHandler(sourcesService *sources.Service) *SourcesHandler {
 	return &SourcesHandler{sourcesService: sourcesService}
 }
 
+//SyncHandler run sync tasks of all source collections
+//dry_run query param (file or table) runs sync tasks which write objects into NDJSON files in log.path/preview dir or
+//into <collection table>_preview tables instead of production tables
 func (sh *SourcesHandler) SyncHandler(c *gin.Context) {
 	sourceId := c.Param("id")
 	if sourceId == "" {
@@ -33,7 +56,12 @@ func (sh *SourcesHandler) SyncHandler(c *gin.Context) {
 		return
 	}
 
-	err := sh.sourcesService.Sync(sourceId)
+	var err error
+	if dryRun := c.Query("dry_run"); dryRun != "" {
+		err = sh.sourcesService.DryRun(sourceId, dryRun)
+	} else {
+		err = sh.sourcesService.Sync(sourceId)
+	}
 	if err != nil {
 		logging.Error(err)
 		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Sync failed", Error: err.Error()})
@@ -43,6 +71,34 @@ func (sh *SourcesHandler) SyncHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, middleware.OkResponse())
 }
 
+//ResetHandler delete collections state in meta storage and run full re-sync in the current instance
+//collection query param (optional, default all collections) and drop_tables=true (drop collection tables in all source destinations before re-sync)
+func (sh *SourcesHandler) ResetHandler(c *gin.Context) {
+	sourceId := c.Param("id")
+	if sourceId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	dropTables := false
+	if dropTablesStr := c.Query("drop_tables"); dropTablesStr != "" {
+		var err error
+		dropTables, err = strconv.ParseBool(dropTablesStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "drop_tables must be boolean"})
+			return
+		}
+	}
+
+	if err := sh.sourcesService.Reset(sourceId, c.Query("collection"), dropTables); err != nil {
+		logging.Error(err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Reset failed", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.OkResponse())
+}
+
 func (sh *SourcesHandler) StatusHandler(c *gin.Context) {
 	sourceId := c.Param("id")
 	if sourceId == "" {
@@ -64,6 +120,13 @@ func (sh *SourcesHandler) StatusHandler(c *gin.Context) {
 		return
 	}
 
+	lastTasksMap, err := sh.sourcesService.GetTasks(sourceId, "", 0, 1)
+	if err != nil {
+		logging.Error(err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Getting statuses failed", Error: err.Error()})
+		return
+	}
+
 	var statuses []SourceSyncStatus
 	for collection, status := range statusesMap {
 		if status == "" {
@@ -74,12 +137,77 @@ func (sh *SourcesHandler) StatusHandler(c *gin.Context) {
 		if ok {
 			logs = actualLogs
 		}
+		var lastTask *meta.SyncTask
+		if collectionTasks, ok := lastTasksMap[collection]; ok && len(collectionTasks.Tasks) > 0 {
+			lastTask = &collectionTasks.Tasks[0]
+		}
 		statuses = append(statuses, SourceSyncStatus{
 			Collection: collection,
 			Status:     status,
 			Logs:       logs,
+			LastTask:   lastTask,
 		})
 	}
 
 	c.JSON(http.StatusOK, SourceSyncStatusResponse{Statuses: statuses})
 }
+
+//TasksHandler return sync tasks history (the newest first) per collection with pagination:
+//collection (optional, default all collections), offset (default 0) and limit (default 20, max 100) query params
+func (sh *SourcesHandler) TasksHandler(c *gin.Context) {
+	sourceId := c.Param("id")
+	if sourceId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	offset, err := parseNonNegativeInt(c.Query("offset"), 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "offset must be non negative int"})
+		return
+	}
+	limit, err := parseNonNegativeInt(c.Query("limit"), defaultSyncTasksLimit)
+	if err != nil || limit == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "limit must be positive int"})
+		return
+	}
+	if limit > maxSyncTasksLimit {
+		limit = maxSyncTasksLimit
+	}
+
+	tasksMap, err := sh.sourcesService.GetTasks(sourceId, c.Query("collection"), offset, limit)
+	if err != nil {
+		logging.Error(err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Getting sync tasks failed", Error: err.Error()})
+		return
+	}
+
+	response := SourceSyncTasksResponse{Collections: []CollectionSyncTasks{}}
+	for collection, collectionTasks := range tasksMap {
+		response.Collections = append(response.Collections, CollectionSyncTasks{
+			Collection: collection,
+			Total:      collectionTasks.Total,
+			Offset:     offset,
+			Limit:      limit,
+			Tasks:      collectionTasks.Tasks,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func parseNonNegativeInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if result < 0 {
+		return 0, strconv.ErrRange
+	}
+
+	return result, nil
+}
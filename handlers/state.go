@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/state"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//StateGetHandler return the whole declarative configuration (tokens, destinations, sources, mappings)
+//with the state version in ETag header. Secrets and credentials are redacted
+func StateGetHandler(c *gin.Context) {
+	current, err := state.Get()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to get state", Error: err.Error()})
+		return
+	}
+
+	writeRedactedState(c, current)
+}
+
+//StatePutHandler replace the whole declarative configuration with optimistic concurrency:
+//If-Match header must contain ETag of the last read state (or * for overwriting)
+//redacted values are kept as they are in the current state
+//428 is returned without If-Match header and 412 (with the current ETag) if state has been changed since reading
+func StatePutHandler(c *gin.Context) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, middleware.ErrorResponse{Message: "If-Match header is required: ETag of GET /api/v1/state response or *"})
+		return
+	}
+	expectedVersion, err := parseETag(ifMatch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse If-Match header", Error: err.Error()})
+		return
+	}
+
+	body := &state.State{}
+	if err := c.BindJSON(body); err != nil {
+		logging.Errorf("Error parsing state body: %v", err)
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to parse body", Error: err.Error()})
+		return
+	}
+
+	saved, err := state.Put(body, expectedVersion)
+	if err != nil {
+		if err == meta.ErrStateVersionConflict {
+			if current, getErr := state.Get(); getErr == nil {
+				c.Header("ETag", formatETag(current.Version))
+			}
+			c.JSON(http.StatusPreconditionFailed, middleware.ErrorResponse{Message: "State has been changed since reading. Please read the state again", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Failed to save state", Error: err.Error()})
+		return
+	}
+
+	writeRedactedState(c, saved)
+}
+
+//writeRedactedState write state with ETag header and redacted secrets and credentials
+func writeRedactedState(c *gin.Context, current *state.State) {
+	redacted, err := state.Redact(current)
+	if err != nil {
+		logging.Errorf("Error redacting state: %v", err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Failed to redact state", Error: err.Error()})
+		return
+	}
+
+	c.Header("ETag", formatETag(current.Version))
+	c.JSON(http.StatusOK, redacted)
+}
+
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+//parseETag return state version from If-Match header value or state.AnyVersion for *
+func parseETag(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return state.AnyVersion, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("ETag must be a quoted non-negative state version, e.g. \"3\": %s", value)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/drift"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type UniquesResponse struct {
+	Uniques []UniqueUsers `json:"uniques"`
+}
+
+type UniqueUsers struct {
+	Id    string         `json:"id"`
+	Daily map[string]int `json:"daily"`
+	Total int            `json:"total"`
+}
+
+type PipelineResponse struct {
+	Pipeline []PipelineStages `json:"pipeline"`
+}
+
+//PipelineStages is a funnel of events counters by pipeline stages for the whole [start, end] period and per window
+//Estimated are counters of original events extrapolated by sampling rates (equal to Total if events aren't sampled)
+type PipelineStages struct {
+	Id        string           `json:"id"`
+	Total     map[string]int   `json:"total"`
+	Estimated map[string]int   `json:"estimated,omitempty"`
+	Windows   []PipelineWindow `json:"windows,omitempty"`
+}
+
+type PipelineWindow struct {
+	Start     string         `json:"start"`
+	Stages    map[string]int `json:"stages"`
+	Estimated map[string]int `json:"estimated,omitempty"`
+}
+
+type DriftResponse struct {
+	Tokens    []TokenDrift     `json:"tokens"`
+	Anomalies []*drift.Anomaly `json:"anomalies"`
+}
+
+//TokenDrift is a baseline distribution of token event types and fields presence
+type TokenDrift struct {
+	Id         string                           `json:"id"`
+	EventTypes map[string]*drift.EventTypeStats `json:"event_types"`
+}
+
+//EventsStatisticsResponse is a time-series of success/skip/errors events counters per token and per destination
+type EventsStatisticsResponse struct {
+	Granularity  string           `json:"granularity"`
+	Start        string           `json:"start"`
+	End          string           `json:"end"`
+	Tokens       []EventsCounters `json:"tokens"`
+	Destinations []EventsCounters `json:"destinations"`
+}
+
+//EventsCounters are events counters by status for the whole period and per bucket (hour or day)
+type EventsCounters struct {
+	Id      string         `json:"id"`
+	Total   map[string]int `json:"total"`
+	Buckets []EventsBucket `json:"buckets"`
+}
+
+type EventsBucket struct {
+	Start  string         `json:"start"`
+	Events map[string]int `json:"events"`
+}
+
+type StatisticsHandler struct {
+}
+
+func NewStatisticsHandler() *StatisticsHandler {
+	return &StatisticsHandler{}
+}
+
+//UniquesHandler return approximated unique users count per day and for the whole [start, end] period
+//per token_ids and destination_ids
+func (sh *StatisticsHandler) UniquesHandler(c *gin.Context) {
+	var ids []string
+	tokenIds := c.Query("token_ids")
+	if tokenIds != "" {
+		for _, tokenId := range strings.Split(tokenIds, ",") {
+			ids = append(ids, counters.TokenPrefix+tokenId)
+		}
+	}
+	destinationIds := c.Query("destination_ids")
+	if destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			ids = append(ids, counters.DestinationPrefix+destinationId)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "token_ids or destination_ids is required parameter."})
+		return
+	}
+
+	end := time.Now().UTC()
+	endStr := c.Query("end")
+	if endStr != "" {
+		var err error
+		end, err = time.Parse(timestamp.DayLayout, endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing end query parameter. Accepted date format: " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	//last 30 days by default
+	start := end.AddDate(0, 0, -29)
+	startStr := c.Query("start")
+	if startStr != "" {
+		var err error
+		start, err = time.Parse(timestamp.DayLayout, startStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing start query parameter. Accepted date format: " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	if start.After(end) {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "start must be before end"})
+		return
+	}
+
+	response := UniquesResponse{Uniques: []UniqueUsers{}}
+	for _, id := range ids {
+		uniques := UniqueUsers{Id: id, Daily: map[string]int{}}
+		for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+			count, err := counters.CountUniqueUsers(id, day, day)
+			if err != nil {
+				logging.Errorf("Error counting [%s] unique users: %v", id, err)
+				c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error counting unique users", Error: err.Error()})
+				return
+			}
+			uniques.Daily[day.Format(timestamp.DayLayout)] = count
+		}
+
+		total, err := counters.CountUniqueUsers(id, start, end)
+		if err != nil {
+			logging.Errorf("Error counting [%s] unique users: %v", id, err)
+			c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error counting unique users", Error: err.Error()})
+			return
+		}
+		uniques.Total = total
+
+		response.Uniques = append(response.Uniques, uniques)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//PipelineHandler return events counters by pipeline stages (received -> enriched -> logged for tokens and
+//logged -> uploaded -> loaded -> errored -> fallback for destinations) per token_ids and destination_ids
+//for [start, end] period (RFC3339 or yyyymmdd; last 24 hours by default) with hour or day windows (granularity parameter)
+func (sh *StatisticsHandler) PipelineHandler(c *gin.Context) {
+	type stagesId struct {
+		id     string
+		stages []string
+	}
+	var ids []stagesId
+	if tokenIds := c.Query("token_ids"); tokenIds != "" {
+		for _, tokenId := range strings.Split(tokenIds, ",") {
+			ids = append(ids, stagesId{id: counters.TokenPrefix + tokenId, stages: counters.TokenStages})
+		}
+	}
+	if destinationIds := c.Query("destination_ids"); destinationIds != "" {
+		for _, destinationId := range strings.Split(destinationIds, ",") {
+			ids = append(ids, stagesId{id: counters.DestinationPrefix + destinationId, stages: counters.DestinationStages})
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "token_ids or destination_ids is required parameter."})
+		return
+	}
+
+	end := time.Now().UTC()
+	if endStr := c.Query("end"); endStr != "" {
+		var err error
+		end, err = parseStatisticsTime(endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing end query parameter. Accepted formats: RFC3339, " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	start := end.Add(-23 * time.Hour)
+	if startStr := c.Query("start"); startStr != "" {
+		var err error
+		start, err = parseStatisticsTime(startStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing start query parameter. Accepted formats: RFC3339, " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	if start.After(end) {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "start must be before end"})
+		return
+	}
+
+	var window time.Duration
+	switch granularity := c.DefaultQuery("granularity", "hour"); granularity {
+	case "hour":
+		window = time.Hour
+	case "day":
+		window = 24 * time.Hour
+	case "total":
+	default:
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Unknown granularity: " + granularity + ". Available values: hour, day, total"})
+		return
+	}
+
+	response := PipelineResponse{Pipeline: []PipelineStages{}}
+	for _, sid := range ids {
+		counts, err := counters.GetPipelineStages(sid.id, start, end)
+		if err != nil {
+			logging.Errorf("Error getting [%s] pipeline stages: %v", sid.id, err)
+			c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error getting pipeline stages", Error: err.Error()})
+			return
+		}
+
+		response.Pipeline = append(response.Pipeline, buildPipelineStages(sid.id, sid.stages, counts, start, end, window))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//EventsHandler return events counters (success, skip, errors) per token_ids and destination_ids bucketed by hour or day
+//(granularity parameter) for [start, end] period (RFC3339 or yyyymmdd; last 24 hours for hour and last 30 days for day granularity by default)
+func (sh *StatisticsHandler) EventsHandler(c *gin.Context) {
+	var tokenIds, destinationIds []string
+	if value := c.Query("token_ids"); value != "" {
+		tokenIds = strings.Split(value, ",")
+	}
+	if value := c.Query("destination_ids"); value != "" {
+		destinationIds = strings.Split(value, ",")
+	}
+	if len(tokenIds) == 0 && len(destinationIds) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "token_ids or destination_ids is required parameter."})
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", meta.HourGranularity)
+	var window time.Duration
+	switch granularity {
+	case meta.HourGranularity:
+		window = time.Hour
+	case meta.DayGranularity:
+		window = 24 * time.Hour
+	default:
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Unknown granularity: " + granularity + ". Available values: hour, day"})
+		return
+	}
+
+	end := time.Now().UTC()
+	if endStr := c.Query("end"); endStr != "" {
+		var err error
+		end, err = parseStatisticsTime(endStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing end query parameter. Accepted formats: RFC3339, " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	start := end.Add(-23 * time.Hour)
+	if granularity == meta.DayGranularity {
+		start = end.AddDate(0, 0, -29)
+	}
+	if startStr := c.Query("start"); startStr != "" {
+		var err error
+		start, err = parseStatisticsTime(startStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Error parsing start query parameter. Accepted formats: RFC3339, " + timestamp.DayLayout, Error: err.Error()})
+			return
+		}
+	}
+
+	if start.After(end) {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "start must be before end"})
+		return
+	}
+
+	response := EventsStatisticsResponse{Granularity: granularity, Start: start.Format(time.RFC3339), End: end.Format(time.RFC3339),
+		Tokens: []EventsCounters{}, Destinations: []EventsCounters{}}
+	for _, namespaceIds := range []struct {
+		namespace string
+		ids       []string
+		result    *[]EventsCounters
+	}{
+		{meta.TokenNamespace, tokenIds, &response.Tokens},
+		{meta.DestinationNamespace, destinationIds, &response.Destinations},
+	} {
+		for _, id := range namespaceIds.ids {
+			counts, err := counters.GetEventsCounters(namespaceIds.namespace, id, granularity, start, end)
+			if err != nil {
+				logging.Errorf("Error getting %s [%s] events counters: %v", namespaceIds.namespace, id, err)
+				c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error getting events counters", Error: err.Error()})
+				return
+			}
+
+			*namespaceIds.result = append(*namespaceIds.result, buildEventsCounters(id, counts, start, end, window))
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+//DriftHandler return event types and fields presence baselines per token_ids and the last detected schema drift anomalies
+//(of all tokens if token_ids isn't set)
+func (sh *StatisticsHandler) DriftHandler(c *gin.Context) {
+	if !drift.IsEnabled() {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Schema drift detection isn't enabled. Please configure server.statistics.drift"})
+		return
+	}
+
+	response := DriftResponse{Tokens: []TokenDrift{}}
+	tokenIds := map[string]bool{}
+	if tokenIdsStr := c.Query("token_ids"); tokenIdsStr != "" {
+		for _, tokenId := range strings.Split(tokenIdsStr, ",") {
+			tokenIds[tokenId] = true
+			response.Tokens = append(response.Tokens, TokenDrift{Id: tokenId, EventTypes: drift.GetTokenStats(tokenId)})
+		}
+	}
+	response.Anomalies = drift.GetAnomalies(tokenIds)
+
+	c.JSON(http.StatusOK, response)
+}
+
+//buildPipelineStages sum hourly counters into windows (if window > 0) and total. All stages are present with zero values
+//estimated counters (<stage>_estimated) are summed into Estimated under the stage name
+func buildPipelineStages(id string, stages []string, counts map[time.Time]map[string]int, start, end time.Time, window time.Duration) PipelineStages {
+	result := PipelineStages{Id: id, Total: emptyStages(stages), Estimated: map[string]int{}}
+
+	windowsByStart := map[time.Time]PipelineWindow{}
+	if window > 0 {
+		for windowStart := start.UTC().Truncate(window); !windowStart.After(end); windowStart = windowStart.Add(window) {
+			windowsByStart[windowStart] = PipelineWindow{Start: windowStart.Format(time.RFC3339), Stages: emptyStages(stages), Estimated: map[string]int{}}
+			result.Windows = append(result.Windows, windowsByStart[windowStart])
+		}
+	}
+
+	for hour, hourStages := range counts {
+		pipelineWindow, hasWindow := windowsByStart[hour.Truncate(window)]
+		for stage, value := range hourStages {
+			total, windowTotal := result.Total, pipelineWindow.Stages
+			if baseStage := strings.TrimSuffix(stage, counters.EstimatedSuffix); baseStage != stage {
+				stage = baseStage
+				total, windowTotal = result.Estimated, pipelineWindow.Estimated
+			}
+
+			if _, ok := result.Total[stage]; !ok {
+				continue
+			}
+			total[stage] += value
+			if hasWindow {
+				windowTotal[stage] += value
+			}
+		}
+	}
+
+	return result
+}
+
+//buildEventsCounters return all the buckets of [start, end] with all statuses (zero values if there are no counters)
+func buildEventsCounters(id string, counts map[time.Time]map[string]int, start, end time.Time, window time.Duration) EventsCounters {
+	result := EventsCounters{Id: id, Total: emptyStages(counters.EventsStatuses), Buckets: []EventsBucket{}}
+
+	for bucketStart := start.UTC().Truncate(window); !bucketStart.After(end); bucketStart = bucketStart.Add(window) {
+		bucket := EventsBucket{Start: bucketStart.Format(time.RFC3339), Events: emptyStages(counters.EventsStatuses)}
+		for status, value := range counts[bucketStart] {
+			if _, ok := bucket.Events[status]; !ok {
+				continue
+			}
+			bucket.Events[status] += value
+			result.Total[status] += value
+		}
+		result.Buckets = append(result.Buckets, bucket)
+	}
+
+	return result
+}
+
+func emptyStages(stages []string) map[string]int {
+	result := map[string]int{}
+	for _, stage := range stages {
+		result[stage] = 0
+	}
+	return result
+}
+
+func parseStatisticsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	return time.Parse(timestamp.DayLayout, value)
+}
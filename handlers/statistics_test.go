@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildPipelineStages(t *testing.T) {
+	start := time.Date(2021, 1, 1, 22, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 1, 2, 1, 30, 0, 0, time.UTC)
+	counts := map[time.Time]map[string]int{
+		start:                    {counters.StageLogged: 10, counters.StageLoaded: 8, "unknown": 1, counters.StageLoaded + counters.EstimatedSuffix: 80},
+		start.Add(time.Hour):     {counters.StageLoaded: 1, counters.StageErrored: 1},
+		start.Add(3 * time.Hour): {counters.StageFallback: 1},
+	}
+
+	total := buildPipelineStages("destination_dest1", counters.DestinationStages, counts, start, end, 0)
+	require.Empty(t, total.Windows)
+	require.Equal(t, map[string]int{"logged": 10, "uploaded": 0, "loaded": 9, "errored": 1, "fallback": 1}, total.Total)
+	require.Equal(t, map[string]int{"loaded": 80}, total.Estimated)
+
+	daily := buildPipelineStages("destination_dest1", counters.DestinationStages, counts, start, end, 24*time.Hour)
+	require.Len(t, daily.Windows, 2)
+	require.Equal(t, "2021-01-01T00:00:00Z", daily.Windows[0].Start)
+	require.Equal(t, 9, daily.Windows[0].Stages[counters.StageLoaded])
+	require.Equal(t, 80, daily.Windows[0].Estimated[counters.StageLoaded])
+	require.Empty(t, daily.Windows[1].Estimated)
+	require.Equal(t, 1, daily.Windows[1].Stages[counters.StageFallback])
+
+	hourly := buildPipelineStages("destination_dest1", counters.DestinationStages, counts, start, end, time.Hour)
+	require.Len(t, hourly.Windows, 4)
+	require.Equal(t, 1, hourly.Windows[3].Stages[counters.StageFallback])
+}
+
+func TestBuildEventsCounters(t *testing.T) {
+	start := time.Date(2021, 1, 1, 22, 30, 0, 0, time.UTC)
+	end := time.Date(2021, 1, 3, 1, 0, 0, 0, time.UTC)
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	counts := map[time.Time]map[string]int{
+		day:                  {"success": 10, "errors": 1},
+		day.AddDate(0, 0, 2): {"skip": 2, "unknown": 5},
+	}
+
+	result := buildEventsCounters("token1", counts, start, end, 24*time.Hour)
+	require.Equal(t, "token1", result.Id)
+	require.Equal(t, map[string]int{"success": 10, "skip": 2, "errors": 1}, result.Total)
+	require.Len(t, result.Buckets, 3)
+	require.Equal(t, "2021-01-01T00:00:00Z", result.Buckets[0].Start)
+	require.Equal(t, map[string]int{"success": 0, "skip": 0, "errors": 0}, result.Buckets[1].Events)
+	require.Equal(t, map[string]int{"success": 0, "skip": 2, "errors": 0}, result.Buckets[2].Events)
+}
+
+func TestUniquesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/uniques", NewStatisticsHandler().UniquesHandler)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{"ids are required", "", http.StatusBadRequest},
+		{"wrong end format", "?token_ids=token1&end=2021-01-03", http.StatusBadRequest},
+		{"wrong start format", "?token_ids=token1&start=2021-01-01", http.StatusBadRequest},
+		{"start after end", "?token_ids=token1&start=20210105&end=20210103", http.StatusBadRequest},
+		{"ok", "?token_ids=token1,token2&destination_ids=dest1&start=20210101&end=20210103", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/uniques"+tt.query, nil))
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/uniques?token_ids=token1,token2&destination_ids=dest1&start=20210101&end=20210103", nil))
+	response := &UniquesResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+	require.Len(t, response.Uniques, 3)
+	require.Equal(t, counters.TokenPrefix+"token1", response.Uniques[0].Id)
+	require.Equal(t, counters.DestinationPrefix+"dest1", response.Uniques[2].Id)
+	require.Equal(t, map[string]int{"20210101": 0, "20210102": 0, "20210103": 0}, response.Uniques[0].Daily)
+}
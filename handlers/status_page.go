@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appconfig"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	statusPageName        = "status.html"
+	defaultStatusCacheTTL = 10 * time.Second
+	statusPagePeriod      = 24 * time.Hour
+)
+
+var statusPageTemplate = template.Must(template.New("status page").Parse(`<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta http-equiv="refresh" content="30">
+<title>EventNative status</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 40px auto; max-width: 960px; color: #333; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 32px; }
+th, td { border-bottom: 1px solid #eee; padding: 6px 8px; text-align: left; }
+td.num { text-align: right; }
+.banner { padding: 16px; border-radius: 6px; margin-bottom: 32px; font-size: 18px; }
+.ok { background: #e6f7ea; color: #1e7b34; }
+.degraded { background: #fff6e0; color: #8a6100; }
+.down { background: #fdecea; color: #b3261e; }
+.muted { color: #999; font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>EventNative status</h1>
+{{if .Unauthorized}}
+<div class="banner down">Status page requires admin token: /p/status.html?token=&lt;admin_token&gt;</div>
+{{else}}
+{{if .EventsFlowing}}
+<div class="banner ok">Events are flowing: {{.LastHourReceived}} events have been received in the last hour</div>
+{{else}}
+<div class="banner degraded">No events have been received in the last hour. Please check your tracking code and tokens</div>
+{{end}}
+<h2>Health: <span class="{{.Health.Status}}">{{.Health.Status}}</span></h2>
+<table>
+<tr><th>Component</th><th>Status</th><th>Error</th></tr>
+{{range .Health.Components}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Error}}</td></tr>
+{{end}}
+</table>
+<h2>Tokens (last 24 hours)</h2>
+<table>
+<tr><th>Token</th>{{range .TokenStages}}<th>{{.}}</th>{{end}}</tr>
+{{range .Tokens}}<tr><td>{{.Id}}</td>{{range .Values}}<td class="num">{{.}}</td>{{end}}</tr>
+{{end}}
+</table>
+<h2>Destinations (last 24 hours)</h2>
+<table>
+<tr><th>Destination</th>{{range .DestinationStages}}<th>{{.}}</th>{{end}}</tr>
+{{range .Destinations}}<tr><td>{{.Id}}</td>{{range .Values}}<td class="num">{{.}}</td>{{end}}</tr>
+{{end}}
+</table>
+<div class="muted">Updated at {{.UpdatedAt}}. The page is refreshed every 30 seconds</div>
+{{end}}
+</body>
+</html>
+`))
+
+//StatusPage is a mini dashboard (/p/status.html) with health components and pipeline stages counters
+//of all tokens and destinations. Data is collected server-side and cached for cacheTTL
+//for not querying meta storage on every page refresh
+type StatusPage struct {
+	sync.Mutex
+
+	adminToken    string
+	healthHandler *HealthHandler
+	cacheTTL      time.Duration
+
+	cached   *statusPageData
+	cachedAt time.Time
+}
+
+type statusPageData struct {
+	Unauthorized      bool
+	EventsFlowing     bool
+	LastHourReceived  int
+	Health            *HealthResponse
+	TokenStages       []string
+	Tokens            []statusPageRow
+	DestinationStages []string
+	Destinations      []statusPageRow
+	UpdatedAt         string
+}
+
+type statusPageRow struct {
+	Id     string
+	Values []int
+}
+
+func NewStatusPage(adminToken string, healthHandler *HealthHandler, cacheTTL time.Duration) *StatusPage {
+	if cacheTTL <= 0 {
+		cacheTTL = defaultStatusCacheTTL
+	}
+
+	return &StatusPage{adminToken: adminToken, healthHandler: healthHandler, cacheTTL: cacheTTL}
+}
+
+//Handler render status page if admin token is provided in query parameter or in X-Admin-Token header
+func (sp *StatusPage) Handler(c *gin.Context) {
+	c.Header("Content-type", htmlContentType)
+
+	token := c.Query(middleware.TokenName)
+	if token == "" {
+		token = c.GetHeader("X-Admin-Token")
+	}
+	if sp.adminToken == "" || token != sp.adminToken {
+		c.Status(http.StatusUnauthorized)
+		if err := statusPageTemplate.Execute(c.Writer, &statusPageData{Unauthorized: true}); err != nil {
+			logging.Error("Error executing status.html template", err)
+		}
+		return
+	}
+
+	if err := statusPageTemplate.Execute(c.Writer, sp.data()); err != nil {
+		logging.Error("Error executing status.html template", err)
+	}
+}
+
+//data return cached data or collect it if cache is expired
+func (sp *StatusPage) data() *statusPageData {
+	sp.Lock()
+	defer sp.Unlock()
+
+	now := time.Now().UTC()
+	if sp.cached != nil && now.Sub(sp.cachedAt) < sp.cacheTTL {
+		return sp.cached
+	}
+
+	sp.cached = sp.collect(now)
+	sp.cachedAt = now
+	return sp.cached
+}
+
+func (sp *StatusPage) collect(now time.Time) *statusPageData {
+	data := &statusPageData{
+		Health:            sp.healthHandler.check(),
+		TokenStages:       counters.TokenStages,
+		DestinationStages: counters.DestinationStages,
+		UpdatedAt:         now.Format(time.RFC3339),
+	}
+
+	start := now.Add(-statusPagePeriod)
+	lastHour := now.Truncate(time.Hour)
+
+	var tokenIds []string
+	if appconfig.Instance != nil && appconfig.Instance.AuthorizationService != nil {
+		tokenIds = appconfig.Instance.AuthorizationService.GetAllTokenIds()
+	}
+	sort.Strings(tokenIds)
+	for _, tokenId := range tokenIds {
+		counts := sp.pipelineStages(counters.TokenPrefix+tokenId, start, now)
+		data.LastHourReceived += counts[lastHour][counters.StageReceived]
+		data.Tokens = append(data.Tokens, newStatusPageRow(tokenId, counters.TokenStages, counts))
+	}
+	data.EventsFlowing = data.LastHourReceived > 0
+
+	var destinationIds []string
+	if sp.healthHandler.destinations != nil {
+		for destinationId := range sp.healthHandler.destinations.GetAllStorages() {
+			destinationIds = append(destinationIds, destinationId)
+		}
+	}
+	sort.Strings(destinationIds)
+	for _, destinationId := range destinationIds {
+		counts := sp.pipelineStages(counters.DestinationPrefix+destinationId, start, now)
+		data.Destinations = append(data.Destinations, newStatusPageRow(destinationId, counters.DestinationStages, counts))
+	}
+
+	return data
+}
+
+func (sp *StatusPage) pipelineStages(id string, start, end time.Time) map[time.Time]map[string]int {
+	counts, err := counters.GetPipelineStages(id, start, end)
+	if err != nil {
+		logging.Errorf("Error getting [%s] pipeline stages for status page: %v", id, err)
+		return map[time.Time]map[string]int{}
+	}
+
+	return counts
+}
+
+func newStatusPageRow(id string, stages []string, counts map[time.Time]map[string]int) statusPageRow {
+	total := map[string]int{}
+	for _, hourStages := range counts {
+		for stage, value := range hourStages {
+			total[stage] += value
+		}
+	}
+
+	row := statusPageRow{Id: id}
+	for _, stage := range stages {
+		row.Values = append(row.Values, total[stage])
+	}
+
+	return row
+}
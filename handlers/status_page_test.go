@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	statusPage := NewStatusPage("admin", NewHealthHandler(&meta.Dummy{}, nil, nil), time.Minute)
+	router := gin.New()
+	router.GET("/p/:filename", NewPageHandler("", "", true, statusPage).Handler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/p/status.html", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Contains(t, w.Body.String(), "requires admin token")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/p/status.html?token=admin", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No events have been received in the last hour")
+	require.Contains(t, w.Body.String(), "meta_storage")
+
+	//data is cached
+	first := statusPage.data()
+	require.True(t, first == statusPage.data())
+
+	statusPage.cachedAt = time.Now().Add(-2 * time.Minute)
+	require.False(t, first == statusPage.data())
+}
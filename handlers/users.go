@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const userExportManifestFile = "manifest.json"
+
+//UserExportManifest describes user export archive content: rows count per destination table
+//and destinations which don't support user rows selection (e.g. S3, Google Analytics)
+type UserExportManifest struct {
+	UserId       string                    `json:"user_id"`
+	CreatedAt    string                    `json:"created_at"`
+	Columns      []string                  `json:"columns"`
+	Destinations map[string]map[string]int `json:"destinations"`
+	Skipped      []string                  `json:"skipped"`
+}
+
+type UserExportErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
+}
+
+//UsersHandler exports all rows belonging to a user from SQL destinations (data-subject access requests)
+type UsersHandler struct {
+	destinationService *destinations.Service
+	userColumns        []string
+}
+
+//NewUsersHandler return UsersHandler which searches user id in userColumns (flattened column names)
+func NewUsersHandler(destinationService *destinations.Service, userColumns []string) *UsersHandler {
+	return &UsersHandler{destinationService: destinationService, userColumns: userColumns}
+}
+
+//ExportHandler return zip archive with manifest.json and <destination id>/<table>.json files (JSON arrays of rows)
+//Optional query parameters (comma separated): destinations - limit export, columns - override configured user columns
+//If any destination fails the export isn't returned because it would be incomplete
+func (uh *UsersHandler) ExportHandler(c *gin.Context) {
+	userId := c.Param("id")
+	if userId == "" {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "id is required path parameter"})
+		return
+	}
+
+	userColumns := uh.userColumns
+	if columnsStr := c.Query("columns"); columnsStr != "" {
+		userColumns = strings.Split(columnsStr, ",")
+	}
+	if len(userColumns) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "User columns aren't configured: set users_export.columns or columns query parameter"})
+		return
+	}
+
+	storageProxies := uh.destinationService.GetAllStorages()
+	if destinationsStr := c.Query("destinations"); destinationsStr != "" {
+		requested := map[string]events.StorageProxy{}
+		for _, destinationId := range strings.Split(destinationsStr, ",") {
+			storageProxy, ok := storageProxies[destinationId]
+			if !ok {
+				c.JSON(http.StatusBadRequest, middleware.ErrorResponse{Message: "Destination [" + destinationId + "] wasn't found"})
+				return
+			}
+			requested[destinationId] = storageProxy
+		}
+		storageProxies = requested
+	}
+
+	var destinationIds []string
+	for destinationId := range storageProxies {
+		destinationIds = append(destinationIds, destinationId)
+	}
+	sort.Strings(destinationIds)
+
+	manifest := &UserExportManifest{
+		UserId:       userId,
+		CreatedAt:    timestamp.NowUTC(),
+		Columns:      userColumns,
+		Destinations: map[string]map[string]int{},
+		Skipped:      []string{},
+	}
+	exportErrors := map[string]string{}
+	destinationRows := map[string]map[string][]map[string]interface{}{}
+	for _, destinationId := range destinationIds {
+		storage, ok := storageProxies[destinationId].Get()
+		if !ok {
+			exportErrors[destinationId] = "destination hasn't been initialized yet"
+			continue
+		}
+
+		exporter, ok := storage.(storages.UserRowsExporter)
+		if !ok {
+			manifest.Skipped = append(manifest.Skipped, destinationId)
+			continue
+		}
+
+		tableRows, err := exporter.ExportUserRows(userColumns, userId)
+		if err != nil {
+			logging.Errorf("[%s] Error exporting user rows: %v", destinationId, err)
+			exportErrors[destinationId] = err.Error()
+			continue
+		}
+
+		destinationRows[destinationId] = tableRows
+		manifest.Destinations[destinationId] = map[string]int{}
+		for table, rows := range tableRows {
+			manifest.Destinations[destinationId][table] = len(rows)
+		}
+	}
+
+	if len(exportErrors) > 0 {
+		c.JSON(http.StatusInternalServerError, UserExportErrorResponse{Message: "Error exporting user data", Errors: exportErrors})
+		return
+	}
+
+	archive, err := buildUserExportArchive(manifest, destinationRows)
+	if err != nil {
+		logging.Errorf("Error building user export archive: %v", err)
+		c.JSON(http.StatusInternalServerError, middleware.ErrorResponse{Message: "Error building user export archive", Error: err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="user_data_export.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+//buildUserExportArchive return zip archive bytes with manifest and JSON file per destination table
+func buildUserExportArchive(manifest *UserExportManifest, destinationRows map[string]map[string][]map[string]interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+
+	if err := writeZipJson(zipWriter, userExportManifestFile, manifest); err != nil {
+		return nil, err
+	}
+	for destinationId, tableRows := range destinationRows {
+		for table, rows := range tableRows {
+			if err := writeZipJson(zipWriter, destinationId+"/"+table+".json", rows); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeZipJson(zipWriter *zip.Writer, name string, value interface{}) error {
+	fileWriter, err := zipWriter.Create(name)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", name, err)
+	}
+
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error marshalling %s: %v", name, err)
+	}
+
+	_, err = fileWriter.Write(b)
+	return err
+}
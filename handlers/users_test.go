@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBuildUserExportArchive(t *testing.T) {
+	manifest := &UserExportManifest{
+		UserId:       "user1",
+		CreatedAt:    "2020-10-01T12:00:00.000000Z",
+		Columns:      []string{"eventn_ctx_user_internal_id"},
+		Destinations: map[string]map[string]int{"pg": {"events": 2}},
+		Skipped:      []string{"s3"},
+	}
+	rows := map[string]map[string][]map[string]interface{}{
+		"pg": {"events": {{"eventn_ctx_event_id": "1", "eventn_ctx_user_internal_id": "user1"}, {"eventn_ctx_event_id": "2", "eventn_ctx_user_internal_id": "user1"}}},
+	}
+
+	archive, err := buildUserExportArchive(manifest, rows)
+	require.NoError(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[file.Name] = b
+	}
+	require.Len(t, files, 2)
+
+	actualManifest := &UserExportManifest{}
+	require.NoError(t, json.Unmarshal(files["manifest.json"], actualManifest))
+	require.Equal(t, manifest, actualManifest)
+
+	var actualRows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(files["pg/events.json"], &actualRows))
+	require.Equal(t, rows["pg"]["events"], actualRows)
+}
@@ -29,11 +29,11 @@ func TestPrimaryKeyRemoval(t *testing.T) {
 
 	enrichment.InitDefault()
 	dsConfig := &adapters.DataSourceConfig{Host: container.Host, Port: container.Port, Db: container.Database, Schema: container.Schema, Username: container.Username, Password: container.Password, Parameters: map[string]string{"sslmode": "disable"}}
-	pg, err := adapters.NewPostgres(ctx, dsConfig, logging.NewQueryLogger("test", nil, nil), map[string]string{})
+	pg, err := adapters.NewPostgres(ctx, dsConfig, logging.NewQueryLogger("test", nil, nil), map[string]string{}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, pg)
 
-	tableHelperWithPk := storages.NewTableHelper(pg, synchronization.NewInMemoryService([]string{}), map[string]bool{"email": true}, adapters.SchemaToPostgres)
+	tableHelperWithPk := storages.NewTableHelper(pg, synchronization.NewInMemoryService([]string{}), map[string]bool{"email": true}, adapters.SchemaToPostgres, nil)
 
 	// all events should be merged as have the same PK value
 	tableWithMerge := tableHelperWithPk.MapTableSchema(&schema.BatchHeader{
@@ -56,7 +56,7 @@ func TestPrimaryKeyRemoval(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 1, rowsUnique)
 
-	tableHelperWithoutPk := storages.NewTableHelper(pg, synchronization.NewInMemoryService([]string{}), map[string]bool{}, adapters.SchemaToPostgres)
+	tableHelperWithoutPk := storages.NewTableHelper(pg, synchronization.NewInMemoryService([]string{}), map[string]bool{}, adapters.SchemaToPostgres, nil)
 	// all events should be merged as have the same PK value
 	table := tableHelperWithoutPk.MapTableSchema(&schema.BatchHeader{
 		TableName: "users",
@@ -93,7 +93,7 @@ func TestRetrospectiveUsersRecognition(t *testing.T) {
 	require.NoError(t, err)
 
 	eventsCache := caching.NewEventsCache(metaStorage, 100)
-	destinationService, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
+	destinationService, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
 	require.NoError(t, err)
 	appconfig.Instance.ScheduleClosing(destinationService)
 
@@ -110,9 +110,10 @@ func TestRetrospectiveUsersRecognition(t *testing.T) {
 	usersRecognitionService, err := users.NewRecognitionService(metaStorage, destinationService, recognitionConfiguration, "/tmp")
 	require.NoError(t, err)
 	appconfig.Instance.ScheduleClosing(usersRecognitionService)
+	identityService, _ := users.NewIdentityService(nil, nil)
 
-	router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5),
-		sources.NewTestService(), fallback.NewTestService(), usersRecognitionService)
+	router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}), &meta.Dummy{}, eventsCache, events.NewCache(5),
+		sources.NewTestService(), fallback.NewTestService(), usersRecognitionService, identityService, nil)
 
 	server := &http.Server{
 		Addr:              httpAuthority,
@@ -8,7 +8,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 )
@@ -27,7 +26,7 @@ func NewArchiver(sourceDir, archiveDir string) *Archiver {
 
 //Archive write new archived file and delete old one
 func (a *Archiver) Archive(fileName string) error {
-	return a.ArchiveByPath(path.Join(a.sourceDir, fileName))
+	return a.ArchiveByPath(filepath.Join(a.sourceDir, fileName))
 }
 
 //ArchiveByPath write new archived file and delete old one
@@ -54,11 +53,11 @@ func (a *Archiver) ArchiveByPath(sourceFilePath string) error {
 	if len(regexResult) != 2 {
 		logging.Warnf("Archiver: can't get date from file name: %s", sourceFilePath)
 	} else {
-		outputDir = path.Join(a.archiveDir, regexResult[1])
+		outputDir = filepath.Join(a.archiveDir, regexResult[1])
 		_ = os.Mkdir(outputDir, 0744)
 	}
 
-	err = ioutil.WriteFile(path.Join(outputDir, filepath.Base(sourceFilePath)+".gz"), output.Bytes(), 0644)
+	err = ioutil.WriteFile(filepath.Join(outputDir, filepath.Base(sourceFilePath)+".gz"), output.Bytes(), 0644)
 	if err != nil {
 		return err
 	}
@@ -5,7 +5,6 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -30,7 +29,7 @@ type StatusManager struct {
 }
 
 func NewStatusManager(logFilesDir string) (*StatusManager, error) {
-	statusFilesMask := path.Join(logFilesDir, statusFileMask)
+	statusFilesMask := filepath.Join(logFilesDir, statusFileMask)
 	files, err := filepath.Glob(statusFilesMask)
 	if err != nil {
 		return nil, err
@@ -115,7 +114,7 @@ func (sm *StatusManager) CleanUp(fileName string) {
 
 	delete(sm.fileStorageTableStatuses, fileName)
 
-	os.Remove(path.Join(sm.filesDir, fileName+statusFileExtension))
+	os.Remove(filepath.Join(sm.filesDir, fileName+statusFileExtension))
 }
 
 func (sm *StatusManager) persist(fileName string, statuses map[string]map[string]*Status) {
@@ -125,7 +124,7 @@ func (sm *StatusManager) persist(fileName string, statuses map[string]map[string
 		return
 	}
 
-	filePath := path.Join(sm.filesDir, fileName+statusFileExtension)
+	filePath := filepath.Join(sm.filesDir, fileName+statusFileExtension)
 	if err := ioutil.WriteFile(filePath, b, 0644); err != nil {
 		logging.SystemErrorf("Error writing event log status file [%s]: %v", filePath, err)
 	}
@@ -1,16 +1,23 @@
 package logfiles
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/jitsucom/eventnative/appstatus"
 	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/enrichment"
+	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
-	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/storages"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -25,127 +32,399 @@ type PeriodicUploader struct {
 	archiver           *Archiver
 	statusManager      *StatusManager
 	destinationService *destinations.Service
+	priority           *events.Priority
+
+	//uploadMutex prevents concurrent periodic and forced uploads of the same files
+	uploadMutex sync.Mutex
 }
 
-func NewUploader(logEventPath, fileMask string, uploadEveryS int, destinationService *destinations.Service) (*PeriodicUploader, error) {
-	logIncomingEventPath := path.Join(logEventPath, "incoming")
-	logArchiveEventPath := path.Join(logEventPath, "archive")
+//NewUploader return PeriodicUploader. priority might be nil (files are uploaded in name order)
+func NewUploader(logEventPath, fileMask string, uploadEveryS int, destinationService *destinations.Service, priority *events.Priority) (*PeriodicUploader, error) {
+	logIncomingEventPath := filepath.Join(logEventPath, "incoming")
+	logArchiveEventPath := filepath.Join(logEventPath, "archive")
 	statusManager, err := NewStatusManager(logIncomingEventPath)
 	if err != nil {
 		return nil, err
 	}
 	return &PeriodicUploader{
 		logIncomingEventPath: logIncomingEventPath,
-		fileMask:             path.Join(logIncomingEventPath, fileMask),
+		fileMask:             filepath.Join(logIncomingEventPath, fileMask),
 		uploadEvery:          time.Duration(uploadEveryS) * time.Second,
 		archiver:             NewArchiver(logIncomingEventPath, logArchiveEventPath),
 		statusManager:        statusManager,
 		destinationService:   destinationService,
+		priority:             priority,
 	}, nil
 }
 
-//Start reading event logger log directory and finding already rotated and closed files by mask
+//Start schedule reading event logger log directory and finding already rotated and closed files by mask
 //pass them to storages according to tokens
 //keep uploading log statuses file for every event log file
 func (u *PeriodicUploader) Start() {
-	safego.RunWithRestart(func() {
-		for {
-			if appstatus.Instance.Idle {
-				break
+	job := scheduler.Add("uploader", scheduler.Every(u.uploadEvery), u.upload)
+	job.RunNow()
+}
+
+//logFile is a rotated log file with events of the token
+type logFile struct {
+	name    string
+	path    string
+	tokenId string
+	payload []byte
+	rows    int
+	//sampleWeight is an average count of original events which a file event represents (1 if token events aren't sampled)
+	sampleWeight float64
+	//priority is true if the file is from priority token or has priority events
+	priority bool
+}
+
+//destinationFiles are log files which must be uploaded into the destination
+type destinationFiles struct {
+	storageProxy events.StorageProxy
+	files        []*logFile
+}
+
+//destinationFlush are not uploaded files of the destination which must be flushed divided into priority and regular ones
+type destinationFlush struct {
+	storageProxy events.StorageProxy
+	batchPolicy  *storages.BatchPolicy
+	priority     []*logFile
+	regular      []*logFile
+}
+
+//Flush upload all rotated log files into destinations right away regardless of destinations batch flush policies
+//return error if some files haven't been uploaded (e.g. on server drain)
+func (u *PeriodicUploader) Flush() error {
+	if err := u.uploadFiles(true); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(u.fileMask)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%d log files haven't been uploaded", len(files))
+	}
+
+	return nil
+}
+
+func (u *PeriodicUploader) upload() error {
+	return u.uploadFiles(false)
+}
+
+//uploadFiles upload all rotated log files into destinations according to destinations batch flush policies
+//if force is true - batch flush policies are ignored
+func (u *PeriodicUploader) uploadFiles(force bool) error {
+	u.uploadMutex.Lock()
+	defer u.uploadMutex.Unlock()
+
+	//wait for destinations reloading
+	for destinations.StatusInstance.Reloading {
+		if appstatus.Instance.Idle {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	if appstatus.Instance.Idle {
+		return nil
+	}
+
+	files, err := filepath.Glob(u.fileMask)
+	if err != nil {
+		logging.SystemErrorf("Error finding files by %s mask: %v", u.fileMask, err)
+		return err
+	}
+
+	existingFiles := map[string]bool{}
+	//flag for archiving file if all storages don't have errors while storing this file
+	archiveFiles := map[string]bool{}
+	var orderedFiles []*logFile
+	filesPerDestination := map[string]*destinationFiles{}
+	for _, filePath := range files {
+		fileName := filepath.Base(filePath)
+
+		b, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			logging.SystemErrorf("Error reading file [%s] with events: %v", filePath, err)
+			continue
+		}
+		if len(b) == 0 {
+			os.Remove(filePath)
+			continue
+		}
+		existingFiles[fileName] = true
+		//get token from filename
+		regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
+		if len(regexResult) != 2 {
+			logging.SystemErrorf("Error processing file %s. Malformed name", filePath)
+			continue
+		}
+
+		tokenId := regexResult[1]
+		storageProxies := u.destinationService.GetStorages(tokenId)
+		if len(storageProxies) == 0 {
+			logging.Warnf("Destination storages weren't found for file [%s] and token [%s]", filePath, tokenId)
+			continue
+		}
+
+		file := &logFile{name: fileName, path: filePath, tokenId: tokenId, payload: b, rows: bytes.Count(b, []byte("\n")),
+			sampleWeight: fileSampleWeight(b, tokenId), priority: u.priority.MatchPayload(b, tokenId)}
+		orderedFiles = append(orderedFiles, file)
+		archiveFiles[fileName] = true
+		for _, storageProxy := range storageProxies {
+			storage, ok := storageProxy.Get()
+			if !ok {
+				archiveFiles[fileName] = false
+				continue
+			}
+
+			df, ok := filesPerDestination[storage.Name()]
+			if !ok {
+				df = &destinationFiles{storageProxy: storageProxy}
+				filesPerDestination[storage.Name()] = df
 			}
+			df.files = append(df.files, file)
+		}
+	}
 
-			if destinations.StatusInstance.Reloading {
-				time.Sleep(2 * time.Second)
+	var destinationNames []string
+	for name := range filesPerDestination {
+		destinationNames = append(destinationNames, name)
+	}
+	sort.Strings(destinationNames)
+
+	//files are kept until the destination flush
+	var flushes []*destinationFlush
+	now := time.Now()
+	for _, name := range destinationNames {
+		df := filesPerDestination[name]
+		batchPolicy := storages.GetBatchPolicy(df.storageProxy)
+		batchPolicy.Retain(existingFiles)
+
+		flush := &destinationFlush{storageProxy: df.storageProxy, batchPolicy: batchPolicy}
+		pendingRows, pendingBytes := 0, int64(0)
+		for _, file := range df.files {
+			if batchPolicy.IsUploaded(file.name) {
 				continue
 			}
+			if file.priority {
+				flush.priority = append(flush.priority, file)
+			} else {
+				flush.regular = append(flush.regular, file)
+			}
+			pendingRows += file.rows
+			pendingBytes += int64(len(file.payload))
+		}
+		if len(flush.priority) == 0 && len(flush.regular) == 0 {
+			continue
+		}
+
+		if !force && !batchPolicy.ShouldFlush(pendingRows, pendingBytes, now) {
+			for _, file := range append(flush.priority, flush.regular...) {
+				archiveFiles[file.name] = false
+			}
+			continue
+		}
+		flushes = append(flushes, flush)
+	}
+
+	//priority files of all destinations are uploaded ahead of regular ones
+	for _, flush := range flushes {
+		for fileName := range u.uploadDestinationFiles(flush.storageProxy, flush.batchPolicy, flush.priority) {
+			archiveFiles[fileName] = false
+		}
+	}
+	for _, flush := range flushes {
+		for fileName := range u.uploadDestinationFiles(flush.storageProxy, flush.batchPolicy, flush.regular) {
+			archiveFiles[fileName] = false
+		}
+		flush.batchPolicy.Flushed(now)
+	}
 
-			files, err := filepath.Glob(u.fileMask)
+	for _, file := range orderedFiles {
+		if archiveFiles[file.name] {
+			err := u.archiver.Archive(file.name)
 			if err != nil {
-				logging.SystemErrorf("Error finding files by %s mask: %v", u.fileMask, err)
-				return
+				logging.SystemErrorf("Error archiving [%s] file: %v", file.path, err)
+			} else {
+				u.statusManager.CleanUp(file.name)
 			}
+		}
+	}
 
-			for _, filePath := range files {
-				fileName := filepath.Base(filePath)
+	return nil
+}
 
-				b, err := ioutil.ReadFile(filePath)
-				if err != nil {
-					logging.SystemErrorf("Error reading file [%s] with events: %v", filePath, err)
-					continue
-				}
-				if len(b) == 0 {
-					os.Remove(filePath)
-					continue
-				}
-				//get token from filename
-				regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName)
-				if len(regexResult) != 2 {
-					logging.SystemErrorf("Error processing file %s. Malformed name", filePath)
-					continue
+//uploadDestinationFiles upload files into the destination by batch policy workers concurrently
+//workers share the destination TableHelper: it is safe because cached table schemas are replaced with patched copies
+//under the TableHelper lock and aren't changed in place
+//return names of files which haven't been uploaded
+func (u *PeriodicUploader) uploadDestinationFiles(storageProxy events.StorageProxy, batchPolicy *storages.BatchPolicy, files []*logFile) map[string]bool {
+	mutex := sync.Mutex{}
+	failed := map[string]bool{}
+	filesCh := make(chan *logFile)
+	wg := sync.WaitGroup{}
+	for i := 0; i < batchPolicy.Workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range filesCh {
+				if u.uploadFile(storageProxy, file) {
+					batchPolicy.MarkUploaded(file.name)
+				} else {
+					mutex.Lock()
+					failed[file.name] = true
+					mutex.Unlock()
 				}
+			}
+		}()
+	}
 
-				tokenId := regexResult[1]
-				storageProxies := u.destinationService.GetStorages(tokenId)
-				if len(storageProxies) == 0 {
-					logging.Warnf("Destination storages weren't found for file [%s] and token [%s]", filePath, tokenId)
-					continue
-				}
+	for _, file := range files {
+		filesCh <- file
+	}
+	close(filesCh)
+	wg.Wait()
 
-				//flag for archiving file if all storages don't have errors while storing this file
-				archiveFile := true
-				for _, storageProxy := range storageProxies {
-					storage, ok := storageProxy.Get()
-					if !ok {
-						archiveFile = false
-						continue
-					}
-
-					alreadyUploadedTables := map[string]bool{}
-					tableStatuses := u.statusManager.GetTablesStatuses(fileName, storage.Name())
-					for tableName, status := range tableStatuses {
-						if status.Uploaded {
-							alreadyUploadedTables[tableName] = true
-						}
-					}
-
-					resultPerTable, errRowsCount, err := storage.Store(fileName, b, alreadyUploadedTables)
-					if errRowsCount > 0 {
-						metrics.ErrorTokenEvents(tokenId, storage.Name(), errRowsCount)
-						counters.ErrorEvents(storage.Name(), errRowsCount)
-					}
-
-					if err != nil {
-						archiveFile = false
-						logging.Errorf("[%s] Error storing file %s in destination: %v", storage.Name(), filePath, err)
-						continue
-					}
-
-					for tableName, result := range resultPerTable {
-						if result.Err != nil {
-							archiveFile = false
-							logging.Errorf("[%s] Error storing table %s from file %s: %v", storage.Name(), tableName, filePath, result.Err)
-							metrics.ErrorTokenEvents(tokenId, storage.Name(), result.RowsCount)
-							counters.ErrorEvents(storage.Name(), result.RowsCount)
-						} else {
-							metrics.SuccessTokenEvents(tokenId, storage.Name(), result.RowsCount)
-							counters.SuccessEvents(storage.Name(), result.RowsCount)
-						}
-
-						u.statusManager.UpdateStatus(fileName, storage.Name(), tableName, result.Err)
-					}
-				}
+	return failed
+}
 
-				if archiveFile {
-					err := u.archiver.Archive(fileName)
-					if err != nil {
-						logging.SystemErrorf("Error archiving [%s] file: %v", filePath, err)
-					} else {
-						u.statusManager.CleanUp(fileName)
-					}
-				}
+//uploadFile store log file into the destination and update file tables statuses
+//return false if the file must be uploaded again
+func (u *PeriodicUploader) uploadFile(storageProxy events.StorageProxy, file *logFile) bool {
+	storage, ok := storageProxy.Get()
+	if !ok {
+		return false
+	}
+
+	//destination is unhealthy: file will be uploaded after circuit breaker open timeout
+	circuitBreaker := storages.GetCircuitBreaker(storageProxy)
+	if !circuitBreaker.Allow() {
+		return false
+	}
+
+	//destination mode has been changed: upload only events which haven't been streamed
+	payload := file.payload
+	window, ok := u.destinationService.GetUploadWindow(storage.Name())
+	if !ok {
+		return true
+	}
+	if !window.IsUnbounded() {
+		var skipped int
+		payload, skipped = filterByUploadWindow(file.payload, window)
+		if skipped > 0 {
+			logging.Infof("[%s] %d events from file %s have been skipped because they were received in the other destination mode", storage.Name(), skipped, file.path)
+		}
+		if len(payload) == 0 {
+			return true
+		}
+	}
+
+	alreadyUploadedTables := map[string]bool{}
+	tableStatuses := u.statusManager.GetTablesStatuses(file.name, storage.Name())
+	for tableName, status := range tableStatuses {
+		if status.Uploaded {
+			alreadyUploadedTables[tableName] = true
+		}
+	}
+
+	start := time.Now()
+	resultPerTable, errRowsCount, err := storage.Store(file.name, payload, alreadyUploadedTables)
+	uploadedRows := errRowsCount
+	for _, result := range resultPerTable {
+		uploadedRows += result.RowsCount
+	}
+	counters.DestinationStage(storage.Name(), counters.StageUploaded, uploadedRows)
+	counters.DestinationStageEstimated(storage.Name(), counters.StageUploaded, float64(uploadedRows)*file.sampleWeight)
+	metrics.DestinationInsert(storage.Name(), storages.BatchMode, time.Since(start))
+	if errRowsCount > 0 {
+		metrics.ErrorTokenEvents(file.tokenId, storage.Name(), errRowsCount)
+		counters.ErrorEvents(storage.Name(), file.tokenId, errRowsCount)
+		counters.DestinationStageEstimated(storage.Name(), counters.StageErrored, float64(errRowsCount)*file.sampleWeight)
+	}
+
+	if err != nil {
+		circuitBreaker.Failure()
+		metrics.DestinationInsertError(storage.Name(), storages.BatchMode)
+		logging.Errorf("[%s] Error storing file %s in destination: %v", storage.Name(), file.path, err)
+		return false
+	}
+	circuitBreaker.Success()
+
+	uploaded := true
+	for tableName, result := range resultPerTable {
+		if result.Err != nil {
+			uploaded = false
+			metrics.DestinationInsertError(storage.Name(), storages.BatchMode)
+			logging.Errorf("[%s] Error storing table %s from file %s: %v", storage.Name(), tableName, file.path, result.Err)
+			metrics.ErrorTokenEvents(file.tokenId, storage.Name(), result.RowsCount)
+			counters.ErrorEvents(storage.Name(), file.tokenId, result.RowsCount)
+			counters.DestinationStageEstimated(storage.Name(), counters.StageErrored, float64(result.RowsCount)*file.sampleWeight)
+		} else {
+			metrics.DestinationBatchSize(storage.Name(), storages.BatchMode, result.RowsCount)
+			metrics.SuccessTokenEvents(file.tokenId, storage.Name(), result.RowsCount)
+			counters.SuccessEvents(storage.Name(), file.tokenId, result.RowsCount)
+			counters.DestinationStageEstimated(storage.Name(), counters.StageLoaded, float64(result.RowsCount)*file.sampleWeight)
+		}
+
+		u.statusManager.UpdateStatus(file.name, storage.Name(), tableName, result.Err)
+	}
+
+	return uploaded
+}
+
+//filterByUploadWindow return payload lines which _timestamp is in the window and skipped lines count
+//lines without valid _timestamp are kept
+func filterByUploadWindow(payload []byte, window *destinations.UploadWindow) ([]byte, int) {
+	filtered := bytes.Buffer{}
+	skipped := 0
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		event := struct {
+			Timestamp string `json:"_timestamp"`
+		}{}
+		if err := json.Unmarshal(line, &event); err == nil && event.Timestamp != "" {
+			if t, err := time.Parse(time.RFC3339Nano, event.Timestamp); err == nil && !window.Contains(t) {
+				skipped++
+				continue
 			}
+		}
+
+		filtered.Write(line)
+		filtered.WriteByte('\n')
+	}
+
+	return filtered.Bytes(), skipped
+}
 
-			time.Sleep(u.uploadEvery)
+//fileSampleWeight return average sample weight of file events (see enrichment.SampleWeight)
+//file isn't parsed if token events aren't sampled
+func fileSampleWeight(payload []byte, tokenId string) float64 {
+	if !enrichment.IsSampled(tokenId) {
+		return 1
+	}
+
+	var total float64
+	var count int
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		event := map[string]interface{}{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
 		}
-	})
+		total += enrichment.SampleWeight(event, tokenId)
+		count++
+	}
+
+	if count == 0 {
+		return 1
+	}
+	return total / float64(count)
 }
@@ -0,0 +1,27 @@
+package logfiles
+
+import (
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFilterByUploadWindow(t *testing.T) {
+	payload := []byte(`{"_timestamp":"2021-01-01T11:59:59.000000Z","id":1}
+{"_timestamp":"2021-01-01T12:00:00.000000Z","id":2}
+{"id":3}
+`)
+	window := &destinations.UploadWindow{From: time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	filtered, skipped := filterByUploadWindow(payload, window)
+	require.Equal(t, 1, skipped)
+	require.Equal(t, `{"_timestamp":"2021-01-01T12:00:00.000000Z","id":2}
+{"id":3}
+`, string(filtered))
+
+	window = &destinations.UploadWindow{To: time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC)}
+	filtered, skipped = filterByUploadWindow(payload, window)
+	require.Equal(t, 2, skipped)
+	require.Equal(t, "{\"id\":3}\n", string(filtered))
+}
@@ -17,6 +17,11 @@ type AsyncLogger struct {
 	closed bool
 }
 
+//rotateRequest is put into the logger channel for rotating the writer after all previously consumed events are written
+type rotateRequest struct {
+	done chan error
+}
+
 //Create AsyncLogger and run goroutine that's read from channel and write to file
 func NewAsyncLogger(writer io.WriteCloser, showInGlobalLogger bool) *AsyncLogger {
 	logger := &AsyncLogger{writer: writer, logCh: make(chan interface{}, 20000), showInGlobalLogger: showInGlobalLogger}
@@ -28,6 +33,11 @@ func NewAsyncLogger(writer io.WriteCloser, showInGlobalLogger bool) *AsyncLogger
 			}
 
 			event := <-logger.logCh
+			if request, ok := event.(*rotateRequest); ok {
+				request.done <- logger.rotateWriter()
+				continue
+			}
+
 			bts, err := json.Marshal(event)
 			if err != nil {
 				Errorf("Error marshaling event to json: %v", err)
@@ -62,6 +72,27 @@ func (al *AsyncLogger) ConsumeAny(object interface{}) {
 	al.logCh <- object
 }
 
+//Rotate wait until all consumed events are written and rotate the writer (if it supports rotation)
+//it is used for flushing current log files to the uploader (e.g. on server drain)
+func (al *AsyncLogger) Rotate() error {
+	if al.closed {
+		return nil
+	}
+
+	request := &rotateRequest{done: make(chan error, 1)}
+	al.logCh <- request
+	return <-request.done
+}
+
+func (al *AsyncLogger) rotateWriter() error {
+	rotator, ok := al.writer.(interface{ Rotate() error })
+	if !ok {
+		return nil
+	}
+
+	return rotator.Rotate()
+}
+
 //Close underlying log file writer
 func (al *AsyncLogger) Close() (resultErr error) {
 	al.closed = true
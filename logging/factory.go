@@ -2,7 +2,7 @@ package logging
 
 import (
 	"io"
-	"path"
+	"path/filepath"
 	"time"
 )
 
@@ -28,7 +28,7 @@ func NewFactory(logEventPath string, logRotationMin int64, showInServer bool, dd
 func (f *Factory) CreateIncomingLogger(tokenId string) *AsyncLogger {
 	eventLogWriter := NewRollingWriter(Config{
 		FileName:      "incoming.tok=" + tokenId,
-		FileDir:       path.Join(f.logEventPath, "incoming"),
+		FileDir:       filepath.Join(f.logEventPath, "incoming"),
 		RotationMin:   f.logRotationMin,
 		RotateOnClose: true,
 	})
@@ -39,7 +39,7 @@ func (f *Factory) CreateIncomingLogger(tokenId string) *AsyncLogger {
 func (f *Factory) CreateFailedLogger(destinationName string) *AsyncLogger {
 	return NewAsyncLogger(NewRollingWriter(Config{
 		FileName:      "failed.dst=" + destinationName,
-		FileDir:       path.Join(f.logEventPath, "failed"),
+		FileDir:       filepath.Join(f.logEventPath, "failed"),
 		RotationMin:   f.logRotationMin,
 		RotateOnClose: true,
 	}), false)
@@ -52,7 +52,7 @@ func (f *Factory) CreateSQLQueryLogger(destinationName string) *QueryLogger {
 func (f *Factory) CreateStreamingArchiveLogger(destinationName string) *AsyncLogger {
 	return NewAsyncLogger(NewRollingWriter(Config{
 		FileName:      "streaming-archive.dst=" + destinationName,
-		FileDir:       path.Join(f.logEventPath, "archive", time.Now().UTC().Format("2006-01-02")),
+		FileDir:       filepath.Join(f.logEventPath, "archive", time.Now().UTC().Format("2006-01-02")),
 		RotationMin:   f.logRotationMin,
 		RotateOnClose: true,
 		Compress:      true,
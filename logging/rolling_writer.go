@@ -50,10 +50,8 @@ func NewRollingWriter(config Config) io.WriteCloser {
 	safego.RunWithRestart(func() {
 		for {
 			<-ticker.C
-			if atomic.SwapUint64(&rwp.records, 0) > 0 {
-				if err := lWriter.Rotate(); err != nil {
-					log.Errorf("Error rotating log file [%s]: %v", config.FileName, err)
-				}
+			if err := rwp.Rotate(); err != nil {
+				log.Errorf("Error rotating log file [%s]: %v", config.FileName, err)
 			}
 		}
 	})
@@ -66,6 +64,15 @@ func (rwp *RollingWriterProxy) Write(p []byte) (int, error) {
 	return rwp.lWriter.Write(p)
 }
 
+//Rotate current log file if it isn't empty
+func (rwp *RollingWriterProxy) Rotate() error {
+	if atomic.SwapUint64(&rwp.records, 0) == 0 {
+		return nil
+	}
+
+	return rwp.lWriter.Rotate()
+}
+
 func (rwp *RollingWriterProxy) Close() error {
 	if rwp.rotateOnClose {
 		if err := rwp.lWriter.Rotate(); err != nil {
@@ -3,11 +3,11 @@ package logging
 import (
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 )
 
 func IsDirWritable(dir string) bool {
-	testFile := path.Join(dir, "tmp_test")
+	testFile := filepath.Join(dir, "tmp_test")
 	err := ioutil.WriteFile(testFile, []byte{}, 0644)
 	if err != nil {
 		return false
@@ -4,34 +4,38 @@ import (
 	"bytes"
 	"context"
 	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/appstatus"
-	"github.com/jitsucom/eventnative/caching"
-	"github.com/jitsucom/eventnative/counters"
-	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/audit"
+	"github.com/jitsucom/eventnative/cli"
+	"github.com/jitsucom/eventnative/drain"
+	"github.com/jitsucom/eventnative/engine"
 	"github.com/jitsucom/eventnative/enrichment"
-	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/fallback"
 	"github.com/jitsucom/eventnative/logfiles"
 	"github.com/jitsucom/eventnative/logging"
-	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/mqtt"
 	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/overload"
 	"github.com/jitsucom/eventnative/routers"
 	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/secrets"
 	"github.com/jitsucom/eventnative/sources"
-	"github.com/jitsucom/eventnative/storages"
-	"github.com/jitsucom/eventnative/synchronization"
+	"github.com/jitsucom/eventnative/state"
 	"github.com/jitsucom/eventnative/telemetry"
-	"github.com/jitsucom/eventnative/users"
 	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -44,13 +48,15 @@ const (
 	uploaderFileMask   = "incoming.tok=*-20*.log"
 	uploaderLoadEveryS = 60
 
-	destinationsKey = "destinations"
-	sourcesKey      = "sources"
+	sourcesKey = "sources"
 )
 
 var (
 	configFilePath   = flag.String("cfg", "", "config file path")
 	containerizedRun = flag.Bool("cr", false, "containerised run marker")
+	validateRun      = flag.Bool("validate", false, "validate config (test destinations connections, create sources and load tokens), print report and exit")
+	validateFormat   = flag.String("validate-format", "text", "validation report format: text or json")
+	preflightRun     = flag.Bool("preflight", false, "run preflight checks (config, meta storage, destinations connections, directories permissions) and exit with non-zero code on problems")
 
 	//ldflags
 	commit  string
@@ -81,8 +87,32 @@ func readInViperConfig() error {
 	return nil
 }
 
+//resolveSecrets replace secret references (e.g. vault://secret/data/ch#password or awssm://ch#password)
+//in application config with secret values
+func resolveSecrets() error {
+	secretsConfig := &secrets.Config{}
+	if err := viper.UnmarshalKey("secrets", secretsConfig); err != nil {
+		return fmt.Errorf("Error parsing 'secrets' config: %v", err)
+	}
+	if err := secrets.Init(secretsConfig); err != nil {
+		return err
+	}
+
+	resolved, err := secrets.ResolveValues(viper.AllSettings())
+	if err != nil {
+		return err
+	}
+
+	return viper.MergeConfigMap(resolved.(map[string]interface{}))
+}
+
 //go:generate easyjson -all useragent/resolver.go telemetry/models.go
 func main() {
+	//operational commands e.g. eventnative replay or eventnative tokens rotate
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	//Setup seed for globalRand
 	rand.Seed(time.Now().Unix())
 
@@ -96,6 +126,20 @@ func main() {
 		logging.Fatal("Error while reading application config: ", err)
 	}
 
+	if err := resolveSecrets(); err != nil {
+		logging.Fatal("Error while resolving application config secrets: ", err)
+	}
+
+	//dry run: exit code is non-zero if config has problems
+	if *validateRun {
+		os.Exit(cli.Validate(os.Stdout, *validateFormat))
+	}
+
+	//container entrypoint checks before the server start
+	if *preflightRun {
+		os.Exit(cli.Preflight(os.Stdout, *validateFormat, *containerizedRun))
+	}
+
 	//parse EN version
 	parsed := appconfig.VersionRegex.FindStringSubmatch(tag)
 	if len(parsed) == 4 {
@@ -115,135 +159,95 @@ func main() {
 		logging.Error("panic")
 		logging.Error(value)
 		logging.Error(string(debug.Stack()))
-		notifications.SystemErrorf("Panic:\n%s\n%s", value, string(debug.Stack()))
+		notifications.Panicf("%s\n%s", value, string(debug.Stack()))
 	}
 
 	telemetry.Init(commit, tag, builtAt, viper.GetBool("server.telemetry.disabled.usage"))
 	metrics.Init(viper.GetBool("server.metrics.prometheus.enabled"))
 
-	slackNotificationsWebHook := viper.GetString("notifications.slack.url")
-	if slackNotificationsWebHook != "" {
-		notifications.Init(notifications.ServiceName, slackNotificationsWebHook, appconfig.Instance.ServerName, logging.Errorf)
+	if viper.IsSet("notifications") {
+		notificationsConfig := &notifications.Config{}
+		if err := viper.UnmarshalKey("notifications", notificationsConfig); err != nil {
+			logging.Fatal("Error parsing 'notifications' config:", err)
+		}
+		if err := notifications.Init(notifications.ServiceName, appconfig.Instance.ServerName, notificationsConfig, logging.Errorf); err != nil {
+			logging.Fatal(err)
+		}
+	}
+
+	//background jobs schedules overrides
+	if viper.IsSet("scheduler") {
+		schedulerConfig := &scheduler.Config{}
+		if err := viper.UnmarshalKey("scheduler", schedulerConfig); err != nil {
+			logging.Fatal("Error parsing 'scheduler' config:", err)
+		}
+		if err := scheduler.Init(schedulerConfig); err != nil {
+			logging.Fatal(err)
+		}
 	}
 
-	//listen to shutdown signal to free up all resources
 	ctx, cancel := context.WithCancel(context.Background())
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL, syscall.SIGHUP)
-	go func() {
-		<-c
+	//drainServiceValue keeps *drain.Service which is created after the ingestion pipeline (shutdown might be called before)
+	var drainServiceValue atomic.Value
+	//free up all resources
+	shutdown := func() {
 		logging.Info("* Service is shutting down.. *")
 		telemetry.ServerStop()
 		appstatus.Instance.Idle = true
 		cancel()
+		scheduler.Close()
 		appconfig.Instance.Close()
 		telemetry.Flush()
 		notifications.Close()
-		time.Sleep(3 * time.Second)
+		//drained server has already delivered all the events
+		if drainService, ok := drainServiceValue.Load().(*drain.Service); !ok || !drainService.IsDrained() {
+			time.Sleep(3 * time.Second)
+		}
 		telemetry.Close()
+	}
+
+	//listen to shutdown signal
+	//on Windows console close, logoff and shutdown events are delivered as SIGTERM
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL, syscall.SIGHUP)
+	go func() {
+		<-c
+		shutdown()
 		os.Exit(0)
 	}()
 
+	//handle Windows Service Control Manager stop requests if the process is run as a Windows service
+	if err := startServiceHandler(shutdown); err != nil {
+		logging.Fatal("Error starting Windows service handler:", err)
+	}
+
 	//Get logger configuration
 	logEventPath := viper.GetString("log.path")
 	//check if log.path is writable
 	if !logging.IsDirWritable(logEventPath) {
 		logging.Fatal("log.path:", logEventPath, "must be writable! Since EventNative docker user and owner of mounted dir are different: Please use 'chmod 777 your_mount_dir'")
 	}
-	logRotationMin := viper.GetInt64("log.rotation_min")
-
-	loggerFactory := logging.NewFactory(logEventPath, logRotationMin, viper.GetBool("log.show_in_server"),
-		appconfig.Instance.DDLLogsWriter, appconfig.Instance.QueryLogsWriter)
 
-	//synchronization service
-	syncService, err := synchronization.NewService(
-		ctx,
-		appconfig.Instance.ServerName,
-		viper.GetString("synchronization_service.type"),
-		viper.GetString("synchronization_service.endpoint"),
-		viper.GetUint("synchronization_service.connection_timeout_seconds"))
-	if err != nil {
-		logging.Fatal("Failed to initiate synchronization service", err)
-	}
-
-	// ** Destinations **
-
-	//destinations config
-	destinationsViper := viper.Sub(destinationsKey)
-	destinationsStr := viper.GetString(destinationsKey)
-
-	//override with config from os env
-	destinationsJsonConfig := viper.GetString("destinations_json")
-	if destinationsJsonConfig != "" && destinationsJsonConfig != "{}" {
-		envJsonViper := viper.New()
-		envJsonViper.SetConfigType("json")
-		if err := envJsonViper.ReadConfig(bytes.NewBufferString(destinationsJsonConfig)); err != nil {
-			logging.Error("Error reading/parsing json config from DESTINATIONS_JSON", err)
-		} else {
-			destinationsViper = envJsonViper.Sub(destinationsKey)
-			destinationsStr = envJsonViper.GetString(destinationsKey)
-		}
-	}
-
-	//meta storage config
-	metaStorageViper := viper.Sub("meta.storage")
-
-	//override with config from os env
-	metaStorageJsonConfig := viper.GetString("meta_storage_json")
-	if metaStorageJsonConfig != "" && metaStorageJsonConfig != "{}" {
-		envJsonViper := viper.New()
-		envJsonViper.SetConfigType("json")
-		if err := envJsonViper.ReadConfig(bytes.NewBufferString(metaStorageJsonConfig)); err != nil {
-			logging.Error("Error reading/parsing json config from META_STORAGE_JSON", err)
-		} else {
-			metaStorageViper = envJsonViper.Sub("meta_storage")
-		}
-	}
-
-	//meta storage
-	metaStorage, err := meta.NewStorage(metaStorageViper)
-	if err != nil {
-		logging.Fatalf("Error initializing meta storage: %v", err)
-	}
-	//close after all for saving last task statuses
-	defer metaStorage.Close()
-
-	//events counters
-	counters.InitEvents(metaStorage)
-
-	//events cache
-	eventsCacheSize := viper.GetInt("server.cache.events.size")
-	eventsCache := caching.NewEventsCache(metaStorage, eventsCacheSize)
-	appconfig.Instance.ScheduleClosing(eventsCache)
-
-	//Deprecated
-	inMemoryEventsCache := events.NewCache(eventsCacheSize)
-	appconfig.Instance.ScheduleClosing(inMemoryEventsCache)
-
-	//Create event destinations
-	destinationsService, err := destinations.NewService(ctx, destinationsViper, destinationsStr, logEventPath, syncService, eventsCache, loggerFactory, storages.Create)
+	//events ingestion pipeline: meta storage, events caches, destinations and users services
+	eventsEngine, err := engine.New(ctx, nil)
 	if err != nil {
 		logging.Fatal(err)
 	}
-	appconfig.Instance.ScheduleClosing(destinationsService)
-
-	// ** Retrospective users recognition
-	var recognitionConfiguration *storages.UsersRecognition
-	if viper.IsSet("users_recognition") {
-		recognitionConfiguration = &storages.UsersRecognition{
-			Enabled:         viper.GetBool("users_recognition.enabled"),
-			AnonymousIdNode: viper.GetString("users_recognition.anonymous_id_node"),
-			UserIdNode:      viper.GetString("users_recognition.user_id_node"),
+	metaStorage := eventsEngine.MetaStorage()
+	destinationsService := eventsEngine.Destinations()
+	syncService := eventsEngine.SyncService()
+
+	//audit log of admin API calls
+	if viper.IsSet("audit") {
+		auditConfig := &audit.Config{}
+		if err := viper.UnmarshalKey("audit", auditConfig); err != nil {
+			logging.Fatal("Error parsing audit config:", err)
 		}
-	} else {
-		logging.Warnf("Global users recognition isn't configured")
-	}
-
-	usersRecognitionService, err := users.NewRecognitionService(metaStorage, destinationsService, recognitionConfiguration, logEventPath)
-	if err != nil {
-		logging.Fatal(err)
+		if auditConfig.Path == "" {
+			logging.Fatal("audit.path is required parameter")
+		}
+		appconfig.Instance.ScheduleClosing(audit.Init(appconfig.Instance.ServerName, auditConfig, destinationsService.GetStorageById))
 	}
-	appconfig.Instance.ScheduleClosing(usersRecognitionService)
 
 	// ** Sources **
 
@@ -266,19 +270,30 @@ func main() {
 	poolSize := viper.GetInt("server.sync_tasks.pool.size")
 
 	//Create sources
-	sourceService, err := sources.NewService(ctx, sourcesViper, destinationsService, metaStorage, syncService, poolSize)
+	sourceService, err := sources.NewService(ctx, sourcesViper, destinationsService, metaStorage, syncService, syncService, appconfig.Instance.ServerName, filepath.Join(logEventPath, "preview"), poolSize)
 	if err != nil {
 		logging.Fatal(err)
 	}
 	appconfig.Instance.ScheduleClosing(sourceService)
 
+	//declarative state (tokens, destinations, sources) saved via API overrides configuration
+	stateService, err := state.Init(metaStorage, appconfig.Instance.AuthorizationService, destinationsService, sourceService)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	appconfig.Instance.ScheduleClosing(stateService)
+
 	//Uploader must read event logger directory
-	uploader, err := logfiles.NewUploader(logEventPath, uploaderFileMask, uploaderLoadEveryS, destinationsService)
+	uploader, err := logfiles.NewUploader(logEventPath, uploaderFileMask, uploaderLoadEveryS, destinationsService, appconfig.Instance.Priority)
 	if err != nil {
 		logging.Fatal("Error while creating file uploader", err)
 	}
 	uploader.Start()
 
+	//graceful drain before termination (see /api/v1/admin/drain)
+	drainService := drain.NewService(destinationsService, uploader, sourceService, time.Duration(viper.GetInt("server.drain_timeout_sec"))*time.Second)
+	drainServiceValue.Store(drainService)
+
 	adminToken := viper.GetString("server.admin_token")
 
 	fallbackService, err := fallback.NewService(logEventPath, destinationsService)
@@ -293,11 +308,58 @@ func main() {
 		appconfig.Instance.ScheduleClosing(vn)
 	}
 
-	router := routers.SetupRouter(destinationsService, adminToken, syncService, eventsCache, inMemoryEventsCache, sourceService, fallbackService, usersRecognitionService)
+	//MQTT listener
+	if viper.IsSet("mqtt") {
+		mqttConfig := &mqtt.Config{}
+		if err := viper.UnmarshalKey("mqtt", mqttConfig); err != nil {
+			logging.Fatal("Error parsing mqtt config:", err)
+		}
+
+		mqttListener, err := mqtt.NewListener(mqttConfig, appconfig.Instance.ServerName, eventsEngine.EventHandler(), appconfig.Instance.AuthorizationService.GetTokenId)
+		if err != nil {
+			logging.Fatal("Error creating mqtt listener:", err)
+		}
+		appconfig.Instance.ScheduleClosing(mqttListener)
+	}
+
+	//close after all events producers (sources, MQTT listener). Meta storage is closed last for saving last task statuses
+	appconfig.Instance.ScheduleClosing(eventsEngine)
+
+	//load shedding of non-critical requests under overload
+	overloadConfig := &overload.Config{}
+	if err := viper.UnmarshalKey("server.overload", overloadConfig); err != nil {
+		logging.Fatal("Error parsing server.overload config:", err)
+	}
+	overloadController, err := overload.Init(overloadConfig, destinationsService.QueuesSize)
+	if err != nil {
+		logging.Fatal("Error initializing overload controller:", err)
+	}
+	if overloadController != nil {
+		appconfig.Instance.ScheduleClosing(overloadController)
+	}
+
+	router := routers.SetupRouter(destinationsService, adminToken, syncService, eventsEngine.MetaStorage(), eventsEngine.EventsCache(), eventsEngine.InMemoryEventsCache(),
+		sourceService, fallbackService, eventsEngine.RecognitionService(), eventsEngine.IdentityService(), drainService)
+
+	//debug endpoints (pprof) are served only on the private port (on loopback interface by default)
+	if privatePort := viper.GetString("server.private_port"); privatePort != "" {
+		privateServer := &http.Server{
+			Addr:              viper.GetString("server.private_host") + ":" + privatePort,
+			Handler:           routers.SetupPrivateRouter(adminToken, appconfig.Instance.ServerName),
+			ReadHeaderTimeout: time.Second * 60,
+			IdleTimeout:       time.Second * 65,
+		}
+		go func() {
+			logging.Info("Started private server: " + privateServer.Addr)
+			if err := privateServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.Errorf("Private server error: %v", err)
+			}
+		}()
+		appconfig.Instance.ScheduleClosing(privateServer)
+	}
 
 	telemetry.ServerStart()
 	notifications.ServerStart()
-	logging.Info("Started server: " + appconfig.Instance.Authority)
 	server := &http.Server{
 		Addr:              appconfig.Instance.Authority,
 		Handler:           middleware.Cors(router, appconfig.Instance.AuthorizationService.GetClientOrigins),
@@ -305,5 +367,32 @@ func main() {
 		ReadHeaderTimeout: time.Second * 60,
 		IdleTimeout:       time.Second * 65,
 	}
+
+	//native TLS termination (with HTTP/2) without reverse proxy
+	if viper.IsSet("server.tls") {
+		tlsConfig := &appconfig.TLSConfig{}
+		if err := viper.UnmarshalKey("server.tls", tlsConfig); err != nil {
+			logging.Fatal("Error parsing server.tls config:", err)
+		}
+
+		redirectServer, err := appconfig.ConfigureTLS(server, tlsConfig)
+		if err != nil {
+			logging.Fatal(err)
+		}
+		if redirectServer != nil {
+			go func() {
+				logging.Info("Started HTTP to HTTPS redirect server: " + redirectServer.Addr)
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logging.Errorf("Redirect server error: %v", err)
+				}
+			}()
+			appconfig.Instance.ScheduleClosing(redirectServer)
+		}
+
+		logging.Info("Started HTTPS server: " + appconfig.Instance.Authority)
+		logging.Fatal(server.ListenAndServeTLS("", ""))
+	}
+
+	logging.Info("Started server: " + appconfig.Instance.Authority)
 	logging.Fatal(server.ListenAndServe())
 }
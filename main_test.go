@@ -181,9 +181,10 @@ func TestCors(t *testing.T) {
 			appconfig.Instance.ScheduleClosing(destinationService)
 
 			dummyRecognitionService, _ := users.NewRecognitionService(nil, nil, nil, "")
-			router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}),
+			dummyIdentityService, _ := users.NewIdentityService(nil, nil)
+			router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}), &meta.Dummy{},
 				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(),
-				fallback.NewTestService(), dummyRecognitionService)
+				fallback.NewTestService(), dummyRecognitionService, dummyIdentityService, nil)
 
 			freezeTime := time.Date(2020, 06, 16, 23, 0, 0, 0, time.UTC)
 			patch := monkey.Patch(time.Now, func() time.Time { return freezeTime })
@@ -310,9 +311,10 @@ func TestApiEvent(t *testing.T) {
 			appconfig.Instance.ScheduleClosing(destinationService)
 
 			dummyRecognitionService, _ := users.NewRecognitionService(nil, nil, nil, "")
-			router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}),
+			dummyIdentityService, _ := users.NewIdentityService(nil, nil)
+			router := routers.SetupRouter(destinationService, "", synchronization.NewInMemoryService([]string{}), &meta.Dummy{},
 				caching.NewEventsCache(&meta.Dummy{}, 100), events.NewCache(5), sources.NewTestService(),
-				fallback.NewTestService(), dummyRecognitionService)
+				fallback.NewTestService(), dummyRecognitionService, dummyIdentityService, nil)
 
 			freezeTime := time.Date(2020, 06, 16, 23, 0, 0, 0, time.UTC)
 			patch := monkey.Patch(time.Now, func() time.Time { return freezeTime })
@@ -449,13 +451,14 @@ func testPostgresStoreEvents(t *testing.T, pgDestinationConfigTemplate string, e
 	enrichment.InitDefault()
 	monitor := synchronization.NewInMemoryService([]string{})
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	dest, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
+	dest, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
 	require.NoError(t, err)
 	defer dest.Close()
 
 	dummyRecognitionService, _ := users.NewRecognitionService(nil, nil, nil, "")
-	router := routers.SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5),
-		sources.NewTestService(), fallback.NewTestService(), dummyRecognitionService)
+	dummyIdentityService, _ := users.NewIdentityService(nil, nil)
+	router := routers.SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), &meta.Dummy{}, eventsCache, events.NewCache(5),
+		sources.NewTestService(), fallback.NewTestService(), dummyRecognitionService, dummyIdentityService, nil)
 
 	server := &http.Server{
 		Addr:              httpAuthority,
@@ -550,13 +553,14 @@ func testClickhouseStoreEvents(t *testing.T, configTemplate string, expectedEven
 
 	monitor := synchronization.NewInMemoryService([]string{})
 	eventsCache := caching.NewEventsCache(&meta.Dummy{}, 100)
-	dest, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
+	dest, err := destinations.NewService(ctx, nil, destinationConfig, "/tmp", monitor, eventsCache, &meta.Dummy{}, logging.NewFactory("/tmp", 5, false, nil, nil), storages.Create)
 	require.NoError(t, err)
 	appconfig.Instance.ScheduleClosing(dest)
 
 	dummyRecognitionService, _ := users.NewRecognitionService(nil, nil, nil, "")
-	router := routers.SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), eventsCache, events.NewCache(5),
-		sources.NewTestService(), fallback.NewTestService(), dummyRecognitionService)
+	dummyIdentityService, _ := users.NewIdentityService(nil, nil)
+	router := routers.SetupRouter(dest, "", synchronization.NewInMemoryService([]string{}), &meta.Dummy{}, eventsCache, events.NewCache(5),
+		sources.NewTestService(), fallback.NewTestService(), dummyRecognitionService, dummyIdentityService, nil)
 
 	server := &http.Server{
 		Addr:              httpAuthority,
@@ -1,5 +1,7 @@
 package maputils
 
+import "fmt"
+
 func CopyMap(m map[string]interface{}) map[string]interface{} {
 	cp := make(map[string]interface{})
 	for k, v := range m {
@@ -13,3 +15,30 @@ func CopyMap(m map[string]interface{}) map[string]interface{} {
 
 	return cp
 }
+
+//StringKeys return value with map[interface{}]interface{} (e.g. from YAML) converted to map[string]interface{} recursively
+//so it might be serialized into JSON
+func StringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[fmt.Sprint(k)] = StringKeys(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = StringKeys(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = StringKeys(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
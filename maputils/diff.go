@@ -0,0 +1,55 @@
+package maputils
+
+import (
+	"reflect"
+	"sort"
+)
+
+//Difference is a changed value by path (e.g. /data_layout/table_name_template)
+//Previous or Current is nil if the value doesn't exist
+type Difference struct {
+	Path     string      `json:"path"`
+	Previous interface{} `json:"previous,omitempty"`
+	Current  interface{} `json:"current,omitempty"`
+}
+
+//Diff return differences between nested maps sorted by path
+func Diff(previous, current map[string]interface{}) []*Difference {
+	return diff("", previous, current)
+}
+
+func diff(prefix string, previous, current map[string]interface{}) []*Difference {
+	keys := map[string]bool{}
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	var differences []*Difference
+	for _, key := range sortedKeys {
+		path := prefix + "/" + key
+		previousValue, previousOk := previous[key]
+		currentValue, currentOk := current[key]
+
+		previousMap, previousIsMap := previousValue.(map[string]interface{})
+		currentMap, currentIsMap := currentValue.(map[string]interface{})
+		if previousIsMap && currentIsMap {
+			differences = append(differences, diff(path, previousMap, currentMap)...)
+			continue
+		}
+
+		if previousOk != currentOk || !reflect.DeepEqual(previousValue, currentValue) {
+			differences = append(differences, &Difference{Path: path, Previous: previousValue, Current: currentValue})
+		}
+	}
+
+	return differences
+}
@@ -0,0 +1,30 @@
+package maputils
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	previous := map[string]interface{}{
+		"mode":        "stream",
+		"same":        1.0,
+		"data_layout": map[string]interface{}{"table_name_template": "events", "keep": true},
+		"removed":     "value",
+	}
+	current := map[string]interface{}{
+		"mode":        "batch",
+		"same":        1.0,
+		"data_layout": map[string]interface{}{"table_name_template": "{{.event_type}}", "keep": true},
+		"added":       []interface{}{"a"},
+	}
+
+	require.Equal(t, []*Difference{
+		{Path: "/added", Current: []interface{}{"a"}},
+		{Path: "/data_layout/table_name_template", Previous: "events", Current: "{{.event_type}}"},
+		{Path: "/mode", Previous: "stream", Current: "batch"},
+		{Path: "/removed", Previous: "value"},
+	}, Diff(previous, current))
+
+	require.Nil(t, Diff(previous, previous))
+}
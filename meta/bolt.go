@@ -0,0 +1,1193 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/timestamp"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const expiredKeysCleanupInterval = time.Minute
+
+var (
+	stringsBucket = []byte("strings")
+	hashesBucket  = []byte("hashes")
+	listsBucket   = []byte("lists")
+	zsetsBucket   = []byte("zsets")
+	expiresBucket = []byte("expires")
+
+	zsetMembersBucket = []byte("members")
+	zsetScoresBucket  = []byte("scores")
+)
+
+//Bolt is an embedded meta storage (single file) for single-node deployments without Redis
+//Keys are the same as in Redis (see Redis key descriptions) so snapshots are portable between storages.
+//Data model (top level buckets):
+//strings [key] - value (e.g. identities, dedup and replay protection keys)
+//hashes  [key] - nested bucket with fields
+//lists   [key] - JSON array
+//zsets   [key] - nested buckets: members [member] - score, scores [score+member] - empty (ordered by score, member)
+//expires [key] - unix milliseconds of key expiration
+//Unique users are counted exactly (hash with anonymous ids per day) instead of HyperLogLog,
+//so daily_uniques keys aren't portable between Bolt and Redis
+type Bolt struct {
+	db *bolt.DB
+
+	done chan struct{}
+}
+
+func NewBolt(path string) (*Bolt, error) {
+	if path == "" {
+		return nil, errors.New("meta.storage.bolt.path is required")
+	}
+	logging.Infof("Initializing bolt meta storage [%s]...", path)
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("Error creating bolt meta storage dir [%s]: %v", dir, err)
+		}
+	}
+
+	//file is locked by the opened storage: another process can't open it
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Error opening bolt meta storage file [%s]: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{stringsBucket, hashesBucket, listsBucket, zsetsBucket, expiresBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Error initializing bolt meta storage buckets: %v", err)
+	}
+
+	b := &Bolt{db: db, done: make(chan struct{})}
+	b.startCleanup()
+
+	return b, nil
+}
+
+func (b *Bolt) GetSignature(sourceId, collection, interval string) (string, error) {
+	return b.hget("source#"+sourceId+":collection#"+collection+":chunks", interval)
+}
+
+func (b *Bolt) SaveSignature(sourceId, collection, interval, signature string) error {
+	return b.hset("source#"+sourceId+":collection#"+collection+":chunks", interval, signature)
+}
+
+//DeleteSignatures remove collection signatures hashtable
+func (b *Bolt) DeleteSignatures(sourceId, collection string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return deleteKey(tx, "source#"+sourceId+":collection#"+collection+":chunks")
+	})
+}
+
+func (b *Bolt) GetCollectionStatus(sourceId, collection string) (string, error) {
+	return b.hget("source#"+sourceId+":collection#"+collection+":status", "current")
+}
+
+func (b *Bolt) SaveCollectionStatus(sourceId, collection, status string) error {
+	return b.hset("source#"+sourceId+":collection#"+collection+":status", "current", status)
+}
+
+func (b *Bolt) GetCollectionLog(sourceId, collection string) (string, error) {
+	return b.hget("source#"+sourceId+":collection#"+collection+":log", "current")
+}
+
+func (b *Bolt) SaveCollectionLog(sourceId, collection, log string) error {
+	return b.hset("source#"+sourceId+":collection#"+collection+":log", "current", log)
+}
+
+//SaveSyncTask put sync task to the head of collection tasks list and trim the list to the history size
+func (b *Bolt) SaveSyncTask(sourceId, collection string, task *SyncTask) error {
+	key := "source#" + sourceId + ":collection#" + collection + ":tasks"
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("Error serializing sync task: %v", err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		list, err := getList(tx, key)
+		if err != nil {
+			return err
+		}
+
+		list = append([]string{string(payload)}, list...)
+		if len(list) > maxSyncTasksHistory {
+			list = list[:maxSyncTasksHistory]
+		}
+
+		return setList(tx, key, list)
+	})
+}
+
+//GetSyncTasks return page of collection sync tasks (the newest first) and total tasks count
+func (b *Bolt) GetSyncTasks(sourceId, collection string, offset, limit int) ([]SyncTask, int, error) {
+	key := "source#" + sourceId + ":collection#" + collection + ":tasks"
+	var list []string
+	err := b.db.View(func(tx *bolt.Tx) (err error) {
+		list, err = getList(tx, key)
+		return
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tasks := []SyncTask{}
+	for i := offset; i >= 0 && i < len(list) && i < offset+limit; i++ {
+		task := SyncTask{}
+		if err := json.Unmarshal([]byte(list[i]), &task); err != nil {
+			return nil, 0, fmt.Errorf("Error deserializing sync task [%s]: %v", list[i], err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, len(list), nil
+}
+
+//IncrementEventsCounter increment hourly and daily events counters of the status
+func (b *Bolt) IncrementEventsCounter(namespace, id, status string, now time.Time, value int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := hincrby(tx, hourlyEventsKey(namespace, id, status, now), strconv.Itoa(now.Hour()), value); err != nil {
+			return err
+		}
+
+		return hincrby(tx, dailyEventsKey(namespace, id, status, now), strconv.Itoa(now.Day()), value)
+	})
+}
+
+//GetEventsCounters return events counters of the status by hour or day for buckets in [start, end]
+func (b *Bolt) GetEventsCounters(namespace, id, status, granularity string, start, end time.Time) (map[time.Time]int, error) {
+	hashes, err := eventsCountersHashes(namespace, id, status, granularity, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[time.Time]int{}
+	for _, hash := range hashes {
+		values, err := b.hgetall(hash.key)
+		if err != nil {
+			return nil, err
+		}
+
+		counters := map[string]int{}
+		for field, value := range values {
+			if count, err := strconv.Atoi(value); err == nil {
+				counters[field] = count
+			}
+		}
+		collectEventsCounters(hash, counters, start, end.UTC(), result)
+	}
+
+	return result, nil
+}
+
+//IncrementQuotaUsage increment token quota period counter and prolong its ttl
+func (b *Bolt) IncrementQuotaUsage(tokenId, period string, value int, ttl time.Duration) (int, error) {
+	var total int
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		quotaKey := "quota_usage:token#" + tokenId + ":period#" + period
+		if current, ok := getString(tx, quotaKey); ok {
+			var err error
+			total, err = strconv.Atoi(string(current))
+			if err != nil {
+				return fmt.Errorf("quota usage [%s] value isn't an integer: %v", quotaKey, err)
+			}
+		}
+
+		total += value
+		return setString(tx, quotaKey, []byte(strconv.Itoa(total)), ttl)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+//AddUniqueUser add anonymous id into the daily hash (exact counting instead of HyperLogLog)
+func (b *Bolt) AddUniqueUser(id, anonymousId string, now time.Time) error {
+	return b.hset("daily_uniques:id#"+id+":day#"+now.Format(timestamp.DayLayout), anonymousId, "")
+}
+
+//CountUniqueUsers return count of unique users in [start, end] days range (union of all daily anonymous ids)
+func (b *Bolt) CountUniqueUsers(id string, start, end time.Time) (int, error) {
+	uniques := map[string]bool{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+			hash := hashBucket(tx, "daily_uniques:id#"+id+":day#"+day.Format(timestamp.DayLayout))
+			if hash == nil {
+				continue
+			}
+			if err := hash.ForEach(func(k, v []byte) error {
+				uniques[string(k)] = true
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(uniques), nil
+}
+
+func (b *Bolt) AddEvent(destinationId, eventId, tokenId, payload string, now time.Time) (int, error) {
+	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+	lastEventsIndexKey := "last_events_index:destination#" + destinationId
+
+	var count int
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := hset(tx, lastEventsKey, map[string]string{"original": payload, "token_id": tokenId}); err != nil {
+			return err
+		}
+
+		if err := zadd(tx, lastEventsIndexKey, now.Unix(), eventId); err != nil {
+			return err
+		}
+
+		if tokenId != "" {
+			if err := zadd(tx, tokenEventsIndexKey(destinationId, tokenId), now.Unix(), eventId); err != nil {
+				return err
+			}
+		}
+
+		count = zcard(tx, lastEventsIndexKey)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (b *Bolt) UpdateSucceedEvent(destinationId, eventId, success string) error {
+	return b.updateCachedEvent(destinationId, eventId, map[string]string{"success": success, "error": "", "rejected": ""})
+}
+
+func (b *Bolt) UpdateErrorEvent(destinationId, eventId, error, configHash string) error {
+	return b.updateCachedEvent(destinationId, eventId, map[string]string{"error": error, "config_hash": configHash})
+}
+
+//UpdateRejectedEvent write error and rejected payload into the cached event
+func (b *Bolt) UpdateRejectedEvent(destinationId, eventId, error, rejected, configHash string) error {
+	return b.updateCachedEvent(destinationId, eventId, map[string]string{"error": error, "rejected": rejected, "config_hash": configHash})
+}
+
+func (b *Bolt) RemoveLastEvent(destinationId string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		eventId, ok, err := zpopmin(tx, "last_events_index:destination#"+destinationId)
+		if err != nil || !ok {
+			return err
+		}
+
+		lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+		if hash := hashBucket(tx, lastEventsKey); hash != nil {
+			if tokenId := string(hash.Get([]byte("token_id"))); tokenId != "" {
+				if err := zrem(tx, tokenEventsIndexKey(destinationId, tokenId), eventId); err != nil {
+					return err
+				}
+			}
+		}
+
+		return deleteKey(tx, lastEventsKey)
+	})
+}
+
+//GetTotalTokenEvents return amount of the token events in the destination cache
+func (b *Bolt) GetTotalTokenEvents(destinationId, tokenId string) (int, error) {
+	var count int
+	err := b.db.View(func(tx *bolt.Tx) error {
+		count = zcard(tx, tokenEventsIndexKey(destinationId, tokenId))
+		return nil
+	})
+
+	return count, err
+}
+
+//RemoveLastTokenEvent remove the oldest token event from the destination cache
+func (b *Bolt) RemoveLastTokenEvent(destinationId, tokenId string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		eventId, ok, err := zpopmin(tx, tokenEventsIndexKey(destinationId, tokenId))
+		if err != nil || !ok {
+			return err
+		}
+
+		return removeCachedEvent(tx, destinationId, eventId)
+	})
+}
+
+//RemoveTokenEventsBefore remove the token events which were cached before the time from the destination cache
+//return amount of removed events
+func (b *Bolt) RemoveTokenEventsBefore(destinationId, tokenId string, before time.Time) (int, error) {
+	var removed int
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		tokenIndexKey := tokenEventsIndexKey(destinationId, tokenId)
+		members := zrangeByScore(tx, tokenIndexKey, minScore, before.Unix()-1, 0, -1)
+		for _, member := range members {
+			if err := zrem(tx, tokenIndexKey, member.Member); err != nil {
+				return err
+			}
+			if err := removeCachedEvent(tx, destinationId, member.Member); err != nil {
+				return err
+			}
+		}
+		removed = len(members)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
+
+func (b *Bolt) GetEvents(destinationId string, start, end time.Time, n int) ([]Event, error) {
+	events := []Event{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		members := zrangeByScore(tx, "last_events_index:destination#"+destinationId, start.Unix(), end.Unix(), 0, n)
+		for _, member := range members {
+			if event := getCachedEvent(tx, destinationId, member.Member); event != nil {
+				events = append(events, *event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+//GetEventsIndex return at most n event ids with timestamps in [start, end] ordered by timestamp, event id
+//skip first offset items
+func (b *Bolt) GetEventsIndex(destinationId string, start, end time.Time, offset, n int) ([]EventIndex, error) {
+	index := []EventIndex{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		members := zrangeByScore(tx, "last_events_index:destination#"+destinationId, start.Unix(), end.Unix(), offset, n)
+		for _, member := range members {
+			index = append(index, EventIndex{EventId: member.Member, Timestamp: int64(member.Score)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+//GetEvent return cached event or nil if it doesn't exist
+func (b *Bolt) GetEvent(destinationId, eventId string) (*Event, error) {
+	var event *Event
+	err := b.db.View(func(tx *bolt.Tx) error {
+		event = getCachedEvent(tx, destinationId, eventId)
+		return nil
+	})
+
+	return event, err
+}
+
+func (b *Bolt) GetTotalEvents(destinationId string) (int, error) {
+	var count int
+	err := b.db.View(func(tx *bolt.Tx) error {
+		count = zcard(tx, "last_events_index:destination#"+destinationId)
+		return nil
+	})
+
+	return count, err
+}
+
+func (b *Bolt) SaveConfigSnapshot(destinationId, hash, snapshot string) error {
+	return b.hset("config_snapshots:destination#"+destinationId, hash, snapshot)
+}
+
+//GetConfigSnapshot return destination configuration json by hash or empty string if it doesn't exist
+func (b *Bolt) GetConfigSnapshot(destinationId, hash string) (string, error) {
+	return b.hget("config_snapshots:destination#"+destinationId, hash)
+}
+
+func (b *Bolt) SaveAnonymousEvent(destinationId, anonymousId, eventId, payload string) error {
+	return b.hset("anonymous_events:destination_id#"+destinationId+":anonymous_id#"+anonymousId, eventId, payload)
+}
+
+func (b *Bolt) GetAnonymousEvents(destinationId, anonymousId string) (map[string]string, error) {
+	return b.hgetall("anonymous_events:destination_id#" + destinationId + ":anonymous_id#" + anonymousId)
+}
+
+func (b *Bolt) DeleteAnonymousEvent(destinationId, anonymousId, eventId string) error {
+	return b.hdel("anonymous_events:destination_id#"+destinationId+":anonymous_id#"+anonymousId, eventId)
+}
+
+//SaveIdentity save anonymous id -> user id mapping with ttl (without expiration if ttl is 0)
+func (b *Bolt) SaveIdentity(anonymousId, userId string, ttl time.Duration) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return setString(tx, "identities:anonymous_id#"+anonymousId, []byte(userId), ttl)
+	})
+}
+
+//GetIdentity return user id by anonymous id or empty string if mapping doesn't exist
+func (b *Bolt) GetIdentity(anonymousId string) (string, error) {
+	var userId string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value, _ := getString(tx, "identities:anonymous_id#"+anonymousId)
+		userId = string(value)
+		return nil
+	})
+
+	return userId, err
+}
+
+//MarkEventSeen mark event as seen in the scope for ttl
+//return true if event hasn't been seen yet or has been seen from the same origin (retries of the same batch aren't duplicates)
+func (b *Bolt) MarkEventSeen(scope, eventId, origin string, ttl time.Duration) (bool, error) {
+	var unseen bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		dedupKey := "dedup:scope#" + scope + ":event#" + eventId
+		seenOrigin, ok := getString(tx, dedupKey)
+		if ok {
+			unseen = origin != "" && string(seenOrigin) == origin
+			return nil
+		}
+
+		unseen = true
+		return setString(tx, dedupKey, []byte(origin), ttl)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return unseen, nil
+}
+
+//MarkNonceUsed return true if nonce hasn't been used in the scope within ttl and save it
+func (b *Bolt) MarkNonceUsed(scope, nonce string, ttl time.Duration) (bool, error) {
+	var unused bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		nonceKey := "replay:scope#" + scope + ":nonce#" + nonce
+		if _, ok := getString(tx, nonceKey); ok {
+			return nil
+		}
+
+		unused = true
+		return setString(tx, nonceKey, []byte(strconv.FormatInt(time.Now().UTC().Unix(), 10)), ttl)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return unused, nil
+}
+
+//IncrementPipelineStage increment stage events counter of the hour
+func (b *Bolt) IncrementPipelineStage(id, stage string, hour time.Time, value int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return hincrby(tx, "pipeline_stages:id#"+id+":day#"+hour.Format(timestamp.DayLayout), stage+"#"+strconv.Itoa(hour.Hour()), value)
+	})
+}
+
+//GetPipelineStages return events counters by hour and stage for hours in [start, end]
+func (b *Bolt) GetPipelineStages(id string, start, end time.Time) (map[time.Time]map[string]int, error) {
+	start = start.UTC().Truncate(time.Hour)
+	end = end.UTC()
+	result := map[time.Time]map[string]int{}
+	for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+		counters, err := b.hgetall("pipeline_stages:id#" + id + ":day#" + day.Format(timestamp.DayLayout))
+		if err != nil {
+			return nil, err
+		}
+
+		for field, value := range counters {
+			parts := strings.Split(field, "#")
+			if len(parts) != 2 {
+				continue
+			}
+			h, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			hour := day.Add(time.Duration(h) * time.Hour)
+			if hour.Before(start) || hour.After(end) {
+				continue
+			}
+
+			stages, ok := result[hour]
+			if !ok {
+				stages = map[string]int{}
+				result[hour] = stages
+			}
+			stages[parts[0]] += count
+		}
+	}
+
+	return result, nil
+}
+
+//SaveDestinationMode save destination mode JSON
+func (b *Bolt) SaveDestinationMode(destinationId, payload string) error {
+	return b.hset(destinationModesKey, destinationId, payload)
+}
+
+//GetDestinationModes return all destinations modes JSON by destination id
+func (b *Bolt) GetDestinationModes() (map[string]string, error) {
+	return b.hgetall(destinationModesKey)
+}
+
+//SaveFeatureFlag save feature flag JSON by name
+func (b *Bolt) SaveFeatureFlag(name, payload string) error {
+	return b.hset(featureFlagsKey, name, payload)
+}
+
+//GetFeatureFlags return all feature flags JSON by name
+func (b *Bolt) GetFeatureFlags() (map[string]string, error) {
+	return b.hgetall(featureFlagsKey)
+}
+
+//DeleteFeatureFlag remove feature flag by name
+func (b *Bolt) DeleteFeatureFlag(name string) error {
+	return b.hdel(featureFlagsKey, name)
+}
+
+//GetState return state JSON and its version or empty string and 0 if state hasn't been saved
+func (b *Bolt) GetState() (string, int64, error) {
+	state, err := b.hgetall(stateKey)
+	if err != nil || state["version"] == "" {
+		return "", 0, err
+	}
+
+	version, err := strconv.ParseInt(state["version"], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Error parsing state version [%s]: %v", state["version"], err)
+	}
+
+	return state["payload"], version, nil
+}
+
+//SaveState save state JSON if the stored version is equal to expectedVersion (0 if state hasn't been saved)
+//return new version or ErrStateVersionConflict
+func (b *Bolt) SaveState(payload string, expectedVersion int64) (int64, error) {
+	var version int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if hash := hashBucket(tx, stateKey); hash != nil {
+			if stored := hash.Get([]byte("version")); stored != nil {
+				var err error
+				if version, err = strconv.ParseInt(string(stored), 10, 64); err != nil {
+					return fmt.Errorf("Error parsing state version [%s]: %v", string(stored), err)
+				}
+			}
+		}
+		if version != expectedVersion {
+			return ErrStateVersionConflict
+		}
+
+		version++
+		return hset(tx, stateKey, map[string]string{"payload": payload, "version": strconv.FormatInt(version, 10)})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+//Export return snapshot with all keys which match at least one pattern (all keys if patterns are empty)
+//patterns are Redis glob-style: * ? [abc]
+func (b *Bolt) Export(patterns []string) (*Snapshot, error) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		matcher, err := globRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing pattern [%s]: %v", pattern, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	snapshot := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC(), Source: BoltType, Entries: []*SnapshotEntry{}}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, entryType := range []string{StringEntry, HashEntry, ListEntry, ZSetEntry} {
+			bucket := tx.Bucket(typeBucket(entryType))
+			err := bucket.ForEach(func(k, v []byte) error {
+				key := string(k)
+				if !matchesAny(matchers, key) {
+					return nil
+				}
+
+				ttl, expired := ttlOf(tx, key, now)
+				if expired {
+					return nil
+				}
+
+				entry, err := exportEntry(tx, entryType, key)
+				if err != nil {
+					return fmt.Errorf("Error exporting key [%s]: %v", key, err)
+				}
+				if ttl > 0 {
+					entry.TTLMs = int64(ttl / time.Millisecond)
+				}
+				snapshot.Entries = append(snapshot.Entries, entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+//Import write all snapshot entries (existing keys are overwritten) in one transaction
+func (b *Bolt) Import(snapshot *Snapshot) error {
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, entry := range snapshot.Entries {
+			if err := importEntry(tx, entry); err != nil {
+				return fmt.Errorf("Error importing key [%s]: %v", entry.Key, err)
+			}
+		}
+		return nil
+	})
+}
+
+//Ping return err if bolt file isn't opened
+func (b *Bolt) Ping() error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+func (b *Bolt) Type() string {
+	return BoltType
+}
+
+func (b *Bolt) Close() error {
+	close(b.done)
+	return b.db.Close()
+}
+
+//startCleanup run goroutine which removes expired keys every minute
+//(expired keys are never returned even before the cleanup)
+func (b *Bolt) startCleanup() {
+	safego.RunWithRestart(func() {
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-time.After(expiredKeysCleanupInterval):
+			}
+
+			if err := b.removeExpired(time.Now()); err != nil {
+				logging.SystemErrorf("Error removing expired keys from bolt meta storage: %v", err)
+			}
+		}
+	})
+}
+
+func (b *Bolt) removeExpired(now time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		var expired []string
+		err := tx.Bucket(expiresBucket).ForEach(func(k, v []byte) error {
+			if isExpired(v, now) {
+				expired = append(expired, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := deleteKey(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//increment success or errors keys depends on input status string
+//updateCachedEvent write fields only if cached event exists
+func (b *Bolt) updateCachedEvent(destinationId, eventId string, fields map[string]string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+		if hashBucket(tx, lastEventsKey) == nil {
+			return nil
+		}
+
+		return hset(tx, lastEventsKey, fields)
+	})
+}
+
+func (b *Bolt) hget(key, field string) (string, error) {
+	var value string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if hash := hashBucket(tx, key); hash != nil {
+			value = string(hash.Get([]byte(field)))
+		}
+		return nil
+	})
+
+	return value, err
+}
+
+func (b *Bolt) hset(key, field, value string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return hset(tx, key, map[string]string{field: value})
+	})
+}
+
+func (b *Bolt) hgetall(key string) (map[string]string, error) {
+	result := map[string]string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		hash := hashBucket(tx, key)
+		if hash == nil {
+			return nil
+		}
+		return hash.ForEach(func(k, v []byte) error {
+			result[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+//hdel remove field and the hash itself if it becomes empty (like in Redis)
+func (b *Bolt) hdel(key, field string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		hash := hashBucket(tx, key)
+		if hash == nil {
+			return nil
+		}
+		if err := hash.Delete([]byte(field)); err != nil {
+			return err
+		}
+
+		if k, _ := hash.Cursor().First(); k == nil {
+			return deleteKey(tx, key)
+		}
+		return nil
+	})
+}
+
+func removeCachedEvent(tx *bolt.Tx, destinationId, eventId string) error {
+	if err := zrem(tx, "last_events_index:destination#"+destinationId, eventId); err != nil {
+		return err
+	}
+
+	return deleteKey(tx, "last_events:destination#"+destinationId+":id#"+eventId)
+}
+
+//getCachedEvent return cached event or nil if it doesn't exist
+func getCachedEvent(tx *bolt.Tx, destinationId, eventId string) *Event {
+	hash := hashBucket(tx, "last_events:destination#"+destinationId+":id#"+eventId)
+	if hash == nil {
+		return nil
+	}
+
+	return &Event{
+		Original:   string(hash.Get([]byte("original"))),
+		Success:    string(hash.Get([]byte("success"))),
+		Error:      string(hash.Get([]byte("error"))),
+		Rejected:   string(hash.Get([]byte("rejected"))),
+		TokenId:    string(hash.Get([]byte("token_id"))),
+		ConfigHash: string(hash.Get([]byte("config_hash"))),
+	}
+}
+
+func typeBucket(entryType string) []byte {
+	switch entryType {
+	case HashEntry:
+		return hashesBucket
+	case ListEntry:
+		return listsBucket
+	case ZSetEntry:
+		return zsetsBucket
+	default:
+		return stringsBucket
+	}
+}
+
+//deleteKey remove key of any type with expiration
+func deleteKey(tx *bolt.Tx, key string) error {
+	k := []byte(key)
+	for _, name := range [][]byte{hashesBucket, zsetsBucket} {
+		if err := tx.Bucket(name).DeleteBucket(k); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	for _, name := range [][]byte{stringsBucket, listsBucket, expiresBucket} {
+		if err := tx.Bucket(name).Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//ttlOf return remaining time to live (0 - without expiration) and true if key has been expired
+func ttlOf(tx *bolt.Tx, key string, now time.Time) (time.Duration, bool) {
+	value := tx.Bucket(expiresBucket).Get([]byte(key))
+	if value == nil {
+		return 0, false
+	}
+	if isExpired(value, now) {
+		return 0, true
+	}
+
+	return time.Duration(int64(binary.BigEndian.Uint64(value))-now.UnixNano()/int64(time.Millisecond)) * time.Millisecond, false
+}
+
+func isExpired(expiresAt []byte, now time.Time) bool {
+	return int64(binary.BigEndian.Uint64(expiresAt)) <= now.UnixNano()/int64(time.Millisecond)
+}
+
+func expire(tx *bolt.Tx, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return tx.Bucket(expiresBucket).Delete([]byte(key))
+	}
+
+	expiresAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(expiresAt, uint64(time.Now().Add(ttl).UnixNano()/int64(time.Millisecond)))
+	return tx.Bucket(expiresBucket).Put([]byte(key), expiresAt)
+}
+
+//getString return value and true if key exists and hasn't been expired
+func getString(tx *bolt.Tx, key string) ([]byte, bool) {
+	//values might be empty (e.g. dedup origin) so existence is checked by key
+	k, value := tx.Bucket(stringsBucket).Cursor().Seek([]byte(key))
+	if !bytes.Equal(k, []byte(key)) {
+		return nil, false
+	}
+	if _, expired := ttlOf(tx, key, time.Now()); expired {
+		return nil, false
+	}
+
+	return value, true
+}
+
+//setString overwrite value and expiration (without expiration if ttl is 0)
+func setString(tx *bolt.Tx, key string, value []byte, ttl time.Duration) error {
+	if err := tx.Bucket(stringsBucket).Put([]byte(key), value); err != nil {
+		return err
+	}
+
+	return expire(tx, key, ttl)
+}
+
+func hashBucket(tx *bolt.Tx, key string) *bolt.Bucket {
+	return tx.Bucket(hashesBucket).Bucket([]byte(key))
+}
+
+func hset(tx *bolt.Tx, key string, fields map[string]string) error {
+	hash, err := tx.Bucket(hashesBucket).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+
+	for field, value := range fields {
+		if err := hash.Put([]byte(field), []byte(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hincrby(tx *bolt.Tx, key, field string, value int) error {
+	hash, err := tx.Bucket(hashesBucket).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+
+	current := 0
+	if v := hash.Get([]byte(field)); v != nil {
+		current, err = strconv.Atoi(string(v))
+		if err != nil {
+			return fmt.Errorf("hash [%s] field [%s] value isn't an integer: %v", key, field, err)
+		}
+	}
+
+	return hash.Put([]byte(field), []byte(strconv.Itoa(current+value)))
+}
+
+func getList(tx *bolt.Tx, key string) ([]string, error) {
+	value := tx.Bucket(listsBucket).Get([]byte(key))
+	if value == nil {
+		return []string{}, nil
+	}
+
+	list := []string{}
+	if err := json.Unmarshal(value, &list); err != nil {
+		return nil, fmt.Errorf("Error deserializing list [%s]: %v", key, err)
+	}
+
+	return list, nil
+}
+
+func setList(tx *bolt.Tx, key string, list []string) error {
+	value, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(listsBucket).Put([]byte(key), value)
+}
+
+const (
+	minScore = int64(-1 << 63)
+	maxScore = int64(1<<63 - 1)
+)
+
+//encodeScore return big endian bytes with flipped sign bit: byte order equals to numeric order
+func encodeScore(score int64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(score)^(1<<63))
+	return encoded
+}
+
+func decodeScore(encoded []byte) int64 {
+	return int64(binary.BigEndian.Uint64(encoded[:8]) ^ (1 << 63))
+}
+
+func zsetBuckets(tx *bolt.Tx, key string) (*bolt.Bucket, *bolt.Bucket) {
+	zset := tx.Bucket(zsetsBucket).Bucket([]byte(key))
+	if zset == nil {
+		return nil, nil
+	}
+
+	return zset.Bucket(zsetMembersBucket), zset.Bucket(zsetScoresBucket)
+}
+
+//zadd add member with score or update score of the existing member
+func zadd(tx *bolt.Tx, key string, score int64, member string) error {
+	zset, err := tx.Bucket(zsetsBucket).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+	members, err := zset.CreateBucketIfNotExists(zsetMembersBucket)
+	if err != nil {
+		return err
+	}
+	scores, err := zset.CreateBucketIfNotExists(zsetScoresBucket)
+	if err != nil {
+		return err
+	}
+
+	if previous := members.Get([]byte(member)); previous != nil {
+		if err := scores.Delete(append(append([]byte{}, previous...), member...)); err != nil {
+			return err
+		}
+	}
+
+	encoded := encodeScore(score)
+	if err := members.Put([]byte(member), encoded); err != nil {
+		return err
+	}
+
+	return scores.Put(append(encoded, member...), nil)
+}
+
+//zrem remove member and the sorted set itself if it becomes empty (like in Redis)
+func zrem(tx *bolt.Tx, key, member string) error {
+	members, scores := zsetBuckets(tx, key)
+	if members == nil {
+		return nil
+	}
+
+	encoded := members.Get([]byte(member))
+	if encoded == nil {
+		return nil
+	}
+	if err := scores.Delete(append(append([]byte{}, encoded...), member...)); err != nil {
+		return err
+	}
+	if err := members.Delete([]byte(member)); err != nil {
+		return err
+	}
+
+	if k, _ := members.Cursor().First(); k == nil {
+		return deleteKey(tx, key)
+	}
+	return nil
+}
+
+func zcard(tx *bolt.Tx, key string) int {
+	members, _ := zsetBuckets(tx, key)
+	if members == nil {
+		return 0
+	}
+
+	count := 0
+	cursor := members.Cursor()
+	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+		count++
+	}
+
+	return count
+}
+
+//zpopmin remove and return member with the lowest score. Return false if sorted set is empty
+func zpopmin(tx *bolt.Tx, key string) (string, bool, error) {
+	_, scores := zsetBuckets(tx, key)
+	if scores == nil {
+		return "", false, nil
+	}
+
+	k, _ := scores.Cursor().First()
+	if k == nil {
+		return "", false, nil
+	}
+
+	member := string(k[8:])
+	return member, true, zrem(tx, key, member)
+}
+
+//zrangeByScore return at most n (all if n < 0) members with scores in [min, max] ordered by score, member
+//skip first offset items
+func zrangeByScore(tx *bolt.Tx, key string, min, max int64, offset, n int) []*ZMember {
+	result := []*ZMember{}
+	_, scores := zsetBuckets(tx, key)
+	if scores == nil || n == 0 {
+		return result
+	}
+
+	cursor := scores.Cursor()
+	skipped := 0
+	for k, _ := cursor.Seek(encodeScore(min)); k != nil; k, _ = cursor.Next() {
+		score := decodeScore(k)
+		if score > max {
+			break
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+
+		result = append(result, &ZMember{Member: string(k[8:]), Score: float64(score)})
+		if n > 0 && len(result) >= n {
+			break
+		}
+	}
+
+	return result
+}
+
+func exportEntry(tx *bolt.Tx, entryType, key string) (*SnapshotEntry, error) {
+	entry := &SnapshotEntry{Key: key, Type: entryType}
+	switch entryType {
+	case StringEntry:
+		entry.Value = append([]byte{}, tx.Bucket(stringsBucket).Get([]byte(key))...)
+	case HashEntry:
+		entry.Hash = map[string]string{}
+		err := hashBucket(tx, key).ForEach(func(k, v []byte) error {
+			entry.Hash[string(k)] = string(v)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case ListEntry:
+		list, err := getList(tx, key)
+		if err != nil {
+			return nil, err
+		}
+		entry.List = list
+	case ZSetEntry:
+		entry.ZSet = zrangeByScore(tx, key, minScore, maxScore, 0, -1)
+	}
+
+	return entry, nil
+}
+
+func importEntry(tx *bolt.Tx, entry *SnapshotEntry) error {
+	if err := deleteKey(tx, entry.Key); err != nil {
+		return err
+	}
+
+	switch entry.Type {
+	case StringEntry:
+		if err := tx.Bucket(stringsBucket).Put([]byte(entry.Key), entry.Value); err != nil {
+			return err
+		}
+	case HashEntry:
+		if len(entry.Hash) > 0 {
+			if err := hset(tx, entry.Key, entry.Hash); err != nil {
+				return err
+			}
+		}
+	case ListEntry:
+		if len(entry.List) > 0 {
+			if err := setList(tx, entry.Key, entry.List); err != nil {
+				return err
+			}
+		}
+	case ZSetEntry:
+		for _, member := range entry.ZSet {
+			if err := zadd(tx, entry.Key, int64(member.Score), member.Member); err != nil {
+				return err
+			}
+		}
+	}
+
+	return expire(tx, entry.Key, time.Duration(entry.TTLMs)*time.Millisecond)
+}
+
+//globRegexp convert Redis glob-style pattern into regexp
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	quoted := regexp.QuoteMeta(pattern)
+	replacer := strings.NewReplacer(`\*`, `.*`, `\?`, `.`, `\[`, `[`, `\]`, `]`)
+	return regexp.Compile("^" + replacer.Replace(quoted) + "$")
+}
+
+//matchesAny return true if there are no matchers or key matches at least one
+func matchesAny(matchers []*regexp.Regexp, key string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+
+	for _, matcher := range matchers {
+		if matcher.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,235 @@
+package meta
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBolt(t *testing.T) *Bolt {
+	dir, err := ioutil.TempDir("", "bolt_meta")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	b, err := NewBolt(filepath.Join(dir, "meta", "meta.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() })
+
+	return b
+}
+
+func TestBoltEventsCache(t *testing.T) {
+	b := newTestBolt(t)
+	now := time.Date(2020, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	for i, eventId := range []string{"e1", "e2", "e3"} {
+		count, err := b.AddEvent("d1", eventId, "t1", `{"id":"`+eventId+`"}`, now.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+		require.Equal(t, i+1, count)
+	}
+
+	require.NoError(t, b.UpdateErrorEvent("d1", "e2", "error", "hash"))
+	require.NoError(t, b.UpdateSucceedEvent("d1", "e2", "success"))
+	//not cached events aren't created
+	require.NoError(t, b.UpdateSucceedEvent("d1", "unknown", "success"))
+
+	event, err := b.GetEvent("d1", "e2")
+	require.NoError(t, err)
+	require.Equal(t, &Event{Original: `{"id":"e2"}`, Success: "success", TokenId: "t1", ConfigHash: "hash"}, event)
+
+	unknown, err := b.GetEvent("d1", "unknown")
+	require.NoError(t, err)
+	require.Nil(t, unknown)
+
+	index, err := b.GetEventsIndex("d1", now, now.Add(time.Minute), 1, 5)
+	require.NoError(t, err)
+	require.Equal(t, []EventIndex{{EventId: "e2", Timestamp: now.Unix() + 1}, {EventId: "e3", Timestamp: now.Unix() + 2}}, index)
+
+	require.NoError(t, b.RemoveLastEvent("d1"))
+	tokenEvents, err := b.GetTotalTokenEvents("d1", "t1")
+	require.NoError(t, err)
+	require.Equal(t, 2, tokenEvents)
+
+	removed, err := b.RemoveTokenEventsBefore("d1", "t1", now.Add(2*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	events, err := b.GetEvents("d1", now, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, `{"id":"e3"}`, events[0].Original)
+
+	total, err := b.GetTotalEvents("d1")
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+}
+
+func TestBoltExpiringKeys(t *testing.T) {
+	b := newTestBolt(t)
+
+	unseen, err := b.MarkEventSeen("scope", "e1", "", time.Hour)
+	require.NoError(t, err)
+	require.True(t, unseen)
+	unseen, err = b.MarkEventSeen("scope", "e1", "", time.Hour)
+	require.NoError(t, err)
+	require.False(t, unseen, "empty origin must be detected as a duplicate")
+
+	unseen, err = b.MarkEventSeen("scope", "e2", "batch1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, unseen)
+	unseen, err = b.MarkEventSeen("scope", "e2", "batch1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, unseen, "retry of the same batch isn't a duplicate")
+	unseen, err = b.MarkEventSeen("scope", "e2", "batch2", time.Hour)
+	require.NoError(t, err)
+	require.False(t, unseen)
+
+	unused, err := b.MarkNonceUsed("scope", "n1", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, unused)
+	time.Sleep(5 * time.Millisecond)
+	unused, err = b.MarkNonceUsed("scope", "n1", time.Hour)
+	require.NoError(t, err)
+	require.True(t, unused, "expired nonce might be used again")
+	unused, err = b.MarkNonceUsed("scope", "n1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, unused)
+
+	require.NoError(t, b.SaveIdentity("anonym", "user", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, b.removeExpired(time.Now()))
+	userId, err := b.GetIdentity("anonym")
+	require.NoError(t, err)
+	require.Equal(t, "", userId)
+}
+
+func TestBoltCounters(t *testing.T) {
+	b := newTestBolt(t)
+	day := time.Date(2020, 10, 17, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, b.AddUniqueUser("token_t1", "a1", day))
+	require.NoError(t, b.AddUniqueUser("token_t1", "a1", day))
+	require.NoError(t, b.AddUniqueUser("token_t1", "a2", day.AddDate(0, 0, 1)))
+	require.NoError(t, b.AddUniqueUser("token_t1", "a1", day.AddDate(0, 0, 1)))
+
+	count, err := b.CountUniqueUsers("token_t1", day, day)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	count, err = b.CountUniqueUsers("token_t1", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.NoError(t, b.IncrementPipelineStage("token_t1", "received", day, 5))
+	require.NoError(t, b.IncrementPipelineStage("token_t1", "received", day, 2))
+	require.NoError(t, b.IncrementPipelineStage("token_t1", "loaded", day.Add(time.Hour), 3))
+
+	stages, err := b.GetPipelineStages("token_t1", day, day.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, map[time.Time]map[string]int{
+		day:                {"received": 7},
+		day.Add(time.Hour): {"loaded": 3},
+	}, stages)
+}
+
+func TestBoltEventsCounters(t *testing.T) {
+	b := newTestBolt(t)
+	now := time.Date(2020, 10, 31, 22, 30, 0, 0, time.UTC)
+
+	require.NoError(t, b.IncrementEventsCounter(TokenNamespace, "t1", SuccessStatus, now, 5))
+	require.NoError(t, b.IncrementEventsCounter(TokenNamespace, "t1", SuccessStatus, now.Add(time.Hour), 2))
+	require.NoError(t, b.IncrementEventsCounter(TokenNamespace, "t1", SuccessStatus, now.Add(2*time.Hour), 3))
+	require.NoError(t, b.IncrementEventsCounter(TokenNamespace, "t1", SkipStatus, now, 1))
+	require.NoError(t, b.IncrementEventsCounter(DestinationNamespace, "t1", SuccessStatus, now, 100))
+
+	hourly, err := b.GetEventsCounters(TokenNamespace, "t1", SuccessStatus, HourGranularity, now, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, map[time.Time]int{
+		now.Truncate(time.Hour):                    5,
+		now.Truncate(time.Hour).Add(time.Hour):     2,
+		now.Truncate(time.Hour).Add(2 * time.Hour): 3,
+	}, hourly)
+
+	//the range is split into months hashes
+	daily, err := b.GetEventsCounters(TokenNamespace, "t1", SuccessStatus, DayGranularity, now.AddDate(0, 0, -1), now.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, map[time.Time]int{
+		time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC): 7,
+		time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC):  3,
+	}, daily)
+
+	skipped, err := b.GetEventsCounters(TokenNamespace, "t1", SkipStatus, DayGranularity, now, now)
+	require.NoError(t, err)
+	require.Equal(t, map[time.Time]int{time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC): 1}, skipped)
+
+	_, err = b.GetEventsCounters(TokenNamespace, "t1", SkipStatus, "week", now, now)
+	require.EqualError(t, err, "Unknown granularity [week]. Available: [hour, day]")
+}
+
+func TestBoltSnapshot(t *testing.T) {
+	b := newTestBolt(t)
+
+	require.NoError(t, b.SaveSignature("s1", "c1", "2020-10", "signature"))
+	require.NoError(t, b.SaveSyncTask("s1", "c1", &SyncTask{Status: "SUCCESS", RowsSynced: 10}))
+	_, err := b.AddEvent("d1", "e1", "", "{}", time.Unix(100, 0))
+	require.NoError(t, err)
+	require.NoError(t, b.SaveIdentity("anonym", "user", time.Hour))
+	require.NoError(t, b.SaveFeatureFlag("flag", `{"enabled":true}`))
+
+	snapshot, err := b.Export([]string{"source#*", "last_events_index:*", "identities:*"})
+	require.NoError(t, err)
+	require.Equal(t, BoltType, snapshot.Source)
+	require.Len(t, snapshot.Entries, 4)
+
+	restored := newTestBolt(t)
+	require.NoError(t, restored.Import(snapshot))
+
+	signature, err := restored.GetSignature("s1", "c1", "2020-10")
+	require.NoError(t, err)
+	require.Equal(t, "signature", signature)
+
+	tasks, total, err := restored.GetSyncTasks("s1", "c1", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, []SyncTask{{Status: "SUCCESS", RowsSynced: 10}}, tasks)
+
+	index, err := restored.GetEventsIndex("d1", time.Unix(0, 0), time.Unix(200, 0), 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []EventIndex{{EventId: "e1", Timestamp: 100}}, index)
+
+	userId, err := restored.GetIdentity("anonym")
+	require.NoError(t, err)
+	require.Equal(t, "user", userId)
+
+	restoredSnapshot, err := restored.Export([]string{"identities:*"})
+	require.NoError(t, err)
+	require.Len(t, restoredSnapshot.Entries, 1)
+	require.True(t, restoredSnapshot.Entries[0].TTLMs > 0 && restoredSnapshot.Entries[0].TTLMs <= time.Hour.Milliseconds())
+
+	flags, err := restored.GetFeatureFlags()
+	require.NoError(t, err)
+	require.Empty(t, flags)
+}
+
+func TestBoltState(t *testing.T) {
+	b := newTestBolt(t)
+
+	payload, version, err := b.GetState()
+	require.NoError(t, err)
+	require.Equal(t, "", payload)
+	require.Equal(t, int64(0), version)
+
+	version, err = b.SaveState(`{"tokens":[]}`, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), version)
+
+	_, err = b.SaveState(`{"tokens":null}`, 0)
+	require.Equal(t, ErrStateVersionConflict, err)
+
+	payload, version, err = b.GetState()
+	require.NoError(t, err)
+	require.Equal(t, `{"tokens":[]}`, payload)
+	require.Equal(t, int64(1), version)
+}
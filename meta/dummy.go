@@ -1,6 +1,9 @@
 package meta
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type Dummy struct {
 }
@@ -13,6 +16,10 @@ func (d *Dummy) SaveSignature(sourceId, collection, interval, signature string)
 	return nil
 }
 
+func (d *Dummy) DeleteSignatures(sourceId, collection string) error {
+	return nil
+}
+
 func (d *Dummy) GetCollectionStatus(sourceId, collection string) (string, error) {
 	return "", nil
 }
@@ -29,14 +36,35 @@ func (d *Dummy) SaveCollectionLog(sourceId, collection, log string) error {
 	return nil
 }
 
-func (d *Dummy) SuccessEvents(destinationId string, now time.Time, value int) error {
+func (d *Dummy) SaveSyncTask(sourceId, collection string, task *SyncTask) error {
+	return nil
+}
+
+func (d *Dummy) GetSyncTasks(sourceId, collection string, offset, limit int) ([]SyncTask, int, error) {
+	return []SyncTask{}, 0, nil
+}
+
+func (d *Dummy) IncrementEventsCounter(namespace, id, status string, now time.Time, value int) error {
 	return nil
 }
-func (d *Dummy) ErrorEvents(destinationId string, now time.Time, value int) error {
+
+func (d *Dummy) GetEventsCounters(namespace, id, status, granularity string, start, end time.Time) (map[time.Time]int, error) {
+	return map[time.Time]int{}, nil
+}
+
+func (d *Dummy) IncrementQuotaUsage(tokenId, period string, value int, ttl time.Duration) (int, error) {
+	return 0, nil
+}
+
+func (d *Dummy) AddUniqueUser(id, anonymousId string, now time.Time) error {
 	return nil
 }
 
-func (d *Dummy) AddEvent(destinationId, eventId, payload string, now time.Time) (int, error) {
+func (d *Dummy) CountUniqueUsers(id string, start, end time.Time) (int, error) {
+	return 0, nil
+}
+
+func (d *Dummy) AddEvent(destinationId, eventId, tokenId, payload string, now time.Time) (int, error) {
 	return 0, nil
 }
 
@@ -44,13 +72,29 @@ func (d *Dummy) UpdateSucceedEvent(destinationId, eventId, success string) error
 	return nil
 }
 
-func (d *Dummy) UpdateErrorEvent(destinationId, eventId, error string) error {
+func (d *Dummy) UpdateErrorEvent(destinationId, eventId, error, configHash string) error {
+	return nil
+}
+
+func (d *Dummy) UpdateRejectedEvent(destinationId, eventId, error, rejected, configHash string) error {
 	return nil
 }
 func (d *Dummy) RemoveLastEvent(destinationId string) error {
 	return nil
 }
 
+func (d *Dummy) GetTotalTokenEvents(destinationId, tokenId string) (int, error) {
+	return 0, nil
+}
+
+func (d *Dummy) RemoveLastTokenEvent(destinationId, tokenId string) error {
+	return nil
+}
+
+func (d *Dummy) RemoveTokenEventsBefore(destinationId, tokenId string, before time.Time) (int, error) {
+	return 0, nil
+}
+
 func (d *Dummy) GetTotalEvents(destinationId string) (int, error) {
 	return 0, nil
 }
@@ -59,6 +103,22 @@ func (d *Dummy) GetEvents(destinationId string, start, end time.Time, n int) ([]
 	return []Event{}, nil
 }
 
+func (d *Dummy) GetEventsIndex(destinationId string, start, end time.Time, offset, n int) ([]EventIndex, error) {
+	return []EventIndex{}, nil
+}
+
+func (d *Dummy) GetEvent(destinationId, eventId string) (*Event, error) {
+	return nil, nil
+}
+
+func (d *Dummy) SaveConfigSnapshot(destinationId, hash, snapshot string) error {
+	return nil
+}
+
+func (d *Dummy) GetConfigSnapshot(destinationId, hash string) (string, error) {
+	return "", nil
+}
+
 func (d *Dummy) SaveAnonymousEvent(destinationId, anonymousId, eventId, payload string) error {
 	return nil
 }
@@ -71,6 +131,70 @@ func (d *Dummy) DeleteAnonymousEvent(destinationId, anonymousId, eventId string)
 	return nil
 }
 
+func (d *Dummy) SaveIdentity(anonymousId, userId string, ttl time.Duration) error {
+	return nil
+}
+
+func (d *Dummy) GetIdentity(anonymousId string) (string, error) {
+	return "", nil
+}
+
+func (d *Dummy) MarkEventSeen(scope, eventId, origin string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (d *Dummy) MarkNonceUsed(scope, nonce string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (d *Dummy) Export(patterns []string) (*Snapshot, error) {
+	return &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC(), Source: DummyType, Entries: []*SnapshotEntry{}}, nil
+}
+
+func (d *Dummy) Import(snapshot *Snapshot) error {
+	return errors.New("Meta storage isn't configured")
+}
+
+func (d *Dummy) SaveDestinationMode(destinationId, payload string) error {
+	return nil
+}
+
+func (d *Dummy) GetDestinationModes() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (d *Dummy) SaveFeatureFlag(name, payload string) error {
+	return nil
+}
+
+func (d *Dummy) GetFeatureFlags() (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (d *Dummy) DeleteFeatureFlag(name string) error {
+	return nil
+}
+
+func (d *Dummy) GetState() (string, int64, error) {
+	return "", 0, nil
+}
+
+func (d *Dummy) SaveState(payload string, expectedVersion int64) (int64, error) {
+	return expectedVersion + 1, nil
+}
+
+func (d *Dummy) IncrementPipelineStage(id, stage string, hour time.Time, value int) error {
+	return nil
+}
+
+func (d *Dummy) GetPipelineStages(id string, start, end time.Time) (map[time.Time]map[string]int, error) {
+	return map[time.Time]map[string]int{}, nil
+}
+
+func (d *Dummy) Ping() error {
+	return nil
+}
+
 func (d *Dummy) Type() string {
 	return DummyType
 }
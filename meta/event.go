@@ -1,7 +1,39 @@
 package meta
 
+const (
+	TriggeredByApi     = "api"
+	TriggeredByCluster = "cluster"
+	TriggeredByDryRun  = "dry_run"
+	TriggeredByReset   = "reset"
+
+	//maxSyncTasksHistory is a number of the last sync tasks which are kept per collection
+	maxSyncTasksHistory = 100
+)
+
 type Event struct {
 	Original string `json:"original,omitempty" redis:"original"`
 	Success  string `json:"success,omitempty" redis:"success"`
 	Error    string `json:"error,omitempty" redis:"error"`
+	//Rejected is a payload (with table and error type) which was rejected by the destination
+	Rejected string `json:"rejected,omitempty" redis:"rejected"`
+	TokenId  string `json:"token_id,omitempty" redis:"token_id"`
+	//destination configuration hash which was active when the error occurred
+	ConfigHash string `json:"config_hash,omitempty" redis:"config_hash"`
+}
+
+//EventIndex is an events cache index item: event id with unix timestamp (seconds) of caching
+type EventIndex struct {
+	EventId   string
+	Timestamp int64
+}
+
+//SyncTask is a finished source collection sync run
+type SyncTask struct {
+	Status      string `json:"status"`
+	StartedAt   string `json:"started_at"`
+	FinishedAt  string `json:"finished_at"`
+	RowsSynced  int    `json:"rows_synced"`
+	Error       string `json:"error,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	Instance    string `json:"instance,omitempty"`
 }
@@ -0,0 +1,95 @@
+package meta
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strconv"
+	"time"
+)
+
+//events counters namespaces
+const (
+	DestinationNamespace = "destination"
+	TokenNamespace       = "token"
+)
+
+//events counters statuses
+const (
+	SuccessStatus = "success"
+	SkipStatus    = "skip"
+	ErrorStatus   = "errors"
+)
+
+//events counters granularities
+const (
+	HourGranularity = "hour"
+	DayGranularity  = "day"
+)
+
+//eventsCountersHash is a hash of hourly counters of the day (fields are hours)
+//or of daily counters of the month (fields are days of the month)
+type eventsCountersHash struct {
+	key         string
+	start       time.Time
+	granularity string
+}
+
+func hourlyEventsKey(namespace, id, status string, day time.Time) string {
+	return "hourly_events:" + namespace + "#" + id + ":day#" + day.Format(timestamp.DayLayout) + ":" + status
+}
+
+func dailyEventsKey(namespace, id, status string, month time.Time) string {
+	return "daily_events:" + namespace + "#" + id + ":month#" + month.Format(timestamp.MonthLayout) + ":" + status
+}
+
+//eventsCountersHashes return hashes which contain counters of [start, end] period with the granularity
+func eventsCountersHashes(namespace, id, status, granularity string, start, end time.Time) ([]eventsCountersHash, error) {
+	start = start.UTC()
+	end = end.UTC()
+
+	var hashes []eventsCountersHash
+	switch granularity {
+	case HourGranularity:
+		for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+			hashes = append(hashes, eventsCountersHash{key: hourlyEventsKey(namespace, id, status, day), start: day, granularity: granularity})
+		}
+	case DayGranularity:
+		for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(end); month = month.AddDate(0, 1, 0) {
+			hashes = append(hashes, eventsCountersHash{key: dailyEventsKey(namespace, id, status, month), start: month, granularity: granularity})
+		}
+	default:
+		return nil, fmt.Errorf("Unknown granularity [%s]. Available: [%s, %s]", granularity, HourGranularity, DayGranularity)
+	}
+
+	return hashes, nil
+}
+
+//bucket return start time of the counter bucket by the hash field
+func (ech eventsCountersHash) bucket(field string) (time.Time, bool) {
+	n, err := strconv.Atoi(field)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if ech.granularity == HourGranularity {
+		return ech.start.Add(time.Duration(n) * time.Hour), true
+	}
+	return ech.start.AddDate(0, 0, n-1), true
+}
+
+//collectEventsCounters put hash counters of buckets in [start, end] into result
+func collectEventsCounters(hash eventsCountersHash, counters map[string]int, start, end time.Time, result map[time.Time]int) {
+	if hash.granularity == HourGranularity {
+		start = start.UTC().Truncate(time.Hour)
+	} else {
+		start = start.UTC().Truncate(24 * time.Hour)
+	}
+
+	for field, value := range counters {
+		bucket, ok := hash.bucket(field)
+		if !ok || bucket.Before(start) || bucket.After(end) {
+			continue
+		}
+		result[bucket] += value
+	}
+}
@@ -1,6 +1,7 @@
 package meta
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gomodule/redigo/redis"
 	"github.com/jitsucom/eventnative/logging"
@@ -11,8 +12,17 @@ import (
 	"time"
 )
 
-var updateOneFieldCachedEvent = redis.NewScript(3, `if redis.call('exists',KEYS[1]) == 1 then redis.call('hset', KEYS[1], KEYS[2], KEYS[3]) end`)
 var updateTwoFieldsCachedEvent = redis.NewScript(5, `if redis.call('exists',KEYS[1]) == 1 then redis.call('hmset', KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]) end`)
+var updateThreeFieldsCachedEvent = redis.NewScript(7, `if redis.call('exists',KEYS[1]) == 1 then redis.call('hmset', KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5], KEYS[6], KEYS[7]) end`)
+
+//saveState set state payload and increment version only if the current version is equal to ARGV[1]. Return new version or -1 on conflict
+var saveState = redis.NewScript(1, `local version = tonumber(redis.call('hget', KEYS[1], 'version') or '0') if version ~= tonumber(ARGV[1]) then return -1 end redis.call('hmset', KEYS[1], 'payload', ARGV[2], 'version', version + 1) return version + 1`)
+
+const (
+	destinationModesKey = "destination_modes"
+	featureFlagsKey     = "feature_flags"
+	stateKey            = "state"
+)
 
 type Redis struct {
 	pool *redis.Pool
@@ -23,6 +33,7 @@ type Redis struct {
 //source#sourceId:collection#collectionId:chunks [sourceId, collectionId] - hashtable with signatures
 //source#sourceId:collection#collectionId:status [sourceId, collectionId] - hashtable with collection statuses
 //source#sourceId:collection#collectionId:log    [sourceId, collectionId] - hashtable with reloading logs
+//source#sourceId:collection#collectionId:tasks  [sourceId, collectionId] - list with the last sync tasks JSON (the newest first)
 //
 //events caching
 //hourly_events:destination#destinationId:day#yyyymmdd:success [hour] - hashtable with success events counter by hour
@@ -30,11 +41,34 @@ type Redis struct {
 //daily_events:destination#destinationId:month#yyyymm:success  [day] - hashtable with success events counter by day
 //daily_events:destination#destinationId:month#yyyymm:errors   [day] - hashtable with error events counter by day
 //
-//last_events:destination#destinationId:id#eventn_ctx_event_id [original, success, error] - hashtable with original event json, processed with schema json, error json
+//tokens quotas
+//quota_usage:token#tokenId:period#yyyymmdd|yyyymm - string with events counter of the token quota period, with ttl
+//
+//pipeline stages counting
+//pipeline_stages:id#id:day#yyyymmdd [stage#hour] - hashtable with events counter by stage and hour (id is token_tokenId or destination_destinationId)
+//
+//unique users counting
+//daily_uniques:id#id:day#yyyymmdd - HyperLogLog with anonymous ids per day (id is token_tokenId or destination_destinationId)
+//
+//last_events:destination#destinationId:id#eventn_ctx_event_id [original, success, error, token_id, config_hash] - hashtable with original event json, processed with schema json, error json, token id, destination config hash of error
 //last_events_index:destination#destinationId [timestamp_long eventn_ctx_event_id] - sorted set of eventIds and timestamps
+//last_events_token_index:destination#destinationId:token#tokenId [timestamp_long eventn_ctx_event_id] - sorted set of the token eventIds and timestamps
+//
+//destinations configuration snapshots
+//config_snapshots:destination#destinationId [hash] - hashtable with destination configuration json (without credentials) by config hash
 //
 //retrospective user recognition
 //anonymous_events:destination_id#${destination_id}:anonymous_id#${cookies_anonymous_id} [event_id] {event JSON} - hashtable with all anonymous events
+//destination_modes [destinationId] - hashtable with destination mode JSON (current mode and the last mode transition)
+//
+//events deduplication
+//dedup:scope#scope:event#eventn_ctx_event_id - string with origin (e.g. batch file name) where event was seen first, with ttl = dedup window
+//
+//feature flags
+//feature_flags [name] - hashtable with feature flag JSON by name
+//
+//declarative configuration state
+//state [payload, version] - hashtable with state JSON and its version (incremented on every saving)
 func NewRedis(host string, port int, password string) (*Redis, error) {
 	logging.Infof("Initializing redis [%s:%d]...", host, port)
 	r := &Redis{pool: &redis.Pool{
@@ -105,6 +139,20 @@ func (r *Redis) SaveSignature(sourceId, collection, interval, signature string)
 	return nil
 }
 
+//DeleteSignatures remove collection signatures hashtable
+func (r *Redis) DeleteSignatures(sourceId, collection string) error {
+	key := "source#" + sourceId + ":collection#" + collection + ":chunks"
+	connection := r.pool.Get()
+	defer connection.Close()
+	_, err := connection.Do("DEL", key)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *Redis) GetCollectionStatus(sourceId, collection string) (string, error) {
 	key := "source#" + sourceId + ":collection#" + collection + ":status"
 	field := "current"
@@ -169,21 +217,173 @@ func (r *Redis) SaveCollectionLog(sourceId, collection, log string) error {
 	return nil
 }
 
-func (r *Redis) SuccessEvents(destinationId string, now time.Time, value int) error {
-	return r.incrementEventsCount(destinationId, "success", now, value)
+//SaveSyncTask push sync task to the head of collection tasks list and trim the list to the history size
+func (r *Redis) SaveSyncTask(sourceId, collection string, task *SyncTask) error {
+	key := "source#" + sourceId + ":collection#" + collection + ":tasks"
+	b, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("Error serializing sync task: %v", err)
+	}
+
+	connection := r.pool.Get()
+	defer connection.Close()
+	_, err = connection.Do("LPUSH", key, b)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	_, err = connection.Do("LTRIM", key, 0, maxSyncTasksHistory-1)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//GetSyncTasks return page of collection sync tasks (the newest first) and total tasks count
+func (r *Redis) GetSyncTasks(sourceId, collection string, offset, limit int) ([]SyncTask, int, error) {
+	key := "source#" + sourceId + ":collection#" + collection + ":tasks"
+	connection := r.pool.Get()
+	defer connection.Close()
+
+	total, err := redis.Int(connection.Do("LLEN", key))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return nil, 0, err
+	}
+
+	values, err := redis.ByteSlices(connection.Do("LRANGE", key, offset, offset+limit-1))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return nil, 0, err
+	}
+
+	tasks := []SyncTask{}
+	for _, value := range values {
+		task := SyncTask{}
+		if err := json.Unmarshal(value, &task); err != nil {
+			return nil, 0, fmt.Errorf("Error deserializing sync task [%s]: %v", string(value), err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, total, nil
+}
+
+//IncrementEventsCounter increment hourly and daily events counters of the status
+func (r *Redis) IncrementEventsCounter(namespace, id, status string, now time.Time, value int) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	//increment hourly events
+	_, err := conn.Do("HINCRBY", hourlyEventsKey(namespace, id, status, now), strconv.Itoa(now.Hour()), value)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	//increment daily events
+	_, err = conn.Do("HINCRBY", dailyEventsKey(namespace, id, status, now), strconv.Itoa(now.Day()), value)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//GetEventsCounters return events counters of the status by hour or day for buckets in [start, end]
+func (r *Redis) GetEventsCounters(namespace, id, status, granularity string, start, end time.Time) (map[time.Time]int, error) {
+	hashes, err := eventsCountersHashes(namespace, id, status, granularity, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	result := map[time.Time]int{}
+	for _, hash := range hashes {
+		counters, err := redis.IntMap(conn.Do("HGETALL", hash.key))
+		noticeError(err)
+		if err != nil {
+			if err == redis.ErrNil {
+				continue
+			}
+			return nil, err
+		}
+
+		collectEventsCounters(hash, counters, start, end.UTC(), result)
+	}
+
+	return result, nil
+}
+
+//IncrementQuotaUsage increment token quota period counter and prolong its ttl
+func (r *Redis) IncrementQuotaUsage(tokenId, period string, value int, ttl time.Duration) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	quotaKey := "quota_usage:token#" + tokenId + ":period#" + period
+	total, err := redis.Int(conn.Do("INCRBY", quotaKey, value))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	_, err = conn.Do("EXPIRE", quotaKey, int(ttl.Seconds()))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (r *Redis) AddUniqueUser(id, anonymousId string, now time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	dailyUniquesKey := "daily_uniques:id#" + id + ":day#" + now.Format(timestamp.DayLayout)
+	_, err := conn.Do("PFADD", dailyUniquesKey, anonymousId)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
 }
 
-func (r *Redis) ErrorEvents(destinationId string, now time.Time, value int) error {
-	return r.incrementEventsCount(destinationId, "errors", now, value)
+//CountUniqueUsers return approximated count of unique users in [start, end] days range
+//(union of all daily HyperLogLogs)
+func (r *Redis) CountUniqueUsers(id string, start, end time.Time) (int, error) {
+	var keys []interface{}
+	for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+		keys = append(keys, "daily_uniques:id#"+id+":day#"+day.Format(timestamp.DayLayout))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("PFCOUNT", keys...))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	return count, nil
 }
 
-func (r *Redis) AddEvent(destinationId, eventId, payload string, now time.Time) (int, error) {
+func (r *Redis) AddEvent(destinationId, eventId, tokenId, payload string, now time.Time) (int, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
 	//add event
 	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
-	field := "original"
-	_, err := conn.Do("HSET", lastEventsKey, field, payload)
+	_, err := conn.Do("HSET", lastEventsKey, "original", payload, "token_id", tokenId)
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
 		return 0, err
@@ -197,6 +397,15 @@ func (r *Redis) AddEvent(destinationId, eventId, payload string, now time.Time)
 		return 0, err
 	}
 
+	//enrich token index
+	if tokenId != "" {
+		_, err = conn.Do("ZADD", tokenEventsIndexKey(destinationId, tokenId), now.Unix(), eventId)
+		noticeError(err)
+		if err != nil && err != redis.ErrNil {
+			return 0, err
+		}
+	}
+
 	//get index length
 	count, err := redis.Int(conn.Do("ZCOUNT", lastEventsIndexKey, "-inf", "+inf"))
 	noticeError(err)
@@ -213,7 +422,7 @@ func (r *Redis) UpdateSucceedEvent(destinationId, eventId, success string) error
 	conn := r.pool.Get()
 	defer conn.Close()
 
-	_, err := updateTwoFieldsCachedEvent.Do(conn, lastEventsKey, "success", success, "error", "")
+	_, err := updateThreeFieldsCachedEvent.Do(conn, lastEventsKey, "success", success, "error", "", "rejected", "")
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
 		return err
@@ -222,13 +431,29 @@ func (r *Redis) UpdateSucceedEvent(destinationId, eventId, success string) error
 	return nil
 }
 
-func (r *Redis) UpdateErrorEvent(destinationId, eventId, error string) error {
+func (r *Redis) UpdateErrorEvent(destinationId, eventId, error, configHash string) error {
 	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
 
 	conn := r.pool.Get()
 	defer conn.Close()
 
-	_, err := updateOneFieldCachedEvent.Do(conn, lastEventsKey, "error", error)
+	_, err := updateTwoFieldsCachedEvent.Do(conn, lastEventsKey, "error", error, "config_hash", configHash)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//UpdateRejectedEvent write error and rejected payload into the cached event
+func (r *Redis) UpdateRejectedEvent(destinationId, eventId, error, rejected, configHash string) error {
+	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := updateThreeFieldsCachedEvent.Do(conn, lastEventsKey, "error", error, "rejected", rejected, "config_hash", configHash)
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
 		return err
@@ -255,6 +480,19 @@ func (r *Redis) RemoveLastEvent(destinationId string) error {
 	eventId := values[0]
 
 	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+	tokenId, err := redis.String(conn.Do("HGET", lastEventsKey, "token_id"))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+	if tokenId != "" {
+		_, err = conn.Do("ZREM", tokenEventsIndexKey(destinationId, tokenId), eventId)
+		noticeError(err)
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+	}
+
 	_, err = conn.Do("DEL", lastEventsKey)
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
@@ -264,6 +502,83 @@ func (r *Redis) RemoveLastEvent(destinationId string) error {
 	return nil
 }
 
+//GetTotalTokenEvents return amount of the token events in the destination cache
+func (r *Redis) GetTotalTokenEvents(destinationId, tokenId string) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("ZCARD", tokenEventsIndexKey(destinationId, tokenId)))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+//RemoveLastTokenEvent remove the oldest token event from the destination cache
+func (r *Redis) RemoveLastTokenEvent(destinationId, tokenId string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Strings(conn.Do("ZPOPMIN", tokenEventsIndexKey(destinationId, tokenId)))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	if len(values) != 2 {
+		return fmt.Errorf("Error response format: %v", values)
+	}
+
+	return r.removeCachedEvent(conn, destinationId, values[0])
+}
+
+//RemoveTokenEventsBefore remove the token events which were cached before the time from the destination cache
+//return amount of removed events
+func (r *Redis) RemoveTokenEventsBefore(destinationId, tokenId string, before time.Time) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	tokenIndexKey := tokenEventsIndexKey(destinationId, tokenId)
+	eventIds, err := redis.Strings(conn.Do("ZRANGEBYSCORE", tokenIndexKey, "-inf", "("+strconv.FormatInt(before.Unix(), 10)))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return 0, err
+	}
+
+	for i, eventId := range eventIds {
+		_, err = conn.Do("ZREM", tokenIndexKey, eventId)
+		noticeError(err)
+		if err != nil && err != redis.ErrNil {
+			return i, err
+		}
+
+		if err := r.removeCachedEvent(conn, destinationId, eventId); err != nil {
+			return i, err
+		}
+	}
+
+	return len(eventIds), nil
+}
+
+//removeCachedEvent remove event from the destination index and event itself
+func (r *Redis) removeCachedEvent(conn redis.Conn, destinationId, eventId string) error {
+	_, err := conn.Do("ZREM", "last_events_index:destination#"+destinationId, eventId)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	_, err = conn.Do("DEL", "last_events:destination#"+destinationId+":id#"+eventId)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *Redis) GetEvents(destinationId string, start, end time.Time, n int) ([]Event, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
@@ -299,6 +614,55 @@ func (r *Redis) GetEvents(destinationId string, start, end time.Time, n int) ([]
 	return events, nil
 }
 
+//GetEventsIndex return at most n event ids with timestamps in [start, end] ordered by timestamp, event id
+//skip first offset items
+func (r *Redis) GetEventsIndex(destinationId string, start, end time.Time, offset, n int) ([]EventIndex, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	lastEventsIndexKey := "last_events_index:destination#" + destinationId
+	values, err := redis.Strings(conn.Do("ZRANGEBYSCORE", lastEventsIndexKey, start.Unix(), end.Unix(), "WITHSCORES", "LIMIT", offset, n))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+
+	index := []EventIndex{}
+	for i := 0; i+1 < len(values); i += 2 {
+		ts, err := strconv.ParseInt(values[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing event [%s] index score [%s]: %v", values[i], values[i+1], err)
+		}
+		index = append(index, EventIndex{EventId: values[i], Timestamp: ts})
+	}
+
+	return index, nil
+}
+
+//GetEvent return cached event or nil if it doesn't exist
+func (r *Redis) GetEvent(destinationId, eventId string) (*Event, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	lastEventsKey := "last_events:destination#" + destinationId + ":id#" + eventId
+	values, err := redis.Values(conn.Do("HGETALL", lastEventsKey))
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	event := &Event{}
+	if err := redis.ScanStruct(values, event); err != nil {
+		return nil, fmt.Errorf("Error deserializing event struct key [%s]: %v", lastEventsKey, err)
+	}
+
+	return event, nil
+}
+
 func (r *Redis) GetTotalEvents(destinationId string) (int, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
@@ -313,6 +677,38 @@ func (r *Redis) GetTotalEvents(destinationId string) (int, error) {
 	return count, nil
 }
 
+func (r *Redis) SaveConfigSnapshot(destinationId, hash, snapshot string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	configSnapshotsKey := "config_snapshots:destination#" + destinationId
+	_, err := conn.Do("HSET", configSnapshotsKey, hash, snapshot)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//GetConfigSnapshot return destination configuration json by hash or empty string if it doesn't exist
+func (r *Redis) GetConfigSnapshot(destinationId, hash string) (string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	configSnapshotsKey := "config_snapshots:destination#" + destinationId
+	snapshot, err := redis.String(conn.Do("HGET", configSnapshotsKey, hash))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return snapshot, nil
+}
+
 func (r *Redis) SaveAnonymousEvent(destinationId, anonymousId, eventId, payload string) error {
 	conn := r.pool.Get()
 	defer conn.Close()
@@ -357,33 +753,163 @@ func (r *Redis) DeleteAnonymousEvent(destinationId, anonymousId, eventId string)
 	return nil
 }
 
-func (r *Redis) Type() string {
-	return RedisType
+//SaveIdentity save anonymous id -> user id mapping with ttl (without expiration if ttl is 0)
+func (r *Redis) SaveIdentity(anonymousId, userId string, ttl time.Duration) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	identityKey := "identities:anonymous_id#" + anonymousId
+	var err error
+	if ttl > 0 {
+		_, err = conn.Do("SET", identityKey, userId, "EX", int(ttl.Seconds()))
+	} else {
+		_, err = conn.Do("SET", identityKey, userId)
+	}
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
 }
 
-func (r *Redis) Close() error {
-	return r.pool.Close()
+//GetIdentity return user id by anonymous id or empty string if mapping doesn't exist
+func (r *Redis) GetIdentity(anonymousId string) (string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	identityKey := "identities:anonymous_id#" + anonymousId
+	userId, err := redis.String(conn.Do("GET", identityKey))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return userId, nil
 }
 
-//increment success or errors keys depends on input status string
-func (r *Redis) incrementEventsCount(destinationId, status string, now time.Time, value int) error {
+//MarkEventSeen mark event as seen in the scope for ttl
+//return true if event hasn't been seen yet or has been seen from the same origin (retries of the same batch aren't duplicates)
+func (r *Redis) MarkEventSeen(scope, eventId, origin string, ttl time.Duration) (bool, error) {
 	conn := r.pool.Get()
 	defer conn.Close()
-	//increment hourly events
-	dayKey := now.Format(timestamp.DayLayout)
-	hourlyEventsKey := "hourly_events:destination#" + destinationId + ":day#" + dayKey + ":" + status
-	fieldHour := strconv.Itoa(now.Hour())
-	_, err := conn.Do("HINCRBY", hourlyEventsKey, fieldHour, 1)
+
+	dedupKey := "dedup:scope#" + scope + ":event#" + eventId
+	_, err := redis.String(conn.Do("SET", dedupKey, origin, "NX", "EX", int(ttl.Seconds())))
+	noticeError(err)
+	if err == nil {
+		return true, nil
+	}
+	if err != redis.ErrNil {
+		return false, err
+	}
+
+	//key already exists
+	if origin == "" {
+		return false, nil
+	}
+	seenOrigin, err := redis.String(conn.Do("GET", dedupKey))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			//key has been just expired
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return seenOrigin == origin, nil
+}
+
+//MarkNonceUsed return true if nonce hasn't been used in the scope within ttl and save it
+func (r *Redis) MarkNonceUsed(scope, nonce string, ttl time.Duration) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	nonceKey := "replay:scope#" + scope + ":nonce#" + nonce
+	_, err := redis.String(conn.Do("SET", nonceKey, time.Now().UTC().Unix(), "NX", "EX", int(ttl.Seconds())))
+	noticeError(err)
+	if err == nil {
+		return true, nil
+	}
+	if err == redis.ErrNil {
+		return false, nil
+	}
+
+	return false, err
+}
+
+//IncrementPipelineStage increment stage events counter of the hour
+func (r *Redis) IncrementPipelineStage(id, stage string, hour time.Time, value int) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	stagesKey := "pipeline_stages:id#" + id + ":day#" + hour.Format(timestamp.DayLayout)
+	_, err := conn.Do("HINCRBY", stagesKey, stage+"#"+strconv.Itoa(hour.Hour()), value)
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
 		return err
 	}
 
-	//increment daily events
-	monthKey := now.Format(timestamp.MonthLayout)
-	dailyEventsKey := "daily_events:destination#" + destinationId + ":month#" + monthKey + ":" + status
-	fieldDay := strconv.Itoa(now.Day())
-	_, err = conn.Do("HINCRBY", dailyEventsKey, fieldDay, value)
+	return nil
+}
+
+//GetPipelineStages return events counters by hour and stage for hours in [start, end]
+func (r *Redis) GetPipelineStages(id string, start, end time.Time) (map[time.Time]map[string]int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	start = start.UTC().Truncate(time.Hour)
+	end = end.UTC()
+	result := map[time.Time]map[string]int{}
+	for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+		stagesKey := "pipeline_stages:id#" + id + ":day#" + day.Format(timestamp.DayLayout)
+		counters, err := redis.IntMap(conn.Do("HGETALL", stagesKey))
+		noticeError(err)
+		if err != nil {
+			if err == redis.ErrNil {
+				continue
+			}
+			return nil, err
+		}
+
+		for field, value := range counters {
+			parts := strings.Split(field, "#")
+			if len(parts) != 2 {
+				continue
+			}
+			h, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			hour := day.Add(time.Duration(h) * time.Hour)
+			if hour.Before(start) || hour.After(end) {
+				continue
+			}
+
+			stages, ok := result[hour]
+			if !ok {
+				stages = map[string]int{}
+				result[hour] = stages
+			}
+			stages[parts[0]] += value
+		}
+	}
+
+	return result, nil
+}
+
+//SaveDestinationMode save destination mode JSON
+func (r *Redis) SaveDestinationMode(destinationId, payload string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HSET", destinationModesKey, destinationId, payload)
 	noticeError(err)
 	if err != nil && err != redis.ErrNil {
 		return err
@@ -392,6 +918,293 @@ func (r *Redis) incrementEventsCount(destinationId, status string, now time.Time
 	return nil
 }
 
+//GetDestinationModes return all destinations modes JSON by destination id
+func (r *Redis) GetDestinationModes() (map[string]string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	modes, err := redis.StringMap(conn.Do("HGETALL", destinationModesKey))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return map[string]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	return modes, nil
+}
+
+//SaveFeatureFlag save feature flag JSON by name
+func (r *Redis) SaveFeatureFlag(name, payload string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HSET", featureFlagsKey, name, payload)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//GetFeatureFlags return all feature flags JSON by name
+func (r *Redis) GetFeatureFlags() (map[string]string, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	flags, err := redis.StringMap(conn.Do("HGETALL", featureFlagsKey))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return map[string]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+//DeleteFeatureFlag remove feature flag by name
+func (r *Redis) DeleteFeatureFlag(name string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HDEL", featureFlagsKey, name)
+	noticeError(err)
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	return nil
+}
+
+//GetState return state JSON and its version or empty string and 0 if state hasn't been saved
+func (r *Redis) GetState() (string, int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Strings(conn.Do("HMGET", stateKey, "payload", "version"))
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", 0, nil
+		}
+
+		return "", 0, err
+	}
+
+	if values[1] == "" {
+		return "", 0, nil
+	}
+	version, err := strconv.ParseInt(values[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Error parsing state version [%s]: %v", values[1], err)
+	}
+
+	return values[0], version, nil
+}
+
+//SaveState save state JSON if the stored version is equal to expectedVersion (0 if state hasn't been saved)
+//return new version or ErrStateVersionConflict
+func (r *Redis) SaveState(payload string, expectedVersion int64) (int64, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	version, err := redis.Int64(saveState.Do(conn, stateKey, expectedVersion, payload))
+	noticeError(err)
+	if err != nil {
+		return 0, err
+	}
+	if version < 0 {
+		return 0, ErrStateVersionConflict
+	}
+
+	return version, nil
+}
+
+//Export return snapshot with all keys which match at least one pattern (all keys if patterns are empty)
+func (r *Redis) Export(patterns []string) (*Snapshot, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+
+	snapshot := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC(), Source: RedisType, Entries: []*SnapshotEntry{}}
+	exported := map[string]bool{}
+	for _, pattern := range patterns {
+		cursor := 0
+		for {
+			values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 1000))
+			noticeError(err)
+			if err != nil {
+				return nil, fmt.Errorf("Error scanning keys [%s]: %v", pattern, err)
+			}
+
+			cursor, _ = redis.Int(values[0], nil)
+			keys, _ := redis.Strings(values[1], nil)
+			for _, key := range keys {
+				if exported[key] {
+					continue
+				}
+				entry, err := r.exportKey(conn, key)
+				if err != nil {
+					return nil, fmt.Errorf("Error exporting key [%s]: %v", key, err)
+				}
+				//key has been expired or removed while scanning
+				if entry == nil {
+					continue
+				}
+				exported[key] = true
+				snapshot.Entries = append(snapshot.Entries, entry)
+			}
+
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+//Import write all snapshot entries (existing keys are overwritten)
+func (r *Redis) Import(snapshot *Snapshot) error {
+	if err := snapshot.Validate(); err != nil {
+		return err
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	for _, entry := range snapshot.Entries {
+		if err := r.importEntry(conn, entry); err != nil {
+			return fmt.Errorf("Error importing key [%s]: %v", entry.Key, err)
+		}
+	}
+
+	return nil
+}
+
+//exportKey return snapshot entry or nil if key doesn't exist
+func (r *Redis) exportKey(conn redis.Conn, key string) (*SnapshotEntry, error) {
+	keyType, err := redis.String(conn.Do("TYPE", key))
+	noticeError(err)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &SnapshotEntry{Key: key}
+	switch keyType {
+	case "none":
+		return nil, nil
+	case "string":
+		entry.Type = StringEntry
+		entry.Value, err = redis.Bytes(conn.Do("GET", key))
+	case "hash":
+		entry.Type = HashEntry
+		entry.Hash, err = redis.StringMap(conn.Do("HGETALL", key))
+	case "list":
+		entry.Type = ListEntry
+		entry.List, err = redis.Strings(conn.Do("LRANGE", key, 0, -1))
+	case "zset":
+		entry.Type = ZSetEntry
+		var values []string
+		values, err = redis.Strings(conn.Do("ZRANGE", key, 0, -1, "WITHSCORES"))
+		for i := 0; err == nil && i+1 < len(values); i += 2 {
+			var score float64
+			score, err = strconv.ParseFloat(values[i+1], 64)
+			entry.ZSet = append(entry.ZSet, &ZMember{Member: values[i], Score: score})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported redis type: %s", keyType)
+	}
+	noticeError(err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ttl, err := redis.Int64(conn.Do("PTTL", key))
+	noticeError(err)
+	if err != nil {
+		return nil, err
+	}
+	//-2 key doesn't exist, -1 key without expiration
+	if ttl == -2 {
+		return nil, nil
+	}
+	if ttl > 0 {
+		entry.TTLMs = ttl
+	}
+
+	return entry, nil
+}
+
+func (r *Redis) importEntry(conn redis.Conn, entry *SnapshotEntry) error {
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	conn.Send("DEL", entry.Key)
+	switch entry.Type {
+	case StringEntry:
+		conn.Send("SET", entry.Key, entry.Value)
+	case HashEntry:
+		if len(entry.Hash) > 0 {
+			args := redis.Args{}.Add(entry.Key).AddFlat(entry.Hash)
+			conn.Send("HMSET", args...)
+		}
+	case ListEntry:
+		if len(entry.List) > 0 {
+			args := redis.Args{}.Add(entry.Key).AddFlat(entry.List)
+			conn.Send("RPUSH", args...)
+		}
+	case ZSetEntry:
+		if len(entry.ZSet) > 0 {
+			args := redis.Args{}.Add(entry.Key)
+			for _, member := range entry.ZSet {
+				args = args.Add(member.Score, member.Member)
+			}
+			conn.Send("ZADD", args...)
+		}
+	}
+	if entry.TTLMs > 0 {
+		conn.Send("PEXPIRE", entry.Key, entry.TTLMs)
+	}
+
+	_, err := conn.Do("EXEC")
+	noticeError(err)
+	return err
+}
+
+func (r *Redis) Ping() error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	noticeError(err)
+	return err
+}
+
+func (r *Redis) Type() string {
+	return RedisType
+}
+
+func (r *Redis) Close() error {
+	return r.pool.Close()
+}
+
+func tokenEventsIndexKey(destinationId, tokenId string) string {
+	return "last_events_token_index:destination#" + destinationId + ":token#" + tokenId
+}
+
 func noticeError(err error) {
 	if err != nil {
 		if err == redis.ErrPoolExhausted {
@@ -0,0 +1,64 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	SnapshotVersion = 1
+
+	StringEntry = "string"
+	HashEntry   = "hash"
+	ListEntry   = "list"
+	ZSetEntry   = "zset"
+)
+
+//Snapshot is a portable (backend independent) meta storage state: sync cursors and statuses, counters, events cache, etc.
+//It is used for migrations between meta storages and disaster recovery restores
+type Snapshot struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Source    string           `json:"source"`
+	Entries   []*SnapshotEntry `json:"entries"`
+}
+
+//SnapshotEntry is one meta storage key with value according to type:
+//string - Value (binary safe, e.g. HyperLogLog), hash - Hash, list - List, zset - ZSet
+//TTL is a remaining time to live of the key (0 - without expiration)
+type SnapshotEntry struct {
+	Key   string            `json:"key"`
+	Type  string            `json:"type"`
+	TTLMs int64             `json:"ttl_ms,omitempty"`
+	Value []byte            `json:"value,omitempty"`
+	Hash  map[string]string `json:"hash,omitempty"`
+	List  []string          `json:"list,omitempty"`
+	ZSet  []*ZMember        `json:"zset,omitempty"`
+}
+
+//ZMember is a sorted set member with score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+//Validate check snapshot version and entries types
+func (s *Snapshot) Validate() error {
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version: %d. Supported: %d", s.Version, SnapshotVersion)
+	}
+
+	for _, entry := range s.Entries {
+		if entry.Key == "" {
+			return errors.New("Snapshot entry key can't be empty")
+		}
+		switch entry.Type {
+		case StringEntry, HashEntry, ListEntry, ZSetEntry:
+		default:
+			return fmt.Errorf("Unknown snapshot entry [%s] type: %s", entry.Key, entry.Type)
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package meta
 
 import (
+	"errors"
 	"github.com/spf13/viper"
 	"io"
 	"time"
@@ -13,38 +14,98 @@ const (
 
 	DummyType = "Dummy"
 	RedisType = "Redis"
+	BoltType  = "Bolt"
 )
 
+//ErrStateVersionConflict is returned by SaveState if the stored state version isn't equal to the expected one
+var ErrStateVersionConflict = errors.New("state has been changed concurrently: version conflict")
+
 type Storage interface {
 	io.Closer
 
 	//sources
 	GetSignature(sourceId, collection, interval string) (string, error)
 	SaveSignature(sourceId, collection, interval, signature string) error
+	//DeleteSignatures remove all collection signatures (the next sync is a full re-sync)
+	DeleteSignatures(sourceId, collection string) error
 
 	GetCollectionStatus(sourceId, collection string) (string, error)
 	SaveCollectionStatus(sourceId, collection, status string) error
 	GetCollectionLog(sourceId, collection string) (string, error)
 	SaveCollectionLog(sourceId, collection, log string) error
+	SaveSyncTask(sourceId, collection string, task *SyncTask) error
+	GetSyncTasks(sourceId, collection string, offset, limit int) ([]SyncTask, int, error)
+
+	//events counters per hour and day of the namespace (destination or token) id by status (success, skip or errors)
+	IncrementEventsCounter(namespace, id, status string, now time.Time, value int) error
+	//GetEventsCounters return counters by hour or day (granularity) start time for buckets in [start, end]
+	GetEventsCounters(namespace, id, status, granularity string, start, end time.Time) (map[time.Time]int, error)
+
+	//tokens quotas usage counters per period (shared between cluster nodes). Return the new value
+	IncrementQuotaUsage(tokenId, period string, value int, ttl time.Duration) (int, error)
+
+	//pipeline stages counters per hour
+	IncrementPipelineStage(id, stage string, hour time.Time, value int) error
+	GetPipelineStages(id string, start, end time.Time) (map[time.Time]map[string]int, error)
 
-	//events counters
-	SuccessEvents(destinationId string, now time.Time, value int) error
-	ErrorEvents(destinationId string, now time.Time, value int) error
+	//unique users counters (HyperLogLog)
+	AddUniqueUser(id, anonymousId string, now time.Time) error
+	CountUniqueUsers(id string, start, end time.Time) (int, error)
 
 	//events caching
-	AddEvent(destinationId, eventId, payload string, now time.Time) (int, error)
+	AddEvent(destinationId, eventId, tokenId, payload string, now time.Time) (int, error)
 	UpdateSucceedEvent(destinationId, eventId, success string) error
-	UpdateErrorEvent(destinationId, eventId, error string) error
+	UpdateErrorEvent(destinationId, eventId, error, configHash string) error
+	UpdateRejectedEvent(destinationId, eventId, error, rejected, configHash string) error
 	RemoveLastEvent(destinationId string) error
+	//token scoped events caching (each token has own capacity and retention)
+	GetTotalTokenEvents(destinationId, tokenId string) (int, error)
+	RemoveLastTokenEvent(destinationId, tokenId string) error
+	RemoveTokenEventsBefore(destinationId, tokenId string, before time.Time) (int, error)
 
 	GetEvents(destinationId string, start, end time.Time, n int) ([]Event, error)
+	GetEventsIndex(destinationId string, start, end time.Time, offset, n int) ([]EventIndex, error)
+	GetEvent(destinationId, eventId string) (*Event, error)
 	GetTotalEvents(destinationId string) (int, error)
 
+	//destinations modes (batch/stream) with the last mode transition
+	SaveDestinationMode(destinationId, payload string) error
+	GetDestinationModes() (map[string]string, error)
+
+	//destinations configuration snapshots
+	SaveConfigSnapshot(destinationId, hash, snapshot string) error
+	GetConfigSnapshot(destinationId, hash string) (string, error)
+
 	//user recognition
 	SaveAnonymousEvent(destinationId, anonymousId, eventId, payload string) error
 	GetAnonymousEvents(destinationId, anonymousId string) (map[string]string, error)
 	DeleteAnonymousEvent(destinationId, anonymousId, eventId string) error
 
+	//identity stitching
+	SaveIdentity(anonymousId, userId string, ttl time.Duration) error
+	GetIdentity(anonymousId string) (string, error)
+
+	//events deduplication window
+	MarkEventSeen(scope, eventId, origin string, ttl time.Duration) (bool, error)
+
+	//signed requests replay protection
+	MarkNonceUsed(scope, nonce string, ttl time.Duration) (bool, error)
+
+	//feature flags
+	SaveFeatureFlag(name, payload string) error
+	GetFeatureFlags() (map[string]string, error)
+	DeleteFeatureFlag(name string) error
+
+	//declarative configuration state (optimistic concurrency)
+	GetState() (string, int64, error)
+	SaveState(payload string, expectedVersion int64) (int64, error)
+
+	//portable snapshots for migrations and restores
+	Export(patterns []string) (*Snapshot, error)
+	Import(snapshot *Snapshot) error
+
+	//Ping return err if storage isn't available
+	Ping() error
 	Type() string
 }
 
@@ -53,6 +114,11 @@ func NewStorage(meta *viper.Viper) (Storage, error) {
 		return &Dummy{}, nil
 	}
 
+	//embedded storage for single-node deployments
+	if meta.IsSet("bolt") {
+		return NewBolt(meta.GetString("bolt.path"))
+	}
+
 	host := meta.GetString("redis.host")
 	port := meta.GetInt("redis.port")
 	password := meta.GetString("redis.password")
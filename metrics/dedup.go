@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	duplicateEvents *prometheus.CounterVec
+)
+
+func initDedup() {
+	duplicateEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "events",
+		Name:      "duplicates",
+	}, []string{"scope"})
+}
+
+//DuplicateEvent count events which were dropped by deduplication window per scope (global or destination_id)
+func DuplicateEvent(scope string) {
+	if Enabled {
+		duplicateEvents.WithLabelValues(scope).Inc()
+	}
+}
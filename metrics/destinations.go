@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+var (
+	destinationModeLabels  = []string{"project_id", "destination_id", "mode"}
+	destinationQueueLabels = []string{"project_id", "destination_id"}
+	streamWriteLabels      = []string{"project_id", "destination_id", "result", "error_type"}
+)
+
+//Stream write results
+const (
+	StreamWriteAcknowledged = "acknowledged"
+	StreamWriteRejected     = "rejected"
+	StreamWriteRetried      = "retried"
+)
+
+var (
+	insertLatency *prometheus.HistogramVec
+	batchSize     *prometheus.HistogramVec
+	insertErrors  *prometheus.CounterVec
+	queueSize     *prometheus.GaugeVec
+	priorityQueue *prometheus.GaugeVec
+	circuitOpen   *prometheus.GaugeVec
+	streamWrites  *prometheus.CounterVec
+	healthy       *prometheus.GaugeVec
+	healthLatency *prometheus.GaugeVec
+)
+
+func initDestinations() {
+	insertLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "insert_latency_seconds",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, destinationModeLabels)
+	batchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "batch_size",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	}, destinationModeLabels)
+	insertErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "insert_errors",
+	}, destinationModeLabels)
+	queueSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "queue_size",
+	}, destinationQueueLabels)
+	priorityQueue = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "priority_queue_size",
+	}, destinationQueueLabels)
+	circuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "circuit_open",
+	}, destinationQueueLabels)
+	streamWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "stream_writes",
+	}, streamWriteLabels)
+	healthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "healthy",
+	}, destinationQueueLabels)
+	healthLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "health_check_latency_seconds",
+	}, destinationQueueLabels)
+}
+
+//DestinationInsert observe insert (stream mode) or store (batch mode) duration
+func DestinationInsert(destinationName, mode string, duration time.Duration) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		insertLatency.WithLabelValues(projectId, destinationId, mode).Observe(duration.Seconds())
+	}
+}
+
+//DestinationBatchSize observe rows count which were flushed at once
+func DestinationBatchSize(destinationName, mode string, rowsCount int) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		batchSize.WithLabelValues(projectId, destinationId, mode).Observe(float64(rowsCount))
+	}
+}
+
+func DestinationInsertError(destinationName, mode string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		insertErrors.WithLabelValues(projectId, destinationId, mode).Inc()
+	}
+}
+
+func DestinationQueueSize(destinationName string, size int) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		queueSize.WithLabelValues(projectId, destinationId).Set(float64(size))
+	}
+}
+
+//DestinationPriorityQueueSize set events count in the destination priority lane
+func DestinationPriorityQueueSize(destinationName string, size int) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		priorityQueue.WithLabelValues(projectId, destinationId).Set(float64(size))
+	}
+}
+
+//DestinationStreamWrite count stream mode write results: acknowledged, rejected (with error type) or retried
+func DestinationStreamWrite(destinationName, result, errorType string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		streamWrites.WithLabelValues(projectId, destinationId, result, errorType).Inc()
+	}
+}
+
+//DestinationCircuitOpen set 1 if destination circuit breaker is open or half-open and 0 otherwise
+func DestinationCircuitOpen(destinationName string, open bool) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		value := 0.0
+		if open {
+			value = 1
+		}
+		circuitOpen.WithLabelValues(projectId, destinationId).Set(value)
+	}
+}
+
+//DestinationHealth set 1 if the last destination health check succeeded and 0 otherwise with the check duration
+func DestinationHealth(destinationName string, ok bool, duration time.Duration) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		value := 0.0
+		if ok {
+			value = 1
+		}
+		healthy.WithLabelValues(projectId, destinationId).Set(value)
+		healthLatency.WithLabelValues(projectId, destinationId).Set(duration.Seconds())
+	}
+}
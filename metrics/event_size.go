@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var eventSizeLabels = []string{"token_id", "policy"}
+
+var (
+	oversizedEvents *prometheus.CounterVec
+)
+
+func initEventSize() {
+	oversizedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "events",
+		Name:      "oversized",
+	}, eventSizeLabels)
+}
+
+//OversizedEvent count events which exceed max size per token and applied policy (truncate/fallback)
+func OversizedEvent(tokenId, policy string) {
+	if Enabled {
+		oversizedEvents.WithLabelValues("token_"+tokenId, policy).Inc()
+	}
+}
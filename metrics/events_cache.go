@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsCacheSize *prometheus.GaugeVec
+)
+
+func initEventsCache() {
+	eventsCacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "eventnative",
+		Subsystem: "events_cache",
+		Name:      "size",
+	}, []string{"destination_id", "token_id"})
+}
+
+//EventsCacheSize set amount of the token cached events in the destination cache (token scoped events cache)
+func EventsCacheSize(destinationId, tokenId string, value int) {
+	if Enabled {
+		eventsCacheSize.WithLabelValues(destinationId, "token_"+tokenId).Set(float64(value))
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var lateEventsLabels = []string{"project_id", "destination_id", "policy"}
+
+var (
+	lateEvents *prometheus.CounterVec
+)
+
+func initLateEvents() {
+	lateEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "destinations",
+		Name:      "late_events",
+	}, lateEventsLabels)
+}
+
+func LateEvent(destinationName, policy string) {
+	if Enabled {
+		projectId, destinationId := extractLabels(destinationName)
+		lateEvents.WithLabelValues(projectId, destinationId, policy).Inc()
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	mqttEvents        *prometheus.CounterVec
+	mqttErrorMessages *prometheus.CounterVec
+)
+
+func initMqtt() {
+	mqttEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "mqtt",
+		Name:      "events",
+	}, []string{"topic"})
+	mqttErrorMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "mqtt",
+		Name:      "error_messages",
+	}, []string{"topic"})
+}
+
+func MqttMessage(topicFilter string, eventsCount int) {
+	if Enabled {
+		mqttEvents.WithLabelValues(topicFilter).Add(float64(eventsCount))
+	}
+}
+
+func MqttErrorMessage(topicFilter string) {
+	if Enabled {
+		mqttErrorMessages.WithLabelValues(topicFilter).Inc()
+	}
+}
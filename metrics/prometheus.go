@@ -15,6 +15,14 @@ func Init(enabled bool) {
 		initSourcesPool()
 		initSourceObjects()
 		initRedis()
+		initLateEvents()
+		initDestinations()
+		initTimestamps()
+		initEventSize()
+		initSampling()
+		initDedup()
+		initEventsCache()
+		initMqtt()
 	} else {
 		logging.Warnf("Metrics isn't enabled")
 	}
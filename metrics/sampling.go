@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var samplingLabels = []string{"token_id", "event_type"}
+
+var (
+	sampledOutEvents *prometheus.CounterVec
+)
+
+func initSampling() {
+	sampledOutEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "events",
+		Name:      "sampled_out",
+	}, samplingLabels)
+}
+
+//SampledOutEvent count events which were dropped by sampling per token and event type
+func SampledOutEvent(tokenId, eventType string) {
+	if Enabled {
+		sampledOutEvents.WithLabelValues("token_"+tokenId, eventType).Inc()
+	}
+}
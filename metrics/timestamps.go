@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var timestampsLabels = []string{"token_id", "reason", "action"}
+
+var (
+	invalidTimestamps *prometheus.CounterVec
+)
+
+func initTimestamps() {
+	invalidTimestamps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "eventnative",
+		Subsystem: "events",
+		Name:      "invalid_timestamps",
+	}, timestampsLabels)
+}
+
+//InvalidTimestamp count events with future or too old timestamps per token, reason (future/past) and action (clamp/flag)
+func InvalidTimestamp(tokenId, reason, action string) {
+	if Enabled {
+		invalidTimestamps.WithLabelValues("token_"+tokenId, reason, action).Inc()
+	}
+}
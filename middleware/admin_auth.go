@@ -2,18 +2,41 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/authorization"
 	"net/http"
 )
 
 const AdminTokenErr = "Admin token does not match"
 
+//AdminToken authorizes admin API requests with the global admin token (all scopes)
+//or with scoped tokens server secrets if ScopeFunc is set
 type AdminToken struct {
-	Token string
+	Token     string
+	ScopeFunc func(serverSecret, scope string) bool
 }
 
+//AdminAuth pass requests with admin token or token with admin scope (query parameter or X-Admin-Token header)
+//All requests including unauthorized ones are written into the audit log
 func (a *AdminToken) AdminAuth(main gin.HandlerFunc, errMsg string) gin.HandlerFunc {
+	return a.ScopeAuth(main, authorization.AdminScope, errMsg)
+}
+
+//ReadAuth pass requests with admin token or token with read (or admin) scope
+func (a *AdminToken) ReadAuth(main gin.HandlerFunc, errMsg string) gin.HandlerFunc {
+	return a.ScopeAuth(main, authorization.ReadScope, errMsg)
+}
+
+//ScopeAuth pass requests with admin token or token with the scope
+//All requests including unauthorized ones are written into the audit log
+func (a *AdminToken) ScopeAuth(main gin.HandlerFunc, scope, errMsg string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if a.Token == "" {
+		withAudit(c, a.scopeAuth(main, scope, errMsg))
+	}
+}
+
+func (a *AdminToken) scopeAuth(main gin.HandlerFunc, scope, errMsg string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.Token == "" && a.ScopeFunc == nil {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "admin_token must be configured"})
 			return
 		}
@@ -23,10 +46,44 @@ func (a *AdminToken) AdminAuth(main gin.HandlerFunc, errMsg string) gin.HandlerF
 			token = c.GetHeader("X-Admin-Token")
 		}
 
-		if token != a.Token {
+		if !a.allowed(token, scope) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: errMsg})
+			return
+		}
+		main(c)
+	}
+}
+
+//allowed return true if token is the admin token or a server secret with the scope
+func (a *AdminToken) allowed(token, scope string) bool {
+	if token == "" {
+		return false
+	}
+	if a.Token != "" && token == a.Token {
+		return true
+	}
+
+	return a.ScopeFunc != nil && a.ScopeFunc(token, scope)
+}
+
+//AdminOrTokenFuncAuth pass requests with admin token or token with admin scope (query parameter or X-Admin-Token header)
+//or with a token which exists in specific (js or api) token config. Only the latter is put into context
+//Only requests with admin token are written into the audit log
+func (a *AdminToken) AdminOrTokenFuncAuth(main gin.HandlerFunc, isAllowedOriginsFunc func(string) ([]string, bool), errMsg string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := extractToken(c.Request)
+		if a.allowed(token, authorization.AdminScope) || a.allowed(c.GetHeader("X-Admin-Token"), authorization.AdminScope) {
+			withAudit(c, main)
+			return
+		}
+
+		if _, allowed := isAllowedOriginsFunc(token); !allowed {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: errMsg})
 			return
 		}
+
+		c.Set(TokenName, token)
+
 		main(c)
 	}
 }
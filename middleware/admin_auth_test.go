@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScopeAuth(t *testing.T) {
+	scopes := map[string][]string{"reader": {authorization.ReadScope}, "operator": {authorization.AdminScope}}
+	adminToken := &AdminToken{Token: "admin", ScopeFunc: func(serverSecret, scope string) bool {
+		token := &authorization.Token{Scopes: scopes[serverSecret]}
+		return token.HasScope(scope)
+	}}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, OkResponse()) }
+	router.GET("/status", adminToken.scopeAuth(ok, authorization.ReadScope, AdminTokenErr))
+	router.POST("/sync", adminToken.scopeAuth(ok, authorization.AdminScope, AdminTokenErr))
+
+	tests := []struct {
+		method   string
+		path     string
+		token    string
+		expected int
+	}{
+		{http.MethodGet, "/status", "admin", http.StatusOK},
+		{http.MethodPost, "/sync", "admin", http.StatusOK},
+		{http.MethodGet, "/status", "reader", http.StatusOK},
+		{http.MethodPost, "/sync", "reader", http.StatusUnauthorized},
+		{http.MethodGet, "/status", "operator", http.StatusOK},
+		{http.MethodPost, "/sync", "operator", http.StatusOK},
+		{http.MethodGet, "/status", "ingest_only", http.StatusUnauthorized},
+		{http.MethodGet, "/status", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Admin-Token", tt.token)
+		router.ServeHTTP(w, req)
+		require.Equal(t, tt.expected, w.Code, "%s %s with token [%s]", tt.method, tt.path, tt.token)
+	}
+}
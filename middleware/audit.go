@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/audit"
+	"github.com/jitsucom/eventnative/timestamp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	//maxAuditBodySize is a max size of JSON request body which is written into audit entry
+	maxAuditBodySize = 64 * 1024
+	//maxAuditResponseSize is a max size of error response which is read for the audit entry error
+	maxAuditResponseSize = 1024
+)
+
+//auditResponseWriter keeps the beginning of error responses
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	errBody bytes.Buffer
+}
+
+func (arw *auditResponseWriter) Write(b []byte) (int, error) {
+	if arw.Status() >= http.StatusBadRequest && arw.errBody.Len() < maxAuditResponseSize {
+		rest := maxAuditResponseSize - arw.errBody.Len()
+		if len(b) < rest {
+			rest = len(b)
+		}
+		arw.errBody.Write(b[:rest])
+	}
+
+	return arw.ResponseWriter.Write(b)
+}
+
+//withAudit run main and write audit entry with the request parameters and the result
+func withAudit(c *gin.Context, main gin.HandlerFunc) {
+	if !audit.Enabled() {
+		main(c)
+		return
+	}
+
+	start := time.Now()
+	entry := &audit.Entry{
+		Timestamp: timestamp.ToISOFormat(start.UTC()),
+		Action:    c.Request.Method + " " + c.FullPath(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		CallerIp:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Params:    auditParams(c),
+		Body:      auditBody(c),
+	}
+
+	writer := &auditResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	main(c)
+	c.Writer = writer.ResponseWriter
+
+	entry.Status = writer.Status()
+	entry.DurationMs = time.Since(start).Milliseconds()
+	switch {
+	case entry.Status == http.StatusUnauthorized:
+		entry.Result = audit.ResultUnauthorized
+	case entry.Status >= http.StatusBadRequest:
+		entry.Result = audit.ResultError
+	default:
+		entry.Result = audit.ResultSuccess
+	}
+	if writer.errBody.Len() > 0 {
+		entry.Error = auditError(writer.errBody.Bytes())
+	}
+
+	audit.Record(entry)
+}
+
+//auditParams return path and query parameters with redacted sensitive values (e.g. admin token)
+func auditParams(c *gin.Context) map[string]interface{} {
+	params := map[string]interface{}{}
+	for _, param := range c.Params {
+		params[param.Key] = param.Value
+	}
+	for key, values := range c.Request.URL.Query() {
+		if len(values) == 1 {
+			params[key] = values[0]
+		} else {
+			params[key] = values
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+
+	for key := range params {
+		if audit.IsSensitive(key) {
+			params[key] = audit.Redacted
+		}
+	}
+
+	return params
+}
+
+//auditBody return JSON request body with redacted sensitive values. The body is restored for the handler.
+//Non JSON (e.g. multipart files) and large bodies aren't written
+func auditBody(c *gin.Context) interface{} {
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") ||
+		c.Request.ContentLength < 0 || c.Request.ContentLength > maxAuditBodySize {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize))
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return nil
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil
+	}
+
+	return audit.Redact(parsed)
+}
+
+//auditError return ErrorResponse message and error or raw response
+func auditError(body []byte) string {
+	response := &ErrorResponse{}
+	if err := json.Unmarshal(body, response); err != nil || response.Message == "" {
+		return string(body)
+	}
+	if response.Error != "" {
+		return response.Message + ": " + response.Error
+	}
+
+	return response.Message
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/audit"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminAuthAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	auditLogger := audit.Init("test", &audit.Config{Path: dir}, nil)
+	defer auditLogger.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	adminToken := &AdminToken{Token: "admin"}
+	router.POST("/sources/:id/sync", adminToken.AdminAuth(func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		if !strings.Contains(string(body), "full") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Body must be read by handler"})
+			return
+		}
+		c.JSON(http.StatusOK, OkResponse())
+	}, AdminTokenErr))
+
+	for _, token := range []string{"admin", "wrong"} {
+		req := httptest.NewRequest(http.MethodPost, "/sources/s1/sync?token="+token, bytes.NewBufferString(`{"mode":"full","password":"pwd"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var entries []map[string]interface{}
+	require.Eventually(t, func() bool {
+		content, _ := ioutil.ReadFile(filepath.Join(dir, "test-audit.log"))
+		entries = nil
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			entry := map[string]interface{}{}
+			if json.Unmarshal([]byte(line), &entry) == nil {
+				entries = append(entries, entry)
+			}
+		}
+		return len(entries) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, "POST /sources/:id/sync", entries[0]["action"])
+	require.Equal(t, map[string]interface{}{"id": "s1", "token": audit.Redacted}, entries[0]["params"])
+	require.Equal(t, map[string]interface{}{"mode": "full", "password": audit.Redacted}, entries[0]["body"])
+	require.Equal(t, float64(http.StatusOK), entries[0]["status"])
+	require.Equal(t, audit.ResultSuccess, entries[0]["result"])
+
+	require.Equal(t, float64(http.StatusUnauthorized), entries[1]["status"])
+	require.Equal(t, audit.ResultUnauthorized, entries[1]["result"])
+	require.Equal(t, AdminTokenErr, entries[1]["error"])
+}
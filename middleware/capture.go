@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/capture"
+)
+
+//CaptureRequests put raw requests of ingest routes (route paths) into token capture sessions (see capture.Start)
+//tokenIdFunc return token id by client_secret/server_secret/token id
+func CaptureRequests(ingestRoutes map[string]bool, tokenIdFunc func(string) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if capture.IsActive() && ingestRoutes[c.FullPath()] {
+			if tokenId := tokenIdFunc(extractToken(c.Request)); tokenId != "" {
+				capture.Capture(tokenId, c.Request)
+			}
+		}
+
+		c.Next()
+	}
+}
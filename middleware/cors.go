@@ -5,7 +5,7 @@ import (
 	"strings"
 )
 
-//Cors handle OPTIONS requests and check if request /event or dynamic event endpoint or static endpoint (/t /s /p)
+//Cors handle OPTIONS requests and check if request /event or dynamic event endpoint, Snowplow endpoints or static endpoint (/t /s /p)
 //check origins - if matched write origin to acao header otherwise don't write it
 func Cors(h http.Handler, isAllowedOriginsFunc func(string) ([]string, bool)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -28,6 +28,12 @@ func Cors(h http.Handler, isAllowedOriginsFunc func(string) ([]string, bool)) ht
 				}
 			}
 
+		} else if r.URL.Path == "/i" || strings.HasPrefix(r.URL.Path, "/com.snowplowanalytics.snowplow/") {
+			//Snowplow trackers send requests with credentials and tokens are in the body: origin is allowed as is and checked by handlers
+			writeDefaultCorsHeaders(w)
+			if reqOrigin := r.Header.Get("Origin"); reqOrigin != "" {
+				w.Header().Add("Access-Control-Allow-Origin", reqOrigin)
+			}
 		} else if strings.Contains(r.URL.Path, "/p/") || strings.Contains(r.URL.Path, "/s/") || strings.Contains(r.URL.Path, "/t/") {
 			writeDefaultCorsHeaders(w)
 			w.Header().Add("Access-Control-Allow-Origin", "*")
@@ -49,6 +55,21 @@ func writeDefaultCorsHeaders(w http.ResponseWriter) {
 	w.Header().Add("Access-Control-Allow-Credentials", "true")
 }
 
+//IsOriginAllowed return true if request origin matches at least one of allowed origins (patterns with '*' are supported)
+//empty request origin isn't allowed
+func IsOriginAllowed(allowedOrigins []string, reqOrigin string) bool {
+	if reqOrigin == "" {
+		return false
+	}
+	for _, allowedOrigin := range allowedOrigins {
+		if checkOrigin(allowedOrigin, reqOrigin) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func checkOrigin(allowedOrigin, reqOrigin string) bool {
 	var prefix, suffix bool
 	//reformat req origin
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appstatus"
+	"net/http"
+	"strconv"
+)
+
+const drainingRetryAfterSec = 30
+
+//Draining reject requests of ingest routes (route paths) with 503 if the server is draining (see drain.Service)
+//Connection is closed so clients are able to reconnect to other instances
+func Draining(ingestRoutes map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !appstatus.Instance.Draining || !ingestRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(drainingRetryAfterSec))
+		c.Header("Connection", "close")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{Message: "Server is draining. Please retry later"})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/appstatus"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDraining(t *testing.T) {
+	defer func() { appstatus.Instance.Draining = false }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Draining(map[string]bool{"/event": true}))
+	router.POST("/event", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/statistics", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	appstatus.Instance.Draining = true
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/statistics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
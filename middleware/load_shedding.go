@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/overload"
+	"net/http"
+	"strconv"
+)
+
+//LoadShedding reject requests with 503 and Retry-After header if the server is overloaded (see overload.Controller)
+//priorities is a map of route path -> overload priority. Not listed routes have overload.Normal priority.
+//Connection is closed after shed responses so clients are able to reconnect to less loaded instances
+func LoadShedding(priorities map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		priority, ok := priorities[c.FullPath()]
+		if !ok {
+			priority = overload.Normal
+		}
+
+		allowed, retryAfter := overload.Allow(priority)
+		if allowed {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.Header("Connection", "close")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{Message: "Server is overloaded. Please retry later"})
+	}
+}
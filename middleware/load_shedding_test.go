@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/overload"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShedding(t *testing.T) {
+	controller, err := overload.Init(&overload.Config{Enabled: true, MaxGoroutines: 1, CheckIntervalMs: 10}, nil)
+	require.NoError(t, err)
+	defer controller.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LoadShedding(map[string]string{"/event": overload.Critical}))
+	router.GET("/event", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/statistics", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	//wait for the first check: a test process always has more than one goroutine
+	require.Eventually(t, func() bool {
+		allowed, _ := overload.Allow(overload.Normal)
+		return !allowed
+	}, time.Second, 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/statistics", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Equal(t, "close", w.Header().Get("Connection"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/event", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
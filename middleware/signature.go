@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/signing"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = signing.Header
+
+	signatureReplayScope = "signature"
+)
+
+//SignatureAuth check X-EN-Signature header of requests with tokens which have signing secret:
+//header format: t=<unix timestamp seconds>,v1=<hex HMAC-SHA256(secret, "<t>.<request body>")>
+//requests with timestamp out of replay window (now +- max age) or already used signatures are rejected.
+//Used signatures are kept in memory and in meta storage (if configured) so requests can't be replayed to other cluster nodes
+//requests with tokens without signing secret are passed as is
+func SignatureAuth(main gin.HandlerFunc, getSigningSecretFunc func(string) (string, time.Duration), metaStorage meta.Storage) gin.HandlerFunc {
+	replays := newReplayGuard(metaStorage)
+	return func(c *gin.Context) {
+		secret, maxAge := getSigningSecretFunc(extractToken(c.Request))
+		if secret == "" {
+			main(c)
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Error reading request body", Error: err.Error()})
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		signature, err := signing.Verify(c.GetHeader(SignatureHeader), secret, body, maxAge, time.Now())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid request signature", Error: err.Error()})
+			return
+		}
+		if !replays.check(signature, maxAge) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid request signature", Error: "signature has already been used"})
+			return
+		}
+
+		main(c)
+	}
+}
+
+//RejectSigned reject requests with tokens which have signing secret. It is used on endpoints where the event
+//isn't in the body (e.g. GET pixel: the event is in the query string) and can't be covered by SignatureAuth
+func RejectSigned(main gin.HandlerFunc, getSigningSecretFunc func(string) (string, time.Duration)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret, _ := getSigningSecretFunc(extractToken(c.Request)); secret != "" {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "The token requires signed requests. The endpoint doesn't support signatures: please use a token without signing_secret"})
+			return
+		}
+
+		main(c)
+	}
+}
+
+//replayGuard keeps used signatures during replay window in memory and in meta storage (shared between cluster nodes)
+type replayGuard struct {
+	sync.Mutex
+	expiration  map[string]time.Time
+	lastCleanUp time.Time
+
+	//nil if signatures are kept only in memory
+	metaStorage meta.Storage
+}
+
+func newReplayGuard(metaStorage meta.Storage) *replayGuard {
+	if metaStorage != nil && metaStorage.Type() == meta.DummyType {
+		metaStorage = nil
+	}
+
+	return &replayGuard{expiration: map[string]time.Time{}, lastCleanUp: time.Now(), metaStorage: metaStorage}
+}
+
+//check return false if signature has already been used on this node or on another one (meta storage) and remember it otherwise
+//if meta storage is unavailable - only this node used signatures are checked
+func (rg *replayGuard) check(signature string, maxAge time.Duration) bool {
+	if !rg.checkLocal(signature, maxAge) {
+		return false
+	}
+
+	if rg.metaStorage == nil {
+		return true
+	}
+
+	firstUse, err := rg.metaStorage.MarkNonceUsed(signatureReplayScope, signature, 2*maxAge)
+	if err != nil {
+		logging.SystemErrorf("Error checking request signature replay: %v", err)
+		return true
+	}
+
+	return firstUse
+}
+
+//checkLocal return false if signature has already been used on this node and remember it otherwise
+//signatures older than twice max age can't be replayed (timestamp check) and are removed
+func (rg *replayGuard) checkLocal(signature string, maxAge time.Duration) bool {
+	rg.Lock()
+	defer rg.Unlock()
+
+	now := time.Now()
+	if now.Sub(rg.lastCleanUp) > time.Minute {
+		for s, expiredAt := range rg.expiration {
+			if now.After(expiredAt) {
+				delete(rg.expiration, s)
+			}
+		}
+		rg.lastCleanUp = now
+	}
+
+	if expiredAt, ok := rg.expiration[signature]; ok && now.Before(expiredAt) {
+		return false
+	}
+
+	rg.expiration[signature] = now.Add(2 * maxAge)
+	return true
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/signing"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type nonceStorage struct {
+	meta.Dummy
+	used map[string]bool
+}
+
+func (ns *nonceStorage) MarkNonceUsed(scope, nonce string, ttl time.Duration) (bool, error) {
+	key := scope + ":" + nonce
+	if ns.used[key] {
+		return false, nil
+	}
+
+	ns.used[key] = true
+	return true, nil
+}
+
+func (ns *nonceStorage) Type() string {
+	return meta.RedisType
+}
+
+func TestSignatureAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"event_type":"purchase"}`)
+	signingSecrets := func(token string) (string, time.Duration) {
+		if token == "signed" {
+			return "secret", time.Minute
+		}
+		return "", 0
+	}
+
+	var handledBody []byte
+	handler := SignatureAuth(func(c *gin.Context) {
+		handledBody, _ = ioutil.ReadAll(c.Request.Body)
+		c.Status(http.StatusOK)
+	}, signingSecrets, &meta.Dummy{})
+
+	serve := func(token, signature string) int {
+		handledBody = nil
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/s2s/event?token="+token, bytes.NewReader(body))
+		if signature != "" {
+			c.Request.Header.Set(SignatureHeader, signature)
+		}
+		handler(c)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("unsigned", ""))
+	require.Equal(t, http.StatusUnauthorized, serve("signed", ""))
+
+	signature := signing.Sign("secret", body, time.Now())
+	require.Equal(t, http.StatusOK, serve("signed", signature))
+	require.Equal(t, body, handledBody, "Body must be available for the handler")
+
+	require.Equal(t, http.StatusUnauthorized, serve("signed", signature), "Replayed request must be rejected")
+	require.Nil(t, handledBody)
+}
+
+func TestRejectSigned(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signingSecrets := func(token string) (string, time.Duration) {
+		if token == "signed" {
+			return "secret", time.Minute
+		}
+		return "", 0
+	}
+	handler := RejectSigned(func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}, signingSecrets)
+
+	serve := func(token string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/event.gif?event_type=open&token="+token, nil)
+		//signature of the empty body mustn't authenticate query payload
+		c.Request.Header.Set(SignatureHeader, signing.Sign("secret", nil, time.Now()))
+		handler(c)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("unsigned"))
+	require.Equal(t, http.StatusUnauthorized, serve("signed"))
+}
+
+func TestSignatureAuthSharedReplayProtection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"event_type":"purchase"}`)
+	signingSecrets := func(token string) (string, time.Duration) {
+		return "secret", time.Minute
+	}
+
+	//two cluster nodes with shared meta storage
+	storage := &nonceStorage{used: map[string]bool{}}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	node1 := SignatureAuth(ok, signingSecrets, storage)
+	node2 := SignatureAuth(ok, signingSecrets, storage)
+
+	serve := func(handler gin.HandlerFunc, signature string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/s2s/event?token=signed", bytes.NewReader(body))
+		c.Request.Header.Set(SignatureHeader, signature)
+		handler(c)
+		return w.Code
+	}
+
+	signature := signing.Sign("secret", body, time.Now())
+	require.Equal(t, http.StatusOK, serve(node1, signature))
+	require.Equal(t, http.StatusUnauthorized, serve(node2, signature), "Request replayed to another node must be rejected")
+
+	anotherSignature := signing.Sign("secret", body, time.Now().Add(-time.Second))
+	require.Equal(t, http.StatusOK, serve(node2, anotherSignature))
+	require.Equal(t, http.StatusUnauthorized, serve(node1, anotherSignature))
+}
@@ -1,8 +1,12 @@
 package middleware
 
+//QuotaExceededCode is an ErrorResponse code of events which are rejected because of token quota exhaustion
+const QuotaExceededCode = "quota_exceeded"
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
 }
 
 type StatusResponse struct {
@@ -0,0 +1,47 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Config is a dto for parsing mqtt configuration section
+type Config struct {
+	Broker   string         `mapstructure:"broker" json:"broker,omitempty"`
+	ClientId string         `mapstructure:"client_id" json:"client_id,omitempty"`
+	Username string         `mapstructure:"username" json:"username,omitempty"`
+	Password string         `mapstructure:"password" json:"password,omitempty"`
+	Qos      byte           `mapstructure:"qos" json:"qos,omitempty"`
+	Topics   []*TopicConfig `mapstructure:"topics" json:"topics,omitempty"`
+}
+
+//TopicConfig is a topic filter (might contain + and # wildcards) with token which will be applied to topic events
+type TopicConfig struct {
+	Topic string `mapstructure:"topic" json:"topic,omitempty"`
+	Token string `mapstructure:"token" json:"token,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("mqtt config is required")
+	}
+	if c.Broker == "" {
+		return errors.New("broker is required parameter")
+	}
+	if c.Qos > 2 {
+		return fmt.Errorf("qos must be 0, 1 or 2. Current value: %d", c.Qos)
+	}
+	if len(c.Topics) == 0 {
+		return errors.New("at least one topic is required")
+	}
+	for _, topic := range c.Topics {
+		if topic.Topic == "" {
+			return errors.New("topic is required parameter in every topics item")
+		}
+		if topic.Token == "" {
+			return fmt.Errorf("token is required parameter for topic [%s]", topic.Topic)
+		}
+	}
+
+	return nil
+}
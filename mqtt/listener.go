@@ -0,0 +1,211 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	connectTimeout = 30 * time.Second
+	topicKey       = "mqtt_topic"
+
+	//maxLoggedPayload is a max size of message payload in error logs
+	maxLoggedPayload = 256
+	//errorLogInterval is a min interval between error logs of one topic filter (other errors are only counted)
+	errorLogInterval = time.Minute
+)
+
+//EventProcessor is a handler of parsed events (e.g. handlers.EventHandler)
+type EventProcessor interface {
+	ProcessEvent(payload events.Event, token string, r *http.Request) error
+}
+
+//Listener subscribes to configured MQTT topics and passes every JSON message
+//as an event (or array of events) to EventProcessor with the topic token
+type Listener struct {
+	client      paho.Client
+	processor   EventProcessor
+	tokenIdFunc func(token string) string
+	config      *Config
+	errorsLog   errorsLog
+}
+
+//errorsLog writes at most one error log per topic filter in errorLogInterval and counts suppressed errors
+type errorsLog struct {
+	sync.Mutex
+	lastLogged map[string]time.Time
+	suppressed map[string]int
+}
+
+func (el *errorsLog) errorf(topicFilter, format string, v ...interface{}) {
+	el.Lock()
+	defer el.Unlock()
+	if el.lastLogged == nil {
+		el.lastLogged = map[string]time.Time{}
+		el.suppressed = map[string]int{}
+	}
+
+	now := time.Now()
+	if last, ok := el.lastLogged[topicFilter]; ok && now.Sub(last) < errorLogInterval {
+		el.suppressed[topicFilter]++
+		return
+	}
+
+	msg := fmt.Sprintf(format, v...)
+	if suppressed := el.suppressed[topicFilter]; suppressed > 0 {
+		msg += fmt.Sprintf(" (%d similar errors of topic [%s] have been suppressed)", suppressed, topicFilter)
+	}
+	logging.Errorf("[mqtt] %s", msg)
+	el.lastLogged[topicFilter] = now
+	el.suppressed[topicFilter] = 0
+}
+
+//NewListener validate configuration, connect to the broker and subscribe to all configured topics
+//subscriptions are restored on every reconnect
+//tokenIdFunc return token id or "" if the topic token isn't authorized (tokens might be reloaded, so it is checked on every message)
+func NewListener(config *Config, defaultClientId string, processor EventProcessor, tokenIdFunc func(token string) string) (*Listener, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	clientId := config.ClientId
+	if clientId == "" {
+		clientId = defaultClientId
+	}
+
+	l := &Listener{processor: processor, tokenIdFunc: tokenIdFunc, config: config}
+
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(clientId).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(connectTimeout).
+		SetOnConnectHandler(l.subscribe).
+		SetConnectionLostHandler(func(client paho.Client, err error) {
+			logging.Errorf("[mqtt] Connection to %s has been lost: %v", config.Broker, err)
+		})
+
+	l.client = paho.NewClient(options)
+	token := l.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		return nil, fmt.Errorf("Error connecting to MQTT broker %s: timeout", config.Broker)
+	}
+	if token.Error() != nil {
+		return nil, fmt.Errorf("Error connecting to MQTT broker %s: %v", config.Broker, token.Error())
+	}
+
+	logging.Infof("[mqtt] Listener has been connected to %s", config.Broker)
+	return l, nil
+}
+
+//subscribe to all configured topics
+func (l *Listener) subscribe(client paho.Client) {
+	for _, topic := range l.config.Topics {
+		token := client.Subscribe(topic.Topic, l.config.Qos, l.messageHandler(topic.Topic, topic.Token))
+		if token.Wait() && token.Error() != nil {
+			logging.SystemErrorf("[mqtt] Error subscribing to topic [%s]: %v", topic.Topic, token.Error())
+			continue
+		}
+		logging.Infof("[mqtt] Subscribed to topic [%s]", topic.Topic)
+	}
+}
+
+//messageHandler return paho.MessageHandler which parses message and passes events to the processor with token
+//metrics are labeled with the configured topic filter (concrete topics of wildcard filters are unbounded)
+func (l *Listener) messageHandler(topicFilter, token string) paho.MessageHandler {
+	return func(client paho.Client, message paho.Message) {
+		if l.tokenIdFunc(token) == "" {
+			l.errorsLog.errorf(topicFilter, "Message from topic [%s] was rejected: topic token [%s] isn't authorized", message.Topic(), maskToken(token))
+			metrics.MqttErrorMessage(topicFilter)
+			return
+		}
+
+		parsed, err := parse(message.Payload())
+		if err != nil {
+			l.errorsLog.errorf(topicFilter, "Error parsing message from topic [%s]: %v. Payload: %s", message.Topic(), err, truncatePayload(message.Payload()))
+			metrics.MqttErrorMessage(topicFilter)
+			return
+		}
+
+		for _, event := range parsed {
+			event[topicKey] = message.Topic()
+			if err := l.processor.ProcessEvent(event, token, nil); err != nil {
+				logging.Debugf("[mqtt] Event from topic [%s] was rejected: %v", message.Topic(), err)
+			}
+		}
+		metrics.MqttMessage(topicFilter, len(parsed))
+	}
+}
+
+//maskToken return the first 3 token symbols and mask the rest
+func maskToken(token string) string {
+	if len(token) <= 3 {
+		return "*****"
+	}
+	return token[:3] + "*****"
+}
+
+//truncatePayload return payload string which is at most maxLoggedPayload bytes
+func truncatePayload(payload []byte) string {
+	if len(payload) <= maxLoggedPayload {
+		return string(payload)
+	}
+	return string(payload[:maxLoggedPayload]) + fmt.Sprintf("...(%d bytes)", len(payload))
+}
+
+func (l *Listener) Close() error {
+	if l.client.IsConnected() {
+		for _, topic := range l.config.Topics {
+			l.client.Unsubscribe(topic.Topic)
+		}
+		l.client.Disconnect(250)
+	}
+
+	return nil
+}
+
+//parse return events from JSON object or JSON array of objects
+func parse(payload []byte) ([]events.Event, error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty payload")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+
+	if trimmed[0] == '[' {
+		var array []events.Event
+		if err := decoder.Decode(&array); err != nil {
+			return nil, err
+		}
+		//null array elements are decoded as nil events
+		for i, event := range array {
+			if event == nil {
+				return nil, fmt.Errorf("array element %d isn't a JSON object", i)
+			}
+		}
+		return array, nil
+	}
+
+	event := events.Event{}
+	if err := decoder.Decode(&event); err != nil {
+		return nil, err
+	}
+	//null payload is decoded as nil event
+	if event == nil {
+		return nil, errors.New("payload isn't a JSON object or array of JSON objects")
+	}
+	return []events.Event{event}, nil
+}
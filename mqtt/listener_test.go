@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []events.Event
+		expectedErr string
+	}{
+		{
+			"Empty payload",
+			"  ",
+			nil,
+			"empty payload",
+		},
+		{
+			"Not a JSON payload",
+			"temperature=21",
+			nil,
+			"invalid character 'e' in literal true (expecting 'r')",
+		},
+		{
+			"JSON object",
+			`{"device":"abc","temperature":21.5}`,
+			[]events.Event{{"device": "abc", "temperature": json.Number("21.5")}},
+			"",
+		},
+		{
+			"null payload",
+			"null",
+			nil,
+			"payload isn't a JSON object or array of JSON objects",
+		},
+		{
+			"JSON array with null element",
+			`[{"device":"abc"},null]`,
+			nil,
+			"array element 1 isn't a JSON object",
+		},
+		{
+			"JSON array",
+			` [{"device":"abc"},{"device":"def","nested":{"a":1}}]`,
+			[]events.Event{{"device": "abc"}, {"device": "def", "nested": map[string]interface{}{"a": json.Number("1")}}},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parse([]byte(tt.input))
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.EqualError(t, (&Config{}).Validate(), "broker is required parameter")
+	require.EqualError(t, (&Config{Broker: "tcp://localhost:1883", Qos: 3}).Validate(), "qos must be 0, 1 or 2. Current value: 3")
+	require.EqualError(t, (&Config{Broker: "tcp://localhost:1883"}).Validate(), "at least one topic is required")
+	require.EqualError(t, (&Config{Broker: "tcp://localhost:1883", Topics: []*TopicConfig{{Topic: "devices/#"}}}).Validate(), "token is required parameter for topic [devices/#]")
+	require.NoError(t, (&Config{Broker: "tcp://localhost:1883", Topics: []*TopicConfig{{Topic: "devices/#", Token: "s2s"}}}).Validate())
+}
+
+type testMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (tm *testMessage) Duplicate() bool   { return false }
+func (tm *testMessage) Qos() byte         { return 0 }
+func (tm *testMessage) Retained() bool    { return false }
+func (tm *testMessage) Topic() string     { return tm.topic }
+func (tm *testMessage) MessageID() uint16 { return 0 }
+func (tm *testMessage) Payload() []byte   { return tm.payload }
+func (tm *testMessage) Ack()              {}
+
+type testProcessor struct {
+	events []events.Event
+}
+
+func (tp *testProcessor) ProcessEvent(payload events.Event, token string, r *http.Request) error {
+	tp.events = append(tp.events, payload)
+	return nil
+}
+
+func TestMessageHandler(t *testing.T) {
+	processor := &testProcessor{}
+	l := &Listener{processor: processor, tokenIdFunc: func(token string) string {
+		if token == "s2s" {
+			return "token1"
+		}
+		return ""
+	}}
+
+	l.messageHandler("devices/+", "s2s")(nil, &testMessage{topic: "devices/1", payload: []byte(`[null]`)})
+	l.messageHandler("devices/+", "s2s")(nil, &testMessage{topic: "devices/1", payload: []byte(`null`)})
+	require.Empty(t, processor.events)
+
+	l.messageHandler("devices/+", "unknown")(nil, &testMessage{topic: "devices/1", payload: []byte(`{"device":"abc"}`)})
+	require.Empty(t, processor.events)
+
+	l.messageHandler("devices/+", "s2s")(nil, &testMessage{topic: "devices/1", payload: []byte(`{"device":"abc"}`)})
+	require.Equal(t, []events.Event{{"device": "abc", topicKey: "devices/1"}}, processor.events)
+}
+
+func TestErrorsLog(t *testing.T) {
+	el := &errorsLog{}
+	el.errorf("devices/+", "error %d", 1)
+	el.errorf("devices/+", "error %d", 2)
+	el.errorf("devices/+", "error %d", 3)
+	el.errorf("sensors/#", "error %d", 1)
+	require.Equal(t, 2, el.suppressed["devices/+"])
+	require.Equal(t, 0, el.suppressed["sensors/#"])
+
+	//the next log is written after the interval
+	el.lastLogged["devices/+"] = time.Now().Add(-errorLogInterval)
+	el.errorf("devices/+", "error %d", 4)
+	require.Equal(t, 0, el.suppressed["devices/+"])
+}
+
+func TestMaskAndTruncate(t *testing.T) {
+	require.Equal(t, "s2s*****", maskToken("s2s_secret"))
+	require.Equal(t, "*****", maskToken("abc"))
+
+	require.Equal(t, "{}", truncatePayload([]byte("{}")))
+	truncated := truncatePayload(bytes.Repeat([]byte("a"), 1000))
+	require.Equal(t, strings.Repeat("a", maxLoggedPayload)+"...(1000 bytes)", truncated)
+}
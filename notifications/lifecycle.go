@@ -0,0 +1,221 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/signing"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/uuid"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DestinationDown = "destination_down"
+	DestinationUp   = "destination_up"
+	SyncFinished    = "sync_finished"
+	ConfigReloaded  = "config_reloaded"
+	ReplayFinished  = "replay_finished"
+	QuotaThreshold  = "quota_threshold"
+
+	LifecycleEventHeader     = "X-EN-Event"
+	LifecycleDeliveryHeader  = "X-EN-Delivery"
+	LifecycleSignatureHeader = signing.Header
+
+	defaultLifecycleRetries      = 3
+	defaultLifecycleRetryDelayMs = 1000
+)
+
+var allLifecycleEvents = []string{DestinationDown, DestinationUp, SyncFinished, ConfigReloaded, ReplayFinished, QuotaThreshold}
+
+//LifecycleWebhookConfig is an outbound webhook on pipeline lifecycle events
+//requests are signed if secret is set: X-EN-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256(secret, "<t>.<body>")>
+//(the same format as signed incoming requests)
+type LifecycleWebhookConfig struct {
+	Url          string            `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Headers      map[string]string `mapstructure:"headers" json:"headers,omitempty" yaml:"headers,omitempty"`
+	Secret       string            `mapstructure:"secret" json:"secret,omitempty" yaml:"secret,omitempty"`
+	Events       []string          `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"`
+	Retries      *int              `mapstructure:"retries" json:"retries,omitempty" yaml:"retries,omitempty"`
+	RetryDelayMs int               `mapstructure:"retry_delay_ms" json:"retry_delay_ms,omitempty" yaml:"retry_delay_ms,omitempty"`
+}
+
+//LifecycleEvent is a webhook payload
+type LifecycleEvent struct {
+	Id        string                 `json:"id"`
+	Event     string                 `json:"event"`
+	Service   string                 `json:"service"`
+	Server    string                 `json:"server"`
+	Timestamp string                 `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+//lifecycleWebhook delivers lifecycle events asynchronously with retries (exponential backoff)
+type lifecycleWebhook struct {
+	client       *http.Client
+	url          string
+	headers      map[string]string
+	secret       string
+	events       map[string]bool
+	retries      int
+	retryDelay   time.Duration
+	eventsCh     chan *LifecycleEvent
+	errorLogFunc func(format string, v ...interface{})
+	closed       bool
+}
+
+func newLifecycleWebhook(client *http.Client, config *LifecycleWebhookConfig, errorLogFunc func(format string, v ...interface{})) (*lifecycleWebhook, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("lifecycle webhook url is required parameter")
+	}
+	events, err := parseLifecycleEvents(config.Events)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing lifecycle webhook [%s] events: %v", config.Url, err)
+	}
+
+	retries := defaultLifecycleRetries
+	if config.Retries != nil {
+		retries = *config.Retries
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("lifecycle webhook [%s] retries can't be negative", config.Url)
+	}
+	retryDelayMs := config.RetryDelayMs
+	if retryDelayMs <= 0 {
+		retryDelayMs = defaultLifecycleRetryDelayMs
+	}
+
+	return &lifecycleWebhook{
+		client:       client,
+		url:          config.Url,
+		headers:      config.Headers,
+		secret:       config.Secret,
+		events:       events,
+		retries:      retries,
+		retryDelay:   time.Duration(retryDelayMs) * time.Millisecond,
+		eventsCh:     make(chan *LifecycleEvent, messagesBufferSize),
+		errorLogFunc: errorLogFunc,
+	}, nil
+}
+
+//parseLifecycleEvents return set of lifecycle events or all events if values are empty
+func parseLifecycleEvents(values []string) (map[string]bool, error) {
+	if len(values) == 0 {
+		values = allLifecycleEvents
+	}
+
+	events := map[string]bool{}
+	for _, value := range values {
+		event := strings.ToLower(strings.TrimSpace(value))
+		switch event {
+		case DestinationDown, DestinationUp, SyncFinished, ConfigReloaded, ReplayFinished, QuotaThreshold:
+			events[event] = true
+		default:
+			return nil, fmt.Errorf("unknown lifecycle event [%s]. Available: %s", value, strings.Join(allLifecycleEvents, ", "))
+		}
+	}
+
+	return events, nil
+}
+
+func (lw *lifecycleWebhook) start() {
+	safego.RunWithRestart(func() {
+		for {
+			if lw.closed {
+				break
+			}
+
+			event := <-lw.eventsCh
+			if err := lw.deliver(event); err != nil {
+				lw.errorLogFunc("Error delivering lifecycle event [%s] %s to webhook [%s]: %v", event.Id, event.Event, lw.url, err)
+			}
+		}
+	})
+}
+
+//deliver send event and retry on network errors, 408, 429 and 5xx responses
+func (lw *lifecycleWebhook) deliver(event *LifecycleEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("Error marshalling payload: %v", err)
+	}
+
+	delay := lw.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := lw.send(event, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= lw.retries || lw.closed {
+			return fmt.Errorf("%v (attempts: %d)", err, attempt+1)
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+//send return true if request might be retried
+func (lw *lifecycleWebhook) send(event *LifecycleEvent, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, lw.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("Error creating http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range lw.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set(LifecycleEventHeader, event.Event)
+	req.Header.Set(LifecycleDeliveryHeader, event.Id)
+	if lw.secret != "" {
+		//every attempt is signed with the current time because receivers check the signature age
+		req.Header.Set(LifecycleSignatureHeader, signing.Sign(lw.secret, body, time.Now()))
+	}
+
+	resp, err := lw.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("Error sending http request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBytes, _ := ioutil.ReadAll(resp.Body)
+		retryable := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retryable, fmt.Errorf("Error http response code: %d body: %s", resp.StatusCode, string(respBytes))
+	}
+
+	return false, nil
+}
+
+//Lifecycle put pipeline lifecycle event into queues of all webhooks which are subscribed to the event
+//event is skipped if webhook queue is full
+func Lifecycle(event string, data map[string]interface{}) {
+	if instance == nil || len(instance.lifecycleWebhooks) == 0 {
+		return
+	}
+
+	lifecycleEvent := &LifecycleEvent{
+		Id:        uuid.New(),
+		Event:     event,
+		Service:   instance.serviceName,
+		Server:    instance.serverName,
+		Timestamp: time.Now().UTC().Format(timestamp.Layout),
+		Data:      data,
+	}
+
+	for _, webhook := range instance.lifecycleWebhooks {
+		if !webhook.events[event] {
+			continue
+		}
+
+		select {
+		case webhook.eventsCh <- lifecycleEvent:
+		default:
+			instance.errorLoggingFunc("Error delivering lifecycle event %s to webhook [%s]: events queue is full", event, webhook.url)
+		}
+	}
+}
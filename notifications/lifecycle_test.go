@@ -0,0 +1,122 @@
+package notifications
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/signing"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLifecycleWebhooks(t *testing.T) {
+	type delivery struct {
+		event     *LifecycleEvent
+		signature string
+		body      []byte
+	}
+	signedCh := make(chan *delivery, 10)
+	syncCh := make(chan *delivery, 10)
+	var signedAttempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		event := &LifecycleEvent{}
+		require.NoError(t, json.Unmarshal(body, event))
+		require.Equal(t, event.Event, r.Header.Get(LifecycleEventHeader))
+		require.Equal(t, event.Id, r.Header.Get(LifecycleDeliveryHeader))
+
+		if r.URL.Path == "/signed" {
+			//the first attempt fails and is retried
+			if atomic.AddInt32(&signedAttempts, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			signedCh <- &delivery{event: event, signature: r.Header.Get(LifecycleSignatureHeader), body: body}
+		} else {
+			syncCh <- &delivery{event: event, signature: r.Header.Get(LifecycleSignatureHeader)}
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{Lifecycle: []*LifecycleWebhookConfig{
+		{Url: server.URL + "/signed", Secret: "secret", Events: []string{DestinationDown, DestinationUp}, RetryDelayMs: 10},
+		{Url: server.URL + "/sync", Events: []string{SyncFinished}},
+	}}
+	require.NoError(t, Init(ServiceName, "test", config, t.Logf))
+	defer func() {
+		Close()
+		instance = nil
+	}()
+
+	Lifecycle(DestinationDown, map[string]interface{}{"destination_id": "pg"})
+	Lifecycle(SyncFinished, map[string]interface{}{"source_id": "source"})
+	Lifecycle(ConfigReloaded, map[string]interface{}{"component": "destinations"})
+
+	select {
+	case d := <-signedCh:
+		require.Equal(t, DestinationDown, d.event.Event)
+		require.Equal(t, "test", d.event.Server)
+		require.Equal(t, map[string]interface{}{"destination_id": "pg"}, d.event.Data)
+		require.Equal(t, int32(2), atomic.LoadInt32(&signedAttempts))
+
+		require.True(t, strings.HasPrefix(d.signature, "t="), d.signature)
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(d.signature, ",")[0], "t="), 10, 64)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), time.Unix(seconds, 0), time.Minute)
+		require.Equal(t, signing.Sign("secret", d.body, time.Unix(seconds, 0)), d.signature)
+	case <-time.After(5 * time.Second):
+		t.Fatal("destination_down event wasn't delivered")
+	}
+
+	select {
+	case d := <-syncCh:
+		require.Equal(t, SyncFinished, d.event.Event)
+		require.Equal(t, "", d.signature)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync_finished event wasn't delivered")
+	}
+
+	select {
+	case d := <-signedCh:
+		t.Fatalf("unexpected event: %v", d.event)
+	case d := <-syncCh:
+		t.Fatalf("unexpected event: %v", d.event)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestLifecycleWebhookRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if r.URL.Path == "/bad_request" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retries := 2
+	webhook, err := newLifecycleWebhook(http.DefaultClient, &LifecycleWebhookConfig{Url: server.URL, Retries: &retries, RetryDelayMs: 1}, t.Logf)
+	require.NoError(t, err)
+	require.Error(t, webhook.deliver(&LifecycleEvent{Id: "1", Event: SyncFinished}))
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	//client errors aren't retried
+	atomic.StoreInt32(&attempts, 0)
+	webhook.url = server.URL + "/bad_request"
+	require.Error(t, webhook.deliver(&LifecycleEvent{Id: "2", Event: SyncFinished}))
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestLifecycleInitErrors(t *testing.T) {
+	require.Error(t, Init(ServiceName, "test", &Config{Lifecycle: []*LifecycleWebhookConfig{{}}}, t.Logf))
+	require.Error(t, Init(ServiceName, "test", &Config{Lifecycle: []*LifecycleWebhookConfig{{Url: "url", Events: []string{"destination_removed"}}}}, t.Logf))
+	require.Nil(t, instance)
+}
@@ -0,0 +1,316 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/safego"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ServiceName = "EventNative"
+
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+
+	messagesBufferSize = 1000
+)
+
+var (
+	instance *Notifier
+
+	allSeverities = []string{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical}
+)
+
+//Config is a notification channels configuration
+//every channel receives only messages with configured severities
+//lifecycle webhooks receive pipeline lifecycle events (not messages) and aren't filtered by severities
+type Config struct {
+	Slack     *SlackConfig              `mapstructure:"slack" json:"slack,omitempty" yaml:"slack,omitempty"`
+	PagerDuty *PagerDutyConfig          `mapstructure:"pagerduty" json:"pagerduty,omitempty" yaml:"pagerduty,omitempty"`
+	Webhook   *WebhookConfig            `mapstructure:"webhook" json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Lifecycle []*LifecycleWebhookConfig `mapstructure:"lifecycle" json:"lifecycle,omitempty" yaml:"lifecycle,omitempty"`
+}
+
+//Message is a notification which is routed to channels by severity
+type Message struct {
+	Severity    string
+	Title       string
+	Text        string
+	ServiceName string
+	ServerName  string
+	Timestamp   time.Time
+}
+
+//Channel sends notification messages to an external system
+type Channel interface {
+	Name() string
+	Send(message *Message) error
+}
+
+//channelWorker sends messages to the channel asynchronously
+type channelWorker struct {
+	channel    Channel
+	severities map[string]bool
+	messagesCh chan *Message
+	closed     bool
+}
+
+type Notifier struct {
+	errorLoggingFunc func(format string, v ...interface{})
+	serviceName      string
+	serverName       string
+
+	workers           []*channelWorker
+	lifecycleWebhooks []*lifecycleWebhook
+}
+
+//Init create configured notification channels and start sending goroutines
+//does nothing if there are no configured channels
+func Init(serviceName, serverName string, config *Config, errorLoggingFunc func(format string, v ...interface{})) error {
+	if config == nil {
+		return nil
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        1000,
+			MaxIdleConnsPerHost: 1000,
+		},
+	}
+
+	notifier := &Notifier{errorLoggingFunc: errorLoggingFunc, serviceName: serviceName, serverName: serverName}
+	if config.Slack != nil && config.Slack.Url != "" {
+		severities, err := parseSeverities(config.Slack.Severities, allSeverities)
+		if err != nil {
+			return fmt.Errorf("Error parsing slack notifications severities: %v", err)
+		}
+		notifier.addChannel(NewSlackChannel(client, config.Slack), severities)
+	}
+	if config.PagerDuty != nil {
+		if config.PagerDuty.RoutingKey == "" {
+			return fmt.Errorf("pagerduty notifications routing_key is required parameter")
+		}
+		severities, err := parseSeverities(config.PagerDuty.Severities, []string{SeverityError, SeverityCritical})
+		if err != nil {
+			return fmt.Errorf("Error parsing pagerduty notifications severities: %v", err)
+		}
+		notifier.addChannel(NewPagerDutyChannel(client, config.PagerDuty), severities)
+	}
+	if config.Webhook != nil {
+		if config.Webhook.Url == "" {
+			return fmt.Errorf("webhook notifications url is required parameter")
+		}
+		severities, err := parseSeverities(config.Webhook.Severities, allSeverities)
+		if err != nil {
+			return fmt.Errorf("Error parsing webhook notifications severities: %v", err)
+		}
+		notifier.addChannel(NewWebhookChannel(client, config.Webhook), severities)
+	}
+	for _, lifecycleConfig := range config.Lifecycle {
+		webhook, err := newLifecycleWebhook(client, lifecycleConfig, errorLoggingFunc)
+		if err != nil {
+			return err
+		}
+		notifier.lifecycleWebhooks = append(notifier.lifecycleWebhooks, webhook)
+	}
+	for _, webhook := range notifier.lifecycleWebhooks {
+		webhook.start()
+	}
+
+	if len(notifier.workers) > 0 || len(notifier.lifecycleWebhooks) > 0 {
+		instance = notifier
+	}
+
+	return nil
+}
+
+//parseSeverities return set of severities or defaults if values are empty
+func parseSeverities(values []string, defaults []string) (map[string]bool, error) {
+	if len(values) == 0 {
+		values = defaults
+	}
+
+	severities := map[string]bool{}
+	for _, value := range values {
+		severity := strings.ToLower(strings.TrimSpace(value))
+		switch severity {
+		case SeverityInfo, SeverityWarning, SeverityError, SeverityCritical:
+			severities[severity] = true
+		default:
+			return nil, fmt.Errorf("unknown severity [%s]. Available: %s", value, strings.Join(allSeverities, ", "))
+		}
+	}
+
+	return severities, nil
+}
+
+func (n *Notifier) addChannel(channel Channel, severities map[string]bool) {
+	worker := &channelWorker{channel: channel, severities: severities, messagesCh: make(chan *Message, messagesBufferSize)}
+	n.workers = append(n.workers, worker)
+
+	safego.RunWithRestart(func() {
+		for {
+			if worker.closed {
+				break
+			}
+
+			message := <-worker.messagesCh
+			if err := worker.channel.Send(message); err != nil {
+				n.errorLoggingFunc("Error notify to %s: %v", worker.channel.Name(), err)
+			}
+		}
+	})
+}
+
+//notify put message into queues of all channels which accept message severity
+//message is skipped if channel queue is full
+func (n *Notifier) notify(severity, title, text string) {
+	n.notifyChannels(nil, severity, title, text)
+}
+
+//notifyChannels put message into queues of channels (all channels if names are empty) which accept message severity
+func (n *Notifier) notifyChannels(channels []string, severity, title, text string) {
+	message := &Message{
+		Severity:    severity,
+		Title:       title,
+		Text:        text,
+		ServiceName: n.serviceName,
+		ServerName:  n.serverName,
+		Timestamp:   time.Now().UTC(),
+	}
+
+	for _, worker := range n.workers {
+		if !worker.severities[severity] || !containsChannel(channels, worker.channel.Name()) {
+			continue
+		}
+
+		select {
+		case worker.messagesCh <- message:
+		default:
+			n.errorLoggingFunc("Error notify to %s: messages queue is full", worker.channel.Name())
+		}
+	}
+}
+
+func ServerStart() {
+	if instance != nil {
+		instance.notify(SeverityInfo, "Start", "Service has been started!")
+	}
+}
+
+//NewVersion notify that new EventNative version has been released
+func NewVersion(version string) {
+	if instance != nil {
+		instance.notify(SeverityWarning, "New version", fmt.Sprintf("New version is out: %s", version))
+	}
+}
+
+//SchemaDrift notify about detected event schema drift (e.g. broken instrumentation)
+func SchemaDrift(text string) {
+	if instance != nil {
+		instance.notify(SeverityWarning, "Schema drift", text)
+	}
+}
+
+//Quota notify that token events quota warning threshold has been crossed or quota has been exhausted
+func Quota(exhausted bool, text string) {
+	if instance != nil {
+		severity := SeverityWarning
+		if exhausted {
+			severity = SeverityError
+		}
+		instance.notify(severity, "Quota", text)
+	}
+}
+
+func SystemErrorf(format string, v ...interface{}) {
+	SystemError(fmt.Sprintf(format, v...))
+}
+
+func SystemError(msg ...interface{}) {
+	if instance != nil {
+		instance.notify(SeverityError, "System error", join(msg))
+	}
+}
+
+func Panicf(format string, v ...interface{}) {
+	Panic(fmt.Sprintf(format, v...))
+}
+
+func Panic(msg ...interface{}) {
+	if instance != nil {
+		instance.notify(SeverityCritical, "Panic", join(msg))
+	}
+}
+
+func Close() {
+	if instance != nil {
+		for _, worker := range instance.workers {
+			worker.closed = true
+		}
+		for _, webhook := range instance.lifecycleWebhooks {
+			webhook.closed = true
+		}
+	}
+}
+
+func containsChannel(channels []string, name string) bool {
+	if len(channels) == 0 {
+		return true
+	}
+
+	for _, channel := range channels {
+		if strings.EqualFold(strings.TrimSpace(channel), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func join(msg []interface{}) string {
+	var valuesStr []string
+	for _, v := range msg {
+		valuesStr = append(valuesStr, fmt.Sprint(v))
+	}
+	return strings.Join(valuesStr, " ")
+}
+
+//postJson send payload as JSON and return err if response code isn't 2xx
+func postJson(client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("Error marshalling payload: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("Error creating http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending http request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error http response code: %d body: %s reading error: %v", resp.StatusCode, string(respBytes), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package notifications
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeverityRouting(t *testing.T) {
+	pagerDutyCh := make(chan map[string]interface{}, 10)
+	webhookCh := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := map[string]interface{}{}
+		json.Unmarshal(body, &payload)
+		if r.URL.Path == "/pagerduty" {
+			pagerDutyCh <- payload
+		} else {
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			webhookCh <- payload
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		PagerDuty: &PagerDutyConfig{RoutingKey: "key", Url: server.URL + "/pagerduty", Severities: []string{"critical"}},
+		Webhook:   &WebhookConfig{Url: server.URL + "/webhook", Headers: map[string]string{"Authorization": "Bearer token"}},
+	}
+	require.NoError(t, Init(ServiceName, "test", config, t.Logf))
+	defer func() {
+		Close()
+		instance = nil
+	}()
+
+	SystemError("system", "error")
+	Panicf("panic: %s", "value")
+
+	received := map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case payload := <-webhookCh:
+			received[payload["severity"].(string)] = payload["text"].(string)
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook messages weren't received")
+		}
+	}
+	require.Equal(t, map[string]string{SeverityError: "system error", SeverityCritical: "panic: value"}, received)
+
+	select {
+	case payload := <-pagerDutyCh:
+		require.Equal(t, "key", payload["routing_key"])
+		require.Equal(t, "trigger", payload["event_action"])
+		details := payload["payload"].(map[string]interface{})
+		require.Equal(t, SeverityCritical, details["severity"])
+		require.Equal(t, "EventNative [test]: Panic: panic: value", details["summary"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("pagerduty message wasn't received")
+	}
+	require.Len(t, pagerDutyCh, 0)
+}
+
+func TestInitErrors(t *testing.T) {
+	require.Error(t, Init(ServiceName, "test", &Config{PagerDuty: &PagerDutyConfig{}}, t.Logf))
+	require.Error(t, Init(ServiceName, "test", &Config{Slack: &SlackConfig{Url: "url", Severities: []string{"fatal"}}}, t.Logf))
+	require.Nil(t, instance)
+}
+
+func TestSourceSyncRouting(t *testing.T) {
+	slackCh := make(chan map[string]interface{}, 10)
+	webhookCh := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := map[string]interface{}{}
+		json.Unmarshal(body, &payload)
+		if r.URL.Path == "/slack" {
+			slackCh <- payload
+		} else {
+			webhookCh <- payload
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Slack:   &SlackConfig{Url: server.URL + "/slack"},
+		Webhook: &WebhookConfig{Url: server.URL + "/webhook"},
+	}
+	require.NoError(t, Init(ServiceName, "test", config, t.Logf))
+	defer func() {
+		Close()
+		instance = nil
+	}()
+
+	//summaries are disabled by default
+	SourceSync(&SourceSyncConfig{Channels: []string{"webhook"}}, false, "sync", "skipped summary")
+	SourceSync(&SourceSyncConfig{Channels: []string{"webhook"}}, true, "sync", "failure")
+	SourceSync(&SourceSyncConfig{Channels: []string{"webhook"}, Summary: true}, false, "sync", "summary")
+	SourceSync(nil, true, "sync", "not configured")
+
+	received := map[string]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case payload := <-webhookCh:
+			received[payload["severity"].(string)] = payload["text"].(string)
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook messages weren't received")
+		}
+	}
+	require.Equal(t, map[string]string{SeverityError: "failure", SeverityInfo: "summary"}, received)
+
+	select {
+	case payload := <-webhookCh:
+		t.Fatalf("unexpected webhook message: %v", payload)
+	case payload := <-slackCh:
+		t.Fatalf("unexpected slack message: %v", payload)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
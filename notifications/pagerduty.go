@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/http"
+)
+
+const (
+	pagerDutyEventsUrl = "https://events.pagerduty.com/v2/enqueue"
+	//PagerDuty Events API v2 summary max length
+	pagerDutyMaxSummaryLength = 1024
+)
+
+type PagerDutyConfig struct {
+	RoutingKey string   `mapstructure:"routing_key" json:"routing_key,omitempty" yaml:"routing_key,omitempty"`
+	Url        string   `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Severities []string `mapstructure:"severities" json:"severities,omitempty" yaml:"severities,omitempty"`
+}
+
+//PagerDutyChannel triggers PagerDuty incidents via Events API v2
+type PagerDutyChannel struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+func NewPagerDutyChannel(client *http.Client, config *PagerDutyConfig) *PagerDutyChannel {
+	url := config.Url
+	if url == "" {
+		url = pagerDutyEventsUrl
+	}
+	return &PagerDutyChannel{client: client, url: url, routingKey: config.RoutingKey}
+}
+
+func (pc *PagerDutyChannel) Name() string {
+	return "pagerduty"
+}
+
+func (pc *PagerDutyChannel) Send(message *Message) error {
+	summary := fmt.Sprintf("%s [%s]: %s", message.ServiceName, message.ServerName, message.Title)
+	if message.Text != "" {
+		summary += ": " + message.Text
+	}
+	if len(summary) > pagerDutyMaxSummaryLength {
+		summary = summary[:pagerDutyMaxSummaryLength]
+	}
+
+	payload := map[string]interface{}{
+		"routing_key":  pc.routingKey,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         message.ServerName,
+			"component":      message.ServiceName,
+			"severity":       message.Severity,
+			"timestamp":      message.Timestamp.Format(timestamp.Layout),
+			"custom_details": map[string]interface{}{"text": message.Text},
+		},
+	}
+
+	if err := postJson(pc.client, pc.url, nil, payload); err != nil {
+		return fmt.Errorf("pagerduty: %v", err)
+	}
+
+	return nil
+}
@@ -1,144 +1,56 @@
 package notifications
 
 import (
-	"bytes"
 	"fmt"
-	"github.com/jitsucom/eventnative/safego"
-	"io/ioutil"
 	"net/http"
-	"strings"
-	"time"
 )
 
-const (
-	ServiceName         = "EventNative"
-	serverStartTemplate = `{
-    "text": "*%s* [%s]: Start",
-	"attachments": [
-		{
-			"color": "#5cb85c",
-			"blocks": [
-				{
-					"type": "divider"
-				},
-				{
-					"type": "section",
-					"text": {
-						"type": "mrkdwn",
-						"text": "%s"
-					}
-				}
-			]
-		}
-	]
-}`
-	systemErrorTemplate = `{
-    "text": "*%s* [%s]: System error",
-	"attachments": [
-		{
-			"color": "#d9534f",
-			"blocks": [
-				{
-					"type": "divider"
-				},
-				{
-					"type": "section",
-					"text": {
-						"type": "mrkdwn",
-						"text": "%s"
-					}
-				}
-			]
-		}
-	]
-}`
-)
-
-var instance *SlackNotifier
-
-type SlackNotifier struct {
-	client           *http.Client
-	errorLoggingFunc func(format string, v ...interface{})
-	serviceName      string
-	webHookUrl       string
-	serverName       string
-
-	messagesCh chan string
-	closed     bool
+var slackColors = map[string]string{
+	SeverityInfo:     "#5cb85c",
+	SeverityWarning:  "#f0ad4e",
+	SeverityError:    "#d9534f",
+	SeverityCritical: "#d9534f",
 }
 
-func (sn *SlackNotifier) Send(payload string) error {
-	resp, err := sn.client.Post(sn.webHookUrl, "application/json", bytes.NewBufferString(payload))
-	if err != nil {
-		return fmt.Errorf("Error sending slack http request: %v", err)
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		respBytes, err := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("Error slack http response code: %d body: %s reading error: %v", resp.StatusCode, string(respBytes), err)
-	}
+type SlackConfig struct {
+	Url        string   `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Severities []string `mapstructure:"severities" json:"severities,omitempty" yaml:"severities,omitempty"`
+}
 
-	return nil
+//SlackChannel sends messages to Slack incoming webhook
+type SlackChannel struct {
+	client     *http.Client
+	webHookUrl string
 }
 
-func (sn *SlackNotifier) start() {
-	safego.RunWithRestart(func() {
-		for {
-			if sn.closed {
-				break
-			}
+func NewSlackChannel(client *http.Client, config *SlackConfig) *SlackChannel {
+	return &SlackChannel{client: client, webHookUrl: config.Url}
+}
 
-			message := <-sn.messagesCh
-			err := sn.Send(message)
-			if err != nil {
-				sn.errorLoggingFunc("Error notify: %v", err)
-			}
-		}
-	})
+func (sc *SlackChannel) Name() string {
+	return "slack"
 }
 
-func Init(serviceName, url, serverName string, errorLoggingFunc func(format string, v ...interface{})) {
-	instance = &SlackNotifier{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        1000,
-				MaxIdleConnsPerHost: 1000,
+func (sc *SlackChannel) Send(message *Message) error {
+	payload := map[string]interface{}{
+		"text": fmt.Sprintf("*%s* [%s]: %s", message.ServiceName, message.ServerName, message.Title),
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"color": slackColors[message.Severity],
+				"blocks": []interface{}{
+					map[string]interface{}{"type": "divider"},
+					map[string]interface{}{
+						"type": "section",
+						"text": map[string]interface{}{"type": "mrkdwn", "text": message.Text},
+					},
+				},
 			},
 		},
-		errorLoggingFunc: errorLoggingFunc,
-		serviceName:      serviceName,
-		webHookUrl:       url,
-		serverName:       serverName,
-		messagesCh:       make(chan string, 1000),
 	}
-	instance.start()
-}
 
-func ServerStart() {
-	if instance != nil {
-		instance.messagesCh <- fmt.Sprintf(serverStartTemplate, instance.serviceName, instance.serverName, "Service has been started!")
+	if err := postJson(sc.client, sc.webHookUrl, nil, payload); err != nil {
+		return fmt.Errorf("slack: %v", err)
 	}
-}
-
-func SystemErrorf(format string, v ...interface{}) {
-	SystemError(fmt.Sprintf(format, v...))
-}
 
-func SystemError(msg ...interface{}) {
-	if instance != nil {
-		var valuesStr []string
-		for _, v := range msg {
-			valuesStr = append(valuesStr, fmt.Sprint(v))
-		}
-		instance.messagesCh <- fmt.Sprintf(systemErrorTemplate, instance.serviceName, instance.serverName, strings.Join(valuesStr, " "))
-	}
-}
-
-func Close() {
-	if instance != nil {
-		instance.closed = true
-	}
+	return nil
 }
@@ -0,0 +1,31 @@
+package notifications
+
+//SourceSyncConfig is a per source configuration of sync runs notifications
+type SourceSyncConfig struct {
+	//Channels are notification channels names (slack, pagerduty, webhook). Default: all configured channels
+	Channels []string `mapstructure:"channels" json:"channels,omitempty" yaml:"channels,omitempty"`
+	//Summary enables successful sync runs summaries (info severity)
+	Summary bool `mapstructure:"summary" json:"summary,omitempty" yaml:"summary,omitempty"`
+	//DisableFailures disables failed sync runs alerts (error severity)
+	DisableFailures bool `mapstructure:"disable_failures" json:"disable_failures,omitempty" yaml:"disable_failures,omitempty"`
+}
+
+//SourceSync notify about source collection sync run via configured channels
+//successful runs summaries have info severity, failures have error severity. Channels severities filters are applied as well
+func SourceSync(config *SourceSyncConfig, failed bool, title, text string) {
+	if instance == nil || config == nil {
+		return
+	}
+
+	severity := SeverityInfo
+	if failed {
+		if config.DisableFailures {
+			return
+		}
+		severity = SeverityError
+	} else if !config.Summary {
+		return
+	}
+
+	instance.notifyChannels(config.Channels, severity, title, text)
+}
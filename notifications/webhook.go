@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/timestamp"
+	"net/http"
+)
+
+type WebhookConfig struct {
+	Url        string            `mapstructure:"url" json:"url,omitempty" yaml:"url,omitempty"`
+	Headers    map[string]string `mapstructure:"headers" json:"headers,omitempty" yaml:"headers,omitempty"`
+	Severities []string          `mapstructure:"severities" json:"severities,omitempty" yaml:"severities,omitempty"`
+}
+
+//WebhookChannel sends messages as JSON to any HTTP endpoint
+type WebhookChannel struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func NewWebhookChannel(client *http.Client, config *WebhookConfig) *WebhookChannel {
+	return &WebhookChannel{client: client, url: config.Url, headers: config.Headers}
+}
+
+func (wc *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+func (wc *WebhookChannel) Send(message *Message) error {
+	payload := map[string]interface{}{
+		"service":   message.ServiceName,
+		"server":    message.ServerName,
+		"severity":  message.Severity,
+		"title":     message.Title,
+		"text":      message.Text,
+		"timestamp": message.Timestamp.Format(timestamp.Layout),
+	}
+
+	if err := postJson(wc.client, wc.url, wc.headers, payload); err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package overload
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/scheduler"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	//Critical requests (events intake, health probes) are never shed
+	Critical = "critical"
+	//Normal requests are shed when any limit is reached
+	Normal = "normal"
+	//Bulk requests (replays, syncs, imports) are shed first: when bulk_ratio of any limit is reached
+	Bulk = "bulk"
+
+	defaultCheckIntervalMs = 1000
+	defaultBulkRatio       = 0.8
+	defaultRetryAfterSec   = 30
+)
+
+var instance *Controller
+
+//Config is a server.overload configuration. Zero limits aren't checked
+type Config struct {
+	Enabled         bool    `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	MaxQueueSize    int     `mapstructure:"max_queue_size" json:"max_queue_size,omitempty" yaml:"max_queue_size,omitempty"`
+	MaxGCPauseMs    int     `mapstructure:"max_gc_pause_ms" json:"max_gc_pause_ms,omitempty" yaml:"max_gc_pause_ms,omitempty"`
+	MaxGoroutines   int     `mapstructure:"max_goroutines" json:"max_goroutines,omitempty" yaml:"max_goroutines,omitempty"`
+	BulkRatio       float64 `mapstructure:"bulk_ratio" json:"bulk_ratio,omitempty" yaml:"bulk_ratio,omitempty"`
+	RetryAfterSec   int     `mapstructure:"retry_after_sec" json:"retry_after_sec,omitempty" yaml:"retry_after_sec,omitempty"`
+	CheckIntervalMs int     `mapstructure:"check_interval_ms" json:"check_interval_ms,omitempty" yaml:"check_interval_ms,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.MaxQueueSize < 0 || c.MaxGCPauseMs < 0 || c.MaxGoroutines < 0 {
+		return errors.New("overload limits can't be negative")
+	}
+	if c.MaxQueueSize == 0 && c.MaxGCPauseMs == 0 && c.MaxGoroutines == 0 {
+		return errors.New("at least one of max_queue_size, max_gc_pause_ms, max_goroutines is required")
+	}
+	if c.BulkRatio < 0 || c.BulkRatio > 1 {
+		return errors.New("bulk_ratio must be in (0, 1]")
+	}
+
+	return nil
+}
+
+//Sample is a snapshot of the server load indicators
+type Sample struct {
+	QueueSize  int
+	GCPause    time.Duration
+	Goroutines int
+}
+
+//Controller samples load indicators periodically and decides which requests priorities are shed
+type Controller struct {
+	sync.RWMutex
+
+	config     *Config
+	queueSize  func() int
+	retryAfter time.Duration
+
+	lastNumGC int64
+	pressure  float64
+
+	job *scheduler.Job
+}
+
+//Init create global Controller if enabled. queueSize return the total size of stream destinations queues
+func Init(config *Config, queueSize func() int) (*Controller, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.BulkRatio == 0 {
+		config.BulkRatio = defaultBulkRatio
+	}
+	if config.RetryAfterSec <= 0 {
+		config.RetryAfterSec = defaultRetryAfterSec
+	}
+	if config.CheckIntervalMs <= 0 {
+		config.CheckIntervalMs = defaultCheckIntervalMs
+	}
+
+	c := &Controller{config: config, queueSize: queueSize, retryAfter: time.Duration(config.RetryAfterSec) * time.Second}
+	c.job = scheduler.Add("overload_check", scheduler.Every(time.Duration(config.CheckIntervalMs)*time.Millisecond), func() error {
+		c.update(c.sample())
+		return nil
+	})
+	instance = c
+
+	logging.Infof("Overload controller is configured: max queue size [%d] max GC pause [%d ms] max goroutines [%d]",
+		config.MaxQueueSize, config.MaxGCPauseMs, config.MaxGoroutines)
+	return c, nil
+}
+
+//Allow return false and Retry-After duration if requests of the priority must be shed. Always true if the controller isn't enabled
+func Allow(priority string) (bool, time.Duration) {
+	if instance == nil {
+		return true, 0
+	}
+
+	return instance.Allow(priority)
+}
+
+func (c *Controller) Allow(priority string) (bool, time.Duration) {
+	c.RLock()
+	pressure := c.pressure
+	c.RUnlock()
+
+	switch priority {
+	case Critical:
+		return true, 0
+	case Bulk:
+		if pressure >= c.config.BulkRatio {
+			return false, c.retryAfter
+		}
+	default:
+		if pressure >= 1 {
+			return false, c.retryAfter
+		}
+	}
+
+	return true, 0
+}
+
+//sample return current queues size, the longest GC pause since the previous sample and goroutines count
+func (c *Controller) sample() *Sample {
+	s := &Sample{Goroutines: runtime.NumGoroutine()}
+	if c.queueSize != nil {
+		s.QueueSize = c.queueSize()
+	}
+
+	if c.config.MaxGCPauseMs > 0 {
+		stats := &debug.GCStats{}
+		debug.ReadGCStats(stats)
+		//Pause is ordered from the most recent
+		for i := 0; i < int(stats.NumGC-c.lastNumGC) && i < len(stats.Pause); i++ {
+			if stats.Pause[i] > s.GCPause {
+				s.GCPause = stats.Pause[i]
+			}
+		}
+		c.lastNumGC = stats.NumGC
+	}
+
+	return s
+}
+
+//update pressure: the max ratio of sample indicators to configured limits. Shedding state changes are logged
+func (c *Controller) update(s *Sample) {
+	pressure := c.evaluate(s)
+
+	c.Lock()
+	previous := c.pressure
+	c.pressure = pressure
+	c.Unlock()
+
+	switch {
+	case pressure >= 1 && previous < 1:
+		logging.Warnf("Server is overloaded (queue size: %d, GC pause: %s, goroutines: %d). Non-critical requests are shed", s.QueueSize, s.GCPause, s.Goroutines)
+	case pressure >= c.config.BulkRatio && previous < c.config.BulkRatio:
+		logging.Warnf("Server is close to overload (queue size: %d, GC pause: %s, goroutines: %d). Bulk requests are shed", s.QueueSize, s.GCPause, s.Goroutines)
+	case pressure < c.config.BulkRatio && previous >= c.config.BulkRatio:
+		logging.Infof("Server isn't overloaded anymore. All requests are accepted")
+	}
+}
+
+func (c *Controller) evaluate(s *Sample) float64 {
+	var pressure float64
+	if c.config.MaxQueueSize > 0 {
+		pressure = maxFloat(pressure, float64(s.QueueSize)/float64(c.config.MaxQueueSize))
+	}
+	if c.config.MaxGCPauseMs > 0 {
+		pressure = maxFloat(pressure, float64(s.GCPause)/float64(time.Duration(c.config.MaxGCPauseMs)*time.Millisecond))
+	}
+	if c.config.MaxGoroutines > 0 {
+		pressure = maxFloat(pressure, float64(s.Goroutines)/float64(c.config.MaxGoroutines))
+	}
+
+	return pressure
+}
+
+func (c *Controller) Close() error {
+	if c.job != nil {
+		c.job.Stop()
+	}
+	instance = nil
+
+	return nil
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,42 @@
+package overload
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestController(t *testing.T) {
+	c := &Controller{config: &Config{MaxQueueSize: 1000, MaxGCPauseMs: 100, BulkRatio: 0.8}, retryAfter: 30 * time.Second}
+
+	c.update(&Sample{QueueSize: 100, GCPause: 10 * time.Millisecond, Goroutines: 100000})
+	for _, priority := range []string{Critical, Normal, Bulk} {
+		allowed, _ := c.Allow(priority)
+		require.True(t, allowed, priority)
+	}
+
+	//bulk requests are shed first
+	c.update(&Sample{QueueSize: 850})
+	allowed, retryAfter := c.Allow(Bulk)
+	require.False(t, allowed)
+	require.Equal(t, 30*time.Second, retryAfter)
+	allowed, _ = c.Allow(Normal)
+	require.True(t, allowed)
+
+	c.update(&Sample{GCPause: 150 * time.Millisecond})
+	allowed, _ = c.Allow(Normal)
+	require.False(t, allowed)
+	allowed, _ = c.Allow(Critical)
+	require.True(t, allowed)
+
+	c.update(&Sample{})
+	allowed, _ = c.Allow(Bulk)
+	require.True(t, allowed)
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.Error(t, (&Config{Enabled: true}).Validate())
+	require.Error(t, (&Config{MaxGoroutines: -1}).Validate())
+	require.Error(t, (&Config{MaxGoroutines: 100, BulkRatio: 2}).Validate())
+	require.NoError(t, (&Config{MaxGoroutines: 100}).Validate())
+}
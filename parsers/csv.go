@@ -1,6 +1,7 @@
 package parsers
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"github.com/jitsucom/eventnative/logging"
@@ -12,46 +13,111 @@ import (
 //toLower and replaced all spaces with underscore
 //cast types
 func ParseCsv(r io.Reader, typeConverts map[string]func(interface{}) (interface{}, error)) ([]map[string]interface{}, error) {
+	return ParseCsvWithDelimiter(r, ',', typeConverts)
+}
+
+//ParseCsvWithDelimiter is a ParseCsv with custom fields delimiter
+func ParseCsvWithDelimiter(r io.Reader, delimiter rune, typeConverts map[string]func(interface{}) (interface{}, error)) ([]map[string]interface{}, error) {
 	var objects []map[string]interface{}
+	err := ReadCsvWithDelimiter(r, delimiter, typeConverts, func(object map[string]interface{}) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+//ReadCsvWithDelimiter is a ParseCsvWithDelimiter which passes objects to handle func one by one instead of keeping them in memory
+//stop on the first handle func error and return it. Return io.EOF if there is no header
+func ReadCsvWithDelimiter(r io.Reader, delimiter rune, typeConverts map[string]func(interface{}) (interface{}, error), handle func(map[string]interface{}) error) error {
 	csvReader := csv.NewReader(r)
+	csvReader.Comma = delimiter
 
 	line, readErr := csvReader.Read()
 	if readErr != nil {
-		return nil, readErr
+		return readErr
 	}
 
 	var header []string
 	for readErr == nil {
 		if len(header) == 0 {
-			for _, field := range line {
-				header = append(header, strings.ToLower(strings.ReplaceAll(field, " ", "_")))
-			}
-		} else {
-			lineObject := map[string]interface{}{}
-			for i, k := range header {
-				strValue := line[i]
-				convertFunc, ok := typeConverts[k]
-				if ok {
-					castedValue, err := convertFunc(strValue)
-					if err != nil {
-						logging.Warnf("Error casting [%s] csv field from string with %v rules: %v", k, typeConverts, err)
-						lineObject[k] = strValue
-					} else {
-						lineObject[k] = castedValue
-					}
-				} else {
-					lineObject[k] = strValue
-				}
-			}
-
-			objects = append(objects, lineObject)
+			header = formatHeader(line)
+		} else if err := handle(toObject(header, line, typeConverts)); err != nil {
+			return err
 		}
 
 		line, readErr = csvReader.Read()
 		if readErr != nil && readErr != io.EOF {
-			return nil, fmt.Errorf("Error reading csv line: %v", readErr)
+			return fmt.Errorf("Error reading csv line: %v", readErr)
 		}
 	}
 
+	return nil
+}
+
+//ParseTsv return objects from tab separated values without quoting (e.g. App Store Connect reports)
+//keys are formatted and types are casted as in ParseCsv. Empty lines are skipped
+func ParseTsv(r io.Reader, typeConverts map[string]func(interface{}) (interface{}, error)) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	var header []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		values := strings.Split(line, "\t")
+		if len(header) == 0 {
+			header = formatHeader(values)
+			continue
+		}
+
+		//pad short lines
+		for len(values) < len(header) {
+			values = append(values, "")
+		}
+		objects = append(objects, toObject(header, values, typeConverts))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading tsv line: %v", err)
+	}
+
 	return objects, nil
 }
+
+//formatHeader return lower cased fields with underscores instead of spaces
+func formatHeader(fields []string) []string {
+	var header []string
+	for _, field := range fields {
+		header = append(header, strings.ToLower(strings.ReplaceAll(field, " ", "_")))
+	}
+
+	return header
+}
+
+func toObject(header, line []string, typeConverts map[string]func(interface{}) (interface{}, error)) map[string]interface{} {
+	lineObject := map[string]interface{}{}
+	for i, k := range header {
+		strValue := line[i]
+		convertFunc, ok := typeConverts[k]
+		if ok {
+			castedValue, err := convertFunc(strValue)
+			if err != nil {
+				logging.Warnf("Error casting [%s] csv field from string with %v rules: %v", k, typeConverts, err)
+				lineObject[k] = strValue
+			} else {
+				lineObject[k] = castedValue
+			}
+		} else {
+			lineObject[k] = strValue
+		}
+	}
+
+	return lineObject
+}
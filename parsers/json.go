@@ -1,9 +1,11 @@
 package parsers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 )
 
 //Parse json bytes into map with json Numbers
@@ -16,6 +18,48 @@ func ParseJson(b []byte) (map[string]interface{}, error) {
 	return obj, err
 }
 
+//ParseJsonLines return objects from newline delimited JSON (JSONL/NDJSON). Empty lines are skipped
+func ParseJsonLines(r io.Reader) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	err := ReadJsonLines(r, func(object map[string]interface{}) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+//ReadJsonLines is a ParseJsonLines which passes objects to handle func line by line instead of keeping them in memory
+//stop on the first handle func error and return it
+func ReadJsonLines(r io.Reader, handle func(map[string]interface{}) error) error {
+	reader := bufio.NewReader(r)
+	lineNumber := 0
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		lineNumber++
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			object, err := ParseJson(trimmed)
+			if err != nil {
+				return fmt.Errorf("malformed JSON line [%d]: %v", lineNumber, err)
+			}
+			if err := handle(object); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
 //Return parsed into map[string]interface{} event from events.FailedFact
 func ParseFallbackJson(line []byte) (map[string]interface{}, error) {
 	object, err := ParseJson(line)
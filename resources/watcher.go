@@ -3,9 +3,8 @@ package resources
 import (
 	"crypto/md5"
 	"fmt"
-	"github.com/jitsucom/eventnative/appstatus"
 	"github.com/jitsucom/eventnative/logging"
-	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/scheduler"
 	"time"
 )
 
@@ -37,18 +36,12 @@ func Watch(name, source string, loadFunc func(string, string) ([]byte, string, e
 	return w.forceReload
 }
 
+//watch load source and schedule reloading job
 func (w *Watcher) watch() {
 	w.download()
-	safego.RunWithRestart(func() {
-		for {
-			if appstatus.Instance.Idle {
-				break
-			}
-
-			time.Sleep(w.reloadEvery)
-
-			w.download()
-		}
+	scheduler.Add("resource_"+w.name, scheduler.Every(w.reloadEvery), func() error {
+		w.download()
+		return nil
 	})
 }
 
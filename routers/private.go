@@ -0,0 +1,36 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/middleware"
+	"net/http/pprof"
+)
+
+//SetupPrivateRouter return router for the private port with debug endpoints (all of them require admin token):
+//net/http/pprof handlers under /debug/pprof and on-demand profiles bundle capturing
+func SetupPrivateRouter(adminToken, serverName string) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	adminTokenMiddleware := middleware.AdminToken{Token: adminToken}
+	debug := router.Group("/debug")
+	{
+		debug.GET("/pprof/", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Index), middleware.AdminTokenErr))
+		debug.GET("/pprof/cmdline", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Cmdline), middleware.AdminTokenErr))
+		debug.GET("/pprof/profile", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Profile), middleware.AdminTokenErr))
+		debug.GET("/pprof/symbol", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Symbol), middleware.AdminTokenErr))
+		debug.POST("/pprof/symbol", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Symbol), middleware.AdminTokenErr))
+		debug.GET("/pprof/trace", adminTokenMiddleware.AdminAuth(gin.WrapF(pprof.Trace), middleware.AdminTokenErr))
+		//named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+		debug.GET("/pprof/:name", adminTokenMiddleware.AdminAuth(func(c *gin.Context) {
+			pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+		}, middleware.AdminTokenErr))
+
+		debug.POST("/capture", adminTokenMiddleware.AdminAuth(handlers.NewProfilerHandler(serverName).CaptureHandler, middleware.AdminTokenErr))
+	}
+
+	return router
+}
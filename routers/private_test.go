@@ -0,0 +1,41 @@
+package routers
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrivateRouter(t *testing.T) {
+	router := SetupPrivateRouter("admin", "test")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?token=admin", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/capture?token=admin&seconds=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	bundle, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range bundle.File {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"cpu.pprof", "heap.pprof", "goroutine.pprof"}, names)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/capture?token=admin&seconds=0", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
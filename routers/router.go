@@ -6,64 +6,163 @@ import (
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/cluster"
 	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drain"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/fallback"
 	"github.com/jitsucom/eventnative/handlers"
+	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
 	"github.com/jitsucom/eventnative/middleware"
+	"github.com/jitsucom/eventnative/overload"
 	"github.com/jitsucom/eventnative/sources"
 	"github.com/jitsucom/eventnative/users"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 	"net/http"
+	"time"
 )
 
-func SetupRouter(destinations *destinations.Service, adminToken string, clusterManager cluster.Manager, eventsCache *caching.EventsCache,
-	inMemoryEventsCache *events.Cache, sources *sources.Service, fallbackService *fallback.Service, usersRecognitionService *users.RecognitionService) *gin.Engine {
+// routePriorities are overload priorities: events intake and health probes are never shed, bulk requests are shed first
+var routePriorities = map[string]string{
+	"/health/live":                        overload.Critical,
+	"/health/ready":                       overload.Critical,
+	"/ping":                               overload.Critical,
+	"/api/v1/event":                       overload.Critical,
+	"/api/v1/event.gif":                   overload.Critical,
+	"/api/v1/s2s/event":                   overload.Critical,
+	"/api.:ignored":                       overload.Critical,
+	"/i":                                  overload.Critical,
+	"/com.snowplowanalytics.snowplow/tp2": overload.Critical,
+	"/api/v1/fallback/replay":             overload.Bulk,
+	"/api/v1/events/cache/rerun":          overload.Bulk,
+	"/api/v1/sources/:id/sync":            overload.Bulk,
+	"/api/v1/sources/:id/reset":           overload.Bulk,
+	"/api/v1/destinations/test":           overload.Bulk,
+	"/api/v1/events/bulk":                 overload.Bulk,
+	"/api/v1/meta/export":                 overload.Bulk,
+	"/api/v1/meta/import":                 overload.Bulk,
+	"/api/v1/users/:id/export":            overload.Bulk,
+	"/api/v1/state":                       overload.Bulk,
+	"/api/v1/admin/drain":                 overload.Critical,
+}
+
+//ingestRoutes are events intake routes which are rejected while the server is draining
+//and which raw requests are captured by token capture sessions
+var ingestRoutes = map[string]bool{
+	"/api/v1/event":                       true,
+	"/api/v1/event.gif":                   true,
+	"/api/v1/s2s/event":                   true,
+	"/api/v1/events/bulk":                 true,
+	"/api.:ignored":                       true,
+	"/i":                                  true,
+	"/com.snowplowanalytics.snowplow/tp2": true,
+}
+
+//SetupRouter return router with all the endpoints. drainService might be nil (drain endpoint isn't registered)
+func SetupRouter(destinations *destinations.Service, adminToken string, clusterManager cluster.Manager, metaStorage meta.Storage, eventsCache *caching.EventsCache,
+	inMemoryEventsCache *events.Cache, sources *sources.Service, fallbackService *fallback.Service, usersRecognitionService *users.RecognitionService,
+	identityService *users.IdentityService, drainService *drain.Service) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New() //gin.Default()
 	router.Use(gin.Recovery())
+	router.Use(middleware.LoadShedding(routePriorities))
+	router.Use(middleware.Draining(ingestRoutes))
+	router.Use(middleware.CaptureRequests(ingestRoutes, appconfig.Instance.AuthorizationService.GetTokenId))
 
 	router.GET("/", handlers.NewRedirectHandler("/p/welcome.html").Handler)
+	healthHandler := handlers.NewHealthHandler(metaStorage, destinations, clusterManager)
+	router.GET("/health/live", healthHandler.LiveHandler)
+	router.GET("/health/ready", healthHandler.ReadyHandler)
+	//Deprecated: use /health/live
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
 	publicUrl := viper.GetString("server.public_url")
 
-	htmlHandler := handlers.NewPageHandler(viper.GetString("server.static_files_dir"), publicUrl, viper.GetBool("server.disable_welcome_page"))
+	var statusPage *handlers.StatusPage
+	if !viper.GetBool("server.status_page.disabled") {
+		statusPage = handlers.NewStatusPage(adminToken, healthHandler, time.Duration(viper.GetInt("server.status_page.cache_ttl_sec"))*time.Second)
+	}
+	htmlHandler := handlers.NewPageHandler(viper.GetString("server.static_files_dir"), publicUrl, viper.GetBool("server.disable_welcome_page"), statusPage)
 	router.GET("/p/:filename", htmlHandler.Handler)
 
 	staticHandler := handlers.NewStaticHandler(viper.GetString("server.static_files_dir"), publicUrl)
 	router.GET("/s/:filename", staticHandler.Handler)
 	router.GET("/t/:filename", staticHandler.Handler)
 
-	jsEventHandler := handlers.NewEventHandler(destinations, events.NewJsPreprocessor(), eventsCache, inMemoryEventsCache, usersRecognitionService)
-	apiEventHandler := handlers.NewEventHandler(destinations, events.NewApiPreprocessor(), eventsCache, inMemoryEventsCache, usersRecognitionService)
+	jsEventHandler := handlers.NewEventHandler(destinations, events.NewJsPreprocessor(), eventsCache, inMemoryEventsCache, usersRecognitionService, identityService)
+	apiEventHandler := handlers.NewEventHandler(destinations, events.NewApiPreprocessor(), eventsCache, inMemoryEventsCache, usersRecognitionService, identityService)
+	snowplowEventHandler := handlers.NewEventHandler(destinations, events.NewSnowplowPreprocessor(), eventsCache, inMemoryEventsCache, usersRecognitionService, identityService)
 
 	sourcesHandler := handlers.NewSourcesHandler(sources)
 	fallbackHandler := handlers.NewFallbackHandler(fallbackService)
+	statisticsHandler := handlers.NewStatisticsHandler()
+	schemaHandler := handlers.NewSchemaHandler(destinations)
+	metaHandler := handlers.NewMetaHandler(metaStorage)
+	usersHandler := handlers.NewUsersHandler(destinations, viper.GetStringSlice("users_export.columns"))
 
-	adminTokenMiddleware := middleware.AdminToken{Token: adminToken}
+	adminTokenMiddleware := middleware.AdminToken{Token: adminToken, ScopeFunc: appconfig.Instance.AuthorizationService.HasServerScope}
 	apiV1 := router.Group("/api/v1")
 	{
-		apiV1.POST("/event", middleware.TokenFuncAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
-		apiV1.POST("/s2s/event", middleware.TokenTwoFuncAuth(apiEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetServerOrigins, appconfig.Instance.AuthorizationService.GetClientOrigins, "The token isn't a server token. Please use s2s integration token"))
+		apiV1.POST("/event", middleware.TokenFuncAuth(middleware.SignatureAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetSigningSecret, metaStorage), appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+		apiV1.GET("/event.gif", middleware.TokenFuncAuth(middleware.RejectSigned(jsEventHandler.PixelHandler, appconfig.Instance.AuthorizationService.GetSigningSecret), appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+		apiV1.POST("/s2s/event", middleware.TokenTwoFuncAuth(middleware.SignatureAuth(apiEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetSigningSecret, metaStorage), appconfig.Instance.AuthorizationService.GetServerOrigins, appconfig.Instance.AuthorizationService.GetClientOrigins, "The token isn't a server token. Please use s2s integration token"))
+		apiV1.POST("/events/bulk", adminTokenMiddleware.AdminOrTokenFuncAuth(handlers.NewBulkHandler(destinations).Handler, appconfig.Instance.AuthorizationService.GetServerOrigins, "The token isn't a server token or admin token"))
 
 		apiV1.POST("/destinations/test", adminTokenMiddleware.AdminAuth(handlers.DestinationsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/destinations/status", adminTokenMiddleware.ReadAuth(handlers.NewDestinationsStatusHandler(destinations).Handler, middleware.AdminTokenErr))
+		//static and wildcard segments on the same level (destinations/test and destinations/:id) require gin >= 1.7
+		apiV1.POST("/destinations/:id/schema/refresh", adminTokenMiddleware.AdminAuth(schemaHandler.RefreshHandler, middleware.AdminTokenErr))
 		apiV1.POST("/sources/:id/sync", adminTokenMiddleware.AdminAuth(sourcesHandler.SyncHandler, middleware.AdminTokenErr))
-		apiV1.GET("/sources/:id/status", adminTokenMiddleware.AdminAuth(sourcesHandler.StatusHandler, middleware.AdminTokenErr))
-
-		apiV1.GET("/cluster", adminTokenMiddleware.AdminAuth(handlers.NewClusterHandler(clusterManager).Handler, middleware.AdminTokenErr))
+		apiV1.POST("/sources/:id/reset", adminTokenMiddleware.AdminAuth(sourcesHandler.ResetHandler, middleware.AdminTokenErr))
+		apiV1.GET("/sources/:id/status", adminTokenMiddleware.ReadAuth(sourcesHandler.StatusHandler, middleware.AdminTokenErr))
+		apiV1.GET("/sources/:id/tasks", adminTokenMiddleware.ReadAuth(sourcesHandler.TasksHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/cluster", adminTokenMiddleware.ReadAuth(handlers.NewClusterHandler(clusterManager).Handler, middleware.AdminTokenErr))
+		apiV1.GET("/features", adminTokenMiddleware.ReadAuth(handlers.FeaturesGetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/features", adminTokenMiddleware.AdminAuth(handlers.FeaturesPostHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/state", adminTokenMiddleware.AdminAuth(handlers.StateGetHandler, middleware.AdminTokenErr))
+		apiV1.PUT("/state", adminTokenMiddleware.AdminAuth(handlers.StatePutHandler, middleware.AdminTokenErr))
+		apiV1.DELETE("/features/:name", adminTokenMiddleware.AdminAuth(handlers.FeaturesDeleteHandler, middleware.AdminTokenErr))
+		apiV1.GET("/scheduler/jobs", adminTokenMiddleware.ReadAuth(handlers.SchedulerJobsHandler, middleware.AdminTokenErr))
 		apiV1.GET("/cache/events", adminTokenMiddleware.AdminAuth(jsEventHandler.OldGetHandler, middleware.AdminTokenErr))
 		apiV1.GET("/events/cache", adminTokenMiddleware.AdminAuth(jsEventHandler.GetHandler, middleware.AdminTokenErr))
+		apiV1.POST("/events/cache/rerun", adminTokenMiddleware.AdminAuth(jsEventHandler.RerunHandler, middleware.AdminTokenErr))
+		apiV1.GET("/events/stream", adminTokenMiddleware.AdminAuth(jsEventHandler.StreamHandler, middleware.AdminTokenErr))
 
 		apiV1.GET("/fallback", adminTokenMiddleware.AdminAuth(fallbackHandler.GetHandler, middleware.AdminTokenErr))
 		apiV1.POST("/fallback/replay", adminTokenMiddleware.AdminAuth(fallbackHandler.ReplayHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/statistics", adminTokenMiddleware.ReadAuth(statisticsHandler.EventsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/statistics/uniques", adminTokenMiddleware.ReadAuth(statisticsHandler.UniquesHandler, middleware.AdminTokenErr))
+		apiV1.GET("/statistics/pipeline", adminTokenMiddleware.ReadAuth(statisticsHandler.PipelineHandler, middleware.AdminTokenErr))
+		apiV1.GET("/statistics/drift", adminTokenMiddleware.ReadAuth(statisticsHandler.DriftHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/meta/export", adminTokenMiddleware.AdminAuth(metaHandler.ExportHandler, middleware.AdminTokenErr))
+		apiV1.POST("/meta/import", adminTokenMiddleware.AdminAuth(metaHandler.ImportHandler, middleware.AdminTokenErr))
+
+		apiV1.GET("/users/:id/export", adminTokenMiddleware.AdminAuth(usersHandler.ExportHandler, middleware.AdminTokenErr))
+
+		apiV1.POST("/debug/capture", adminTokenMiddleware.AdminAuth(handlers.CaptureStartHandler, middleware.AdminTokenErr))
+		apiV1.GET("/debug/capture", adminTokenMiddleware.AdminAuth(handlers.CaptureSessionsHandler, middleware.AdminTokenErr))
+		apiV1.GET("/debug/capture/:token_id", adminTokenMiddleware.AdminAuth(handlers.CaptureGetHandler, middleware.AdminTokenErr))
+		apiV1.DELETE("/debug/capture/:token_id", adminTokenMiddleware.AdminAuth(handlers.CaptureStopHandler, middleware.AdminTokenErr))
+
+		if drainService != nil {
+			drainHandler := handlers.NewDrainHandler(drainService)
+			apiV1.POST("/admin/drain", adminTokenMiddleware.AdminAuth(drainHandler.PostHandler, middleware.AdminTokenErr))
+			apiV1.GET("/admin/drain", adminTokenMiddleware.ReadAuth(drainHandler.GetHandler, middleware.AdminTokenErr))
+		}
 	}
 
-	router.POST("/api.:ignored", middleware.TokenFuncAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
+	//Snowplow collector compatibility: tracker events are authorized by app id (aid) or token parameter
+	router.GET("/i", snowplowEventHandler.SnowplowGetHandler)
+	router.POST("/com.snowplowanalytics.snowplow/tp2", snowplowEventHandler.SnowplowPostHandler)
+
+	router.POST("/api.:ignored", middleware.TokenFuncAuth(middleware.SignatureAuth(jsEventHandler.PostHandler, appconfig.Instance.AuthorizationService.GetSigningSecret, metaStorage), appconfig.Instance.AuthorizationService.GetClientOrigins, ""))
 
 	if metrics.Enabled {
 		router.GET("/prometheus", middleware.TokenAuth(gin.WrapH(promhttp.Handler()), adminToken))
@@ -0,0 +1,193 @@
+package routing
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/geo"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"strings"
+	"sync"
+)
+
+var (
+	//regions are aliases which can be used in residency countries list
+	regions = map[string][]string{
+		"EU": {"AT", "BE", "BG", "HR", "CY", "CZ", "DK", "EE", "FI", "FR", "DE", "GR", "HU", "IE", "IT", "LV", "LT", "LU", "MT",
+			"NL", "PL", "PT", "RO", "SK", "SI", "ES", "SE"},
+	}
+
+	countryPath  = jsonutils.NewJsonPath("/eventn_ctx/location/country")
+	sourceIpPath = jsonutils.NewJsonPath("/source_ip")
+
+	strictRegistry = &registry{residencies: map[string]*Residency{}}
+)
+
+func init() {
+	regions["EEA"] = append([]string{"IS", "LI", "NO"}, regions["EU"]...)
+}
+
+//ResidencyConfig is a destination data residency configuration:
+//only events from Countries (ISO codes or regions: EU, EEA) are delivered to the destination.
+//If Strict is true - events from these countries aren't delivered to other destinations of the same tokens
+//and events without resolved country aren't delivered to the destination
+type ResidencyConfig struct {
+	Countries []string `mapstructure:"countries" json:"countries,omitempty" yaml:"countries,omitempty"`
+	Strict    bool     `mapstructure:"strict" json:"strict,omitempty" yaml:"strict,omitempty"`
+}
+
+//Residency matches events by resolved country: from /eventn_ctx/location/country or by /source_ip lookup
+type Residency struct {
+	destinationId string
+	tokenIds      []string
+	//nil if destination accepts events from all countries
+	countries   map[string]bool
+	strict      bool
+	geoResolver geo.Resolver
+}
+
+//NewResidency return Residency of the destination. Residency isn't nil even if config is nil
+//because strict residencies of other destinations of the same tokens are also checked
+func NewResidency(destinationId string, tokenIds []string, config *ResidencyConfig, geoResolver geo.Resolver) (*Residency, error) {
+	r := &Residency{destinationId: destinationId, tokenIds: tokenIds, geoResolver: geoResolver}
+	if config == nil {
+		return r, nil
+	}
+
+	if len(config.Countries) == 0 {
+		return nil, errors.New("residency countries are required")
+	}
+
+	r.countries = map[string]bool{}
+	for _, country := range config.Countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if regionCountries, ok := regions[country]; ok {
+			for _, regionCountry := range regionCountries {
+				r.countries[regionCountry] = true
+			}
+			continue
+		}
+		if len(country) != 2 {
+			return nil, errors.New("residency country must be ISO 3166-1 alpha-2 code or one of regions: [EU, EEA]: " + country)
+		}
+		r.countries[country] = true
+	}
+	r.strict = config.Strict
+
+	return r, nil
+}
+
+//IsStrict return true if the residency blocks delivery of its countries events to other destinations
+func (r *Residency) IsStrict() bool {
+	return r != nil && r.strict
+}
+
+//Match return true if event country is allowed in the destination and
+//the country isn't bound to another destination of the same tokens with strict residency
+func (r *Residency) Match(event map[string]interface{}) bool {
+	if r == nil {
+		return true
+	}
+	//skip geo resolving if there aren't any residency restrictions
+	if r.countries == nil && strictRegistry.isEmpty() {
+		return true
+	}
+
+	country := r.resolveCountry(event)
+	if r.countries != nil {
+		if country == "" {
+			return !r.strict
+		}
+		if !r.countries[country] {
+			return false
+		}
+	}
+
+	//destinations with the country in own residency are always allowed
+	return country == "" || r.countries[country] || strictRegistry.allowed(r.destinationId, r.tokenIds, country)
+}
+
+//resolveCountry return upper case ISO country code or empty string if it can't be resolved
+func (r *Residency) resolveCountry(event map[string]interface{}) string {
+	if country, ok := countryPath.Get(event); ok {
+		if countryStr, ok := country.(string); ok && countryStr != "" {
+			return strings.ToUpper(countryStr)
+		}
+	}
+
+	if r.geoResolver == nil {
+		return ""
+	}
+	ip, ok := sourceIpPath.Get(event)
+	if !ok {
+		return ""
+	}
+	ipStr, ok := ip.(string)
+	if !ok || ipStr == "" {
+		return ""
+	}
+	data, err := r.geoResolver.Resolve(ipStr)
+	if err != nil || data == nil {
+		return ""
+	}
+
+	return strings.ToUpper(data.Country)
+}
+
+//RegisterStrict make destination strict residency visible for other destinations of the same tokens
+func RegisterStrict(residency *Residency) {
+	if residency.IsStrict() {
+		strictRegistry.add(residency)
+	}
+}
+
+//UnregisterStrict remove destination strict residency (on destination removing)
+func UnregisterStrict(destinationId string) {
+	strictRegistry.remove(destinationId)
+}
+
+//registry holds strict residencies by destination id
+type registry struct {
+	sync.RWMutex
+	residencies map[string]*Residency
+}
+
+func (reg *registry) add(residency *Residency) {
+	reg.Lock()
+	reg.residencies[residency.destinationId] = residency
+	reg.Unlock()
+}
+
+func (reg *registry) remove(destinationId string) {
+	reg.Lock()
+	delete(reg.residencies, destinationId)
+	reg.Unlock()
+}
+
+func (reg *registry) isEmpty() bool {
+	reg.RLock()
+	defer reg.RUnlock()
+
+	return len(reg.residencies) == 0
+}
+
+//allowed return false if there is another destination with strict residency of the country which shares a token with the destination
+//destinations with several tokens are checked against all their tokens
+func (reg *registry) allowed(destinationId string, tokenIds []string, country string) bool {
+	reg.RLock()
+	defer reg.RUnlock()
+
+	for strictDestinationId, strictResidency := range reg.residencies {
+		if strictDestinationId == destinationId || !strictResidency.countries[country] {
+			continue
+		}
+
+		for _, tokenId := range tokenIds {
+			for _, strictTokenId := range strictResidency.tokenIds {
+				if tokenId == strictTokenId {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
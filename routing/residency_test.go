@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"github.com/jitsucom/eventnative/geo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResidency(t *testing.T) {
+	resolver := geo.Mock{"10.10.10.10": {Country: "DE"}, "20.20.20.20": {Country: "US"}}
+	deEvent := map[string]interface{}{"source_ip": "10.10.10.10"}
+	usEvent := map[string]interface{}{"source_ip": "20.20.20.20"}
+	frEvent := map[string]interface{}{"eventn_ctx": map[string]interface{}{"location": map[string]interface{}{"country": "fr"}}}
+	unknownEvent := map[string]interface{}{"source_ip": "30.30.30.30"}
+
+	eu, err := NewResidency("eu_warehouse", []string{"token1"}, &ResidencyConfig{Countries: []string{"EU"}, Strict: true}, resolver)
+	require.NoError(t, err)
+	us, err := NewResidency("us_warehouse", []string{"token1", "token2"}, nil, resolver)
+	require.NoError(t, err)
+	other, err := NewResidency("other_warehouse", []string{"token3"}, nil, resolver)
+	require.NoError(t, err)
+
+	require.True(t, eu.Match(deEvent))
+	require.True(t, eu.Match(frEvent))
+	require.False(t, eu.Match(usEvent))
+	require.False(t, eu.Match(unknownEvent), "strict residency doesn't accept events without country")
+
+	//before registration EU events are delivered everywhere
+	require.True(t, us.Match(deEvent))
+
+	RegisterStrict(eu)
+	defer UnregisterStrict("eu_warehouse")
+
+	require.False(t, us.Match(deEvent))
+	require.False(t, us.Match(frEvent))
+	require.True(t, us.Match(usEvent))
+	require.True(t, us.Match(unknownEvent))
+	require.True(t, other.Match(deEvent), "destination of another token isn't restricted")
+
+	rules, err := ParseRules([]string{"event_type == 'purchase'"})
+	require.NoError(t, err)
+	rules = rules.WithResidency(eu)
+	require.True(t, rules.Match(map[string]interface{}{"event_type": "purchase", "source_ip": "10.10.10.10"}))
+	require.False(t, rules.Match(map[string]interface{}{"event_type": "purchase", "source_ip": "20.20.20.20"}))
+	require.False(t, rules.Match(map[string]interface{}{"event_type": "pageview", "source_ip": "10.10.10.10"}))
+}
+
+func TestNewResidencyErrors(t *testing.T) {
+	_, err := NewResidency("test", nil, &ResidencyConfig{}, nil)
+	require.Error(t, err)
+	_, err = NewResidency("test", nil, &ResidencyConfig{Countries: []string{"Germany"}}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"strings"
+)
+
+const (
+	equalOperator    = "=="
+	notEqualOperator = "!="
+	andOperator      = "&&"
+
+	nullLiteral = "null"
+)
+
+//condition is a comparison of event field (JSON path) with a literal value
+type condition struct {
+	path     *jsonutils.JsonPath
+	operator string
+	//value is nil if literal is null
+	value *string
+}
+
+//Rules is a set of routing rules of a destination. Event matches if at least one rule matches.
+//Rule is conditions joined with && e.g. "event_type == 'purchase' && /eventn_ctx/utm/source != null"
+//Condition format: <field JSON path> <== or !=> <'string', number, true, false or null>
+//Residency (if set) is checked before rules
+type Rules struct {
+	rules     [][]*condition
+	residency *Residency
+}
+
+//ParseRules return parsed Rules or nil if there are no rules (all events match)
+func ParseRules(expressions []string) (*Rules, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+
+	rules := &Rules{}
+	for _, expression := range expressions {
+		var conditions []*condition
+		for _, part := range strings.Split(expression, andOperator) {
+			c, err := parseCondition(part)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing routing rule [%s]: %v", expression, err)
+			}
+			conditions = append(conditions, c)
+		}
+		rules.rules = append(rules.rules, conditions)
+	}
+
+	return rules, nil
+}
+
+//WithResidency return rules with data residency check (creates empty rules if r is nil)
+func (r *Rules) WithResidency(residency *Residency) *Rules {
+	if residency == nil {
+		return r
+	}
+	if r == nil {
+		r = &Rules{}
+	}
+	r.residency = residency
+
+	return r
+}
+
+//Match return true if rules are nil or event matches residency and at least one rule (or there are no rules)
+func (r *Rules) Match(event map[string]interface{}) bool {
+	if r == nil {
+		return true
+	}
+	if !r.residency.Match(event) {
+		return false
+	}
+	if len(r.rules) == 0 {
+		return true
+	}
+
+	for _, conditions := range r.rules {
+		matched := true
+		for _, c := range conditions {
+			if !c.match(event) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCondition(expression string) (*condition, error) {
+	//the first operator is used: values may contain operators
+	operator := equalOperator
+	index := strings.Index(expression, equalOperator)
+	if notEqualIndex := strings.Index(expression, notEqualOperator); notEqualIndex >= 0 && (index < 0 || notEqualIndex < index) {
+		operator = notEqualOperator
+		index = notEqualIndex
+	}
+	if index < 0 {
+		return nil, errors.New("condition must contain == or != operator")
+	}
+
+	field := strings.TrimSpace(expression[:index])
+	if field == "" {
+		return nil, errors.New("field is empty")
+	}
+
+	literal := strings.TrimSpace(expression[index+len(operator):])
+	if literal == "" {
+		return nil, errors.New("value is empty")
+	}
+
+	c := &condition{path: jsonutils.NewJsonPath(field), operator: operator}
+	if literal == nullLiteral {
+		return c, nil
+	}
+
+	if len(literal) >= 2 && (literal[0] == '\'' || literal[0] == '"') {
+		if literal[len(literal)-1] != literal[0] {
+			return nil, fmt.Errorf("value %s isn't closed with quote", literal)
+		}
+		literal = literal[1 : len(literal)-1]
+	}
+	c.value = &literal
+
+	return c, nil
+}
+
+func (c *condition) match(event map[string]interface{}) bool {
+	value, ok := c.path.Get(event)
+	var equal bool
+	if c.value == nil {
+		equal = !ok || value == nil
+	} else {
+		equal = ok && value != nil && fmt.Sprint(value) == *c.value
+	}
+
+	if c.operator == equalOperator {
+		return equal
+	}
+
+	return !equal
+}
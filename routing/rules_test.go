@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules([]string{
+		"event_type == 'purchase'",
+		`event_type == "refund" && /eventn_ctx/amount != 0`,
+		"/eventn_ctx/utm/source != null && vip == true",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		event    map[string]interface{}
+		expected bool
+	}{
+		{"first rule", map[string]interface{}{"event_type": "purchase"}, true},
+		{"no rules matched", map[string]interface{}{"event_type": "pageview"}, false},
+		{"second rule", map[string]interface{}{"event_type": "refund", "eventn_ctx": map[string]interface{}{"amount": json.Number("10")}}, true},
+		{"second rule zero amount", map[string]interface{}{"event_type": "refund", "eventn_ctx": map[string]interface{}{"amount": json.Number("0")}}, false},
+		{"second rule missing amount", map[string]interface{}{"event_type": "refund"}, true},
+		{"third rule", map[string]interface{}{"vip": true, "eventn_ctx": map[string]interface{}{"utm": map[string]interface{}{"source": "google"}}}, true},
+		{"third rule null source", map[string]interface{}{"vip": true, "eventn_ctx": map[string]interface{}{"utm": map[string]interface{}{"source": nil}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, rules.Match(tt.event))
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(nil)
+	require.NoError(t, err)
+	require.True(t, rules.Match(map[string]interface{}{}))
+
+	rules, err = ParseRules([]string{"title != '=='"})
+	require.NoError(t, err)
+	require.True(t, rules.Match(map[string]interface{}{"title": "a"}))
+	require.False(t, rules.Match(map[string]interface{}{"title": "=="}))
+
+	_, err = ParseRules([]string{"event_type = 'purchase'"})
+	require.Error(t, err)
+	_, err = ParseRules([]string{"event_type == 'purchase"})
+	require.Error(t, err)
+	_, err = ParseRules([]string{" == 'purchase'"})
+	require.Error(t, err)
+}
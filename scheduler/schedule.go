@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"strings"
+	"time"
+)
+
+const atPrefix = "@at "
+
+//Schedule return the next run time after the given time. Zero time means that there are no more runs
+type Schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+//interval runs job every duration
+type interval struct {
+	every time.Duration
+}
+
+//Every return fixed interval schedule
+func Every(every time.Duration) Schedule {
+	return &interval{every: every}
+}
+
+func (i *interval) Next(after time.Time) time.Time {
+	return after.Add(i.every)
+}
+
+func (i *interval) String() string {
+	return "every " + i.every.String()
+}
+
+//at runs job once
+type at struct {
+	time time.Time
+}
+
+//At return one-off schedule
+func At(t time.Time) Schedule {
+	return &at{time: t}
+}
+
+func (a *at) Next(after time.Time) time.Time {
+	if a.time.After(after) {
+		return a.time
+	}
+
+	return time.Time{}
+}
+
+func (a *at) String() string {
+	return "at " + a.time.UTC().Format(time.RFC3339)
+}
+
+//cronSchedule runs job by standard 5 fields cron expression (or descriptors like @hourly, @every 5m) in UTC
+type cronSchedule struct {
+	expression string
+	schedule   cron.Schedule
+}
+
+func (cs *cronSchedule) Next(after time.Time) time.Time {
+	return cs.schedule.Next(after.UTC())
+}
+
+func (cs *cronSchedule) String() string {
+	return "cron " + cs.expression
+}
+
+//Parse return schedule from string:
+//duration (30s, 5m), one-off '@at <RFC3339 time>' or cron expression ('*/5 * * * *', @hourly, @every 1h)
+func Parse(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, fmt.Errorf("schedule can't be empty")
+	}
+
+	if every, err := time.ParseDuration(expression); err == nil {
+		if every <= 0 {
+			return nil, fmt.Errorf("schedule interval must be positive: %s", expression)
+		}
+		return Every(every), nil
+	}
+
+	if strings.HasPrefix(expression, atPrefix) {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(strings.TrimPrefix(expression, atPrefix)))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s schedule time (RFC3339 format is expected): %v", expression, err)
+		}
+		return At(t), nil
+	}
+
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing cron expression [%s]: %v", expression, err)
+	}
+
+	return &cronSchedule{expression: expression, schedule: schedule}, nil
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/safego"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var instance = New()
+
+//JobConfig overrides job schedule and jitter by job name
+type JobConfig struct {
+	//Schedule is a duration, '@at <RFC3339 time>' or cron expression (see Parse)
+	Schedule  string `mapstructure:"schedule" json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	JitterSec int    `mapstructure:"jitter_sec" json:"jitter_sec,omitempty" yaml:"jitter_sec,omitempty"`
+}
+
+//Config is a scheduler configuration
+type Config struct {
+	Jobs map[string]*JobConfig `mapstructure:"jobs" json:"jobs,omitempty" yaml:"jobs,omitempty"`
+}
+
+//JobStatus is a job state for runtime inspection
+type JobStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	JitterSec    int        `json:"jitter_sec,omitempty"`
+	Running      bool       `json:"running"`
+	Runs         uint64     `json:"runs"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+//Scheduler runs background jobs by schedules with jitter.
+//Every job is run in a separate goroutine: runs of the same job don't overlap
+type Scheduler struct {
+	sync.RWMutex
+
+	overrides map[string]*JobConfig
+	jobs      map[string]*Job
+	closed    bool
+}
+
+//Job is a scheduled function
+type Job struct {
+	sync.Mutex
+
+	scheduler *Scheduler
+	name      string
+	schedule  Schedule
+	jitter    time.Duration
+	run       func() error
+
+	runNow chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	running      bool
+	runs         uint64
+	nextRun      time.Time
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastError    string
+}
+
+func New() *Scheduler {
+	return &Scheduler{overrides: map[string]*JobConfig{}, jobs: map[string]*Job{}}
+}
+
+//Init validate jobs schedules overrides and apply them to all jobs which will be added
+func Init(config *Config) error {
+	if config == nil {
+		return nil
+	}
+
+	for name, jobConfig := range config.Jobs {
+		if jobConfig == nil {
+			continue
+		}
+		if jobConfig.Schedule != "" {
+			if _, err := Parse(jobConfig.Schedule); err != nil {
+				return fmt.Errorf("Error parsing scheduler job [%s] schedule: %v", name, err)
+			}
+		}
+		if jobConfig.JitterSec < 0 {
+			return fmt.Errorf("scheduler job [%s] jitter_sec can't be negative", name)
+		}
+	}
+
+	instance.Lock()
+	instance.overrides = config.Jobs
+	instance.Unlock()
+	return nil
+}
+
+//Add schedule job in the global scheduler
+func Add(name string, schedule Schedule, run func() error) *Job {
+	return instance.Add(name, schedule, run)
+}
+
+//Jobs return all jobs statuses of the global scheduler
+func Jobs() []*JobStatus {
+	return instance.Jobs()
+}
+
+//Close stop all jobs of the global scheduler
+func Close() error {
+	return instance.Close()
+}
+
+//Add start running job by schedule (or by configured override). Job with the same name is replaced
+//run error is saved in the job status
+func (s *Scheduler) Add(name string, schedule Schedule, run func() error) *Job {
+	s.Lock()
+	defer s.Unlock()
+
+	job := &Job{
+		scheduler: s,
+		name:      name,
+		schedule:  schedule,
+		run:       run,
+		runNow:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	if override, ok := s.overrides[name]; ok && override != nil {
+		if override.Schedule != "" {
+			//validated in Init
+			job.schedule, _ = Parse(override.Schedule)
+		}
+		job.jitter = time.Duration(override.JitterSec) * time.Second
+	}
+
+	if s.closed {
+		job.stop()
+		return job
+	}
+
+	if existing, ok := s.jobs[name]; ok {
+		existing.stop()
+	}
+	s.jobs[name] = job
+
+	logging.Debugf("Job [%s] has been scheduled: %s", name, job.schedule.String())
+	safego.RunWithRestart(job.loop)
+	return job
+}
+
+//Jobs return jobs statuses sorted by name
+func (s *Scheduler) Jobs() []*JobStatus {
+	s.RLock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.RUnlock()
+
+	statuses := make([]*JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+//Close stop all jobs. Running jobs aren't interrupted
+func (s *Scheduler) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.closed = true
+	for _, job := range s.jobs {
+		job.stop()
+	}
+
+	return nil
+}
+
+//RunNow trigger job run without waiting for the schedule (does nothing if run is already triggered)
+func (j *Job) RunNow() {
+	select {
+	case j.runNow <- struct{}{}:
+	default:
+	}
+}
+
+//Stop job and remove it from the scheduler. Running job isn't interrupted
+func (j *Job) Stop() {
+	j.stop()
+
+	j.scheduler.Lock()
+	if j.scheduler.jobs[j.name] == j {
+		delete(j.scheduler.jobs, j.name)
+	}
+	j.scheduler.Unlock()
+}
+
+func (j *Job) stop() {
+	j.once.Do(func() {
+		close(j.done)
+	})
+}
+
+func (j *Job) Status() *JobStatus {
+	j.Lock()
+	defer j.Unlock()
+
+	status := &JobStatus{
+		Name:      j.name,
+		Schedule:  j.schedule.String(),
+		JitterSec: int(j.jitter.Seconds()),
+		Running:   j.running,
+		Runs:      j.runs,
+		LastError: j.lastError,
+	}
+	if !j.nextRun.IsZero() {
+		nextRun := j.nextRun
+		status.NextRun = &nextRun
+	}
+	if !j.lastRun.IsZero() {
+		lastRun := j.lastRun
+		status.LastRun = &lastRun
+		status.LastDuration = j.lastDuration.String()
+	}
+
+	return status
+}
+
+//loop wait for the next run time (with random jitter) or RunNow trigger and run the job until stop
+func (j *Job) loop() {
+	for {
+		next := j.schedule.Next(time.Now().UTC())
+		//nil channel blocks forever: one-off job which has been run waits only for RunNow or stop
+		var timerCh <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			if j.jitter > 0 {
+				next = next.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+			}
+			timer = time.NewTimer(time.Until(next))
+			timerCh = timer.C
+		}
+		j.setNextRun(next)
+
+		select {
+		case <-j.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-timerCh:
+		case <-j.runNow:
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		j.execute()
+	}
+}
+
+func (j *Job) setNextRun(next time.Time) {
+	j.Lock()
+	j.nextRun = next
+	j.Unlock()
+}
+
+//execute run job function and save the result. Panic is saved as an error
+func (j *Job) execute() {
+	j.Lock()
+	j.running = true
+	j.Unlock()
+
+	start := time.Now().UTC()
+	err := j.safeRun()
+
+	j.Lock()
+	defer j.Unlock()
+	j.running = false
+	j.runs++
+	j.lastRun = start
+	j.lastDuration = time.Since(start)
+	j.lastError = ""
+	if err != nil {
+		j.lastError = err.Error()
+		logging.Errorf("Job [%s] run error: %v", j.name, err)
+	}
+}
+
+func (j *Job) safeRun() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.run()
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		expected    string
+		expectedErr bool
+	}{
+		{"duration", "30s", "every 30s", false},
+		{"cron", "*/5 * * * *", "cron */5 * * * *", false},
+		{"descriptor", "@hourly", "cron @hourly", false},
+		{"at", "@at 2021-01-01T00:00:00Z", "at 2021-01-01T00:00:00Z", false},
+		{"empty", "", "", true},
+		{"negative duration", "-1s", "", true},
+		{"malformed at", "@at tomorrow", "", true},
+		{"malformed cron", "* * *", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expression)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, schedule.String())
+		})
+	}
+
+	at, _ := Parse("@at 2021-01-01T00:00:00Z")
+	require.True(t, at.Next(time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)).IsZero(), "one-off schedule must not run twice")
+}
+
+func TestJobRuns(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	runs := make(chan struct{}, 10)
+	job := s.Add("test", At(time.Now().Add(time.Hour)), func() error {
+		runs <- struct{}{}
+		return errors.New("failed")
+	})
+
+	job.RunNow()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		require.Fail(t, "job hasn't been run by RunNow")
+	}
+
+	require.Eventually(t, func() bool {
+		return job.Status().Runs == 1
+	}, time.Second, 10*time.Millisecond)
+
+	statuses := s.Jobs()
+	require.Len(t, statuses, 1)
+	require.Equal(t, "test", statuses[0].Name)
+	require.Equal(t, "failed", statuses[0].LastError)
+	require.NotNil(t, statuses[0].LastRun)
+
+	job.Stop()
+	require.Empty(t, s.Jobs())
+}
+
+func TestOverrides(t *testing.T) {
+	require.Error(t, Init(&Config{Jobs: map[string]*JobConfig{"test": {Schedule: "wrong"}}}))
+
+	s := New()
+	defer s.Close()
+	s.overrides = map[string]*JobConfig{"test": {Schedule: "@daily", JitterSec: 5}}
+
+	job := s.Add("test", Every(time.Minute), func() error { return nil })
+	status := job.Status()
+	require.Equal(t, "cron @daily", status.Schedule)
+	require.Equal(t, 5, status.JitterSec)
+}
@@ -0,0 +1,205 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/typing"
+	"sort"
+	"sync"
+)
+
+const (
+	ColumnsOverflowUnmapped = "unmapped"
+	ColumnsOverflowFallback = "fallback"
+
+	//UnmappedColumn is a JSON string column with fields which don't fit into the columns limit
+	UnmappedColumn = "_unmapped"
+)
+
+//ColumnsLimitConfig is a per destination configuration of max table columns count
+type ColumnsLimitConfig struct {
+	MaxColumns int    `mapstructure:"max_columns" json:"max_columns,omitempty" yaml:"max_columns,omitempty"`
+	Overflow   string `mapstructure:"overflow" json:"overflow,omitempty" yaml:"overflow,omitempty"`
+}
+
+func (clc *ColumnsLimitConfig) Validate() error {
+	if clc == nil {
+		return nil
+	}
+
+	if clc.MaxColumns <= 0 {
+		return errors.New("columns_limit.max_columns must be positive")
+	}
+
+	switch clc.Overflow {
+	case ColumnsOverflowUnmapped, ColumnsOverflowFallback:
+		return nil
+	default:
+		return fmt.Errorf("Unknown columns_limit.overflow: %s. Available values: [%s, %s]", clc.Overflow, ColumnsOverflowUnmapped, ColumnsOverflowFallback)
+	}
+}
+
+//TableColumnsProvider return destination table column names (empty if table doesn't exist)
+type TableColumnsProvider interface {
+	TableColumns(tableName string) (map[string]bool, error)
+}
+
+//ColumnsGuard limits the number of columns a destination table may accumulate:
+//new fields beyond the limit are folded into UnmappedColumn or object is rejected (and stored into fallback)
+type ColumnsGuard struct {
+	sync.Mutex
+
+	identifier string
+	maxColumns int
+	overflow   string
+
+	provider TableColumnsProvider
+	//columns are known table columns: destination table columns and columns accepted by the guard
+	columns map[string]map[string]bool
+}
+
+//NewColumnsGuard return nil if config is nil (columns count isn't limited)
+func NewColumnsGuard(identifier string, config *ColumnsLimitConfig) (*ColumnsGuard, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.Overflow == "" {
+		config.Overflow = ColumnsOverflowUnmapped
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ColumnsGuard{
+		identifier: identifier,
+		maxColumns: config.MaxColumns,
+		overflow:   config.Overflow,
+		columns:    map[string]map[string]bool{},
+	}, nil
+}
+
+//SetProvider set destination tables columns provider. Without provider only columns accepted by the guard are counted
+func (cg *ColumnsGuard) SetProvider(provider TableColumnsProvider) {
+	if cg == nil {
+		return
+	}
+
+	cg.Lock()
+	cg.provider = provider
+	cg.Unlock()
+}
+
+//Apply check new fields of the flat object against the table columns limit
+//fields beyond the limit are moved into UnmappedColumn JSON (object and batch header are changed)
+//or error is returned with fallback overflow policy
+func (cg *ColumnsGuard) Apply(batchHeader *BatchHeader, object map[string]interface{}) error {
+	if cg == nil || !batchHeader.Exists() {
+		return nil
+	}
+
+	cg.Lock()
+	defer cg.Unlock()
+
+	known, err := cg.getColumns(batchHeader.TableName)
+	if err != nil {
+		return err
+	}
+
+	var newFields []string
+	for name := range batchHeader.Fields {
+		if !known[name] {
+			newFields = append(newFields, name)
+		}
+	}
+	if len(newFields) == 0 {
+		return nil
+	}
+
+	if len(known)+len(newFields) <= cg.maxColumns {
+		for _, name := range newFields {
+			known[name] = true
+		}
+		return nil
+	}
+
+	//keep the limit including unmapped column
+	allowed := cg.maxColumns - len(known)
+	if !known[UnmappedColumn] && cg.overflow == ColumnsOverflowUnmapped {
+		allowed--
+	}
+	if allowed < 0 {
+		allowed = 0
+	}
+
+	sort.Strings(newFields)
+	overflowFields := newFields[allowed:]
+	if cg.overflow == ColumnsOverflowFallback {
+		return fmt.Errorf("Table [%s] columns limit [%d] has been exceeded. New fields: %v", batchHeader.TableName, cg.maxColumns, overflowFields)
+	}
+
+	for _, name := range newFields[:allowed] {
+		known[name] = true
+	}
+	known[UnmappedColumn] = true
+
+	return foldUnmapped(batchHeader, object, overflowFields)
+}
+
+//getColumns return known table columns. Table columns are requested from provider only once
+//must be called under lock
+func (cg *ColumnsGuard) getColumns(tableName string) (map[string]bool, error) {
+	known, ok := cg.columns[tableName]
+	if ok {
+		return known, nil
+	}
+
+	known = map[string]bool{}
+	if cg.provider != nil {
+		tableColumns, err := cg.provider.TableColumns(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting table [%s] columns for columns limit check: %v", tableName, err)
+		}
+		for name := range tableColumns {
+			known[name] = true
+		}
+	}
+	if len(known) > cg.maxColumns {
+		logging.Warnf("[%s] Table [%s] already has [%d] columns which is more than columns limit [%d]", cg.identifier, tableName, len(known), cg.maxColumns)
+	}
+
+	cg.columns[tableName] = known
+	return known, nil
+}
+
+//foldUnmapped move fields into UnmappedColumn JSON string (merge with existing one)
+func foldUnmapped(batchHeader *BatchHeader, object map[string]interface{}, fields []string) error {
+	unmapped := map[string]interface{}{}
+	if existing, ok := object[UnmappedColumn]; ok {
+		if existingStr, ok := existing.(string); ok {
+			if err := json.Unmarshal([]byte(existingStr), &unmapped); err != nil {
+				unmapped = map[string]interface{}{}
+			}
+		}
+	}
+
+	for _, name := range fields {
+		if value, ok := object[name]; ok {
+			unmapped[name] = value
+			delete(object, name)
+		}
+		delete(batchHeader.Fields, name)
+	}
+
+	b, err := json.Marshal(unmapped)
+	if err != nil {
+		return fmt.Errorf("Error marshalling %s column: %v", UnmappedColumn, err)
+	}
+	object[UnmappedColumn] = string(b)
+	batchHeader.Fields[UnmappedColumn] = NewField(typing.STRING)
+
+	return nil
+}
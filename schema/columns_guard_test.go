@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testColumnsProvider map[string]bool
+
+func (tcp testColumnsProvider) TableColumns(tableName string) (map[string]bool, error) {
+	return tcp, nil
+}
+
+func testBatchHeader(object map[string]interface{}) *BatchHeader {
+	header := &BatchHeader{TableName: "events", Fields: Fields{}}
+	for name := range object {
+		header.Fields[name] = NewField(typing.STRING)
+	}
+	return header
+}
+
+func TestColumnsGuardUnmapped(t *testing.T) {
+	guard, err := NewColumnsGuard("test", &ColumnsLimitConfig{MaxColumns: 4})
+	require.NoError(t, err)
+	guard.SetProvider(testColumnsProvider{"a": true, "b": true})
+
+	//fits into the limit
+	object := map[string]interface{}{"a": "1", "c": "2"}
+	require.NoError(t, guard.Apply(testBatchHeader(object), object))
+	require.Equal(t, map[string]interface{}{"a": "1", "c": "2"}, object)
+
+	//d, e don't fit: 3 known columns + _unmapped
+	object = map[string]interface{}{"a": "1", "d": "3", "e": "4"}
+	header := testBatchHeader(object)
+	require.NoError(t, guard.Apply(header, object))
+	require.Equal(t, map[string]interface{}{"a": "1", UnmappedColumn: `{"d":"3","e":"4"}`}, object)
+	require.ElementsMatch(t, []string{"a", UnmappedColumn}, header.Fields.Header())
+
+	//known columns are accepted
+	object = map[string]interface{}{"c": "5", "f": "6"}
+	require.NoError(t, guard.Apply(testBatchHeader(object), object))
+	require.Equal(t, map[string]interface{}{"c": "5", UnmappedColumn: `{"f":"6"}`}, object)
+}
+
+func TestColumnsGuardFallback(t *testing.T) {
+	guard, err := NewColumnsGuard("test", &ColumnsLimitConfig{MaxColumns: 2, Overflow: ColumnsOverflowFallback})
+	require.NoError(t, err)
+
+	object := map[string]interface{}{"a": "1", "b": "2"}
+	require.NoError(t, guard.Apply(testBatchHeader(object), object))
+
+	object = map[string]interface{}{"a": "1", "c": "3"}
+	require.EqualError(t, guard.Apply(testBatchHeader(object), object), "Table [events] columns limit [2] has been exceeded. New fields: [c]")
+
+	_, err = NewColumnsGuard("test", &ColumnsLimitConfig{MaxColumns: 2, Overflow: "drop"})
+	require.Error(t, err)
+}
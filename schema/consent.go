@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"net"
+	"strings"
+)
+
+const (
+	ConsentDrop      = "drop"
+	ConsentAnonymize = "anonymize"
+	ConsentPass      = "pass"
+)
+
+var (
+	ErrNoConsentObject = errors.New("Event doesn't have consent for the destination. This object will be skipped.")
+
+	consentSourceIpPath = jsonutils.NewJsonPath("/source_ip")
+	consentUaPaths      = []*jsonutils.JsonPath{
+		jsonutils.NewJsonPath("/" + events.EventnKey + "/user_agent"),
+		jsonutils.NewJsonPath("/" + events.EventnKey + "/parsed_ua"),
+	}
+)
+
+//ConsentConfig is a per destination configuration of events without consent: events with DNT or GPC signal
+//or with consent object (see events.ConsentKey) where Category isn't granted.
+//If OptIn is true - events without consent object (or without Category in it) are also considered as without consent
+type ConsentConfig struct {
+	Category string `mapstructure:"category" json:"category,omitempty" yaml:"category,omitempty"`
+	Policy   string `mapstructure:"policy" json:"policy,omitempty" yaml:"policy,omitempty"`
+	OptIn    bool   `mapstructure:"opt_in" json:"opt_in,omitempty" yaml:"opt_in,omitempty"`
+}
+
+func (cc *ConsentConfig) Validate() error {
+	if cc == nil {
+		return nil
+	}
+
+	if cc.Category == events.DntKey || cc.Category == events.GpcKey {
+		return fmt.Errorf("consent.category can't be [%s]: it is reserved for DNT/GPC signals", cc.Category)
+	}
+
+	switch cc.Policy {
+	case ConsentDrop, ConsentAnonymize, ConsentPass:
+		return nil
+	default:
+		return fmt.Errorf("Unknown consent.policy: %s. Available policies: [%s, %s, %s]", cc.Policy, ConsentDrop, ConsentAnonymize, ConsentPass)
+	}
+}
+
+//ConsentPolicy checks event consent signals and applies configured policy to events without consent:
+//drop, anonymize (mask source IP and remove user agent) or pass as is
+type ConsentPolicy struct {
+	identifier string
+	category   string
+	policy     string
+	optIn      bool
+}
+
+//NewConsentPolicy return nil if config is nil (DNT/GPC signals and consent objects are ignored)
+func NewConsentPolicy(identifier string, config *ConsentConfig) (*ConsentPolicy, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.Policy == "" {
+		config.Policy = ConsentAnonymize
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ConsentPolicy{
+		identifier: identifier,
+		category:   config.Category,
+		policy:     config.Policy,
+		optIn:      config.OptIn,
+	}, nil
+}
+
+//Apply return ErrNoConsentObject if object without consent must be dropped or anonymize it in place
+func (cp *ConsentPolicy) Apply(object map[string]interface{}) error {
+	if cp == nil || cp.policy == ConsentPass || cp.IsGranted(object) {
+		return nil
+	}
+
+	if cp.policy == ConsentDrop {
+		return ErrNoConsentObject
+	}
+
+	if ip, ok := consentSourceIpPath.Get(object); ok {
+		consentSourceIpPath.Set(object, AnonymizeIp(fmt.Sprint(ip)))
+	}
+	for _, path := range consentUaPaths {
+		path.GetAndRemove(object)
+	}
+
+	return nil
+}
+
+//IsGranted return false if object has DNT/GPC signal or the category isn't granted in the consent object
+func (cp *ConsentPolicy) IsGranted(object map[string]interface{}) bool {
+	consent, ok := object[events.ConsentKey].(map[string]interface{})
+	if !ok {
+		return !cp.optIn
+	}
+
+	if isTrue(consent[events.DntKey]) || isTrue(consent[events.GpcKey]) {
+		return false
+	}
+
+	if cp.category == "" {
+		return true
+	}
+
+	granted, ok := consent[cp.category]
+	if !ok {
+		return !cp.optIn
+	}
+
+	return isTrue(granted)
+}
+
+//AnonymizeIp return IPv4 with zeroed last octet or IPv6 with zeroed last 80 bits. Unparsed values are removed
+func AnonymizeIp(ip string) string {
+	//X-Forwarded-For might contain several addresses: the first one is the client
+	ip = strings.TrimSpace(strings.Split(ip, ",")[0])
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+//isTrue return true if value is true boolean, "true"/"yes"/"1" string or 1 number
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		v = strings.ToLower(strings.TrimSpace(v))
+		return v == "true" || v == "yes" || v == "1"
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(v) == "1"
+	}
+}
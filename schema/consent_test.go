@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConsentPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *ConsentConfig
+		input       map[string]interface{}
+		expected    map[string]interface{}
+		expectedErr error
+	}{
+		{
+			"Nil config",
+			nil,
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"dnt": true}},
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"dnt": true}},
+			nil,
+		},
+		{
+			"Granted category",
+			&ConsentConfig{Category: "analytics", Policy: ConsentDrop},
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"analytics": true}},
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"analytics": true}},
+			nil,
+		},
+		{
+			"No consent object opt-out",
+			&ConsentConfig{Category: "analytics", Policy: ConsentDrop},
+			map[string]interface{}{"source_ip": "10.10.10.10"},
+			map[string]interface{}{"source_ip": "10.10.10.10"},
+			nil,
+		},
+		{
+			"No consent object opt-in",
+			&ConsentConfig{Category: "analytics", Policy: ConsentDrop, OptIn: true},
+			map[string]interface{}{"source_ip": "10.10.10.10"},
+			nil,
+			ErrNoConsentObject,
+		},
+		{
+			"Not granted category drop",
+			&ConsentConfig{Category: "marketing", Policy: ConsentDrop},
+			map[string]interface{}{"consent": map[string]interface{}{"analytics": true, "marketing": "false"}},
+			nil,
+			ErrNoConsentObject,
+		},
+		{
+			"DNT anonymize",
+			&ConsentConfig{Category: "analytics"},
+			map[string]interface{}{"source_ip": "10.10.10.10", "eventn_ctx": map[string]interface{}{"event_id": "1", "user_agent": "Mozilla/5.0"},
+				"consent": map[string]interface{}{"analytics": true, "dnt": true}},
+			map[string]interface{}{"source_ip": "10.10.10.0", "eventn_ctx": map[string]interface{}{"event_id": "1"},
+				"consent": map[string]interface{}{"analytics": true, "dnt": true}},
+			nil,
+		},
+		{
+			"GPC pass",
+			&ConsentConfig{Policy: ConsentPass},
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"gpc": true}},
+			map[string]interface{}{"source_ip": "10.10.10.10", "consent": map[string]interface{}{"gpc": true}},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewConsentPolicy("test", tt.config)
+			require.NoError(t, err)
+
+			err = policy.Apply(tt.input)
+			if tt.expectedErr != nil {
+				require.Equal(t, tt.expectedErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, tt.input)
+		})
+	}
+}
+
+func TestAnonymizeIp(t *testing.T) {
+	require.Equal(t, "192.168.1.0", AnonymizeIp("192.168.1.42"))
+	require.Equal(t, "192.168.1.0", AnonymizeIp("192.168.1.42, 10.0.0.1"))
+	require.Equal(t, "2001:db8:85a3::", AnonymizeIp("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	require.Equal(t, "", AnonymizeIp("unknown"))
+}
+
+func TestConsentConfigValidate(t *testing.T) {
+	require.Error(t, (&ConsentConfig{Policy: "forget"}).Validate())
+	require.Error(t, (&ConsentConfig{Category: "dnt", Policy: ConsentDrop}).Validate())
+	require.NoError(t, (&ConsentConfig{Category: "analytics", Policy: ConsentAnonymize}).Validate())
+}
@@ -372,3 +372,16 @@ func TestNewStyleStrictMap(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnDescriptions(t *testing.T) {
+	require.Equal(t, map[string]string{}, ColumnDescriptions(nil))
+
+	mappings := &Mapping{Fields: []MappingField{
+		{Src: "/user/email", Dst: "/user/email", Action: MOVE, Description: "User email"},
+		{Dst: "/amount", Action: CAST, Type: "numeric(38,2)", Description: "Purchase amount"},
+		{Src: "/ip", Action: REMOVE},
+	}}
+	require.Equal(t, map[string]string{"user_email": "User email", "amount": "Purchase amount"}, ColumnDescriptions(mappings))
+
+	require.Error(t, (&MappingField{Src: "/ip", Action: REMOVE, Description: "IP"}).Validate())
+}
@@ -2,22 +2,89 @@ package schema
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
+const defaultSeparator = "_"
+
+var separatorRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+//FlatteningConfig is a per destination flattening strategy (data_layout.flattening):
+//Separator joins nested keys (default '_'), objects deeper than MaxDepth and JsonFields subtrees (JSON paths)
+//are stored as JSON strings, Rename maps JSON path to column name: "/properties/very/long/path -> short_name"
+type FlatteningConfig struct {
+	Separator  string   `mapstructure:"separator" json:"separator,omitempty" yaml:"separator,omitempty"`
+	MaxDepth   int      `mapstructure:"max_depth" json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	JsonFields []string `mapstructure:"json_fields" json:"json_fields,omitempty" yaml:"json_fields,omitempty"`
+	Rename     []string `mapstructure:"rename" json:"rename,omitempty" yaml:"rename,omitempty"`
+}
+
 type Flattener struct {
 	omitNilValues   bool
 	toLowerCaseKeys bool
+	separator       string
+	maxDepth        int
+	//JSON path -> true
+	jsonPaths map[string]bool
+	//JSON path -> column name
+	renames map[string]string
 
 	specialCharsReplacer *strings.Replacer
 }
 
+//NewConfiguredFlattener return Flattener with configured strategy or default one if config is nil
+//system fields (event id and utc time) keep default column names if separator is changed
+func NewConfiguredFlattener(config *FlatteningConfig) (*Flattener, error) {
+	f := NewFlattener()
+	if config == nil {
+		return f, nil
+	}
+
+	if config.Separator != "" {
+		if !separatorRegex.MatchString(config.Separator) {
+			return nil, fmt.Errorf("Flattening separator [%s] must contain only latin letters, digits and '_'", config.Separator)
+		}
+		f.separator = config.Separator
+		f.renames["/eventn_ctx/event_id"] = "eventn_ctx_event_id"
+		f.renames["/eventn_ctx/utc_time"] = "eventn_ctx_utc_time"
+	}
+
+	if config.MaxDepth < 0 || config.MaxDepth == 1 {
+		return nil, errors.New("Flattening max_depth must be 0 (unlimited) or >= 2 (eventn_ctx system fields are nested)")
+	}
+	f.maxDepth = config.MaxDepth
+
+	for _, jsonField := range config.JsonFields {
+		f.jsonPaths[normalizePath(jsonField)] = true
+	}
+
+	for _, rename := range config.Rename {
+		parts := strings.Split(rename, "->")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed flattening rename [%s]. Use format: /field1/subfield1 -> column_name", rename)
+		}
+		path := normalizePath(strings.TrimSpace(parts[0]))
+		column := strings.TrimSpace(parts[1])
+		if path == "/" || column == "" {
+			return nil, fmt.Errorf("Malformed flattening rename [%s]. JSON path and column name can't be empty", rename)
+		}
+		f.renames[path] = f.Reformat(column)
+	}
+
+	return f, nil
+}
+
 func NewFlattener() *Flattener {
 	return &Flattener{
 		omitNilValues:   true,
 		toLowerCaseKeys: true,
+		separator:       defaultSeparator,
+		jsonPaths:       map[string]bool{},
+		renames:         map[string]string{},
 		specialCharsReplacer: strings.NewReplacer(
 			"(", "_",
 			")", "_",
@@ -57,7 +124,7 @@ func (f *Flattener) Reformat(key string) string {
 func (f *Flattener) FlattenObject(json map[string]interface{}) (map[string]interface{}, error) {
 	flattenMap := make(map[string]interface{})
 
-	err := f.flatten("", json, flattenMap)
+	err := f.flattenMap("", "", 0, json, flattenMap)
 	if err != nil {
 		return nil, err
 	}
@@ -66,10 +133,29 @@ func (f *Flattener) FlattenObject(json map[string]interface{}) (map[string]inter
 
 }
 
+//flattenMap flatten every object field with key = parent key + separator + reformatted field name
+//or renamed column name if field JSON path is configured in renames
+func (f *Flattener) flattenMap(path, key string, depth int, object map[string]interface{}, destination map[string]interface{}) error {
+	for k, v := range object {
+		fieldPath := path + "/" + k
+		fieldKey, renamed := f.renames[fieldPath]
+		if !renamed {
+			fieldKey = f.Reformat(k)
+			if key != "" {
+				fieldKey = key + f.separator + fieldKey
+			}
+		}
+		if err := f.flatten(fieldPath, fieldKey, depth+1, v, destination); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 //recursive function for flatten key (if value is inner object -> recursion call)
-//Reformat key
-func (f *Flattener) flatten(key string, value interface{}, destination map[string]interface{}) error {
-	key = f.Reformat(key)
+//objects from json paths or deeper than max depth are serialized as JSON
+func (f *Flattener) flatten(path, key string, depth int, value interface{}, destination map[string]interface{}) error {
 	t := reflect.ValueOf(value)
 	switch t.Kind() {
 	case reflect.Slice:
@@ -79,16 +165,16 @@ func (f *Flattener) flatten(key string, value interface{}, destination map[strin
 		}
 		destination[key] = string(b)
 	case reflect.Map:
-		unboxed := value.(map[string]interface{})
-		for k, v := range unboxed {
-			newKey := k
-			if key != "" {
-				newKey = key + "_" + newKey
-			}
-			if err := f.flatten(newKey, v, destination); err != nil {
-				return err
+		if f.jsonPaths[path] || (f.maxDepth > 0 && depth >= f.maxDepth) {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("Error marshaling object with key %s: %v", key, err)
 			}
+			destination[key] = string(b)
+			return nil
 		}
+
+		return f.flattenMap(path, key, depth, value.(map[string]interface{}), destination)
 	case reflect.Bool:
 		boolValue, _ := value.(bool)
 		destination[key] = boolValue
@@ -107,3 +193,8 @@ func (f *Flattener) flatten(key string, value interface{}, destination map[strin
 
 	return nil
 }
+
+//normalizePath return JSON path with leading and without trailing '/'
+func normalizePath(path string) string {
+	return "/" + strings.Trim(strings.TrimSpace(path), "/")
+}
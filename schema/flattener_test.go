@@ -68,3 +68,63 @@ func TestFlattenObject(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguredFlattener(t *testing.T) {
+	input := map[string]interface{}{
+		"eventn_ctx": map[string]interface{}{"event_id": "1", "utc_time": "2020-01-01T00:00:00Z"},
+		"key1": map[string]interface{}{
+			"sub_key1": map[string]interface{}{"sub_sub_key1": 1},
+		},
+		"properties": map[string]interface{}{
+			"very": map[string]interface{}{"long": map[string]interface{}{"path": "value"}},
+			"raw":  map[string]interface{}{"a": 1},
+		},
+	}
+	tests := []struct {
+		name         string
+		config       *FlatteningConfig
+		expectedJson map[string]interface{}
+	}{
+		{
+			"Custom separator",
+			&FlatteningConfig{Separator: "__"},
+			map[string]interface{}{"eventn_ctx_event_id": "1", "eventn_ctx_utc_time": "2020-01-01T00:00:00Z", "key1__sub_key1__sub_sub_key1": 1,
+				"properties__very__long__path": "value", "properties__raw__a": 1},
+		},
+		{
+			"Max depth",
+			&FlatteningConfig{MaxDepth: 2},
+			map[string]interface{}{"eventn_ctx_event_id": "1", "eventn_ctx_utc_time": "2020-01-01T00:00:00Z", "key1_sub_key1": `{"sub_sub_key1":1}`,
+				"properties_very": `{"long":{"path":"value"}}`, "properties_raw": `{"a":1}`},
+		},
+		{
+			"JSON fields and renames",
+			&FlatteningConfig{JsonFields: []string{"/properties/raw/"}, Rename: []string{"/properties/very/long/path -> Short.Name"}},
+			map[string]interface{}{"eventn_ctx_event_id": "1", "eventn_ctx_utc_time": "2020-01-01T00:00:00Z", "key1_sub_key1_sub_sub_key1": 1,
+				"short_name": "value", "properties_raw": `{"a":1}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flattener, err := NewConfiguredFlattener(tt.config)
+			require.NoError(t, err)
+
+			actualFlattenJson, err := flattener.FlattenObject(input)
+			require.NoError(t, err)
+			test.ObjectsEqual(t, tt.expectedJson, actualFlattenJson, "Wrong flattened json")
+		})
+	}
+}
+
+func TestConfiguredFlattenerErrors(t *testing.T) {
+	for _, config := range []*FlatteningConfig{
+		{Separator: "."},
+		{MaxDepth: 1},
+		{MaxDepth: -1},
+		{Rename: []string{"/key1 column"}},
+		{Rename: []string{"/ -> column"}},
+	} {
+		_, err := NewConfiguredFlattener(config)
+		require.Error(t, err, config)
+	}
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+)
+
+const (
+	IpTruncate = "truncate"
+	IpDrop     = "drop"
+
+	defaultIpField = "/source_ip"
+)
+
+//AnonymizeIpConfig is a per destination configuration of IP addresses anonymization.
+//IP addresses are truncated (IPv4 to /24, IPv6 to /48) or dropped after lookup enrichment (geo resolution)
+//Fields are JSON paths of IP addresses. Default: /source_ip and sources of ip_lookup enrichment rules
+type AnonymizeIpConfig struct {
+	Mode   string   `mapstructure:"mode" json:"mode,omitempty" yaml:"mode,omitempty"`
+	Fields []string `mapstructure:"fields" json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+func (iac *AnonymizeIpConfig) Validate() error {
+	if iac == nil {
+		return nil
+	}
+
+	switch iac.Mode {
+	case IpTruncate, IpDrop:
+	default:
+		return fmt.Errorf("Unknown ip_anonymization.mode: %s. Available modes: [%s, %s]", iac.Mode, IpTruncate, IpDrop)
+	}
+
+	for _, field := range iac.Fields {
+		if jsonutils.NewJsonPath(field).IsEmpty() {
+			return fmt.Errorf("ip_anonymization.fields must be valid paths like: /node1/node2. Got: %s", field)
+		}
+	}
+
+	return nil
+}
+
+//IpAnonymizer truncates or drops IP addresses of processed objects
+type IpAnonymizer struct {
+	identifier string
+	mode       string
+	paths      []*jsonutils.JsonPath
+}
+
+//NewIpAnonymizer return nil if config is nil (IP addresses are stored as is)
+//ipLookupSources are used as IP fields if config fields aren't set
+func NewIpAnonymizer(identifier string, config *AnonymizeIpConfig, ipLookupSources []string) (*IpAnonymizer, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.Mode == "" {
+		config.Mode = IpTruncate
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	fields := config.Fields
+	if len(fields) == 0 {
+		fields = append([]string{defaultIpField}, ipLookupSources...)
+	}
+
+	var paths []*jsonutils.JsonPath
+	unique := map[string]bool{}
+	for _, field := range fields {
+		path := jsonutils.NewJsonPath(field)
+		if unique[path.String()] {
+			continue
+		}
+		unique[path.String()] = true
+		paths = append(paths, path)
+	}
+
+	return &IpAnonymizer{identifier: identifier, mode: config.Mode, paths: paths}, nil
+}
+
+//Apply truncate or remove IP addresses in place. Values which aren't IP addresses are removed in truncate mode
+func (ia *IpAnonymizer) Apply(object map[string]interface{}) {
+	if ia == nil {
+		return
+	}
+
+	for _, path := range ia.paths {
+		ip, ok := path.Get(object)
+		if !ok {
+			continue
+		}
+
+		if ia.mode == IpTruncate {
+			if truncated := AnonymizeIp(fmt.Sprint(ip)); truncated != "" {
+				path.Set(object, truncated)
+				continue
+			}
+		}
+
+		path.GetAndRemove(object)
+	}
+}
+
+//Fields return JSON paths of anonymized IP addresses
+func (ia *IpAnonymizer) Fields() []string {
+	var fields []string
+	for _, path := range ia.paths {
+		fields = append(fields, path.String())
+	}
+	return fields
+}
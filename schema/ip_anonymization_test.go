@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIpAnonymizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *AnonymizeIpConfig
+		sources  []string
+		input    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			"Nil config",
+			nil,
+			nil,
+			map[string]interface{}{"source_ip": "10.10.10.10"},
+			map[string]interface{}{"source_ip": "10.10.10.10"},
+		},
+		{
+			"Truncate IPv4 and IPv6 from ip_lookup sources",
+			&AnonymizeIpConfig{},
+			[]string{"/eventn_ctx/ip", "/source_ip"},
+			map[string]interface{}{"source_ip": "10.10.10.10", "eventn_ctx": map[string]interface{}{"ip": "2001:db8:85a3:8d3:1319:8a2e:370:7348",
+				"location": map[string]interface{}{"country": "US"}}},
+			map[string]interface{}{"source_ip": "10.10.10.0", "eventn_ctx": map[string]interface{}{"ip": "2001:db8:85a3::",
+				"location": map[string]interface{}{"country": "US"}}},
+		},
+		{
+			"Truncate removes invalid IP",
+			&AnonymizeIpConfig{Mode: IpTruncate},
+			nil,
+			map[string]interface{}{"source_ip": "unknown", "event_type": "pageview"},
+			map[string]interface{}{"event_type": "pageview"},
+		},
+		{
+			"Drop configured fields",
+			&AnonymizeIpConfig{Mode: IpDrop, Fields: []string{"/user/ip"}},
+			[]string{"/eventn_ctx/ip"},
+			map[string]interface{}{"source_ip": "10.10.10.10", "user": map[string]interface{}{"ip": "10.10.10.11", "id": "1"}},
+			map[string]interface{}{"source_ip": "10.10.10.10", "user": map[string]interface{}{"id": "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anonymizer, err := NewIpAnonymizer("test", tt.config, tt.sources)
+			require.NoError(t, err)
+
+			anonymizer.Apply(tt.input)
+			require.Equal(t, tt.expected, tt.input)
+		})
+	}
+
+	_, err := NewIpAnonymizer("test", &AnonymizeIpConfig{Mode: "hash"}, nil)
+	require.Error(t, err)
+	_, err = NewIpAnonymizer("test", &AnonymizeIpConfig{Fields: []string{""}}, nil)
+	require.Error(t, err)
+}
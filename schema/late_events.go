@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/timestamp"
+	"time"
+)
+
+const (
+	LateEventsLoad      = "load"
+	LateEventsDrop      = "drop"
+	LateEventsLateTable = "late_table"
+
+	defaultLateTableSuffix = "_late"
+)
+
+var ErrLateObject = errors.New("Event timestamp is older than late events watermark. This object will be skipped.")
+
+//LateEventsConfig is a per destination configuration of events which are older than max_age_days
+type LateEventsConfig struct {
+	MaxAgeDays int    `mapstructure:"max_age_days" json:"max_age_days,omitempty" yaml:"max_age_days,omitempty"`
+	Policy     string `mapstructure:"policy" json:"policy,omitempty" yaml:"policy,omitempty"`
+	TableName  string `mapstructure:"table_name" json:"table_name,omitempty" yaml:"table_name,omitempty"`
+}
+
+func (lec *LateEventsConfig) Validate() error {
+	if lec == nil {
+		return nil
+	}
+
+	if lec.MaxAgeDays <= 0 {
+		return errors.New("late_events.max_age_days must be positive")
+	}
+
+	switch lec.Policy {
+	case LateEventsLoad, LateEventsDrop, LateEventsLateTable:
+		return nil
+	default:
+		return fmt.Errorf("Unknown late_events.policy: %s. Available policies: [%s, %s, %s]", lec.Policy, LateEventsLoad, LateEventsDrop, LateEventsLateTable)
+	}
+}
+
+//LateEventsPolicy checks event _timestamp against watermark (now - max age) and
+//applies configured policy: load normally, drop or route to a separate table
+type LateEventsPolicy struct {
+	identifier string
+	maxAge     time.Duration
+	policy     string
+	tableName  string
+}
+
+//NewLateEventsPolicy return nil if config is nil (all events are loaded normally)
+func NewLateEventsPolicy(identifier string, config *LateEventsConfig) (*LateEventsPolicy, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	//default policy is applied to a copy: the caller config isn't changed
+	configCopy := *config
+	if configCopy.Policy == "" {
+		configCopy.Policy = LateEventsLateTable
+	}
+
+	if err := configCopy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &LateEventsPolicy{
+		identifier: identifier,
+		maxAge:     time.Duration(configCopy.MaxAgeDays) * 24 * time.Hour,
+		policy:     configCopy.Policy,
+		tableName:  configCopy.TableName,
+	}, nil
+}
+
+//Policy return configured (or default) late events policy
+func (lep *LateEventsPolicy) Policy() string {
+	return lep.policy
+}
+
+//Apply return table name for the object according to policy or ErrLateObject if object must be dropped
+//object _timestamp must be already parsed into time.Time
+func (lep *LateEventsPolicy) Apply(tableName string, object map[string]interface{}) (string, error) {
+	if lep == nil {
+		return tableName, nil
+	}
+
+	t, ok := object[timestamp.Key].(time.Time)
+	if !ok || !t.Before(time.Now().UTC().Add(-lep.maxAge)) {
+		return tableName, nil
+	}
+
+	metrics.LateEvent(lep.identifier, lep.policy)
+
+	switch lep.policy {
+	case LateEventsDrop:
+		return "", ErrLateObject
+	case LateEventsLateTable:
+		if lep.tableName != "" {
+			return lep.tableName, nil
+		}
+		return tableName + defaultLateTableSuffix, nil
+	default:
+		return tableName, nil
+	}
+}
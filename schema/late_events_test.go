@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLateEventsPolicy(t *testing.T) {
+	oldTime := time.Now().UTC().AddDate(0, 0, -10)
+	freshTime := time.Now().UTC().Add(-time.Hour)
+	tests := []struct {
+		name              string
+		config            *LateEventsConfig
+		eventTime         time.Time
+		expectedTableName string
+		expectedErr       error
+	}{
+		{
+			"Nil config",
+			nil,
+			oldTime,
+			"events",
+			nil,
+		},
+		{
+			"Fresh event",
+			&LateEventsConfig{MaxAgeDays: 3, Policy: LateEventsDrop},
+			freshTime,
+			"events",
+			nil,
+		},
+		{
+			"Late event load",
+			&LateEventsConfig{MaxAgeDays: 3, Policy: LateEventsLoad},
+			oldTime,
+			"events",
+			nil,
+		},
+		{
+			"Late event drop",
+			&LateEventsConfig{MaxAgeDays: 3, Policy: LateEventsDrop},
+			oldTime,
+			"",
+			ErrLateObject,
+		},
+		{
+			"Late event default late table",
+			&LateEventsConfig{MaxAgeDays: 3},
+			oldTime,
+			"events_late",
+			nil,
+		},
+		{
+			"Late event configured late table",
+			&LateEventsConfig{MaxAgeDays: 3, Policy: LateEventsLateTable, TableName: "old_events"},
+			oldTime,
+			"old_events",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewLateEventsPolicy("test", tt.config)
+			require.NoError(t, err)
+
+			tableName, err := policy.Apply("events", map[string]interface{}{timestamp.Key: tt.eventTime})
+			require.Equal(t, tt.expectedErr, err)
+			require.Equal(t, tt.expectedTableName, tableName)
+		})
+	}
+}
+
+func TestLateEventsPolicyDefault(t *testing.T) {
+	config := &LateEventsConfig{MaxAgeDays: 3}
+	policy, err := NewLateEventsPolicy("test", config)
+	require.NoError(t, err)
+	require.Equal(t, LateEventsLateTable, policy.Policy())
+	//config isn't changed
+	require.Equal(t, "", config.Policy)
+}
+
+func TestLateEventsConfigValidate(t *testing.T) {
+	_, err := NewLateEventsPolicy("test", &LateEventsConfig{MaxAgeDays: 0, Policy: LateEventsDrop})
+	require.Error(t, err)
+
+	_, err = NewLateEventsPolicy("test", &LateEventsConfig{MaxAgeDays: 1, Policy: "unknown"})
+	require.Error(t, err)
+}
@@ -8,6 +8,8 @@ import (
 type MappingStep struct {
 	fieldMapper Mapper
 	flattener   *Flattener
+	//schema-on-read raw JSON column (mappings and flattening aren't applied if it is set)
+	rawColumn string
 }
 
 func NewMappingStep(fieldMapper Mapper, flattener *Flattener) *MappingStep {
@@ -20,19 +22,28 @@ func NewMappingStep(fieldMapper Mapper, flattener *Flattener) *MappingStep {
 //1. apply mappings
 //2. flatten object
 //3. apply default typecasts
+//or serialize object into raw column in schema-on-read mode
 func (ms *MappingStep) Execute(tableName string, object map[string]interface{}) (*BatchHeader, map[string]interface{}, error) {
-	batchHeader := &BatchHeader{TableName: tableName, Fields: Fields{}}
-
-	mappedObject, err := ms.fieldMapper.Map(object)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error mapping object: %v", err)
-	}
+	var flatObject map[string]interface{}
+	var err error
+	if ms.rawColumn != "" {
+		flatObject, err = rawObject(ms.rawColumn, object)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		mappedObject, err := ms.fieldMapper.Map(object)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error mapping object: %v", err)
+		}
 
-	flatObject, err := ms.flattener.FlattenObject(mappedObject)
-	if err != nil {
-		return nil, nil, err
+		flatObject, err = ms.flattener.FlattenObject(mappedObject)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
+	batchHeader := &BatchHeader{TableName: tableName, Fields: Fields{}}
 	//apply default typecast and define column types
 	for k, v := range flatObject {
 		//reformat from json.Number into int64 or float64 and put back
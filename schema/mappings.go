@@ -1,6 +1,9 @@
 package schema
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+)
 
 type FieldMappingType string
 
@@ -34,6 +37,8 @@ type MappingField struct {
 	Action string      `mapstructure:"action" json:"action,omitempty" yaml:"action,omitempty"`
 	Type   string      `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
 	Value  interface{} `mapstructure:"value" json:"value,omitempty" yaml:"value,omitempty"`
+	//Description is set as the destination column comment (description) in warehouses which support it
+	Description string `mapstructure:"description" json:"description,omitempty" yaml:"description,omitempty"`
 }
 
 func (mf *MappingField) Validate() error {
@@ -56,6 +61,10 @@ func (mf *MappingField) Validate() error {
 		return fmt.Errorf("dst is required field")
 	}
 
+	if mf.Description != "" && mf.Action == REMOVE {
+		return fmt.Errorf("description can't be set in mappings with action: [%s]", REMOVE)
+	}
+
 	return nil
 }
 
@@ -77,3 +86,19 @@ func (mf *MappingField) String() string {
 	}
 	return fmt.Sprintf("%s --[%s]--> %s %s", src, mf.Action, typeCast, mf.Dst)
 }
+
+//ColumnDescriptions return destination column name -> description from new style mappings
+func ColumnDescriptions(mappings *Mapping) map[string]string {
+	descriptions := map[string]string{}
+	if mappings == nil {
+		return descriptions
+	}
+
+	for _, mapping := range mappings.Fields {
+		if mapping.Description != "" && mapping.Dst != "" {
+			descriptions[jsonutils.NewJsonPath(mapping.Dst).FieldName()] = mapping.Description
+		}
+	}
+
+	return descriptions
+}
@@ -5,10 +5,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/dedup"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/maputils"
+	"github.com/jitsucom/eventnative/routing"
 	"io"
 )
 
@@ -19,11 +22,41 @@ type Processor struct {
 	tableNameExtractor   *TableNameExtractor
 	lookupEnrichmentStep *enrichment.LookupEnrichmentStep
 	mappingStep          *MappingStep
+	timestampsPolicy     *TimestampsPolicy
+	lateEventsPolicy     *LateEventsPolicy
+	sharding             *Sharding
+	consentPolicy        *ConsentPolicy
+	ipAnonymizer         *IpAnonymizer
+	routingRules         *routing.Rules
+	dedupWindow          *dedup.Window
+	columnsGuard         *ColumnsGuard
 	breakOnError         bool
 }
 
-func NewProcessor(identifier, tableNameFuncExpression string, fieldMapper Mapper, enrichmentRules []enrichment.Rule, breakOnError bool) (*Processor, error) {
-	flattener := NewFlattener()
+//ProcessorOptions are optional processing steps and policies of the destination. Nil fields are disabled steps
+type ProcessorOptions struct {
+	//Flattener might be nil (default flattening strategy is used)
+	Flattener        *Flattener
+	TimestampsPolicy *TimestampsPolicy
+	LateEventsPolicy *LateEventsPolicy
+	Sharding         *Sharding
+	ConsentPolicy    *ConsentPolicy
+	IpAnonymizer     *IpAnonymizer
+	RoutingRules     *routing.Rules
+	DedupWindow      *dedup.Window
+	ColumnsGuard     *ColumnsGuard
+}
+
+//NewProcessor return configured Processor. options might be nil (all optional steps are disabled)
+func NewProcessor(identifier, tableNameFuncExpression string, fieldMapper Mapper, enrichmentRules []enrichment.Rule,
+	breakOnError bool, options *ProcessorOptions) (*Processor, error) {
+	if options == nil {
+		options = &ProcessorOptions{}
+	}
+	flattener := options.Flattener
+	if flattener == nil {
+		flattener = NewFlattener()
+	}
 	mappingStep := NewMappingStep(fieldMapper, flattener)
 	tableNameExtractor, err := NewTableNameExtractor(tableNameFuncExpression, flattener)
 	if err != nil {
@@ -35,6 +68,14 @@ func NewProcessor(identifier, tableNameFuncExpression string, fieldMapper Mapper
 		tableNameExtractor:   tableNameExtractor,
 		lookupEnrichmentStep: enrichment.NewLookupEnrichmentStep(enrichmentRules),
 		mappingStep:          mappingStep,
+		timestampsPolicy:     options.TimestampsPolicy,
+		lateEventsPolicy:     options.LateEventsPolicy,
+		sharding:             options.Sharding,
+		consentPolicy:        options.ConsentPolicy,
+		ipAnonymizer:         options.IpAnonymizer,
+		routingRules:         options.RoutingRules,
+		dedupWindow:          options.DedupWindow,
+		columnsGuard:         options.ColumnsGuard,
 		breakOnError:         breakOnError,
 	}, nil
 }
@@ -51,6 +92,7 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, alreadyU
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*ProcessedFile, []*events.FailedEvent, error) {
 	var failedFacts []*events.FailedEvent
 	filePerTable := map[string]*ProcessedFile{}
+	skipped := 0
 
 	input := bytes.NewBuffer(payload)
 	reader := bufio.NewReaderSize(input, 64*1024)
@@ -62,11 +104,25 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, alreadyU
 			return nil, nil, err
 		}
 
+		//batch files are shared by all token destinations: skip objects which aren't routed to this destination
+		//and objects which have been already seen by this destination in another file within deduplication window
+		if !p.routingRules.Match(object) || p.dedupWindow.IsDuplicate(events.ExtractEventId(object), fileName) {
+			line, readErr = reader.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				return nil, nil, fmt.Errorf("Error reading line in [%s] file: %v", fileName, readErr)
+			}
+			continue
+		}
+
 		batchHeader, processedObject, err := p.processObject(object, alreadyUploadedTables)
 		if err != nil {
 			//handle skip object functionality
-			if err == ErrSkipObject {
+			if err == ErrNoConsentObject {
+				logging.Debugf("[%s] Event [%s]: %v", p.identifier, events.ExtractEventId(object), err)
+				skipped++
+			} else if err == ErrSkipObject || err == ErrLateObject {
 				logging.Warnf("[%s] Event [%s]: %v", p.identifier, events.ExtractEventId(object), err)
+				skipped++
 			} else if p.breakOnError {
 				return nil, nil, err
 			} else {
@@ -98,6 +154,15 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, alreadyU
 		}
 	}
 
+	if skipped > 0 {
+		//incoming log files names contain the token id
+		var tokenId string
+		if parsed := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName); len(parsed) == 2 {
+			tokenId = parsed[1]
+		}
+		counters.SkipEvents(p.identifier, tokenId, skipped)
+	}
+
 	return filePerTable, failedFacts, nil
 }
 
@@ -110,6 +175,9 @@ func (p *Processor) ProcessObjects(objects []map[string]interface{}) (map[string
 	for _, object := range objects {
 		batchHeader, processedObject, err := p.processObject(object, map[string]bool{})
 		if err != nil {
+			if err == ErrLateObject || err == ErrNoConsentObject {
+				continue
+			}
 			return nil, err
 		}
 
@@ -132,10 +200,17 @@ func (p *Processor) ProcessObjects(objects []map[string]interface{}) (map[string
 
 //Check if table name in skipTables => return empty Table for skipping or
 //Return table representation of object and flatten, mapped object
-//1. extract table name
-//2. execute enrichment.LookupEnrichmentStep and MappingStep
-//or ErrSkipObject/another error
+//1. apply timestamps policy (set standard timestamps and partition _timestamp)
+//2. extract table name
+//3. apply late events policy and tables sharding
+//4. apply consent policy (before lookup enrichment: location is resolved from anonymized IP)
+//5. execute enrichment.LookupEnrichmentStep
+//6. anonymize IP addresses (after geo resolution) and execute MappingStep
+//7. apply columns limit
+//or ErrSkipObject/ErrLateObject/ErrNoConsentObject/another error
 func (p *Processor) processObject(object map[string]interface{}, alreadyUploadedTables map[string]bool) (*BatchHeader, map[string]interface{}, error) {
+	p.timestampsPolicy.Apply(object)
+
 	tableName, err := p.tableNameExtractor.Extract(object)
 	if err != nil {
 		return nil, nil, err
@@ -144,6 +219,13 @@ func (p *Processor) processObject(object map[string]interface{}, alreadyUploaded
 		return nil, nil, ErrSkipObject
 	}
 
+	tableName, err = p.lateEventsPolicy.Apply(tableName, object)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tableName = p.sharding.Apply(tableName, object)
+
 	//object has been already processed (storage:table pair might be already processed)
 	_, ok := alreadyUploadedTables[tableName]
 	if ok {
@@ -152,7 +234,36 @@ func (p *Processor) processObject(object map[string]interface{}, alreadyUploaded
 
 	objectCopy := maputils.CopyMap(object)
 
+	if err := p.consentPolicy.Apply(objectCopy); err != nil {
+		return nil, nil, err
+	}
+
 	p.lookupEnrichmentStep.Execute(objectCopy)
+	p.ipAnonymizer.Apply(objectCopy)
+
+	batchHeader, flatObject, err := p.mappingStep.Execute(tableName, objectCopy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.columnsGuard.Apply(batchHeader, flatObject); err != nil {
+		return nil, nil, err
+	}
+
+	return batchHeader, flatObject, nil
+}
+
+//SetRawColumn switch processor to schema-on-read mode: events are stored as JSON in the raw column
+func (p *Processor) SetRawColumn(rawColumn string) {
+	p.mappingStep.rawColumn = rawColumn
+}
+
+//Sharding return tables sharding or nil if it isn't configured
+func (p *Processor) Sharding() *Sharding {
+	return p.sharding
+}
 
-	return p.mappingStep.Execute(tableName, objectCopy)
+//ColumnsGuard return columns limit guard or nil if it isn't configured
+func (p *Processor) ColumnsGuard() *ColumnsGuard {
+	return p.columnsGuard
 }
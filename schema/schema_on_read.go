@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/timestamp"
+	"regexp"
+)
+
+const (
+	DefaultRawColumn  = "_raw"
+	DefaultViewSuffix = "_view"
+)
+
+var columnNameRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+//SchemaOnReadConfig is a data_layout.schema_on_read configuration: every event is stored as a single raw JSON column
+//(plus event id and _timestamp columns). If Views is true - typed views (or materialized views) are built from mappings
+type SchemaOnReadConfig struct {
+	Enabled      bool   `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Column       string `mapstructure:"column" json:"column,omitempty" yaml:"column,omitempty"`
+	Views        bool   `mapstructure:"views" json:"views,omitempty" yaml:"views,omitempty"`
+	Materialized bool   `mapstructure:"materialized" json:"materialized,omitempty" yaml:"materialized,omitempty"`
+	ViewSuffix   string `mapstructure:"view_suffix" json:"view_suffix,omitempty" yaml:"view_suffix,omitempty"`
+	RefreshMin   int    `mapstructure:"refresh_min" json:"refresh_min,omitempty" yaml:"refresh_min,omitempty"`
+}
+
+//IsEnabled is nil-safe
+func (sor *SchemaOnReadConfig) IsEnabled() bool {
+	return sor != nil && sor.Enabled
+}
+
+//RawColumn return configured raw JSON column name or default one
+func (sor *SchemaOnReadConfig) RawColumn() string {
+	if sor.Column == "" {
+		return DefaultRawColumn
+	}
+
+	return sor.Column
+}
+
+//GetViewSuffix return configured view name suffix or default one
+func (sor *SchemaOnReadConfig) GetViewSuffix() string {
+	if sor.ViewSuffix == "" {
+		return DefaultViewSuffix
+	}
+
+	return sor.ViewSuffix
+}
+
+func (sor *SchemaOnReadConfig) Validate() error {
+	if !sor.IsEnabled() {
+		return nil
+	}
+
+	if !columnNameRegex.MatchString(sor.RawColumn()) {
+		return fmt.Errorf("schema_on_read column [%s] must contain only lower case latin letters, digits and '_'", sor.RawColumn())
+	}
+	if !columnNameRegex.MatchString(sor.GetViewSuffix()) {
+		return fmt.Errorf("schema_on_read view_suffix [%s] must contain only lower case latin letters, digits and '_'", sor.GetViewSuffix())
+	}
+	if sor.Materialized && !sor.Views {
+		return errors.New("schema_on_read materialized requires views: true")
+	}
+	if sor.RefreshMin < 0 {
+		return errors.New("schema_on_read refresh_min can't be negative")
+	}
+
+	return nil
+}
+
+//rawObject return object with event id, _timestamp and whole object serialized as JSON into raw column
+func rawObject(rawColumn string, object map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling raw object: %v", err)
+	}
+
+	rawObject := map[string]interface{}{rawColumn: string(b)}
+	if eventId := events.ExtractEventId(object); eventId != "" {
+		rawObject[events.EventnKey+"_"+events.EventIdKey] = eventId
+	}
+	if ts, ok := object[timestamp.Key]; ok && ts != nil {
+		rawObject[timestamp.Key] = ts
+	}
+
+	return rawObject, nil
+}
@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/typing"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSchemaOnReadProcessing(t *testing.T) {
+	fieldMapper, _, err := NewFieldMapper(Default, []string{"/key1 -> /key2"}, nil)
+	require.NoError(t, err)
+	p, err := NewProcessor("test", "events", fieldMapper, nil, false, nil)
+	require.NoError(t, err)
+	p.SetRawColumn(DefaultRawColumn)
+
+	batchHeader, object, err := p.ProcessEvent(map[string]interface{}{
+		"eventn_ctx": map[string]interface{}{"event_id": "id1"},
+		"_timestamp": "2020-07-02T18:23:59.757719Z",
+		"key1":       map[string]interface{}{"nested": 1},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "events", batchHeader.TableName)
+	require.Equal(t, 3, len(batchHeader.Fields))
+	require.Equal(t, typing.STRING, batchHeader.Fields[DefaultRawColumn].GetType())
+	require.Equal(t, typing.TIMESTAMP, batchHeader.Fields["_timestamp"].GetType())
+	require.Equal(t, "id1", object["eventn_ctx_event_id"])
+	require.JSONEq(t, `{"eventn_ctx":{"event_id":"id1"},"_timestamp":"2020-07-02T18:23:59.757719Z","key1":{"nested":1}}`, object[DefaultRawColumn].(string))
+}
+
+func TestSchemaOnReadConfigValidate(t *testing.T) {
+	require.NoError(t, (*SchemaOnReadConfig)(nil).Validate())
+	require.NoError(t, (&SchemaOnReadConfig{Enabled: true, Views: true, Materialized: true}).Validate())
+	require.Error(t, (&SchemaOnReadConfig{Enabled: true, Column: "Raw-Data"}).Validate())
+	require.Error(t, (&SchemaOnReadConfig{Enabled: true, Materialized: true}).Validate())
+	require.Error(t, (&SchemaOnReadConfig{Enabled: true, RefreshMin: -1}).Validate())
+}
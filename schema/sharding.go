@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+const (
+	ShardRoundRobin = "round_robin"
+	ShardHash       = "hash"
+)
+
+var defaultShardKey = "/" + events.EventnKey + "/" + events.EventIdKey
+
+//ShardingConfig is a configuration of splitting a hot table into Shards physical tables: table_0 .. table_<Shards-1>
+//Events are distributed with round robin or with hash of Key JSON path value (default: event id)
+//If View is true - view with the table name over all shards is maintained (Postgres and ClickHouse only)
+type ShardingConfig struct {
+	Table  string `mapstructure:"table" json:"table,omitempty" yaml:"table,omitempty"`
+	Shards int    `mapstructure:"shards" json:"shards,omitempty" yaml:"shards,omitempty"`
+	Policy string `mapstructure:"policy" json:"policy,omitempty" yaml:"policy,omitempty"`
+	Key    string `mapstructure:"key" json:"key,omitempty" yaml:"key,omitempty"`
+	View   bool   `mapstructure:"view" json:"view,omitempty" yaml:"view,omitempty"`
+}
+
+func (sc *ShardingConfig) Validate() error {
+	if sc.Table == "" {
+		return fmt.Errorf("sharding.table is required parameter")
+	}
+	if sc.Shards < 2 {
+		return fmt.Errorf("sharding.shards of table [%s] must be at least 2", sc.Table)
+	}
+
+	switch sc.Policy {
+	case ShardRoundRobin, ShardHash:
+	default:
+		return fmt.Errorf("Unknown sharding.policy: %s. Available policies: [%s, %s]", sc.Policy, ShardRoundRobin, ShardHash)
+	}
+
+	if sc.Key != "" && jsonutils.NewJsonPath(sc.Key).IsEmpty() {
+		return fmt.Errorf("sharding.key must be a valid path like: /node1/node2. Got: %s", sc.Key)
+	}
+
+	return nil
+}
+
+type tableSharding struct {
+	shards  []string
+	policy  string
+	keyPath *jsonutils.JsonPath
+	view    bool
+	counter uint64
+}
+
+//Sharding replaces sharded table names with shard table names
+type Sharding struct {
+	identifier string
+	//logical table name -> sharding
+	tables map[string]*tableSharding
+	//shard table name -> logical table name
+	shards map[string]string
+}
+
+//NewSharding return nil if configs are empty (tables aren't sharded)
+func NewSharding(identifier string, configs []*ShardingConfig) (*Sharding, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	s := &Sharding{identifier: identifier, tables: map[string]*tableSharding{}, shards: map[string]string{}}
+	for _, config := range configs {
+		if config.Policy == "" {
+			config.Policy = ShardHash
+		}
+		if config.Key == "" {
+			config.Key = defaultShardKey
+		}
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := s.tables[config.Table]; ok {
+			return nil, fmt.Errorf("sharding of table [%s] is configured twice", config.Table)
+		}
+
+		ts := &tableSharding{policy: config.Policy, keyPath: jsonutils.NewJsonPath(config.Key), view: config.View}
+		for i := 0; i < config.Shards; i++ {
+			shard := ShardName(config.Table, i)
+			ts.shards = append(ts.shards, shard)
+			s.shards[shard] = config.Table
+		}
+		s.tables[config.Table] = ts
+	}
+
+	return s, nil
+}
+
+//ShardName return physical shard table name
+func ShardName(tableName string, shard int) string {
+	return fmt.Sprintf("%s_%d", tableName, shard)
+}
+
+//Apply return shard table name if the table is sharded or the table name as is
+//hash policy falls back to round robin if the object doesn't have key value
+func (s *Sharding) Apply(tableName string, object map[string]interface{}) string {
+	if s == nil {
+		return tableName
+	}
+
+	ts, ok := s.tables[tableName]
+	if !ok {
+		return tableName
+	}
+
+	if ts.policy == ShardHash {
+		if key, ok := ts.keyPath.Get(object); ok && key != nil {
+			h := fnv.New32a()
+			h.Write([]byte(fmt.Sprint(key)))
+			return ts.shards[h.Sum32()%uint32(len(ts.shards))]
+		}
+	}
+
+	next := atomic.AddUint64(&ts.counter, 1)
+	return ts.shards[next%uint64(len(ts.shards))]
+}
+
+//ViewTable return sharded table name and true if the table is a shard of the table with configured view
+func (s *Sharding) ViewTable(shardName string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+
+	tableName, ok := s.shards[shardName]
+	if !ok || !s.tables[tableName].view {
+		return "", false
+	}
+
+	return tableName, true
+}
+
+//Shards return shard table names of the sharded table
+func (s *Sharding) Shards(tableName string) []string {
+	if s == nil {
+		return nil
+	}
+
+	ts, ok := s.tables[tableName]
+	if !ok {
+		return nil
+	}
+
+	return ts.shards
+}
+
+//HasViews return true if at least one sharded table has configured view
+func (s *Sharding) HasViews() bool {
+	if s == nil {
+		return false
+	}
+
+	for _, ts := range s.tables {
+		if ts.view {
+			return true
+		}
+	}
+
+	return false
+}
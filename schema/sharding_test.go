@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestShardingConfigValidate(t *testing.T) {
+	_, err := NewSharding("test", []*ShardingConfig{{Shards: 2}})
+	require.Error(t, err)
+	_, err = NewSharding("test", []*ShardingConfig{{Table: "events", Shards: 1}})
+	require.Error(t, err)
+	_, err = NewSharding("test", []*ShardingConfig{{Table: "events", Shards: 2, Policy: "random"}})
+	require.Error(t, err)
+	_, err = NewSharding("test", []*ShardingConfig{{Table: "events", Shards: 2}, {Table: "events", Shards: 4}})
+	require.Error(t, err)
+
+	sharding, err := NewSharding("test", nil)
+	require.NoError(t, err)
+	require.Nil(t, sharding)
+	require.Equal(t, "events", sharding.Apply("events", map[string]interface{}{}))
+	require.False(t, sharding.HasViews())
+}
+
+func TestShardingApply(t *testing.T) {
+	sharding, err := NewSharding("test", []*ShardingConfig{
+		{Table: "events", Shards: 4, View: true},
+		{Table: "clicks", Shards: 2, Policy: ShardRoundRobin},
+		{Table: "users", Shards: 3, Policy: ShardHash, Key: "/user/id"},
+	})
+	require.NoError(t, err)
+	require.True(t, sharding.HasViews())
+	require.Equal(t, []string{"events_0", "events_1", "events_2", "events_3"}, sharding.Shards("events"))
+
+	//not sharded table
+	require.Equal(t, "pages", sharding.Apply("pages", map[string]interface{}{}))
+
+	//hash of event id (default key) is stable
+	event := map[string]interface{}{"eventn_ctx": map[string]interface{}{"event_id": "id1"}}
+	shard := sharding.Apply("events", event)
+	require.Contains(t, sharding.Shards("events"), shard)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, shard, sharding.Apply("events", event))
+	}
+
+	//hash of configured key
+	user := map[string]interface{}{"user": map[string]interface{}{"id": 42}}
+	require.Equal(t, sharding.Apply("users", user), sharding.Apply("users", user))
+
+	//round robin
+	first := sharding.Apply("clicks", map[string]interface{}{})
+	second := sharding.Apply("clicks", map[string]interface{}{})
+	require.NotEqual(t, first, second)
+	require.Equal(t, first, sharding.Apply("clicks", map[string]interface{}{}))
+
+	viewTable, ok := sharding.ViewTable("events_2")
+	require.True(t, ok)
+	require.Equal(t, "events", viewTable)
+	_, ok = sharding.ViewTable("clicks_0")
+	require.False(t, ok)
+	_, ok = sharding.ViewTable("events")
+	require.False(t, ok)
+}
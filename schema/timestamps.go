@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/timestamp"
+	"time"
+)
+
+const (
+	PartitionByServer = "server"
+	PartitionByClient = "client"
+	PartitionByLoad   = "load"
+
+	defaultClientTimestampField = "/eventn_ctx/utc_time"
+)
+
+//TimestampsConfig is a per destination configuration of event timestamps:
+//PartitionBy - which timestamp is written into _timestamp (used for partitioning, table name templates and late events policy)
+//ClientField - JSON path of client timestamp in the event
+type TimestampsConfig struct {
+	PartitionBy string `mapstructure:"partition_by" json:"partition_by,omitempty" yaml:"partition_by,omitempty"`
+	ClientField string `mapstructure:"client_field" json:"client_field,omitempty" yaml:"client_field,omitempty"`
+}
+
+func (tc *TimestampsConfig) Validate() error {
+	if tc == nil {
+		return nil
+	}
+
+	switch tc.PartitionBy {
+	case "", PartitionByServer, PartitionByClient, PartitionByLoad:
+		return nil
+	default:
+		return fmt.Errorf("Unknown timestamps.partition_by: %s. Available values: [%s, %s, %s]", tc.PartitionBy, PartitionByServer, PartitionByClient, PartitionByLoad)
+	}
+}
+
+//TimestampsPolicy writes standard timestamps into every event: _server_timestamp (receive time), _client_timestamp
+//(if client sent valid one) and _load_timestamp (processing time) and sets _timestamp to configured partition timestamp.
+//If client timestamp is chosen but it is absent or malformed - server timestamp is used
+type TimestampsPolicy struct {
+	partitionBy string
+	clientPath  *jsonutils.JsonPath
+	now         func() time.Time
+}
+
+//NewTimestampsPolicy return policy with server partitioning and default client field if config is nil
+func NewTimestampsPolicy(config *TimestampsConfig) (*TimestampsPolicy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	partitionBy := PartitionByServer
+	clientField := defaultClientTimestampField
+	if config != nil {
+		if config.PartitionBy != "" {
+			partitionBy = config.PartitionBy
+		}
+		if config.ClientField != "" {
+			clientField = config.ClientField
+		}
+	}
+
+	return &TimestampsPolicy{
+		partitionBy: partitionBy,
+		clientPath:  jsonutils.NewJsonPath(clientField),
+		now:         func() time.Time { return time.Now().UTC() },
+	}, nil
+}
+
+//Apply put standard timestamps into the object (in timestamp.Layout format) and set _timestamp
+//Server timestamp is kept in _server_timestamp because the same object might be processed by several destinations
+func (tp *TimestampsPolicy) Apply(object map[string]interface{}) {
+	if tp == nil {
+		return
+	}
+
+	serverValue, ok := object[timestamp.ServerKey]
+	if !ok {
+		serverValue, ok = object[timestamp.Key]
+	}
+	serverTime, ok := parseTimestamp(serverValue)
+	if !ok {
+		//malformed _timestamp is reported by table name extractor
+		return
+	}
+	server := timestamp.ToISOFormat(serverTime)
+	load := timestamp.ToISOFormat(tp.now())
+	object[timestamp.ServerKey] = server
+	object[timestamp.LoadKey] = load
+
+	client := ""
+	if clientValue, ok := tp.clientPath.Get(object); ok {
+		if clientTime, ok := parseTimestamp(clientValue); ok {
+			client = timestamp.ToISOFormat(clientTime.UTC())
+			object[timestamp.ClientKey] = client
+		}
+	}
+
+	switch tp.partitionBy {
+	case PartitionByClient:
+		if client != "" {
+			object[timestamp.Key] = client
+		} else {
+			object[timestamp.Key] = server
+		}
+	case PartitionByLoad:
+		object[timestamp.Key] = load
+	default:
+		object[timestamp.Key] = server
+	}
+}
+
+//PartitionBy return which timestamp is written into _timestamp
+func (tp *TimestampsPolicy) PartitionBy() string {
+	return tp.partitionBy
+}
+
+//parseTimestamp return time from time.Time or string value in timestamp.Layout or RFC3339 format
+func parseTimestamp(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse(timestamp.Layout, v); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimestampsPolicy(t *testing.T) {
+	server := "2020-10-01T12:00:00.000000Z"
+	client := "2020-10-01T11:59:30.123000Z"
+	load := time.Date(2020, 10, 1, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name              string
+		config            *TimestampsConfig
+		input             map[string]interface{}
+		expectedTimestamp string
+		expectedClient    interface{}
+	}{
+		{
+			"Nil config: partition by server",
+			nil,
+			map[string]interface{}{timestamp.Key: server, "eventn_ctx": map[string]interface{}{"utc_time": "2020-10-01T11:59:30.123Z"}},
+			server,
+			client,
+		},
+		{
+			"Partition by client",
+			&TimestampsConfig{PartitionBy: PartitionByClient},
+			map[string]interface{}{timestamp.Key: server, "eventn_ctx": map[string]interface{}{"utc_time": "2020-10-01T14:59:30.123+03:00"}},
+			client,
+			client,
+		},
+		{
+			"Partition by client without client timestamp",
+			&TimestampsConfig{PartitionBy: PartitionByClient},
+			map[string]interface{}{timestamp.Key: server, "eventn_ctx": map[string]interface{}{"utc_time": "malformed"}},
+			server,
+			nil,
+		},
+		{
+			"Partition by client with custom field",
+			&TimestampsConfig{PartitionBy: PartitionByClient, ClientField: "/sent_at"},
+			map[string]interface{}{timestamp.Key: server, "sent_at": "2020-10-01T11:59:30.123Z"},
+			client,
+			client,
+		},
+		{
+			"Partition by load",
+			&TimestampsConfig{PartitionBy: PartitionByLoad},
+			map[string]interface{}{timestamp.Key: server},
+			"2020-10-01T13:00:00.000000Z",
+			nil,
+		},
+		{
+			"Already processed by another destination",
+			nil,
+			map[string]interface{}{timestamp.Key: load, timestamp.ServerKey: server},
+			server,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewTimestampsPolicy(tt.config)
+			require.NoError(t, err)
+			policy.now = func() time.Time { return load }
+
+			policy.Apply(tt.input)
+
+			require.Equal(t, tt.expectedTimestamp, tt.input[timestamp.Key])
+			require.Equal(t, server, tt.input[timestamp.ServerKey])
+			require.Equal(t, "2020-10-01T13:00:00.000000Z", tt.input[timestamp.LoadKey])
+			require.Equal(t, tt.expectedClient, tt.input[timestamp.ClientKey])
+		})
+	}
+
+	_, err := NewTimestampsPolicy(&TimestampsConfig{PartitionBy: "unknown"})
+	require.Error(t, err)
+}
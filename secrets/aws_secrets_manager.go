@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"sync"
+)
+
+const awsSecretsManagerScheme = "awssm"
+
+//AwsSecretsManagerConfig is an optional AWS Secrets Manager configuration
+//AWS default credentials chain and region (e.g. AWS_REGION env variable) are used if they aren't configured
+type AwsSecretsManagerConfig struct {
+	Region      string `mapstructure:"region" json:"region,omitempty" yaml:"region,omitempty"`
+	AccessKeyID string `mapstructure:"access_key_id" json:"access_key_id,omitempty" yaml:"access_key_id,omitempty"`
+	SecretKey   string `mapstructure:"secret_access_key" json:"secret_access_key,omitempty" yaml:"secret_access_key,omitempty"`
+}
+
+//AwsSecretsManager reads secrets: awssm://<secret id or ARN>#<JSON key>
+//key might be omitted if the secret string isn't a JSON object
+type AwsSecretsManager struct {
+	sync.Mutex
+
+	config *AwsSecretsManagerConfig
+	//client is created on the first usage
+	client *secretsmanager.SecretsManager
+}
+
+func NewAwsSecretsManager(config *AwsSecretsManagerConfig) *AwsSecretsManager {
+	if config == nil {
+		config = &AwsSecretsManagerConfig{}
+	}
+
+	return &AwsSecretsManager{config: config}
+}
+
+func (asm *AwsSecretsManager) Scheme() string {
+	return awsSecretsManagerScheme
+}
+
+func (asm *AwsSecretsManager) Get(secretId string) (*Secret, error) {
+	client, err := asm.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(secretId)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+
+	var value string
+	if output.SecretString != nil {
+		value = *output.SecretString
+	} else {
+		value = string(output.SecretBinary)
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return &Secret{Raw: value}, nil
+	}
+
+	return &Secret{Data: data}, nil
+}
+
+func (asm *AwsSecretsManager) getClient() (*secretsmanager.SecretsManager, error) {
+	asm.Lock()
+	defer asm.Unlock()
+
+	if asm.client != nil {
+		return asm.client, nil
+	}
+
+	awsConfig := aws.NewConfig()
+	if asm.config.Region != "" {
+		awsConfig.WithRegion(asm.config.Region)
+	}
+	if asm.config.AccessKeyID != "" {
+		awsConfig.WithCredentials(credentials.NewStaticCredentials(asm.config.AccessKeyID, asm.config.SecretKey, ""))
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating AWS session: %v", err)
+	}
+
+	asm.client = secretsmanager.New(awsSession)
+	return asm.client, nil
+}
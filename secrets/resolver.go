@@ -0,0 +1,399 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/safego"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	schemeSeparator = "://"
+	keySeparator    = "#"
+
+	defaultCacheTTL = 5 * time.Minute
+	renewInterval   = 10 * time.Second
+)
+
+var (
+	instance *Resolver
+
+	//errNotFound is returned by providers if secret doesn't exist
+	errNotFound = errors.New("secret not found")
+)
+
+//Config is a secrets resolution configuration
+type Config struct {
+	Vault             *VaultConfig             `mapstructure:"vault" json:"vault,omitempty" yaml:"vault,omitempty"`
+	AwsSecretsManager *AwsSecretsManagerConfig `mapstructure:"aws_secrets_manager" json:"aws_secrets_manager,omitempty" yaml:"aws_secrets_manager,omitempty"`
+	//CacheTTLSec is a caching time of secrets without leases. Default value is 300
+	CacheTTLSec int `mapstructure:"cache_ttl_sec" json:"cache_ttl_sec,omitempty" yaml:"cache_ttl_sec,omitempty"`
+}
+
+//Secret is a key-value secret payload with optional lease (e.g. Vault dynamic database credentials)
+type Secret struct {
+	Data map[string]interface{}
+	//Raw is a secret string if it isn't a JSON object
+	Raw string
+
+	LeaseId       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+//Provider return secrets by path
+type Provider interface {
+	Scheme() string
+	Get(path string) (*Secret, error)
+}
+
+//LeaseRenewer is a Provider which supports leases renewal
+type LeaseRenewer interface {
+	Renew(leaseId string) (time.Duration, error)
+}
+
+type cachedSecret struct {
+	secret    *Secret
+	expiresAt time.Time
+	//leased secrets are renewed or re-read on expiration with change notification
+	leased bool
+}
+
+//leaseRenewal is a cached secret which lease should be renewed
+type leaseRenewal struct {
+	key     string
+	cached  *cachedSecret
+	renewer LeaseRenewer
+}
+
+//Resolver replaces secret references <scheme>://<path>#<key> with secret values
+//Secrets are cached per path: all keys of the path (e.g. username and password) belong to the same lease.
+//Leases of renewable secrets are renewed in background. Expired leased secrets are removed from cache
+//and OnChange callbacks are called for reloading configurations with the new credentials
+type Resolver struct {
+	sync.RWMutex
+
+	providers map[string]Provider
+	cacheTTL  time.Duration
+	cache     map[string]*cachedSecret
+
+	onChange []func()
+	closed   chan struct{}
+}
+
+//Init create global Resolver with Vault (if configured) and AWS Secrets Manager providers
+func Init(config *Config) error {
+	if config == nil {
+		config = &Config{}
+	}
+
+	var providers []Provider
+	vault, err := NewVault(config.Vault)
+	if err != nil {
+		return err
+	}
+	if vault != nil {
+		providers = append(providers, vault)
+	}
+	providers = append(providers, NewAwsSecretsManager(config.AwsSecretsManager))
+
+	cacheTTL := defaultCacheTTL
+	if config.CacheTTLSec > 0 {
+		cacheTTL = time.Duration(config.CacheTTLSec) * time.Second
+	}
+
+	instance = NewResolver(cacheTTL, providers...)
+	return nil
+}
+
+//NewResolver return Resolver and run leases renewal goroutine
+func NewResolver(cacheTTL time.Duration, providers ...Provider) *Resolver {
+	r := &Resolver{
+		providers: map[string]Provider{},
+		cacheTTL:  cacheTTL,
+		cache:     map[string]*cachedSecret{},
+		closed:    make(chan struct{}),
+	}
+	for _, provider := range providers {
+		r.providers[provider.Scheme()] = provider
+	}
+
+	r.startRenewal()
+
+	return r
+}
+
+//OnChange register callback which is called when leased secret has been expired
+func OnChange(callback func()) {
+	if instance != nil {
+		instance.OnChange(callback)
+	}
+}
+
+//ResolveValues return value with resolved secret references in strings (recursively in maps and slices)
+//value is returned as is if secrets aren't initialized
+func ResolveValues(value interface{}) (interface{}, error) {
+	if instance == nil {
+		return value, nil
+	}
+
+	return instance.ResolveValues(value)
+}
+
+//ResolveJson return JSON payload with resolved secret references
+func ResolveJson(payload []byte) ([]byte, error) {
+	if instance == nil {
+		return payload, nil
+	}
+
+	return instance.ResolveJson(payload)
+}
+
+//Close stop leases renewal
+func Close() {
+	if instance != nil {
+		instance.Close()
+	}
+}
+
+func (r *Resolver) OnChange(callback func()) {
+	r.Lock()
+	r.onChange = append(r.onChange, callback)
+	r.Unlock()
+}
+
+//IsReference return true if value is <scheme>://... and scheme is supported
+func (r *Resolver) IsReference(value string) bool {
+	index := strings.Index(value, schemeSeparator)
+	if index <= 0 {
+		return false
+	}
+
+	_, ok := r.providers[value[:index]]
+	return ok
+}
+
+//Resolve return secret value by reference <scheme>://<path>#<key>
+//key might be omitted if secret isn't a JSON object
+func (r *Resolver) Resolve(reference string) (string, error) {
+	index := strings.Index(reference, schemeSeparator)
+	if index <= 0 {
+		return "", fmt.Errorf("Secret reference [%s] must be <scheme>://<path>#<key>", reference)
+	}
+	scheme := reference[:index]
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return "", fmt.Errorf("Unsupported secret scheme [%s]", scheme)
+	}
+
+	path := reference[index+len(schemeSeparator):]
+	var key string
+	if keyIndex := strings.LastIndex(path, keySeparator); keyIndex >= 0 {
+		key = path[keyIndex+1:]
+		path = path[:keyIndex]
+	}
+	if path == "" {
+		return "", fmt.Errorf("Secret reference [%s] path is empty", reference)
+	}
+
+	secret, err := r.get(provider, scheme+schemeSeparator+path)
+	if err != nil {
+		return "", fmt.Errorf("Error getting secret [%s%s%s]: %v", scheme, schemeSeparator, path, err)
+	}
+
+	if key == "" {
+		if secret.Raw == "" {
+			return "", fmt.Errorf("Secret [%s] is a JSON object: key must be specified e.g. %s#key", reference, reference)
+		}
+		return secret.Raw, nil
+	}
+
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("Secret [%s%s%s] doesn't contain [%s] key", scheme, schemeSeparator, path, key)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+//ResolveValues return copy of value with resolved secret references
+func (r *Resolver) ResolveValues(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !r.IsReference(v) {
+			return v, nil
+		}
+		return r.Resolve(v)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			resolved, err := r.ResolveValues(element)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for key, element := range v {
+			resolved, err := r.ResolveValues(element)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			resolved, err := r.ResolveValues(element)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+//ResolveJson return JSON payload with resolved secret references
+//payload is returned as is if it doesn't contain references
+func (r *Resolver) ResolveJson(payload []byte) ([]byte, error) {
+	hasReferences := false
+	for scheme := range r.providers {
+		if bytes.Contains(payload, []byte(scheme+schemeSeparator)) {
+			hasReferences = true
+			break
+		}
+	}
+	if !hasReferences {
+		return payload, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var object interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON payload: %v", err)
+	}
+
+	resolved, err := r.ResolveValues(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resolved)
+}
+
+func (r *Resolver) Close() {
+	close(r.closed)
+}
+
+//get return cached secret or get it from provider
+func (r *Resolver) get(provider Provider, cacheKey string) (*Secret, error) {
+	r.RLock()
+	cached, ok := r.cache[cacheKey]
+	r.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.secret, nil
+	}
+
+	path := cacheKey[len(provider.Scheme()+schemeSeparator):]
+	secret, err := provider.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cached = &cachedSecret{secret: secret, expiresAt: time.Now().Add(r.cacheTTL)}
+	if secret.LeaseDuration > 0 {
+		cached.leased = true
+		cached.expiresAt = time.Now().Add(secret.LeaseDuration)
+	}
+
+	r.Lock()
+	r.cache[cacheKey] = cached
+	r.Unlock()
+
+	return secret, nil
+}
+
+func (r *Resolver) startRenewal() {
+	safego.RunWithRestart(func() {
+		ticker := time.NewTicker(renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.closed:
+				return
+			case <-ticker.C:
+				r.renew(time.Now())
+			}
+		}
+	})
+}
+
+//renew renew leases which expire in the next third of their duration
+//expired (or not renewed) leased secrets are removed from cache and OnChange callbacks are called
+//renewal requests are sent without lock (secrets are resolved meanwhile)
+func (r *Resolver) renew(now time.Time) {
+	r.Lock()
+	var candidates []*leaseRenewal
+	for key, cached := range r.cache {
+		if !cached.leased {
+			continue
+		}
+
+		renewer, ok := r.providers[key[:strings.Index(key, schemeSeparator)]].(LeaseRenewer)
+		if ok && cached.secret.Renewable && now.Add(cached.secret.LeaseDuration/3).After(cached.expiresAt) {
+			candidates = append(candidates, &leaseRenewal{key: key, cached: cached, renewer: renewer})
+		}
+	}
+	r.Unlock()
+
+	renewed := map[*cachedSecret]time.Duration{}
+	for _, candidate := range candidates {
+		duration, err := candidate.renewer.Renew(candidate.cached.secret.LeaseId)
+		if err != nil {
+			logging.Errorf("Error renewing secret [%s] lease: %v", candidate.key, err)
+		} else if duration > 0 {
+			renewed[candidate.cached] = duration
+		}
+	}
+
+	r.Lock()
+	var expired []string
+	for key, cached := range r.cache {
+		if !cached.leased {
+			continue
+		}
+
+		//secrets which have been re-read meanwhile aren't in renewed
+		if duration, ok := renewed[cached]; ok {
+			cached.expiresAt = now.Add(duration)
+			continue
+		}
+
+		//re-read secret a bit before the lease expiration
+		if now.Add(2 * renewInterval).After(cached.expiresAt) {
+			expired = append(expired, key)
+			delete(r.cache, key)
+		}
+	}
+	callbacks := r.onChange
+	r.Unlock()
+
+	if len(expired) > 0 {
+		logging.Infof("Secrets %v leases have been expired. Configurations will be reloaded", expired)
+		for _, callback := range callbacks {
+			callback()
+		}
+	}
+}
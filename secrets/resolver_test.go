@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testProvider struct {
+	secrets  map[string]*Secret
+	reads    int
+	renewErr error
+}
+
+func (tp *testProvider) Scheme() string {
+	return "test"
+}
+
+func (tp *testProvider) Get(path string) (*Secret, error) {
+	tp.reads++
+	secret, ok := tp.secrets[path]
+	if !ok {
+		return nil, errNotFound
+	}
+	return secret, nil
+}
+
+func (tp *testProvider) Renew(leaseId string) (time.Duration, error) {
+	return time.Minute, tp.renewErr
+}
+
+func TestResolveValues(t *testing.T) {
+	provider := &testProvider{secrets: map[string]*Secret{
+		"db":    {Data: map[string]interface{}{"username": "user", "password": "pass", "port": json.Number("5432")}},
+		"token": {Raw: "raw_token"},
+	}}
+	resolver := NewResolver(time.Minute, provider)
+	defer resolver.Close()
+
+	resolved, err := resolver.ResolveValues(map[string]interface{}{
+		"datasource": map[interface{}]interface{}{"username": "test://db#username", "password": "test://db#password", "port": "test://db#port"},
+		"hosts":      []interface{}{"https://host", "test://token"},
+		"batch_size": 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"datasource": map[interface{}]interface{}{"username": "user", "password": "pass", "port": "5432"},
+		"hosts":      []interface{}{"https://host", "raw_token"},
+		"batch_size": 10,
+	}, resolved)
+	//secrets are cached per path
+	require.Equal(t, 2, provider.reads)
+
+	_, err = resolver.ResolveValues("test://db")
+	require.Error(t, err)
+	_, err = resolver.ResolveValues("test://db#unknown")
+	require.Error(t, err)
+	_, err = resolver.ResolveValues("test://unknown#key")
+	require.Error(t, err)
+
+	payload, err := resolver.ResolveJson([]byte(`{"destinations":{"pg":{"datasource":{"password":"test://db#password"}}}}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"destinations":{"pg":{"datasource":{"password":"pass"}}}}`, string(payload))
+}
+
+func TestRenewLeases(t *testing.T) {
+	provider := &testProvider{secrets: map[string]*Secret{
+		"creds": {Data: map[string]interface{}{"password": "pass"}, LeaseId: "lease", LeaseDuration: time.Minute, Renewable: true},
+	}}
+	resolver := NewResolver(time.Minute, provider)
+	defer resolver.Close()
+	changes := 0
+	resolver.OnChange(func() { changes++ })
+
+	_, err := resolver.Resolve("test://creds#password")
+	require.NoError(t, err)
+
+	//renewed lease
+	resolver.renew(time.Now().Add(50 * time.Second))
+	require.Equal(t, 0, changes)
+	require.Len(t, resolver.cache, 1)
+
+	//failed renewal: secret is removed and callbacks are called before expiration
+	provider.renewErr = errors.New("max TTL")
+	resolver.renew(time.Now().Add(100 * time.Second))
+	require.Equal(t, 1, changes)
+	require.Len(t, resolver.cache, 0)
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token", r.Header.Get(vaultTokenHeader))
+		switch r.URL.Path {
+		case "/v1/secret/data/ch":
+			w.Write([]byte(`{"data":{"data":{"password":"pass"},"metadata":{"version":1}}}`))
+		case "/v1/database/creds/ro":
+			w.Write([]byte(`{"lease_id":"database/creds/ro/1","lease_duration":3600,"renewable":true,"data":{"username":"v-user","password":"v-pass"}}`))
+		case "/v1/sys/leases/renew":
+			require.Equal(t, http.MethodPut, r.Method)
+			w.Write([]byte(`{"lease_id":"database/creds/ro/1","lease_duration":1800,"renewable":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	vault, err := NewVault(&VaultConfig{Address: server.URL, Token: "token"})
+	require.NoError(t, err)
+
+	secret, err := vault.Get("secret/data/ch")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"password": "pass"}, secret.Data)
+
+	secret, err = vault.Get("database/creds/ro")
+	require.NoError(t, err)
+	require.Equal(t, "v-user", secret.Data["username"])
+	require.Equal(t, time.Hour, secret.LeaseDuration)
+	require.True(t, secret.Renewable)
+
+	duration, err := vault.Renew(secret.LeaseId)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, duration)
+
+	_, err = vault.Get("secret/data/unknown")
+	require.Equal(t, errNotFound, err)
+}
@@ -0,0 +1,157 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	vaultScheme = "vault"
+
+	vaultTokenHeader     = "X-Vault-Token"
+	vaultNamespaceHeader = "X-Vault-Namespace"
+)
+
+//VaultConfig is a HashiCorp Vault connection configuration
+//Address and token might be provided with VAULT_ADDR and VAULT_TOKEN env variables
+type VaultConfig struct {
+	Address   string `mapstructure:"address" json:"address,omitempty" yaml:"address,omitempty"`
+	Token     string `mapstructure:"token" json:"token,omitempty" yaml:"token,omitempty"`
+	Namespace string `mapstructure:"namespace" json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+type vaultResponse struct {
+	LeaseId       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+//Vault reads secrets with Vault HTTP API: vault://<path>#<key> e.g. vault://secret/data/clickhouse#password
+//KV v2 (data.data), KV v1 and dynamic secrets engines (e.g. database/creds/role) are supported
+type Vault struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+//NewVault return Vault or nil if address isn't configured
+func NewVault(config *VaultConfig) (*Vault, error) {
+	if config == nil {
+		config = &VaultConfig{}
+	}
+
+	address := config.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, nil
+	}
+
+	token := config.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("Vault token is required: please configure secrets.vault.token or VAULT_TOKEN env variable")
+	}
+
+	return &Vault{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: config.Namespace,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (v *Vault) Scheme() string {
+	return vaultScheme
+}
+
+//Get return secret data. KV v2 data.data is unwrapped
+func (v *Vault) Get(path string) (*Secret, error) {
+	response, err := v.request(http.MethodGet, "/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	return &Secret{
+		Data:          data,
+		LeaseId:       response.LeaseId,
+		LeaseDuration: time.Duration(response.LeaseDuration) * time.Second,
+		Renewable:     response.Renewable,
+	}, nil
+}
+
+//Renew renew lease and return new lease duration
+func (v *Vault) Renew(leaseId string) (time.Duration, error) {
+	body, err := json.Marshal(map[string]string{"lease_id": leaseId})
+	if err != nil {
+		return 0, err
+	}
+
+	response, err := v.request(http.MethodPut, "/v1/sys/leases/renew", body)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(response.LeaseDuration) * time.Second, nil
+}
+
+func (v *Vault) request(method, path string, body []byte) (*vaultResponse, error) {
+	req, err := http.NewRequest(method, v.address+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(vaultTokenHeader, v.token)
+	if v.namespace != "" {
+		req.Header.Set(vaultNamespaceHeader, v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading Vault response: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+
+	response := &vaultResponse{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, response); err != nil {
+			return nil, fmt.Errorf("Error parsing Vault response [%d]: %v", resp.StatusCode, err)
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Vault response code: %d errors: %v", resp.StatusCode, response.Errors)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,8 @@
+// +build !windows
+
+package main
+
+//startServiceHandler does nothing: services are supported only on Windows
+func startServiceHandler(stop func()) error {
+	return nil
+}
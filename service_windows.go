@@ -0,0 +1,58 @@
+// +build windows
+
+package main
+
+import (
+	"github.com/jitsucom/eventnative/logging"
+	"golang.org/x/sys/windows/svc"
+	"os"
+)
+
+const windowsServiceName = "EventNative"
+
+//windowsService is a Windows Service Control Manager requests handler
+type windowsService struct {
+	stop func()
+}
+
+//startServiceHandler start Windows service control dispatcher if the process is run by Service Control Manager
+//stop is called on service Stop and Shutdown requests and then the process exits
+func startServiceHandler(stop func()) error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return err
+	}
+	if interactive {
+		return nil
+	}
+
+	go func() {
+		if err := svc.Run(windowsServiceName, &windowsService{stop: stop}); err != nil {
+			logging.Errorf("Error running Windows service: %v", err)
+			return
+		}
+		os.Exit(0)
+	}()
+
+	return nil
+}
+
+//Execute report Running status and wait for Stop or Shutdown request
+func (ws *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, statuses chan<- svc.Status) (bool, uint32) {
+	statuses <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			statuses <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			statuses <- svc.Status{State: svc.StopPending}
+			ws.stop()
+			return false, 0
+		default:
+			logging.Warnf("Unexpected Windows service control request: %d", request.Cmd)
+		}
+	}
+
+	return false, 0
+}
@@ -0,0 +1,76 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//Header is a signature header of signed requests (see middleware.SignatureAuth) and lifecycle webhooks
+	Header = "X-EN-Signature"
+
+	timestampKey = "t"
+	valueKey     = "v1"
+)
+
+//Sign return X-EN-Signature header value for the body
+func Sign(secret string, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return timestampKey + "=" + timestamp + "," + valueKey + "=" + compute(secret, timestamp, body)
+}
+
+//Verify return signature value if header is valid and signature timestamp is within the replay window
+func Verify(header, secret string, body []byte, maxAge time.Duration, now time.Time) (string, error) {
+	if header == "" {
+		return "", fmt.Errorf("%s header is required", Header)
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case timestampKey:
+			timestamp = kv[1]
+		case valueKey:
+			signature = kv[1]
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return "", fmt.Errorf("Malformed %s header: expected t=<timestamp>,v1=<signature>", Header)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("Malformed signature timestamp: %v", err)
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > maxAge || age < -maxAge {
+		return "", errors.New("signature timestamp is out of the allowed window")
+	}
+
+	signature = strings.ToLower(signature)
+	expected := compute(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", errors.New("signature doesn't match")
+	}
+
+	return signature, nil
+}
+
+//compute return hex HMAC-SHA256(secret, "<timestamp>.<body>")
+func compute(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
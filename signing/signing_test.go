@@ -0,0 +1,58 @@
+package signing
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1608811200, 0)
+	body := []byte(`{"event_type":"purchase"}`)
+	tests := []struct {
+		name        string
+		header      string
+		expectedErr string
+	}{
+		{
+			"Valid signature",
+			Sign("secret", body, now.Add(-time.Minute)),
+			"",
+		},
+		{
+			"Empty header",
+			"",
+			"X-EN-Signature header is required",
+		},
+		{
+			"Malformed header",
+			"abc",
+			"Malformed X-EN-Signature header: expected t=<timestamp>,v1=<signature>",
+		},
+		{
+			"Old timestamp",
+			Sign("secret", body, now.Add(-10*time.Minute)),
+			"signature timestamp is out of the allowed window",
+		},
+		{
+			"Future timestamp",
+			Sign("secret", body, now.Add(10*time.Minute)),
+			"signature timestamp is out of the allowed window",
+		},
+		{
+			"Wrong secret",
+			Sign("another", body, now),
+			"signature doesn't match",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(tt.header, "secret", body, 5*time.Minute, now)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
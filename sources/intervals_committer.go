@@ -0,0 +1,102 @@
+package sources
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/drivers"
+	"sync"
+)
+
+type intervalResult struct {
+	rowsCount  int
+	bytesCount int64
+	err        error
+}
+
+//intervalsCommitter commits concurrently synced intervals in the intervals order:
+//interval is committed only after all the previous intervals have been synced successfully.
+//Intervals which have been synced after a failed one aren't committed and will be synced again
+type intervalsCommitter struct {
+	sync.Mutex
+
+	intervals []*drivers.TimeInterval
+	results   []*intervalResult
+	commit    func(interval *drivers.TimeInterval)
+
+	next       int
+	rowsSynced int
+	hasErrors  bool
+
+	//successfully synced (not only committed) intervals totals are used for sync run limits
+	syncedRows  int
+	syncedBytes int64
+}
+
+func newIntervalsCommitter(intervals []*drivers.TimeInterval, commit func(interval *drivers.TimeInterval)) *intervalsCommitter {
+	return &intervalsCommitter{intervals: intervals, results: make([]*intervalResult, len(intervals)), commit: commit}
+}
+
+//done save interval sync result and commit all synced intervals which are next in order
+func (ic *intervalsCommitter) done(index, rowsCount int, bytesCount int64, err error) {
+	ic.Lock()
+	defer ic.Unlock()
+
+	ic.results[index] = &intervalResult{rowsCount: rowsCount, bytesCount: bytesCount, err: err}
+	if err != nil {
+		ic.hasErrors = true
+	} else {
+		ic.syncedRows += rowsCount
+		ic.syncedBytes += bytesCount
+	}
+
+	for ic.next < len(ic.intervals) && ic.results[ic.next] != nil && ic.results[ic.next].err == nil {
+		ic.commit(ic.intervals[ic.next])
+		ic.rowsSynced += ic.results[ic.next].rowsCount
+		ic.next++
+	}
+}
+
+//failed return true if at least one interval sync has been failed
+func (ic *intervalsCommitter) failed() bool {
+	ic.Lock()
+	defer ic.Unlock()
+
+	return ic.hasErrors
+}
+
+//synced return rows and bytes count of successfully synced intervals
+func (ic *intervalsCommitter) synced() (int, int64) {
+	ic.Lock()
+	defer ic.Unlock()
+
+	return ic.syncedRows, ic.syncedBytes
+}
+
+//truncate exclude intervals after the first n (not dispatched ones) from the result
+//must be called after all dispatched intervals are done
+func (ic *intervalsCommitter) truncate(n int) {
+	ic.Lock()
+	defer ic.Unlock()
+
+	if n < len(ic.intervals) {
+		ic.intervals = ic.intervals[:n]
+		ic.results = ic.results[:n]
+	}
+}
+
+//result return committed rows count and the first (in intervals order) error
+func (ic *intervalsCommitter) result() (int, error) {
+	ic.Lock()
+	defer ic.Unlock()
+
+	if ic.next == len(ic.intervals) {
+		return ic.rowsSynced, nil
+	}
+
+	for _, result := range ic.results[ic.next:] {
+		if result != nil && result.err != nil {
+			return ic.rowsSynced, result.err
+		}
+	}
+
+	return ic.rowsSynced, errors.New("Not all intervals have been synced")
+}
@@ -0,0 +1,62 @@
+package sources
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestIntervalsCommitter(t *testing.T) {
+	var intervals []*drivers.TimeInterval
+	for i := 0; i < 4; i++ {
+		intervals = append(intervals, drivers.NewTimeInterval(drivers.DAY, time.Date(2021, 1, i+1, 0, 0, 0, 0, time.UTC)))
+	}
+
+	var committed []string
+	committer := newIntervalsCommitter(intervals, func(interval *drivers.TimeInterval) {
+		committed = append(committed, interval.String())
+	})
+
+	//out of order results are committed in the intervals order
+	committer.done(1, 20, 0, nil)
+	require.Empty(t, committed)
+	committer.done(0, 10, 0, nil)
+	require.Equal(t, []string{intervals[0].String(), intervals[1].String()}, committed)
+
+	//intervals after the failed one aren't committed
+	committer.done(3, 40, 0, nil)
+	committer.done(2, 0, 0, errors.New("sync error"))
+	require.True(t, committer.failed())
+	require.Equal(t, []string{intervals[0].String(), intervals[1].String()}, committed)
+
+	rowsSynced, err := committer.result()
+	require.EqualError(t, err, "sync error")
+	require.Equal(t, 30, rowsSynced)
+}
+
+func TestIntervalsCommitterLimits(t *testing.T) {
+	var intervals []*drivers.TimeInterval
+	for i := 0; i < 3; i++ {
+		intervals = append(intervals, drivers.NewTimeInterval(drivers.DAY, time.Date(2021, 1, i+1, 0, 0, 0, 0, time.UTC)))
+	}
+
+	committer := newIntervalsCommitter(intervals, func(interval *drivers.TimeInterval) {})
+	limits := &SyncLimits{MaxRows: 100, MaxBytes: 1000}
+
+	committer.done(0, 60, 400, nil)
+	require.False(t, limits.Exceeded(committer.synced()))
+	committer.done(1, 60, 400, nil)
+	require.True(t, limits.Exceeded(committer.synced()))
+
+	//not dispatched intervals will be synced in the next run
+	committer.truncate(2)
+	rowsSynced, err := committer.result()
+	require.NoError(t, err)
+	require.Equal(t, 120, rowsSynced)
+
+	var noLimits *SyncLimits
+	require.False(t, noLimits.Exceeded(1000000, 1000000))
+	require.True(t, (&SyncLimits{MaxBytes: 500}).Exceeded(0, 800))
+}
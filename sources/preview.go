@@ -0,0 +1,81 @@
+package sources
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	//DryRunFile mode writes synced objects into local NDJSON file <preview dir>/<source id>_<collection>.ndjson
+	DryRunFile = "file"
+	//DryRunTable mode writes synced objects into <collection table>_preview tables of source destinations
+	DryRunTable = "table"
+
+	PreviewTableSuffix = "_preview"
+)
+
+//ValidateDryRunMode return error if mode isn't file or table
+func ValidateDryRunMode(mode string) error {
+	if mode != DryRunFile && mode != DryRunTable {
+		return fmt.Errorf("Unknown dry run mode: %s. Supported: [%s, %s]", mode, DryRunFile, DryRunTable)
+	}
+
+	return nil
+}
+
+//PreviewFilePath return NDJSON file path of collection dry run
+func PreviewFilePath(previewDir, sourceId, collection string) string {
+	return filepath.Join(previewDir, sourceId+"_"+collection+".ndjson")
+}
+
+//previewFile writes objects as JSON lines (goroutine safe)
+type previewFile struct {
+	sync.Mutex
+
+	file   *os.File
+	writer *bufio.Writer
+}
+
+//newPreviewFile create preview dir if not exists and truncate file
+func newPreviewFile(filePath string) (*previewFile, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("Error creating preview dir: %v", err)
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating preview file: %v", err)
+	}
+
+	return &previewFile{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (pf *previewFile) Write(objects []map[string]interface{}) error {
+	pf.Lock()
+	defer pf.Unlock()
+
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("Error marshalling object: %v", err)
+		}
+		if _, err := pf.writer.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("Error writing preview file: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (pf *previewFile) Close() error {
+	if err := pf.writer.Flush(); err != nil {
+		pf.file.Close()
+		return fmt.Errorf("Error flushing preview file: %v", err)
+	}
+
+	return pf.file.Close()
+}
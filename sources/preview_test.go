@@ -0,0 +1,31 @@
+package sources
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPreviewFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preview")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filePath := PreviewFilePath(dir+"/preview", "source", "collection")
+	for i := 0; i < 2; i++ {
+		preview, err := newPreviewFile(filePath)
+		require.NoError(t, err)
+		require.NoError(t, preview.Write([]map[string]interface{}{{"id": 1}, {"id": 2, "name": "a"}}))
+		require.NoError(t, preview.Close())
+	}
+
+	//file is truncated on every dry run
+	b, err := ioutil.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, "{\"id\":1}\n{\"id\":2,\"name\":\"a\"}\n", string(b))
+
+	require.NoError(t, ValidateDryRunMode(DryRunFile))
+	require.NoError(t, ValidateDryRunMode(DryRunTable))
+	require.Error(t, ValidateDryRunMode("true"))
+}
@@ -5,14 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/cluster"
 	"github.com/jitsucom/eventnative/destinations"
 	"github.com/jitsucom/eventnative/drivers"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
-	"github.com/jitsucom/eventnative/safego"
+	"github.com/jitsucom/eventnative/scheduler"
 	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/transform"
 	"github.com/panjf2000/ants/v2"
 	"github.com/spf13/viper"
 	"io"
@@ -38,8 +40,14 @@ type Service struct {
 	destinationsService *destinations.Service
 	metaStorage         meta.Storage
 	monitorKeeper       storages.MonitorKeeper
-
-	closed bool
+	clusterManager      cluster.Manager
+	serverName          string
+	//previewDir is a directory of dry run NDJSON files
+	previewDir string
+
+	monitoringJob *scheduler.Job
+	//draining is true if new sync tasks aren't accepted (see Drain)
+	draining bool
 }
 
 //only for tests
@@ -47,8 +55,10 @@ func NewTestService() *Service {
 	return &Service{}
 }
 
+//NewService return Service which distributes collections sync tasks across cluster instances:
+//every collection has an owner instance (see cluster.GetOwner) which runs its sync tasks
 func NewService(ctx context.Context, sources *viper.Viper, destinationsService *destinations.Service,
-	metaStorage meta.Storage, monitorKeeper storages.MonitorKeeper, poolSize int) (*Service, error) {
+	metaStorage meta.Storage, monitorKeeper storages.MonitorKeeper, clusterManager cluster.Manager, serverName, previewDir string, poolSize int) (*Service, error) {
 
 	service := &Service{
 		ctx:     ctx,
@@ -57,23 +67,32 @@ func NewService(ctx context.Context, sources *viper.Viper, destinationsService *
 		destinationsService: destinationsService,
 		metaStorage:         metaStorage,
 		monitorKeeper:       monitorKeeper,
-	}
-
-	if sources == nil {
-		logging.Warnf("Sources aren't configured")
-		return service, nil
+		clusterManager:      clusterManager,
+		serverName:          serverName,
+		previewDir:          previewDir,
 	}
 
 	if metaStorage.Type() == meta.DummyType {
+		if sources == nil {
+			logging.Warnf("Sources aren't configured")
+			return service, nil
+		}
 		return nil, errors.New("Meta storage is required")
 	}
 
+	//sources might be created later with Update (e.g. from declarative state) even if they aren't configured
 	pool, err := ants.NewPoolWithFunc(poolSize, service.syncCollection)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating goroutines pool: %v", err)
 	}
 	service.pool = pool
 	defer service.startMonitoring()
+	defer clusterManager.WatchSyncTasks(service.syncTaskHandler)
+
+	if sources == nil {
+		logging.Warnf("Sources aren't configured")
+		return service, nil
+	}
 
 	sc := map[string]drivers.SourceConfig{}
 	if err := sources.Unmarshal(&sc); err != nil {
@@ -92,41 +111,150 @@ func NewService(ctx context.Context, sources *viper.Viper, destinationsService *
 
 func (s *Service) init(sc map[string]drivers.SourceConfig) {
 	for name, sourceConfig := range sc {
-
-		driverPerCollection, err := drivers.Create(s.ctx, name, &sourceConfig)
+		unit, err := s.create(name, sourceConfig)
 		if err != nil {
-			logging.Errorf("[%s] Error initializing source of type %s: %v", name, sourceConfig.Type, err)
+			logging.Errorf("[%s] %v", name, err)
 			continue
 		}
 
 		s.Lock()
-		s.sources[name] = &Unit{
-			DriverPerCollection: driverPerCollection,
-			DestinationIds:      sourceConfig.Destinations,
-		}
+		s.sources[name] = unit
 		s.Unlock()
 
 		logging.Infof("[%s] source has been initialized!", name)
+	}
+}
+
+//Update close and remove sources which don't exist in sc or have been changed and create new and changed sources
+//return err if sources can't be created without meta storage
+func (s *Service) Update(sc map[string]drivers.SourceConfig) error {
+	if s.pool == nil {
+		if len(sc) == 0 {
+			return nil
+		}
+		return errors.New("Sources can't be updated: meta storage is required")
+	}
+
+	changed := map[string]drivers.SourceConfig{}
+	s.Lock()
+	for name, unit := range s.sources {
+		sourceConfig, ok := sc[name]
+		if ok && unit.hash == getHash(name, sourceConfig) {
+			continue
+		}
 
+		delete(s.sources, name)
+		unit.Close(name)
+		logging.Infof("[%s] source has been removed!", name)
 	}
+	for name, sourceConfig := range sc {
+		if _, ok := s.sources[name]; !ok {
+			changed[name] = sourceConfig
+		}
+	}
+	s.Unlock()
+
+	s.init(changed)
+
+	return nil
 }
 
-//startMonitoring run goroutine for setting pool size metrics every 20 seconds
-func (s *Service) startMonitoring() {
-	safego.RunWithRestart(func() {
-		for {
-			if s.closed {
-				break
+//GetConfigs return configurations of all initialized sources
+func (s *Service) GetConfigs() map[string]drivers.SourceConfig {
+	s.RLock()
+	defer s.RUnlock()
+
+	configs := map[string]drivers.SourceConfig{}
+	for name, unit := range s.sources {
+		configs[name] = unit.config
+	}
+
+	return configs
+}
+
+//create return initialized source Unit with drivers per collection
+func (s *Service) create(name string, sourceConfig drivers.SourceConfig) (*Unit, error) {
+	driverPerCollection, err := drivers.Create(s.ctx, name, &sourceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing source of type %s: %v", sourceConfig.Type, err)
+	}
+
+	transformerPerCollection, err := createTransformers(&sourceConfig)
+	if err != nil {
+		for _, driver := range driverPerCollection {
+			if err := driver.Close(); err != nil {
+				logging.Errorf("[%s] Error closing driver: %v", name, err)
 			}
+		}
+		return nil, fmt.Errorf("Error initializing source transformations: %v", err)
+	}
 
-			metrics.RunningSourcesGoroutines(s.pool.Running())
-			metrics.FreeSourcesGoroutines(s.pool.Free())
+	for _, driver := range driverPerCollection {
+		if statefulDriver, ok := driver.(drivers.StatefulDriver); ok {
+			statefulDriver.SetStateStorage(name, s.metaStorage)
+		}
+	}
+
+	return &Unit{
+		DriverPerCollection:      driverPerCollection,
+		TransformerPerCollection: transformerPerCollection,
+		DestinationIds:           sourceConfig.Destinations,
+		Parallelism:              sourceConfig.Parallelism,
+		LimitsPerCollection:      createLimits(&sourceConfig),
+		Notifications:            sourceConfig.Notifications,
+		config:                   withStringKeys(sourceConfig),
+		hash:                     getHash(name, sourceConfig),
+	}, nil
+}
 
-			time.Sleep(20 * time.Second)
+//createTransformers return compiled transformations per collection
+func createTransformers(sourceConfig *drivers.SourceConfig) (map[string]*transform.Transformer, error) {
+	collections, err := drivers.ParseCollections(sourceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	transformerPerCollection := map[string]*transform.Transformer{}
+	for _, collection := range collections {
+		transformer, err := transform.NewTransformer(collection.Transform)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] collection: %v", collection.Name, err)
+		}
+		if transformer != nil {
+			transformerPerCollection[collection.Name] = transformer
+		}
+	}
+
+	return transformerPerCollection, nil
+}
+
+//createLimits return sync run limits per collection
+func createLimits(sourceConfig *drivers.SourceConfig) map[string]*SyncLimits {
+	limitsPerCollection := map[string]*SyncLimits{}
+	//collections have been already parsed by drivers.Create
+	collections, _ := drivers.ParseCollections(sourceConfig)
+	for _, collection := range collections {
+		if collection.MaxRowsPerRun > 0 || collection.MaxBytesPerRun > 0 {
+			limitsPerCollection[collection.Name] = &SyncLimits{MaxRows: collection.MaxRowsPerRun, MaxBytes: collection.MaxBytesPerRun}
 		}
+	}
+
+	return limitsPerCollection
+}
+
+//startMonitoring schedule setting pool size metrics every 20 seconds
+func (s *Service) startMonitoring() {
+	s.monitoringJob = scheduler.Add("sources_monitoring", scheduler.Every(20*time.Second), func() error {
+		metrics.RunningSourcesGoroutines(s.pool.Running())
+		metrics.FreeSourcesGoroutines(s.pool.Free())
+
+		return nil
 	})
 }
 
+//Sync run sync tasks of all source collections
+//every collection sync task is run on the owner instance or locally if owner is the current instance
+//or the owner hasn't taken the task (e.g. owner is stale or down)
 func (s *Service) Sync(sourceId string) (multiErr error) {
 	s.RLock()
 	sourceUnit, ok := s.sources[sourceId]
@@ -136,6 +264,172 @@ func (s *Service) Sync(sourceId string) (multiErr error) {
 		return errors.New("Source doesn't exist")
 	}
 
+	instances, err := s.clusterManager.GetInstances()
+	if err != nil {
+		logging.Errorf("[%s] Error getting cluster instances: %v. Sync tasks will be run locally", sourceId, err)
+	}
+
+	for collection := range sourceUnit.DriverPerCollection {
+		owner := cluster.GetOwner(instances, sourceId+"_"+collection)
+		if owner != "" && owner != s.serverName {
+			err := s.clusterManager.PushSyncTask(owner, sourceId, collection)
+			if err == nil {
+				logging.Infof("[%s_%s] Sync task has been sent to instance [%s]", sourceId, collection, owner)
+				continue
+			}
+
+			logging.Errorf("[%s_%s] Error sending sync task to instance [%s]: %v. Sync task will be run locally", sourceId, collection, owner, err)
+		}
+
+		if err := s.syncLocally(sourceId, collection, meta.TriggeredByApi, ""); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+//DryRun run sync tasks of all source collections in the current instance with writing results into
+//NDJSON files (DryRunFile) or _preview tables (DryRunTable) instead of production tables
+func (s *Service) DryRun(sourceId, mode string) (multiErr error) {
+	if err := ValidateDryRunMode(mode); err != nil {
+		return err
+	}
+
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
+	if !ok {
+		return errors.New("Source doesn't exist")
+	}
+
+	for collection := range sourceUnit.DriverPerCollection {
+		if err := s.syncLocally(sourceId, collection, meta.TriggeredByDryRun, mode); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+//Reset delete collections state (intervals signatures and stateful drivers state) and run full re-sync in the current instance
+//collection is optional (all source collections if empty)
+//if dropTables is true collection tables are dropped in all source destinations before re-sync (e.g. after upstream schema changes)
+func (s *Service) Reset(sourceId, collection string, dropTables bool) (multiErr error) {
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
+	if !ok {
+		return errors.New("Source doesn't exist")
+	}
+
+	var collections []string
+	if collection != "" {
+		if _, ok := sourceUnit.DriverPerCollection[collection]; !ok {
+			return fmt.Errorf("Collection [%s] doesn't exist in source [%s]", collection, sourceId)
+		}
+		collections = append(collections, collection)
+	} else {
+		for collection := range sourceUnit.DriverPerCollection {
+			collections = append(collections, collection)
+		}
+	}
+
+	//check all destinations before any changes
+	var droppers []storages.TableDropper
+	if dropTables {
+		for _, destinationId := range sourceUnit.DestinationIds {
+			storageProxy, ok := s.destinationsService.GetStorageById(destinationId)
+			if !ok {
+				return fmt.Errorf("Destination [%s] doesn't exist", destinationId)
+			}
+			storage, ok := storageProxy.Get()
+			if !ok {
+				return fmt.Errorf("Destination [%s] isn't initialized", destinationId)
+			}
+			dropper, ok := storage.(storages.TableDropper)
+			if !ok {
+				return fmt.Errorf("Destination [%s] of type [%s] doesn't support tables dropping", destinationId, storage.Type())
+			}
+			droppers = append(droppers, dropper)
+		}
+	}
+
+	for _, collection := range collections {
+		if err := s.resetCollection(sourceId, collection, sourceUnit.DriverPerCollection[collection], droppers); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+
+		if err := s.syncLocally(sourceId, collection, meta.TriggeredByReset, ""); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+//resetCollection lock collection (wait for the running sync task) and delete collection state and tables
+func (s *Service) resetCollection(sourceId, collection string, driver drivers.Driver, droppers []storages.TableDropper) error {
+	collectionLock, err := s.monitorKeeper.Lock(sourceId, collection)
+	if err != nil {
+		return fmt.Errorf("Error locking [%s] source [%s] collection: %v", sourceId, collection, err)
+	}
+	defer s.monitorKeeper.Unlock(collectionLock)
+
+	if err := s.metaStorage.DeleteSignatures(sourceId, collectionMetaKey(collection, driver)); err != nil {
+		return fmt.Errorf("Error deleting [%s] source [%s] collection signatures: %v", sourceId, collection, err)
+	}
+
+	if statefulDriver, ok := driver.(drivers.StatefulDriver); ok {
+		if err := s.metaStorage.DeleteSignatures(sourceId, statefulDriver.StateKey()); err != nil {
+			return fmt.Errorf("Error deleting [%s] source [%s] collection driver state: %v", sourceId, collection, err)
+		}
+	}
+
+	for _, dropper := range droppers {
+		if err := dropper.DropTable(driver.GetCollectionTable()); err != nil {
+			return fmt.Errorf("Error dropping [%s] source [%s] collection table [%s]: %v", sourceId, collection, driver.GetCollectionTable(), err)
+		}
+	}
+
+	logging.Infof("[%s_%s] Collection state has been reset (tables dropped: %t)", sourceId, collection, len(droppers) > 0)
+	return nil
+}
+
+//syncTaskHandler run sync task which was sent by another instance
+func (s *Service) syncTaskHandler(sourceId, collection string) {
+	logging.Infof("[%s_%s] Sync task has been received", sourceId, collection)
+	go func() {
+		if err := s.syncLocally(sourceId, collection, meta.TriggeredByCluster, ""); err != nil {
+			logging.Errorf("[%s_%s] Error running received sync task: %v", sourceId, collection, err)
+		}
+	}()
+}
+
+//syncLocally lock collection and run sync task in the current instance goroutines pool
+//triggeredBy is saved in the sync task history
+//dryRun is empty or dry run mode (see SyncTask)
+func (s *Service) syncLocally(sourceId, collection, triggeredBy, dryRun string) error {
+	if s.draining {
+		return errors.New("Server is draining: new sync tasks aren't accepted")
+	}
+
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
+	if !ok {
+		return errors.New("Source doesn't exist")
+	}
+
+	driver, ok := sourceUnit.DriverPerCollection[collection]
+	if !ok {
+		return fmt.Errorf("Collection [%s] doesn't exist in source [%s]", collection, sourceId)
+	}
+
 	var destinationStorages []events.Storage
 	for _, destinationId := range sourceUnit.DestinationIds {
 		storageProxy, ok := s.destinationsService.GetStorageById(destinationId)
@@ -156,31 +450,36 @@ func (s *Service) Sync(sourceId string) (multiErr error) {
 		return errors.New("Empty destinations")
 	}
 
-	for collection, driver := range sourceUnit.DriverPerCollection {
-		identifier := sourceId + "_" + collection
+	identifier := sourceId + "_" + collection
 
-		collectionLock, err := s.monitorKeeper.Lock(sourceId, collection)
-		if err != nil {
-			multiErr = multierror.Append(multiErr, fmt.Errorf("Error locking [%s] source [%s] collection: %v", sourceId, collection, err))
-			continue
-		}
+	collectionLock, err := s.monitorKeeper.Lock(sourceId, collection)
+	if err != nil {
+		return fmt.Errorf("Error locking [%s] source [%s] collection: %v", sourceId, collection, err)
+	}
 
-		err = s.pool.Invoke(SyncTask{
-			sourceId:     sourceId,
-			collection:   collection,
-			identifier:   identifier,
-			driver:       driver,
-			metaStorage:  s.metaStorage,
-			destinations: destinationStorages,
-			lock:         collectionLock,
-		})
-		if err != nil {
-			multiErr = multierror.Append(multiErr, fmt.Errorf("Error running sync task goroutine [%s] source [%s] collection: %v", sourceId, collection, err))
-			continue
-		}
+	err = s.pool.Invoke(SyncTask{
+		sourceId:      sourceId,
+		collection:    collection,
+		identifier:    identifier,
+		triggeredBy:   triggeredBy,
+		instance:      s.serverName,
+		driver:        driver,
+		transformer:   sourceUnit.TransformerPerCollection[collection],
+		metaStorage:   s.metaStorage,
+		destinations:  destinationStorages,
+		dryRun:        dryRun,
+		previewDir:    s.previewDir,
+		parallelism:   sourceUnit.Parallelism,
+		limits:        sourceUnit.LimitsPerCollection[collection],
+		notifications: sourceUnit.Notifications,
+		lock:          collectionLock,
+	})
+	if err != nil {
+		s.monitorKeeper.Unlock(collectionLock)
+		return fmt.Errorf("Error running sync task goroutine [%s] source [%s] collection: %v", sourceId, collection, err)
 	}
 
-	return
+	return nil
 }
 
 //GetStatus return status per collection
@@ -206,7 +505,43 @@ func (s *Service) GetStatus(sourceId string) (map[string]string, error) {
 	return statuses, nil
 }
 
-//GetStatus return logs per collection
+//GetTasks return page of sync tasks history (the newest first) and total tasks count per collection
+//if collection is empty - all source collections are returned
+func (s *Service) GetTasks(sourceId, collection string, offset, limit int) (map[string]*CollectionTasks, error) {
+	s.RLock()
+	sourceUnit, ok := s.sources[sourceId]
+	s.RUnlock()
+
+	if !ok {
+		return nil, errors.New("Source doesn't exist")
+	}
+
+	var collections []string
+	if collection != "" {
+		if _, ok := sourceUnit.DriverPerCollection[collection]; !ok {
+			return nil, fmt.Errorf("Collection [%s] doesn't exist in source [%s]", collection, sourceId)
+		}
+		collections = append(collections, collection)
+	} else {
+		for name := range sourceUnit.DriverPerCollection {
+			collections = append(collections, name)
+		}
+	}
+
+	tasksMap := map[string]*CollectionTasks{}
+	for _, name := range collections {
+		tasks, total, err := s.metaStorage.GetSyncTasks(sourceId, name, offset, limit)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting collection sync tasks: %v", err)
+		}
+
+		tasksMap[name] = &CollectionTasks{Tasks: tasks, Total: total}
+	}
+
+	return tasksMap, nil
+}
+
+//GetLogs return logs per collection
 func (s *Service) GetLogs(sourceId string) (map[string]string, error) {
 	s.RLock()
 	sourceUnit, ok := s.sources[sourceId]
@@ -240,8 +575,24 @@ func (s *Service) syncCollection(i interface{}) {
 	synctTask.Sync()
 }
 
+//Drain stop accepting new sync tasks. Running tasks are finished (see RunningTasks)
+func (s *Service) Drain() {
+	s.draining = true
+}
+
+//RunningTasks return count of currently running sync tasks
+func (s *Service) RunningTasks() int {
+	if s.pool == nil {
+		return 0
+	}
+
+	return s.pool.Running()
+}
+
 func (s *Service) Close() error {
-	s.closed = true
+	if s.monitoringJob != nil {
+		s.monitoringJob.Stop()
+	}
 
 	if s.pool != nil {
 		s.pool.Release()
@@ -1,14 +1,19 @@
 package sources
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/jitsucom/eventnative/drivers"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/meta"
 	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/notifications"
 	"github.com/jitsucom/eventnative/storages"
 	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/jitsucom/eventnative/transform"
 	"github.com/jitsucom/eventnative/uuid"
+	"sync"
 	"time"
 )
 
@@ -16,13 +21,29 @@ type SyncTask struct {
 	sourceId   string
 	collection string
 
-	identifier string
+	identifier  string
+	triggeredBy string
+	instance    string
 
-	driver      drivers.Driver
+	driver drivers.Driver
+	//transformer is nil if collection transformation isn't configured
+	transformer *transform.Transformer
 	metaStorage meta.Storage
 
 	destinations []events.Storage
 
+	//dryRun is DryRunFile or DryRunTable if sync results mustn't be written into production tables
+	//in dry run mode all intervals are synced, signatures aren't saved and transactional drivers are rolled back
+	dryRun     string
+	previewDir string
+
+	//parallelism is a number of concurrently synced intervals
+	parallelism int
+	//limits is nil if sync run limits aren't configured
+	limits *SyncLimits
+	//notifications is nil if sync runs notifications aren't configured
+	notifications *notifications.SourceSyncConfig
+
 	lock storages.Lock
 }
 
@@ -32,15 +53,30 @@ func (st *SyncTask) Sync() {
 	strLogger := logging.NewSyncLogger(strWriter)
 	now := time.Now().UTC()
 
-	st.updateCollectionStatus(meta.StatusLoading, "Still Running..")
+	//dry run doesn't change the collection status
+	if st.dryRun == "" {
+		st.updateCollectionStatus(meta.StatusLoading, "Still Running..")
+	}
 
 	status := meta.StatusFailed
-	defer st.updateCollectionStatus(status, strWriter.String())
+	var rowsSynced int
+	var syncErr string
+	var intervalsSynced int
+	//warnings are included into the sync run notification
+	var warnings []string
+	defer func() {
+		if st.dryRun == "" {
+			st.updateCollectionStatus(status, strWriter.String())
+			st.notify(time.Since(start), status, rowsSynced, intervalsSynced, syncErr, warnings)
+		}
+		st.saveTask(start, status, rowsSynced, syncErr)
+	}()
 
 	logging.Infof("[%s] Running sync task type: [%s]", st.identifier, st.driver.Type())
 	strLogger.Infof("[%s] Running sync task type: [%s]", st.identifier, st.driver.Type())
 	intervals, err := st.driver.GetAllAvailableIntervals()
 	if err != nil {
+		syncErr = fmt.Sprintf("Error getting all available intervals: %v", err)
 		strLogger.Errorf("[%s] Error getting all available intervals: %v", st.identifier, err)
 		logging.Errorf("[%s] Error getting all available intervals: %v", st.identifier, err)
 		return
@@ -50,8 +86,14 @@ func (st *SyncTask) Sync() {
 
 	var intervalsToSync []*drivers.TimeInterval
 	for _, interval := range intervals {
+		if st.dryRun != "" {
+			intervalsToSync = append(intervalsToSync, interval)
+			continue
+		}
+
 		storedSignature, err := st.metaStorage.GetSignature(st.sourceId, st.getCollectionMetaKey(), interval.String())
 		if err != nil {
+			syncErr = fmt.Sprintf("Error getting interval [%s] signature: %v", interval.String(), err)
 			strLogger.Errorf("[%s] Error getting interval [%s] signature: %v", st.identifier, interval.String(), err)
 			logging.Errorf("[%s] Error getting interval [%s] signature: %v", st.identifier, interval.String(), err)
 			return
@@ -78,53 +120,266 @@ func (st *SyncTask) Sync() {
 	strLogger.Infof("[%s] Intervals to sync: [%d]", st.identifier, len(intervalsToSync))
 
 	collectionTable := st.driver.GetCollectionTable()
-	for _, intervalToSync := range intervalsToSync {
-		strLogger.Infof("[%s] Running [%s] synchronization", st.identifier, intervalToSync.String())
-
-		objects, err := st.driver.GetObjectsFor(intervalToSync)
+	destinationStorages := st.destinations
+	var preview *previewFile
+	switch st.dryRun {
+	case DryRunTable:
+		collectionTable += PreviewTableSuffix
+	case DryRunFile:
+		//objects are written only into the file
+		destinationStorages = nil
+		previewPath := PreviewFilePath(st.previewDir, st.sourceId, st.collection)
+		preview, err = newPreviewFile(previewPath)
 		if err != nil {
-			strLogger.Errorf("[%s] Error [%s] synchronization: %v", st.identifier, intervalToSync.String(), err)
-			logging.Errorf("[%s] Error [%s] synchronization: %v", st.identifier, intervalToSync.String(), err)
+			syncErr = err.Error()
+			strLogger.Errorf("[%s] %v", st.identifier, err)
+			logging.Errorf("[%s] %v", st.identifier, err)
 			return
 		}
+		defer func() {
+			if err := preview.Close(); err != nil {
+				logging.Errorf("[%s] %v", st.identifier, err)
+			}
+		}()
+		strLogger.Infof("[%s] Dry run objects will be written into [%s]", st.identifier, previewPath)
+	}
 
-		for _, object := range objects {
-			//enrich with values
-			object["src"] = "source"
-			object[timestamp.Key] = timestamp.NowUTC()
-			events.EnrichWithEventId(object, uuid.GetHash(object))
-			events.EnrichWithCollection(object, st.collection)
-			events.EnrichWithTimeInterval(object, intervalToSync)
-		}
-		for _, storage := range st.destinations {
-			rowsCount, err := storage.SyncStore(collectionTable, objects, intervalToSync.String())
-			if err != nil {
-				strLogger.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", st.identifier, rowsCount, storage.Name(), err)
-				logging.Errorf("[%s] Error storing %d source objects in [%s] destination: %v", st.identifier, rowsCount, storage.Name(), err)
-				metrics.ErrorSourceEvents(st.sourceId, storage.Name(), rowsCount)
-				metrics.ErrorObjects(st.sourceId, rowsCount)
-				return
+	parallelism := st.parallelism
+	//transactional drivers claim objects until commit: their intervals are synced sequentially
+	if _, transactional := st.driver.(drivers.TransactionalDriver); transactional || parallelism < 1 {
+		parallelism = 1
+	}
+
+	if parallelism > 1 {
+		strLogger.Infof("[%s] Intervals are synced concurrently by [%d] goroutines", st.identifier, parallelism)
+	}
+
+	committer := newIntervalsCommitter(intervalsToSync, func(interval *drivers.TimeInterval) {
+		st.commitInterval(interval, now, strLogger)
+	})
+	indexes := make(chan int)
+	//slots are acquired before dispatching so limits are checked with results of all the previous intervals
+	//(except the concurrently synced ones)
+	slots := make(chan struct{}, parallelism)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				//skip intervals which have been dispatched before the first error
+				if !committer.failed() {
+					rowsCount, bytesCount, err := st.syncIntervalSafely(intervalsToSync[index], collectionTable, destinationStorages, preview, strLogger)
+					committer.done(index, rowsCount, bytesCount, err)
+				}
+				<-slots
 			}
+		}()
+	}
 
-			metrics.SuccessSourceEvents(st.sourceId, storage.Name(), rowsCount)
-			metrics.SuccessObjects(st.sourceId, rowsCount)
+	//stop dispatching intervals after the first error or if sync run limits have been reached
+	dispatched := 0
+	for index := range intervalsToSync {
+		slots <- struct{}{}
+		if committer.failed() || st.limits.Exceeded(committer.synced()) {
+			break
 		}
+		indexes <- index
+		dispatched++
+	}
+	close(indexes)
+	wg.Wait()
 
-		if err := st.metaStorage.SaveSignature(st.sourceId, st.getCollectionMetaKey(), intervalToSync.String(), intervalToSync.CalculateSignatureFrom(now)); err != nil {
-			logging.SystemErrorf("Unable to save source [%s] collection [%s] signature: %v", st.sourceId, st.collection, err)
-		}
+	if !committer.failed() && dispatched < len(intervalsToSync) {
+		syncedRows, syncedBytes := committer.synced()
+		strLogger.Infof("[%s] Sync run limits have been reached: [%d] rows, [%d] bytes. [%d] intervals will be synced in the next runs",
+			st.identifier, syncedRows, syncedBytes, len(intervalsToSync)-dispatched)
+		logging.Infof("[%s] Sync run limits have been reached. [%d] intervals will be synced in the next runs", st.identifier, len(intervalsToSync)-dispatched)
+		warnings = append(warnings, fmt.Sprintf("Sync run limits have been reached: [%d] rows, [%d] bytes. [%d] intervals will be synced in the next runs",
+			syncedRows, syncedBytes, len(intervalsToSync)-dispatched))
+		committer.truncate(dispatched)
+	}
 
-		strLogger.Infof("[%s] Interval [%s] has been synchronized!", st.identifier, intervalToSync.String())
+	intervalsSynced = dispatched
+	rowsSynced, err = committer.result()
+	if err != nil {
+		syncErr = err.Error()
+		strLogger.Errorf("[%s] %v", st.identifier, err)
+		logging.Errorf("[%s] %v", st.identifier, err)
+		return
 	}
 
 	end := time.Now().Sub(start)
 	strLogger.Infof("[%s] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", st.identifier, end.Seconds(), end.Minutes())
-	logging.Infof("[%s] type: [%s] intervals: [%d] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", st.identifier, st.driver.Type(), len(intervalsToSync), end.Seconds(), end.Minutes())
+	logging.Infof("[%s] type: [%s] intervals: [%d] FINISHED SUCCESSFULLY in [%.2f] seconds (~ %.2f minutes)", st.identifier, st.driver.Type(), dispatched, end.Seconds(), end.Minutes())
 	status = meta.StatusOk
 }
 
+//notify send sync_finished lifecycle event and sync run summary or failure alert via notifications channels if they are configured
+func (st *SyncTask) notify(duration time.Duration, status string, rowsSynced, intervalsSynced int, syncErr string, warnings []string) {
+	notifications.Lifecycle(notifications.SyncFinished, map[string]interface{}{
+		"source_id":        st.sourceId,
+		"collection":       st.collection,
+		"status":           status,
+		"rows_synced":      rowsSynced,
+		"intervals_synced": intervalsSynced,
+		"duration_sec":     duration.Seconds(),
+		"triggered_by":     st.triggeredBy,
+		"error":            syncErr,
+		"warnings":         warnings,
+	})
+
+	if st.notifications == nil {
+		return
+	}
+
+	failed := status != meta.StatusOk
+	title := fmt.Sprintf("Source [%s] collection [%s] sync has been finished", st.sourceId, st.collection)
+	if failed {
+		title = fmt.Sprintf("Source [%s] collection [%s] sync has been failed", st.sourceId, st.collection)
+	}
+
+	text := fmt.Sprintf("status: %s, rows: %d, intervals: %d, duration: %.2f seconds, triggered by: %s",
+		status, rowsSynced, intervalsSynced, duration.Seconds(), st.triggeredBy)
+	if syncErr != "" {
+		text += "\nerror: " + syncErr
+	}
+	for _, warning := range warnings {
+		text += "\nwarning: " + warning
+	}
+
+	notifications.SourceSync(st.notifications, failed, title, text)
+}
+
+//syncIntervalSafely run syncInterval and return panic as an error
+func (st *SyncTask) syncIntervalSafely(interval *drivers.TimeInterval, collectionTable string, destinationStorages []events.Storage,
+	preview *previewFile, strLogger *logging.SyncLogger) (rowsCount int, bytesCount int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Error [%s] synchronization: panic: %v", interval.String(), r)
+		}
+	}()
+
+	return st.syncInterval(interval, collectionTable, destinationStorages, preview, strLogger)
+}
+
+//syncInterval get, transform and store interval objects into destinations (or preview) and commit transactional drivers
+//return stored objects count and size (only if bytes limit is configured)
+func (st *SyncTask) syncInterval(interval *drivers.TimeInterval, collectionTable string, destinationStorages []events.Storage,
+	preview *previewFile, strLogger *logging.SyncLogger) (int, int64, error) {
+	strLogger.Infof("[%s] Running [%s] synchronization", st.identifier, interval.String())
+
+	objects, err := st.driver.GetObjectsFor(interval)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Error [%s] synchronization: %v", interval.String(), err)
+	}
+
+	transactionalDriver, transactional := st.driver.(drivers.TransactionalDriver)
+	rollback := func() {
+		if transactional {
+			if err := transactionalDriver.Rollback(); err != nil {
+				logging.Errorf("[%s] Error rolling back [%s] synchronization: %v", st.identifier, interval.String(), err)
+			}
+		}
+	}
+
+	if st.transformer != nil {
+		objects, err = st.transformer.Transform(objects)
+		if err != nil {
+			rollback()
+			return 0, 0, fmt.Errorf("Error transforming [%s] objects: %v", interval.String(), err)
+		}
+	}
+
+	//transactional drivers objects are only appended
+	timeIntervalValue := interval.String()
+	if transactional {
+		timeIntervalValue = ""
+	}
+
+	for _, object := range objects {
+		//enrich with values
+		object["src"] = "source"
+		object[timestamp.Key] = timestamp.NowUTC()
+		events.EnrichWithEventId(object, uuid.GetHash(object))
+		events.EnrichWithCollection(object, st.collection)
+		events.EnrichWithTimeInterval(object, interval)
+	}
+	bytesCount := st.countBytes(objects)
+	if preview != nil {
+		if err := preview.Write(objects); err != nil {
+			rollback()
+			return 0, 0, err
+		}
+	}
+
+	for _, storage := range destinationStorages {
+		rowsCount, err := storage.SyncStore(collectionTable, objects, timeIntervalValue)
+		if err != nil {
+			rollback()
+			metrics.ErrorSourceEvents(st.sourceId, storage.Name(), rowsCount)
+			metrics.ErrorObjects(st.sourceId, rowsCount)
+			return 0, 0, fmt.Errorf("Error storing %d source objects in [%s] destination: %v", rowsCount, storage.Name(), err)
+		}
+
+		metrics.SuccessSourceEvents(st.sourceId, storage.Name(), rowsCount)
+		metrics.SuccessObjects(st.sourceId, rowsCount)
+	}
+
+	if st.dryRun != "" {
+		rollback()
+		strLogger.Infof("[%s] Interval [%s] has been written into dry run %s", st.identifier, interval.String(), st.dryRun)
+		return len(objects), bytesCount, nil
+	}
+
+	if transactional {
+		if err := transactionalDriver.Commit(); err != nil {
+			return 0, 0, fmt.Errorf("Error committing [%s] synchronization: %v", interval.String(), err)
+		}
+	}
+
+	return len(objects), bytesCount, nil
+}
+
+//countBytes return objects JSON size if bytes limit is configured
+func (st *SyncTask) countBytes(objects []map[string]interface{}) int64 {
+	if st.limits == nil || st.limits.MaxBytes <= 0 {
+		return 0
+	}
+
+	var bytesCount int64
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			logging.Warnf("[%s] Error marshalling object for counting size: %v", st.identifier, err)
+			continue
+		}
+		bytesCount += int64(len(b))
+	}
+
+	return bytesCount
+}
+
+//commitInterval save synced interval signature (except dry run)
+func (st *SyncTask) commitInterval(interval *drivers.TimeInterval, now time.Time, strLogger *logging.SyncLogger) {
+	if st.dryRun != "" {
+		return
+	}
+
+	if err := st.metaStorage.SaveSignature(st.sourceId, st.getCollectionMetaKey(), interval.String(), interval.CalculateSignatureFrom(now)); err != nil {
+		logging.SystemErrorf("Unable to save source [%s] collection [%s] signature: %v", st.sourceId, st.collection, err)
+	}
+
+	strLogger.Infof("[%s] Interval [%s] has been synchronized!", st.identifier, interval.String())
+}
+
 func (st *SyncTask) getCollectionMetaKey() string {
-	return st.collection + "_" + st.driver.GetCollectionTable()
+	return collectionMetaKey(st.collection, st.driver)
+}
+
+//collectionMetaKey return collection key of intervals signatures in meta storage
+func collectionMetaKey(collection string, driver drivers.Driver) string {
+	return collection + "_" + driver.GetCollectionTable()
 }
 
 func (st *SyncTask) updateCollectionStatus(status, logs string) {
@@ -135,3 +390,19 @@ func (st *SyncTask) updateCollectionStatus(status, logs string) {
 		logging.SystemErrorf("Unable to update source [%s] collection [%s] log in storage: %v", st.sourceId, st.collection, err)
 	}
 }
+
+//saveTask save finished sync task into the collection sync tasks history
+func (st *SyncTask) saveTask(start time.Time, status string, rowsSynced int, syncErr string) {
+	task := &meta.SyncTask{
+		Status:      status,
+		StartedAt:   timestamp.ToISOFormat(start.UTC()),
+		FinishedAt:  timestamp.NowUTC(),
+		RowsSynced:  rowsSynced,
+		Error:       syncErr,
+		TriggeredBy: st.triggeredBy,
+		Instance:    st.instance,
+	}
+	if err := st.metaStorage.SaveSyncTask(st.sourceId, st.collection, task); err != nil {
+		logging.SystemErrorf("Unable to save source [%s] collection [%s] sync task in storage: %v", st.sourceId, st.collection, err)
+	}
+}
@@ -1,8 +1,81 @@
 package sources
 
-import "github.com/jitsucom/eventnative/drivers"
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/maputils"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/notifications"
+	"github.com/jitsucom/eventnative/resources"
+	"github.com/jitsucom/eventnative/transform"
+)
 
 type Unit struct {
 	DriverPerCollection map[string]drivers.Driver
-	DestinationIds      []string
+	//TransformerPerCollection contains only collections with configured transformation
+	TransformerPerCollection map[string]*transform.Transformer
+	DestinationIds           []string
+	//Parallelism is a number of concurrently synced intervals per collection
+	Parallelism int
+	//LimitsPerCollection contains only collections with configured limits
+	LimitsPerCollection map[string]*SyncLimits
+	//Notifications is nil if sync runs notifications aren't configured
+	Notifications *notifications.SourceSyncConfig
+
+	config drivers.SourceConfig
+	hash   string
+}
+
+//Close close all collections drivers
+func (u *Unit) Close(name string) {
+	for _, driver := range u.DriverPerCollection {
+		if err := driver.Close(); err != nil {
+			logging.Errorf("[%s] Error closing driver: %v", name, err)
+		}
+	}
+}
+
+//getHash return source configuration hash for changes detection
+func getHash(name string, sourceConfig drivers.SourceConfig) string {
+	b, err := json.Marshal(withStringKeys(sourceConfig))
+	if err != nil {
+		logging.Errorf("Error getting hash(marshalling) from [%s] source: %v", name, err)
+		return ""
+	}
+
+	return resources.GetHash(b)
+}
+
+//SyncLimits are max rows and bytes synced per collection sync run. 0 means without limit
+type SyncLimits struct {
+	MaxRows  int
+	MaxBytes int64
+}
+
+//Exceeded return true if rows or bytes limit has been reached
+func (sl *SyncLimits) Exceeded(rows int, bytes int64) bool {
+	if sl == nil {
+		return false
+	}
+
+	return (sl.MaxRows > 0 && rows >= sl.MaxRows) || (sl.MaxBytes > 0 && bytes >= sl.MaxBytes)
+}
+
+//CollectionTasks is a page of collection sync tasks history with total tasks count
+type CollectionTasks struct {
+	Tasks []meta.SyncTask
+	Total int
+}
+
+//withStringKeys return source configuration with YAML collections converted for JSON serialization
+func withStringKeys(sourceConfig drivers.SourceConfig) drivers.SourceConfig {
+	if collections, ok := maputils.StringKeys(sourceConfig.Collections).([]interface{}); ok {
+		sourceConfig.Collections = collections
+	}
+	if config, ok := maputils.StringKeys(sourceConfig.Config).(map[string]interface{}); ok {
+		sourceConfig.Config = config
+	}
+
+	return sourceConfig
 }
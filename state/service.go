@@ -0,0 +1,373 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jitsucom/eventnative/audit"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/sources"
+	"github.com/jitsucom/eventnative/storages"
+	"sync"
+	"time"
+)
+
+const (
+	//AnyVersion is an expected version which overwrites the state regardless of its current version
+	AnyVersion int64 = -1
+
+	reloadEvery = 30 * time.Second
+)
+
+var instance *Service
+
+//State is a declarative configuration document: tokens, destinations, sources and destinations mappings.
+//Saved state overrides configuration files on all cluster nodes
+type State struct {
+	//Version is incremented on every saving. 0 means that state hasn't been saved (configuration files are used)
+	Version      int64                                 `json:"version"`
+	Tokens       []authorization.Token                 `json:"tokens"`
+	Destinations map[string]storages.DestinationConfig `json:"destinations"`
+	Sources      map[string]drivers.SourceConfig       `json:"sources"`
+	//Mappings override data_layout.mappings by destination id
+	Mappings map[string]*schema.Mapping `json:"mappings,omitempty"`
+}
+
+//Service keeps the applied state version, saves states in meta storage with optimistic concurrency
+//and applies states saved by other cluster nodes after reloading
+type Service struct {
+	sync.Mutex
+
+	metaStorage          meta.Storage
+	authorizationService *authorization.Service
+	destinationsService  *destinations.Service
+	sourcesService       *sources.Service
+	//local is true without meta storage: state is kept only in memory of the current node
+	local bool
+
+	//current is nil if state hasn't been saved
+	current *State
+	job     *scheduler.Job
+}
+
+//Init load and apply saved state (if any) and schedule its reloading
+func Init(metaStorage meta.Storage, authorizationService *authorization.Service, destinationsService *destinations.Service,
+	sourcesService *sources.Service) (*Service, error) {
+	service := &Service{
+		metaStorage:          metaStorage,
+		authorizationService: authorizationService,
+		destinationsService:  destinationsService,
+		sourcesService:       sourcesService,
+		local:                metaStorage == nil || metaStorage.Type() == meta.DummyType,
+	}
+
+	if !service.local {
+		if err := service.reload(); err != nil {
+			return nil, fmt.Errorf("Error loading state from meta storage: %v", err)
+		}
+		service.job = scheduler.Add("state_reload", scheduler.Every(reloadEvery), service.reload)
+	}
+
+	instance = service
+	return service, nil
+}
+
+//Get return the current state of the global service
+func Get() (*State, error) {
+	if instance == nil {
+		return nil, errors.New("State service isn't initialized")
+	}
+
+	return instance.Get(), nil
+}
+
+//Redact return the state as JSON object with tokens secrets and destinations credentials replaced with audit.Redacted
+func Redact(state *State) (interface{}, error) {
+	object, err := toObject(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Redact(object), nil
+}
+
+//Put save and apply the state with the global service
+func Put(state *State, expectedVersion int64) (*State, error) {
+	if instance == nil {
+		return nil, errors.New("State service isn't initialized")
+	}
+
+	return instance.Put(state, expectedVersion)
+}
+
+//Get return saved state or the current configuration with version 0 if state hasn't been saved
+func (s *Service) Get() *State {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.current != nil {
+		return s.current
+	}
+
+	return &State{
+		Tokens:       s.authorizationService.GetTokens(),
+		Destinations: s.destinationsService.GetConfigs(),
+		Sources:      s.sourcesService.GetConfigs(),
+	}
+}
+
+//Put validate, save and apply the state if the current version is equal to expectedVersion (or expectedVersion is AnyVersion)
+//audit.Redacted values (e.g. from the redacted GET response) are replaced with the current state ones
+//return saved state with the new version, meta.ErrStateVersionConflict or validation error
+func (s *Service) Put(state *State, expectedVersion int64) (*State, error) {
+	state, err := restoreRedacted(state, s.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validate(state); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	currentVersion := int64(0)
+	if s.current != nil {
+		currentVersion = s.current.Version
+	}
+	if expectedVersion == AnyVersion {
+		expectedVersion = currentVersion
+		if !s.local {
+			_, storedVersion, err := s.metaStorage.GetState()
+			if err != nil {
+				return nil, fmt.Errorf("Error getting state from meta storage: %v", err)
+			}
+			expectedVersion = storedVersion
+		}
+	}
+
+	saved := *state
+	saved.Version = 0
+	if s.local {
+		if expectedVersion != currentVersion {
+			return nil, meta.ErrStateVersionConflict
+		}
+		saved.Version = currentVersion + 1
+	} else {
+		b, err := json.Marshal(saved)
+		if err != nil {
+			return nil, fmt.Errorf("Error marshalling state: %v", err)
+		}
+		version, err := s.metaStorage.SaveState(string(b), expectedVersion)
+		if err != nil {
+			return nil, err
+		}
+		saved.Version = version
+	}
+
+	s.apply(&saved)
+	return &saved, nil
+}
+
+//validate return err if state can't be applied: invalid tokens, destinations and sources configurations
+//or references to unknown tokens and destinations
+func (s *Service) validate(state *State) error {
+	if err := authorization.ValidateTokens(state.Tokens); err != nil {
+		return err
+	}
+	identities := map[string]bool{}
+	for _, token := range state.Tokens {
+		identities[token.Id] = true
+		identities[token.ClientSecret] = true
+		identities[token.ServerSecret] = true
+		for _, previousSecret := range token.PreviousServerSecrets {
+			identities[previousSecret] = true
+		}
+	}
+
+	for destinationId := range state.Mappings {
+		if _, ok := state.Destinations[destinationId]; !ok {
+			return fmt.Errorf("mappings of unknown destination [%s]", destinationId)
+		}
+	}
+	for name, destination := range withMappings(state.Destinations, state.Mappings) {
+		if err := storages.Validate(name, destination); err != nil {
+			return fmt.Errorf("destination [%s]: %v", name, err)
+		}
+		for _, token := range destination.OnlyTokens {
+			if token == "" || !identities[token] {
+				return fmt.Errorf("destination [%s]: unknown token [%s] in only_tokens", name, token)
+			}
+		}
+	}
+
+	if len(state.Sources) > 0 && s.local {
+		return errors.New("sources require meta storage")
+	}
+	for name, source := range state.Sources {
+		if err := drivers.Validate(name, &source); err != nil {
+			return fmt.Errorf("source [%s]: %v", name, err)
+		}
+		for _, destinationId := range source.Destinations {
+			if _, ok := state.Destinations[destinationId]; !ok {
+				return fmt.Errorf("source [%s]: unknown destination [%s]", name, destinationId)
+			}
+		}
+	}
+
+	return nil
+}
+
+//apply replace tokens, destinations and sources with state ones (must be called with lock)
+//tokens are applied first because destinations are created per token
+func (s *Service) apply(state *State) {
+	s.authorizationService.SetTokens(state.Tokens)
+	s.destinationsService.Update(withMappings(state.Destinations, state.Mappings))
+	if err := s.sourcesService.Update(state.Sources); err != nil {
+		logging.Errorf("Error applying state [%d] sources: %v", state.Version, err)
+	}
+
+	s.current = state
+	logging.Infof("State version [%d] has been applied: %d tokens, %d destinations, %d sources", state.Version, len(state.Tokens), len(state.Destinations), len(state.Sources))
+}
+
+//reload apply state from meta storage if it has been changed by another node
+func (s *Service) reload() error {
+	payload, version, err := s.metaStorage.GetState()
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if payload == "" || (s.current != nil && s.current.Version == version) {
+		return nil
+	}
+
+	state := &State{}
+	if err := json.Unmarshal([]byte(payload), state); err != nil {
+		return fmt.Errorf("Error unmarshalling state version [%d]: %v", version, err)
+	}
+	state.Version = version
+
+	s.apply(state)
+	return nil
+}
+
+//Close stop reloading
+func (s *Service) Close() error {
+	if s.job != nil {
+		s.job.Stop()
+	}
+
+	return nil
+}
+
+//withMappings return destinations copy with data_layout.mappings overridden by mappings
+func withMappings(destinations map[string]storages.DestinationConfig, mappings map[string]*schema.Mapping) map[string]storages.DestinationConfig {
+	result := map[string]storages.DestinationConfig{}
+	for name, destination := range destinations {
+		if mapping, ok := mappings[name]; ok {
+			dataLayout := &storages.DataLayout{}
+			if destination.DataLayout != nil {
+				*dataLayout = *destination.DataLayout
+			}
+			dataLayout.Mappings = mapping
+			destination.DataLayout = dataLayout
+		}
+		result[name] = destination
+	}
+
+	return result
+}
+
+//restoreRedacted return state copy where audit.Redacted values are replaced with the current state values
+//by the same path (array items with id e.g. tokens are matched by id)
+func restoreRedacted(state, current *State) (*State, error) {
+	object, err := toObject(state)
+	if err != nil {
+		return nil, err
+	}
+	currentObject, err := toObject(current)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(restore(object, currentObject))
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling restored state: %v", err)
+	}
+	restored := &State{}
+	if err := json.Unmarshal(b, restored); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling restored state: %v", err)
+	}
+
+	return restored, nil
+}
+
+//restore return value with audit.Redacted strings replaced with current values (if they exist)
+func restore(value, current interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		currentObject, _ := current.(map[string]interface{})
+		for key, item := range v {
+			v[key] = restore(item, currentObject[key])
+		}
+		return v
+	case []interface{}:
+		currentArray, _ := current.([]interface{})
+		for i, item := range v {
+			v[i] = restore(item, findItem(currentArray, item, i))
+		}
+		return v
+	case string:
+		if v == audit.Redacted && current != nil {
+			return current
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+//findItem return array item with the same id as item has or item with the same index
+func findItem(array []interface{}, item interface{}, index int) interface{} {
+	if object, ok := item.(map[string]interface{}); ok {
+		if id, ok := object["id"]; ok {
+			for _, currentItem := range array {
+				if currentObject, ok := currentItem.(map[string]interface{}); ok && currentObject["id"] == id {
+					return currentItem
+				}
+			}
+			return nil
+		}
+	}
+
+	if index < len(array) {
+		return array[index]
+	}
+
+	return nil
+}
+
+//toObject return state as JSON object
+func toObject(state *State) (map[string]interface{}, error) {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling state: %v", err)
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling state: %v", err)
+	}
+
+	return object, nil
+}
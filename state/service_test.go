@@ -0,0 +1,202 @@
+package state
+
+import (
+	"github.com/jitsucom/eventnative/audit"
+	"github.com/jitsucom/eventnative/authorization"
+	"github.com/jitsucom/eventnative/destinations"
+	"github.com/jitsucom/eventnative/drivers"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/sources"
+	"github.com/jitsucom/eventnative/storages"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+//stateStorage is a shared meta storage with a state saved by another node
+type stateStorage struct {
+	meta.Dummy
+	payload string
+	version int64
+}
+
+func (ss *stateStorage) GetState() (string, int64, error) {
+	return ss.payload, ss.version, nil
+}
+
+func (ss *stateStorage) SaveState(payload string, expectedVersion int64) (int64, error) {
+	if expectedVersion != ss.version {
+		return 0, meta.ErrStateVersionConflict
+	}
+	ss.payload = payload
+	ss.version++
+	return ss.version, nil
+}
+
+func (ss *stateStorage) Type() string {
+	return meta.RedisType
+}
+
+func newTestService(t *testing.T, metaStorage meta.Storage) *Service {
+	viper.Set("server.auth_reload_sec", 1)
+	viper.Set("server.auth", []string{"configured_secret"})
+	authorizationService, err := authorization.NewService()
+	require.NoError(t, err)
+
+	service, err := Init(metaStorage, authorizationService, destinations.NewTestService(destinations.TokenizedConsumers{},
+		destinations.TokenizedStorages{}, destinations.TokenizedIds{}), sources.NewTestService())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		service.Close()
+		instance = nil
+		viper.Reset()
+	})
+
+	return service
+}
+
+func TestStateVersions(t *testing.T) {
+	service := newTestService(t, &meta.Dummy{})
+
+	configured, err := Get()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), configured.Version)
+	require.Len(t, configured.Tokens, 1)
+	require.Equal(t, "configured_secret", configured.Tokens[0].ClientSecret)
+
+	saved, err := Put(&State{Tokens: []authorization.Token{{Id: "t1", ClientSecret: "client1"}}}, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), saved.Version)
+	require.Equal(t, "t1", service.authorizationService.GetTokenId("client1"))
+	require.Equal(t, "", service.authorizationService.GetTokenId("configured_secret"))
+
+	_, err = Put(&State{Tokens: []authorization.Token{{Id: "t2", ClientSecret: "client2"}}}, 0)
+	require.Equal(t, meta.ErrStateVersionConflict, err)
+
+	saved, err = Put(&State{Tokens: []authorization.Token{{Id: "t2", ClientSecret: "client2"}}}, AnyVersion)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), saved.Version)
+
+	current, err := Get()
+	require.NoError(t, err)
+	require.Equal(t, saved, current)
+}
+
+func TestStateReload(t *testing.T) {
+	storage := &stateStorage{payload: `{"tokens":[{"id":"t1","server_secret":"server1"}]}`, version: 3}
+	service := newTestService(t, storage)
+	require.Equal(t, "t1", service.authorizationService.GetTokenId("server1"))
+
+	_, err := service.Put(&State{Tokens: []authorization.Token{{Id: "t2", ServerSecret: "server2"}}}, 2)
+	require.Equal(t, meta.ErrStateVersionConflict, err)
+
+	saved, err := service.Put(&State{Tokens: []authorization.Token{{Id: "t2", ServerSecret: "server2"}}}, 3)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), saved.Version)
+
+	//saved by another node
+	storage.payload = `{"tokens":[{"id":"t3","server_secret":"server3"}]}`
+	storage.version = 5
+	require.NoError(t, service.reload())
+	require.Equal(t, "t3", service.authorizationService.GetTokenId("server3"))
+	require.Equal(t, int64(5), service.Get().Version)
+}
+
+func TestStateRedaction(t *testing.T) {
+	service := newTestService(t, &meta.Dummy{})
+	_, err := service.Put(&State{Tokens: []authorization.Token{
+		{Id: "t1", ServerSecret: "server1", PreviousServerSecrets: []string{"server0"}},
+		{Id: "t2", ClientSecret: "client2"},
+	}}, AnyVersion)
+	require.NoError(t, err)
+
+	redacted, err := Redact(service.Get())
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"id": "t1", "server_secret": audit.Redacted, "previous_server_secrets": []interface{}{audit.Redacted}},
+		map[string]interface{}{"id": "t2", "client_secret": audit.Redacted},
+	}, redacted.(map[string]interface{})["tokens"])
+
+	//redacted values are kept (tokens are matched by id), changed ones are saved
+	saved, err := service.Put(&State{Tokens: []authorization.Token{
+		{Id: "t2", ClientSecret: audit.Redacted, ServerSecret: "server2"},
+		{Id: "t1", ServerSecret: audit.Redacted, PreviousServerSecrets: []string{audit.Redacted}},
+		{Id: "t3", ClientSecret: "client3"},
+	}}, AnyVersion)
+	require.NoError(t, err)
+	require.Equal(t, []authorization.Token{
+		{Id: "t2", ClientSecret: "client2", ServerSecret: "server2"},
+		{Id: "t1", ServerSecret: "server1", PreviousServerSecrets: []string{"server0"}},
+		{Id: "t3", ClientSecret: "client3"},
+	}, saved.Tokens)
+	require.Equal(t, "t2", service.authorizationService.GetTokenId("client2"))
+}
+
+func TestStateValidation(t *testing.T) {
+	service := &Service{local: true}
+	tokens := []authorization.Token{{Id: "t1", ClientSecret: "client1"}}
+	postgres := storages.DestinationConfig{Type: storages.PostgresType, OnlyTokens: []string{"client1"}}
+
+	require.NoError(t, service.validate(&State{Tokens: tokens, Destinations: map[string]storages.DestinationConfig{"pg": postgres}}))
+	//only_tokens might contain previous server secrets
+	require.NoError(t, service.validate(&State{Tokens: []authorization.Token{{Id: "t1", ServerSecret: "server1", PreviousServerSecrets: []string{"server0"}}},
+		Destinations: map[string]storages.DestinationConfig{"pg": {Type: storages.PostgresType, OnlyTokens: []string{"server0"}}}}))
+
+	tests := []struct {
+		name          string
+		state         *State
+		expectedError string
+	}{
+		{
+			"token without secrets",
+			&State{Tokens: []authorization.Token{{Id: "t1"}}},
+			"token [0] t1: client_secret or server_secret is required",
+		},
+		{
+			"unknown only_tokens",
+			&State{Tokens: tokens, Destinations: map[string]storages.DestinationConfig{"pg": {Type: storages.PostgresType, OnlyTokens: []string{"client2"}}}},
+			"destination [pg]: unknown token [client2] in only_tokens",
+		},
+		{
+			"unknown only_tokens previous secret",
+			&State{Tokens: []authorization.Token{{Id: "t1", ServerSecret: "server1", PreviousServerSecrets: []string{"server0"}}},
+				Destinations: map[string]storages.DestinationConfig{"pg": {Type: storages.PostgresType, OnlyTokens: []string{"server2"}}}},
+			"destination [pg]: unknown token [server2] in only_tokens",
+		},
+		{
+			"mappings of unknown destination",
+			&State{Tokens: tokens, Mappings: map[string]*schema.Mapping{"bq": {}}},
+			"mappings of unknown destination [bq]",
+		},
+		{
+			"sources without meta storage",
+			&State{Tokens: tokens, Sources: map[string]drivers.SourceConfig{"source": {Type: "http"}}},
+			"sources require meta storage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.validate(tt.state)
+			require.Error(t, err)
+			require.Equal(t, tt.expectedError, err.Error())
+		})
+	}
+}
+
+func TestWithMappings(t *testing.T) {
+	keepUnmapped := false
+	mapping := &schema.Mapping{KeepUnmapped: &keepUnmapped}
+	dataLayout := &storages.DataLayout{TableNameTemplate: "events"}
+	configs := map[string]storages.DestinationConfig{
+		"pg": {Type: storages.PostgresType, DataLayout: dataLayout},
+		"ch": {Type: storages.ClickHouseType},
+	}
+
+	result := withMappings(configs, map[string]*schema.Mapping{"pg": mapping})
+	require.Equal(t, "events", result["pg"].DataLayout.TableNameTemplate)
+	require.Equal(t, mapping, result["pg"].DataLayout.Mappings)
+	require.Nil(t, result["ch"].DataLayout)
+	//configs aren't changed
+	require.Nil(t, dataLayout.Mappings)
+}
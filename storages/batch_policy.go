@@ -0,0 +1,147 @@
+package storages
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/events"
+	"sync"
+	"time"
+)
+
+//BatchConfig dto for deserialized destination batch flush policy:
+//log files are uploaded into the destination every interval_min minutes or earlier when not uploaded files
+//have max_rows events or max_bytes. Files are uploaded by workers concurrently
+//interval can't be shorter than log files rotation period (log.rotation_min)
+type BatchConfig struct {
+	IntervalMin int   `mapstructure:"interval_min" json:"interval_min,omitempty" yaml:"interval_min,omitempty"`
+	MaxRows     int   `mapstructure:"max_rows" json:"max_rows,omitempty" yaml:"max_rows,omitempty"`
+	MaxBytes    int64 `mapstructure:"max_bytes" json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+	Workers     int   `mapstructure:"workers" json:"workers,omitempty" yaml:"workers,omitempty"`
+}
+
+//Validate return err if config values are negative
+func (bc *BatchConfig) Validate() error {
+	if bc == nil {
+		return nil
+	}
+	if bc.IntervalMin < 0 || bc.MaxRows < 0 || bc.MaxBytes < 0 || bc.Workers < 0 {
+		return errors.New("batch values can't be negative")
+	}
+
+	return nil
+}
+
+//BatchPolicy decides when log files are flushed into the destination and keeps names of already uploaded files
+//nil BatchPolicy flushes files on every uploader run with one worker
+type BatchPolicy struct {
+	sync.Mutex
+
+	interval time.Duration
+	maxRows  int
+	maxBytes int64
+	workers  int
+
+	lastFlush time.Time
+	uploaded  map[string]bool
+}
+
+//NewBatchPolicy return BatchPolicy or nil if it isn't configured
+func NewBatchPolicy(config *BatchConfig) *BatchPolicy {
+	if config == nil {
+		return nil
+	}
+
+	workers := config.Workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	return &BatchPolicy{
+		interval:  time.Duration(config.IntervalMin) * time.Minute,
+		maxRows:   config.MaxRows,
+		maxBytes:  config.MaxBytes,
+		workers:   workers,
+		lastFlush: time.Now(),
+		uploaded:  map[string]bool{},
+	}
+}
+
+//ShouldFlush return true if the interval is expired since the last flush or pending rows/bytes limits are reached
+func (bp *BatchPolicy) ShouldFlush(pendingRows int, pendingBytes int64, now time.Time) bool {
+	if bp == nil {
+		return true
+	}
+
+	bp.Lock()
+	defer bp.Unlock()
+
+	return now.Sub(bp.lastFlush) >= bp.interval ||
+		(bp.maxRows > 0 && pendingRows >= bp.maxRows) ||
+		(bp.maxBytes > 0 && pendingBytes >= bp.maxBytes)
+}
+
+//Flushed reset the interval
+func (bp *BatchPolicy) Flushed(now time.Time) {
+	if bp == nil {
+		return
+	}
+
+	bp.Lock()
+	bp.lastFlush = now
+	bp.Unlock()
+}
+
+//IsUploaded return true if the file has been already uploaded into the destination (it is waiting for other destinations)
+func (bp *BatchPolicy) IsUploaded(fileName string) bool {
+	if bp == nil {
+		return false
+	}
+
+	bp.Lock()
+	defer bp.Unlock()
+
+	return bp.uploaded[fileName]
+}
+
+func (bp *BatchPolicy) MarkUploaded(fileName string) {
+	if bp == nil {
+		return
+	}
+
+	bp.Lock()
+	bp.uploaded[fileName] = true
+	bp.Unlock()
+}
+
+//Retain forget uploaded files which don't exist anymore (have been archived)
+func (bp *BatchPolicy) Retain(existingFiles map[string]bool) {
+	if bp == nil {
+		return
+	}
+
+	bp.Lock()
+	defer bp.Unlock()
+
+	for fileName := range bp.uploaded {
+		if !existingFiles[fileName] {
+			delete(bp.uploaded, fileName)
+		}
+	}
+}
+
+//Workers return number of concurrent upload workers
+func (bp *BatchPolicy) Workers() int {
+	if bp == nil {
+		return 1
+	}
+
+	return bp.workers
+}
+
+//GetBatchPolicy return storage proxy batch policy or nil if proxy doesn't have it
+func GetBatchPolicy(storageProxy events.StorageProxy) *BatchPolicy {
+	if rsp, ok := storageProxy.(*RetryableProxy); ok {
+		return rsp.config.batchPolicy
+	}
+
+	return nil
+}
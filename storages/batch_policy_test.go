@@ -0,0 +1,39 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBatchPolicy(t *testing.T) {
+	var nilPolicy *BatchPolicy
+	require.True(t, nilPolicy.ShouldFlush(0, 0, time.Now()))
+	require.False(t, nilPolicy.IsUploaded("file1"))
+	require.Equal(t, 1, nilPolicy.Workers())
+
+	policy := NewBatchPolicy(&BatchConfig{IntervalMin: 60, MaxRows: 1000, MaxBytes: 1024})
+	now := policy.lastFlush.Add(time.Minute)
+	require.Equal(t, 1, policy.Workers())
+	require.False(t, policy.ShouldFlush(999, 100, now))
+	require.True(t, policy.ShouldFlush(1000, 100, now))
+	require.True(t, policy.ShouldFlush(1, 1024, now))
+	require.True(t, policy.ShouldFlush(1, 1, now.Add(time.Hour)))
+
+	policy.Flushed(now.Add(time.Hour))
+	require.False(t, policy.ShouldFlush(1, 1, now.Add(time.Hour+time.Minute)))
+
+	policy.MarkUploaded("file1")
+	policy.MarkUploaded("file2")
+	require.True(t, policy.IsUploaded("file1"))
+	policy.Retain(map[string]bool{"file2": true})
+	require.False(t, policy.IsUploaded("file1"))
+	require.True(t, policy.IsUploaded("file2"))
+}
+
+func TestValidateBatch(t *testing.T) {
+	require.NoError(t, validateBatch(BatchMode, nil))
+	require.NoError(t, validateBatch(BatchMode, &BatchConfig{IntervalMin: 1, Workers: 4}))
+	require.Error(t, validateBatch(StreamMode, &BatchConfig{IntervalMin: 1}))
+	require.Error(t, validateBatch(BatchMode, &BatchConfig{MaxRows: -1}))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
@@ -24,8 +25,10 @@ type BigQuery struct {
 	tableHelper     *TableHelper
 	processor       *schema.Processor
 	streamingWorker *StreamingWorker
+	batchFallback   *BigQueryBatchFallback
 	fallbackLogger  *logging.AsyncLogger
 	eventsCache     *caching.EventsCache
+	uploadsLimiter  *UploadsLimiter
 }
 
 func NewBigQuery(config *Config) (events.Storage, error) {
@@ -54,7 +57,7 @@ func NewBigQuery(config *Config) (events.Storage, error) {
 	}
 
 	queryLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
-	bigQueryAdapter, err := adapters.NewBigQuery(config.ctx, gConfig, queryLogger, config.sqlTypeCasts)
+	bigQueryAdapter, err := adapters.NewBigQuery(config.ctx, gConfig, queryLogger, config.sqlTypeCasts, config.tableOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +72,8 @@ func NewBigQuery(config *Config) (events.Storage, error) {
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(bigQueryAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToBigQueryString)
+	tableHelper := NewTableHelper(bigQueryAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToBigQueryString, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
 
 	bq := &BigQuery{
 		name:           config.name,
@@ -79,10 +83,13 @@ func NewBigQuery(config *Config) (events.Storage, error) {
 		processor:      config.processor,
 		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
 		eventsCache:    config.eventsCache,
+		uploadsLimiter: config.uploadsLimiter,
 	}
 
 	if config.streamMode {
-		bq.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, bq, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+		bq.batchFallback = newBigQueryBatchFallback(config.name, bigQueryAdapter.Load, bq.batchFallbackError)
+		bq.batchFallback.start()
+		bq.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, bq, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
 		bq.streamingWorker.start()
 	}
 
@@ -90,13 +97,19 @@ func NewBigQuery(config *Config) (events.Storage, error) {
 }
 
 //Insert event in BigQuery
+//if streaming quota is exceeded - event is buffered and loaded in batch (see BigQueryBatchFallback)
 func (bq *BigQuery) Insert(dataSchema *adapters.Table, event events.Event) (err error) {
 	dbTable, err := bq.tableHelper.EnsureTable(bq.Name(), dataSchema)
 	if err != nil {
 		return err
 	}
 
-	err = bq.bqAdapter.Insert(dbTable, event)
+	if bq.batchFallback.Active() {
+		bq.batchFallback.Add(dbTable, event)
+		return nil
+	}
+
+	err = bq.insertOrFallback(dbTable, event)
 
 	//renew current db schema and retry
 	if err != nil {
@@ -105,12 +118,37 @@ func (bq *BigQuery) Insert(dataSchema *adapters.Table, event events.Event) (err
 			return err
 		}
 
-		return bq.bqAdapter.Insert(dbTable, event)
+		return bq.insertOrFallback(dbTable, event)
 	}
 
 	return nil
 }
 
+//insertOrFallback insert event in stream mode or activate batch fallback on quota error
+func (bq *BigQuery) insertOrFallback(dbTable *adapters.Table, event events.Event) error {
+	err := bq.bqAdapter.Insert(dbTable, event)
+	if err != nil && bq.batchFallback != nil && adapters.IsBigQueryQuotaError(err) {
+		bq.batchFallback.Activate()
+		bq.batchFallback.Add(dbTable, event)
+		return nil
+	}
+
+	return err
+}
+
+//batchFallbackError write objects which weren't loaded in batch fallback mode to fallback logger and events cache
+func (bq *BigQuery) batchFallbackError(objects []map[string]interface{}, err error) {
+	for _, object := range objects {
+		eventId := events.ExtractEventId(object)
+		bq.eventsCache.Error(bq.Name(), eventId, err.Error())
+		bq.Fallback(&events.FailedEvent{
+			Event:   []byte(events.Event(object).Serialize()),
+			Error:   err.Error(),
+			EventId: eventId,
+		})
+	}
+}
+
 //Store call StoreWithParseFunc with parsers.ParseJson func
 func (bq *BigQuery) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
 	return bq.StoreWithParseFunc(fileName, payload, alreadyUploadedTables, parsers.ParseJson)
@@ -120,6 +158,9 @@ func (bq *BigQuery) Store(fileName string, payload []byte, alreadyUploadedTables
 //return result per table, failed events count and err if occurred
 func (bq *BigQuery) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	bq.uploadsLimiter.Acquire()
+	defer bq.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := bq.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -195,6 +236,22 @@ func (bq *BigQuery) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		bq.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(bq.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (bq *BigQuery) RefreshSchema() ([]string, error) {
+	return bq.tableHelper.RefreshAllTables(bq.Name())
+}
+
+//DropTable drop table in the warehouse and in-memory schema cache
+func (bq *BigQuery) DropTable(tableName string) error {
+	return bq.tableHelper.DropTable(bq.Name(), tableName)
+}
+
+//Processor return schema processor which is used for dry runs
+func (bq *BigQuery) Processor() *schema.Processor {
+	return bq.processor
 }
 
 func (bq *BigQuery) Name() string {
@@ -205,6 +262,17 @@ func (bq *BigQuery) Type() string {
 	return BigQueryType
 }
 
+//HealthCheck check destination connectivity without writing any data
+func (bq *BigQuery) HealthCheck() error {
+	if bq.gcsAdapter != nil {
+		if err := bq.gcsAdapter.Test(); err != nil {
+			return err
+		}
+	}
+
+	return bq.bqAdapter.Test()
+}
+
 func (bq *BigQuery) Close() (multiErr error) {
 	if bq.gcsAdapter != nil {
 		if err := bq.gcsAdapter.Close(); err != nil {
@@ -222,6 +290,12 @@ func (bq *BigQuery) Close() (multiErr error) {
 		}
 	}
 
+	if bq.batchFallback != nil {
+		if err := bq.batchFallback.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing batch fallback: %v", bq.Name(), err))
+		}
+	}
+
 	if err := bq.fallbackLogger.Close(); err != nil {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", bq.Name(), err))
 	}
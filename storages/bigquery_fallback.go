@@ -0,0 +1,132 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/safego"
+	"sync"
+	"time"
+)
+
+const (
+	bigQueryBatchFallbackPeriod = 5 * time.Minute
+	bigQueryBatchFlushInterval  = time.Minute
+)
+
+//BigQueryBatchFallback buffers stream mode events while BigQuery streaming quota is exceeded
+//and loads them with load jobs (batch) every flush interval
+//streaming inserts aren't used during fallback period after the last quota error
+type BigQueryBatchFallback struct {
+	sync.Mutex
+
+	name      string
+	loadFunc  func(table *adapters.Table, objects []map[string]interface{}) error
+	errorFunc func(objects []map[string]interface{}, err error)
+
+	activeUntil time.Time
+	tables      map[string]*adapters.Table
+	buffers     map[string][]map[string]interface{}
+
+	closed bool
+}
+
+func newBigQueryBatchFallback(name string, loadFunc func(*adapters.Table, []map[string]interface{}) error,
+	errorFunc func([]map[string]interface{}, error)) *BigQueryBatchFallback {
+	return &BigQueryBatchFallback{
+		name:      name,
+		loadFunc:  loadFunc,
+		errorFunc: errorFunc,
+		tables:    map[string]*adapters.Table{},
+		buffers:   map[string][]map[string]interface{}{},
+	}
+}
+
+//start run goroutine which flushes buffered events every flush interval
+func (bf *BigQueryBatchFallback) start() {
+	safego.RunWithRestart(func() {
+		for {
+			if bf.isClosed() {
+				break
+			}
+
+			time.Sleep(bigQueryBatchFlushInterval)
+			bf.flush(true)
+		}
+	})
+}
+
+//Active return true if streaming quota error occurred during fallback period
+//nil BigQueryBatchFallback is never active
+func (bf *BigQueryBatchFallback) Active() bool {
+	if bf == nil {
+		return false
+	}
+
+	bf.Lock()
+	defer bf.Unlock()
+
+	return time.Now().Before(bf.activeUntil)
+}
+
+//Activate switch stream inserts to batch loads for fallback period
+func (bf *BigQueryBatchFallback) Activate() {
+	bf.Lock()
+	defer bf.Unlock()
+
+	if !time.Now().Before(bf.activeUntil) {
+		logging.Warnf("[%s] BigQuery streaming quota is exceeded. Events will be loaded in batch mode during %s", bf.name, bigQueryBatchFallbackPeriod)
+	}
+	bf.activeUntil = time.Now().Add(bigQueryBatchFallbackPeriod)
+}
+
+//Add put object into the table buffer
+func (bf *BigQueryBatchFallback) Add(table *adapters.Table, object map[string]interface{}) {
+	bf.Lock()
+	defer bf.Unlock()
+
+	bf.tables[table.Name] = table
+	bf.buffers[table.Name] = append(bf.buffers[table.Name], object)
+}
+
+//flush load all buffered objects per table
+//objects are kept in buffer if load fails with quota error and retry is true. Otherwise they are passed to errorFunc
+func (bf *BigQueryBatchFallback) flush(retry bool) {
+	bf.Lock()
+	tables, buffers := bf.tables, bf.buffers
+	bf.tables, bf.buffers = map[string]*adapters.Table{}, map[string][]map[string]interface{}{}
+	bf.Unlock()
+
+	for tableName, objects := range buffers {
+		table := tables[tableName]
+		if err := bf.loadFunc(table, objects); err != nil {
+			if retry && adapters.IsBigQueryQuotaError(err) {
+				logging.Warnf("[%s] Error loading %d buffered objects to table [%s]. They will be retried: %v", bf.name, len(objects), tableName, err)
+				bf.Lock()
+				bf.tables[tableName] = table
+				bf.buffers[tableName] = append(objects, bf.buffers[tableName]...)
+				bf.Unlock()
+				continue
+			}
+
+			logging.Errorf("[%s] Error loading %d buffered objects to table [%s]: %v", bf.name, len(objects), tableName, err)
+			bf.errorFunc(objects, err)
+		}
+	}
+}
+
+func (bf *BigQueryBatchFallback) isClosed() bool {
+	bf.Lock()
+	defer bf.Unlock()
+
+	return bf.closed
+}
+
+//Close flush buffered objects without retries
+func (bf *BigQueryBatchFallback) Close() error {
+	bf.Lock()
+	bf.closed = true
+	bf.Unlock()
+
+	bf.flush(false)
+	return nil
+}
@@ -0,0 +1,52 @@
+package storages
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"net/http"
+	"testing"
+)
+
+func TestBigQueryBatchFallback(t *testing.T) {
+	var loaded []map[string]interface{}
+	var failed []map[string]interface{}
+	var loadErr error
+	bf := newBigQueryBatchFallback("test", func(table *adapters.Table, objects []map[string]interface{}) error {
+		if loadErr != nil {
+			return loadErr
+		}
+		loaded = append(loaded, objects...)
+		return nil
+	}, func(objects []map[string]interface{}, err error) {
+		failed = append(failed, objects...)
+	})
+
+	require.False(t, bf.Active())
+	bf.Activate()
+	require.True(t, bf.Active())
+
+	table := &adapters.Table{Name: "events"}
+	bf.Add(table, map[string]interface{}{"id": 1})
+	bf.Add(table, map[string]interface{}{"id": 2})
+
+	//quota error: objects are kept for the next flush
+	loadErr = &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}
+	bf.flush(true)
+	require.Empty(t, loaded)
+	require.Empty(t, failed)
+
+	loadErr = nil
+	bf.flush(true)
+	require.Equal(t, []map[string]interface{}{{"id": 1}, {"id": 2}}, loaded)
+
+	//other errors: objects are passed to error func
+	loadErr = errors.New("invalid schema")
+	bf.Add(table, map[string]interface{}{"id": 3})
+	require.NoError(t, bf.Close())
+	require.Equal(t, []map[string]interface{}{{"id": 3}}, failed)
+
+	var nilFallback *BigQueryBatchFallback
+	require.False(t, nilFallback.Active())
+}
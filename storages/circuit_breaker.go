@@ -0,0 +1,185 @@
+package storages
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/metrics"
+	"github.com/jitsucom/eventnative/notifications"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+
+	defaultCircuitOpenTimeoutSec = 30
+)
+
+//CircuitBreakerConfig dto for deserialized destination circuit breaker config
+//circuit breaker is disabled if failure_threshold isn't positive
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold" json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty"`
+	OpenTimeoutSec   int `mapstructure:"open_timeout_sec" json:"open_timeout_sec,omitempty" yaml:"open_timeout_sec,omitempty"`
+}
+
+//Validate return err if config values are negative
+func (cbc *CircuitBreakerConfig) Validate() error {
+	if cbc == nil {
+		return nil
+	}
+	if cbc.FailureThreshold < 0 {
+		return errors.New("circuit_breaker.failure_threshold can't be negative")
+	}
+	if cbc.OpenTimeoutSec < 0 {
+		return errors.New("circuit_breaker.open_timeout_sec can't be negative")
+	}
+
+	return nil
+}
+
+//CircuitBreaker counts consecutive destination failures:
+//closed - all inserts are allowed
+//open - after N consecutive failures inserts aren't allowed during open timeout (events go to retry queue or stay in log files)
+//half-open - after open timeout only one probe insert is allowed: success closes the circuit, failure opens it again
+//nil CircuitBreaker always allows inserts
+type CircuitBreaker struct {
+	sync.Mutex
+
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	//downAt is a time when the circuit was opened from closed state (re-openings after failed probes don't change it)
+	downAt time.Time
+}
+
+//NewCircuitBreaker return CircuitBreaker or nil if it isn't configured
+func NewCircuitBreaker(name string, config *CircuitBreakerConfig) *CircuitBreaker {
+	if config == nil || config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	openTimeoutSec := config.OpenTimeoutSec
+	if openTimeoutSec == 0 {
+		openTimeoutSec = defaultCircuitOpenTimeoutSec
+	}
+
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: config.FailureThreshold,
+		openTimeout:      time.Duration(openTimeoutSec) * time.Second,
+		state:            CircuitClosed,
+	}
+}
+
+//Allow return true if insert is allowed. Transit open circuit to half-open state after open timeout
+//and allows only one probe insert at once in half-open state
+func (cb *CircuitBreaker) Allow() bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			return false
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probing = true
+		return true
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+//Success reset failures and close the circuit
+func (cb *CircuitBreaker) Success() {
+	if cb == nil {
+		return
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.failures = 0
+	cb.probing = false
+	if cb.state != CircuitClosed {
+		cb.setState(CircuitClosed)
+	}
+}
+
+//Failure increment consecutive failures and open the circuit if threshold is reached or probe is failed
+func (cb *CircuitBreaker) Failure() {
+	if cb == nil {
+		return
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == CircuitHalfOpen || (cb.state == CircuitClosed && cb.failures >= cb.failureThreshold) {
+		cb.openedAt = time.Now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+//State return current circuit state
+func (cb *CircuitBreaker) State() string {
+	if cb == nil {
+		return CircuitClosed
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	return cb.state
+}
+
+//RetryDelay return open timeout: delay for events which weren't allowed
+func (cb *CircuitBreaker) RetryDelay() time.Duration {
+	if cb == nil {
+		return 0
+	}
+
+	return cb.openTimeout
+}
+
+//setState must be called with lock
+//destination is down when the circuit is opened from closed state and up when the circuit is closed
+func (cb *CircuitBreaker) setState(state string) {
+	logging.Infof("[%s] Circuit breaker state: %s -> %s (consecutive failures: %d)", cb.name, cb.state, state, cb.failures)
+	previous := cb.state
+	cb.state = state
+	metrics.DestinationCircuitOpen(cb.name, state != CircuitClosed)
+
+	if previous == CircuitClosed && state == CircuitOpen {
+		cb.downAt = time.Now()
+		notifications.Lifecycle(notifications.DestinationDown, map[string]interface{}{
+			"destination_id":       cb.name,
+			"consecutive_failures": cb.failures,
+			"retry_after_sec":      int(cb.openTimeout.Seconds()),
+		})
+	} else if state == CircuitClosed {
+		notifications.Lifecycle(notifications.DestinationUp, map[string]interface{}{
+			"destination_id": cb.name,
+			"down_sec":       int(time.Since(cb.downAt).Seconds()),
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	require.Nil(t, NewCircuitBreaker("test", nil))
+	require.Nil(t, NewCircuitBreaker("test", &CircuitBreakerConfig{}))
+
+	var disabled *CircuitBreaker
+	disabled.Failure()
+	require.True(t, disabled.Allow())
+	require.Equal(t, CircuitClosed, disabled.State())
+
+	cb := NewCircuitBreaker("test", &CircuitBreakerConfig{FailureThreshold: 2, OpenTimeoutSec: 1})
+	cb.openTimeout = 50 * time.Millisecond
+
+	//success resets consecutive failures
+	cb.Failure()
+	cb.Success()
+	cb.Failure()
+	require.True(t, cb.Allow())
+	require.Equal(t, CircuitClosed, cb.State())
+
+	cb.Failure()
+	require.Equal(t, CircuitOpen, cb.State())
+	require.False(t, cb.Allow())
+
+	//failed probe
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, cb.Allow())
+	require.Equal(t, CircuitHalfOpen, cb.State())
+	require.False(t, cb.Allow(), "Only one probe is allowed in half-open state")
+	cb.Failure()
+	require.Equal(t, CircuitOpen, cb.State())
+	require.False(t, cb.Allow())
+
+	//succeed probe
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, cb.Allow())
+	cb.Success()
+	require.Equal(t, CircuitClosed, cb.State())
+	require.True(t, cb.Allow())
+	require.True(t, cb.Allow())
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
@@ -19,11 +20,14 @@ type ClickHouse struct {
 	name                          string
 	adapters                      []*adapters.ClickHouse
 	tableHelpers                  []*TableHelper
+	shardsViewHelper              *ShardsViewHelper
 	processor                     *schema.Processor
 	streamingWorker               *StreamingWorker
 	fallbackLogger                *logging.AsyncLogger
 	eventsCache                   *caching.EventsCache
 	usersRecognitionConfiguration *events.UserRecognitionConfiguration
+	distributed                   bool
+	uploadsLimiter                *UploadsLimiter
 }
 
 func NewClickHouse(config *Config) (events.Storage, error) {
@@ -32,7 +36,7 @@ func NewClickHouse(config *Config) (events.Storage, error) {
 		return nil, err
 	}
 
-	tableStatementFactory, err := adapters.NewTableStatementFactory(chConfig)
+	tableStatementFactory, err := adapters.NewTableStatementFactory(chConfig, config.tableOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +53,7 @@ func NewClickHouse(config *Config) (events.Storage, error) {
 	var chAdapters []*adapters.ClickHouse
 	var tableHelpers []*TableHelper
 	for _, dsn := range chConfig.Dsns {
-		adapter, err := adapters.NewClickHouse(config.ctx, dsn, chConfig.Database, chConfig.Cluster, chConfig.Tls,
+		adapter, err := adapters.NewClickHouse(config.ctx, config.destination.Pool.EnrichClickHouseDsn(dsn), chConfig.Database, chConfig.Cluster, chConfig.Tls,
 			tableStatementFactory, nullableFields, queryLogger, config.sqlTypeCasts)
 		if err != nil {
 			//close all previous created adapters
@@ -58,19 +62,26 @@ func NewClickHouse(config *Config) (events.Storage, error) {
 			}
 			return nil, err
 		}
+		adapter.ConfigurePool(config.destination.Pool)
 
 		chAdapters = append(chAdapters, adapter)
-		tableHelpers = append(tableHelpers, NewTableHelper(adapter, config.monitorKeeper, config.pkFields, adapters.SchemaToClickhouse))
+		tableHelpers = append(tableHelpers, NewTableHelper(adapter, config.monitorKeeper, config.pkFields, adapters.SchemaToClickhouse, config.columnComments))
 	}
+	//all shards have the same tables schema
+	//views over sharded tables are created with the first node (ON CLUSTER in cluster mode)
+	config.processor.ColumnsGuard().SetProvider(tableHelpers[0])
 
 	ch := &ClickHouse{
 		name:                          config.name,
 		adapters:                      chAdapters,
 		tableHelpers:                  tableHelpers,
+		shardsViewHelper:              NewShardsViewHelper(config.name, chAdapters[0], tableHelpers[0], config.processor.Sharding()),
 		processor:                     config.processor,
 		eventsCache:                   config.eventsCache,
+		uploadsLimiter:                config.uploadsLimiter,
 		fallbackLogger:                config.loggerFactory.CreateFailedLogger(config.name),
 		usersRecognitionConfiguration: config.usersRecognition,
+		distributed:                   chConfig.Cluster != "",
 	}
 
 	adapter, _ := ch.getAdapters()
@@ -85,13 +96,61 @@ func NewClickHouse(config *Config) (events.Storage, error) {
 	}
 
 	if config.streamMode {
-		ch.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ch, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelpers...)
+		ch.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ch, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelpers...)
 		ch.streamingWorker.start()
 	}
 
 	return ch, nil
 }
 
+//RefreshSchema drop cached tables schema on every node and re-read it from ClickHouse
+func (ch *ClickHouse) RefreshSchema() ([]string, error) {
+	refreshedSet := map[string]bool{}
+	for _, tableHelper := range ch.tableHelpers {
+		tables, err := tableHelper.RefreshAllTables(ch.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			refreshedSet[table] = true
+		}
+	}
+
+	var refreshed []string
+	for table := range refreshedSet {
+		refreshed = append(refreshed, table)
+	}
+	return refreshed, nil
+}
+
+//ExportUserRows return rows of all database tables where any of user columns equals userId
+//in cluster mode distributed tables are queried on one node otherwise rows from all nodes are merged
+func (ch *ClickHouse) ExportUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	chAdapters := ch.adapters
+	if ch.distributed {
+		adapter, _ := ch.getAdapters()
+		chAdapters = []*adapters.ClickHouse{adapter}
+	}
+
+	result := map[string][]map[string]interface{}{}
+	for _, adapter := range chAdapters {
+		tableRows, err := adapter.SelectUserRows(userColumns, userId)
+		if err != nil {
+			return nil, err
+		}
+		for table, rows := range tableRows {
+			result[table] = append(result[table], rows...)
+		}
+	}
+
+	return result, nil
+}
+
+//Processor return schema processor which is used for dry runs
+func (ch *ClickHouse) Processor() *schema.Processor {
+	return ch.processor
+}
+
 func (ch *ClickHouse) Name() string {
 	return ch.name
 }
@@ -100,6 +159,18 @@ func (ch *ClickHouse) Type() string {
 	return ClickHouseType
 }
 
+//HealthCheck check destination connectivity without writing any data
+func (ch *ClickHouse) HealthCheck() error {
+	var multiErr error
+	for _, adapter := range ch.adapters {
+		if err := adapter.Test(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return multiErr
+}
+
 //Insert event in ClickHouse (1 retry if err)
 func (ch *ClickHouse) Insert(dataSchema *adapters.Table, event events.Event) (err error) {
 	adapter, tableHelper := ch.getAdapters()
@@ -108,6 +179,7 @@ func (ch *ClickHouse) Insert(dataSchema *adapters.Table, event events.Event) (er
 	if err != nil {
 		return err
 	}
+	ch.ensureView(dbSchema)
 
 	err = adapter.Insert(dbSchema, event)
 
@@ -133,6 +205,9 @@ func (ch *ClickHouse) Store(fileName string, payload []byte, alreadyUploadedTabl
 //return result per table, failed events count and err if occurred
 func (ch *ClickHouse) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	ch.uploadsLimiter.Acquire()
+	defer ch.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := ch.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -179,6 +254,7 @@ func (ch *ClickHouse) storeTable(adapter *adapters.ClickHouse, tableHelper *Tabl
 	if err != nil {
 		return err
 	}
+	ch.ensureView(dbSchema)
 
 	if err := adapter.BulkInsert(dbSchema, fdata.GetPayload()); err != nil {
 		return err
@@ -193,6 +269,9 @@ func (ch *ClickHouse) storeTable(adapter *adapters.ClickHouse, tableHelper *Tabl
 //return rows count and err if can't store
 //or rows count and nil if stored
 func (ch *ClickHouse) SyncStore(overriddenCollectionTable string, objects []map[string]interface{}, timeIntervalValue string) (rowsCount int, err error) {
+	ch.uploadsLimiter.Acquire()
+	defer ch.uploadsLimiter.Release()
+
 	flatData, err := ch.processor.ProcessObjects(objects)
 	if err != nil {
 		return len(objects), err
@@ -215,6 +294,7 @@ func (ch *ClickHouse) SyncStore(overriddenCollectionTable string, objects []map[
 		if err != nil {
 			return rowsCount, err
 		}
+		ch.ensureView(dbSchema)
 		err = adapter.BulkUpdate(dbSchema, fdata.GetPayload(), deleteConditions)
 		if err != nil {
 			return rowsCount, err
@@ -224,6 +304,14 @@ func (ch *ClickHouse) SyncStore(overriddenCollectionTable string, objects []map[
 	return rowsCount, nil
 }
 
+//ensureView create view over table shards if configured
+//view errors don't affect data loading: creation will be retried with the next table write
+func (ch *ClickHouse) ensureView(dbSchema *adapters.Table) {
+	if err := ch.shardsViewHelper.EnsureView(dbSchema); err != nil {
+		logging.SystemErrorf("[%s] %v", ch.Name(), err)
+	}
+}
+
 func (ch *ClickHouse) GetUsersRecognition() *events.UserRecognitionConfiguration {
 	return ch.usersRecognitionConfiguration
 }
@@ -233,6 +321,7 @@ func (ch *ClickHouse) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		ch.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(ch.Name(), counters.StageFallback, len(failedEvents))
 }
 
 //Close adapters.ClickHouse
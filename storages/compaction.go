@@ -0,0 +1,343 @@
+package storages
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/scheduler"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCompactionMinFiles     = 10
+	defaultCompactionSmallFileMb  = 32
+	defaultCompactionTargetFileMb = 128
+	defaultCompactionMinAgeMin    = 60
+	defaultCompactionIntervalMin  = 60
+
+	compactionCollection     = "compaction"
+	compactionManifestPrefix = "_compaction/"
+	compactedFilePrefix      = "compacted."
+)
+
+var (
+	//line delimited JSON files which can be merged by concatenation
+	compactableExtensions = []string{".log", ".ndjson", ".json"}
+	//gzip members and zstd frames can be concatenated without recompression
+	compactableCompressions = []string{"", adapters.CompressionGzip, adapters.CompressionZstd}
+)
+
+//CompactionConfig is a configuration of background merging small files of object store destinations
+//Files smaller than SmallFileMb and older than MinAgeMin in the same folder (partition) are merged into files up to TargetFileMb
+//if there are at least MinFiles of them. Only line delimited JSON files (optionally gzip or zstd compressed) are compacted
+type CompactionConfig struct {
+	MinFiles     int `mapstructure:"min_files" json:"min_files,omitempty" yaml:"min_files,omitempty"`
+	SmallFileMb  int `mapstructure:"small_file_mb" json:"small_file_mb,omitempty" yaml:"small_file_mb,omitempty"`
+	TargetFileMb int `mapstructure:"target_file_mb" json:"target_file_mb,omitempty" yaml:"target_file_mb,omitempty"`
+	MinAgeMin    int `mapstructure:"min_age_min" json:"min_age_min,omitempty" yaml:"min_age_min,omitempty"`
+	IntervalMin  int `mapstructure:"interval_min" json:"interval_min,omitempty" yaml:"interval_min,omitempty"`
+}
+
+func (cc *CompactionConfig) Validate() error {
+	if cc == nil {
+		return nil
+	}
+
+	if cc.MinFiles == 0 {
+		cc.MinFiles = defaultCompactionMinFiles
+	}
+	if cc.SmallFileMb == 0 {
+		cc.SmallFileMb = defaultCompactionSmallFileMb
+	}
+	if cc.TargetFileMb == 0 {
+		cc.TargetFileMb = defaultCompactionTargetFileMb
+	}
+	if cc.MinAgeMin == 0 {
+		cc.MinAgeMin = defaultCompactionMinAgeMin
+	}
+	if cc.IntervalMin == 0 {
+		cc.IntervalMin = defaultCompactionIntervalMin
+	}
+
+	if cc.MinFiles < 2 {
+		return fmt.Errorf("compaction.min_files must be at least 2")
+	}
+	if cc.SmallFileMb < 0 || cc.TargetFileMb < 0 || cc.MinAgeMin < 0 || cc.IntervalMin < 0 {
+		return fmt.Errorf("compaction parameters can't be negative")
+	}
+	if cc.SmallFileMb > cc.TargetFileMb {
+		return fmt.Errorf("compaction.small_file_mb [%d] can't be greater than compaction.target_file_mb [%d]", cc.SmallFileMb, cc.TargetFileMb)
+	}
+
+	return nil
+}
+
+//ObjectStore is implemented by object store adapters. All keys are relative to the configured folder
+type ObjectStore interface {
+	ListObjects(prefix string) ([]*adapters.ObjectInfo, error)
+	GetObject(key string) ([]byte, error)
+	UploadBytes(key string, payload []byte) error
+	DeleteObject(key string) error
+}
+
+//compactionManifest is written before merged file uploading and deleted after sources deletion
+//pending manifests are finished on the next compaction run
+type compactionManifest struct {
+	Target  string   `json:"target"`
+	Sources []string `json:"sources"`
+}
+
+//Compactor merges small files of object store destination by schedule (one node of the cluster at a time)
+//merged file is uploaded before originals deletion: readers might see duplicates only until the deletion completes
+type Compactor struct {
+	destinationName string
+	store           ObjectStore
+	monitorKeeper   MonitorKeeper
+	config          *CompactionConfig
+
+	job *scheduler.Job
+	now func() time.Time
+}
+
+//NewCompactor return nil if config is nil. config must be validated
+func NewCompactor(destinationName string, store ObjectStore, monitorKeeper MonitorKeeper, config *CompactionConfig) *Compactor {
+	if config == nil {
+		return nil
+	}
+
+	c := &Compactor{
+		destinationName: destinationName,
+		store:           store,
+		monitorKeeper:   monitorKeeper,
+		config:          config,
+		now:             time.Now,
+	}
+	c.job = scheduler.Add("compaction_"+destinationName, scheduler.Every(time.Duration(config.IntervalMin)*time.Minute), c.Compact)
+
+	return c
+}
+
+//Compact finish pending compactions and merge small files of every folder
+func (c *Compactor) Compact() error {
+	lock, err := c.monitorKeeper.Lock(c.destinationName, compactionCollection)
+	if err != nil {
+		return fmt.Errorf("[%s] Unable to lock compaction: %v", c.destinationName, err)
+	}
+	defer c.monitorKeeper.Unlock(lock)
+
+	if err := c.finishPending(); err != nil {
+		return fmt.Errorf("[%s] Error finishing pending compactions: %v", c.destinationName, err)
+	}
+
+	objects, err := c.store.ListObjects("")
+	if err != nil {
+		return fmt.Errorf("[%s] %v", c.destinationName, err)
+	}
+
+	var merged, files int
+	for group, candidates := range c.candidates(objects) {
+		if len(candidates) < c.config.MinFiles {
+			continue
+		}
+
+		for i, batch := range c.batches(candidates) {
+			target := fmt.Sprintf("%s%s%d.%d%s", group.folder, compactedFilePrefix, c.now().UnixNano(), i, group.extension)
+			if err := c.merge(target, batch, group.compressed); err != nil {
+				return fmt.Errorf("[%s] Error compacting %d files into [%s]: %v", c.destinationName, len(batch), target, err)
+			}
+			merged++
+			files += len(batch)
+		}
+	}
+
+	if merged > 0 {
+		logging.Infof("[%s] %d small files have been compacted into %d files", c.destinationName, files, merged)
+	}
+
+	return nil
+}
+
+type compactionGroup struct {
+	folder     string
+	extension  string
+	compressed bool
+}
+
+//candidates return small compactable files older than min age grouped by folder and extension and sorted by key
+func (c *Compactor) candidates(objects []*adapters.ObjectInfo) map[compactionGroup][]*adapters.ObjectInfo {
+	smallSize := int64(c.config.SmallFileMb) * 1024 * 1024
+	minModified := c.now().Add(-time.Duration(c.config.MinAgeMin) * time.Minute)
+
+	groups := map[compactionGroup][]*adapters.ObjectInfo{}
+	for _, object := range objects {
+		if object.Size >= smallSize || object.Modified.After(minModified) {
+			continue
+		}
+
+		folder, name := splitKey(object.Key)
+		//hidden files and manifests are ignored (Athena and Spectrum skip them as well)
+		if strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") || strings.HasPrefix(object.Key, compactionManifestPrefix) {
+			continue
+		}
+
+		extension, compressed, ok := compactableExtension(name)
+		if !ok {
+			continue
+		}
+
+		group := compactionGroup{folder: folder, extension: extension, compressed: compressed}
+		groups[group] = append(groups[group], object)
+	}
+
+	for _, files := range groups {
+		sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	}
+
+	return groups
+}
+
+//batches split files into batches up to target size. Batches with one file are skipped
+func (c *Compactor) batches(files []*adapters.ObjectInfo) [][]*adapters.ObjectInfo {
+	targetSize := int64(c.config.TargetFileMb) * 1024 * 1024
+
+	var batches [][]*adapters.ObjectInfo
+	var batch []*adapters.ObjectInfo
+	var batchSize int64
+	for _, file := range files {
+		if len(batch) > 0 && batchSize+file.Size > targetSize {
+			batches = append(batches, batch)
+			batch, batchSize = nil, 0
+		}
+		batch = append(batch, file)
+		batchSize += file.Size
+	}
+	batches = append(batches, batch)
+
+	var result [][]*adapters.ObjectInfo
+	for _, b := range batches {
+		if len(b) > 1 {
+			result = append(result, b)
+		}
+	}
+
+	return result
+}
+
+//merge write manifest -> upload concatenated files -> delete sources -> delete manifest
+//line break is added between not compressed files without trailing line break
+func (c *Compactor) merge(target string, sources []*adapters.ObjectInfo, compressed bool) error {
+	manifest := &compactionManifest{Target: target}
+	for _, source := range sources {
+		manifest.Sources = append(manifest.Sources, source.Key)
+	}
+	manifestKey := fmt.Sprintf("%s%d.json", compactionManifestPrefix, c.now().UnixNano())
+	b, _ := json.Marshal(manifest)
+	if err := c.store.UploadBytes(manifestKey, b); err != nil {
+		return fmt.Errorf("Error writing compaction manifest: %v", err)
+	}
+
+	var payload []byte
+	for _, source := range sources {
+		sourcePayload, err := c.store.GetObject(source.Key)
+		if err != nil {
+			return fmt.Errorf("Error reading [%s]: %v", source.Key, err)
+		}
+		payload = append(payload, sourcePayload...)
+		if !compressed && len(payload) > 0 && payload[len(payload)-1] != '\n' {
+			payload = append(payload, '\n')
+		}
+	}
+
+	if err := c.store.UploadBytes(target, payload); err != nil {
+		return err
+	}
+
+	return c.finish(manifestKey, manifest)
+}
+
+//finishPending finish compactions which were interrupted: if merged file has been uploaded - delete sources
+//otherwise only the manifest is deleted (sources are intact and will be compacted again)
+func (c *Compactor) finishPending() error {
+	manifests, err := c.store.ListObjects(compactionManifestPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, manifestObject := range manifests {
+		b, err := c.store.GetObject(manifestObject.Key)
+		if err != nil {
+			return err
+		}
+		manifest := &compactionManifest{}
+		if err := json.Unmarshal(b, manifest); err != nil {
+			return fmt.Errorf("Error parsing compaction manifest [%s]: %v", manifestObject.Key, err)
+		}
+
+		targets, err := c.store.ListObjects(manifest.Target)
+		if err != nil {
+			return err
+		}
+		uploaded := false
+		for _, target := range targets {
+			if target.Key == manifest.Target {
+				uploaded = true
+			}
+		}
+
+		if !uploaded {
+			manifest.Sources = nil
+		}
+		if err := c.finish(manifestObject.Key, manifest); err != nil {
+			return err
+		}
+		logging.Infof("[%s] Pending compaction into [%s] has been finished (merged file uploaded: %t)", c.destinationName, manifest.Target, uploaded)
+	}
+
+	return nil
+}
+
+func (c *Compactor) finish(manifestKey string, manifest *compactionManifest) error {
+	for _, source := range manifest.Sources {
+		if err := c.store.DeleteObject(source); err != nil {
+			return err
+		}
+	}
+
+	return c.store.DeleteObject(manifestKey)
+}
+
+//Close stop compaction job. Nil-safe
+func (c *Compactor) Close() {
+	if c != nil && c.job != nil {
+		c.job.Stop()
+	}
+}
+
+//splitKey return folder (with trailing slash) and file name
+func splitKey(key string) (string, string) {
+	i := strings.LastIndex(key, "/")
+	return key[:i+1], key[i+1:]
+}
+
+//compactableExtension return file extension with compression extension (e.g. .log.gz), true if the file is compressed
+//and true if the file can be compacted
+func compactableExtension(name string) (string, bool, bool) {
+	for _, compression := range compactableCompressions {
+		compressionExtension := (&adapters.CompressionConfig{Codec: compression}).Extension()
+		withoutCompression := strings.TrimSuffix(name, compressionExtension)
+		if compressionExtension != "" && withoutCompression == name {
+			continue
+		}
+
+		for _, extension := range compactableExtensions {
+			if strings.HasSuffix(withoutCompression, extension) {
+				return extension + compressionExtension, compressionExtension != "", true
+			}
+		}
+	}
+
+	return "", false, false
+}
@@ -0,0 +1,101 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testObjectStore struct {
+	objects  map[string][]byte
+	modified time.Time
+}
+
+func (tos *testObjectStore) ListObjects(prefix string) ([]*adapters.ObjectInfo, error) {
+	var objects []*adapters.ObjectInfo
+	for key, payload := range tos.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, &adapters.ObjectInfo{Key: key, Size: int64(len(payload)), Modified: tos.modified})
+		}
+	}
+	return objects, nil
+}
+func (tos *testObjectStore) GetObject(key string) ([]byte, error) { return tos.objects[key], nil }
+func (tos *testObjectStore) UploadBytes(key string, payload []byte) error {
+	tos.objects[key] = payload
+	return nil
+}
+func (tos *testObjectStore) DeleteObject(key string) error {
+	delete(tos.objects, key)
+	return nil
+}
+
+func TestCompactionConfigValidate(t *testing.T) {
+	require.Error(t, (&CompactionConfig{MinFiles: 1}).Validate())
+	require.Error(t, (&CompactionConfig{SmallFileMb: 256, TargetFileMb: 128}).Validate())
+
+	config := &CompactionConfig{}
+	require.NoError(t, config.Validate())
+	require.Equal(t, defaultCompactionMinFiles, config.MinFiles)
+	require.Equal(t, defaultCompactionTargetFileMb, config.TargetFileMb)
+}
+
+func TestCompact(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	store := &testObjectStore{modified: now.Add(-2 * time.Hour), objects: map[string][]byte{
+		"events/load_date=2020-10-01/a.log":    []byte(`{"id":1}` + "\n"),
+		"events/load_date=2020-10-01/b.log":    []byte(`{"id":2}`),
+		"events/load_date=2020-10-01/c.log":    []byte(`{"id":3}` + "\n"),
+		"events/load_date=2020-10-01/d.log.gz": []byte("gz"),
+		"events/load_date=2020-10-02/a.log":    []byte(`{"id":4}` + "\n"),
+		"events/load_date=2020-10-01/e.csv":    []byte("id\n5\n"),
+		"events/load_date=2020-10-01/f.csv":    []byte("id\n6\n"),
+		"events/load_date=2020-10-01/_g.log":   []byte(`{"id":7}` + "\n"),
+	}}
+	config := &CompactionConfig{MinFiles: 2}
+	require.NoError(t, config.Validate())
+	compactor := &Compactor{destinationName: "test", store: store, monitorKeeper: &testMonitorKeeper{}, config: config, now: func() time.Time { return now }}
+
+	require.NoError(t, compactor.Compact())
+
+	var keys []string
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	require.ElementsMatch(t, []string{
+		"events/load_date=2020-10-01/compacted.1601553600000000000.0.log",
+		"events/load_date=2020-10-01/d.log.gz",
+		"events/load_date=2020-10-02/a.log",
+		"events/load_date=2020-10-01/e.csv",
+		"events/load_date=2020-10-01/f.csv",
+		"events/load_date=2020-10-01/_g.log",
+	}, keys)
+	require.Equal(t, `{"id":1}`+"\n"+`{"id":2}`+"\n"+`{"id":3}`+"\n", string(store.objects["events/load_date=2020-10-01/compacted.1601553600000000000.0.log"]))
+}
+
+func TestCompactFinishPending(t *testing.T) {
+	uploaded, _ := json.Marshal(&compactionManifest{Target: "merged.log", Sources: []string{"a.log", "b.log"}})
+	notUploaded, _ := json.Marshal(&compactionManifest{Target: "merged2.log", Sources: []string{"c.log", "d.log"}})
+	store := &testObjectStore{modified: time.Now(), objects: map[string][]byte{
+		compactionManifestPrefix + "1.json": uploaded,
+		compactionManifestPrefix + "2.json": notUploaded,
+		"merged.log":                        []byte("{}\n{}\n"),
+		"b.log":                             []byte("{}\n"),
+		"c.log":                             []byte("{}\n"),
+		"d.log":                             []byte("{}\n"),
+	}}
+	config := &CompactionConfig{}
+	require.NoError(t, config.Validate())
+	compactor := &Compactor{destinationName: "test", store: store, monitorKeeper: &testMonitorKeeper{}, config: config, now: time.Now}
+
+	require.NoError(t, compactor.Compact())
+
+	var keys []string
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	require.ElementsMatch(t, []string{"merged.log", "c.log", "d.log"}, keys)
+}
@@ -0,0 +1,130 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//Druid is a stream only destination: events are produced into Kafka and consumed by Druid Kafka indexing service
+type Druid struct {
+	name            string
+	druidAdapter    *adapters.Druid
+	tableHelper     *TableHelper
+	processor       *schema.Processor
+	streamingWorker *StreamingWorker
+	fallbackLogger  *logging.AsyncLogger
+	eventsCache     *caching.EventsCache
+}
+
+func NewDruid(config *Config) (events.Storage, error) {
+	if !config.streamMode {
+		return nil, fmt.Errorf("Druid destination doesn't support %s mode", BatchMode)
+	}
+
+	dConfig := config.destination.Druid
+	if err := dConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	requestDebugLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
+	druidAdapter := adapters.NewDruid(config.ctx, dConfig, requestDebugLogger)
+
+	tableHelper := NewTableHelper(druidAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToDruid, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
+
+	d := &Druid{
+		name:           config.name,
+		druidAdapter:   druidAdapter,
+		tableHelper:    tableHelper,
+		processor:      config.processor,
+		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
+		eventsCache:    config.eventsCache,
+	}
+
+	d.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, d, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+	d.streamingWorker.start()
+
+	return d, nil
+}
+
+//Insert ensure Druid table schema and produce event into the table Kafka topic
+func (d *Druid) Insert(dataSchema *adapters.Table, event events.Event) (err error) {
+	dbTable, err := d.tableHelper.EnsureTable(d.Name(), dataSchema)
+	if err != nil {
+		return err
+	}
+
+	return d.druidAdapter.Insert(dbTable, event)
+}
+
+func (d *Druid) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Druid doesn't support Store() func")
+}
+
+func (d *Druid) StoreWithParseFunc(fileName string, payload []byte, skipTables map[string]bool, parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Druid doesn't support StoreWithParseFunc() func")
+}
+
+func (d *Druid) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
+	return 0, errors.New("Druid doesn't support SyncStore() func")
+}
+
+func (d *Druid) GetUsersRecognition() *events.UserRecognitionConfiguration {
+	return disabledRecognitionConfiguration
+}
+
+//Fallback log event with error to fallback logger
+func (d *Druid) Fallback(failedEvents ...*events.FailedEvent) {
+	for _, failedEvent := range failedEvents {
+		d.fallbackLogger.ConsumeAny(failedEvent)
+	}
+	counters.DestinationStage(d.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (d *Druid) RefreshSchema() ([]string, error) {
+	return d.tableHelper.RefreshAllTables(d.Name())
+}
+
+//Processor return schema processor which is used for dry runs
+func (d *Druid) Processor() *schema.Processor {
+	return d.processor
+}
+
+func (d *Druid) Name() string {
+	return d.name
+}
+
+func (d *Druid) Type() string {
+	return DruidType
+}
+
+//HealthCheck check destination connectivity without writing any data
+func (d *Druid) HealthCheck() error {
+	return d.druidAdapter.Test()
+}
+
+func (d *Druid) Close() (multiErr error) {
+	if err := d.druidAdapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing Druid client: %v", d.Name(), err))
+	}
+
+	if d.streamingWorker != nil {
+		if err := d.streamingWorker.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing streaming worker: %v", d.Name(), err))
+		}
+	}
+
+	if err := d.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", d.Name(), err))
+	}
+
+	return
+}
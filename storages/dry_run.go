@@ -0,0 +1,28 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//ProcessorHolder is implemented by storages which process events with schema.Processor
+type ProcessorHolder interface {
+	Processor() *schema.Processor
+}
+
+//DryRun process event with storage processor (mappings, enrichment, table name, flattening) without storing it
+//return result table name and processed flatten object
+func DryRun(storage events.Storage, event events.Event) (string, events.Event, error) {
+	holder, ok := storage.(ProcessorHolder)
+	if !ok {
+		return "", nil, fmt.Errorf("Destination type [%s] doesn't support dry run", storage.Type())
+	}
+
+	batchHeader, processed, err := holder.Processor().ProcessEvent(event.Clone())
+	if err != nil {
+		return "", nil, err
+	}
+
+	return batchHeader.TableName, processed, nil
+}
@@ -0,0 +1,188 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//DynamoDB stores events into DynamoDB tables in two modes:
+//batch: (1 file = BatchWriteItem requests per 25 objects)
+//stream: (1 object = 1 BatchWriteItem request)
+type DynamoDB struct {
+	name            string
+	dynamoAdapter   *adapters.DynamoDB
+	tableHelper     *TableHelper
+	processor       *schema.Processor
+	streamingWorker *StreamingWorker
+	fallbackLogger  *logging.AsyncLogger
+	eventsCache     *caching.EventsCache
+	uploadsLimiter  *UploadsLimiter
+}
+
+func NewDynamoDB(config *Config) (events.Storage, error) {
+	dConfig := config.destination.DynamoDB
+	if err := dConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	dynamoAdapter, err := adapters.NewDynamoDB(config.ctx, dConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	tableHelper := NewTableHelper(dynamoAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToDynamoDB, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
+
+	d := &DynamoDB{
+		name:           config.name,
+		dynamoAdapter:  dynamoAdapter,
+		tableHelper:    tableHelper,
+		processor:      config.processor,
+		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
+		eventsCache:    config.eventsCache,
+		uploadsLimiter: config.uploadsLimiter,
+	}
+
+	if config.streamMode {
+		d.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, d, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+		d.streamingWorker.start()
+	}
+
+	return d, nil
+}
+
+//Insert ensure DynamoDB table and write event into it
+func (d *DynamoDB) Insert(dataSchema *adapters.Table, event events.Event) (err error) {
+	dbTable, err := d.tableHelper.EnsureTable(d.Name(), dataSchema)
+	if err != nil {
+		return err
+	}
+
+	return d.dynamoAdapter.Insert(dbTable, event)
+}
+
+//Store call StoreWithParseFunc with parsers.ParseJson func
+func (d *DynamoDB) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	return d.StoreWithParseFunc(fileName, payload, alreadyUploadedTables, parsers.ParseJson)
+}
+
+//StoreWithParseFunc store file payload to DynamoDB with processing
+//return result per table, failed events count and err if occurred
+func (d *DynamoDB) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
+	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	d.uploadsLimiter.Acquire()
+	defer d.uploadsLimiter.Release()
+
+	flatData, failedEvents, err := d.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
+	if err != nil {
+		return nil, linesCount(payload), err
+	}
+
+	//update cache with failed events
+	for _, failedEvent := range failedEvents {
+		d.eventsCache.Error(d.Name(), failedEvent.EventId, failedEvent.Error)
+	}
+
+	storeFailedEvents := true
+	tableResults := map[string]*events.StoreResult{}
+	for _, fdata := range flatData {
+		table := d.tableHelper.MapTableSchema(fdata.BatchHeader)
+		err := d.storeTable(fdata, table)
+		tableResults[table.Name] = &events.StoreResult{Err: err, RowsCount: fdata.GetPayloadLen()}
+		if err != nil {
+			logging.Errorf("[%s] Error storing file %s into table %s: %v", d.Name(), fileName, table.Name, err)
+			storeFailedEvents = false
+		}
+
+		//events cache
+		for _, object := range fdata.GetPayload() {
+			if err != nil {
+				d.eventsCache.Error(d.Name(), events.ExtractEventId(object), err.Error())
+			} else {
+				d.eventsCache.Succeed(d.Name(), events.ExtractEventId(object), object, table)
+			}
+		}
+	}
+
+	//store failed events to fallback only if other events have been inserted ok
+	if storeFailedEvents {
+		d.Fallback(failedEvents...)
+	}
+
+	return tableResults, len(failedEvents), nil
+}
+
+//check table existence and store data into one table
+func (d *DynamoDB) storeTable(fdata *schema.ProcessedFile, table *adapters.Table) error {
+	dbTable, err := d.tableHelper.EnsureTable(d.Name(), table)
+	if err != nil {
+		return err
+	}
+
+	return d.dynamoAdapter.BatchInsert(dbTable, fdata.GetPayload())
+}
+
+func (d *DynamoDB) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
+	return 0, errors.New("DynamoDB doesn't support SyncStore() func")
+}
+
+func (d *DynamoDB) GetUsersRecognition() *events.UserRecognitionConfiguration {
+	return disabledRecognitionConfiguration
+}
+
+//Fallback log event with error to fallback logger
+func (d *DynamoDB) Fallback(failedEvents ...*events.FailedEvent) {
+	for _, failedEvent := range failedEvents {
+		d.fallbackLogger.ConsumeAny(failedEvent)
+	}
+	counters.DestinationStage(d.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//RefreshSchema drop cached tables schema and re-read it from DynamoDB
+func (d *DynamoDB) RefreshSchema() ([]string, error) {
+	return d.tableHelper.RefreshAllTables(d.Name())
+}
+
+//Processor return schema processor which is used for dry runs
+func (d *DynamoDB) Processor() *schema.Processor {
+	return d.processor
+}
+
+func (d *DynamoDB) Name() string {
+	return d.name
+}
+
+func (d *DynamoDB) Type() string {
+	return DynamoDBType
+}
+
+//HealthCheck check destination connectivity without writing any data
+func (d *DynamoDB) HealthCheck() error {
+	return d.dynamoAdapter.Test()
+}
+
+func (d *DynamoDB) Close() (multiErr error) {
+	if err := d.dynamoAdapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing DynamoDB client: %v", d.Name(), err))
+	}
+
+	if d.streamingWorker != nil {
+		if err := d.streamingWorker.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing streaming worker: %v", d.Name(), err))
+		}
+	}
+
+	if err := d.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", d.Name(), err))
+	}
+
+	return
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/appconfig"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/dedup"
 	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/jsonutils"
 	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/routing"
 	"github.com/jitsucom/eventnative/schema"
+	"strings"
 )
 
 const (
@@ -18,33 +22,167 @@ const (
 
 	BatchMode  = "batch"
 	StreamMode = "stream"
+
+	defaultStreamWorkers = 1
 )
 
 var unknownDestination = errors.New("Unknown destination type")
 
 type DestinationConfig struct {
-	OnlyTokens       []string                 `mapstructure:"only_tokens" json:"only_tokens,omitempty" yaml:"only_tokens,omitempty"`
-	Type             string                   `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
-	Mode             string                   `mapstructure:"mode" json:"mode,omitempty" yaml:"mode,omitempty"`
-	DataLayout       *DataLayout              `mapstructure:"data_layout" json:"data_layout,omitempty" yaml:"data_layout,omitempty"`
-	UsersRecognition *UsersRecognition        `mapstructure:"users_recognition" json:"users_recognition,omitempty" yaml:"users_recognition,omitempty"`
-	Enrichment       []*enrichment.RuleConfig `mapstructure:"enrichment" json:"enrichment,omitempty" yaml:"enrichment,omitempty"`
-	BreakOnError     bool                     `mapstructure:"break_on_error" json:"break_on_error,omitempty" yaml:"break_on_error,omitempty"`
-
-	DataSource      *adapters.DataSourceConfig      `mapstructure:"datasource" json:"datasource,omitempty" yaml:"datasource,omitempty"`
-	S3              *adapters.S3Config              `mapstructure:"s3" json:"s3,omitempty" yaml:"s3,omitempty"`
-	Google          *adapters.GoogleConfig          `mapstructure:"google" json:"google,omitempty" yaml:"google,omitempty"`
-	GoogleAnalytics *adapters.GoogleAnalyticsConfig `mapstructure:"google_analytics" json:"google_analytics,omitempty" yaml:"google_analytics,omitempty"`
-	ClickHouse      *adapters.ClickHouseConfig      `mapstructure:"clickhouse" json:"clickhouse,omitempty" yaml:"clickhouse,omitempty"`
-	Snowflake       *adapters.SnowflakeConfig       `mapstructure:"snowflake" json:"snowflake,omitempty" yaml:"snowflake,omitempty"`
+	OnlyTokens       []string                   `mapstructure:"only_tokens" json:"only_tokens,omitempty" yaml:"only_tokens,omitempty"`
+	Type             string                     `mapstructure:"type" json:"type,omitempty" yaml:"type,omitempty"`
+	Mode             string                     `mapstructure:"mode" json:"mode,omitempty" yaml:"mode,omitempty"`
+	DataLayout       *DataLayout                `mapstructure:"data_layout" json:"data_layout,omitempty" yaml:"data_layout,omitempty"`
+	UsersRecognition *UsersRecognition          `mapstructure:"users_recognition" json:"users_recognition,omitempty" yaml:"users_recognition,omitempty"`
+	Enrichment       []*enrichment.RuleConfig   `mapstructure:"enrichment" json:"enrichment,omitempty" yaml:"enrichment,omitempty"`
+	BreakOnError     bool                       `mapstructure:"break_on_error" json:"break_on_error,omitempty" yaml:"break_on_error,omitempty"`
+	Timestamps       *schema.TimestampsConfig   `mapstructure:"timestamps" json:"timestamps,omitempty" yaml:"timestamps,omitempty"`
+	LateEvents       *schema.LateEventsConfig   `mapstructure:"late_events" json:"late_events,omitempty" yaml:"late_events,omitempty"`
+	Consent          *schema.ConsentConfig      `mapstructure:"consent" json:"consent,omitempty" yaml:"consent,omitempty"`
+	IpAnonymization  *schema.AnonymizeIpConfig  `mapstructure:"ip_anonymization" json:"ip_anonymization,omitempty" yaml:"ip_anonymization,omitempty"`
+	Sharding         []*schema.ShardingConfig   `mapstructure:"sharding" json:"sharding,omitempty" yaml:"sharding,omitempty"`
+	ColumnsLimit     *schema.ColumnsLimitConfig `mapstructure:"columns_limit" json:"columns_limit,omitempty" yaml:"columns_limit,omitempty"`
+	CircuitBreaker   *CircuitBreakerConfig      `mapstructure:"circuit_breaker" json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty"`
+	StreamWorkers    int                        `mapstructure:"stream_workers" json:"stream_workers,omitempty" yaml:"stream_workers,omitempty"`
+	Batch            *BatchConfig               `mapstructure:"batch" json:"batch,omitempty" yaml:"batch,omitempty"`
+	Routing          []string                   `mapstructure:"routing" json:"routing,omitempty" yaml:"routing,omitempty"`
+	Dedup            *dedup.Config              `mapstructure:"dedup" json:"dedup,omitempty" yaml:"dedup,omitempty"`
+	Residency        *routing.ResidencyConfig   `mapstructure:"residency" json:"residency,omitempty" yaml:"residency,omitempty"`
+	Compaction       *CompactionConfig          `mapstructure:"compaction" json:"compaction,omitempty" yaml:"compaction,omitempty"`
+	//Pool is a connection pool and statement timeout configuration of SQL destinations
+	Pool *adapters.PoolConfig `mapstructure:"pool" json:"pool,omitempty" yaml:"pool,omitempty"`
+	//MaxInflightUploads is a max number of simultaneous batch uploads (0 - unlimited)
+	MaxInflightUploads int `mapstructure:"max_inflight_uploads" json:"max_inflight_uploads,omitempty" yaml:"max_inflight_uploads,omitempty"`
+
+	DataSource      *adapters.DataSourceConfig       `mapstructure:"datasource" json:"datasource,omitempty" yaml:"datasource,omitempty"`
+	S3              *adapters.S3Config               `mapstructure:"s3" json:"s3,omitempty" yaml:"s3,omitempty"`
+	Spectrum        *adapters.RedshiftSpectrumConfig `mapstructure:"spectrum" json:"spectrum,omitempty" yaml:"spectrum,omitempty"`
+	Google          *adapters.GoogleConfig           `mapstructure:"google" json:"google,omitempty" yaml:"google,omitempty"`
+	GoogleAnalytics *adapters.GoogleAnalyticsConfig  `mapstructure:"google_analytics" json:"google_analytics,omitempty" yaml:"google_analytics,omitempty"`
+	ClickHouse      *adapters.ClickHouseConfig       `mapstructure:"clickhouse" json:"clickhouse,omitempty" yaml:"clickhouse,omitempty"`
+	Snowflake       *adapters.SnowflakeConfig        `mapstructure:"snowflake" json:"snowflake,omitempty" yaml:"snowflake,omitempty"`
+	Druid           *adapters.DruidConfig            `mapstructure:"druid" json:"druid,omitempty" yaml:"druid,omitempty"`
+	Pinot           *adapters.PinotConfig            `mapstructure:"pinot" json:"pinot,omitempty" yaml:"pinot,omitempty"`
+	DynamoDB        *adapters.DynamoDBConfig         `mapstructure:"dynamodb" json:"dynamodb,omitempty" yaml:"dynamodb,omitempty"`
+	GCS             *adapters.GCSConfig              `mapstructure:"gcs" json:"gcs,omitempty" yaml:"gcs,omitempty"`
+	Profiles        *adapters.ProfilesConfig         `mapstructure:"profiles" json:"profiles,omitempty" yaml:"profiles,omitempty"`
 }
 
 type DataLayout struct {
-	MappingType       schema.FieldMappingType `mapstructure:"mapping_type" json:"mapping_type,omitempty" yaml:"mapping_type,omitempty"`
-	Mapping           []string                `mapstructure:"mapping" json:"mapping,omitempty" yaml:"mapping,omitempty"`
-	Mappings          *schema.Mapping         `mapstructure:"mappings" json:"mappings,omitempty" yaml:"mappings,omitempty"`
-	TableNameTemplate string                  `mapstructure:"table_name_template" json:"table_name_template,omitempty" yaml:"table_name_template,omitempty"`
-	PrimaryKeyFields  []string                `mapstructure:"primary_key_fields" json:"primary_key_fields,omitempty" yaml:"primary_key_fields,omitempty"`
+	MappingType       schema.FieldMappingType    `mapstructure:"mapping_type" json:"mapping_type,omitempty" yaml:"mapping_type,omitempty"`
+	Mapping           []string                   `mapstructure:"mapping" json:"mapping,omitempty" yaml:"mapping,omitempty"`
+	Mappings          *schema.Mapping            `mapstructure:"mappings" json:"mappings,omitempty" yaml:"mappings,omitempty"`
+	TableNameTemplate string                     `mapstructure:"table_name_template" json:"table_name_template,omitempty" yaml:"table_name_template,omitempty"`
+	PrimaryKeyFields  []string                   `mapstructure:"primary_key_fields" json:"primary_key_fields,omitempty" yaml:"primary_key_fields,omitempty"`
+	Flattening        *schema.FlatteningConfig   `mapstructure:"flattening" json:"flattening,omitempty" yaml:"flattening,omitempty"`
+	SchemaOnRead      *schema.SchemaOnReadConfig `mapstructure:"schema_on_read" json:"schema_on_read,omitempty" yaml:"schema_on_read,omitempty"`
+	//Partitioning and ClusteringFields are applied only when tables are created
+	Partitioning     *adapters.PartitioningConfig `mapstructure:"partitioning" json:"partitioning,omitempty" yaml:"partitioning,omitempty"`
+	ClusteringFields []string                     `mapstructure:"clustering_fields" json:"clustering_fields,omitempty" yaml:"clustering_fields,omitempty"`
+}
+
+//GetTableOptions return configured partitioning and clustering or nil
+func (dl *DataLayout) GetTableOptions() *adapters.TableOptions {
+	if dl == nil || (dl.Partitioning == nil && len(dl.ClusteringFields) == 0) {
+		return nil
+	}
+
+	return &adapters.TableOptions{Partitioning: dl.Partitioning, ClusteringFields: dl.ClusteringFields}
+}
+
+//validatePool check connection pool values and max in-flight uploads
+func validatePool(destination DestinationConfig) error {
+	if err := destination.Pool.Validate(); err != nil {
+		return err
+	}
+	if destination.MaxInflightUploads < 0 {
+		return errors.New("max_inflight_uploads can't be negative")
+	}
+
+	return nil
+}
+
+//validateBatch check that batch flush policy is configured only in batch mode
+//ipLookupSources return source paths of configured ip_lookup enrichment rules
+func ipLookupSources(ruleConfigs []*enrichment.RuleConfig) []string {
+	var sources []string
+	for _, ruleConfig := range ruleConfigs {
+		if strings.ToLower(ruleConfig.Name) == enrichment.IpLookup {
+			sources = append(sources, ruleConfig.From)
+		}
+	}
+	return sources
+}
+
+func validateBatch(mode string, batch *BatchConfig) error {
+	if batch == nil {
+		return nil
+	}
+	if mode == StreamMode {
+		return errors.New("batch flush policy isn't supported in stream mode")
+	}
+
+	return batch.Validate()
+}
+
+//validateSchemaOnRead check that destination supports schema-on-read mode (and typed views)
+//validateTableOptions check that partitioning and clustering are supported by the destination type
+//BigQuery tables are partitioned only by day (with up to 4 clustering fields) and partitioning can't be combined with partition decorator
+func validateTableOptions(destination *DestinationConfig) error {
+	tableOptions := destination.DataLayout.GetTableOptions()
+	if tableOptions == nil {
+		return nil
+	}
+	if err := tableOptions.Validate(); err != nil {
+		return err
+	}
+
+	switch destination.Type {
+	case RedshiftType, PostgresType, ClickHouseType, SnowflakeType:
+	case BigQueryType:
+		if tableOptions.Partitioning != nil {
+			if tableOptions.Partitioning.GetGranularity() != adapters.DAY {
+				return fmt.Errorf("only %s partitioning granularity is supported in %s destination", adapters.DAY, BigQueryType)
+			}
+			if destination.Google != nil && destination.Google.PartitionDecorator {
+				return errors.New("partitioning can't be used with bq_partition_decorator")
+			}
+		}
+		if len(tableOptions.ClusteringFields) > 4 {
+			return fmt.Errorf("%s supports up to 4 clustering fields", BigQueryType)
+		}
+	default:
+		return fmt.Errorf("partitioning and clustering_fields aren't supported in %s destination", destination.Type)
+	}
+
+	return nil
+}
+
+func validateSchemaOnRead(destinationType string, dataLayout *DataLayout) error {
+	schemaOnRead := dataLayout.SchemaOnRead
+	if !schemaOnRead.IsEnabled() {
+		return nil
+	}
+	if err := schemaOnRead.Validate(); err != nil {
+		return err
+	}
+
+	switch destinationType {
+	case RedshiftType, BigQueryType, PostgresType, ClickHouseType, S3Type, SnowflakeType, GCSType:
+	default:
+		return fmt.Errorf("schema_on_read isn't supported in %s destination", destinationType)
+	}
+
+	if schemaOnRead.Views {
+		if destinationType != PostgresType {
+			return fmt.Errorf("schema_on_read views are supported only in %s destination", PostgresType)
+		}
+		if len(ViewColumns(dataLayout.Mappings)) == 0 {
+			return errors.New("schema_on_read views require data_layout.mappings fields with move action")
+		}
+	}
+
+	return nil
 }
 
 type UsersRecognition struct {
@@ -77,9 +215,121 @@ type Config struct {
 	monitorKeeper    MonitorKeeper
 	eventQueue       *events.PersistentQueue
 	eventsCache      *caching.EventsCache
+	circuitBreaker   *CircuitBreaker
+	batchPolicy      *BatchPolicy
+	streamWorkers    int
+	uploadsLimiter   *UploadsLimiter
 	loggerFactory    *logging.Factory
 	pkFields         map[string]bool
 	sqlTypeCasts     map[string]string
+	tableOptions     *adapters.TableOptions
+	//columnComments is a column name -> description from mappings
+	columnComments map[string]string
+}
+
+//Validate check destination configuration without connecting to the destination:
+//type, mode, enrichment and mapping rules, late events, routing, deduplication, columns limit and circuit breaker configurations
+func Validate(name string, destination DestinationConfig) error {
+	if destination.Type == "" {
+		destination.Type = name
+	}
+	switch destination.Type {
+	case RedshiftType, BigQueryType, PostgresType, ClickHouseType, S3Type, SnowflakeType, GoogleAnalyticsType, DruidType, PinotType, DynamoDBType, GCSType, ProfilesType:
+	default:
+		return fmt.Errorf("%v: %s", unknownDestination, destination.Type)
+	}
+
+	if destination.Mode != "" && destination.Mode != BatchMode && destination.Mode != StreamMode {
+		return fmt.Errorf("Unknown destination mode: %s. Available mode: [%s, %s]", destination.Mode, BatchMode, StreamMode)
+	}
+
+	for _, ruleConfig := range destination.Enrichment {
+		if _, err := enrichment.NewRule(ruleConfig); err != nil {
+			return fmt.Errorf("Error creating enrichment rule [%s]: %v", ruleConfig.String(), err)
+		}
+	}
+
+	if destination.DataLayout != nil {
+		if _, _, err := schema.NewFieldMapper(destination.DataLayout.MappingType, destination.DataLayout.Mapping, destination.DataLayout.Mappings); err != nil {
+			return err
+		}
+		if _, err := schema.NewConfiguredFlattener(destination.DataLayout.Flattening); err != nil {
+			return err
+		}
+		if err := validateSchemaOnRead(destination.Type, destination.DataLayout); err != nil {
+			return err
+		}
+		if err := validateTableOptions(&destination); err != nil {
+			return err
+		}
+	}
+
+	if err := destination.Timestamps.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := schema.NewLateEventsPolicy(name, destination.LateEvents); err != nil {
+		return err
+	}
+
+	if _, err := schema.NewConsentPolicy(name, destination.Consent); err != nil {
+		return err
+	}
+
+	if err := destination.IpAnonymization.Validate(); err != nil {
+		return err
+	}
+
+	if destination.Compaction != nil {
+		if destination.Type != S3Type && destination.Type != GCSType && (destination.Type != RedshiftType || destination.Spectrum == nil) {
+			return fmt.Errorf("compaction is supported only in %s, %s and %s (with spectrum) destinations", S3Type, GCSType, RedshiftType)
+		}
+		if err := destination.Compaction.Validate(); err != nil {
+			return err
+		}
+	}
+
+	sharding, err := schema.NewSharding(name, destination.Sharding)
+	if err != nil {
+		return err
+	}
+	if sharding.HasViews() && destination.Type != PostgresType && destination.Type != ClickHouseType {
+		return fmt.Errorf("sharding views are supported only in %s and %s destinations", PostgresType, ClickHouseType)
+	}
+
+	if _, err := routing.ParseRules(destination.Routing); err != nil {
+		return err
+	}
+
+	if _, err := routing.NewResidency(name, nil, destination.Residency, nil); err != nil {
+		return err
+	}
+
+	if err := destination.Dedup.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := schema.NewColumnsGuard(name, destination.ColumnsLimit); err != nil {
+		return err
+	}
+
+	if err := destination.CircuitBreaker.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateBatch(destination.Mode, destination.Batch); err != nil {
+		return err
+	}
+
+	if destination.StreamWorkers < 0 {
+		return errors.New("stream_workers can't be negative")
+	}
+
+	if err := validatePool(destination); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 //Create event storage proxy and event consumer (logger or event-queue)
@@ -112,6 +362,10 @@ func Create(ctx context.Context, name, logEventPath string, destination Destinat
 		for _, field := range destination.DataLayout.PrimaryKeyFields {
 			pkFields[field] = true
 		}
+
+		if err := validateTableOptions(&destination); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	if tableName == "" {
@@ -152,6 +406,15 @@ func Create(ctx context.Context, name, logEventPath string, destination Destinat
 		return nil, nil, err
 	}
 
+	var flatteningConfig *schema.FlatteningConfig
+	if destination.DataLayout != nil {
+		flatteningConfig = destination.DataLayout.Flattening
+	}
+	flattener, err := schema.NewConfiguredFlattener(flatteningConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	//write current mapping configuration to logs
 	if newStyleMapping != nil && len(newStyleMapping.Fields) != 0 {
 		mappingMode := "keep unmapped fields"
@@ -195,14 +458,136 @@ func Create(ctx context.Context, name, logEventPath string, destination Destinat
 		usersRecognition = &events.UserRecognitionConfiguration{Enabled: false}
 	}
 
-	processor, err := schema.NewProcessor(name, tableName, fieldMapper, enrichmentRules, destination.BreakOnError)
+	timestampsPolicy, err := schema.NewTimestampsPolicy(destination.Timestamps)
+	if err != nil {
+		return nil, nil, err
+	}
+	if destination.Timestamps != nil {
+		logging.Infof("[%s] Configured timestamps: partition by [%s] timestamp", name, timestampsPolicy.PartitionBy())
+	}
+
+	lateEventsPolicy, err := schema.NewLateEventsPolicy(name, destination.LateEvents)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lateEventsPolicy != nil {
+		logging.Infof("[%s] Configured late events policy: [%s] for events older than [%d] days", name, lateEventsPolicy.Policy(), destination.LateEvents.MaxAgeDays)
+	}
+
+	sharding, err := schema.NewSharding(name, destination.Sharding)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, shardingConfig := range destination.Sharding {
+		logging.Infof("[%s] Configured sharding of table [%s]: %d shards with [%s] policy (view: %t)", name, shardingConfig.Table, shardingConfig.Shards, shardingConfig.Policy, shardingConfig.View)
+	}
+
+	consentPolicy, err := schema.NewConsentPolicy(name, destination.Consent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if consentPolicy != nil {
+		logging.Infof("[%s] Configured consent policy: [%s] for events without [%s] consent (opt-in: %t)", name, destination.Consent.Policy, destination.Consent.Category, destination.Consent.OptIn)
+	}
+
+	ipAnonymizer, err := schema.NewIpAnonymizer(name, destination.IpAnonymization, ipLookupSources(destination.Enrichment))
 	if err != nil {
 		return nil, nil, err
 	}
+	if ipAnonymizer != nil {
+		logging.Infof("[%s] Configured IP anonymization: [%s] of %v", name, destination.IpAnonymization.Mode, ipAnonymizer.Fields())
+	}
+
+	routingRules, err := routing.ParseRules(destination.Routing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	residency, err := routing.NewResidency(name, destination.OnlyTokens, destination.Residency, appconfig.Instance.GeoResolver)
+	if err != nil {
+		return nil, nil, err
+	}
+	if destination.Residency != nil {
+		logging.Infof("[%s] Configured data residency: %v (strict: %t)", name, destination.Residency.Countries, destination.Residency.Strict)
+	}
+	routingRules = routingRules.WithResidency(residency)
+
+	dedupWindow, err := dedup.NewWindow(dedup.DestinationPrefix+name, destination.Dedup)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dedupWindow != nil {
+		logging.Infof("[%s] Configured events deduplication window: [%s]", name, dedupWindow.TTL())
+	}
+
+	columnsGuard, err := schema.NewColumnsGuard(name, destination.ColumnsLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if columnsGuard != nil {
+		logging.Infof("[%s] Configured columns limit: [%d] with overflow: [%s]", name, destination.ColumnsLimit.MaxColumns, destination.ColumnsLimit.Overflow)
+	}
+
+	processor, err := schema.NewProcessor(name, tableName, fieldMapper, enrichmentRules, destination.BreakOnError, &schema.ProcessorOptions{
+		Flattener:        flattener,
+		TimestampsPolicy: timestampsPolicy,
+		LateEventsPolicy: lateEventsPolicy,
+		Sharding:         sharding,
+		ConsentPolicy:    consentPolicy,
+		IpAnonymizer:     ipAnonymizer,
+		RoutingRules:     routingRules,
+		DedupWindow:      dedupWindow,
+		ColumnsGuard:     columnsGuard,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if destination.DataLayout != nil && destination.DataLayout.SchemaOnRead.IsEnabled() {
+		if err := validateSchemaOnRead(destination.Type, destination.DataLayout); err != nil {
+			return nil, nil, err
+		}
+		schemaOnRead := destination.DataLayout.SchemaOnRead
+		processor.SetRawColumn(schemaOnRead.RawColumn())
+		logging.Infof("[%s] Configured schema-on-read mode: events are stored as JSON in [%s] column (typed views: %t)", name, schemaOnRead.RawColumn(), schemaOnRead.Views)
+	}
+
+	if err := destination.CircuitBreaker.Validate(); err != nil {
+		return nil, nil, err
+	}
+	circuitBreaker := NewCircuitBreaker(name, destination.CircuitBreaker)
+	if circuitBreaker != nil {
+		logging.Infof("[%s] Configured circuit breaker: open after [%d] consecutive failures for [%s]", name, circuitBreaker.failureThreshold, circuitBreaker.openTimeout)
+	}
+
+	if err := validateBatch(destination.Mode, destination.Batch); err != nil {
+		return nil, nil, err
+	}
+	batchPolicy := NewBatchPolicy(destination.Batch)
+	if batchPolicy != nil {
+		logging.Infof("[%s] Configured batch flush policy: every [%d] min or [%d] rows or [%d] bytes with [%d] workers", name,
+			destination.Batch.IntervalMin, destination.Batch.MaxRows, destination.Batch.MaxBytes, batchPolicy.Workers())
+	}
+
+	if destination.StreamWorkers < 0 {
+		return nil, nil, errors.New("stream_workers can't be negative")
+	}
+	if destination.StreamWorkers == 0 {
+		destination.StreamWorkers = defaultStreamWorkers
+	}
+
+	if err := validatePool(destination); err != nil {
+		return nil, nil, err
+	}
+	uploadsLimiter := NewUploadsLimiter(destination.MaxInflightUploads)
+	if destination.Pool != nil || uploadsLimiter != nil {
+		logging.Infof("[%s] Configured connection pool: max open [%d] idle [%d] connections, statement timeout [%d] sec, max in-flight uploads [%d]", name,
+			destination.Pool.GetMaxOpenConns(), destination.Pool.GetMaxIdleConns(), destination.Pool.GetStatementTimeoutSec(), destination.MaxInflightUploads)
+	}
 
 	var eventQueue *events.PersistentQueue
 	if destination.Mode == StreamMode {
-		eventQueue, err = events.NewPersistentQueue("queue.dst="+name, logEventPath)
+		eventQueue, err = events.NewPersistentQueue("queue.dst="+name, logEventPath, appconfig.Instance.Priority)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -218,9 +603,15 @@ func Create(ctx context.Context, name, logEventPath string, destination Destinat
 		monitorKeeper:    monitorKeeper,
 		eventQueue:       eventQueue,
 		eventsCache:      eventsCache,
+		circuitBreaker:   circuitBreaker,
+		batchPolicy:      batchPolicy,
+		streamWorkers:    destination.StreamWorkers,
+		uploadsLimiter:   uploadsLimiter,
 		loggerFactory:    loggerFactory,
 		pkFields:         pkFields,
 		sqlTypeCasts:     sqlTypeCasts,
+		tableOptions:     destination.DataLayout.GetTableOptions(),
+		columnComments:   schema.ColumnDescriptions(newStyleMapping),
 	}
 
 	var storageProxy events.StorageProxy
@@ -239,6 +630,16 @@ func Create(ctx context.Context, name, logEventPath string, destination Destinat
 		storageProxy = newProxy(NewSnowflake, storageConfig)
 	case GoogleAnalyticsType:
 		storageProxy = newProxy(NewGoogleAnalytics, storageConfig)
+	case DruidType:
+		storageProxy = newProxy(NewDruid, storageConfig)
+	case PinotType:
+		storageProxy = newProxy(NewPinot, storageConfig)
+	case DynamoDBType:
+		storageProxy = newProxy(NewDynamoDB, storageConfig)
+	case GCSType:
+		storageProxy = newProxy(NewGCS, storageConfig)
+	case ProfilesType:
+		storageProxy = newProxy(NewProfiles, storageConfig)
 	default:
 		if eventQueue != nil {
 			eventQueue.Close()
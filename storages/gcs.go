@@ -0,0 +1,162 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/parsers"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//GCS stores batch files into Google Cloud Storage bucket (1 file = 1 object per table) in JSON, CSV or Parquet format
+type GCS struct {
+	name           string
+	gcsAdapter     *adapters.GCS
+	processor      *schema.Processor
+	fallbackLogger *logging.AsyncLogger
+	eventsCache    *caching.EventsCache
+	compactor      *Compactor
+	uploadsLimiter *UploadsLimiter
+}
+
+func NewGCS(config *Config) (events.Storage, error) {
+	if config.streamMode {
+		if config.eventQueue != nil {
+			config.eventQueue.Close()
+		}
+		return nil, fmt.Errorf("GCS destination doesn't support %s mode", StreamMode)
+	}
+	gcsConfig := config.destination.GCS
+	if err := gcsConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	gcsAdapter, err := adapters.NewGCS(config.ctx, gcsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCS{
+		name:           config.name,
+		gcsAdapter:     gcsAdapter,
+		processor:      config.processor,
+		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
+		eventsCache:    config.eventsCache,
+		uploadsLimiter: config.uploadsLimiter,
+		compactor:      NewCompactor(config.name, gcsAdapter, config.monitorKeeper, config.destination.Compaction),
+	}, nil
+}
+
+func (g *GCS) Consume(event events.Event, tokenId string) {
+	logging.Errorf("[%s] GCS storage doesn't support streaming mode", g.Name())
+}
+
+//Store call StoreWithParseFunc with parsers.ParseJson func
+func (g *GCS) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	return g.StoreWithParseFunc(fileName, payload, alreadyUploadedTables, parsers.ParseJson)
+}
+
+//StoreWithParseFunc store file payload to Google Cloud Storage with processing: one object per table
+//return result per table, failed events count and err if occurred
+func (g *GCS) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
+	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	g.uploadsLimiter.Acquire()
+	defer g.uploadsLimiter.Release()
+
+	flatData, failedEvents, err := g.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
+	if err != nil {
+		return nil, linesCount(payload), err
+	}
+
+	//update cache with failed events
+	for _, failedEvent := range failedEvents {
+		g.eventsCache.Error(g.Name(), failedEvent.EventId, failedEvent.Error)
+	}
+
+	var tokenId string
+	if regexResult := logging.TokenIdExtractRegexp.FindStringSubmatch(fileName); len(regexResult) == 2 {
+		tokenId = regexResult[1]
+	}
+
+	storeFailedEvents := true
+	tableResults := map[string]*events.StoreResult{}
+	for _, fdata := range flatData {
+		tableName := fdata.BatchHeader.TableName
+		key, err := g.gcsAdapter.Upload(tableName, tokenId, fileName, fdata.BatchHeader.Fields, fdata.GetPayload())
+
+		tableResults[tableName] = &events.StoreResult{Err: err, RowsCount: fdata.GetPayloadLen()}
+		if err != nil {
+			logging.Errorf("[%s] Error storing file %s into table %s: %v", g.Name(), fileName, tableName, err)
+			storeFailedEvents = false
+		} else {
+			logging.Debugf("[%s] File %s table %s has been stored as %s", g.Name(), fileName, tableName, key)
+		}
+
+		//events cache
+		for _, object := range fdata.GetPayload() {
+			if err != nil {
+				g.eventsCache.Error(g.Name(), events.ExtractEventId(object), err.Error())
+			}
+		}
+	}
+
+	//store failed events to fallback only if other events have been inserted ok
+	if storeFailedEvents {
+		g.Fallback(failedEvents...)
+	}
+
+	return tableResults, len(failedEvents), nil
+}
+
+//Fallback log event with error to fallback logger
+func (g *GCS) Fallback(failedEvents ...*events.FailedEvent) {
+	for _, failedEvent := range failedEvents {
+		g.fallbackLogger.ConsumeAny(failedEvent)
+	}
+	counters.DestinationStage(g.Name(), counters.StageFallback, len(failedEvents))
+}
+
+func (g *GCS) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
+	return 0, errors.New("GCS doesn't support sync store")
+}
+
+func (g *GCS) GetUsersRecognition() *events.UserRecognitionConfiguration {
+	return disabledRecognitionConfiguration
+}
+
+//Processor return schema processor which is used for dry runs
+func (g *GCS) Processor() *schema.Processor {
+	return g.processor
+}
+
+func (g *GCS) Name() string {
+	return g.name
+}
+
+func (g *GCS) Type() string {
+	return GCSType
+}
+
+//HealthCheck check destination connectivity without writing any data
+func (g *GCS) HealthCheck() error {
+	return g.gcsAdapter.Test()
+}
+
+func (g *GCS) Close() (multiErr error) {
+	g.compactor.Close()
+
+	if err := g.gcsAdapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing google cloud storage client: %v", g.Name(), err))
+	}
+
+	if err := g.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", g.Name(), err))
+	}
+
+	return
+}
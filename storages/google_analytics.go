@@ -6,6 +6,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/schema"
@@ -34,7 +35,7 @@ func NewGoogleAnalytics(config *Config) (events.Storage, error) {
 	requestDebugLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
 	gaAdapter := adapters.NewGoogleAnalytics(gaConfig, requestDebugLogger)
 
-	tableHelper := NewTableHelper(gaAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToGoogleAnalytics)
+	tableHelper := NewTableHelper(gaAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToGoogleAnalytics, config.columnComments)
 
 	ga := &GoogleAnalytics{
 		name:           config.name,
@@ -45,7 +46,7 @@ func NewGoogleAnalytics(config *Config) (events.Storage, error) {
 		eventsCache:    config.eventsCache,
 	}
 
-	ga.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ga, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+	ga.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ga, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
 	ga.streamingWorker.start()
 
 	return ga, nil
@@ -76,6 +77,17 @@ func (ga *GoogleAnalytics) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		ga.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(ga.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (ga *GoogleAnalytics) RefreshSchema() ([]string, error) {
+	return ga.tableHelper.RefreshAllTables(ga.Name())
+}
+
+//Processor return schema processor which is used for dry runs
+func (ga *GoogleAnalytics) Processor() *schema.Processor {
+	return ga.processor
 }
 
 func (ga *GoogleAnalytics) Name() string {
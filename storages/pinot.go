@@ -0,0 +1,130 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+)
+
+//Pinot is a stream only destination: events are produced into Kafka and consumed by Pinot REALTIME tables
+type Pinot struct {
+	name            string
+	pinotAdapter    *adapters.Pinot
+	tableHelper     *TableHelper
+	processor       *schema.Processor
+	streamingWorker *StreamingWorker
+	fallbackLogger  *logging.AsyncLogger
+	eventsCache     *caching.EventsCache
+}
+
+func NewPinot(config *Config) (events.Storage, error) {
+	if !config.streamMode {
+		return nil, fmt.Errorf("Pinot destination doesn't support %s mode", BatchMode)
+	}
+
+	pConfig := config.destination.Pinot
+	if err := pConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	requestDebugLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
+	pinotAdapter := adapters.NewPinot(config.ctx, pConfig, requestDebugLogger)
+
+	tableHelper := NewTableHelper(pinotAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToPinot, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
+
+	p := &Pinot{
+		name:           config.name,
+		pinotAdapter:   pinotAdapter,
+		tableHelper:    tableHelper,
+		processor:      config.processor,
+		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
+		eventsCache:    config.eventsCache,
+	}
+
+	p.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, p, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+	p.streamingWorker.start()
+
+	return p, nil
+}
+
+//Insert ensure Pinot table schema and produce event into the table Kafka topic
+func (p *Pinot) Insert(dataSchema *adapters.Table, event events.Event) (err error) {
+	dbTable, err := p.tableHelper.EnsureTable(p.Name(), dataSchema)
+	if err != nil {
+		return err
+	}
+
+	return p.pinotAdapter.Insert(dbTable, event)
+}
+
+func (p *Pinot) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Pinot doesn't support Store() func")
+}
+
+func (p *Pinot) StoreWithParseFunc(fileName string, payload []byte, skipTables map[string]bool, parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Pinot doesn't support StoreWithParseFunc() func")
+}
+
+func (p *Pinot) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
+	return 0, errors.New("Pinot doesn't support SyncStore() func")
+}
+
+func (p *Pinot) GetUsersRecognition() *events.UserRecognitionConfiguration {
+	return disabledRecognitionConfiguration
+}
+
+//Fallback log event with error to fallback logger
+func (p *Pinot) Fallback(failedEvents ...*events.FailedEvent) {
+	for _, failedEvent := range failedEvents {
+		p.fallbackLogger.ConsumeAny(failedEvent)
+	}
+	counters.DestinationStage(p.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (p *Pinot) RefreshSchema() ([]string, error) {
+	return p.tableHelper.RefreshAllTables(p.Name())
+}
+
+//Processor return schema processor which is used for dry runs
+func (p *Pinot) Processor() *schema.Processor {
+	return p.processor
+}
+
+func (p *Pinot) Name() string {
+	return p.name
+}
+
+func (p *Pinot) Type() string {
+	return PinotType
+}
+
+//HealthCheck check destination connectivity without writing any data
+func (p *Pinot) HealthCheck() error {
+	return p.pinotAdapter.Test()
+}
+
+func (p *Pinot) Close() (multiErr error) {
+	if err := p.pinotAdapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing Pinot client: %v", p.Name(), err))
+	}
+
+	if p.streamingWorker != nil {
+		if err := p.streamingWorker.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing streaming worker: %v", p.Name(), err))
+		}
+	}
+
+	if err := p.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", p.Name(), err))
+	}
+
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
@@ -18,11 +19,14 @@ type Postgres struct {
 	name                          string
 	adapter                       *adapters.Postgres
 	tableHelper                   *TableHelper
+	viewsHelper                   *ViewsHelper
+	shardsViewHelper              *ShardsViewHelper
 	processor                     *schema.Processor
 	streamingWorker               *StreamingWorker
 	fallbackLogger                *logging.AsyncLogger
 	eventsCache                   *caching.EventsCache
 	usersRecognitionConfiguration *events.UserRecognitionConfiguration
+	uploadsLimiter                *UploadsLimiter
 }
 
 func NewPostgres(config *Config) (events.Storage, error) {
@@ -43,12 +47,14 @@ func NewPostgres(config *Config) (events.Storage, error) {
 	if _, ok := pgConfig.Parameters["connect_timeout"]; !ok {
 		pgConfig.Parameters["connect_timeout"] = "600"
 	}
+	config.destination.Pool.EnrichPostgresParameters(pgConfig.Parameters)
 
 	queryLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
-	adapter, err := adapters.NewPostgres(config.ctx, pgConfig, queryLogger, config.sqlTypeCasts)
+	adapter, err := adapters.NewPostgres(config.ctx, pgConfig, queryLogger, config.sqlTypeCasts, config.tableOptions)
 	if err != nil {
 		return nil, err
 	}
+	adapter.ConfigurePool(config.destination.Pool)
 
 	//create db schema if doesn't exist
 	err = adapter.CreateDbSchema(pgConfig.Schema)
@@ -57,20 +63,24 @@ func NewPostgres(config *Config) (events.Storage, error) {
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(adapter, config.monitorKeeper, config.pkFields, adapters.SchemaToPostgres)
+	tableHelper := NewTableHelper(adapter, config.monitorKeeper, config.pkFields, adapters.SchemaToPostgres, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
 
 	p := &Postgres{
 		name:                          config.name,
 		adapter:                       adapter,
 		tableHelper:                   tableHelper,
+		viewsHelper:                   config.viewsHelper(adapter),
+		shardsViewHelper:              NewShardsViewHelper(config.name, adapter, tableHelper, config.processor.Sharding()),
 		processor:                     config.processor,
 		fallbackLogger:                config.loggerFactory.CreateFailedLogger(config.name),
 		eventsCache:                   config.eventsCache,
+		uploadsLimiter:                config.uploadsLimiter,
 		usersRecognitionConfiguration: config.usersRecognition,
 	}
 
 	if config.streamMode {
-		p.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, p, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+		p.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, p, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
 		p.streamingWorker.start()
 	}
 
@@ -86,6 +96,9 @@ func (p *Postgres) Store(fileName string, payload []byte, alreadyUploadedTables
 //return result per table, failed events count and err if occurred
 func (p *Postgres) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	p.uploadsLimiter.Acquire()
+	defer p.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := p.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -131,6 +144,7 @@ func (p *Postgres) storeTable(fdata *schema.ProcessedFile, table *adapters.Table
 	if err != nil {
 		return err
 	}
+	p.ensureView(dbSchema)
 
 	if err := p.adapter.BulkInsert(dbSchema, fdata.GetPayload()); err != nil {
 		return err
@@ -144,6 +158,7 @@ func (p *Postgres) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		p.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(p.Name(), counters.StageFallback, len(failedEvents))
 }
 
 //SyncStore is used in two cases:
@@ -152,6 +167,9 @@ func (p *Postgres) Fallback(failedEvents ...*events.FailedEvent) {
 //return rows count and err if can't store
 //or rows count and nil if stored
 func (p *Postgres) SyncStore(overriddenCollectionTable string, objects []map[string]interface{}, timeIntervalValue string) (rowsCount int, err error) {
+	p.uploadsLimiter.Acquire()
+	defer p.uploadsLimiter.Release()
+
 	flatData, err := p.processor.ProcessObjects(objects)
 	if err != nil {
 		return len(objects), err
@@ -173,6 +191,7 @@ func (p *Postgres) SyncStore(overriddenCollectionTable string, objects []map[str
 		if err != nil {
 			return 0, err
 		}
+		p.ensureView(dbSchema)
 		if err = p.adapter.BulkUpdate(dbSchema, fdata.GetPayload(), deleteConditions); err != nil {
 			return rowsCount, err
 		}
@@ -187,6 +206,7 @@ func (p *Postgres) Insert(table *adapters.Table, event events.Event) (err error)
 	if err != nil {
 		return err
 	}
+	p.ensureView(dbTable)
 
 	err = p.adapter.Insert(dbTable, event)
 
@@ -203,12 +223,25 @@ func (p *Postgres) Insert(table *adapters.Table, event events.Event) (err error)
 	return nil
 }
 
+//ensureView create schema-on-read typed view over the table and view over table shards if configured
+//view errors don't affect data loading: creation will be retried with the next table write
+func (p *Postgres) ensureView(dbSchema *adapters.Table) {
+	if err := p.viewsHelper.EnsureView(dbSchema.Name); err != nil {
+		logging.SystemErrorf("[%s] %v", p.Name(), err)
+	}
+	if err := p.shardsViewHelper.EnsureView(dbSchema); err != nil {
+		logging.SystemErrorf("[%s] %v", p.Name(), err)
+	}
+}
+
 func (p *Postgres) GetUsersRecognition() *events.UserRecognitionConfiguration {
 	return p.usersRecognitionConfiguration
 }
 
 //Close adapters.Postgres
 func (p *Postgres) Close() (multiErr error) {
+	p.viewsHelper.Close()
+
 	if err := p.adapter.Close(); err != nil {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing postgres datasource: %v", p.Name(), err))
 	}
@@ -226,6 +259,26 @@ func (p *Postgres) Close() (multiErr error) {
 	return
 }
 
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (p *Postgres) RefreshSchema() ([]string, error) {
+	return p.tableHelper.RefreshAllTables(p.Name())
+}
+
+//ExportUserRows return rows of all schema tables where any of user columns equals userId
+func (p *Postgres) ExportUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return p.adapter.SelectUserRows(userColumns, userId)
+}
+
+//DropTable drop table in the warehouse and in-memory schema cache
+func (p *Postgres) DropTable(tableName string) error {
+	return p.tableHelper.DropTable(p.Name(), tableName)
+}
+
+//Processor return schema processor which is used for dry runs
+func (p *Postgres) Processor() *schema.Processor {
+	return p.processor
+}
+
 func (p *Postgres) Name() string {
 	return p.name
 }
@@ -233,3 +286,8 @@ func (p *Postgres) Name() string {
 func (p *Postgres) Type() string {
 	return PostgresType
 }
+
+//HealthCheck check destination connectivity without writing any data
+func (p *Postgres) HealthCheck() error {
+	return p.adapter.Test()
+}
@@ -0,0 +1,196 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/jitsucom/eventnative/timestamp"
+	"strings"
+	"time"
+)
+
+//Profiles materializes the latest user traits keyed by user id into Redis or Postgres (stream mode only):
+//every processed event with user id upserts its traits columns into the user profile
+type Profiles struct {
+	name            string
+	config          *adapters.ProfilesConfig
+	profilesStore   adapters.ProfilesStore
+	eventTypes      map[string]bool
+	processor       *schema.Processor
+	streamingWorker *StreamingWorker
+	fallbackLogger  *logging.AsyncLogger
+}
+
+func NewProfiles(config *Config) (events.Storage, error) {
+	if !config.streamMode {
+		return nil, fmt.Errorf("Profiles destination doesn't support %s mode", BatchMode)
+	}
+
+	profilesConfig := config.destination.Profiles
+	if err := profilesConfig.Validate(config.destination.DataSource); err != nil {
+		return nil, err
+	}
+
+	var profilesStore adapters.ProfilesStore
+	if profilesConfig.Storage == adapters.ProfilesRedisStorage {
+		redisProfiles, err := adapters.NewRedisProfiles(profilesConfig.Redis)
+		if err != nil {
+			return nil, err
+		}
+		profilesStore = redisProfiles
+	} else {
+		pgConfig := config.destination.DataSource
+		if pgConfig.Port <= 0 {
+			pgConfig.Port = 5432
+		}
+		if pgConfig.Schema == "" {
+			pgConfig.Schema = "public"
+		}
+		config.destination.Pool.EnrichPostgresParameters(pgConfig.Parameters)
+		postgresProfiles, err := adapters.NewPostgresProfiles(config.ctx, pgConfig, profilesConfig.Table, config.loggerFactory.CreateSQLQueryLogger(config.name))
+		if err != nil {
+			return nil, err
+		}
+		postgresProfiles.ConfigurePool(config.destination.Pool)
+		profilesStore = postgresProfiles
+	}
+
+	eventTypes := map[string]bool{}
+	for _, eventType := range profilesConfig.EventTypes {
+		eventTypes[eventType] = true
+	}
+
+	p := &Profiles{
+		name:           config.name,
+		config:         profilesConfig,
+		profilesStore:  profilesStore,
+		eventTypes:     eventTypes,
+		processor:      config.processor,
+		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
+	}
+
+	tableHelper := NewTableHelper(&profilesTableManager{}, config.monitorKeeper, config.pkFields, adapters.SchemaToProfiles, config.columnComments)
+	p.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, p, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+	p.streamingWorker.start()
+
+	return p, nil
+}
+
+//Insert upsert event traits into the user profile. Events without user id or with not configured event type are skipped
+func (p *Profiles) Insert(table *adapters.Table, event events.Event) error {
+	userId, traits, ok := p.extractTraits(event)
+	if !ok {
+		return nil
+	}
+
+	updatedAt := time.Now().UTC()
+	if eventTime, ok := event[timestamp.Key].(time.Time); ok {
+		updatedAt = eventTime
+	}
+
+	return p.profilesStore.Upsert(userId, traits, updatedAt)
+}
+
+//extractTraits return user id and trait columns without prefix
+//return false if event doesn't have user id or isn't a profile event
+func (p *Profiles) extractTraits(event events.Event) (string, map[string]interface{}, bool) {
+	userId := fmt.Sprint(event[p.config.UserIdField])
+	if event[p.config.UserIdField] == nil || userId == "" {
+		return "", nil, false
+	}
+
+	if len(p.eventTypes) > 0 && !p.eventTypes[fmt.Sprint(event[p.config.EventTypeField])] {
+		return "", nil, false
+	}
+
+	traits := map[string]interface{}{}
+	for column, value := range event {
+		if column == p.config.UserIdField || !strings.HasPrefix(column, p.config.TraitsPrefix) {
+			continue
+		}
+		if name := strings.TrimPrefix(column, p.config.TraitsPrefix); name != "" {
+			traits[name] = value
+		}
+	}
+
+	return userId, traits, true
+}
+
+func (p *Profiles) Store(fileName string, payload []byte, alreadyUploadedTables map[string]bool) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Profiles doesn't support Store() func")
+}
+
+func (p *Profiles) StoreWithParseFunc(fileName string, payload []byte, skipTables map[string]bool, parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	return nil, 0, errors.New("Profiles doesn't support StoreWithParseFunc() func")
+}
+
+func (p *Profiles) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
+	return 0, errors.New("Profiles doesn't support SyncStore() func")
+}
+
+func (p *Profiles) GetUsersRecognition() *events.UserRecognitionConfiguration {
+	return disabledRecognitionConfiguration
+}
+
+//Fallback log event with error to fallback logger
+func (p *Profiles) Fallback(failedEvents ...*events.FailedEvent) {
+	for _, failedEvent := range failedEvents {
+		p.fallbackLogger.ConsumeAny(failedEvent)
+	}
+	counters.DestinationStage(p.Name(), counters.StageFallback, len(failedEvents))
+}
+
+//Processor return schema processor which is used for dry runs
+func (p *Profiles) Processor() *schema.Processor {
+	return p.processor
+}
+
+func (p *Profiles) Name() string {
+	return p.name
+}
+
+func (p *Profiles) Type() string {
+	return ProfilesType
+}
+
+//HealthCheck check Redis or Postgres connectivity
+func (p *Profiles) HealthCheck() error {
+	return p.profilesStore.Test()
+}
+
+func (p *Profiles) Close() (multiErr error) {
+	if err := p.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing streaming worker: %v", p.Name(), err))
+	}
+
+	if err := p.profilesStore.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing profiles store: %v", p.Name(), err))
+	}
+
+	if err := p.fallbackLogger.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing fallback logger: %v", p.Name(), err))
+	}
+
+	return
+}
+
+//profilesTableManager is a no-op table manager: profiles don't use tables schema
+type profilesTableManager struct{}
+
+//GetTableSchema always return empty schema
+func (ptm *profilesTableManager) GetTableSchema(tableName string) (*adapters.Table, error) {
+	return &adapters.Table{Name: tableName, Columns: adapters.Columns{}, PKFields: map[string]bool{}}, nil
+}
+
+func (ptm *profilesTableManager) CreateTable(schemaToCreate *adapters.Table) error {
+	return nil
+}
+
+func (ptm *profilesTableManager) PatchTableSchema(schemaToAdd *adapters.Table) error {
+	return nil
+}
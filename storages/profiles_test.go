@@ -0,0 +1,66 @@
+package storages
+
+import (
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type profilesStoreMock struct {
+	profiles map[string]map[string]interface{}
+}
+
+func (psm *profilesStoreMock) Upsert(userId string, traits map[string]interface{}, updatedAt time.Time) error {
+	profile, ok := psm.profiles[userId]
+	if !ok {
+		profile = map[string]interface{}{}
+		psm.profiles[userId] = profile
+	}
+	for name, value := range traits {
+		profile[name] = value
+	}
+	profile["_updated_at"] = updatedAt
+
+	return nil
+}
+
+func (psm *profilesStoreMock) Test() error {
+	return nil
+}
+
+func (psm *profilesStoreMock) Close() error {
+	return nil
+}
+
+func TestProfilesInsert(t *testing.T) {
+	config := &adapters.ProfilesConfig{Storage: adapters.ProfilesRedisStorage, Redis: &adapters.ProfilesRedisConfig{Host: "localhost"}, EventTypes: []string{"user_identify"}}
+	require.NoError(t, config.Validate(nil))
+	require.Equal(t, "eventn_ctx_user_id", config.UserIdField)
+	require.Equal(t, "profile:", config.Redis.KeyPrefix)
+	require.EqualError(t, (&adapters.ProfilesConfig{Storage: "mongo"}).Validate(nil), "Unknown profiles storage: mongo. Available storages: [redis, postgres]")
+	require.EqualError(t, (&adapters.ProfilesConfig{Storage: adapters.ProfilesPostgresStorage}).Validate(nil), "Datasource config is required")
+
+	store := &profilesStoreMock{profiles: map[string]map[string]interface{}{}}
+	p := &Profiles{config: config, profilesStore: store, eventTypes: map[string]bool{"user_identify": true}}
+
+	first := time.Date(2020, 10, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	inputs := []events.Event{
+		{"event_type": "user_identify", "eventn_ctx_user_id": "u1", "eventn_ctx_user_email": "a@b.com", "eventn_ctx_user_plan_name": "free", timestamp.Key: first},
+		//not a profile event
+		{"event_type": "pageview", "eventn_ctx_user_id": "u1", "eventn_ctx_user_plan_name": "trial", timestamp.Key: second},
+		//without user id
+		{"event_type": "user_identify", "eventn_ctx_user_email": "anonymous@b.com"},
+		{"event_type": "user_identify", "eventn_ctx_user_id": "u1", "eventn_ctx_user_plan_name": "pro", "url": "https://b.com", timestamp.Key: second},
+	}
+	for _, event := range inputs {
+		require.NoError(t, p.Insert(nil, event))
+	}
+
+	require.Equal(t, map[string]map[string]interface{}{
+		"u1": {"email": "a@b.com", "plan_name": "pro", "_updated_at": second},
+	}, store.profiles)
+}
@@ -56,6 +56,11 @@ func (rsp *RetryableProxy) Get() (events.Storage, bool) {
 	return rsp.storage, rsp.ready
 }
 
+//CircuitBreaker return destination circuit breaker or nil if it isn't configured
+func (rsp *RetryableProxy) CircuitBreaker() *CircuitBreaker {
+	return rsp.config.circuitBreaker
+}
+
 func (rsp *RetryableProxy) Close() error {
 	rsp.closed = true
 	if rsp.storage != nil {
@@ -63,3 +68,12 @@ func (rsp *RetryableProxy) Close() error {
 	}
 	return nil
 }
+
+//GetCircuitBreaker return storage proxy circuit breaker or nil if proxy doesn't have it
+func GetCircuitBreaker(storageProxy events.StorageProxy) *CircuitBreaker {
+	if rsp, ok := storageProxy.(*RetryableProxy); ok {
+		return rsp.CircuitBreaker()
+	}
+
+	return nil
+}
@@ -6,24 +6,30 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
 	"github.com/jitsucom/eventnative/schema"
+	"time"
 )
 
 //Store files to aws RedShift in two modes:
 //batch: via aws s3 in batch mode (1 file = 1 statement)
 //stream: via events queue in stream mode (1 object = 1 statement)
+//if spectrum is configured (batch mode only) - files are kept in s3 and registered as external tables partitions by load date
 type AwsRedshift struct {
 	name            string
 	s3Adapter       *adapters.S3
 	redshiftAdapter *adapters.AwsRedshift
+	spectrumAdapter *adapters.RedshiftSpectrum
 	tableHelper     *TableHelper
 	processor       *schema.Processor
 	streamingWorker *StreamingWorker
 	fallbackLogger  *logging.AsyncLogger
 	eventsCache     *caching.EventsCache
+	compactor       *Compactor
+	uploadsLimiter  *UploadsLimiter
 }
 
 //NewAwsRedshift return AwsRedshift and start goroutine for aws redshift batch storage or for stream consumer depend on destination mode
@@ -45,6 +51,7 @@ func NewAwsRedshift(config *Config) (events.Storage, error) {
 	if _, ok := redshiftConfig.Parameters["connect_timeout"]; !ok {
 		redshiftConfig.Parameters["connect_timeout"] = "600"
 	}
+	config.destination.Pool.EnrichPostgresParameters(redshiftConfig.Parameters)
 
 	var s3Adapter *adapters.S3
 	if !config.streamMode {
@@ -56,10 +63,11 @@ func NewAwsRedshift(config *Config) (events.Storage, error) {
 	}
 
 	queryLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
-	redshiftAdapter, err := adapters.NewAwsRedshift(config.ctx, redshiftConfig, config.destination.S3, queryLogger, config.sqlTypeCasts)
+	redshiftAdapter, err := adapters.NewAwsRedshift(config.ctx, redshiftConfig, config.destination.S3, queryLogger, config.sqlTypeCasts, config.tableOptions)
 	if err != nil {
 		return nil, err
 	}
+	redshiftAdapter.ConfigurePool(config.destination.Pool)
 
 	//create db schema if doesn't exist
 	err = redshiftAdapter.CreateDbSchema(redshiftConfig.Schema)
@@ -68,20 +76,49 @@ func NewAwsRedshift(config *Config) (events.Storage, error) {
 		return nil, err
 	}
 
-	tableHelper := NewTableHelper(redshiftAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToRedshift)
+	tableHelper := NewTableHelper(redshiftAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToRedshift, config.columnComments)
+
+	var spectrumAdapter *adapters.RedshiftSpectrum
+	if config.destination.Spectrum != nil {
+		if config.streamMode {
+			redshiftAdapter.Close()
+			return nil, errors.New("Redshift spectrum is supported only in batch mode")
+		}
+
+		spectrumAdapter, err = adapters.NewRedshiftSpectrum(redshiftAdapter, config.destination.Spectrum)
+		if err != nil {
+			redshiftAdapter.Close()
+			return nil, err
+		}
+
+		//create external schema if doesn't exist
+		if err := spectrumAdapter.CreateExternalSchema(); err != nil {
+			redshiftAdapter.Close()
+			return nil, err
+		}
+
+		tableHelper = NewTableHelper(spectrumAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToRedshift, config.columnComments)
+	}
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
 
 	ar := &AwsRedshift{
 		name:            config.name,
 		s3Adapter:       s3Adapter,
 		redshiftAdapter: redshiftAdapter,
+		spectrumAdapter: spectrumAdapter,
 		tableHelper:     tableHelper,
 		processor:       config.processor,
 		fallbackLogger:  config.loggerFactory.CreateFailedLogger(config.name),
 		eventsCache:     config.eventsCache,
+		uploadsLimiter:  config.uploadsLimiter,
+	}
+	if spectrumAdapter != nil {
+		//only spectrum files are kept in s3
+		ar.compactor = NewCompactor(config.name, s3Adapter, config.monitorKeeper, config.destination.Compaction)
 	}
 
 	if config.streamMode {
-		ar.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ar, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+		ar.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, ar, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
 		ar.streamingWorker.start()
 	}
 
@@ -119,6 +156,9 @@ func (ar *AwsRedshift) Store(fileName string, payload []byte, alreadyUploadedTab
 //return result per table, failed events count and err if occurred
 func (ar *AwsRedshift) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	ar.uploadsLimiter.Acquire()
+	defer ar.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := ar.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -166,6 +206,10 @@ func (ar *AwsRedshift) storeTable(fdata *schema.ProcessedFile, table *adapters.T
 	}
 
 	b := fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
+	if ar.spectrumAdapter != nil {
+		return ar.storeExternalTable(dbTable.Name, fdata.FileName, b)
+	}
+
 	if err := ar.s3Adapter.UploadBytes(fdata.FileName, b); err != nil {
 		return err
 	}
@@ -181,11 +225,22 @@ func (ar *AwsRedshift) storeTable(fdata *schema.ProcessedFile, table *adapters.T
 	return nil
 }
 
+//storeExternalTable upload file into s3 partition folder of the current load date and register the partition
+func (ar *AwsRedshift) storeExternalTable(tableName, fileName string, payload []byte) error {
+	loadDate := time.Now().UTC()
+	if err := ar.s3Adapter.UploadBytes(ar.spectrumAdapter.FileKey(tableName, loadDate, fileName), payload); err != nil {
+		return err
+	}
+
+	return ar.spectrumAdapter.AddPartition(tableName, loadDate)
+}
+
 //Fallback log event with error to fallback logger
 func (ar *AwsRedshift) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		ar.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(ar.Name(), counters.StageFallback, len(failedEvents))
 }
 
 func (ar *AwsRedshift) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
@@ -196,6 +251,26 @@ func (ar *AwsRedshift) GetUsersRecognition() *events.UserRecognitionConfiguratio
 	return disabledRecognitionConfiguration
 }
 
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (ar *AwsRedshift) RefreshSchema() ([]string, error) {
+	return ar.tableHelper.RefreshAllTables(ar.Name())
+}
+
+//ExportUserRows return rows of all schema tables where any of user columns equals userId
+func (ar *AwsRedshift) ExportUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return ar.redshiftAdapter.SelectUserRows(userColumns, userId)
+}
+
+//DropTable drop table in the warehouse and in-memory schema cache
+func (ar *AwsRedshift) DropTable(tableName string) error {
+	return ar.tableHelper.DropTable(ar.Name(), tableName)
+}
+
+//Processor return schema processor which is used for dry runs
+func (ar *AwsRedshift) Processor() *schema.Processor {
+	return ar.processor
+}
+
 func (ar *AwsRedshift) Name() string {
 	return ar.name
 }
@@ -204,7 +279,20 @@ func (ar *AwsRedshift) Type() string {
 	return RedshiftType
 }
 
+//HealthCheck check destination connectivity without writing any data
+func (ar *AwsRedshift) HealthCheck() error {
+	if ar.s3Adapter != nil {
+		if err := ar.s3Adapter.Test(); err != nil {
+			return err
+		}
+	}
+
+	return ar.redshiftAdapter.Test()
+}
+
 func (ar *AwsRedshift) Close() (multiErr error) {
+	ar.compactor.Close()
+
 	if err := ar.redshiftAdapter.Close(); err != nil {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing redshift datasource: %v", ar.Name(), err))
 	}
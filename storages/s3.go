@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
@@ -18,6 +19,8 @@ type S3 struct {
 	processor      *schema.Processor
 	fallbackLogger *logging.AsyncLogger
 	eventsCache    *caching.EventsCache
+	compactor      *Compactor
+	uploadsLimiter *UploadsLimiter
 }
 
 func NewS3(config *Config) (events.Storage, error) {
@@ -43,6 +46,8 @@ func NewS3(config *Config) (events.Storage, error) {
 		processor:      config.processor,
 		fallbackLogger: config.loggerFactory.CreateFailedLogger(config.name),
 		eventsCache:    config.eventsCache,
+		uploadsLimiter: config.uploadsLimiter,
+		compactor:      NewCompactor(config.name, s3Adapter, config.monitorKeeper, config.destination.Compaction),
 	}
 
 	return s3, nil
@@ -61,6 +66,9 @@ func (s3 *S3) Store(fileName string, payload []byte, alreadyUploadedTables map[s
 //return result per table, failed events count and err if occurred
 func (s3 *S3) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	s3.uploadsLimiter.Acquire()
+	defer s3.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := s3.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -75,7 +83,7 @@ func (s3 *S3) StoreWithParseFunc(fileName string, payload []byte, alreadyUploade
 	tableResults := map[string]*events.StoreResult{}
 	for _, fdata := range flatData {
 		b := fdata.GetPayloadBytes(schema.JsonMarshallerInstance)
-		err := s3.s3Adapter.UploadBytes(fileName, b)
+		_, err := s3.s3Adapter.UploadCompressedBytes(fileName, b)
 
 		tableResults[fdata.BatchHeader.TableName] = &events.StoreResult{Err: err, RowsCount: fdata.GetPayloadLen()}
 		if err != nil {
@@ -104,6 +112,7 @@ func (s3 *S3) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		s3.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(s3.Name(), counters.StageFallback, len(failedEvents))
 }
 
 func (s3 *S3) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
@@ -114,6 +123,11 @@ func (s3 *S3) GetUsersRecognition() *events.UserRecognitionConfiguration {
 	return disabledRecognitionConfiguration
 }
 
+//Processor return schema processor which is used for dry runs
+func (s3 *S3) Processor() *schema.Processor {
+	return s3.processor
+}
+
 func (s3 *S3) Name() string {
 	return s3.name
 }
@@ -122,7 +136,14 @@ func (s3 *S3) Type() string {
 	return S3Type
 }
 
+//HealthCheck check destination connectivity without writing any data
+func (s3 *S3) HealthCheck() error {
+	return s3.s3Adapter.Test()
+}
+
 func (s3 *S3) Close() error {
+	s3.compactor.Close()
+
 	if err := s3.fallbackLogger.Close(); err != nil {
 		return fmt.Errorf("[%s] Error closing fallback logger: %v", s3.Name(), err)
 	}
@@ -0,0 +1,90 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/schema"
+	"sync"
+)
+
+//ShardsViewManager is implemented by adapters which support views over sharded tables
+type ShardsViewManager interface {
+	CreateShardsView(view *adapters.ShardsView) error
+}
+
+//ShardsViewHelper maintains view with sharded table name over all its existing shards:
+//the view is recreated when a shard is created or its schema is changed
+type ShardsViewHelper struct {
+	sync.Mutex
+
+	destinationName string
+	manager         ShardsViewManager
+	tableHelper     *TableHelper
+	sharding        *schema.Sharding
+	//shard table name -> schema version which the current view has been created with
+	versions map[string]int64
+}
+
+//NewShardsViewHelper return nil if sharding views aren't configured
+func NewShardsViewHelper(destinationName string, manager ShardsViewManager, tableHelper *TableHelper, sharding *schema.Sharding) *ShardsViewHelper {
+	if !sharding.HasViews() {
+		return nil
+	}
+
+	return &ShardsViewHelper{
+		destinationName: destinationName,
+		manager:         manager,
+		tableHelper:     tableHelper,
+		sharding:        sharding,
+		versions:        map[string]int64{},
+	}
+}
+
+//EnsureView recreate view over shards if the shard schema version differs from the one the view has been created with. Nil-safe
+func (svh *ShardsViewHelper) EnsureView(shard *adapters.Table) error {
+	if svh == nil {
+		return nil
+	}
+
+	tableName, ok := svh.sharding.ViewTable(shard.Name)
+	if !ok {
+		return nil
+	}
+
+	svh.Lock()
+	defer svh.Unlock()
+
+	if version, ok := svh.versions[shard.Name]; ok && version == shard.Version {
+		return nil
+	}
+
+	view := &adapters.ShardsView{Name: tableName}
+	for _, shardName := range svh.sharding.Shards(tableName) {
+		if shardName == shard.Name {
+			view.Shards = append(view.Shards, shard)
+			continue
+		}
+
+		dbSchema, err := svh.tableHelper.TableSchema(shardName)
+		if err != nil {
+			return fmt.Errorf("Error getting shard table %s schema: %v", shardName, err)
+		}
+		if dbSchema.Exists() {
+			//cached schema is shared between goroutines and mustn't be changed
+			shardSchema := *dbSchema
+			shardSchema.Name = shardName
+			view.Shards = append(view.Shards, &shardSchema)
+		}
+	}
+
+	if err := svh.manager.CreateShardsView(view); err != nil {
+		return err
+	}
+
+	for _, viewShard := range view.Shards {
+		svh.versions[viewShard.Name] = viewShard.Version
+	}
+	logging.Infof("[%s] View [%s] over %d shards has been created", svh.destinationName, tableName, len(view.Shards))
+	return nil
+}
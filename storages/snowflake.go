@@ -7,6 +7,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
+	"github.com/jitsucom/eventnative/counters"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/parsers"
@@ -26,6 +27,7 @@ type Snowflake struct {
 	streamingWorker  *StreamingWorker
 	fallbackLogger   *logging.AsyncLogger
 	eventsCache      *caching.EventsCache
+	uploadsLimiter   *UploadsLimiter
 }
 
 //NewSnowflake return Snowflake and start goroutine for Snowflake batch storage or for stream consumer depend on destination mode
@@ -44,6 +46,7 @@ func NewSnowflake(config *Config) (events.Storage, error) {
 		t := "true"
 		snowflakeConfig.Parameters["client_session_keep_alive"] = &t
 	}
+	config.destination.Pool.EnrichSnowflakeParameters(snowflakeConfig.Parameters)
 
 	if config.destination.Google != nil {
 		if err := config.destination.Google.Validate(config.streamMode); err != nil {
@@ -72,15 +75,17 @@ func NewSnowflake(config *Config) (events.Storage, error) {
 	}
 
 	queryLogger := config.loggerFactory.CreateSQLQueryLogger(config.name)
-	snowflakeAdapter, err := CreateSnowflakeAdapter(config.ctx, config.destination.S3, *snowflakeConfig, queryLogger, config.sqlTypeCasts)
+	snowflakeAdapter, err := CreateSnowflakeAdapter(config.ctx, config.destination.S3, *snowflakeConfig, queryLogger, config.sqlTypeCasts, config.tableOptions)
 	if err != nil {
 		if stageAdapter != nil {
 			stageAdapter.Close()
 		}
 		return nil, err
 	}
+	snowflakeAdapter.ConfigurePool(config.destination.Pool)
 
-	tableHelper := NewTableHelper(snowflakeAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToSnowflake)
+	tableHelper := NewTableHelper(snowflakeAdapter, config.monitorKeeper, config.pkFields, adapters.SchemaToSnowflake, config.columnComments)
+	config.processor.ColumnsGuard().SetProvider(tableHelper)
 
 	snowflake := &Snowflake{
 		name:             config.name,
@@ -90,10 +95,11 @@ func NewSnowflake(config *Config) (events.Storage, error) {
 		processor:        config.processor,
 		fallbackLogger:   config.loggerFactory.CreateFailedLogger(config.name),
 		eventsCache:      config.eventsCache,
+		uploadsLimiter:   config.uploadsLimiter,
 	}
 
 	if config.streamMode {
-		snowflake.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, snowflake, config.eventsCache, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
+		snowflake.streamingWorker = newStreamingWorker(config.eventQueue, config.processor, snowflake, config.eventsCache, config.circuitBreaker, config.streamWorkers, config.loggerFactory.CreateStreamingArchiveLogger(config.name), tableHelper)
 		snowflake.streamingWorker.start()
 	}
 
@@ -103,15 +109,15 @@ func NewSnowflake(config *Config) (events.Storage, error) {
 //create snowflake adapter with schema
 //if schema doesn't exist - snowflake returns error. In this case connect without schema and create it
 func CreateSnowflakeAdapter(ctx context.Context, s3Config *adapters.S3Config, config adapters.SnowflakeConfig,
-	queryLogger *logging.QueryLogger, sqlTypeCasts map[string]string) (*adapters.Snowflake, error) {
-	snowflakeAdapter, err := adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts)
+	queryLogger *logging.QueryLogger, sqlTypeCasts map[string]string, tableOptions *adapters.TableOptions) (*adapters.Snowflake, error) {
+	snowflakeAdapter, err := adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts, tableOptions)
 	if err != nil {
 		if sferr, ok := err.(*sf.SnowflakeError); ok {
 			//schema doesn't exist
 			if sferr.Number == sf.ErrObjectNotExistOrAuthorized {
 				snowflakeSchema := config.Schema
 				config.Schema = ""
-				snowflakeAdapter, err := adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts)
+				snowflakeAdapter, err := adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts, tableOptions)
 				if err != nil {
 					return nil, err
 				}
@@ -123,7 +129,7 @@ func CreateSnowflakeAdapter(ctx context.Context, s3Config *adapters.S3Config, co
 				}
 				snowflakeAdapter.Close()
 
-				snowflakeAdapter, err = adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts)
+				snowflakeAdapter, err = adapters.NewSnowflake(ctx, &config, s3Config, queryLogger, sqlTypeCasts, tableOptions)
 				if err != nil {
 					return nil, err
 				}
@@ -166,6 +172,9 @@ func (s *Snowflake) Store(fileName string, payload []byte, alreadyUploadedTables
 //return result per table, failed events count and err if occurred
 func (s *Snowflake) StoreWithParseFunc(fileName string, payload []byte, alreadyUploadedTables map[string]bool,
 	parseFunc func([]byte) (map[string]interface{}, error)) (map[string]*events.StoreResult, int, error) {
+	s.uploadsLimiter.Acquire()
+	defer s.uploadsLimiter.Release()
+
 	flatData, failedEvents, err := s.processor.ProcessFilePayload(fileName, payload, alreadyUploadedTables, parseFunc)
 	if err != nil {
 		return nil, linesCount(payload), err
@@ -237,12 +246,33 @@ func (s *Snowflake) Fallback(failedEvents ...*events.FailedEvent) {
 	for _, failedEvent := range failedEvents {
 		s.fallbackLogger.ConsumeAny(failedEvent)
 	}
+	counters.DestinationStage(s.Name(), counters.StageFallback, len(failedEvents))
 }
 
 func (s *Snowflake) SyncStore(collectionTable string, objects []map[string]interface{}, timeIntervalValue string) (int, error) {
 	return 0, errors.New("Snowflake doesn't support sync store")
 }
 
+//RefreshSchema drop cached tables schema and re-read it from the warehouse
+func (s *Snowflake) RefreshSchema() ([]string, error) {
+	return s.tableHelper.RefreshAllTables(s.Name())
+}
+
+//ExportUserRows return rows of all schema tables where any of user columns equals userId
+func (s *Snowflake) ExportUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error) {
+	return s.snowflakeAdapter.SelectUserRows(userColumns, userId)
+}
+
+//DropTable drop table in the warehouse and in-memory schema cache
+func (s *Snowflake) DropTable(tableName string) error {
+	return s.tableHelper.DropTable(s.Name(), tableName)
+}
+
+//Processor return schema processor which is used for dry runs
+func (s *Snowflake) Processor() *schema.Processor {
+	return s.processor
+}
+
 func (s *Snowflake) Name() string {
 	return s.name
 }
@@ -251,6 +281,17 @@ func (s *Snowflake) Type() string {
 	return SnowflakeType
 }
 
+//HealthCheck check destination connectivity without writing any data
+func (s *Snowflake) HealthCheck() error {
+	if stage, ok := s.stageAdapter.(interface{ Test() error }); ok {
+		if err := stage.Test(); err != nil {
+			return err
+		}
+	}
+
+	return s.snowflakeAdapter.Test()
+}
+
 func (s *Snowflake) Close() (multiErr error) {
 	if err := s.snowflakeAdapter.Close(); err != nil {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("[%s] Error closing snowflake datasource: %v", s.Name(), err))
@@ -4,6 +4,7 @@ import (
 	"github.com/jitsucom/eventnative/adapters"
 	"github.com/jitsucom/eventnative/caching"
 	"github.com/jitsucom/eventnative/counters"
+	"github.com/jitsucom/eventnative/enrichment"
 	"github.com/jitsucom/eventnative/events"
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/metrics"
@@ -25,6 +26,8 @@ type StreamingWorker struct {
 	processor        *schema.Processor
 	streamingStorage StreamingStorage
 	eventsCache      *caching.EventsCache
+	circuitBreaker   *CircuitBreaker
+	workers          int
 	archiveLogger    *logging.AsyncLogger
 	tableHelper      []*TableHelper
 
@@ -32,104 +35,167 @@ type StreamingWorker struct {
 }
 
 func newStreamingWorker(eventQueue *events.PersistentQueue, processor *schema.Processor, streamingStorage StreamingStorage,
-	eventsCache *caching.EventsCache, archiveLogger *logging.AsyncLogger, tableHelper ...*TableHelper) *StreamingWorker {
+	eventsCache *caching.EventsCache, circuitBreaker *CircuitBreaker, workers int, archiveLogger *logging.AsyncLogger, tableHelper ...*TableHelper) *StreamingWorker {
+	if workers <= 0 {
+		workers = defaultStreamWorkers
+	}
 	return &StreamingWorker{
 		eventQueue:       eventQueue,
 		processor:        processor,
 		streamingStorage: streamingStorage,
 		eventsCache:      eventsCache,
+		circuitBreaker:   circuitBreaker,
+		workers:          workers,
 		archiveLogger:    archiveLogger,
 		tableHelper:      tableHelper,
 	}
 }
 
-//Run goroutine to:
-//1. read from queue
-//2. Insert in events.StreamingStorage
+//Run configured number of goroutines which share the destination persistent queue
+//and dedicated goroutines of the priority lane if it is configured
+//note: events are removed from the persistent queue on dequeue (there is no ack), so in-flight events (one per goroutine)
+//are lost on process crash. Events with connection insert errors are put back to the queue (ConsumeTimed)
+//goroutines share TableHelpers which keep cached tables immutable, so concurrent EnsureTable calls are safe
 func (sw *StreamingWorker) start() {
-	safego.RunWithRestart(func() {
-		for {
-			if sw.closed {
-				break
-			}
+	for i := 0; i < sw.workers; i++ {
+		safego.RunWithRestart(func() { sw.run(sw.eventQueue.DequeueBlock) })
+	}
 
-			fact, dequeuedTime, tokenId, err := sw.eventQueue.DequeueBlock()
-			if err != nil {
-				if err == events.ErrQueueClosed && sw.closed {
-					continue
-				}
-				logging.SystemErrorf("[%s] Error reading event from queue: %v", sw.streamingStorage.Name(), err)
-				continue
-			}
+	if priority := sw.eventQueue.Priority(); priority != nil {
+		for i := 0; i < priority.StreamWorkers(); i++ {
+			safego.RunWithRestart(func() { sw.run(sw.eventQueue.DequeuePriorityBlock) })
+		}
+	}
+}
+
+//run in the loop:
+//1. read from queue lane with dequeue func
+//2. Insert in events.StreamingStorage
+func (sw *StreamingWorker) run(dequeue func() (events.Event, time.Time, string, error)) {
+	for {
+		if sw.closed {
+			break
+		}
 
-			//dequeued event was from retry call and retry timeout hasn't come
-			if time.Now().Before(dequeuedTime) {
-				sw.eventQueue.ConsumeTimed(fact, dequeuedTime, tokenId)
+		fact, dequeuedTime, tokenId, err := dequeue()
+		if err != nil {
+			if err == events.ErrQueueClosed && sw.closed {
 				continue
 			}
+			logging.SystemErrorf("[%s] Error reading event from queue: %v", sw.streamingStorage.Name(), err)
+			continue
+		}
 
-			batchHeader, flattenObject, err := sw.processor.ProcessEvent(fact)
-			if err != nil {
-				if err == schema.ErrSkipObject {
-					logging.Warnf("[%s] Event [%s]: %v", sw.streamingStorage.Name(), events.ExtractEventId(fact), err)
-				} else {
-					serialized := fact.Serialize()
-					logging.Errorf("[%s] Unable to process object %s: %v", sw.streamingStorage.Name(), serialized, err)
-					metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
-					counters.ErrorEvents(sw.streamingStorage.Name(), 1)
-					sw.streamingStorage.Fallback(&events.FailedEvent{
-						Event:   []byte(serialized),
-						Error:   err.Error(),
-						EventId: events.ExtractEventId(fact),
-					})
-				}
-
-				//cache
-				sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
+		sw.process(fact, dequeuedTime, tokenId)
+	}
+}
 
-				continue
-			}
+//process insert dequeued event into events.StreamingStorage or put it back to the queue
+//event is marked as processed in the queue (it isn't in-flight anymore) on return
+func (sw *StreamingWorker) process(fact events.Event, dequeuedTime time.Time, tokenId string) {
+	defer sw.eventQueue.Processed()
 
-			//don't process empty object
-			if !batchHeader.Exists() {
-				continue
-			}
+	//dequeued event was from retry call and retry timeout hasn't come
+	if time.Now().Before(dequeuedTime) {
+		sw.eventQueue.ConsumeTimed(fact, dequeuedTime, tokenId)
+		return
+	}
 
-			table := sw.getTableHelper().MapTableSchema(batchHeader)
-
-			if err := sw.streamingStorage.Insert(table, flattenObject); err != nil {
-				logging.Errorf("[%s] Error inserting object %s to table [%s]: %v", sw.streamingStorage.Name(), flattenObject.Serialize(), table.Name, err)
-				if strings.Contains(err.Error(), "connection refused") ||
-					strings.Contains(err.Error(), "EOF") ||
-					strings.Contains(err.Error(), "write: broken pipe") {
-					sw.eventQueue.ConsumeTimed(fact, time.Now().Add(20*time.Second), tokenId)
-				} else {
-					sw.streamingStorage.Fallback(&events.FailedEvent{
-						Event:   []byte(fact.Serialize()),
-						Error:   err.Error(),
-						EventId: events.ExtractEventId(flattenObject),
-					})
-				}
-
-				counters.ErrorEvents(sw.streamingStorage.Name(), 1)
-				//cache
-				sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
-
-				metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
-				continue
-			}
+	sampleWeight := enrichment.SampleWeight(fact, tokenId)
+	batchHeader, flattenObject, err := sw.processor.ProcessEvent(fact)
+	if err != nil {
+		if err == schema.ErrNoConsentObject {
+			logging.Debugf("[%s] Event [%s]: %v", sw.streamingStorage.Name(), events.ExtractEventId(fact), err)
+			counters.SkipEvents(sw.streamingStorage.Name(), tokenId, 1)
+		} else if err == schema.ErrSkipObject || err == schema.ErrLateObject {
+			logging.Warnf("[%s] Event [%s]: %v", sw.streamingStorage.Name(), events.ExtractEventId(fact), err)
+			counters.SkipEvents(sw.streamingStorage.Name(), tokenId, 1)
+		} else {
+			serialized := fact.Serialize()
+			logging.Errorf("[%s] Unable to process object %s: %v", sw.streamingStorage.Name(), serialized, err)
+			metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
+			counters.ErrorEvents(sw.streamingStorage.Name(), tokenId, 1)
+			counters.DestinationStageEstimated(sw.streamingStorage.Name(), counters.StageErrored, sampleWeight)
+			sw.streamingStorage.Fallback(&events.FailedEvent{
+				Event:   []byte(serialized),
+				Error:   err.Error(),
+				EventId: events.ExtractEventId(fact),
+			})
+		}
 
-			counters.SuccessEvents(sw.streamingStorage.Name(), 1)
+		//cache
+		sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
 
-			//cache
-			sw.eventsCache.Succeed(sw.streamingStorage.Name(), events.ExtractEventId(fact), flattenObject, table)
+		return
+	}
 
-			metrics.SuccessTokenEvent(tokenId, sw.streamingStorage.Name())
+	//don't process empty object
+	if !batchHeader.Exists() {
+		return
+	}
+
+	table := sw.getTableHelper().MapTableSchema(batchHeader)
 
-			//archive
-			sw.archiveLogger.Consume(fact, tokenId)
+	//destination is unhealthy: put event back to the queue without insert attempt
+	if !sw.circuitBreaker.Allow() {
+		sw.eventQueue.ConsumeTimed(fact, time.Now().Add(sw.circuitBreaker.RetryDelay()), tokenId)
+		return
+	}
+
+	counters.DestinationStage(sw.streamingStorage.Name(), counters.StageUploaded, 1)
+	counters.DestinationStageEstimated(sw.streamingStorage.Name(), counters.StageUploaded, sampleWeight)
+	start := time.Now()
+	err = sw.streamingStorage.Insert(table, flattenObject)
+	metrics.DestinationInsert(sw.streamingStorage.Name(), StreamMode, time.Since(start))
+	if err != nil {
+		metrics.DestinationInsertError(sw.streamingStorage.Name(), StreamMode)
+		logging.Errorf("[%s] Error inserting object %s to table [%s]: %v", sw.streamingStorage.Name(), flattenObject.Serialize(), table.Name, err)
+		errorType := writeErrorType(err)
+		if errorType == WriteErrorConnection {
+			sw.circuitBreaker.Failure()
+			sw.eventQueue.ConsumeTimed(fact, time.Now().Add(20*time.Second), tokenId)
+			metrics.DestinationStreamWrite(sw.streamingStorage.Name(), metrics.StreamWriteRetried, errorType)
+			//cache
+			sw.eventsCache.Error(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error())
+		} else {
+			//destination is available but rejected the event
+			sw.circuitBreaker.Success()
+			sw.streamingStorage.Fallback(&events.FailedEvent{
+				Event:   []byte(fact.Serialize()),
+				Error:   err.Error(),
+				EventId: events.ExtractEventId(flattenObject),
+			})
+			metrics.DestinationStreamWrite(sw.streamingStorage.Name(), metrics.StreamWriteRejected, errorType)
+			//cache with exact rejected payload
+			sw.eventsCache.Reject(sw.streamingStorage.Name(), events.ExtractEventId(fact), err.Error(), errorType, flattenObject, table)
 		}
-	})
+
+		counters.ErrorEvents(sw.streamingStorage.Name(), tokenId, 1)
+		counters.DestinationStageEstimated(sw.streamingStorage.Name(), counters.StageErrored, sampleWeight)
+
+		metrics.ErrorTokenEvent(tokenId, sw.streamingStorage.Name())
+		return
+	}
+
+	sw.circuitBreaker.Success()
+	metrics.DestinationStreamWrite(sw.streamingStorage.Name(), metrics.StreamWriteAcknowledged, "")
+	counters.SuccessEvents(sw.streamingStorage.Name(), tokenId, 1)
+	counters.DestinationStageEstimated(sw.streamingStorage.Name(), counters.StageLoaded, sampleWeight)
+
+	//cache
+	sw.eventsCache.Succeed(sw.streamingStorage.Name(), events.ExtractEventId(fact), flattenObject, table)
+
+	metrics.SuccessTokenEvent(tokenId, sw.streamingStorage.Name())
+
+	//archive
+	sw.archiveLogger.Consume(fact, tokenId)
+}
+
+//isConnectionError return true if destination is unavailable
+func isConnectionError(err error) bool {
+	return strings.Contains(err.Error(), "connection refused") ||
+		strings.Contains(err.Error(), "EOF") ||
+		strings.Contains(err.Error(), "write: broken pipe")
 }
 
 func (sw *StreamingWorker) Close() error {
@@ -15,6 +15,8 @@ const unlockRetryCount = 5
 
 //Keeping tables schema state inmemory and update it according to incoming new data
 //note: Assume that after any outer changes in db we need to increment table version in MonitorKeeper
+//note: cached tables are immutable (they are shared between streaming workers and uploaders goroutines).
+//Patched schema is saved as a new copy
 type TableHelper struct {
 	sync.RWMutex
 
@@ -24,10 +26,12 @@ type TableHelper struct {
 
 	pkFields           map[string]bool
 	columnTypesMapping map[typing.DataType]string
+	columnComments     map[string]string
 }
 
+//columnComments might be nil (columns are created without comments)
 func NewTableHelper(manager adapters.TableManager, monitorKeeper MonitorKeeper, pkFields map[string]bool,
-	columnTypesMapping map[typing.DataType]string) *TableHelper {
+	columnTypesMapping map[typing.DataType]string, columnComments map[string]string) *TableHelper {
 	return &TableHelper{
 		manager:       manager,
 		monitorKeeper: monitorKeeper,
@@ -35,6 +39,7 @@ func NewTableHelper(manager adapters.TableManager, monitorKeeper MonitorKeeper,
 
 		pkFields:           pkFields,
 		columnTypesMapping: columnTypesMapping,
+		columnComments:     columnComments,
 	}
 }
 
@@ -50,7 +55,7 @@ func (th *TableHelper) MapTableSchema(batchHeader *schema.BatchHeader) *adapters
 		//map storage type
 		sqlType, ok := th.columnTypesMapping[field.GetType()]
 		if ok {
-			table.Columns[fieldName] = adapters.Column{SqlType: sqlType}
+			table.Columns[fieldName] = adapters.Column{SqlType: sqlType, Comment: th.columnComments[fieldName]}
 		} else {
 			logging.SystemErrorf("Unknown column type mapping for %s mapping: %v", field.GetType(), th.columnTypesMapping)
 		}
@@ -59,6 +64,34 @@ func (th *TableHelper) MapTableSchema(batchHeader *schema.BatchHeader) *adapters
 	return table
 }
 
+//TableSchema return in-memory or DB table schema (table might not exist)
+func (th *TableHelper) TableSchema(tableName string) (*adapters.Table, error) {
+	th.RLock()
+	dbSchema, ok := th.tables[tableName]
+	th.RUnlock()
+
+	if ok {
+		return dbSchema, nil
+	}
+
+	return th.manager.GetTableSchema(tableName)
+}
+
+//TableColumns return in-memory or DB table column names (used by schema.ColumnsGuard)
+func (th *TableHelper) TableColumns(tableName string) (map[string]bool, error) {
+	dbSchema, err := th.TableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]bool{}
+	for name := range dbSchema.Columns {
+		columns[name] = true
+	}
+
+	return columns, nil
+}
+
 //EnsureTable return DB table schema and err if occurred
 //if table doesn't exist - create a new one and increment version
 //if exists - calculate diff, patch existing one with diff and increment version
@@ -99,6 +132,11 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *adapters.
 	defer th.monitorKeeper.Unlock(lock)
 
 	//handle schema local changes (patching was in another goroutine)
+	th.RLock()
+	if cached, ok := th.tables[dataSchema.Name]; ok {
+		dbSchema = cached
+	}
+	th.RUnlock()
 	diff = dbSchema.Diff(dataSchema)
 	if !diff.Exists() {
 		return dbSchema, nil
@@ -117,8 +155,13 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *adapters.
 			return nil, fmt.Errorf("Error getting table %s schema: %v", dataSchema.Name, err)
 		}
 
+		dbSchema.Name = dataSchema.Name
 		dbSchema.Version = ver
 
+		th.Lock()
+		th.tables[dbSchema.Name] = dbSchema
+		th.Unlock()
+
 		diff = dbSchema.Diff(dataSchema)
 	}
 
@@ -137,22 +180,28 @@ func (th *TableHelper) EnsureTable(destinationName string, dataSchema *adapters.
 	}
 
 	//** Save **
+	patched := &adapters.Table{Name: dbSchema.Name, Columns: adapters.Columns{}, PKFields: dbSchema.PKFields, Version: newVersion}
 	//columns
+	for k, v := range dbSchema.Columns {
+		patched.Columns[k] = v
+	}
 	for k, v := range diff.Columns {
-		dbSchema.Columns[k] = v
+		patched.Columns[k] = v
 	}
 	//pk fields
 	if len(diff.PKFields) > 0 {
-		dbSchema.PKFields = diff.PKFields
+		patched.PKFields = diff.PKFields
 	}
 	//remove pk fields if a deletion was
 	if diff.DeletePkFields {
-		dbSchema.PKFields = map[string]bool{}
+		patched.PKFields = map[string]bool{}
 	}
-	//version
-	dbSchema.Version = newVersion
 
-	return dbSchema, nil
+	th.Lock()
+	th.tables[patched.Name] = patched
+	th.Unlock()
+
+	return patched, nil
 }
 
 //RefreshTableSchema force get (or create) db table schema and update it in-memory
@@ -170,6 +219,105 @@ func (th *TableHelper) RefreshTableSchema(destinationName string, dataSchema *ad
 	return dbTableSchema, nil
 }
 
+//DropTable lock table, drop it in the warehouse, remove it from in-memory cache and increment version
+//the table is created again on the next EnsureTable call
+func (th *TableHelper) DropTable(destinationName, tableName string) error {
+	dropper, ok := th.manager.(adapters.TableDropper)
+	if !ok {
+		return errors.New("Destination doesn't support tables dropping")
+	}
+
+	lock, err := th.monitorKeeper.Lock(destinationName, tableName)
+	if err != nil {
+		msg := fmt.Sprintf("System error: Unable to lock table %s: %v", tableName, err)
+		notifications.SystemError(msg)
+		return errors.New(msg)
+	}
+	defer th.monitorKeeper.Unlock(lock)
+
+	if err := dropper.DropTable(tableName); err != nil {
+		return err
+	}
+
+	th.Lock()
+	delete(th.tables, tableName)
+	th.Unlock()
+
+	if _, err := th.monitorKeeper.IncrementVersion(destinationName, tableName); err != nil {
+		return fmt.Errorf("Error incrementing table %s version: %v", tableName, err)
+	}
+
+	return nil
+}
+
+//RefreshAllTables drop in-memory tables schema cache, increment every table version in MonitorKeeper
+//(for re-reading schema on other cluster nodes) and re-introspect tables from the warehouse
+//all warehouse tables are refreshed if the adapter is able to list them (adapters.TablesLister) otherwise only cached ones
+//return refreshed table names
+func (th *TableHelper) RefreshAllTables(destinationName string) ([]string, error) {
+	tableNames := map[string]bool{}
+	if lister, ok := th.manager.(adapters.TablesLister); ok {
+		listed, err := lister.TablesList()
+		if err != nil {
+			return nil, err
+		}
+		for _, tableName := range listed {
+			tableNames[tableName] = true
+		}
+	}
+
+	th.Lock()
+	for tableName := range th.tables {
+		tableNames[tableName] = true
+	}
+	th.tables = map[string]*adapters.Table{}
+	th.Unlock()
+
+	var refreshed []string
+	for tableName := range tableNames {
+		dbTableSchema, err := th.refresh(destinationName, tableName)
+		if err != nil {
+			return refreshed, err
+		}
+
+		if dbTableSchema.Exists() {
+			th.Lock()
+			th.tables[tableName] = dbTableSchema
+			th.Unlock()
+		}
+
+		refreshed = append(refreshed, tableName)
+	}
+
+	return refreshed, nil
+}
+
+//lock table -> get existing schema -> increment version
+func (th *TableHelper) refresh(destinationName, tableName string) (*adapters.Table, error) {
+	lock, err := th.monitorKeeper.Lock(destinationName, tableName)
+	if err != nil {
+		msg := fmt.Sprintf("System error: Unable to lock table %s: %v", tableName, err)
+		notifications.SystemError(msg)
+		return nil, errors.New(msg)
+	}
+	defer th.monitorKeeper.Unlock(lock)
+
+	dbTableSchema, err := th.manager.GetTableSchema(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting table %s schema: %v", tableName, err)
+	}
+
+	ver, err := th.monitorKeeper.IncrementVersion(destinationName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error incrementing table %s version: %v", tableName, err)
+	}
+
+	dbTableSchema.Name = tableName
+	dbTableSchema.Version = ver
+
+	return dbTableSchema, nil
+}
+
 //lock table -> get existing schema -> create a new one if doesn't exist -> return schema with version
 func (th *TableHelper) getOrCreate(destinationName string, dataSchema *adapters.Table) (*adapters.Table, error) {
 	lock, err := th.monitorKeeper.Lock(destinationName, dataSchema.Name)
@@ -5,6 +5,8 @@ import (
 	"github.com/jitsucom/eventnative/schema"
 	"github.com/jitsucom/eventnative/typing"
 	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -14,6 +16,7 @@ func TestMapTableSchema(t *testing.T) {
 		input              schema.BatchHeader
 		pkFields           map[string]bool
 		columnTypesMapping map[typing.DataType]string
+		columnComments     map[string]string
 		expected           adapters.Table
 	}{
 		{
@@ -21,6 +24,7 @@ func TestMapTableSchema(t *testing.T) {
 			schema.BatchHeader{TableName: "test_table", Fields: schema.Fields{"field1": schema.NewField(typing.STRING)}},
 			map[string]bool{},
 			map[typing.DataType]string{},
+			nil,
 			adapters.Table{Name: "test_table", Columns: adapters.Columns{}, PKFields: map[string]bool{}},
 		},
 		{
@@ -28,15 +32,175 @@ func TestMapTableSchema(t *testing.T) {
 			schema.BatchHeader{TableName: "test_table", Fields: schema.Fields{"field1": schema.NewField(typing.STRING), "field2": schema.NewField(typing.STRING)}},
 			map[string]bool{"field1": true},
 			map[typing.DataType]string{typing.STRING: "text"},
+			nil,
 			adapters.Table{Name: "test_table", Columns: adapters.Columns{"field1": adapters.Column{SqlType: "text"}, "field2": adapters.Column{SqlType: "text"}},
 				PKFields: map[string]bool{"field1": true}},
 		},
+		{
+			"column comments",
+			schema.BatchHeader{TableName: "test_table", Fields: schema.Fields{"field1": schema.NewField(typing.STRING), "field2": schema.NewField(typing.STRING)}},
+			map[string]bool{},
+			map[typing.DataType]string{typing.STRING: "text"},
+			map[string]string{"field1": "User email"},
+			adapters.Table{Name: "test_table", Columns: adapters.Columns{"field1": adapters.Column{SqlType: "text", Comment: "User email"}, "field2": adapters.Column{SqlType: "text"}},
+				PKFields: map[string]bool{}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tableHelper := NewTableHelper(nil, nil, tt.pkFields, tt.columnTypesMapping)
+			tableHelper := NewTableHelper(nil, nil, tt.pkFields, tt.columnTypesMapping, tt.columnComments)
 			actual := tableHelper.MapTableSchema(&tt.input)
 			require.Equal(t, tt.expected, *actual, "Tables aren't equal")
 		})
 	}
 }
+
+type testLock struct{}
+
+func (tl *testLock) Unlock()            {}
+func (tl *testLock) Identifier() string { return "test" }
+
+type testMonitorKeeper struct {
+	mutex    sync.Mutex
+	versions map[string]int64
+}
+
+func (tmk *testMonitorKeeper) Lock(system string, collection string) (Lock, error) {
+	return &testLock{}, nil
+}
+func (tmk *testMonitorKeeper) Unlock(lock Lock) error { return nil }
+func (tmk *testMonitorKeeper) GetVersion(system string, collection string) (int64, error) {
+	tmk.mutex.Lock()
+	defer tmk.mutex.Unlock()
+	return tmk.versions[system+collection], nil
+}
+func (tmk *testMonitorKeeper) IncrementVersion(system string, collection string) (int64, error) {
+	tmk.mutex.Lock()
+	defer tmk.mutex.Unlock()
+	tmk.versions[system+collection]++
+	return tmk.versions[system+collection], nil
+}
+func (tmk *testMonitorKeeper) Close() error { return nil }
+
+type testTableManager struct {
+	sync.Mutex
+	tables  map[string]*adapters.Table
+	created int
+	patched int
+}
+
+func (ttm *testTableManager) GetTableSchema(tableName string) (*adapters.Table, error) {
+	ttm.Lock()
+	defer ttm.Unlock()
+	if table, ok := ttm.tables[tableName]; ok {
+		return table, nil
+	}
+	return &adapters.Table{Name: tableName, Columns: adapters.Columns{}, PKFields: map[string]bool{}}, nil
+}
+func (ttm *testTableManager) CreateTable(schemaToCreate *adapters.Table) error {
+	ttm.Lock()
+	defer ttm.Unlock()
+	ttm.tables[schemaToCreate.Name] = schemaToCreate
+	ttm.created++
+	return nil
+}
+func (ttm *testTableManager) PatchTableSchema(schemaToAdd *adapters.Table) error {
+	ttm.Lock()
+	defer ttm.Unlock()
+	ttm.patched++
+	return nil
+}
+func (ttm *testTableManager) DropTable(tableName string) error {
+	ttm.Lock()
+	defer ttm.Unlock()
+	delete(ttm.tables, tableName)
+	return nil
+}
+func (ttm *testTableManager) TablesList() ([]string, error) {
+	ttm.Lock()
+	defer ttm.Unlock()
+	var tableNames []string
+	for tableName := range ttm.tables {
+		tableNames = append(tableNames, tableName)
+	}
+	return tableNames, nil
+}
+
+func TestDropTable(t *testing.T) {
+	manager := &testTableManager{tables: map[string]*adapters.Table{}}
+	monitorKeeper := &testMonitorKeeper{versions: map[string]int64{}}
+	tableHelper := NewTableHelper(manager, monitorKeeper, nil, nil, nil)
+
+	table := &adapters.Table{Name: "orders", Columns: adapters.Columns{"id": adapters.Column{SqlType: "text"}}}
+	_, err := tableHelper.EnsureTable("dest", table)
+	require.NoError(t, err)
+	require.Equal(t, 1, manager.created)
+
+	require.NoError(t, tableHelper.DropTable("dest", "orders"))
+	require.Empty(t, manager.tables)
+	require.Equal(t, int64(2), monitorKeeper.versions["destorders"])
+
+	//dropped table is created again with the new schema
+	table = &adapters.Table{Name: "orders", Columns: adapters.Columns{"id": adapters.Column{SqlType: "bigint"}}}
+	dbTable, err := tableHelper.EnsureTable("dest", table)
+	require.NoError(t, err)
+	require.Equal(t, 2, manager.created)
+	require.Equal(t, "bigint", dbTable.Columns["id"].SqlType)
+
+	require.Error(t, NewTableHelper(&adapters.GoogleAnalytics{}, monitorKeeper, nil, nil, nil).DropTable("dest", "orders"))
+}
+
+func TestRefreshAllTables(t *testing.T) {
+	manager := &testTableManager{tables: map[string]*adapters.Table{}}
+	monitorKeeper := &testMonitorKeeper{versions: map[string]int64{}}
+	tableHelper := NewTableHelper(manager, monitorKeeper, nil, nil, nil)
+
+	_, err := tableHelper.EnsureTable("dest", &adapters.Table{Name: "events", Columns: adapters.Columns{"id": adapters.Column{SqlType: "text"}}})
+	require.NoError(t, err)
+
+	//tables changed and created outside EventNative
+	manager.tables["events"] = &adapters.Table{Name: "events", Columns: adapters.Columns{"id": adapters.Column{SqlType: "bigint"}}}
+	manager.tables["orders"] = &adapters.Table{Name: "orders", Columns: adapters.Columns{"id": adapters.Column{SqlType: "text"}}}
+
+	refreshed, err := tableHelper.RefreshAllTables("dest")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"events", "orders"}, refreshed)
+	require.Equal(t, int64(2), monitorKeeper.versions["destevents"])
+	require.Equal(t, int64(1), monitorKeeper.versions["destorders"])
+
+	tableHelper.RLock()
+	defer tableHelper.RUnlock()
+	require.Equal(t, "bigint", tableHelper.tables["events"].Columns["id"].SqlType)
+	require.Contains(t, tableHelper.tables, "orders")
+}
+
+//streaming workers and uploaders share one TableHelper and ensure the same table concurrently
+func TestEnsureTableConcurrently(t *testing.T) {
+	manager := &testTableManager{tables: map[string]*adapters.Table{}}
+	tableHelper := NewTableHelper(manager, &testMonitorKeeper{versions: map[string]int64{}}, nil, nil, nil)
+
+	_, err := tableHelper.EnsureTable("dest", &adapters.Table{Name: "events", Columns: adapters.Columns{"id": adapters.Column{SqlType: "text"}}})
+	require.NoError(t, err)
+
+	workers := 8
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		column := "field" + strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				dbTable, err := tableHelper.EnsureTable("dest", &adapters.Table{Name: "events",
+					Columns: adapters.Columns{"id": adapters.Column{SqlType: "text"}, column: adapters.Column{SqlType: "text"}}})
+				require.NoError(t, err)
+				require.Contains(t, dbTable.Columns, column)
+			}
+		}()
+	}
+	wg.Wait()
+
+	dbTable, err := tableHelper.TableSchema("events")
+	require.NoError(t, err)
+	require.Len(t, dbTable.Columns, workers+1)
+	require.Equal(t, workers, manager.patched)
+}
@@ -0,0 +1,179 @@
+package storages
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jitsucom/eventnative/adapters"
+	"strings"
+)
+
+//TestConnection validate destination config and connect to the destination (without writing any data)
+func TestConnection(config *DestinationConfig) error {
+	switch config.Type {
+	case PostgresType:
+		if err := config.DataSource.Validate(); err != nil {
+			return err
+		}
+
+		postgres, err := adapters.NewPostgres(context.Background(), config.DataSource, nil, map[string]string{}, nil)
+		if err != nil {
+			return err
+		}
+
+		postgres.Close()
+		return nil
+	case ClickHouseType:
+		if err := config.ClickHouse.Validate(); err != nil {
+			return err
+		}
+
+		var multiErr error
+		for _, dsn := range config.ClickHouse.Dsns {
+			ch, err := adapters.NewClickHouse(context.Background(), strings.TrimSpace(dsn),
+				"", "", nil, nil, nil, nil, map[string]string{})
+			if err != nil {
+				multiErr = multierror.Append(multiErr, err)
+				continue
+			} else {
+				ch.Close()
+			}
+		}
+		return multiErr
+	case RedshiftType:
+		if err := config.DataSource.Validate(); err != nil {
+			return err
+		}
+
+		if config.Mode == BatchMode {
+			if err := config.S3.Validate(); err != nil {
+				return err
+			}
+			s3, err := adapters.NewS3(config.S3)
+			if err != nil {
+				return err
+			}
+			s3.Close()
+		}
+
+		redshift, err := adapters.NewAwsRedshift(context.Background(), config.DataSource, config.S3, nil, map[string]string{}, nil)
+		if err != nil {
+			return err
+		}
+
+		redshift.Close()
+		return nil
+	case BigQueryType:
+		if err := config.Google.Validate(config.Mode != BatchMode); err != nil {
+			return err
+		}
+
+		bq, err := adapters.NewBigQuery(context.Background(), config.Google, nil, map[string]string{}, nil)
+		if err != nil {
+			return err
+		}
+		defer bq.Close()
+		if config.Mode == BatchMode {
+			googleStorage, err := adapters.NewGoogleCloudStorage(context.Background(), config.Google)
+			if err != nil {
+				return err
+			}
+			defer googleStorage.Close()
+		}
+		return bq.Test()
+	case SnowflakeType:
+		if err := config.Snowflake.Validate(); err != nil {
+			return err
+		}
+		snowflake, err := adapters.NewSnowflake(context.Background(), config.Snowflake, nil, nil, map[string]string{}, nil)
+		if err != nil {
+			return err
+		}
+		defer snowflake.Close()
+		if config.Mode == BatchMode {
+			if config.S3 != nil && config.S3.Bucket != "" {
+				if err := config.S3.Validate(); err != nil {
+					return err
+				}
+				s3, err := adapters.NewS3(config.S3)
+				if err != nil {
+					return err
+				}
+				defer s3.Close()
+			} else if config.Google != nil && config.Google.Bucket != "" {
+				if err := config.Google.Validate(false); err != nil {
+					return err
+				}
+				gcp, err := adapters.NewGoogleCloudStorage(context.Background(), config.Google)
+				if err != nil {
+					return err
+				}
+				defer gcp.Close()
+			}
+		}
+		return nil
+	case GoogleAnalyticsType:
+		if err := config.GoogleAnalytics.Validate(); err != nil {
+			return err
+		}
+
+		return nil
+	case DruidType:
+		if err := config.Druid.Validate(); err != nil {
+			return err
+		}
+
+		druid := adapters.NewDruid(context.Background(), config.Druid, nil)
+		defer druid.Close()
+		return druid.Test()
+	case PinotType:
+		if err := config.Pinot.Validate(); err != nil {
+			return err
+		}
+
+		pinot := adapters.NewPinot(context.Background(), config.Pinot, nil)
+		defer pinot.Close()
+		return pinot.Test()
+	case DynamoDBType:
+		if err := config.DynamoDB.Validate(); err != nil {
+			return err
+		}
+
+		dynamo, err := adapters.NewDynamoDB(context.Background(), config.DynamoDB)
+		if err != nil {
+			return err
+		}
+		defer dynamo.Close()
+		return dynamo.Test()
+	case GCSType:
+		if err := config.GCS.Validate(); err != nil {
+			return err
+		}
+
+		gcs, err := adapters.NewGCS(context.Background(), config.GCS)
+		if err != nil {
+			return err
+		}
+		return gcs.Close()
+	case ProfilesType:
+		if err := config.Profiles.Validate(config.DataSource); err != nil {
+			return err
+		}
+
+		if config.Profiles.Storage == adapters.ProfilesRedisStorage {
+			redisProfiles, err := adapters.NewRedisProfiles(config.Profiles.Redis)
+			if err != nil {
+				return err
+			}
+			return redisProfiles.Close()
+		}
+
+		postgres, err := adapters.NewPostgres(context.Background(), config.DataSource, nil, map[string]string{}, nil)
+		if err != nil {
+			return err
+		}
+		return postgres.Close()
+	default:
+		return errors.New("unsupported destination type " + config.Type)
+	}
+}
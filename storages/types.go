@@ -8,4 +8,31 @@ const (
 	S3Type              = "s3"
 	SnowflakeType       = "snowflake"
 	GoogleAnalyticsType = "google_analytics"
+	DruidType           = "druid"
+	PinotType           = "pinot"
+	DynamoDBType        = "dynamodb"
+	GCSType             = "gcs"
+	ProfilesType        = "profiles"
 )
+
+//SchemaRefresher is implemented by storages which keep tables schema in memory
+type SchemaRefresher interface {
+	RefreshSchema() ([]string, error)
+}
+
+//UserRowsExporter is implemented by SQL storages which can select all rows belonging to a user (data-subject access requests)
+type UserRowsExporter interface {
+	//ExportUserRows return rows per table where any of user columns equals userId
+	ExportUserRows(userColumns []string, userId string) (map[string][]map[string]interface{}, error)
+}
+
+//HealthChecker is implemented by storages which can check destination connectivity with a cheap request
+//(e.g. ping query or bucket HEAD) without writing any data
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+//TableDropper is implemented by storages which can drop a table (e.g. before full re-sync of a source collection)
+type TableDropper interface {
+	DropTable(tableName string) error
+}
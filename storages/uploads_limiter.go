@@ -0,0 +1,43 @@
+package storages
+
+//UploadsLimiter limits number of simultaneous batch uploads (Store and SyncStore calls) into one destination
+//nil UploadsLimiter doesn't limit anything
+type UploadsLimiter struct {
+	slots chan struct{}
+}
+
+//NewUploadsLimiter return UploadsLimiter or nil if maxInflight isn't positive (unlimited)
+func NewUploadsLimiter(maxInflight int) *UploadsLimiter {
+	if maxInflight <= 0 {
+		return nil
+	}
+
+	return &UploadsLimiter{slots: make(chan struct{}, maxInflight)}
+}
+
+//Acquire block until upload slot is available
+func (ul *UploadsLimiter) Acquire() {
+	if ul == nil {
+		return
+	}
+
+	ul.slots <- struct{}{}
+}
+
+//Release return upload slot
+func (ul *UploadsLimiter) Release() {
+	if ul == nil {
+		return
+	}
+
+	<-ul.slots
+}
+
+//InFlight return current number of uploads
+func (ul *UploadsLimiter) InFlight() int {
+	if ul == nil {
+		return 0
+	}
+
+	return len(ul.slots)
+}
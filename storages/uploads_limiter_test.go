@@ -0,0 +1,44 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUploadsLimiter(t *testing.T) {
+	require.Nil(t, NewUploadsLimiter(0))
+
+	var unlimited *UploadsLimiter
+	unlimited.Acquire()
+	unlimited.Release()
+	require.Equal(t, 0, unlimited.InFlight())
+
+	limiter := NewUploadsLimiter(2)
+	var current, max int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Acquire()
+			defer limiter.Release()
+
+			value := atomic.AddInt32(&current, 1)
+			for {
+				prev := atomic.LoadInt32(&max)
+				if value <= prev || atomic.CompareAndSwapInt32(&max, prev, value) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&current, -1)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), max)
+	require.Equal(t, 0, limiter.InFlight())
+}
@@ -0,0 +1,154 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/scheduler"
+	"github.com/jitsucom/eventnative/schema"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultViewsRefreshMin = 60
+
+//ViewManager is implemented by adapters which support typed views over raw JSON column
+type ViewManager interface {
+	CreateView(view *adapters.View) error
+	RefreshView(viewName string) error
+}
+
+//ViewsHelper creates typed view per table in schema-on-read mode (once per table per process)
+//and refreshes materialized views periodically
+type ViewsHelper struct {
+	sync.RWMutex
+
+	destinationName string
+	manager         ViewManager
+	rawColumn       string
+	suffix          string
+	materialized    bool
+	columns         []adapters.ViewColumn
+	//table name -> view name
+	views map[string]string
+
+	job *scheduler.Job
+}
+
+//NewViewsHelper return nil if schema-on-read views aren't configured
+func NewViewsHelper(destinationName string, manager ViewManager, schemaOnRead *schema.SchemaOnReadConfig, mappings *schema.Mapping) *ViewsHelper {
+	if !schemaOnRead.IsEnabled() || !schemaOnRead.Views {
+		return nil
+	}
+
+	vh := &ViewsHelper{
+		destinationName: destinationName,
+		manager:         manager,
+		rawColumn:       schemaOnRead.RawColumn(),
+		suffix:          schemaOnRead.GetViewSuffix(),
+		materialized:    schemaOnRead.Materialized,
+		columns:         ViewColumns(mappings),
+		views:           map[string]string{},
+	}
+
+	if vh.materialized {
+		refreshMin := schemaOnRead.RefreshMin
+		if refreshMin == 0 {
+			refreshMin = defaultViewsRefreshMin
+		}
+		vh.job = scheduler.Add("views_refresh_"+destinationName, scheduler.Every(time.Duration(refreshMin)*time.Minute), vh.refresh)
+	}
+
+	return vh
+}
+
+//ViewColumns return typed view columns from mappings with move action: src JSON path -> (type) dst column
+func ViewColumns(mappings *schema.Mapping) []adapters.ViewColumn {
+	if mappings == nil {
+		return nil
+	}
+
+	var columns []adapters.ViewColumn
+	for _, field := range mappings.Fields {
+		if field.Action != schema.MOVE || field.Src == "" || field.Dst == "" {
+			continue
+		}
+
+		columns = append(columns, adapters.ViewColumn{
+			Path:    strings.Split(strings.Trim(field.Src, "/"), "/"),
+			Name:    strings.ToLower(strings.ReplaceAll(strings.Trim(field.Dst, "/"), "/", "_")),
+			SqlType: field.Type,
+		})
+	}
+
+	return columns
+}
+
+//EnsureView create view over the table if it hasn't been created yet. Nil-safe
+func (vh *ViewsHelper) EnsureView(tableName string) error {
+	if vh == nil {
+		return nil
+	}
+
+	vh.RLock()
+	_, ok := vh.views[tableName]
+	vh.RUnlock()
+	if ok {
+		return nil
+	}
+
+	vh.Lock()
+	defer vh.Unlock()
+	if _, ok := vh.views[tableName]; ok {
+		return nil
+	}
+
+	view := &adapters.View{
+		Name:         tableName + vh.suffix,
+		Table:        tableName,
+		RawColumn:    vh.rawColumn,
+		Columns:      vh.columns,
+		Materialized: vh.materialized,
+	}
+	if err := vh.manager.CreateView(view); err != nil {
+		return err
+	}
+
+	vh.views[tableName] = view.Name
+	logging.Infof("[%s] Typed view [%s] over table [%s] has been created", vh.destinationName, view.Name, tableName)
+	return nil
+}
+
+func (vh *ViewsHelper) refresh() error {
+	vh.RLock()
+	var viewNames []string
+	for _, viewName := range vh.views {
+		viewNames = append(viewNames, viewName)
+	}
+	vh.RUnlock()
+
+	for _, viewName := range viewNames {
+		if err := vh.manager.RefreshView(viewName); err != nil {
+			return fmt.Errorf("[%s] %v", vh.destinationName, err)
+		}
+	}
+
+	return nil
+}
+
+//Close stop materialized views refreshing. Nil-safe
+func (vh *ViewsHelper) Close() {
+	if vh != nil && vh.job != nil {
+		vh.job.Stop()
+	}
+}
+
+//viewsHelper return ViewsHelper if schema-on-read views are configured or nil
+func (c *Config) viewsHelper(manager ViewManager) *ViewsHelper {
+	if c.destination.DataLayout == nil {
+		return nil
+	}
+
+	return NewViewsHelper(c.name, manager, c.destination.DataLayout.SchemaOnRead, c.destination.DataLayout.Mappings)
+}
@@ -0,0 +1,52 @@
+package storages
+
+import (
+	"errors"
+	"github.com/jitsucom/eventnative/adapters"
+	"github.com/jitsucom/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type viewManagerMock struct {
+	created []*adapters.View
+	err     error
+}
+
+func (vmm *viewManagerMock) CreateView(view *adapters.View) error {
+	if vmm.err != nil {
+		return vmm.err
+	}
+	vmm.created = append(vmm.created, view)
+	return nil
+}
+
+func (vmm *viewManagerMock) RefreshView(viewName string) error {
+	return nil
+}
+
+func TestViewsHelper(t *testing.T) {
+	mappings := &schema.Mapping{Fields: []schema.MappingField{
+		{Src: "/user/id", Dst: "/user_id", Action: schema.MOVE, Type: "bigint"},
+		{Src: "/page/Title", Dst: "/Page/Title", Action: schema.MOVE},
+		{Src: "/key1", Action: schema.REMOVE},
+	}}
+	require.Equal(t, []adapters.ViewColumn{
+		{Path: []string{"user", "id"}, Name: "user_id", SqlType: "bigint"},
+		{Path: []string{"page", "Title"}, Name: "page_title"},
+	}, ViewColumns(mappings))
+
+	require.Nil(t, NewViewsHelper("test", &viewManagerMock{}, &schema.SchemaOnReadConfig{Enabled: true}, mappings))
+
+	manager := &viewManagerMock{err: errors.New("connection refused")}
+	vh := NewViewsHelper("test", manager, &schema.SchemaOnReadConfig{Enabled: true, Views: true}, mappings)
+	require.Error(t, vh.EnsureView("events"))
+
+	//view creation is retried after error and performed once per table
+	manager.err = nil
+	require.NoError(t, vh.EnsureView("events"))
+	require.NoError(t, vh.EnsureView("events"))
+	require.Equal(t, 1, len(manager.created))
+	require.Equal(t, "events_view", manager.created[0].Name)
+	require.Equal(t, schema.DefaultRawColumn, manager.created[0].RawColumn)
+}
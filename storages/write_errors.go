@@ -0,0 +1,38 @@
+package storages
+
+import "strings"
+
+//Stream write error types (used in metrics and events cache)
+const (
+	WriteErrorConstraint = "constraint_violation"
+	WriteErrorType       = "type_error"
+	WriteErrorConnection = "connection"
+	WriteErrorUnknown    = "unknown"
+)
+
+var (
+	constraintErrorMarkers = []string{"duplicate key", "violates", "constraint", "null value", "unique"}
+	typeErrorMarkers       = []string{"invalid input syntax", "cannot parse", "out of range", "cannot be cast", "cannot convert",
+		"type mismatch", "is of type", "invalid value", "incorrect"}
+)
+
+//writeErrorType return type of destination insert error. Adapters wrap driver errors so errors are classified by message
+func writeErrorType(err error) string {
+	if isConnectionError(err) {
+		return WriteErrorConnection
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, marker := range constraintErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return WriteErrorConstraint
+		}
+	}
+	for _, marker := range typeErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return WriteErrorType
+		}
+	}
+
+	return WriteErrorUnknown
+}
@@ -0,0 +1,27 @@
+package storages
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWriteErrorType(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"postgres unique", errors.New(`Error inserting: pq: duplicate key value violates unique constraint "events_pkey"`), WriteErrorConstraint},
+		{"postgres not null", errors.New(`pq: null value in column "user_id" violates not-null constraint`), WriteErrorConstraint},
+		{"postgres syntax", errors.New(`pq: invalid input syntax for type integer: "abc"`), WriteErrorType},
+		{"clickhouse parse", errors.New(`code: 27, message: Cannot parse input: expected , before: abc`), WriteErrorType},
+		{"connection", errors.New(`dial tcp 127.0.0.1:5432: connect: connection refused`), WriteErrorConnection},
+		{"unknown", errors.New(`permission denied for table events`), WriteErrorUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, writeErrorType(tt.err))
+		})
+	}
+}
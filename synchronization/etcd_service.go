@@ -2,6 +2,7 @@ package synchronization
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/clientv3/concurrency"
@@ -9,13 +10,28 @@ import (
 	"github.com/jitsucom/eventnative/logging"
 	"github.com/jitsucom/eventnative/safego"
 	"github.com/jitsucom/eventnative/storages"
+	"github.com/jitsucom/eventnative/uuid"
 	"io"
 	"strconv"
 	"sync"
 	"time"
 )
 
-const instancePrefix = "en_instance_"
+const (
+	instancePrefix  = "en_instance_"
+	syncTasksPrefix = "en_sync_tasks/"
+
+	//sync tasks which weren't taken by an instance (e.g. instance is down) will be removed after TTL
+	syncTaskTTLSeconds = 120
+	//syncTaskAckTimeout is a max time of waiting for the instance to take the sync task (delete its key)
+	syncTaskAckTimeout = 10 * time.Second
+)
+
+//syncTask is a dto for passing source collection sync tasks between instances
+type syncTask struct {
+	SourceId   string `json:"source_id"`
+	Collection string `json:"collection"`
+}
 
 type Service interface {
 	io.Closer
@@ -175,6 +191,102 @@ func (es *EtcdService) heartBeat() error {
 	return nil
 }
 
+//PushSyncTask put sync task under instance prefix with TTL lease and wait until the instance takes it (deletes the key)
+//if the task isn't taken in syncTaskAckTimeout (e.g. instance is stale or down) it is removed and error is returned
+func (es *EtcdService) PushSyncTask(instance, sourceId, collection string) error {
+	return es.pushSyncTask(instance, sourceId, collection, syncTaskAckTimeout)
+}
+
+func (es *EtcdService) pushSyncTask(instance, sourceId, collection string, ackTimeout time.Duration) error {
+	b, err := json.Marshal(syncTask{SourceId: sourceId, Collection: collection})
+	if err != nil {
+		return fmt.Errorf("Error serializing sync task: %v", err)
+	}
+
+	lease, err := es.client.Lease.Grant(context.Background(), syncTaskTTLSeconds)
+	if err != nil {
+		return fmt.Errorf("Error creating Lease: %v", err)
+	}
+
+	key := syncTasksPrefix + instance + "/" + uuid.New()
+	putResponse, err := es.client.Put(context.Background(), key, string(b), clientv3.WithLease(lease.ID))
+	if err != nil {
+		return fmt.Errorf("Error pushing sync task to etcd: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(es.ctx, ackTimeout)
+	defer cancel()
+	for watchResponse := range es.client.Watch(ctx, key, clientv3.WithRev(putResponse.Header.Revision+1)) {
+		for _, event := range watchResponse.Events {
+			if event.Type == clientv3.EventTypeDelete {
+				return nil
+			}
+		}
+	}
+
+	//take the task back: it is run by the caller
+	deleteResponse, err := es.client.Delete(context.Background(), key)
+	if err != nil {
+		return fmt.Errorf("Sync task hasn't been taken by instance [%s] in %s and can't be removed from etcd: %v", instance, ackTimeout, err)
+	}
+	if deleteResponse.Deleted == 0 {
+		//has been taken right before the removal
+		return nil
+	}
+
+	return fmt.Errorf("Sync task hasn't been taken by instance [%s] in %s", instance, ackTimeout)
+}
+
+//WatchSyncTasks starts a new goroutine for watching sync tasks under the current instance prefix
+//every task is deleted from etcd before handling
+func (es *EtcdService) WatchSyncTasks(handler func(sourceId, collection string)) {
+	prefix := syncTasksPrefix + es.serverName + "/"
+	safego.RunWithRestart(func() {
+		//tasks which were pushed before watching
+		response, err := es.client.Get(es.ctx, prefix, clientv3.WithPrefix())
+		if err != nil {
+			logging.Errorf("Error getting sync tasks from etcd: %v", err)
+		} else {
+			for _, kv := range response.Kvs {
+				es.handleSyncTask(kv.Key, kv.Value, handler)
+			}
+		}
+
+		for watchResponse := range es.client.Watch(es.ctx, prefix, clientv3.WithPrefix()) {
+			if err := watchResponse.Err(); err != nil {
+				logging.Errorf("Error watching sync tasks in etcd: %v", err)
+				continue
+			}
+
+			for _, event := range watchResponse.Events {
+				if event.Type == clientv3.EventTypePut {
+					es.handleSyncTask(event.Kv.Key, event.Kv.Value, handler)
+				}
+			}
+		}
+	})
+}
+
+//delete sync task key (only one handling even if several watchers) and call handler
+func (es *EtcdService) handleSyncTask(key, value []byte, handler func(sourceId, collection string)) {
+	deleteResponse, err := es.client.Delete(context.Background(), string(key))
+	if err != nil {
+		logging.Errorf("Error deleting sync task [%s] from etcd: %v", string(key), err)
+		return
+	}
+	if deleteResponse.Deleted == 0 {
+		return
+	}
+
+	task := &syncTask{}
+	if err := json.Unmarshal(value, task); err != nil {
+		logging.Errorf("Error parsing sync task [%s]: %v", string(value), err)
+		return
+	}
+
+	handler(task.SourceId, task.Collection)
+}
+
 func (es *EtcdService) Close() error {
 	es.closed = true
 
@@ -1,6 +1,7 @@
 package synchronization
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jitsucom/eventnative/storages"
 	"sync"
@@ -29,6 +30,8 @@ type InMemoryService struct {
 
 	//for locking in single en node setup
 	locks *sync.Map
+
+	syncTasksHandler func(sourceId, collection string)
 }
 
 func NewInMemoryService(serverNameSingleArray []string) *InMemoryService {
@@ -83,6 +86,23 @@ func (ims *InMemoryService) IncrementVersion(system string, collection string) (
 	return result, nil
 }
 
+//PushSyncTask call sync tasks handler directly so as there is only one instance
+func (ims *InMemoryService) PushSyncTask(instance, sourceId, collection string) error {
+	if len(ims.serverNameSingleArray) == 0 || ims.serverNameSingleArray[0] != instance {
+		return fmt.Errorf("Unknown instance [%s]", instance)
+	}
+	if ims.syncTasksHandler == nil {
+		return errors.New("Sync tasks handler isn't configured")
+	}
+
+	ims.syncTasksHandler(sourceId, collection)
+	return nil
+}
+
+func (ims *InMemoryService) WatchSyncTasks(handler func(sourceId, collection string)) {
+	ims.syncTasksHandler = handler
+}
+
 func (ims *InMemoryService) Close() error {
 	return nil
 }
@@ -39,3 +39,17 @@ func TestIncrementVersion(t *testing.T) {
 	require.NoError(t, err2)
 	require.Equal(t, version2, int64(14000))
 }
+
+func TestPushSyncTask(t *testing.T) {
+	ims := NewInMemoryService([]string{"instance1"})
+	require.Error(t, ims.PushSyncTask("instance1", "source1", "collection1"))
+
+	var received []string
+	ims.WatchSyncTasks(func(sourceId, collection string) {
+		received = append(received, sourceId+"_"+collection)
+	})
+
+	require.NoError(t, ims.PushSyncTask("instance1", "source1", "collection1"))
+	require.Error(t, ims.PushSyncTask("instance2", "source1", "collection2"))
+	require.Equal(t, []string{"source1_collection1"}, received)
+}
@@ -4,6 +4,12 @@ import "time"
 
 //default key and format of event timestamp
 const Key = "_timestamp"
+
+//standard event timestamps which are stored in every destination:
+//client - sent by client (eventn_ctx.utc_time), server - EventNative receive time, load - destination processing time
+const ServerKey = "_server_timestamp"
+const ClientKey = "_client_timestamp"
+const LoadKey = "_load_timestamp"
 const Layout = "2006-01-02T15:04:05.000000Z"
 const DayLayout = "20060102"
 const MonthLayout = "200601"
@@ -0,0 +1,99 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/jitsucom/eventnative/typing"
+	"sync"
+)
+
+const (
+	rowVariable  = "row"
+	functionName = "__transform"
+)
+
+//Transformer applies JavaScript transformation to source objects
+//transformation is a function body with 'row' argument which returns:
+//object - transformed row, array of objects - several rows (e.g. pivoting), null or undefined - row is skipped
+//e.g. "delete row.email; row.name = row.first_name + ' ' + row.last_name; return row"
+type Transformer struct {
+	//goja runtime isn't goroutine safe
+	sync.Mutex
+
+	runtime  *goja.Runtime
+	function goja.Callable
+}
+
+//NewTransformer return compiled Transformer or nil if expression is empty
+func NewTransformer(expression string) (*Transformer, error) {
+	if expression == "" {
+		return nil, nil
+	}
+
+	runtime := goja.New()
+	if _, err := runtime.RunString(fmt.Sprintf("function %s(%s) {\n%s\n}", functionName, rowVariable, expression)); err != nil {
+		return nil, fmt.Errorf("Error compiling transformation: %v", err)
+	}
+
+	function, ok := goja.AssertFunction(runtime.Get(functionName))
+	if !ok {
+		return nil, errors.New("Error compiling transformation: transformation isn't a function")
+	}
+
+	return &Transformer{runtime: runtime, function: function}, nil
+}
+
+//Transform return transformed objects
+//json.Number values are converted into numbers before transformation
+func (t *Transformer) Transform(objects []map[string]interface{}) ([]map[string]interface{}, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	var result []map[string]interface{}
+	for _, object := range objects {
+		for key, value := range object {
+			object[key] = typing.ReformatValue(value)
+		}
+
+		value, err := t.function(goja.Undefined(), t.runtime.ToValue(object))
+		if err != nil {
+			return nil, fmt.Errorf("Error running transformation: %v", err)
+		}
+
+		transformed, err := exportObjects(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, transformed...)
+	}
+
+	return result, nil
+}
+
+//exportObjects return objects from transformation result: object, array of objects or null/undefined
+func exportObjects(value goja.Value) ([]map[string]interface{}, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+
+	switch exported := value.Export().(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{exported}, nil
+	case []interface{}:
+		var objects []map[string]interface{}
+		for _, element := range exported {
+			if element == nil {
+				continue
+			}
+			object, ok := element.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Transformation must return object or array of objects. Array element: %v", element)
+			}
+			objects = append(objects, object)
+		}
+		return objects, nil
+	default:
+		return nil, fmt.Errorf("Transformation must return object, array of objects or null. Returned: %v", exported)
+	}
+}
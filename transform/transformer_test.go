@@ -0,0 +1,66 @@
+package transform
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTransform(t *testing.T) {
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expression string
+		input      []map[string]interface{}
+		expected   []map[string]interface{}
+	}{
+		{
+			"rename and redact",
+			"row.full_name = row.first_name + ' ' + row.last_name; delete row.first_name; delete row.last_name; delete row.email; return row",
+			[]map[string]interface{}{{"first_name": "John", "last_name": "Doe", "email": "john@example.com", "created_at": created, "amount": json.Number("10")}},
+			[]map[string]interface{}{{"full_name": "John Doe", "created_at": created, "amount": int64(10)}},
+		},
+		{
+			"pivot",
+			"return [{metric: 'clicks', value: row.clicks}, {metric: 'views', value: row.views}]",
+			[]map[string]interface{}{{"clicks": json.Number("1"), "views": json.Number("2.5")}},
+			[]map[string]interface{}{{"metric": "clicks", "value": int64(1)}, {"metric": "views", "value": 2.5}},
+		},
+		{
+			"skip",
+			"if (row.test) { return null } return row",
+			[]map[string]interface{}{{"test": true}, {"test": false}},
+			[]map[string]interface{}{{"test": false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer, err := NewTransformer(tt.expression)
+			require.NoError(t, err)
+
+			actual, err := transformer.Transform(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	transformer, err := NewTransformer("")
+	require.NoError(t, err)
+	require.Nil(t, transformer)
+
+	_, err = NewTransformer("return {")
+	require.Error(t, err)
+
+	transformer, err = NewTransformer("return 'string'")
+	require.NoError(t, err)
+	_, err = transformer.Transform([]map[string]interface{}{{"a": 1}})
+	require.Error(t, err)
+
+	transformer, err = NewTransformer("throw new Error('failed')")
+	require.NoError(t, err)
+	_, err = transformer.Transform([]map[string]interface{}{{"a": 1}})
+	require.Error(t, err)
+}
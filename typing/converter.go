@@ -32,6 +32,9 @@ var (
 
 	DefaultTypes = map[string]DataType{
 		timestamp.Key:         TIMESTAMP,
+		timestamp.ServerKey:   TIMESTAMP,
+		timestamp.ClientKey:   TIMESTAMP,
+		timestamp.LoadKey:     TIMESTAMP,
 		"eventn_ctx_utc_time": TIMESTAMP,
 	}
 	convertRules = map[rule]ConvertFunc{
@@ -0,0 +1,180 @@
+package users
+
+import (
+	"fmt"
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/jsonutils"
+	"github.com/jitsucom/eventnative/logging"
+	"github.com/jitsucom/eventnative/meta"
+	"sync"
+	"time"
+)
+
+const (
+	identitiesCacheSize = 100000
+	identitiesCacheTTL  = time.Minute
+)
+
+//IdentityStitchingConfig is a configuration of anonymous id -> user id mappings
+type IdentityStitchingConfig struct {
+	Enabled         bool   `mapstructure:"enabled" json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	AnonymousIdNode string `mapstructure:"anonymous_id_node" json:"anonymous_id_node,omitempty" yaml:"anonymous_id_node,omitempty"`
+	UserIdNode      string `mapstructure:"user_id_node" json:"user_id_node,omitempty" yaml:"user_id_node,omitempty"`
+	EventTypeNode   string `mapstructure:"event_type_node" json:"event_type_node,omitempty" yaml:"event_type_node,omitempty"`
+	//IdentifyEvents are event types which create mappings. If empty, every event with both ids creates a mapping
+	IdentifyEvents []string `mapstructure:"identify_events" json:"identify_events,omitempty" yaml:"identify_events,omitempty"`
+	//TTLDays is a mapping expiration since the last identify event. 0 means without expiration
+	TTLDays int `mapstructure:"ttl_days" json:"ttl_days,omitempty" yaml:"ttl_days,omitempty"`
+}
+
+type cachedIdentity struct {
+	userId    string
+	expiresAt time.Time
+}
+
+//IdentityService maintains anonymous id -> user id mappings in meta storage from identify events
+//and enriches subsequent anonymous events (e.g. from other devices sessions after logout) with the resolved user id
+type IdentityService struct {
+	sync.RWMutex
+
+	metaStorage     meta.Storage
+	anonymousIdPath *jsonutils.JsonPath
+	userIdPath      *jsonutils.JsonPath
+	eventTypePath   *jsonutils.JsonPath
+	identifyEvents  map[string]bool
+	ttl             time.Duration
+
+	//cache is used for preventing meta storage requests on every event
+	cache  map[string]cachedIdentity
+	closed bool
+}
+
+//NewIdentityService return IdentityService if enabled and if meta storage is configured
+func NewIdentityService(metaStorage meta.Storage, config *IdentityStitchingConfig) (*IdentityService, error) {
+	if config == nil || !config.Enabled || metaStorage == nil || metaStorage.Type() == meta.DummyType {
+		if config != nil && config.Enabled {
+			logging.Warnf("Identity stitching required meta storage configuration")
+		}
+
+		return &IdentityService{closed: true}, nil
+	}
+
+	if config.AnonymousIdNode == "" {
+		return nil, fmt.Errorf("Invalid identity stitching configuration: anonymous_id_node is required")
+	}
+	if config.UserIdNode == "" {
+		return nil, fmt.Errorf("Invalid identity stitching configuration: user_id_node is required")
+	}
+
+	identifyEvents := map[string]bool{}
+	for _, eventType := range config.IdentifyEvents {
+		identifyEvents[eventType] = true
+	}
+
+	return &IdentityService{
+		metaStorage:     metaStorage,
+		anonymousIdPath: jsonutils.NewJsonPath(config.AnonymousIdNode),
+		userIdPath:      jsonutils.NewJsonPath(config.UserIdNode),
+		eventTypePath:   jsonutils.NewJsonPath(config.EventTypeNode),
+		identifyEvents:  identifyEvents,
+		ttl:             time.Duration(config.TTLDays) * 24 * time.Hour,
+		cache:           map[string]cachedIdentity{},
+	}, nil
+}
+
+//Stitch save mapping if event is an identify event or set the resolved user id into anonymous event
+func (is *IdentityService) Stitch(event events.Event) {
+	if is.closed {
+		return
+	}
+
+	anonymousId, ok := is.anonymousIdPath.Get(event)
+	if !ok {
+		return
+	}
+	anonymousIdStr := fmt.Sprint(anonymousId)
+	if anonymousIdStr == "" {
+		return
+	}
+
+	if userId, ok := is.userIdPath.Get(event); ok && fmt.Sprint(userId) != "" {
+		if is.isIdentifyEvent(event) {
+			is.identify(anonymousIdStr, fmt.Sprint(userId))
+		}
+		return
+	}
+
+	userId, err := is.resolve(anonymousIdStr)
+	if err != nil {
+		logging.SystemErrorf("Error getting user id by anonymous id [%s] from meta storage: %v", anonymousIdStr, err)
+		return
+	}
+	if userId == "" {
+		return
+	}
+
+	if err := is.userIdPath.Set(event, userId); err != nil {
+		logging.Errorf("Error setting resolved user id into event: %s with json path rule [%s]: %v", event.Serialize(), is.userIdPath.String(), err)
+	}
+}
+
+func (is *IdentityService) isIdentifyEvent(event events.Event) bool {
+	if len(is.identifyEvents) == 0 {
+		return true
+	}
+
+	eventType, ok := is.eventTypePath.Get(event)
+	return ok && is.identifyEvents[fmt.Sprint(eventType)]
+}
+
+//identify save mapping into meta storage. Mappings are re-saved (ttl is prolonged) at most once per cache ttl
+func (is *IdentityService) identify(anonymousId, userId string) {
+	is.RLock()
+	cached, ok := is.cache[anonymousId]
+	is.RUnlock()
+	if ok && cached.userId == userId && time.Now().Before(cached.expiresAt) {
+		return
+	}
+
+	if err := is.metaStorage.SaveIdentity(anonymousId, userId, is.ttl); err != nil {
+		logging.SystemErrorf("Error saving anonymous id [%s] -> user id [%s] mapping into meta storage: %v", anonymousId, userId, err)
+		return
+	}
+
+	is.putCache(anonymousId, userId)
+}
+
+//resolve return user id from cache or meta storage
+func (is *IdentityService) resolve(anonymousId string) (string, error) {
+	is.RLock()
+	cached, ok := is.cache[anonymousId]
+	is.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.userId, nil
+	}
+
+	userId, err := is.metaStorage.GetIdentity(anonymousId)
+	if err != nil {
+		return "", err
+	}
+
+	is.putCache(anonymousId, userId)
+	return userId, nil
+}
+
+//putCache put user id (or empty string if anonymous id isn't identified yet) into cache
+//cache is reset when it exceeds max size
+func (is *IdentityService) putCache(anonymousId, userId string) {
+	is.Lock()
+	defer is.Unlock()
+
+	if len(is.cache) >= identitiesCacheSize {
+		is.cache = map[string]cachedIdentity{}
+	}
+	is.cache[anonymousId] = cachedIdentity{userId: userId, expiresAt: time.Now().Add(identitiesCacheTTL)}
+}
+
+func (is *IdentityService) Close() error {
+	is.closed = true
+	return nil
+}
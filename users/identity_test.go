@@ -0,0 +1,62 @@
+package users
+
+import (
+	"github.com/jitsucom/eventnative/events"
+	"github.com/jitsucom/eventnative/meta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type identitiesStorage struct {
+	meta.Dummy
+	identities map[string]string
+}
+
+func (is *identitiesStorage) SaveIdentity(anonymousId, userId string, ttl time.Duration) error {
+	is.identities[anonymousId] = userId
+	return nil
+}
+
+func (is *identitiesStorage) GetIdentity(anonymousId string) (string, error) {
+	return is.identities[anonymousId], nil
+}
+
+func (is *identitiesStorage) Type() string {
+	return meta.RedisType
+}
+
+func TestIdentityStitching(t *testing.T) {
+	storage := &identitiesStorage{identities: map[string]string{}}
+	service, err := NewIdentityService(storage, &IdentityStitchingConfig{
+		Enabled:         true,
+		AnonymousIdNode: "/eventn_ctx/user/anonymous_id",
+		UserIdNode:      "/eventn_ctx/user/internal_id",
+		EventTypeNode:   "/event_type",
+		IdentifyEvents:  []string{"identify"},
+	})
+	require.NoError(t, err)
+
+	//not identify event
+	pageview := events.Event{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1", "internal_id": "user2"}}}
+	service.Stitch(pageview)
+	require.Empty(t, storage.identities)
+
+	anonymous := events.Event{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	service.Stitch(anonymous)
+	require.Equal(t, map[string]interface{}{"anonymous_id": "anon1"}, anonymous["eventn_ctx"].(map[string]interface{})["user"])
+
+	identify := events.Event{"event_type": "identify", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1", "internal_id": "user1"}}}
+	service.Stitch(identify)
+	require.Equal(t, map[string]string{"anon1": "user1"}, storage.identities)
+
+	anonymous = events.Event{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon1"}}}
+	service.Stitch(anonymous)
+	require.Equal(t, map[string]interface{}{"anonymous_id": "anon1", "internal_id": "user1"}, anonymous["eventn_ctx"].(map[string]interface{})["user"])
+
+	//resolved from meta storage (e.g. identified on another node)
+	storage.identities["anon2"] = "user3"
+	another := events.Event{"eventn_ctx": map[string]interface{}{"user": map[string]interface{}{"anonymous_id": "anon2"}}}
+	service.Stitch(another)
+	require.Equal(t, "user3", another["eventn_ctx"].(map[string]interface{})["user"].(map[string]interface{})["internal_id"])
+}
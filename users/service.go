@@ -257,6 +257,11 @@ func (rs *RecognitionService) runPipeline(destinationId string, identifiers Even
 func (rs *RecognitionService) Close() error {
 	rs.closed = true
 
+	//queue is nil if users recognition is disabled
+	if rs.queue == nil {
+		return nil
+	}
+
 	if err := rs.queue.Close(); err != nil {
 		return fmt.Errorf("Error closing users recognition queue: %v", err)
 	}